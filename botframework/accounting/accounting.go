@@ -0,0 +1,357 @@
+// Package accounting records what every inference request used (its model, API key,
+// tokens and duration) and sums it over date ranges for chargeback reports.
+package accounting
+
+import (
+	"botframework/auth"
+	"botframework/chat"
+	"botframework/engine"
+	"botframework/usage"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is one request
+type Record struct {
+	Time  time.Time `json:"time"`
+	Model string    `json:"model"`
+	// Key is the ID of the API key the request was made with, empty without API keys
+	Key              string  `json:"key,omitempty"`
+	Path             string  `json:"path"`
+	Status           int     `json:"status"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	DurationMS       float64 `json:"duration_ms"`
+}
+
+// Store keeps records
+type Store interface {
+	Add(record Record) error
+	// Scan calls fn with each record from from up to (not including) to, oldest first
+	Scan(from, to time.Time, fn func(Record) error) error
+}
+
+// FileStore appends records to a JSON Lines file per UTC day, so a report reads only the
+// days it covers. It serves hosts without the sqlite3 shell SQLiteStore runs.
+type FileStore struct {
+	Dir string
+
+	mu   sync.Mutex
+	day  string
+	file *os.File
+}
+
+// NewFileStore opens (or creates) a record store in dir
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+func (s *FileStore) path(day string) string {
+	return filepath.Join(s.Dir, "usage-"+day+".jsonl")
+}
+
+func (s *FileStore) Add(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	day := record.Time.UTC().Format(time.DateOnly)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil || s.day != day {
+		if s.file != nil {
+			s.file.Close()
+		}
+		file, err := os.OpenFile(s.path(day), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			s.file = nil
+			return err
+		}
+		s.file, s.day = file, day
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileStore) Scan(from, to time.Time, fn func(Record) error) error {
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.Add(24 * time.Hour) {
+		if err := s.scanDay(day.Format(time.DateOnly), from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) scanDay(day string, from, to time.Time, fn func(Record) error) error {
+	// records being appended are read up to the last complete line
+	s.mu.Lock()
+	file, err := os.Open(s.path(day))
+	s.mu.Unlock()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var record Record
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			continue
+		}
+		if record.Time.Before(from) || !record.Time.Before(to) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Close closes the file being appended to
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// Ledger records every request that passes its middleware in a Store
+type Ledger struct {
+	Store Store
+
+	now func() time.Time
+}
+
+func NewLedger(store Store) *Ledger {
+	return &Ledger{Store: store, now: time.Now}
+}
+
+// Middleware records each POST request once its response is complete: the model it
+// named (or the one that served it), its API key, status, duration and the token usage
+// the response reports. Streams that never report usage take the counts of a
+// chat.UsageFiller behind the ledger, or count one completion token per event without one.
+func (l *Ledger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		model, _ := engine.RequestedModel(r)
+		r, counted := chat.WithUsageReport(r)
+		start := l.now()
+		uw := usage.NewWriter(w)
+		next.ServeHTTP(uw, r)
+
+		if model == "" {
+			model = w.Header().Get(engine.ServedByHeader)
+		}
+		record := Record{
+			Time:       start,
+			Model:      model,
+			Path:       r.URL.Path,
+			Status:     uw.Status(),
+			DurationMS: float64(l.now().Sub(start).Microseconds()) / 1000,
+		}
+		if record.Model == "" {
+			record.Model = "default"
+		}
+		if record.Status == 0 {
+			record.Status = http.StatusOK
+		}
+		if key, ok := auth.KeyFrom(r.Context()); ok {
+			record.Key = key.ID
+		}
+		record.PromptTokens, record.CompletionTokens = tokens(uw, counted)
+		if err := l.Store.Add(record); err != nil {
+			slog.Warn("usage not recorded", "model", record.Model, "err", err)
+		}
+	})
+}
+
+// Grouping names a record field reports can be grouped by
+type Grouping string
+
+const (
+	ByModel Grouping = "model"
+	ByKey   Grouping = "key"
+	ByDay   Grouping = "day"
+	ByPath  Grouping = "path"
+)
+
+// Groupings lists every field reports can be grouped by
+var Groupings = []Grouping{ByModel, ByKey, ByDay, ByPath}
+
+// Query selects the records a report covers, from From up to (not including) To, and
+// how they are grouped; no groupings sums them all
+type Query struct {
+	From    time.Time
+	To      time.Time
+	GroupBy []Grouping
+}
+
+// Total sums a group of records. The fields the report is grouped by name the group.
+type Total struct {
+	Model            string  `json:"model,omitempty"`
+	Key              string  `json:"key,omitempty"`
+	Day              string  `json:"day,omitempty"`
+	Path             string  `json:"path,omitempty"`
+	Requests         int     `json:"requests"`
+	Errors           int     `json:"errors"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	AvgLatencyMS     float64 `json:"avg_latency_ms"`
+	P95LatencyMS     float64 `json:"p95_latency_ms"`
+
+	latencies []float64
+}
+
+func (t *Total) add(record Record) {
+	t.Requests++
+	if record.Status >= 400 {
+		t.Errors++
+	}
+	t.PromptTokens += record.PromptTokens
+	t.CompletionTokens += record.CompletionTokens
+	t.TotalTokens += record.PromptTokens + record.CompletionTokens
+	t.latencies = append(t.latencies, record.DurationMS)
+}
+
+func (t *Total) finish() {
+	if len(t.latencies) == 0 {
+		return
+	}
+	slices.Sort(t.latencies)
+	sum := 0.0
+	for _, latency := range t.latencies {
+		sum += latency
+	}
+	t.AvgLatencyMS = math.Round(sum/float64(len(t.latencies))*10) / 10
+	t.P95LatencyMS = t.latencies[int(math.Ceil(0.95*float64(len(t.latencies))))-1]
+	t.latencies = nil
+}
+
+// Report is the usage of a date range, in total and per group
+type Report struct {
+	From    time.Time  `json:"from"`
+	To      time.Time  `json:"to"`
+	GroupBy []Grouping `json:"group_by"`
+	Total   Total      `json:"total"`
+	Groups  []Total    `json:"groups"`
+}
+
+// Report sums the records q selects, with groups sorted by their fields in GroupBy order
+func (l *Ledger) Report(q Query) (*Report, error) {
+	for _, grouping := range q.GroupBy {
+		if !slices.Contains(Groupings, grouping) {
+			return nil, fmt.Errorf("cannot group by %q (want %s)", grouping, groupingNames())
+		}
+	}
+	if !q.From.Before(q.To) {
+		return nil, errors.New("the range must start before it ends")
+	}
+	report := &Report{From: q.From, To: q.To, GroupBy: q.GroupBy, Groups: []Total{}}
+	groups := make(map[string]*Total)
+	err := l.Store.Scan(q.From, q.To, func(record Record) error {
+		report.Total.add(record)
+		if len(q.GroupBy) == 0 {
+			return nil
+		}
+		group := Total{}
+		var id strings.Builder
+		for _, grouping := range q.GroupBy {
+			value := ""
+			switch grouping {
+			case ByModel:
+				value, group.Model = record.Model, record.Model
+			case ByKey:
+				value, group.Key = record.Key, record.Key
+			case ByDay:
+				day := record.Time.UTC().Format(time.DateOnly)
+				value, group.Day = day, day
+			case ByPath:
+				value, group.Path = record.Path, record.Path
+			}
+			id.WriteString(value + "\x00")
+		}
+		total, ok := groups[id.String()]
+		if !ok {
+			total = &group
+			groups[id.String()] = total
+		}
+		total.add(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Total.finish()
+	for _, total := range groups {
+		total.finish()
+		report.Groups = append(report.Groups, *total)
+	}
+	slices.SortFunc(report.Groups, func(a, b Total) int {
+		for _, grouping := range q.GroupBy {
+			if c := strings.Compare(a.field(grouping), b.field(grouping)); c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return report, nil
+}
+
+func (t Total) field(grouping Grouping) string {
+	switch grouping {
+	case ByModel:
+		return t.Model
+	case ByKey:
+		return t.Key
+	case ByDay:
+		return t.Day
+	case ByPath:
+		return t.Path
+	}
+	return ""
+}
+
+func groupingNames() string {
+	names := make([]string, len(Groupings))
+	for i, grouping := range Groupings {
+		names[i] = string(grouping)
+	}
+	return strings.Join(names, ", ")
+}
+
+// tokens returns the prompt and completion tokens of the response
+func tokens(uw *usage.Writer, counted *chat.UsageReport) (int, int) {
+	if prompt, completion, reported := uw.Tokens(); reported {
+		return prompt, completion
+	}
+	if counted.Counted {
+		return counted.PromptTokens, counted.CompletionTokens
+	}
+	return 0, uw.Chunks()
+}
@@ -0,0 +1,132 @@
+package accounting
+
+import (
+	"botframework/auth"
+	"botframework/chat"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLedger(t *testing.T) (*Ledger, *FileStore) {
+	t.Helper()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return NewLedger(store), store
+}
+
+func TestMiddlewareRecordsRequests(t *testing.T) {
+	ledger, store := newTestLedger(t)
+	clock := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	ledger.now = func() time.Time {
+		clock = clock.Add(250 * time.Millisecond)
+		return clock
+	}
+	handler := ledger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), `"stream":true`) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+			io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"!\"}}]}\n\ndata: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":30,"total_tokens":42}}`)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"fast"}`))
+	req = req.WithContext(auth.WithKey(req.Context(), &auth.Key{ID: "team-a"}))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"fast","stream":true}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	// reads are not inference
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+
+	var records []Record
+	store.Scan(clock.Add(-time.Hour), clock.Add(time.Hour), func(record Record) error {
+		records = append(records, record)
+		return nil
+	})
+	if len(records) != 2 {
+		t.Fatalf("recorded %d requests, want 2: %+v", len(records), records)
+	}
+	if r := records[0]; r.Model != "fast" || r.Key != "team-a" || r.PromptTokens != 12 || r.CompletionTokens != 30 ||
+		r.Status != http.StatusOK || r.DurationMS != 250 {
+		t.Errorf("first record = %+v", r)
+	}
+	if r := records[1]; r.Key != "" || r.PromptTokens != 0 || r.CompletionTokens != 2 {
+		t.Errorf("streamed record = %+v, want two completion tokens counted from its events", r)
+	}
+}
+
+func TestMiddlewareTakesCountedStreamUsage(t *testing.T) {
+	ledger, store := newTestLedger(t)
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"sixteen letters!\"}}]}\n\ndata: [DONE]\n\n")
+	})
+	handler := ledger.Middleware(chat.NewUsageFiller(chat.EstimateCounter{}).Middleware(backend))
+	body := `{"model":"fast","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+
+	var record Record
+	store.Scan(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(r Record) error {
+		record = r
+		return nil
+	})
+	if record.PromptTokens == 0 || record.CompletionTokens != 4 {
+		t.Errorf("record = %+v, want the filler's counts", record)
+	}
+}
+
+func TestReportGroupsByDateRange(t *testing.T) {
+	ledger, store := newTestLedger(t)
+	day := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	for _, record := range []Record{
+		{Time: day, Model: "fast", Key: "a", Status: 200, PromptTokens: 10, CompletionTokens: 20, DurationMS: 100},
+		{Time: day.Add(time.Hour), Model: "fast", Key: "b", Status: 200, PromptTokens: 5, CompletionTokens: 5, DurationMS: 300},
+		{Time: day.AddDate(0, 0, 1), Model: "quality", Key: "a", Status: 500, DurationMS: 50},
+		{Time: day.AddDate(0, 0, 3), Model: "fast", Key: "a", Status: 200, PromptTokens: 100, DurationMS: 10},
+	} {
+		if err := store.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := ledger.Report(Query{From: day.Truncate(24 * time.Hour), To: day.AddDate(0, 0, 2), GroupBy: []Grouping{ByModel}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Requests != 3 || report.Total.TotalTokens != 40 || report.Total.Errors != 1 {
+		t.Errorf("total = %+v, want the three records in range", report.Total)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Model != "fast" || report.Groups[1].Model != "quality" {
+		t.Fatalf("groups = %+v", report.Groups)
+	}
+	fast := report.Groups[0]
+	if fast.Requests != 2 || fast.PromptTokens != 15 || fast.AvgLatencyMS != 200 || fast.P95LatencyMS != 300 {
+		t.Errorf("fast = %+v", fast)
+	}
+
+	report, err = ledger.Report(Query{From: day.AddDate(0, 0, -1), To: day.AddDate(0, 0, 5), GroupBy: []Grouping{ByKey, ByDay}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var groups []string
+	for _, group := range report.Groups {
+		groups = append(groups, group.Key+"/"+group.Day)
+	}
+	if got := strings.Join(groups, " "); got != "a/2026-10-01 a/2026-10-02 a/2026-10-04 b/2026-10-01" {
+		t.Errorf("groups = %s", got)
+	}
+
+	if _, err := ledger.Report(Query{From: day, To: day.Add(time.Hour), GroupBy: []Grouping{"colour"}}); err == nil {
+		t.Error("an unknown grouping should be rejected")
+	}
+}
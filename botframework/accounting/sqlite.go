@@ -0,0 +1,189 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLRunner executes a SQL script against a SQLite database and returns its output, a JSON
+// array of the rows its queries return
+type SQLRunner func(ctx context.Context, path, script string) ([]byte, error)
+
+// RunSQLite shells out to the sqlite3 shell, keeping the manager free of a cgo driver
+// dependency
+func RunSQLite(ctx context.Context, path, script string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "sqlite3", "-bail", "-json", path)
+	// lock waits are bounded; a report reading the table never blocks records for long
+	cmd.Stdin = strings.NewReader(".timeout 5000\n" + script)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sqlite3: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+const sqliteSchema = `PRAGMA journal_mode=WAL;
+CREATE TABLE IF NOT EXISTS requests (
+	time_ns           INTEGER NOT NULL,
+	model             TEXT    NOT NULL,
+	key               TEXT    NOT NULL,
+	path              TEXT    NOT NULL,
+	status            INTEGER NOT NULL,
+	prompt_tokens     INTEGER NOT NULL,
+	completion_tokens INTEGER NOT NULL,
+	duration_ms       REAL    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS requests_time ON requests (time_ns);
+`
+
+// SQLiteStore keeps records in the requests table of a SQLite database. Records are
+// written in the background, those added while a write runs going in its successor's
+// transaction, so recording never holds up a response; Scan and Close wait for them.
+type SQLiteStore struct {
+	Path string
+	Run  SQLRunner
+
+	mu      sync.Mutex
+	pending []Record
+	written chan struct{} // closed once pending is empty and no write runs; nil when idle
+}
+
+// NewSQLiteStore opens (or creates) the database at path with the sqlite3 shell
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		return nil, fmt.Errorf("the SQLite usage store needs the sqlite3 shell: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	// the database holds API key IDs and traffic; SQLite gives its journal the same mode
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+	s := &SQLiteStore{Path: path, Run: RunSQLite}
+	if _, err := s.Run(context.Background(), path, sqliteSchema); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) Add(record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, record)
+	if s.written == nil {
+		s.written = make(chan struct{})
+		go s.write()
+	}
+	return nil
+}
+
+// write inserts pending records until none are left
+func (s *SQLiteStore) write() {
+	for {
+		s.mu.Lock()
+		records := s.pending
+		s.pending = nil
+		if len(records) == 0 {
+			close(s.written)
+			s.written = nil
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+		if _, err := s.Run(context.Background(), s.Path, insertScript(records)); err != nil {
+			slog.Warn("usage not recorded", "records", len(records), "err", err)
+		}
+	}
+}
+
+// Flush waits until the records added so far are written
+func (s *SQLiteStore) Flush() {
+	s.mu.Lock()
+	written := s.written
+	s.mu.Unlock()
+	if written != nil {
+		<-written
+	}
+}
+
+func (s *SQLiteStore) Scan(from, to time.Time, fn func(Record) error) error {
+	s.Flush()
+	out, err := s.Run(context.Background(), s.Path, fmt.Sprintf(
+		"SELECT time_ns, model, key, path, status, prompt_tokens, completion_tokens, duration_ms FROM requests WHERE time_ns >= %d AND time_ns < %d ORDER BY time_ns;\n",
+		from.UnixNano(), to.UnixNano()))
+	if err != nil {
+		return err
+	}
+	var rows []struct {
+		TimeNS           int64   `json:"time_ns"`
+		Model            string  `json:"model"`
+		Key              string  `json:"key"`
+		Path             string  `json:"path"`
+		Status           int     `json:"status"`
+		PromptTokens     int     `json:"prompt_tokens"`
+		CompletionTokens int     `json:"completion_tokens"`
+		DurationMS       float64 `json:"duration_ms"`
+	}
+	// the shell prints nothing for a query without rows
+	if len(bytes.TrimSpace(out)) > 0 {
+		if err := json.Unmarshal(out, &rows); err != nil {
+			return fmt.Errorf("sqlite3 output: %w", err)
+		}
+	}
+	for _, row := range rows {
+		record := Record{
+			Time:             time.Unix(0, row.TimeNS).UTC(),
+			Model:            row.Model,
+			Key:              row.Key,
+			Path:             row.Path,
+			Status:           row.Status,
+			PromptTokens:     row.PromptTokens,
+			CompletionTokens: row.CompletionTokens,
+			DurationMS:       row.DurationMS,
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close waits for the records added so far to be written
+func (s *SQLiteStore) Close() error {
+	s.Flush()
+	return nil
+}
+
+// insertScript inserts records in one transaction
+func insertScript(records []Record) string {
+	var script strings.Builder
+	script.WriteString("BEGIN;\n")
+	for _, r := range records {
+		fmt.Fprintf(&script, "INSERT INTO requests VALUES (%d, %s, %s, %s, %d, %d, %d, %s);\n", r.Time.UnixNano(), sqlQuote(r.Model),
+			sqlQuote(r.Key), sqlQuote(r.Path), r.Status, r.PromptTokens, r.CompletionTokens, strconv.FormatFloat(r.DurationMS, 'f', -1, 64))
+	}
+	script.WriteString("COMMIT;\n")
+	return script.String()
+}
+
+// sqlQuote makes s a SQL string literal. Model names come from requests; NUL bytes, which
+// would end the script early, are dropped.
+func sqlQuote(s string) string {
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,75 @@
+package accounting
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("needs the sqlite3 shell")
+	}
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "usage", "usage.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStoreScansRange(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	day := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: day, Model: "fast", Key: "team-a", Path: "/v1/chat/completions", Status: 200, PromptTokens: 10, CompletionTokens: 20, DurationMS: 12.5},
+		{Time: day.Add(time.Hour), Model: "it's; DROP TABLE requests; --", Path: "/v1/completions", Status: 500, DurationMS: 3},
+		{Time: day.AddDate(0, 0, 1), Model: "quality", Key: "team-b", Path: "/v1/chat/completions", Status: 200, CompletionTokens: 7},
+	}
+	for _, record := range records {
+		if err := store.Add(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []Record
+	err := store.Scan(day, day.AddDate(0, 0, 1), func(record Record) error {
+		got = append(got, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != records[0] || got[1] != records[1] {
+		t.Errorf("scanned %+v, want the first two records", got)
+	}
+	if err := store.Scan(day.AddDate(0, 0, 5), day.AddDate(0, 0, 6), func(Record) error {
+		t.Error("a range without records returned one")
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(store.Path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("database mode = %v, %v; want 0600", info.Mode(), err)
+	}
+}
+
+func TestSQLiteStoreReports(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ledger := NewLedger(store)
+	day := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	for i := range 50 {
+		store.Add(Record{Time: day.Add(time.Duration(i) * time.Minute), Model: "fast", Key: "team-a", Status: 200, PromptTokens: 1, CompletionTokens: 2})
+	}
+
+	report, err := ledger.Report(Query{From: day, To: day.AddDate(0, 0, 1), GroupBy: []Grouping{ByKey}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Requests != 50 || report.Total.TotalTokens != 150 || len(report.Groups) != 1 || report.Groups[0].Key != "team-a" {
+		t.Errorf("report = %+v", report)
+	}
+}
@@ -0,0 +1,284 @@
+// Package anthropic serves the Anthropic Messages API (/v1/messages) on top of the manager's
+// OpenAI-compatible chat completions route, so clients built on the Anthropic SDK can talk
+// to local models unchanged: system prompts, content blocks, tool use and the streaming
+// event format are translated both ways.
+package anthropic
+
+import (
+	"botframework/chat"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Paths the server answers
+const (
+	MessagesPath    = "/v1/messages"
+	CountTokensPath = "/v1/messages/count_tokens"
+)
+
+// Server translates Messages requests into calls of Backend, the OpenAI-compatible
+// inference handler, and converts the responses back
+type Server struct {
+	Backend http.Handler
+	// Counter answers count_tokens and the input tokens announced when a stream starts
+	Counter chat.TokenCounter
+}
+
+func NewServer(backend http.Handler, counter chat.TokenCounter) *Server {
+	return &Server{Backend: backend, Counter: counter}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var req MessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if req.Model == "" {
+		writeError(w, http.StatusBadRequest, "model: field required")
+		return
+	}
+	body, err := openAIBody(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch r.URL.Path {
+	case CountTokensPath:
+		writeJSON(w, http.StatusOK, map[string]int{"input_tokens": s.countTokens(req.Model, body)})
+	case MessagesPath:
+		if req.MaxTokens <= 0 {
+			writeError(w, http.StatusBadRequest, "max_tokens: must be at least 1")
+			return
+		}
+		if req.Stream {
+			s.stream(w, r, req, body)
+			return
+		}
+		s.complete(w, r, req, body)
+	default:
+		writeError(w, http.StatusNotFound, r.URL.Path+" is not supported")
+	}
+}
+
+// countTokens counts the translated prompt, tool definitions included
+func (s *Server) countTokens(model string, body map[string]any) int {
+	counter := s.Counter
+	if counter == nil {
+		counter = chat.EstimateCounter{}
+	}
+	var messages []chat.Message
+	raw, _ := json.Marshal(body["messages"])
+	_ = json.Unmarshal(raw, &messages)
+	tokens := counter.CountTokens(model, messages)
+	if tools, ok := body["tools"]; ok {
+		if counter, ok := counter.(chat.TextCounter); ok {
+			raw, _ := json.Marshal(tools)
+			tokens += counter.CountText(model, string(raw))
+		}
+	}
+	return tokens
+}
+
+// complete answers with one message holding the text and tool_use blocks
+func (s *Server) complete(w http.ResponseWriter, r *http.Request, req MessagesRequest, body map[string]any) {
+	bw := &backendWriter{header: http.Header{}}
+	if !s.call(w, r, body, bw) {
+		return
+	}
+	var completion openAICompletion
+	if err := json.Unmarshal(bw.body.Bytes(), &completion); err != nil {
+		writeError(w, http.StatusBadGateway, "invalid backend response: "+err.Error())
+		return
+	}
+
+	content := []map[string]any{}
+	finish := "stop"
+	for _, choice := range completion.Choices[:min(1, len(completion.Choices))] {
+		if choice.Message.Content != "" {
+			content = append(content, map[string]any{"type": "text", "text": choice.Message.Content})
+		}
+		for _, call := range choice.Message.ToolCalls {
+			content = append(content, map[string]any{"type": "tool_use", "id": toolUseID(call.ID),
+				"name": call.Function.Name, "input": toolInput(call.Function.Arguments)})
+		}
+		if choice.Finish != nil {
+			finish = *choice.Finish
+		}
+	}
+	usage := map[string]int{"input_tokens": 0, "output_tokens": 0}
+	if completion.Usage != nil {
+		usage["input_tokens"], usage["output_tokens"] = completion.Usage.PromptTokens, completion.Usage.CompletionTokens
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":            messageID(),
+		"type":          "message",
+		"role":          "assistant",
+		"model":         req.Model,
+		"content":       content,
+		"stop_reason":   stopReason(finish),
+		"stop_sequence": nil,
+		"usage":         usage,
+	})
+}
+
+// call sends body to the backend's chat completions route. Failures are answered in
+// Anthropic's error shape, with the backend's status and message, and reported as false.
+func (s *Server) call(w http.ResponseWriter, r *http.Request, body map[string]any, bw *backendWriter) bool {
+	data, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	forward, err := http.NewRequestWithContext(r.Context(), http.MethodPost, chat.CompletionsPath, bytes.NewReader(data))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	for _, name := range []string{"Authorization", "X-Api-Key", "X-Request-Id"} {
+		if value := r.Header.Get(name); value != "" {
+			forward.Header.Set(name, value)
+		}
+	}
+	forward.Header.Set("Content-Type", "application/json")
+	forward.RemoteAddr = r.RemoteAddr
+	s.Backend.ServeHTTP(bw, forward)
+
+	if bw.status >= 300 {
+		if retry := bw.header.Get("Retry-After"); retry != "" {
+			w.Header().Set("Retry-After", retry)
+		}
+		writeError(w, bw.status, backendMessage(bw.body.Bytes()))
+		return false
+	}
+	return true
+}
+
+// backendMessage extracts the message of an OpenAI error body
+func backendMessage(body []byte) string {
+	var openAIError struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &openAIError) == nil && openAIError.Error.Message != "" {
+		return openAIError.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// toolInput decodes the argument string of a tool call into the object tool_use carries
+func toolInput(arguments string) json.RawMessage {
+	if input := json.RawMessage(arguments); json.Valid(input) && strings.HasPrefix(strings.TrimSpace(arguments), "{") {
+		return input
+	}
+	return json.RawMessage("{}")
+}
+
+// toolUseID keeps the backend's call ID, which the client sends back in tool_result
+func toolUseID(id string) string {
+	if id == "" {
+		return "toolu_" + randomHex(12)
+	}
+	return id
+}
+
+func messageID() string {
+	return "msg_" + randomHex(12)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// errorTypes are Anthropic's error types by status
+var errorTypes = map[int]string{
+	http.StatusBadRequest:            "invalid_request_error",
+	http.StatusUnauthorized:          "authentication_error",
+	http.StatusForbidden:             "permission_error",
+	http.StatusNotFound:              "not_found_error",
+	http.StatusRequestEntityTooLarge: "request_too_large",
+	http.StatusTooManyRequests:       "rate_limit_error",
+	http.StatusServiceUnavailable:    "overloaded_error",
+}
+
+// errorBody is Anthropic's error shape, {"type": "error", "error": {"type": ..., "message": ...}}
+func errorBody(status int, message string) map[string]any {
+	errType, ok := errorTypes[status]
+	if !ok {
+		errType = "api_error"
+		if status < 500 {
+			errType = "invalid_request_error"
+		}
+	}
+	return map[string]any{"type": "error", "error": map[string]string{"type": errType, "message": message}}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorBody(status, message))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// backendWriter captures a backend response. Successful event streams are parsed as they
+// arrive and each data payload passed to onEvent; everything else is buffered.
+type backendWriter struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	onEvent  func(data []byte)
+	streamed bool
+	pending  []byte
+}
+
+func (b *backendWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *backendWriter) WriteHeader(code int) {
+	if b.status != 0 {
+		return
+	}
+	b.status = code
+	b.streamed = b.onEvent != nil && code < 300 && strings.HasPrefix(b.header.Get("Content-Type"), "text/event-stream")
+}
+
+func (b *backendWriter) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	if !b.streamed {
+		return b.body.Write(p)
+	}
+	b.pending = append(b.pending, p...)
+	for {
+		end := bytes.IndexByte(b.pending, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		line := bytes.TrimSpace(b.pending[:end])
+		b.pending = b.pending[end+1:]
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = bytes.TrimSpace(data)
+			if string(data) != "[DONE]" {
+				b.onEvent(data)
+			}
+		}
+	}
+}
+
+// Flush is a no-op: each translated event is flushed to the client as it is sent
+func (b *backendWriter) Flush() {}
@@ -0,0 +1,235 @@
+package anthropic
+
+import (
+	"botframework/auth"
+	"botframework/chat"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeBackend answers chat completions with "hello there" and a get_weather call, streamed
+// as chunks when asked, and model "missing" with a 404
+func fakeBackend(seen *map[string]any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if seen != nil {
+			*seen = body
+		}
+		if body["model"] == "missing" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"model missing is not loaded","type":"invalid_request_error"}}`)
+			return
+		}
+		if body["stream"] != true {
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"hello there","tool_calls":[{"id":"call_1","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":5,"completion_tokens":2}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"},\"finish_reason\":null}]}\n\n")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\" there\"},\"finish_reason\":null}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"function\":{\"name\":\"get_weather\",\"arguments\":\"\"}}]},\"finish_reason\":null}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"{\\\"city\\\":\\\"Paris\\\"}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":7}}\n\ndata: [DONE]\n\n")
+	})
+}
+
+func post(server *Server, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestMessagesTranslatesRequest(t *testing.T) {
+	var seen map[string]any
+	server := NewServer(fakeBackend(&seen), chat.EstimateCounter{})
+	rec := post(server, MessagesPath, `{"model":"llama-3-8b","max_tokens":64,"temperature":0.2,
+		"system":[{"type":"text","text":"be brief"}],
+		"tools":[{"name":"get_weather","description":"weather","input_schema":{"type":"object"}}],
+		"tool_choice":{"type":"any"},
+		"messages":[
+			{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"aGk="}}]},
+			{"role":"assistant","content":[{"type":"thinking","thinking":"hm"},{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}]},
+			{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"sunny","is_error":true},{"type":"text","text":"thanks"}]}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	if seen["max_tokens"] != 64.0 || seen["temperature"] != 0.2 || seen["tool_choice"] != "required" {
+		t.Errorf("backend request = %v", seen)
+	}
+	messages, _ := json.Marshal(seen["messages"])
+	want := `[{"content":"be brief","role":"system"},` +
+		`{"content":[{"text":"what is this?","type":"text"},{"image_url":{"url":"data:image/png;base64,aGk="},"type":"image_url"}],"role":"user"},` +
+		`{"content":"","role":"assistant","tool_calls":[{"function":{"arguments":"{\"city\":\"Paris\"}","name":"get_weather"},"id":"toolu_1","type":"function"}]},` +
+		`{"content":"Error: sunny","role":"tool","tool_call_id":"toolu_1"},` +
+		`{"content":"thanks","role":"user"}]`
+	if string(messages) != want {
+		t.Errorf("messages = %s\nwant       %s", messages, want)
+	}
+	tools, _ := json.Marshal(seen["tools"])
+	if string(tools) != `[{"function":{"description":"weather","name":"get_weather","parameters":{"type":"object"}},"type":"function"}]` {
+		t.Errorf("tools = %s", tools)
+	}
+}
+
+func TestMessagesResponse(t *testing.T) {
+	server := NewServer(fakeBackend(nil), nil)
+	rec := post(server, MessagesPath, `{"model":"llama-3-8b","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var message struct {
+		ID         string            `json:"id"`
+		Type       string            `json:"type"`
+		Role       string            `json:"role"`
+		Content    []json.RawMessage `json:"content"`
+		StopReason string            `json:"stop_reason"`
+		Usage      map[string]int    `json:"usage"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &message)
+	if !strings.HasPrefix(message.ID, "msg_") || message.Type != "message" || message.Role != "assistant" || message.StopReason != "tool_use" {
+		t.Errorf("message = %s", rec.Body)
+	}
+	if len(message.Content) != 2 || string(message.Content[0]) != `{"text":"hello there","type":"text"}` ||
+		string(message.Content[1]) != `{"id":"call_1","input":{"city":"Paris"},"name":"get_weather","type":"tool_use"}` {
+		t.Errorf("content = %s", message.Content)
+	}
+	if message.Usage["input_tokens"] != 5 || message.Usage["output_tokens"] != 2 {
+		t.Errorf("usage = %v", message.Usage)
+	}
+}
+
+func TestMessagesStreamEvents(t *testing.T) {
+	var seen map[string]any
+	server := NewServer(fakeBackend(&seen), nil)
+	rec := post(server, MessagesPath, `{"model":"llama-3-8b","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if options, _ := json.Marshal(seen["stream_options"]); string(options) != `{"include_usage":true}` {
+		t.Errorf("stream_options = %s", options)
+	}
+
+	var events []string
+	var data []map[string]any
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			events = append(events, event)
+		}
+		if payload, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var m map[string]any
+			json.Unmarshal([]byte(payload), &m)
+			data = append(data, m)
+		}
+	}
+	want := "message_start ping content_block_start content_block_delta content_block_delta content_block_stop " +
+		"content_block_start content_block_delta content_block_stop message_delta message_stop"
+	if strings.Join(events, " ") != want {
+		t.Fatalf("events = %v", events)
+	}
+	for i, m := range data {
+		if m["type"] != events[i] {
+			t.Errorf("event %s carries type %v", events[i], m["type"])
+		}
+	}
+	if delta, _ := json.Marshal(data[4]["delta"]); string(delta) != `{"text":" there","type":"text_delta"}` {
+		t.Errorf("text delta = %s", delta)
+	}
+	if block, _ := json.Marshal(data[6]); string(block) != `{"content_block":{"id":"call_1","input":{},"name":"get_weather","type":"tool_use"},"index":1,"type":"content_block_start"}` {
+		t.Errorf("tool block = %s", block)
+	}
+	if delta, _ := json.Marshal(data[7]["delta"]); string(delta) != `{"partial_json":"{\"city\":\"Paris\"}","type":"input_json_delta"}` {
+		t.Errorf("tool delta = %s", delta)
+	}
+	if end, _ := json.Marshal(data[9]); string(end) != `{"delta":{"stop_reason":"tool_use","stop_sequence":null},"type":"message_delta","usage":{"input_tokens":5,"output_tokens":7}}` {
+		t.Errorf("message_delta = %s", end)
+	}
+}
+
+func TestMessagesErrors(t *testing.T) {
+	server := NewServer(fakeBackend(nil), nil)
+	for _, tc := range []struct {
+		body, errType string
+		status        int
+	}{
+		{`{"model":"missing","max_tokens":8,"messages":[{"role":"user","content":"hi"}]}`, "not_found_error", http.StatusNotFound},
+		{`{"model":"missing","max_tokens":8,"stream":true,"messages":[{"role":"user","content":"hi"}]}`, "not_found_error", http.StatusNotFound},
+		{`{"model":"llama-3-8b","messages":[{"role":"user","content":"hi"}]}`, "invalid_request_error", http.StatusBadRequest},
+		{`{"model":"llama-3-8b","max_tokens":8,"messages":[{"role":"system","content":"hi"}]}`, "invalid_request_error", http.StatusBadRequest},
+	} {
+		rec := post(server, MessagesPath, tc.body)
+		var body struct {
+			Type  string `json:"type"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		if rec.Code != tc.status || body.Type != "error" || body.Error.Type != tc.errType || body.Error.Message == "" {
+			t.Errorf("%s: status %d, body %s", tc.body, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	server := NewServer(fakeBackend(nil), chat.EstimateCounter{})
+	plain := post(server, CountTokensPath, `{"model":"llama-3-8b","messages":[{"role":"user","content":"how is the weather in Paris?"}]}`)
+	withTools := post(server, CountTokensPath, `{"model":"llama-3-8b","messages":[{"role":"user","content":"how is the weather in Paris?"}],
+		"tools":[{"name":"get_weather","description":"current weather of a city","input_schema":{"type":"object","properties":{"city":{"type":"string"}}}}]}`)
+	var a, b map[string]int
+	json.Unmarshal(plain.Body.Bytes(), &a)
+	json.Unmarshal(withTools.Body.Bytes(), &b)
+	if plain.Code != http.StatusOK || a["input_tokens"] <= 0 || b["input_tokens"] <= a["input_tokens"] {
+		t.Errorf("input_tokens = %v without tools, %v with", a, b)
+	}
+}
+
+func TestMessagesCountAgainstDailyQuota(t *testing.T) {
+	keys := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keys, []byte(`{"keys": [{"id": "web", "token": "secret", "daily_tokens": 15}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := auth.LoadFileStore(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := auth.NewAuthenticator(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := a.Middleware(NewServer(fakeBackend(nil), nil))
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, MessagesPath, strings.NewReader(body))
+		req.Header.Set("x-api-key", "secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// 5 prompt and 2 completion tokens, then 5 and 7 streamed
+	if rec := send(`{"model":"llama-3-8b","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("message: status %d, body %s", rec.Code, rec.Body)
+	}
+	if rec := send(`{"model":"llama-3-8b","max_tokens":64,"stream":true,"messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusOK {
+		t.Fatalf("stream: status %d, body %s", rec.Code, rec.Body)
+	}
+	usage := a.Usage()
+	if len(usage) != 1 || len(usage[0].Days) != 1 || usage[0].Days[0].PromptTokens != 10 || usage[0].Days[0].CompletionTokens != 9 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if rec := send(`{"model":"llama-3-8b","max_tokens":64,"messages":[{"role":"user","content":"hi"}]}`); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over quota: status %d, body %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,151 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// eventWriter sends named server-sent events, flushing each
+type eventWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (e *eventWriter) send(event string, data map[string]any) {
+	if !e.started {
+		e.started = true
+		e.w.Header().Set("Content-Type", "text/event-stream")
+		e.w.Header().Set("Cache-Control", "no-cache")
+		e.w.WriteHeader(http.StatusOK)
+	}
+	data["type"] = event
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, payload)
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// streamState turns chat completion chunks into Messages stream events: message_start,
+// one content block per run of text or per tool call, message_delta with the stop reason
+// and usage, and message_stop
+type streamState struct {
+	events      *eventWriter
+	model       string
+	inputTokens int
+	started     bool
+	// block is the index of the open content block, -1 when none is; tool is the OpenAI
+	// index of the call an open tool_use block holds
+	block  int
+	tool   int
+	blocks int
+	finish string
+	// outputTokens is the backend's count, chunks the fallback for backends without usage
+	outputTokens int
+	chunks       int
+}
+
+func (st *streamState) begin() {
+	if st.started {
+		return
+	}
+	st.started = true
+	st.events.send("message_start", map[string]any{"message": map[string]any{
+		"id": messageID(), "type": "message", "role": "assistant", "model": st.model,
+		"content": []any{}, "stop_reason": nil, "stop_sequence": nil,
+		"usage": map[string]int{"input_tokens": st.inputTokens, "output_tokens": 0},
+	}})
+	st.events.send("ping", map[string]any{})
+}
+
+func (st *streamState) open(block map[string]any) {
+	st.close()
+	st.block = st.blocks
+	st.blocks++
+	st.events.send("content_block_start", map[string]any{"index": st.block, "content_block": block})
+}
+
+func (st *streamState) close() {
+	if st.block >= 0 {
+		st.events.send("content_block_stop", map[string]any{"index": st.block})
+		st.block = -1
+	}
+}
+
+func (st *streamState) delta(delta map[string]any) {
+	st.events.send("content_block_delta", map[string]any{"index": st.block, "delta": delta})
+}
+
+// add translates one chunk, or a whole completion read as one
+func (st *streamState) add(c openAICompletion) {
+	st.begin()
+	if c.Usage != nil {
+		st.inputTokens, st.outputTokens = c.Usage.PromptTokens, c.Usage.CompletionTokens
+	}
+	for _, choice := range c.Choices[:min(1, len(c.Choices))] {
+		delta := choice.Delta
+		if delta.Content == "" && len(delta.ToolCalls) == 0 {
+			delta = choice.Message
+		}
+		if delta.Content != "" {
+			if st.block < 0 || st.tool >= 0 {
+				st.open(map[string]any{"type": "text", "text": ""})
+				st.tool = -1
+			}
+			st.delta(map[string]any{"type": "text_delta", "text": delta.Content})
+			st.chunks++
+		}
+		for _, call := range delta.ToolCalls {
+			if st.block < 0 || st.tool != call.Index {
+				st.open(map[string]any{"type": "tool_use", "id": toolUseID(call.ID), "name": call.Function.Name, "input": map[string]any{}})
+				st.tool = call.Index
+			}
+			if call.Function.Arguments != "" {
+				st.delta(map[string]any{"type": "input_json_delta", "partial_json": call.Function.Arguments})
+				st.chunks++
+			}
+		}
+		if choice.Finish != nil && *choice.Finish != "" {
+			st.finish = *choice.Finish
+		}
+	}
+}
+
+// end closes the open block and the message
+func (st *streamState) end() {
+	st.begin()
+	st.close()
+	output := st.outputTokens
+	if output == 0 {
+		output = st.chunks
+	}
+	st.events.send("message_delta", map[string]any{
+		"delta": map[string]any{"stop_reason": stopReason(st.finish), "stop_sequence": nil},
+		"usage": map[string]int{"input_tokens": st.inputTokens, "output_tokens": output},
+	})
+	st.events.send("message_stop", map[string]any{})
+}
+
+// stream answers with Messages stream events. Backend errors before the first chunk are
+// answered as JSON errors, since the stream has not started.
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, req MessagesRequest, body map[string]any) {
+	st := &streamState{events: &eventWriter{w: w}, model: req.Model, inputTokens: s.countTokens(req.Model, body), block: -1, tool: -1}
+	bw := &backendWriter{header: http.Header{}}
+	bw.onEvent = func(data []byte) {
+		var chunk openAICompletion
+		if json.Unmarshal(data, &chunk) == nil {
+			st.add(chunk)
+		}
+	}
+	if !s.call(w, r, body, bw) {
+		return
+	}
+	if !bw.streamed {
+		// the backend answered a stream request with a single completion
+		var completion openAICompletion
+		json.Unmarshal(bw.body.Bytes(), &completion)
+		st.add(completion)
+	}
+	st.end()
+}
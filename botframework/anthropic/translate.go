@@ -0,0 +1,269 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// MessagesRequest is the body of POST /v1/messages
+type MessagesRequest struct {
+	Model     string    `json:"model"`
+	MaxTokens int       `json:"max_tokens"`
+	Messages  []Message `json:"messages"`
+	// System is a string or a list of text blocks
+	System        json.RawMessage `json:"system,omitempty"`
+	StopSequences []string        `json:"stop_sequences,omitempty"`
+	Stream        bool            `json:"stream,omitempty"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	TopP          *float64        `json:"top_p,omitempty"`
+	TopK          *int            `json:"top_k,omitempty"`
+	Tools         []Tool          `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice     `json:"tool_choice,omitempty"`
+}
+
+// Message is one turn; Content is a string or a list of content blocks
+type Message struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Block is a content block: text, image, tool_use or tool_result. Thinking blocks a client
+// sends back are dropped.
+type Block struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	// Source is the image of an image block
+	Source *ImageSource `json:"source,omitempty"`
+	// ID, Name and Input describe a tool_use block
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// ToolUseID, Content and IsError describe a tool_result block; Content is a string or
+	// a list of blocks
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// ImageSource is base64 data with its media type, or a URL
+type ImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+// ToolChoice is auto, any, tool (with Name) or none
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// blocks decodes content given as a string or as a list of blocks
+func blocks(content json.RawMessage) ([]Block, error) {
+	if len(content) == 0 || string(content) == "null" {
+		return nil, nil
+	}
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return []Block{{Type: "text", Text: text}}, nil
+	}
+	var list []Block
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("content must be a string or a list of blocks: %w", err)
+	}
+	return list, nil
+}
+
+// blockText joins the text blocks of content
+func blockText(content json.RawMessage) (string, error) {
+	list, err := blocks(content)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, block := range list {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// openAIMessages converts the system prompt and turns into chat completion messages.
+// Tool results become tool messages ahead of the rest of their user turn, and tool_use
+// blocks become the assistant's tool calls.
+func openAIMessages(req MessagesRequest) ([]map[string]any, error) {
+	var messages []map[string]any
+	system, err := blockText(req.System)
+	if err != nil {
+		return nil, fmt.Errorf("system: %w", err)
+	}
+	if system != "" {
+		messages = append(messages, map[string]any{"role": "system", "content": system})
+	}
+
+	for i, m := range req.Messages {
+		if m.Role != "user" && m.Role != "assistant" {
+			return nil, fmt.Errorf("messages.%d: role must be user or assistant, not %q", i, m.Role)
+		}
+		list, err := blocks(m.Content)
+		if err != nil {
+			return nil, fmt.Errorf("messages.%d: %w", i, err)
+		}
+		var parts []map[string]any
+		var calls []map[string]any
+		for _, block := range list {
+			switch block.Type {
+			case "text":
+				parts = append(parts, map[string]any{"type": "text", "text": block.Text})
+			case "image":
+				if block.Source == nil {
+					return nil, fmt.Errorf("messages.%d: image block without a source", i)
+				}
+				url := block.Source.URL
+				if block.Source.Type == "base64" {
+					url = "data:" + block.Source.MediaType + ";base64," + block.Source.Data
+				}
+				parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]string{"url": url}})
+			case "tool_use":
+				input := block.Input
+				if len(input) == 0 {
+					input = json.RawMessage("{}")
+				}
+				calls = append(calls, map[string]any{
+					"id":       block.ID,
+					"type":     "function",
+					"function": map[string]string{"name": block.Name, "arguments": string(input)},
+				})
+			case "tool_result":
+				result, err := blockText(block.Content)
+				if err != nil {
+					return nil, fmt.Errorf("messages.%d: tool_result: %w", i, err)
+				}
+				if block.IsError {
+					result = "Error: " + result
+				}
+				messages = append(messages, map[string]any{"role": "tool", "tool_call_id": block.ToolUseID, "content": result})
+			case "thinking", "redacted_thinking":
+			default:
+				return nil, fmt.Errorf("messages.%d: %s blocks are not supported", i, block.Type)
+			}
+		}
+		if len(parts) == 0 && len(calls) == 0 {
+			continue
+		}
+		message := map[string]any{"role": m.Role, "content": flatten(parts)}
+		if len(calls) > 0 {
+			message["tool_calls"] = calls
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// flatten sends text-only content as a string, which every backend accepts
+func flatten(parts []map[string]any) any {
+	var texts []string
+	for _, part := range parts {
+		if part["type"] != "text" {
+			return parts
+		}
+		texts = append(texts, part["text"].(string))
+	}
+	return strings.Join(texts, "\n")
+}
+
+// openAIBody builds the chat completion request for the backend
+func openAIBody(req MessagesRequest) (map[string]any, error) {
+	messages, err := openAIMessages(req)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]any{"model": req.Model, "messages": messages, "stream": req.Stream}
+	if req.Stream {
+		body["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	}
+	if len(req.StopSequences) > 0 {
+		body["stop"] = req.StopSequences
+	}
+	if req.Temperature != nil {
+		body["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		body["top_p"] = *req.TopP
+	}
+	if req.TopK != nil {
+		body["top_k"] = *req.TopK
+	}
+	if len(req.Tools) > 0 && (req.ToolChoice == nil || req.ToolChoice.Type != "none") {
+		tools := make([]map[string]any, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			function := map[string]any{"name": tool.Name, "parameters": tool.InputSchema}
+			if tool.Description != "" {
+				function["description"] = tool.Description
+			}
+			tools = append(tools, map[string]any{"type": "function", "function": function})
+		}
+		body["tools"] = tools
+		if req.ToolChoice != nil {
+			switch req.ToolChoice.Type {
+			case "any":
+				body["tool_choice"] = "required"
+			case "tool":
+				body["tool_choice"] = map[string]any{"type": "function", "function": map[string]string{"name": req.ToolChoice.Name}}
+			}
+		}
+	}
+	return body, nil
+}
+
+// stopReason maps an OpenAI finish reason to Anthropic's stop reason
+func stopReason(finish string) string {
+	switch finish {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	}
+	return "end_turn"
+}
+
+// openAICompletion is the part of chat completions, and of their chunks, that is
+// translated back
+type openAICompletion struct {
+	ID      string `json:"id"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+		Delta   openAIMessage `json:"delta"`
+		Finish  *string       `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+type openAIMessage struct {
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		Index    int    `json:"index"`
+		ID       string `json:"id"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"botframework/cache"
+	"botframework/energy"
+	"botframework/engine"
+	"botframework/metrics"
+	"botframework/profiler"
+	"botframework/vram"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type WorkerState struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Model    string `json:"model"`
+	Error    string `json:"error,omitempty"`
+	State    string `json:"state"`
+	Restarts int    `json:"restarts"`
+	LastExit string `json:"last_exit,omitempty"`
+}
+
+type AdminStatus struct {
+	UptimeSeconds float64                `json:"uptime_seconds"`
+	Traffic       metrics.Snapshot       `json:"traffic"`
+	Workers       []WorkerState          `json:"workers"`
+	Usage         profiler.ResourceUsage `json:"usage"`
+	// Queues are the request queues of the engines that have received requests
+	Queues []engine.QueueStats `json:"queues,omitempty"`
+}
+
+// queueReporter is an engine that queues requests, such as the model manager
+type queueReporter interface{ QueueStats() []engine.QueueStats }
+
+func HandleAdminStatus(workerEngine engine.InferenceEngine, recorder *metrics.Recorder, startedAt time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		lifecycle := workerEngine.Status()
+		worker := WorkerState{Name: "default", State: string(lifecycle.State), Restarts: lifecycle.Restarts, LastExit: lifecycle.LastExit}
+		health, err := workerEngine.Health()
+		if err != nil {
+			worker.Status = "unreachable"
+			worker.Error = err.Error()
+		} else {
+			worker.Status = health.Status
+			worker.Model = health.Model
+		}
+
+		status := AdminStatus{
+			UptimeSeconds: time.Since(startedAt).Seconds(),
+			Traffic:       recorder.Snapshot(),
+			Workers:       []WorkerState{worker},
+			Usage:         profiler.SampleUsage(),
+		}
+		if queues, ok := workerEngine.(queueReporter); ok {
+			status.Queues = queues.QueueStats()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			http.Error(w, "failed to write response", http.StatusInternalServerError)
+		}
+	}
+}
+
+// HandleAdminEnergy reports estimated energy and electricity cost per model
+func HandleAdminEnergy(meter *energy.Meter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, meter.Snapshot())
+	}
+}
+
+// HandleAdminCache reports response cache statistics (GET) or empties the cache (DELETE)
+func HandleAdminCache(c *cache.Cache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodDelete:
+			c.Purge()
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, c.Stats())
+	}
+}
+
+// HandleAdminVRAM reports live GPU memory and whether the low-VRAM actions are engaged
+func HandleAdminVRAM(monitor *vram.Monitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, monitor.Status())
+	}
+}
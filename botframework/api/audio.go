@@ -0,0 +1,17 @@
+package api
+
+import (
+	"botframework/audio"
+	"net/http"
+)
+
+// HandleAudioUsage reports audio seconds processed per model and task
+func HandleAudioUsage(transcriber *audio.Transcriber) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, transcriber.Usage())
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"botframework/batch"
+	"botframework/files"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// HandleBatches lists the caller's batches (GET, with ?after= and ?limit=) or creates one
+// from an uploaded JSONL file (POST with input_file_id, endpoint and completion_window)
+func HandleBatches(runner *batch.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner := files.Owner(r)
+		switch r.Method {
+		case http.MethodGet:
+			limit := 20
+			if raw := r.URL.Query().Get("limit"); raw != "" {
+				n, err := strconv.Atoi(raw)
+				if err != nil || n < 1 || n > 100 {
+					http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+					return
+				}
+				limit = n
+			}
+			batches, more := runner.List(owner, r.URL.Query().Get("after"), limit)
+			list := map[string]any{"object": "list", "data": batches, "has_more": more}
+			if len(batches) > 0 {
+				list["first_id"], list["last_id"] = batches[0].ID, batches[len(batches)-1].ID
+			}
+			writeJSON(w, http.StatusOK, list)
+		case http.MethodPost:
+			var req batch.CreateRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid JSON body", http.StatusBadRequest)
+				return
+			}
+			created, err := runner.Create(owner, req)
+			switch {
+			case errors.Is(err, batch.ErrInvalid):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			default:
+				writeJSON(w, http.StatusOK, created)
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// HandleBatch returns /v1/batches/{id}
+func HandleBatch(runner *batch.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := runner.Get(files.Owner(r), r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, b)
+	}
+}
+
+// HandleBatchCancel stops /v1/batches/{id}/cancel; the responses so far become its output
+func HandleBatchCancel(runner *batch.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		b, err := runner.Cancel(files.Owner(r), r.PathValue("id"))
+		switch {
+		case errors.Is(err, batch.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, batch.ErrFinished):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			writeJSON(w, http.StatusOK, b)
+		}
+	}
+}
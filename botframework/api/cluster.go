@@ -0,0 +1,22 @@
+package api
+
+import (
+	"botframework/cluster"
+	"net/http"
+)
+
+// HandleClusterStatus reports the cluster leader and the live manager nodes
+func HandleClusterStatus(node *cluster.Node) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		status, err := node.Status()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	}
+}
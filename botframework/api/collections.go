@@ -0,0 +1,118 @@
+package api
+
+import (
+	"botframework/rag"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type CollectionQueryRequest struct {
+	Vector []float32         `json:"vector"`
+	TopK   int               `json:"top_k"`
+	Filter map[string]string `json:"filter,omitempty"`
+}
+
+type CollectionDocumentsRequest struct {
+	Documents []rag.Document `json:"documents,omitempty"`
+	IDs       []string       `json:"ids,omitempty"`
+}
+
+// HandleCollectionQuery serves POST /v1/collections/{name}/query against the collection's vector store
+func HandleCollectionQuery(stores *rag.Stores) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req CollectionQueryRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Vector) == 0 {
+			http.Error(w, "vector is required", http.StatusBadRequest)
+			return
+		}
+		if req.TopK <= 0 {
+			req.TopK = 5
+		}
+
+		collection := r.PathValue("name")
+		matches, err := stores.For(collection).Query(r.Context(), collection, req.Vector, req.TopK, req.Filter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"matches": matches})
+	}
+}
+
+// HandleCollectionDocuments upserts (POST) or deletes (DELETE, by ids) documents in a collection
+func HandleCollectionDocuments(stores *rag.Stores) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CollectionDocumentsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		collection := r.PathValue("name")
+		store := stores.For(collection)
+		var err error
+		switch r.Method {
+		case http.MethodPost:
+			for _, doc := range req.Documents {
+				if doc.ID == "" || len(doc.Vector) == 0 {
+					http.Error(w, "every document needs an id and a vector", http.StatusBadRequest)
+					return
+				}
+			}
+			err = store.Upsert(r.Context(), collection, req.Documents)
+		case http.MethodDelete:
+			err = store.Delete(r.Context(), collection, req.IDs)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type CollectionSearchRequest struct {
+	Query          string            `json:"query"`
+	TopKIn         int               `json:"top_k_in"`
+	TopKOut        int               `json:"top_k_out"`
+	RerankBudgetMs int               `json:"rerank_budget_ms"`
+	Filter         map[string]string `json:"filter,omitempty"`
+}
+
+// HandleCollectionSearch serves POST /v1/collections/{name}/search: embed the query, search, rerank
+func HandleCollectionSearch(retriever *rag.Retriever) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req CollectionSearchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+			http.Error(w, "query is required", http.StatusBadRequest)
+			return
+		}
+
+		retrieval, err := retriever.Retrieve(r.Context(), r.PathValue("name"), req.Query, rag.RetrievalOptions{
+			TopKIn:       req.TopKIn,
+			TopKOut:      req.TopKOut,
+			RerankBudget: time.Duration(req.RerankBudgetMs) * time.Millisecond,
+			Filter:       req.Filter,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, retrieval)
+	}
+}
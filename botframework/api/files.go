@@ -0,0 +1,120 @@
+package api
+
+import (
+	"botframework/files"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HandleFiles lists the caller's files (GET, optional ?purpose=) or stores a multipart
+// upload (POST with file, purpose and optional expires_after[seconds] fields)
+func HandleFiles(store *files.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner := files.Owner(r)
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": store.List(owner, r.URL.Query().Get("purpose"))})
+		case http.MethodPost:
+			if store.MaxFileBytes > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, store.MaxFileBytes+1<<20)
+			}
+			if err := r.ParseMultipartForm(32 << 20); err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, files.ErrTooLarge.Error(), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "expected a multipart/form-data upload", http.StatusBadRequest)
+				return
+			}
+			defer r.MultipartForm.RemoveAll()
+
+			purpose := r.FormValue("purpose")
+			if purpose == "" {
+				http.Error(w, "purpose is required", http.StatusBadRequest)
+				return
+			}
+			var ttl time.Duration
+			if raw := r.FormValue("expires_after[seconds]"); raw != "" {
+				seconds, err := strconv.Atoi(raw)
+				if err != nil || seconds <= 0 {
+					http.Error(w, "expires_after[seconds] must be a positive integer", http.StatusBadRequest)
+					return
+				}
+				ttl = time.Duration(seconds) * time.Second
+			}
+			upload, header, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "missing file field", http.StatusBadRequest)
+				return
+			}
+			defer upload.Close()
+
+			file, err := store.Create(owner, header.Filename, purpose, ttl, upload)
+			switch {
+			case errors.Is(err, files.ErrTooLarge):
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			case errors.Is(err, files.ErrQuotaExceeded):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			default:
+				writeJSON(w, http.StatusOK, file)
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// HandleFile returns (GET) or deletes (DELETE) /v1/files/{id}
+func HandleFile(store *files.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, id := files.Owner(r), r.PathValue("id")
+		switch r.Method {
+		case http.MethodGet:
+			file, err := store.Get(owner, id)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, file)
+		case http.MethodDelete:
+			if err := store.Delete(owner, id); err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, files.ErrNotFound) {
+					status = http.StatusNotFound
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"id": id, "object": "file", "deleted": true})
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// HandleFileContent streams the contents of /v1/files/{id}/content
+func HandleFileContent(store *files.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		content, file, err := store.Open(files.Owner(r), r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		defer content.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(file.Bytes, 10))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+		_, _ = io.Copy(w, content)
+	}
+}
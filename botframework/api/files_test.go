@@ -0,0 +1,61 @@
+package api
+
+import (
+	"botframework/files"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilesUploadRoundTrip(t *testing.T) {
+	store, err := files.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/files", HandleFiles(store))
+	mux.HandleFunc("/v1/files/{id}", HandleFile(store))
+	mux.HandleFunc("/v1/files/{id}/content", HandleFileContent(store))
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("purpose", "batch")
+	mw.WriteField("expires_after[seconds]", "3600")
+	part, _ := mw.CreateFormFile("file", "input.jsonl")
+	part.Write([]byte(`{"custom_id":"1"}`))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/files", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer key-a")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("upload failed: %d %s", rr.Code, rr.Body.String())
+	}
+	var file files.File
+	json.NewDecoder(rr.Body).Decode(&file)
+	if file.Purpose != "batch" || file.ExpiresAt != file.CreatedAt+3600 {
+		t.Errorf("unexpected file %+v", file)
+	}
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := get("/v1/files/"+file.ID+"/content", "key-a"); rr.Code != http.StatusOK {
+		t.Errorf("owner could not download: %d", rr.Code)
+	} else if data, _ := io.ReadAll(rr.Body); string(data) != `{"custom_id":"1"}` {
+		t.Errorf("unexpected content %q", data)
+	}
+	if rr := get("/v1/files/"+file.ID, "key-b"); rr.Code != http.StatusNotFound {
+		t.Errorf("other key saw the file: %d", rr.Code)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"botframework/engine"
+	"botframework/openai"
 	"encoding/json"
 	"net/http"
 )
@@ -17,6 +18,11 @@ type ModelInfo struct {
 	OwnedBy string `json:"owned_by"`
 }
 
+// modelLister is implemented by engines that serve several named models
+type modelLister interface {
+	ListModels() []string
+}
+
 func HandleHealth(workerEngine engine.InferenceEngine) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
@@ -44,24 +50,81 @@ func HandleModels(workerEngine engine.InferenceEngine) http.HandlerFunc {
 			return
 		}
 
-		health, err := workerEngine.Health()
+		response, err := listModels(workerEngine)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadGateway)
 			return
 		}
 
-		response := ModelListResponse{Object: "list"}
-		if health.Model != "" {
-			response.Data = append(response.Data, ModelInfo{
-				ID:      health.Model,
-				Object:  "model",
-				OwnedBy: "botframework",
-			})
-		}
-
 		w.Header().Set("Content-Type", "application/json")
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			http.Error(w, "failed to write response", http.StatusInternalServerError)
 		}
 	}
 }
+
+// HandleModel serves GET /v1/models/{model}, the OpenAI retrieve-model route
+func HandleModel(workerEngine engine.InferenceEngine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		response, err := listModels(workerEngine)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		id := r.PathValue("model")
+		for _, model := range response.Data {
+			if model.ID == id {
+				writeJSON(w, http.StatusOK, model)
+				return
+			}
+		}
+		openai.WriteError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", "model "+id+" does not exist")
+	}
+}
+
+// ModelIDs returns the names of the models the engine serves, as listed by /v1/models
+func ModelIDs(workerEngine engine.InferenceEngine) ([]string, error) {
+	response, err := listModels(workerEngine)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(response.Data))
+	for _, model := range response.Data {
+		ids = append(ids, model.ID)
+	}
+	return ids, nil
+}
+
+func listModels(workerEngine engine.InferenceEngine) (ModelListResponse, error) {
+	health, err := workerEngine.Health()
+	if err != nil {
+		return ModelListResponse{}, err
+	}
+
+	response := ModelListResponse{Object: "list"}
+	seen := make(map[string]bool)
+	addModel := func(id string) {
+		if id == "" || seen[id] {
+			return
+		}
+		seen[id] = true
+		response.Data = append(response.Data, ModelInfo{
+			ID:      id,
+			Object:  "model",
+			OwnedBy: "botframework",
+		})
+	}
+
+	addModel(health.Model)
+	if lister, ok := workerEngine.(modelLister); ok {
+		for _, id := range lister.ListModels() {
+			addModel(id)
+		}
+	}
+	return response, nil
+}
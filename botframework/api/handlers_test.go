@@ -1,12 +1,15 @@
 package api
 
 import (
+	"botframework/metrics"
 	"botframework/supervisor"
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 type mockEngine struct {
@@ -19,6 +22,9 @@ func (m *mockEngine) ProxyRequest(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusTeapot)
 }
 func (m *mockEngine) Stop() error { return nil }
+func (m *mockEngine) Status() supervisor.WorkerStatus {
+	return supervisor.WorkerStatus{State: supervisor.StateRunning, Restarts: 2}
+}
 func (m *mockEngine) Health() (*supervisor.WorkerHealth, error) {
 	if m.err != nil {
 		return nil, m.err
@@ -79,3 +85,69 @@ func TestHandleModelsSuccess(t *testing.T) {
 		t.Fatalf("expected json response, got %q", got)
 	}
 }
+
+func TestHandleAdminStatusReportsWorker(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+	rr := httptest.NewRecorder()
+
+	h := HandleAdminStatus(&mockEngine{err: errors.New("connection refused")}, metrics.NewRecorder(), time.Now())
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var status AdminStatus
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(status.Workers) != 1 || status.Workers[0].Status != "unreachable" {
+		t.Fatalf("unexpected workers: %+v", status.Workers)
+	}
+}
+
+type listingEngine struct {
+	mockEngine
+	models []string
+}
+
+func (l *listingEngine) ListModels() []string { return l.models }
+
+func TestHandleModelsListsRegisteredModels(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	rr := httptest.NewRecorder()
+
+	h := HandleModels(&listingEngine{
+		mockEngine: mockEngine{health: &supervisor.WorkerHealth{Status: "ok", Model: "qwen"}},
+		models:     []string{"qwen", "phi"},
+	})
+	h.ServeHTTP(rr, req)
+
+	var response ModelListResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(response.Data) != 2 || response.Data[0].ID != "qwen" || response.Data[1].ID != "phi" {
+		t.Fatalf("unexpected models: %+v", response.Data)
+	}
+}
+
+func TestHandleModelRetrievesOne(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/models/{model}", HandleModel(&listingEngine{
+		mockEngine: mockEngine{health: &supervisor.WorkerHealth{Status: "ok", Model: "qwen"}},
+		models:     []string{"phi"},
+	}))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models/phi", nil))
+	var model ModelInfo
+	if err := json.NewDecoder(rr.Body).Decode(&model); err != nil || model.ID != "phi" || model.Object != "model" {
+		t.Fatalf("unexpected model: %+v (%v)", model, err)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models/gpt-4", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown model, got %d", rr.Code)
+	}
+}
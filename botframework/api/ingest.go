@@ -0,0 +1,113 @@
+package api
+
+import (
+	"botframework/files"
+	"botframework/rag"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxIngestUpload caps the size of a multipart ingestion request
+const maxIngestUpload = 256 << 20
+
+type IngestRequest struct {
+	Documents []rag.Source     `json:"documents"`
+	FileIDs   []string         `json:"file_ids,omitempty"` // uploads from /v1/files
+	Chunking  rag.ChunkOptions `json:"chunking"`
+}
+
+// HandleIngest serves POST /v1/collections/{name}/ingest. It accepts either JSON
+// (IngestRequest) or a multipart upload of "file" parts with optional file_id, strategy,
+// chunk_size, overlap and threshold form fields, and responds 202 with the queued job.
+func HandleIngest(ingester *rag.Ingester, store *files.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req IngestRequest
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			var err error
+			req, err = parseIngestUpload(w, r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		for _, id := range req.FileIDs {
+			data, file, err := store.ReadAll(files.Owner(r), id)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s: %v", id, err), http.StatusBadRequest)
+				return
+			}
+			req.Documents = append(req.Documents, rag.Source{Name: file.Filename, Data: data})
+		}
+
+		status, err := ingester.Submit(r.PathValue("name"), req.Documents, req.Chunking)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, status)
+	}
+}
+
+func parseIngestUpload(w http.ResponseWriter, r *http.Request) (IngestRequest, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxIngestUpload)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return IngestRequest{}, err
+	}
+
+	req := IngestRequest{Chunking: rag.ChunkOptions{Strategy: r.FormValue("strategy")}, FileIDs: r.MultipartForm.Value["file_id"]}
+	req.Chunking.Size, _ = strconv.Atoi(r.FormValue("chunk_size"))
+	req.Chunking.Overlap, _ = strconv.Atoi(r.FormValue("overlap"))
+	req.Chunking.Threshold, _ = strconv.ParseFloat(r.FormValue("threshold"), 64)
+
+	for _, header := range r.MultipartForm.File["file"] {
+		file, err := header.Open()
+		if err != nil {
+			return IngestRequest{}, err
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return IngestRequest{}, err
+		}
+		req.Documents = append(req.Documents, rag.Source{
+			Name:        header.Filename,
+			ContentType: header.Header.Get("Content-Type"),
+			Data:        data,
+		})
+	}
+	return req, nil
+}
+
+// HandleIngestJobs lists ingestion jobs, or reports one job at /v1/ingest/jobs/{id}
+func HandleIngestJobs(ingester *rag.Ingester) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := r.PathValue("id")
+		if id == "" {
+			writeJSON(w, http.StatusOK, map[string]any{"data": ingester.Jobs()})
+			return
+		}
+		status, ok := ingester.Job(id)
+		if !ok {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	}
+}
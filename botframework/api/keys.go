@@ -0,0 +1,66 @@
+package api
+
+import (
+	"botframework/auth"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// RegisterKeyRoutes serves API key usage and rotation on mux
+func RegisterKeyRoutes(mux *http.ServeMux, authenticator *auth.Authenticator) {
+	mux.HandleFunc("/admin/usage/keys", HandleKeyUsage(authenticator))
+	mux.HandleFunc("/admin/usage/keys/{id}", HandleKeyUsage(authenticator))
+	mux.HandleFunc("/admin/keys/{id}/rotate", HandleKeyRotate(authenticator))
+}
+
+// HandleKeyUsage reports each API key's limits and daily token usage, or one key's when
+// the route has an {id}
+func HandleKeyUsage(authenticator *auth.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		usage := authenticator.Usage()
+		id := r.PathValue("id")
+		if id == "" {
+			writeJSON(w, http.StatusOK, usage)
+			return
+		}
+		for _, key := range usage {
+			if key.ID == id {
+				writeJSON(w, http.StatusOK, key)
+				return
+			}
+		}
+		http.Error(w, "unknown key "+strconv.Quote(id), http.StatusNotFound)
+	}
+}
+
+// HandleKeyRotate gives the key named by the route's {id} a new token and returns it once:
+// {"id": "...", "token": "..."}. The old token stops working.
+func HandleKeyRotate(authenticator *auth.Authenticator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rotator, ok := authenticator.Store.(auth.Rotator)
+		if !ok {
+			http.Error(w, "the key store cannot rotate keys", http.StatusNotImplemented)
+			return
+		}
+		id := r.PathValue("id")
+		token, err := rotator.Rotate(id)
+		if errors.Is(err, auth.ErrUnknownKey) {
+			http.Error(w, "unknown key "+strconv.Quote(id), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "rotate failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"id": id, "token": token})
+	}
+}
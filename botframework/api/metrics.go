@@ -0,0 +1,213 @@
+package api
+
+import (
+	"botframework/cache"
+	"botframework/engine"
+	"botframework/guardrails"
+	"botframework/metrics"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"botframework/vram"
+	"maps"
+	"sort"
+	"strconv"
+)
+
+// WorkerMetrics reports the lifecycle and memory of every worker the manager runs. Pool
+// members are reported individually, labelled by their index.
+func WorkerMetrics(manager *engine.ModelManager) func(*metrics.Exposition) {
+	return func(e *metrics.Exposition) {
+		type worker struct {
+			labels metrics.Labels
+			status supervisor.WorkerStatus
+		}
+		var workers []worker
+		engines := manager.Engines()
+		names := make([]string, 0, len(engines))
+		for name := range engines {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if pool, ok := engines[name].(*supervisor.WorkerPool); ok {
+				for _, member := range pool.Members() {
+					workers = append(workers, worker{metrics.Labels{"model": name, "member": strconv.Itoa(member.Index)}, member.Status})
+				}
+				continue
+			}
+			workers = append(workers, worker{metrics.Labels{"model": name}, engines[name].Status()})
+		}
+
+		e.Describe("botframework_worker_up", "gauge", "1 when the worker is running and serving.")
+		for _, w := range workers {
+			up := 0.0
+			if w.status.State == supervisor.StateRunning {
+				up = 1
+			}
+			e.Sample("botframework_worker_up", w.labels, up)
+		}
+		e.Describe("botframework_worker_health", "gauge", "1 for the worker's health state: starting, healthy, degraded or failed.")
+		for _, w := range workers {
+			if w.status.Liveness.State == "" {
+				continue
+			}
+			labels := maps.Clone(w.labels)
+			labels["state"] = string(w.status.Liveness.State)
+			e.Sample("botframework_worker_health", labels, 1)
+		}
+		e.Describe("botframework_worker_liveness_seconds", "gauge", "How long the worker's last liveness probe took.")
+		for _, w := range workers {
+			if !w.status.Liveness.CheckedAt.IsZero() {
+				e.Sample("botframework_worker_liveness_seconds", w.labels, w.status.Liveness.LatencySeconds)
+			}
+		}
+		e.Describe("botframework_worker_restarts_total", "counter", "Times the worker was restarted after exiting.")
+		for _, w := range workers {
+			e.Sample("botframework_worker_restarts_total", w.labels, float64(w.status.Restarts))
+		}
+		e.Describe("botframework_worker_memory_rss_bytes", "gauge", "Resident memory of the worker process and its children.")
+		for _, w := range workers {
+			if rss, err := supervisor.ProcessRSS(w.status.PID); err == nil {
+				e.Sample("botframework_worker_memory_rss_bytes", w.labels, float64(rss))
+			}
+		}
+		e.Describe("botframework_worker_oom_total", "counter", "Worker processes that logged running out of memory.")
+		for _, w := range workers {
+			e.Sample("botframework_worker_oom_total", w.labels, float64(w.status.OOMs))
+		}
+		e.Describe("botframework_worker_model_load_seconds", "gauge", "How long the worker took to load its model, from its output.")
+		for _, w := range workers {
+			if w.status.ModelLoadSeconds > 0 {
+				e.Sample("botframework_worker_model_load_seconds", w.labels, w.status.ModelLoadSeconds)
+			}
+		}
+		e.Describe("botframework_worker_warmup_seconds", "gauge", "How long the worker took to answer its warmup prompts once ready.")
+		for _, w := range workers {
+			if w.status.Warmup.Seconds > 0 {
+				e.Sample("botframework_worker_warmup_seconds", w.labels, w.status.Warmup.Seconds)
+			}
+		}
+		e.Describe("botframework_worker_tokens_per_second", "gauge", "Generation throughput the engine last logged.")
+		for _, w := range workers {
+			if w.status.TokensPerSecond > 0 {
+				e.Sample("botframework_worker_tokens_per_second", w.labels, w.status.TokensPerSecond)
+			}
+		}
+	}
+}
+
+// HardwareMetrics reports the hardware profile the manager planned for
+func HardwareMetrics(profile *profiler.HardwareProfile, backend profiler.Engine) func(*metrics.Exposition) {
+	return func(e *metrics.Exposition) {
+		if profile == nil {
+			return
+		}
+		e.Describe("botframework_hardware_info", "gauge", "Hardware tier and the engine chosen for it.")
+		e.Sample("botframework_hardware_info", metrics.Labels{"tier": string(profile.ClassifyTier()), "engine": string(backend)}, 1)
+		e.Describe("botframework_hardware_vram_bytes", "gauge", "VRAM of the largest GPU (unified memory share on Apple Silicon).")
+		e.Sample("botframework_hardware_vram_bytes", nil, float64(profile.VRAM_MB)*(1<<20))
+		e.Describe("botframework_hardware_vram_total_bytes", "gauge", "VRAM across every detected GPU.")
+		e.Sample("botframework_hardware_vram_total_bytes", nil, float64(profile.TotalVRAM_MB())*(1<<20))
+		e.Describe("botframework_hardware_ram_bytes", "gauge", "System RAM.")
+		e.Sample("botframework_hardware_ram_bytes", nil, float64(profile.SystemRAM_MB)*(1<<20))
+		e.Describe("botframework_hardware_gpus", "gauge", "Detected GPUs.")
+		e.Sample("botframework_hardware_gpus", nil, float64(len(profile.GPUs)))
+	}
+}
+
+// QueueMetrics reports the request queue in front of each engine
+func QueueMetrics(manager *engine.ModelManager) func(*metrics.Exposition) {
+	return func(e *metrics.Exposition) {
+		stats := manager.QueueStats()
+		if len(stats) == 0 {
+			return
+		}
+		e.Describe("botframework_queue_depth", "gauge", "Requests waiting for a worker slot, by priority class.")
+		for _, s := range stats {
+			for _, p := range engine.Priorities {
+				e.Sample("botframework_queue_depth", metrics.Labels{"model": s.Model, "priority": string(p)}, float64(s.QueuedByPriority[p]))
+			}
+		}
+		e.Describe("botframework_queue_in_flight", "gauge", "Requests holding a worker slot.")
+		for _, s := range stats {
+			e.Sample("botframework_queue_in_flight", metrics.Labels{"model": s.Model}, float64(s.InFlight))
+		}
+		e.Describe("botframework_queue_capacity", "gauge", "Worker slots, the in-flight limit.")
+		for _, s := range stats {
+			e.Sample("botframework_queue_capacity", metrics.Labels{"model": s.Model}, float64(s.Capacity))
+		}
+		e.Describe("botframework_queue_rejected_total", "counter", "Requests turned away with 429, by reason.")
+		for _, s := range stats {
+			e.Sample("botframework_queue_rejected_total", metrics.Labels{"model": s.Model, "reason": "full"}, float64(s.Rejected))
+			e.Sample("botframework_queue_rejected_total", metrics.Labels{"model": s.Model, "reason": "timeout"}, float64(s.TimedOut))
+		}
+		e.Describe("botframework_queue_wait_seconds", "histogram", "Time admitted requests spent queued, by priority class.")
+		for _, s := range stats {
+			for _, p := range engine.Priorities {
+				e.Histogram("botframework_queue_wait_seconds", metrics.Labels{"model": s.Model, "priority": string(p)}, s.Wait[p])
+			}
+		}
+		e.Describe("botframework_queue_request_seconds", "histogram", "Time from joining the queue to finishing, by priority class.")
+		for _, s := range stats {
+			for _, p := range engine.Priorities {
+				e.Histogram("botframework_queue_request_seconds", metrics.Labels{"model": s.Model, "priority": string(p)}, s.Latency[p])
+			}
+		}
+	}
+}
+
+// VRAMMetrics reports live GPU memory and the monitor's pressure state
+func VRAMMetrics(monitor *vram.Monitor) func(*metrics.Exposition) {
+	return func(e *metrics.Exposition) {
+		status := monitor.Status()
+		if len(status.GPUs) == 0 {
+			return
+		}
+		e.Describe("botframework_gpu_memory_used_bytes", "gauge", "GPU memory in use, sampled at runtime.")
+		for _, gpu := range status.GPUs {
+			e.Sample("botframework_gpu_memory_used_bytes", metrics.Labels{"gpu": strconv.Itoa(gpu.Index)}, float64(gpu.UsedMB)*(1<<20))
+		}
+		e.Describe("botframework_gpu_memory_total_bytes", "gauge", "GPU memory capacity.")
+		for _, gpu := range status.GPUs {
+			e.Sample("botframework_gpu_memory_total_bytes", metrics.Labels{"gpu": strconv.Itoa(gpu.Index)}, float64(gpu.TotalMB)*(1<<20))
+		}
+		pressure := 0.0
+		if status.Pressure {
+			pressure = 1
+		}
+		e.Describe("botframework_vram_pressure", "gauge", "1 while free VRAM is below the configured threshold.")
+		e.Sample("botframework_vram_pressure", nil, pressure)
+		e.Describe("botframework_vram_pressure_episodes_total", "counter", "Times free VRAM dropped below the threshold.")
+		e.Sample("botframework_vram_pressure_episodes_total", nil, float64(status.Episodes))
+		e.Describe("botframework_vram_actions_total", "counter", "Requests paused or shrunk because of low VRAM.")
+		e.Sample("botframework_vram_actions_total", metrics.Labels{"action": string(vram.ActionPause)}, float64(status.Paused))
+		e.Sample("botframework_vram_actions_total", metrics.Labels{"action": string(vram.ActionShrink)}, float64(status.Shrunk))
+	}
+}
+
+// CacheMetrics reports how often the response cache answered requests
+func CacheMetrics(c *cache.Cache) func(*metrics.Exposition) {
+	return func(e *metrics.Exposition) {
+		stats := c.Stats()
+		e.Describe("botframework_cache_requests_total", "counter", "Cacheable requests by outcome.")
+		e.Sample("botframework_cache_requests_total", metrics.Labels{"result": "hit"}, float64(stats.Hits))
+		e.Sample("botframework_cache_requests_total", metrics.Labels{"result": "miss"}, float64(stats.Misses))
+		e.Sample("botframework_cache_requests_total", metrics.Labels{"result": "bypass"}, float64(stats.Bypasses))
+		e.Describe("botframework_cache_evictions_total", "counter", "Responses evicted to stay within the cache limits.")
+		e.Sample("botframework_cache_evictions_total", nil, float64(stats.Evictions))
+		e.Describe("botframework_cache_entries", "gauge", "Responses in the cache.")
+		e.Sample("botframework_cache_entries", nil, float64(stats.Entries))
+		e.Describe("botframework_cache_bytes", "gauge", "Size of the cached response bodies.")
+		e.Sample("botframework_cache_bytes", nil, float64(stats.Bytes))
+	}
+}
+
+// GuardrailMetrics reports how often each guardrail rejected or redacted prompts and output
+func GuardrailMetrics(g *guardrails.Guard) func(*metrics.Exposition) {
+	return func(e *metrics.Exposition) {
+		e.Describe("botframework_guardrail_hits_total", "counter", "Prompts and outputs a guardrail matched, by rule, direction and action.")
+		for _, stat := range g.Stats() {
+			e.Sample("botframework_guardrail_hits_total", metrics.Labels{"rule": stat.Rule, "direction": stat.Direction, "action": stat.Action}, float64(stat.Hits))
+		}
+	}
+}
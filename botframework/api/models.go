@@ -0,0 +1,86 @@
+package api
+
+import (
+	"botframework/engine"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// RegisterModelRoutes serves loading, swapping and unloading models on mux. A load
+// hot-swaps the default model, so swap is the same handler under botctl's name for it.
+func RegisterModelRoutes(mux *http.ServeMux, manager *engine.ModelManager) {
+	mux.HandleFunc("/admin/models/load", HandleModelLoad(manager))
+	mux.HandleFunc("/admin/models/swap", HandleModelLoad(manager))
+	mux.HandleFunc("/admin/models/unload", HandleModelUnload(manager))
+}
+
+type ModelLoadRequest struct {
+	Model string `json:"model"`
+	// DrainTimeout bounds the wait for in-flight requests on the old worker, e.g. "30s"
+	DrainTimeout string `json:"drain_timeout,omitempty"`
+}
+
+// HandleModelLoad hot-swaps the default model: POST {"model": "..."} responds once the new
+// worker serves traffic and the old one has drained and stopped
+func HandleModelLoad(manager *engine.ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req ModelLoadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model == "" {
+			http.Error(w, "model is required", http.StatusBadRequest)
+			return
+		}
+		drain := engine.DefaultDrainTimeout
+		if req.DrainTimeout != "" {
+			d, err := time.ParseDuration(req.DrainTimeout)
+			if err != nil || d < 0 {
+				http.Error(w, "invalid drain_timeout", http.StatusBadRequest)
+				return
+			}
+			drain = d
+		}
+
+		// finish the swap even if the caller disconnects, so no worker is left half-retired
+		result, err := manager.SwapModel(context.WithoutCancel(r.Context()), req.Model, drain)
+		if err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, engine.ErrUnknownModel) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// HandleModelUnload stops serving a model: POST {"model": "..."} removes it and stops its
+// worker unless another model name or the default route still uses it
+func HandleModelUnload(manager *engine.ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req ModelLoadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model == "" {
+			http.Error(w, "model is required", http.StatusBadRequest)
+			return
+		}
+		if err := manager.Unload(req.Model); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, engine.ErrUnknownModel) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"model": req.Model, "status": "unloaded"})
+	}
+}
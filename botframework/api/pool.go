@@ -0,0 +1,17 @@
+package api
+
+import (
+	"botframework/supervisor"
+	"net/http"
+)
+
+// HandleWorkerPool lists the pool's members with their health and in-flight requests
+func HandleWorkerPool(pool *supervisor.WorkerPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"strategy": pool.Strategy, "members": pool.Members()})
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"botframework/engine"
+	"encoding/json"
+	"net/http"
+)
+
+type RolloutRequest struct {
+	Model     string                `json:"model"`
+	Candidate string                `json:"candidate"`
+	Percent   int                   `json:"percent"`
+	Policy    *engine.RolloutPolicy `json:"policy,omitempty"`
+}
+
+// HandleRollouts lists rollouts (GET) or starts a new one (POST)
+func HandleRollouts(manager *engine.ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, manager.Rollouts())
+		case http.MethodPost:
+			var req RolloutRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			if req.Model == "" || req.Candidate == "" {
+				http.Error(w, "model and candidate are required", http.StatusBadRequest)
+				return
+			}
+
+			policy := engine.DefaultRolloutPolicy()
+			if req.Policy != nil {
+				policy = *req.Policy
+			}
+			rollout, err := manager.StartRollout(req.Model, req.Candidate, req.Percent, policy)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
+			writeJSON(w, http.StatusCreated, rollout.Progress())
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// HandleRolloutAction serves POST /admin/rollouts/{model}/{action} for promote, rollback and percent
+func HandleRolloutAction(manager *engine.ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		model := r.PathValue("model")
+		var err error
+		switch r.PathValue("action") {
+		case "promote":
+			err = manager.PromoteRollout(model)
+		case "rollback":
+			err = manager.RollbackRollout(model)
+		case "percent":
+			var body struct {
+				Percent int `json:"percent"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			rollout, rerr := manager.Rollout(model)
+			if rerr != nil {
+				err = rerr
+				break
+			}
+			rollout.SetPercent(body.Percent)
+			writeJSON(w, http.StatusOK, rollout.Progress())
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+type ShadowRequest struct {
+	Model   string `json:"model"`
+	Shadow  string `json:"shadow"`
+	Percent int    `json:"percent"`
+}
+
+// HandleShadows lists shadow comparisons (GET) or configures a shadow (POST, empty shadow disables it)
+func HandleShadows(manager *engine.ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, manager.ShadowStats())
+		case http.MethodPost:
+			var req ShadowRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model == "" {
+				http.Error(w, "model is required", http.StatusBadRequest)
+				return
+			}
+			if req.Shadow != "" {
+				if _, err := manager.EnsureLoaded(req.Shadow); err != nil {
+					http.Error(w, err.Error(), http.StatusNotFound)
+					return
+				}
+			}
+			manager.SetShadow(req.Model, engine.ShadowConfig{Shadow: req.Shadow, Percent: req.Percent})
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
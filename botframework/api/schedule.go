@@ -0,0 +1,17 @@
+package api
+
+import (
+	"botframework/schedule"
+	"net/http"
+)
+
+// HandleSchedule lists the model warm/unload policies and whether each is in effect
+func HandleSchedule(scheduler *schedule.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, scheduler.Status())
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"botframework/chat"
+	"botframework/files"
+	"botframework/sessions"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+type SessionRequest struct {
+	ID       string            `json:"id,omitempty"`
+	Title    *string           `json:"title,omitempty"`
+	Model    string            `json:"model,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Messages []chat.Message    `json:"messages,omitempty"`
+}
+
+var errSessionExists = errors.New("session already exists")
+
+// HandleSessions lists the caller's chat sessions (GET) or creates one (POST), optionally
+// seeded with messages
+func HandleSessions(store sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner := files.Owner(r)
+		switch r.Method {
+		case http.MethodGet:
+			list, err := store.List(owner)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": list})
+		case http.MethodPost:
+			var req SessionRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid session", http.StatusBadRequest)
+				return
+			}
+			if req.ID == "" {
+				id, err := sessions.NewID()
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				req.ID = id
+			}
+			session, err := store.Update(owner, req.ID, true, func(s *sessions.Session) error {
+				if s.UpdatedAt != 0 {
+					return errSessionExists
+				}
+				if req.Title != nil {
+					s.Title = *req.Title
+				}
+				s.Model, s.Metadata, s.Messages = req.Model, req.Metadata, req.Messages
+				return nil
+			})
+			switch {
+			case errors.Is(err, sessions.ErrInvalidID):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, errSessionExists):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			default:
+				writeJSON(w, http.StatusOK, session)
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// HandleSession returns (GET), renames or retags (PATCH with title and metadata) or deletes
+// (DELETE) /v1/sessions/{id}
+func HandleSession(store sessions.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		owner, id := files.Owner(r), r.PathValue("id")
+		var (
+			session *sessions.Session
+			err     error
+		)
+		switch r.Method {
+		case http.MethodGet:
+			session, err = store.Get(owner, id)
+		case http.MethodPatch:
+			var req SessionRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid session", http.StatusBadRequest)
+				return
+			}
+			session, err = store.Update(owner, id, false, func(s *sessions.Session) error {
+				if req.Title != nil {
+					s.Title = *req.Title
+				}
+				if req.Metadata != nil {
+					s.Metadata = req.Metadata
+				}
+				return nil
+			})
+		case http.MethodDelete:
+			err = store.Delete(owner, id)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch {
+		case errors.Is(err, sessions.ErrNotFound), errors.Is(err, sessions.ErrInvalidID):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		case r.Method == http.MethodDelete:
+			writeJSON(w, http.StatusOK, map[string]any{"id": id, "object": "chat.session", "deleted": true})
+		default:
+			writeJSON(w, http.StatusOK, session)
+		}
+	}
+}
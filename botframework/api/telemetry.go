@@ -0,0 +1,29 @@
+package api
+
+import (
+	"botframework/telemetry"
+	"net/http"
+)
+
+// TelemetryPreview shows exactly what the next telemetry report would contain
+type TelemetryPreview struct {
+	Sending  bool             `json:"sending"`
+	Endpoint string           `json:"endpoint,omitempty"`
+	Interval string           `json:"interval,omitempty"`
+	Report   telemetry.Report `json:"next_report"`
+}
+
+// HandleTelemetryPreview returns the pending telemetry report without sending it
+func HandleTelemetryPreview(collector *telemetry.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		preview := TelemetryPreview{Sending: collector.Endpoint != "", Endpoint: collector.Endpoint, Report: collector.Preview()}
+		if preview.Sending {
+			preview.Interval = collector.Interval.String()
+		}
+		writeJSON(w, http.StatusOK, preview)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"botframework/accounting"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// usageDays is how far back a usage report reaches when it is given no start
+const usageDays = 30
+
+// HandleUsage reports recorded usage summed over a date range. from and to are dates
+// (both days included) or RFC 3339 times, defaulting to the last 30 days; group_by lists
+// the fields to group by (model, key, day, path; default: model).
+//
+//	GET /admin/usage?from=2026-10-01&to=2026-10-31&group_by=key,model
+func HandleUsage(ledger *accounting.Ledger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		now := time.Now().UTC()
+		q := accounting.Query{From: now.Truncate(24*time.Hour).AddDate(0, 0, -usageDays+1), To: now}
+		var err error
+		if value := query.Get("from"); value != "" {
+			if q.From, err = parseUsageTime(value, false); err != nil {
+				http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if value := query.Get("to"); value != "" {
+			if q.To, err = parseUsageTime(value, true); err != nil {
+				http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		groupBy := "model"
+		if query.Has("group_by") {
+			groupBy = query.Get("group_by")
+		}
+		for _, grouping := range strings.Split(groupBy, ",") {
+			if grouping = strings.TrimSpace(grouping); grouping != "" {
+				q.GroupBy = append(q.GroupBy, accounting.Grouping(grouping))
+			}
+		}
+
+		report, err := ledger.Report(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}
+}
+
+// parseUsageTime reads a date or an RFC 3339 time; a date ending a range includes the day
+func parseUsageTime(value string, end bool) (time.Time, error) {
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"botframework/engine"
+	"botframework/supervisor"
+	"crypto/subtle"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WorkerInfo describes one engine's worker process for /admin/workers
+type WorkerInfo struct {
+	ID            string  `json:"id"`
+	State         string  `json:"state"`
+	PID           int     `json:"pid,omitempty"`
+	Port          string  `json:"port,omitempty"`
+	UptimeSeconds float64 `json:"uptime_seconds"`
+	Restarts      int     `json:"restarts"`
+	Health        string  `json:"health"`
+	Liveness      string  `json:"liveness,omitempty"`
+	Model         string  `json:"model,omitempty"`
+	MemoryBytes   int64   `json:"memory_bytes,omitempty"`
+	LastExit      string  `json:"last_exit,omitempty"`
+	// TokensPerSecond is the generation rate the engine last logged
+	TokensPerSecond float64 `json:"tokens_per_second,omitempty"`
+	// Failure classifies the worker's trouble from its output: oom or load_failed
+	Failure string `json:"failure,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Warmup is how the prompts the worker was warmed up with went
+	Warmup supervisor.WarmupStatus `json:"warmup,omitzero"`
+}
+
+// Workers that can be relaunched, and that keep their recent output
+type (
+	relauncher interface{ Relaunch() error }
+	logSource  interface{ Logs(n int) []string }
+)
+
+func describeWorker(manager *engine.ModelManager, id string, e engine.InferenceEngine) WorkerInfo {
+	status := e.Status()
+	info := WorkerInfo{ID: id, State: string(status.State), PID: status.PID, Port: status.Port, Restarts: status.Restarts, LastExit: status.LastExit, Failure: status.Failure,
+		Liveness: string(status.Liveness.State), TokensPerSecond: status.TokensPerSecond, Warmup: status.Warmup}
+	if !status.StartedAt.IsZero() {
+		info.UptimeSeconds = time.Since(status.StartedAt).Seconds()
+	}
+	if status.PID > 0 {
+		info.MemoryBytes, _ = supervisor.ProcessRSS(status.PID)
+	}
+	if manager.Unloaded(e) {
+		// stopped for being idle; the next request for its model starts it
+		info.Health = "unloaded"
+		return info
+	}
+	health, err := e.Health()
+	if err != nil {
+		info.Health, info.Error = "unreachable", err.Error()
+	} else {
+		info.Health, info.Model = health.Status, health.Model
+	}
+	return info
+}
+
+// HandleWorkers lists every engine's worker: GET /admin/workers
+func HandleWorkers(manager *engine.ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		engines := manager.Engines()
+		ids := make([]string, 0, len(engines))
+		for id := range engines {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		workers := make([]WorkerInfo, 0, len(ids))
+		for _, id := range ids {
+			workers = append(workers, describeWorker(manager, id, engines[id]))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"workers": workers})
+	}
+}
+
+// HandleWorker serves one worker, named by its model or "default":
+//
+//	GET  /admin/workers/{id}           its state
+//	GET  /admin/workers/{id}/logs      its recent output (?tail=N, default 100)
+//	POST /admin/workers/{id}/restart   stop it and start it again
+//	POST /admin/workers/{id}/stop      stop it; requests for its model fail until a restart
+func HandleWorker(manager *engine.ModelManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		e, ok := manager.Engines()[id]
+		if !ok {
+			http.Error(w, "unknown worker "+strconv.Quote(id), http.StatusNotFound)
+			return
+		}
+
+		action := r.PathValue("action")
+		wantMethod := http.MethodPost
+		if action == "" || action == "logs" {
+			wantMethod = http.MethodGet
+		}
+		if r.Method != wantMethod {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch action {
+		case "":
+			writeJSON(w, http.StatusOK, describeWorker(manager, id, e))
+		case "logs":
+			source, ok := e.(logSource)
+			if !ok {
+				http.Error(w, "worker "+id+" does not keep its output", http.StatusNotImplemented)
+				return
+			}
+			tail := 100
+			if n, err := strconv.Atoi(r.URL.Query().Get("tail")); err == nil {
+				tail = n
+			}
+			writeJSON(w, http.StatusOK, map[string]any{"id": id, "lines": source.Logs(tail)})
+		case "restart":
+			worker, ok := e.(relauncher)
+			if !ok {
+				http.Error(w, "worker "+id+" cannot be restarted", http.StatusNotImplemented)
+				return
+			}
+			if err := worker.Relaunch(); err != nil {
+				http.Error(w, "restart failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, describeWorker(manager, id, e))
+		case "stop":
+			if err := e.Stop(); err != nil {
+				http.Error(w, "stop failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			writeJSON(w, http.StatusOK, describeWorker(manager, id, e))
+		default:
+			http.Error(w, "unknown action "+strconv.Quote(action), http.StatusNotFound)
+		}
+	}
+}
+
+// RequireAdminToken rejects requests under /admin/ that do not carry token as a bearer
+// token; other routes pass through. Without a token, /admin/ stays open except for the
+// actions that start or stop workers or hand out keys, which are refused.
+func RequireAdminToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && isPrivilegedAction(r.URL.Path) {
+				http.Error(w, "set an admin token to change workers, models or keys", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/admin/") {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="botframework-admin"`)
+				http.Error(w, "admin token required", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isPrivilegedAction reports whether path restarts or stops a worker, loads, swaps or
+// unloads a model, or rotates an API key
+func isPrivilegedAction(path string) bool {
+	switch path {
+	case "/admin/models/load", "/admin/models/swap", "/admin/models/unload":
+		return true
+	}
+	if rest, ok := strings.CutPrefix(path, "/admin/keys/"); ok {
+		_, action, _ := strings.Cut(rest, "/")
+		return action == "rotate"
+	}
+	rest, ok := strings.CutPrefix(path, "/admin/workers/")
+	if !ok {
+		return false
+	}
+	_, action, _ := strings.Cut(rest, "/")
+	return action == "restart" || action == "stop"
+}
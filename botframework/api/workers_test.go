@@ -0,0 +1,117 @@
+package api
+
+import (
+	"botframework/engine"
+	"botframework/supervisor"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type lifecycleEngine struct {
+	mockEngine
+	relaunched int
+}
+
+func (l *lifecycleEngine) Relaunch() error     { l.relaunched++; return nil }
+func (l *lifecycleEngine) Logs(n int) []string { return []string{"INFO: ready"}[:min(n, 1)] }
+
+func workerMux(manager *engine.ModelManager) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/workers", HandleWorkers(manager))
+	mux.HandleFunc("/admin/workers/{id}", HandleWorker(manager))
+	mux.HandleFunc("/admin/workers/{id}/{action}", HandleWorker(manager))
+	return mux
+}
+
+func TestWorkerEndpoints(t *testing.T) {
+	fast := &lifecycleEngine{mockEngine: mockEngine{health: &supervisor.WorkerHealth{Status: "ok", Model: "phi-3"}}}
+	manager := &engine.ModelManager{Engine: &mockEngine{health: &supervisor.WorkerHealth{Status: "ok"}}}
+	manager.Register("fast", fast)
+	mux := workerMux(manager)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/workers", nil))
+	var list struct{ Workers []WorkerInfo }
+	json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Workers) != 2 || list.Workers[0].ID != "default" || list.Workers[1].Model != "phi-3" || list.Workers[1].Restarts != 2 {
+		t.Fatalf("workers = %+v", list.Workers)
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/workers/fast/restart", nil))
+	if rr.Code != http.StatusOK || fast.relaunched != 1 {
+		t.Errorf("restart: status %d, relaunched %d", rr.Code, fast.relaunched)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/workers/default/restart", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("restart without Relaunch: status %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/workers/fast/logs?tail=5", nil))
+	var logs struct{ Lines []string }
+	json.NewDecoder(rr.Body).Decode(&logs)
+	if len(logs.Lines) != 1 || logs.Lines[0] != "INFO: ready" {
+		t.Errorf("logs = %s", rr.Body)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/workers/fast/stop", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET stop: status %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/workers/slow", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("unknown worker: status %d", rr.Code)
+	}
+}
+
+func TestRequireAdminToken(t *testing.T) {
+	h := RequireAdminToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		path, auth string
+		want       int
+	}{
+		{"/admin/workers", "", http.StatusUnauthorized},
+		{"/admin/workers", "Bearer wrong", http.StatusUnauthorized},
+		{"/admin/workers", "Bearer s3cret", http.StatusOK},
+		{"/v1/models", "", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		if rr.Code != tc.want {
+			t.Errorf("%s with %q: status %d, want %d", tc.path, tc.auth, rr.Code, tc.want)
+		}
+	}
+}
+
+func TestRequireAdminTokenUnsetRefusesPrivilegedActions(t *testing.T) {
+	h := RequireAdminToken("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/admin/workers", http.StatusOK},
+		{http.MethodGet, "/admin/workers/default/logs", http.StatusOK},
+		{http.MethodPost, "/admin/workers/default/restart", http.StatusForbidden},
+		{http.MethodPost, "/admin/workers/fast/stop", http.StatusForbidden},
+		{http.MethodPost, "/admin/models/load", http.StatusForbidden},
+		{http.MethodPost, "/admin/models/swap", http.StatusForbidden},
+		{http.MethodPost, "/admin/models/unload", http.StatusForbidden},
+		{http.MethodPost, "/admin/keys/alice/rotate", http.StatusForbidden},
+		{http.MethodGet, "/admin/usage/keys/alice", http.StatusOK},
+		{http.MethodPost, "/v1/chat/completions", http.StatusOK},
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+		if rr.Code != tc.want {
+			t.Errorf("%s %s: status %d, want %d", tc.method, tc.path, rr.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration returns the playing time of an audio file, read from WAV, FLAC, MP3 or Ogg
+// (Opus/Vorbis) headers, falling back to ffprobe for other containers when it is installed
+func Duration(data []byte) (time.Duration, bool) {
+	var seconds float64
+	var ok bool
+	switch {
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		seconds, ok = wavSeconds(data)
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		seconds, ok = flacSeconds(data)
+	case bytes.HasPrefix(data, []byte("OggS")):
+		seconds, ok = oggSeconds(data)
+	case bytes.HasPrefix(data, []byte("ID3")) || len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		seconds, ok = mp3Seconds(data)
+	}
+	if !ok {
+		seconds, ok = ffprobeSeconds(data)
+	}
+	if !ok || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+func wavSeconds(data []byte) (float64, bool) {
+	var byteRate uint32
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		body := pos + 8
+		switch id {
+		case "fmt ":
+			if body+12 > len(data) {
+				return 0, false
+			}
+			byteRate = binary.LittleEndian.Uint32(data[body+8 : body+12])
+		case "data":
+			if byteRate == 0 {
+				return 0, false
+			}
+			// Streamed WAVs leave the size unset; count what was uploaded
+			if size == 0 || size == 0xFFFFFFFF || int(size) > len(data)-body {
+				size = uint32(len(data) - body)
+			}
+			return float64(size) / float64(byteRate), true
+		}
+		pos = body + int(size) + int(size&1)
+	}
+	return 0, false
+}
+
+func flacSeconds(data []byte) (float64, bool) {
+	// STREAMINFO is always the first metadata block: 4-byte marker, 4-byte block header
+	const info = 8
+	if len(data) < info+18 {
+		return 0, false
+	}
+	b := data[info:]
+	rate := uint64(b[10])<<12 | uint64(b[11])<<4 | uint64(b[12])>>4
+	samples := uint64(b[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(b[14:18]))
+	if rate == 0 || samples == 0 {
+		return 0, false
+	}
+	return float64(samples) / float64(rate), true
+}
+
+func oggSeconds(data []byte) (float64, bool) {
+	// The first page carries the codec header, the last page the final granule position
+	const header = 27
+	if len(data) < header {
+		return 0, false
+	}
+	segments := int(data[26])
+	packet := data[min(len(data), header+segments):]
+
+	var rate, preSkip float64
+	switch {
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 12:
+		rate = 48000
+		preSkip = float64(binary.LittleEndian.Uint16(packet[10:12]))
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+		rate = float64(binary.LittleEndian.Uint32(packet[12:16]))
+	default:
+		return 0, false
+	}
+
+	last := bytes.LastIndex(data, []byte("OggS"))
+	if rate == 0 || last < 0 || last+14 > len(data) {
+		return 0, false
+	}
+	granule := float64(binary.LittleEndian.Uint64(data[last+6 : last+14]))
+	return (granule - preSkip) / rate, true
+}
+
+var (
+	mp3Bitrates = [2][16]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}, // MPEG-1 layer III
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},     // MPEG-2/2.5 layer III
+	}
+	mp3Rates = map[byte][3]int{3: {44100, 48000, 32000}, 2: {22050, 24000, 16000}, 0: {11025, 12000, 8000}}
+)
+
+// mp3Seconds reads the Xing/Info frame count when present and otherwise assumes constant bitrate
+func mp3Seconds(data []byte) (float64, bool) {
+	pos := 0
+	if bytes.HasPrefix(data, []byte("ID3")) && len(data) >= 10 {
+		size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+		pos = 10 + size
+	}
+	for ; pos+4 <= len(data); pos++ {
+		if data[pos] == 0xFF && data[pos+1]&0xE0 == 0xE0 {
+			break
+		}
+	}
+	if pos+4 > len(data) {
+		return 0, false
+	}
+
+	h := data[pos : pos+4]
+	version := (h[1] >> 3) & 0x03 // 3 = MPEG-1, 2 = MPEG-2, 0 = MPEG-2.5
+	layer := (h[1] >> 1) & 0x03   // 1 = layer III
+	rates, ok := mp3Rates[version]
+	if !ok || layer != 1 || (h[2]>>2)&0x03 == 3 {
+		return 0, false
+	}
+	table, samplesPerFrame := 1, 576.0
+	if version == 3 {
+		table, samplesPerFrame = 0, 1152
+	}
+	bitrate := mp3Bitrates[table][h[2]>>4] * 1000
+	rate := float64(rates[(h[2]>>2)&0x03])
+
+	mono := h[3]>>6 == 3
+	sideInfo := 17
+	if version == 3 && !mono {
+		sideInfo = 32
+	} else if version != 3 && mono {
+		sideInfo = 9
+	}
+	xing := pos + 4 + sideInfo
+	if xing+12 <= len(data) {
+		tag := string(data[xing : xing+4])
+		if (tag == "Xing" || tag == "Info") && binary.BigEndian.Uint32(data[xing+4:xing+8])&1 == 1 {
+			frames := float64(binary.BigEndian.Uint32(data[xing+8 : xing+12]))
+			return frames * samplesPerFrame / rate, true
+		}
+	}
+	if bitrate == 0 {
+		return 0, false
+	}
+	return float64(len(data)-pos) * 8 / float64(bitrate), true
+}
+
+func ffprobeSeconds(data []byte) (float64, bool) {
+	cmd := exec.Command("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "csv=p=0", "-i", "pipe:0")
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	return seconds, err == nil
+}
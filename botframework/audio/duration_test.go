@@ -0,0 +1,97 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// wav builds a 16-bit mono PCM file of the given length
+func wav(seconds float64, rate int) []byte {
+	samples := int(seconds * float64(rate))
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+samples*2))
+	buf.WriteString("WAVEfmt ")
+	for _, field := range []any{uint32(16), uint16(1), uint16(1), uint32(rate), uint32(rate * 2), uint16(2), uint16(16)} {
+		binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(samples*2))
+	buf.Write(make([]byte, samples*2))
+	return buf.Bytes()
+}
+
+func TestDurationWAV(t *testing.T) {
+	got, ok := Duration(wav(1.5, 16000))
+	if !ok || got != 1500*time.Millisecond {
+		t.Errorf("want 1.5s, got %v %v", got, ok)
+	}
+}
+
+func TestDurationFLAC(t *testing.T) {
+	info := make([]byte, 34)
+	// 44100 Hz, 2 channels, 16 bits, 441000 samples
+	rate, samples := uint64(44100), uint64(441000)
+	info[10] = byte(rate >> 12)
+	info[11] = byte(rate >> 4)
+	info[12] = byte(rate<<4) | 1<<1
+	info[13] = 0xF0 | byte(samples>>32)
+	binary.BigEndian.PutUint32(info[14:18], uint32(samples))
+	data := append([]byte("fLaC\x00\x00\x00\x22"), info...)
+
+	got, ok := Duration(data)
+	if !ok || got != 10*time.Second {
+		t.Errorf("want 10s, got %v %v", got, ok)
+	}
+}
+
+func TestDurationMP3ConstantBitrate(t *testing.T) {
+	// MPEG-1 layer III, 128 kbps, 44.1 kHz, stereo; 16000 bytes = 1s at 128 kbps
+	data := make([]byte, 16000)
+	copy(data, []byte{0xFF, 0xFB, 0x90, 0x00})
+	got, ok := Duration(data)
+	if !ok || got != time.Second {
+		t.Errorf("want 1s, got %v %v", got, ok)
+	}
+}
+
+func TestDurationMP3Xing(t *testing.T) {
+	data := make([]byte, 2000)
+	copy(data, []byte{0xFF, 0xFB, 0x90, 0x00})
+	xing := 4 + 32
+	copy(data[xing:], "Xing")
+	binary.BigEndian.PutUint32(data[xing+4:], 1)
+	binary.BigEndian.PutUint32(data[xing+8:], 3828) // 3828 * 1152 / 44100 ≈ 100s
+	got, ok := Duration(data)
+	if !ok || got.Round(time.Second) != 100*time.Second {
+		t.Errorf("want ~100s, got %v %v", got, ok)
+	}
+}
+
+func oggPage(granule uint64, packet []byte) []byte {
+	page := make([]byte, 27, 28+len(packet))
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint64(page[6:14], granule)
+	page[26] = 1
+	page = append(page, byte(len(packet)))
+	return append(page, packet...)
+}
+
+func TestDurationOggOpus(t *testing.T) {
+	head := []byte("OpusHead\x01\x01")
+	head = binary.LittleEndian.AppendUint16(head, 312)
+	head = append(head, make([]byte, 7)...)
+	data := append(oggPage(0, head), oggPage(48000*3+312, []byte{0})...)
+	got, ok := Duration(data)
+	if !ok || got != 3*time.Second {
+		t.Errorf("want 3s, got %v %v", got, ok)
+	}
+}
+
+func TestDurationUnknown(t *testing.T) {
+	if _, ok := Duration([]byte("not audio at all")); ok {
+		t.Error("want unknown duration for garbage input")
+	}
+}
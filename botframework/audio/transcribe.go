@@ -0,0 +1,307 @@
+package audio
+
+import (
+	"botframework/engine"
+	"botframework/openai"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Routes served by the Transcriber
+const (
+	TranscriptionsPath = "/v1/audio/transcriptions"
+	TranslationsPath   = "/v1/audio/translations"
+)
+
+// SecondsHeader reports the audio duration billed for a request
+const SecondsHeader = "X-BotFramework-Audio-Seconds"
+
+// formOverhead allows for multipart boundaries and text fields beyond the file itself
+const formOverhead = 1 << 20
+
+// Usage aggregates audio processed per model
+type Usage struct {
+	Model        string  `json:"model"`
+	Task         string  `json:"task"`
+	Requests     int     `json:"requests"`
+	AudioSeconds float64 `json:"audio_seconds"`
+}
+
+// Transcriber validates speech-to-text uploads and forwards them to an OpenAI-compatible
+// STT backend (whisper.cpp server, faster-whisper-server, a worker). When a client asks to
+// stream but the backend answers with a single JSON body, the result is sent as one
+// transcript.text.done event.
+type Transcriber struct {
+	Backend     http.Handler
+	MaxBytes    int64
+	MaxDuration time.Duration
+	// Files resolves a file_id form field to a previously uploaded file, when set
+	Files func(r *http.Request, id string) (filename string, data []byte, err error)
+
+	mu    sync.Mutex
+	usage map[[2]string]*Usage
+}
+
+func NewTranscriber(backend http.Handler) *Transcriber {
+	return &Transcriber{
+		Backend:     backend,
+		MaxBytes:    25 << 20,
+		MaxDuration: 30 * time.Minute,
+		usage:       make(map[[2]string]*Usage),
+	}
+}
+
+// Usage returns audio seconds per model and task sorted by model
+func (t *Transcriber) Usage() []Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]Usage, 0, len(t.usage))
+	for _, u := range t.usage {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Model != out[j].Model {
+			return out[i].Model < out[j].Model
+		}
+		return out[i].Task < out[j].Task
+	})
+	return out
+}
+
+func (t *Transcriber) record(model, task string, seconds float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := [2]string{model, task}
+	u, ok := t.usage[k]
+	if !ok {
+		u = &Usage{Model: model, Task: task}
+		t.usage[k] = u
+	}
+	u.Requests++
+	u.AudioSeconds += seconds
+}
+
+// ServeHTTP handles both audio routes; the task is taken from the path
+func (t *Transcriber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	task := "transcription"
+	if r.URL.Path == TranslationsPath {
+		task = "translation"
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, t.MaxBytes+formOverhead)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			openai.WriteError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "file_too_large", fmt.Sprintf("audio uploads are limited to %d MB", t.MaxBytes>>20))
+			return
+		}
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "invalid_request", "expected a multipart/form-data upload: "+err.Error())
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	filename, data, err := t.upload(r)
+	if err != nil {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "invalid_request", err.Error())
+		return
+	}
+	if int64(len(data)) > t.MaxBytes {
+		openai.WriteError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "file_too_large", fmt.Sprintf("audio uploads are limited to %d MB", t.MaxBytes>>20))
+		return
+	}
+
+	duration, known := Duration(data)
+	if known && t.MaxDuration > 0 && duration > t.MaxDuration {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "audio_too_long", fmt.Sprintf("audio is %s long, the limit is %s", duration.Round(time.Second), t.MaxDuration))
+		return
+	}
+
+	body, contentType, err := rebuildForm(r.MultipartForm.Value, filename, data)
+	if err != nil {
+		openai.WriteError(w, http.StatusInternalServerError, "server_error", "server_error", err.Error())
+		return
+	}
+	model := r.FormValue("model")
+	stream := r.FormValue("stream") == "true"
+
+	forward := r.Clone(r.Context())
+	// Drop the parsed form so in-process backends read the rebuilt body
+	forward.Form, forward.PostForm, forward.MultipartForm = nil, nil, nil
+	forward.Body = io.NopCloser(bytes.NewReader(body))
+	forward.ContentLength = int64(len(body))
+	forward.Header.Set("Content-Type", contentType)
+	forward.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	if model != "" {
+		// Multipart bodies are opaque to model routing, so name the model in the header
+		forward.Header.Set(engine.ModelHeader, model)
+	}
+	if known {
+		w.Header().Set(SecondsHeader, strconv.FormatFloat(duration.Seconds(), 'f', 2, 64))
+	}
+
+	sw := &sttWriter{ResponseWriter: w, stream: stream}
+	t.Backend.ServeHTTP(sw, forward)
+	sw.finish()
+
+	if sw.status >= 300 {
+		return
+	}
+	seconds := duration.Seconds()
+	if !known {
+		// verbose_json responses report the duration the backend decoded
+		seconds = sw.reportedDuration()
+	}
+	if model == "" {
+		model = "default"
+	}
+	t.record(model, task, seconds)
+}
+
+// upload returns the audio sent in the file field, or the stored file named by file_id
+func (t *Transcriber) upload(r *http.Request) (string, []byte, error) {
+	file, header, err := r.FormFile("file")
+	if err == nil {
+		defer file.Close()
+		data, err := io.ReadAll(io.LimitReader(file, t.MaxBytes+1))
+		return header.Filename, data, err
+	}
+	id := r.FormValue("file_id")
+	if id == "" || t.Files == nil {
+		return "", nil, errors.New("missing file field")
+	}
+	filename, data, err := t.Files(r, id)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", id, err)
+	}
+	return filename, data, nil
+}
+
+// rebuildForm re-encodes the validated upload with its text fields for the backend
+func rebuildForm(values map[string][]string, filename string, data []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if name != "file_id" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range values[name] {
+			if err := mw.WriteField(name, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
+
+// sttWriter passes backend responses through, flushing streamed events as they arrive. When the
+// client asked for a stream and the backend returned JSON, it buffers and converts the result.
+type sttWriter struct {
+	http.ResponseWriter
+	stream    bool
+	status    int
+	buffering bool
+	decided   bool
+	body      bytes.Buffer
+}
+
+func (s *sttWriter) decide() {
+	if s.decided {
+		return
+	}
+	s.decided = true
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	contentType := s.Header().Get("Content-Type")
+	s.buffering = s.stream && s.status < 300 && strings.HasPrefix(contentType, "application/json")
+	if !s.buffering {
+		s.ResponseWriter.WriteHeader(s.status)
+	}
+}
+
+func (s *sttWriter) WriteHeader(code int) {
+	if s.decided {
+		return
+	}
+	s.status = code
+	s.decide()
+}
+
+func (s *sttWriter) Write(b []byte) (int, error) {
+	s.decide()
+	if s.body.Len() < formOverhead {
+		s.body.Write(b[:min(len(b), formOverhead-s.body.Len())])
+	}
+	if s.buffering {
+		return len(b), nil
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *sttWriter) Flush() {
+	if s.buffering {
+		return
+	}
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish emits the buffered JSON transcript as a single streamed event
+func (s *sttWriter) finish() {
+	s.decide()
+	if !s.buffering {
+		return
+	}
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(s.body.Bytes(), &result); err != nil {
+		slog.Warn("audio backend returned unparseable JSON", "err", err)
+	}
+	event, _ := json.Marshal(map[string]string{"type": "transcript.text.done", "text": result.Text})
+
+	h := s.ResponseWriter.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Del("Content-Length")
+	s.ResponseWriter.WriteHeader(http.StatusOK)
+	fmt.Fprintf(s.ResponseWriter, "data: %s\n\ndata: [DONE]\n\n", event)
+}
+
+func (s *sttWriter) reportedDuration() float64 {
+	var payload struct {
+		Duration float64 `json:"duration"`
+	}
+	_ = json.Unmarshal(s.body.Bytes(), &payload)
+	return payload.Duration
+}
@@ -0,0 +1,157 @@
+package audio
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func upload(t *testing.T, path string, file []byte, fields map[string]string) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	part, _ := mw.CreateFormFile("file", "speech.wav")
+	part.Write(file)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestTranscriberForwardsAndMeters(t *testing.T) {
+	var gotModel, gotLanguage string
+	var gotFile []byte
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotModel = r.Header.Get("X-Model")
+		gotLanguage = r.FormValue("language")
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotFile, _ = io.ReadAll(f)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"hello world"}`))
+	})
+	transcriber := NewTranscriber(backend)
+
+	audio := wav(2, 8000)
+	rec := httptest.NewRecorder()
+	transcriber.ServeHTTP(rec, upload(t, TranscriptionsPath, audio, map[string]string{"model": "whisper-small", "language": "en"}))
+
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "hello world") {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	if gotModel != "whisper-small" || gotLanguage != "en" || !bytes.Equal(gotFile, audio) {
+		t.Errorf("backend got model=%q language=%q file=%d bytes", gotModel, gotLanguage, len(gotFile))
+	}
+	if rec.Header().Get(SecondsHeader) != "2.00" {
+		t.Errorf("want 2.00 audio seconds header, got %q", rec.Header().Get(SecondsHeader))
+	}
+
+	usage := transcriber.Usage()
+	if len(usage) != 1 || usage[0].AudioSeconds != 2 || usage[0].Task != "transcription" {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
+
+func TestTranscriberEnforcesLimits(t *testing.T) {
+	called := false
+	transcriber := NewTranscriber(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	transcriber.MaxDuration = time.Second
+
+	rec := httptest.NewRecorder()
+	transcriber.ServeHTTP(rec, upload(t, TranslationsPath, wav(3, 8000), nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "audio_too_long") {
+		t.Errorf("want audio_too_long, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	transcriber.MaxBytes = 1000
+	rec = httptest.NewRecorder()
+	transcriber.ServeHTTP(rec, upload(t, TranscriptionsPath, make([]byte, 5000), nil))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("want 413, got %d %s", rec.Code, rec.Body.String())
+	}
+	if called {
+		t.Error("rejected uploads must not reach the backend")
+	}
+}
+
+func TestTranscriberStreamsBufferedResult(t *testing.T) {
+	transcriber := NewTranscriber(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"text":"hi","duration":4.5}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	transcriber.ServeHTTP(rec, upload(t, TranscriptionsPath, []byte("opaque m4a bytes"), map[string]string{"stream": "true"}))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("want event stream, got %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, `"type":"transcript.text.done"`) || !strings.Contains(body, "[DONE]") {
+		t.Errorf("unexpected stream %q", body)
+	}
+	// Unknown container: duration comes from the backend's response
+	if usage := transcriber.Usage(); len(usage) != 1 || usage[0].AudioSeconds != 4.5 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
+
+func TestTranscriberPassesThroughBackendStreams(t *testing.T) {
+	transcriber := NewTranscriber(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"type\":\"transcript.text.delta\",\"delta\":\"he\"}\n\n"))
+		w.(http.Flusher).Flush()
+		w.Write([]byte("data: {\"type\":\"transcript.text.done\",\"text\":\"hello\"}\n\n"))
+	}))
+
+	rec := httptest.NewRecorder()
+	transcriber.ServeHTTP(rec, upload(t, TranscriptionsPath, wav(1, 8000), map[string]string{"stream": "true"}))
+	if !strings.Contains(rec.Body.String(), "transcript.text.delta") || !rec.Flushed {
+		t.Errorf("want backend events passed through and flushed, got %q", rec.Body.String())
+	}
+}
+
+func TestTranscriberResolvesFileIDs(t *testing.T) {
+	var gotFile []byte
+	var gotFields []string
+	transcriber := NewTranscriber(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, _ := r.FormFile("file")
+		gotFile, _ = io.ReadAll(f)
+		for name := range r.MultipartForm.Value {
+			gotFields = append(gotFields, name)
+		}
+		w.Write([]byte(`{"text":"ok"}`))
+	}))
+	stored := wav(1, 8000)
+	transcriber.Files = func(r *http.Request, id string) (string, []byte, error) {
+		if id != "file-abc" {
+			return "", nil, io.EOF
+		}
+		return "meeting.wav", stored, nil
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("file_id", "file-abc")
+	mw.WriteField("model", "whisper")
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, TranscriptionsPath, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	rec := httptest.NewRecorder()
+	transcriber.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !bytes.Equal(gotFile, stored) {
+		t.Fatalf("want stored audio forwarded, got %d (%d bytes)", rec.Code, len(gotFile))
+	}
+	if len(gotFields) != 1 || gotFields[0] != "model" {
+		t.Errorf("file_id should not reach the backend, got fields %v", gotFields)
+	}
+}
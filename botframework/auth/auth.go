@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"botframework/openai"
+	"botframework/usage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// usageDays is how many days of usage are kept per key
+const usageDays = 31
+
+// DayUsage is what one key spent on one UTC day
+type DayUsage struct {
+	Day              string `json:"day"` // YYYY-MM-DD
+	Requests         int    `json:"requests"`
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	RateLimited      int    `json:"rate_limited"`
+	QuotaExceeded    int    `json:"quota_exceeded"`
+}
+
+// KeyUsage is a key, its limits and its recent usage, newest day first
+type KeyUsage struct {
+	Key
+	TokensLeft int        `json:"tokens_left,omitempty"` // today's remaining quota
+	Days       []DayUsage `json:"days"`
+}
+
+// Authenticator admits requests carrying a known key, within its rate limit and daily quota
+type Authenticator struct {
+	Store Store
+	// Public lists path prefixes served without a key
+	Public []string
+	// UsagePath persists usage so quotas survive restarts; empty keeps it in memory
+	UsagePath string
+
+	now func() time.Time
+
+	mu      sync.Mutex
+	usage   map[string]map[string]*DayUsage // key ID -> day -> usage
+	buckets map[string]*bucket
+	dirty   bool
+}
+
+func NewAuthenticator(store Store, usagePath string) (*Authenticator, error) {
+	a := &Authenticator{
+		Store:     store,
+		UsagePath: usagePath,
+		now:       time.Now,
+		usage:     make(map[string]map[string]*DayUsage),
+		buckets:   make(map[string]*bucket),
+	}
+	if usagePath == "" {
+		return a, nil
+	}
+	data, err := os.ReadFile(usagePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved map[string][]DayUsage
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("%s: %w", usagePath, err)
+	}
+	for id, days := range saved {
+		a.usage[id] = make(map[string]*DayUsage, len(days))
+		for _, day := range days {
+			a.usage[id][day.Day] = &day
+		}
+	}
+	return a, nil
+}
+
+// bucket is a token bucket refilled at RateLimit requests per minute
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take spends one request from the bucket of key, or returns how long until one is available
+func (a *Authenticator) take(key *Key, now time.Time) (bool, time.Duration) {
+	if key.RateLimit <= 0 {
+		return true, 0
+	}
+	capacity := float64(key.RateLimit)
+	perSecond := capacity / 60
+	b, ok := a.buckets[key.ID]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		a.buckets[key.ID] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+}
+
+// day returns the usage of key ID on the UTC day of now, pruning days past usageDays
+func (a *Authenticator) day(id string, now time.Time) *DayUsage {
+	days, ok := a.usage[id]
+	if !ok {
+		days = make(map[string]*DayUsage)
+		a.usage[id] = days
+	}
+	today := now.UTC().Format(time.DateOnly)
+	usage, ok := days[today]
+	if !ok {
+		usage = &DayUsage{Day: today}
+		days[today] = usage
+		oldest := now.UTC().AddDate(0, 0, -usageDays+1).Format(time.DateOnly)
+		for day := range days {
+			if day < oldest {
+				delete(days, day)
+			}
+		}
+	}
+	return usage
+}
+
+func (a *Authenticator) public(path string) bool {
+	for _, prefix := range a.Public {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware rejects requests without a valid key (401), over their key's rate limit or
+// past its daily token quota (429), and attaches the key to the request context. Tokens
+// in each response's usage, or one per streamed chunk without it, count against the quota.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// browsers send CORS preflights without credentials
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if preflight || a.public(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := TokenFrom(r)
+		key, ok := a.Store.Lookup(token)
+		if token == "" || !ok || key.Disabled {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="botframework"`)
+			openai.WriteError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "a valid API key is required")
+			return
+		}
+
+		now := a.now()
+		a.mu.Lock()
+		today := a.day(key.ID, now)
+		allowed, wait := a.take(key, now)
+		switch {
+		case !allowed:
+			today.RateLimited++
+		case key.DailyTokens > 0 && today.TotalTokens >= key.DailyTokens:
+			today.QuotaExceeded++
+		default:
+			today.Requests++
+		}
+		spent := today.TotalTokens
+		a.dirty = true
+		a.mu.Unlock()
+
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			openai.WriteError(w, http.StatusTooManyRequests, "rate_limit_error", "rate_limit_exceeded",
+				fmt.Sprintf("key %s is limited to %d requests per minute", key.ID, key.RateLimit))
+			return
+		}
+		if key.DailyTokens > 0 && spent >= key.DailyTokens {
+			midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(midnight.Sub(now).Seconds()))))
+			openai.WriteError(w, http.StatusTooManyRequests, "rate_limit_error", "quota_exceeded",
+				fmt.Sprintf("key %s used its %d tokens for today", key.ID, key.DailyTokens))
+			return
+		}
+
+		uw := usage.NewWriter(w)
+		next.ServeHTTP(uw, r.WithContext(WithKey(r.Context(), key)))
+		prompt, completion, reported := uw.Tokens()
+		if !reported {
+			// streams that never report usage count one completion token per event
+			completion = uw.Chunks()
+		}
+		if prompt+completion == 0 {
+			return
+		}
+		a.mu.Lock()
+		today = a.day(key.ID, now)
+		today.PromptTokens += prompt
+		today.CompletionTokens += completion
+		today.TotalTokens += prompt + completion
+		a.dirty = true
+		a.mu.Unlock()
+	})
+}
+
+// Usage reports every key with its usage, sorted by ID
+func (a *Authenticator) Usage() []KeyUsage {
+	keys := a.Store.Keys()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	today := a.now().UTC().Format(time.DateOnly)
+	out := make([]KeyUsage, 0, len(keys))
+	for _, key := range keys {
+		ku := KeyUsage{Key: key, Days: []DayUsage{}}
+		for _, day := range a.usage[key.ID] {
+			ku.Days = append(ku.Days, *day)
+		}
+		sort.Slice(ku.Days, func(i, j int) bool { return ku.Days[i].Day > ku.Days[j].Day })
+		if key.DailyTokens > 0 {
+			ku.TokensLeft = key.DailyTokens
+			if len(ku.Days) > 0 && ku.Days[0].Day == today {
+				ku.TokensLeft = max(key.DailyTokens-ku.Days[0].TotalTokens, 0)
+			}
+		}
+		out = append(out, ku)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Run saves usage every interval and once more when ctx is cancelled
+func (a *Authenticator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := a.Save(); err != nil {
+				slog.Warn("api key usage not saved", "err", err)
+			}
+			return
+		case <-ticker.C:
+			if err := a.Save(); err != nil {
+				slog.Warn("api key usage not saved", "err", err)
+			}
+		}
+	}
+}
+
+// Save writes usage to UsagePath when it changed since the last save
+func (a *Authenticator) Save() error {
+	if a.UsagePath == "" {
+		return nil
+	}
+	a.mu.Lock()
+	if !a.dirty {
+		a.mu.Unlock()
+		return nil
+	}
+	saved := make(map[string][]DayUsage, len(a.usage))
+	for id, days := range a.usage {
+		for _, day := range days {
+			saved[id] = append(saved[id], *day)
+		}
+		sort.Slice(saved[id], func(i, j int) bool { return saved[id][i].Day < saved[id][j].Day })
+	}
+	a.dirty = false
+	a.mu.Unlock()
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := a.UsagePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, a.UsagePath)
+}
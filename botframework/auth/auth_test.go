@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeKeys(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestAuthenticator(t *testing.T, keys string) *Authenticator {
+	t.Helper()
+	store, err := LoadFileStore(writeKeys(t, keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAuthenticator(store, filepath.Join(t.TempDir(), "usage.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Public = []string{"/v1/health"}
+	return a
+}
+
+func call(h http.Handler, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareAuthenticatesAndAttachesKey(t *testing.T) {
+	a := newTestAuthenticator(t, `{"keys": [
+		{"id": "web", "token": "secret"},
+		{"id": "cli", "token": "sha256:`+hashToken("hashed")+`"},
+		{"id": "old", "token": "retired", "disabled": true}
+	]}`)
+	var seen string
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, ok := KeyFrom(r.Context()); ok {
+			seen = key.ID
+		}
+	}))
+
+	for token, want := range map[string]string{"secret": "web", "hashed": "cli"} {
+		seen = ""
+		if rec := call(h, "/v1/chat/completions", token); rec.Code != http.StatusOK || seen != want {
+			t.Fatalf("token %q: status %d, key %q; want 200 and %q", token, rec.Code, seen, want)
+		}
+	}
+	for _, token := range []string{"", "wrong", "retired"} {
+		if rec := call(h, "/v1/chat/completions", token); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("token %q: status %d, want 401", token, rec.Code)
+		}
+	}
+	if rec := call(h, "/v1/health", ""); rec.Code != http.StatusOK {
+		t.Fatalf("public path: status %d, want 200", rec.Code)
+	}
+}
+
+func TestMiddlewareEnforcesRateLimit(t *testing.T) {
+	a := newTestAuthenticator(t, `{"keys": [{"id": "web", "token": "secret", "rate_limit": 2}]}`)
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	h := a.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for i := range 2 {
+		if rec := call(h, "/v1/chat/completions", "secret"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+	}
+	rec := call(h, "/v1/chat/completions", "secret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Fatalf("third request: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	now = now.Add(30 * time.Second)
+	if rec := call(h, "/v1/chat/completions", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("after refill: status %d", rec.Code)
+	}
+}
+
+func TestMiddlewareEnforcesDailyQuota(t *testing.T) {
+	a := newTestAuthenticator(t, `{"keys": [{"id": "web", "token": "secret", "daily_tokens": 100}]}`)
+	now := time.Date(2026, 5, 1, 23, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"usage":{"prompt_tokens":40,"completion_tokens":30,"total_tokens":70}}`))
+	}))
+
+	for i := range 2 {
+		if rec := call(h, "/v1/chat/completions", "secret"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i+1, rec.Code)
+		}
+	}
+	rec := call(h, "/v1/chat/completions", "secret")
+	if rec.Code != http.StatusTooManyRequests || !strings.Contains(rec.Body.String(), "quota_exceeded") {
+		t.Fatalf("over quota: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Retry-After"); got != "3600" {
+		t.Fatalf("Retry-After = %q, want seconds until midnight UTC", got)
+	}
+
+	usage := a.Usage()
+	if len(usage) != 1 || len(usage[0].Days) != 1 {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+	day := usage[0].Days[0]
+	if day.Requests != 2 || day.PromptTokens != 80 || day.CompletionTokens != 60 || day.QuotaExceeded != 1 || usage[0].TokensLeft != 0 {
+		t.Fatalf("unexpected day: %+v, tokens left %d", day, usage[0].TokensLeft)
+	}
+
+	now = now.Add(time.Hour)
+	if rec := call(h, "/v1/chat/completions", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("next day: status %d", rec.Code)
+	}
+}
+
+func TestUsageSurvivesRestart(t *testing.T) {
+	a := newTestAuthenticator(t, `{"keys": [{"id": "web", "token": "secret"}]}`)
+	h := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\":[]}\n\ndata: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":7,\"total_tokens\":12}}\n\ndata: [DONE]\n\n"))
+	}))
+	call(h, "/v1/chat/completions", "secret")
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := NewAuthenticator(a.Store, a.UsagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := restarted.Usage()
+	if len(usage) != 1 || len(usage[0].Days) != 1 || usage[0].Days[0].TotalTokens != 12 {
+		t.Fatalf("unexpected usage after restart: %+v", usage)
+	}
+}
+
+func TestFileStoreRejectsDuplicateIDs(t *testing.T) {
+	_, err := LoadFileStore(writeKeys(t, `{"keys": [{"id": "a", "token": "x"}, {"id": "a", "token": "y"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatalf("err = %v, want duplicate key id", err)
+	}
+}
+
+func TestFileStoreRejectsUnknownPriority(t *testing.T) {
+	_, err := LoadFileStore(writeKeys(t, `{"keys": [{"id": "a", "token": "x", "priority": "urgent"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "priority") {
+		t.Fatalf("err = %v, want an invalid priority", err)
+	}
+}
@@ -0,0 +1,231 @@
+// Package auth checks API keys on inference requests. Keys come from a Store; each may
+// carry a request rate limit and a daily token quota, and the tokens spent per key and day
+// are tracked for the admin API.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Key is an API key and its limits
+type Key struct {
+	// ID names the key in logs and the admin API
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Token is the secret clients send, stored in the clear or as "sha256:<hex>"
+	Token string `json:"token,omitempty"`
+	// RateLimit is the requests allowed per minute (0: unlimited)
+	RateLimit int `json:"rate_limit,omitempty"`
+	// DailyTokens is the prompt and completion tokens allowed per UTC day (0: unlimited)
+	DailyTokens int  `json:"daily_tokens,omitempty"`
+	Disabled    bool `json:"disabled,omitempty"`
+	// Priority is the queue class of the key's requests, "interactive" (the default) or
+	// "background" for batch jobs that should yield to chat traffic
+	Priority string `json:"priority,omitempty"`
+}
+
+// Store looks up the key a token belongs to. Other backends, a database for example, plug
+// in by implementing it.
+type Store interface {
+	Lookup(token string) (*Key, bool)
+	Keys() []Key
+}
+
+// ErrUnknownKey is returned for a key ID the store does not hold
+var ErrUnknownKey = errors.New("unknown key")
+
+// Rotator is a Store that can replace a key's token
+type Rotator interface {
+	// Rotate gives key id a new token and returns it; the old token stops working
+	Rotate(id string) (string, error)
+}
+
+// hashToken is the form tokens are indexed by, so clear and hashed entries match alike
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenFrom returns the API key r carries as a bearer token or X-Api-Key header
+func TokenFrom(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return strings.TrimSpace(r.Header.Get("X-Api-Key"))
+}
+
+// FileStore reads keys from a JSON file:
+//
+//	{"keys": [{"id": "web", "token": "sha256:9f86d0...", "rate_limit": 60, "daily_tokens": 200000}]}
+//
+// Edits to the file are picked up without a restart.
+type FileStore struct {
+	Path string
+
+	mu       sync.Mutex
+	byHash   map[string]*Key
+	keys     []Key
+	modTime  time.Time
+	checked  time.Time
+	lastErr  error
+	interval time.Duration
+}
+
+// LoadFileStore reads the keys in path, which must parse
+func LoadFileStore(path string) (*FileStore, error) {
+	s := &FileStore{Path: path, interval: 2 * time.Second}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) reload() error {
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return err
+	}
+	var file struct {
+		Keys []Key `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("%s: %w", s.Path, err)
+	}
+	byHash := make(map[string]*Key, len(file.Keys))
+	ids := make(map[string]bool, len(file.Keys))
+	for i := range file.Keys {
+		key := &file.Keys[i]
+		if key.ID == "" || key.Token == "" {
+			return fmt.Errorf("%s: key %d needs an id and a token", s.Path, i+1)
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("%s: duplicate key id %q", s.Path, key.ID)
+		}
+		ids[key.ID] = true
+		if key.Priority != "" && key.Priority != "interactive" && key.Priority != "background" {
+			return fmt.Errorf("%s: key %q: priority must be interactive or background", s.Path, key.ID)
+		}
+		hash, hashed := strings.CutPrefix(key.Token, "sha256:")
+		if !hashed {
+			hash = hashToken(key.Token)
+		}
+		byHash[strings.ToLower(hash)] = key
+		key.Token = ""
+	}
+	s.byHash, s.keys, s.modTime = byHash, file.Keys, info.ModTime()
+	return nil
+}
+
+// refresh rereads the file when it changed, keeping the previous keys if it no longer parses
+func (s *FileStore) refresh() {
+	if time.Since(s.checked) < s.interval {
+		return
+	}
+	s.checked = time.Now()
+	info, err := os.Stat(s.Path)
+	if err != nil || info.ModTime().Equal(s.modTime) {
+		return
+	}
+	err = s.reload()
+	if err != nil && (s.lastErr == nil || err.Error() != s.lastErr.Error()) {
+		slog.Warn("api keys not reloaded", "path", s.Path, "err", err)
+	}
+	s.lastErr = err
+}
+
+func (s *FileStore) Lookup(token string) (*Key, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	key, ok := s.byHash[hashToken(token)]
+	return key, ok
+}
+
+// Keys returns the keys without their tokens
+func (s *FileStore) Keys() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	return append([]Key(nil), s.keys...)
+}
+
+// Rotate gives key id a new random token, stored hashed in the key file, and returns it.
+// The file's other keys and fields are kept as they are.
+func (s *FileStore) Rotate(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return "", err
+	}
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		return "", fmt.Errorf("%s: %w", s.Path, err)
+	}
+	var keys []map[string]any
+	if err := json.Unmarshal(file["keys"], &keys); err != nil {
+		return "", fmt.Errorf("%s: %w", s.Path, err)
+	}
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := "bf-" + hex.EncodeToString(secret)
+	found := false
+	for _, key := range keys {
+		if key["id"] == id {
+			key["token"] = "sha256:" + hashToken(token)
+			found = true
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	if file["keys"], err = json.Marshal(keys); err != nil {
+		return "", err
+	}
+	if data, err = json.MarshalIndent(file, "", "  "); err != nil {
+		return "", err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return "", err
+	}
+	if err := s.reload(); err != nil {
+		return "", err
+	}
+	slog.Info("api key rotated", "id", id)
+	return token, nil
+}
+
+type contextKey struct{}
+
+// WithKey returns ctx carrying the key a request was authenticated with
+func WithKey(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFrom returns the key a request was authenticated with, if any
+func KeyFrom(ctx context.Context) (*Key, bool) {
+	key, ok := ctx.Value(contextKey{}).(*Key)
+	return key, ok
+}
@@ -0,0 +1,762 @@
+// Package batch runs OpenAI-style batch jobs: a JSONL file of requests uploaded to the file
+// store is executed in the background, yielding to interactive traffic, and the responses are
+// stored as a downloadable results file.
+package batch
+
+import (
+	"botframework/files"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is where a batch is in its lifecycle
+type Status string
+
+const (
+	StatusValidating Status = "validating"
+	StatusFailed     Status = "failed"
+	StatusInProgress Status = "in_progress"
+	StatusFinalizing Status = "finalizing"
+	StatusCompleted  Status = "completed"
+	StatusExpired    Status = "expired"
+	StatusCancelling Status = "cancelling"
+	StatusCancelled  Status = "cancelled"
+)
+
+// finished reports whether a batch in status s will not change again
+func (s Status) finished() bool {
+	return s == StatusFailed || s == StatusCompleted || s == StatusExpired || s == StatusCancelled
+}
+
+// Endpoints are the routes a batch may run
+var Endpoints = []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings"}
+
+// Errors returned by Runner
+var (
+	ErrNotFound = errors.New("batch not found")
+	ErrInvalid  = errors.New("invalid batch")
+	ErrFinished = errors.New("batch has already finished")
+)
+
+// Purposes of the files batches read and write
+const (
+	PurposeInput  = "batch"
+	PurposeOutput = "batch_output"
+)
+
+// Batch is the OpenAI-style state of a batch job
+type Batch struct {
+	ID               string  `json:"id"`
+	Object           string  `json:"object"`
+	Endpoint         string  `json:"endpoint"`
+	Errors           *Errors `json:"errors,omitempty"`
+	InputFileID      string  `json:"input_file_id"`
+	CompletionWindow string  `json:"completion_window"`
+	Status           Status  `json:"status"`
+	OutputFileID     string  `json:"output_file_id,omitempty"`
+	ErrorFileID      string  `json:"error_file_id,omitempty"`
+	CreatedAt        int64   `json:"created_at"`
+	InProgressAt     int64   `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64   `json:"expires_at"`
+	FinalizingAt     int64   `json:"finalizing_at,omitempty"`
+	CompletedAt      int64   `json:"completed_at,omitempty"`
+	FailedAt         int64   `json:"failed_at,omitempty"`
+	ExpiredAt        int64   `json:"expired_at,omitempty"`
+	CancellingAt     int64   `json:"cancelling_at,omitempty"`
+	CancelledAt      int64   `json:"cancelled_at,omitempty"`
+	RequestCounts    Counts  `json:"request_counts"`
+	// Metadata is the client's own labels, returned unchanged
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type Counts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Errors lists why a batch's input file was rejected
+type Errors struct {
+	Object string      `json:"object"`
+	Data   []LineError `json:"data"`
+}
+
+type LineError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Line is the 1-based input line the error is about
+	Line int `json:"line,omitempty"`
+}
+
+// CreateRequest is the body of POST /v1/batches
+type CreateRequest struct {
+	InputFileID string `json:"input_file_id"`
+	Endpoint    string `json:"endpoint"`
+	// CompletionWindow is how long the batch may run, "24h" by default
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// Request is one line of an input file
+type Request struct {
+	CustomID string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// Result is one line of an output or error file
+type Result struct {
+	ID       string     `json:"id"`
+	CustomID string     `json:"custom_id"`
+	Response *Response  `json:"response"`
+	Error    *LineError `json:"error"`
+}
+
+type Response struct {
+	StatusCode int             `json:"status_code"`
+	RequestID  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// stored is the on-disk metadata, which keeps the owner hidden from API responses
+type stored struct {
+	Batch
+	Owner string `json:"owner"`
+}
+
+// Runner keeps batches in Dir and executes them one at a time, in the order they were
+// created, by passing each request to Handler. Like files, every batch belongs to the owner
+// that created it.
+type Runner struct {
+	Dir     string
+	Files   *files.Store
+	Handler http.Handler
+	// Concurrency is the requests of a batch in flight at once
+	Concurrency int
+	// Retries is how often a request answered with 429 or a 5xx is tried again
+	Retries int
+	// Idle reports whether interactive traffic leaves room for another batch request. Batch
+	// requests wait while it returns false; nil never waits.
+	Idle func() bool
+
+	mu      sync.Mutex
+	batches map[string]*stored
+	wake    chan struct{}
+	now     func() time.Time
+	// backoff is the wait before retry n (0-based)
+	backoff func(n int) time.Duration
+}
+
+// NewRunner opens (or creates) a batch directory, loading the batches already in it.
+// Batches that were running when the manager stopped resume from where they left off.
+func NewRunner(dir string, store *files.Store, handler http.Handler) (*Runner, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	r := &Runner{
+		Dir:         dir,
+		Files:       store,
+		Handler:     handler,
+		Concurrency: 1,
+		Retries:     3,
+		batches:     make(map[string]*stored),
+		wake:        make(chan struct{}, 1),
+		now:         time.Now,
+		backoff:     func(n int) time.Duration { return time.Second << n },
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var meta stored
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		r.batches[meta.ID] = &meta
+	}
+	return r, nil
+}
+
+func (r *Runner) metaPath(id string) string   { return filepath.Join(r.Dir, id+".json") }
+func (r *Runner) outputPath(id string) string { return filepath.Join(r.Dir, id+".output.jsonl") }
+func (r *Runner) errorPath(id string) string  { return filepath.Join(r.Dir, id+".errors.jsonl") }
+
+// Create queues a batch of the requests in one of owner's files
+func (r *Runner) Create(owner string, req CreateRequest) (Batch, error) {
+	if !slices.Contains(Endpoints, req.Endpoint) {
+		return Batch{}, fmt.Errorf("%w: endpoint must be one of %v", ErrInvalid, Endpoints)
+	}
+	if req.CompletionWindow == "" {
+		req.CompletionWindow = "24h"
+	}
+	window, err := time.ParseDuration(req.CompletionWindow)
+	if err != nil || window <= 0 {
+		return Batch{}, fmt.Errorf("%w: completion_window %q is not a duration such as 24h", ErrInvalid, req.CompletionWindow)
+	}
+	file, err := r.Files.Get(owner, req.InputFileID)
+	if err != nil {
+		return Batch{}, fmt.Errorf("%w: input file %q not found", ErrInvalid, req.InputFileID)
+	}
+	if file.Purpose != PurposeInput {
+		return Batch{}, fmt.Errorf("%w: input file %s has purpose %q, want %q", ErrInvalid, file.ID, file.Purpose, PurposeInput)
+	}
+	id, err := newID("batch_")
+	if err != nil {
+		return Batch{}, err
+	}
+
+	now := r.now()
+	b := &stored{Owner: owner, Batch: Batch{
+		ID:               id,
+		Object:           "batch",
+		Endpoint:         req.Endpoint,
+		InputFileID:      req.InputFileID,
+		CompletionWindow: req.CompletionWindow,
+		Status:           StatusValidating,
+		CreatedAt:        now.Unix(),
+		ExpiresAt:        now.Add(window).Unix(),
+		Metadata:         req.Metadata,
+	}}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.save(b); err != nil {
+		return Batch{}, err
+	}
+	r.batches[id] = b
+	r.signal()
+	return b.Batch, nil
+}
+
+// Get returns one of owner's batches
+func (r *Runner) Get(owner, id string) (Batch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.batches[id]
+	if !ok || b.Owner != owner {
+		return Batch{}, ErrNotFound
+	}
+	return b.Batch, nil
+}
+
+// List returns up to limit of owner's batches, newest first, starting after the batch
+// with ID after. more reports whether older batches remain.
+func (r *Runner) List(owner, after string, limit int) (batches []Batch, more bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	batches = []Batch{}
+	for _, b := range r.batches {
+		if b.Owner == owner {
+			batches = append(batches, b.Batch)
+		}
+	}
+	sort.Slice(batches, func(i, j int) bool {
+		if batches[i].CreatedAt != batches[j].CreatedAt {
+			return batches[i].CreatedAt > batches[j].CreatedAt
+		}
+		return batches[i].ID > batches[j].ID
+	})
+	if after != "" {
+		for i, b := range batches {
+			if b.ID == after {
+				batches = batches[i+1:]
+				break
+			}
+		}
+	}
+	if limit > 0 && len(batches) > limit {
+		return batches[:limit], true
+	}
+	return batches, false
+}
+
+// Cancel stops one of owner's batches. Requests in flight finish, and the responses so far
+// are stored as the batch's output.
+func (r *Runner) Cancel(owner, id string) (Batch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.batches[id]
+	if !ok || b.Owner != owner {
+		return Batch{}, ErrNotFound
+	}
+	switch {
+	case b.Status.finished() || b.Status == StatusFinalizing:
+		return b.Batch, ErrFinished
+	case b.Status != StatusCancelling:
+		b.Status, b.CancellingAt = StatusCancelling, r.now().Unix()
+		if err := r.save(b); err != nil {
+			return Batch{}, err
+		}
+		r.signal()
+	}
+	return b.Batch, nil
+}
+
+// Run executes queued batches until ctx is cancelled
+func (r *Runner) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if id := r.next(); id != "" {
+			r.process(ctx, id)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		}
+	}
+}
+
+// next returns the oldest unfinished batch, if any
+func (r *Runner) next() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var oldest *stored
+	for _, b := range r.batches {
+		if b.Status.finished() {
+			continue
+		}
+		if oldest == nil || b.CreatedAt < oldest.CreatedAt || (b.CreatedAt == oldest.CreatedAt && b.ID < oldest.ID) {
+			oldest = b
+		}
+	}
+	if oldest == nil {
+		return ""
+	}
+	return oldest.ID
+}
+
+func (r *Runner) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// process takes a batch from wherever it is to a final status, unless ctx is cancelled
+// first, in which case it resumes on the next run
+func (r *Runner) process(ctx context.Context, id string) {
+	b := r.snapshot(id)
+	requests, lineErrs, err := r.validate(b)
+	switch {
+	case err != nil || len(lineErrs) > 0:
+		if err != nil {
+			lineErrs = []LineError{{Code: "invalid_file", Message: err.Error()}}
+		}
+		r.update(id, func(b *stored) {
+			b.Status, b.FailedAt = StatusFailed, r.now().Unix()
+			b.Errors = &Errors{Object: "list", Data: lineErrs}
+		})
+		slog.Warn("batch failed validation", "batch", id, "errors", len(lineErrs))
+		return
+	case b.Status == StatusValidating:
+		b = r.update(id, func(b *stored) {
+			b.Status, b.InProgressAt = StatusInProgress, r.now().Unix()
+			b.RequestCounts.Total = len(requests)
+		})
+		slog.Info("batch started", "batch", id, "requests", len(requests), "endpoint", b.Endpoint)
+	case b.RequestCounts.Total == 0:
+		// cancelled before it started
+		b = r.update(id, func(b *stored) { b.RequestCounts.Total = len(requests) })
+	}
+
+	if b.Status == StatusInProgress || b.Status == StatusCancelling {
+		if err := r.execute(ctx, b, requests); err != nil {
+			if ctx.Err() == nil {
+				slog.Error("batch interrupted", "batch", id, "err", err)
+			}
+			return
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	r.finalize(id)
+}
+
+// validate reads a batch's input file, returning its requests or what is wrong with its lines
+func (r *Runner) validate(b stored) ([]Request, []LineError, error) {
+	content, _, err := r.Files.Open(b.Owner, b.InputFileID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("input file %s: %w", b.InputFileID, err)
+	}
+	defer content.Close()
+
+	var requests []Request
+	var lineErrs []LineError
+	seen := make(map[string]bool)
+	fail := func(line int, code, format string, args ...any) {
+		if len(lineErrs) < 100 {
+			lineErrs = append(lineErrs, LineError{Code: code, Message: fmt.Sprintf(format, args...), Line: line})
+		}
+	}
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			fail(line, "invalid_json_line", "line is not a JSON request: %v", err)
+			continue
+		}
+		var body map[string]any
+		switch {
+		case req.CustomID == "":
+			fail(line, "missing_custom_id", "custom_id is required")
+		case seen[req.CustomID]:
+			fail(line, "duplicate_custom_id", "custom_id %q is used by an earlier line", req.CustomID)
+		case req.Method != http.MethodPost:
+			fail(line, "invalid_method", "method must be POST")
+		case req.URL != b.Endpoint:
+			fail(line, "mismatched_url", "url %q does not match the batch endpoint %s", req.URL, b.Endpoint)
+		case json.Unmarshal(req.Body, &body) != nil || body == nil:
+			fail(line, "invalid_body", "body must be a JSON object")
+		case body["stream"] == true:
+			fail(line, "invalid_body", "streaming responses are not supported in batches")
+		}
+		seen[req.CustomID] = true
+		requests = append(requests, req)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(requests) == 0 && len(lineErrs) == 0 {
+		fail(0, "empty_file", "the input file has no requests")
+	}
+	return requests, lineErrs, nil
+}
+
+// execute runs the requests that have no result yet, appending each result to the batch's
+// output or error file as it arrives. It returns an error when ctx is cancelled so the
+// batch resumes later; requests the completion window runs out on are recorded as expired.
+func (r *Runner) execute(ctx context.Context, b stored, requests []Request) error {
+	done, err := r.recorded(b.ID)
+	if err != nil {
+		return err
+	}
+	output, err := os.OpenFile(r.outputPath(b.ID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	errorsFile, err := os.OpenFile(r.errorPath(b.ID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer errorsFile.Close()
+
+	var writeMu sync.Mutex
+	record := func(result Result, failed bool) {
+		line, _ := json.Marshal(result)
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		file := output
+		if failed {
+			file = errorsFile
+		}
+		file.Write(append(line, '\n'))
+		r.update(b.ID, func(b *stored) {
+			if failed {
+				b.RequestCounts.Failed++
+			} else {
+				b.RequestCounts.Completed++
+			}
+		})
+	}
+
+	runCtx, cancel := context.WithDeadline(ctx, time.Unix(b.ExpiresAt, 0))
+	defer cancel()
+	slots := make(chan struct{}, max(1, r.Concurrency))
+	var wg sync.WaitGroup
+	var expired []Request
+	for i, req := range requests {
+		if done[req.CustomID] {
+			continue
+		}
+		if !r.waitIdle(runCtx) {
+			expired = requests[i:]
+			break
+		}
+		if r.snapshot(b.ID).Status == StatusCancelling {
+			break
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-runCtx.Done():
+			expired = requests[i:]
+		}
+		if expired != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result, failed, ok := r.call(runCtx, b, req)
+			if !ok {
+				if ctx.Err() == nil {
+					record(expiredResult(req), true)
+				}
+				return
+			}
+			record(result, failed)
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	for _, req := range expired {
+		if !done[req.CustomID] {
+			record(expiredResult(req), true)
+		}
+	}
+	if runCtx.Err() != nil {
+		r.update(b.ID, func(b *stored) {
+			if b.Status == StatusInProgress {
+				b.Status = StatusExpired
+			}
+		})
+	}
+	return nil
+}
+
+// recorded returns the custom IDs that already have a result, from an earlier run
+func (r *Runner) recorded(id string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	for _, path := range []string{r.outputPath(id), r.errorPath(id)} {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 64<<20)
+		for scanner.Scan() {
+			var result Result
+			if json.Unmarshal(scanner.Bytes(), &result) == nil {
+				done[result.CustomID] = true
+			}
+		}
+		f.Close()
+	}
+	return done, nil
+}
+
+// waitIdle waits until Idle allows another request, reporting false if ctx ends first
+func (r *Runner) waitIdle(ctx context.Context) bool {
+	for r.Idle != nil && !r.Idle() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// call passes req to Handler, retrying 429s and 5xxs. ok is false when ctx ended first.
+func (r *Runner) call(ctx context.Context, b stored, req Request) (result Result, failed, ok bool) {
+	id, _ := newID("batch_req_")
+	result = Result{ID: id, CustomID: req.CustomID}
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, b.Endpoint, bytes.NewReader(req.Body))
+		if err != nil {
+			result.Error = &LineError{Code: "invalid_request", Message: err.Error()}
+			return result, true, true
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("X-BotFramework-Batch", b.ID)
+		// queue behind interactive traffic
+		httpReq.Header.Set("X-BotFramework-Priority", "background")
+		w := &responseRecorder{header: make(http.Header)}
+		r.Handler.ServeHTTP(w, httpReq)
+		if ctx.Err() != nil {
+			return result, false, false
+		}
+		status := w.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		retryable := status == http.StatusTooManyRequests || status >= 500
+		if retryable && attempt < r.Retries {
+			wait := r.backoff(attempt)
+			if seconds, err := strconv.Atoi(w.header.Get("Retry-After")); err == nil && seconds > 0 {
+				wait = min(time.Duration(seconds)*time.Second, time.Minute)
+			}
+			select {
+			case <-ctx.Done():
+				return result, false, false
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		body := w.body.Bytes()
+		if !json.Valid(body) {
+			body, _ = json.Marshal(string(body))
+		}
+		requestID := w.header.Get("X-Request-Id")
+		if requestID == "" {
+			requestID, _ = newID("req_")
+		}
+		result.Response = &Response{StatusCode: status, RequestID: requestID, Body: body}
+		return result, status >= 400, true
+	}
+}
+
+func expiredResult(req Request) Result {
+	id, _ := newID("batch_req_")
+	return Result{ID: id, CustomID: req.CustomID, Error: &LineError{
+		Code:    "batch_expired",
+		Message: "the request was not executed before the batch's completion window ended",
+	}}
+}
+
+// finalize stores the results as files and gives the batch its final status
+func (r *Runner) finalize(id string) {
+	b := r.update(id, func(b *stored) {
+		b.FinalizingAt = r.now().Unix()
+		if b.Status == StatusInProgress || b.Status == StatusValidating {
+			b.Status = StatusFinalizing
+		}
+	})
+	outputID, err := r.publish(b, r.outputPath(id), "output")
+	if err == nil {
+		var errorID string
+		if errorID, err = r.publish(b, r.errorPath(id), "errors"); err == nil {
+			b = r.update(id, func(b *stored) { b.OutputFileID, b.ErrorFileID = outputID, errorID })
+		}
+	}
+	if err != nil {
+		slog.Error("batch results could not be stored", "batch", id, "err", err)
+		r.update(id, func(b *stored) {
+			b.Status, b.FailedAt = StatusFailed, r.now().Unix()
+			b.Errors = &Errors{Object: "list", Data: []LineError{{Code: "output_failed", Message: err.Error()}}}
+		})
+		return
+	}
+	os.Remove(r.outputPath(id))
+	os.Remove(r.errorPath(id))
+
+	b = r.update(id, func(b *stored) {
+		now := r.now().Unix()
+		switch b.Status {
+		case StatusCancelling:
+			b.Status, b.CancelledAt = StatusCancelled, now
+		case StatusExpired:
+			b.ExpiredAt = now
+		default:
+			b.Status, b.CompletedAt = StatusCompleted, now
+		}
+	})
+	slog.Info("batch finished", "batch", id, "status", b.Status,
+		"completed", b.RequestCounts.Completed, "failed", b.RequestCounts.Failed)
+}
+
+// publish moves a results file into the file store, returning "" when it is empty
+func (r *Runner) publish(b stored, path, kind string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() == 0 {
+		return "", err
+	}
+	file, err := r.Files.Create(b.Owner, b.ID+"_"+kind+".jsonl", PurposeOutput, 0, f)
+	if err != nil {
+		return "", err
+	}
+	return file.ID, nil
+}
+
+func (r *Runner) snapshot(id string) stored {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.batches[id]
+}
+
+// update applies change to a batch and saves it, returning the result
+func (r *Runner) update(id string, change func(*stored)) stored {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := r.batches[id]
+	change(b)
+	if err := r.save(b); err != nil {
+		slog.Warn("batch state not saved", "batch", id, "err", err)
+	}
+	return *b
+}
+
+func (r *Runner) save(b *stored) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	tmp := r.metaPath(b.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.metaPath(b.ID))
+}
+
+// responseRecorder collects the response to a batch request
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) Header() http.Header { return w.header }
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+func newID(prefix string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
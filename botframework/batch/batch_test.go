@@ -0,0 +1,242 @@
+package batch
+
+import (
+	"botframework/files"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRunner(t *testing.T, handler http.Handler) (*Runner, *files.Store) {
+	t.Helper()
+	store, err := files.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner, err := NewRunner(t.TempDir(), store, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner.backoff = func(int) time.Duration { return time.Millisecond }
+	return runner, store
+}
+
+func upload(t *testing.T, store *files.Store, owner, purpose string, lines ...string) string {
+	t.Helper()
+	file, err := store.Create(owner, "input.jsonl", purpose, 0, strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file.ID
+}
+
+// waitFor runs the runner until the batch reaches a final status
+func waitFor(t *testing.T, runner *Runner, owner, id string) Batch {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { runner.Run(ctx); close(done) }()
+	defer func() { cancel(); <-done }()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, err := runner.Get(owner, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Status.finished() {
+			return b
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("batch stuck in %s: %+v", b.Status, b)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func readResults(t *testing.T, store *files.Store, owner, id string) map[string]Result {
+	t.Helper()
+	results := make(map[string]Result)
+	if id == "" {
+		return results
+	}
+	content, file, err := store.Open(owner, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+	if file.Purpose != PurposeOutput {
+		t.Errorf("results file purpose %q", file.Purpose)
+	}
+	scanner := bufio.NewScanner(content)
+	for scanner.Scan() {
+		var result Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		results[result.CustomID] = result
+	}
+	return results
+}
+
+func TestBatchRunsRetriesAndReportsFailures(t *testing.T) {
+	var flaky atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Model string }
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Header.Get("X-BotFramework-Batch") == "":
+			http.Error(w, "not a batch request", http.StatusTeapot)
+		case body.Model == "flaky" && flaky.Add(1) < 3:
+			http.Error(w, "worker restarting", http.StatusServiceUnavailable)
+		case body.Model == "missing":
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error": {"message": "model not found"}}`)
+		default:
+			w.Header().Set("X-Request-Id", "req-"+body.Model)
+			io.WriteString(w, `{"object": "chat.completion", "model": "`+body.Model+`"}`)
+		}
+	})
+	runner, store := newTestRunner(t, handler)
+	input := upload(t, store, "alice", PurposeInput,
+		`{"custom_id": "a", "method": "POST", "url": "/v1/chat/completions", "body": {"model": "phi"}}`,
+		`{"custom_id": "b", "method": "POST", "url": "/v1/chat/completions", "body": {"model": "flaky"}}`,
+		``,
+		`{"custom_id": "c", "method": "POST", "url": "/v1/chat/completions", "body": {"model": "missing"}}`,
+	)
+	created, err := runner.Create("alice", CreateRequest{InputFileID: input, Endpoint: "/v1/chat/completions", Metadata: map[string]string{"job": "nightly"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != StatusValidating || created.CompletionWindow != "24h" || created.ExpiresAt-created.CreatedAt != 86400 {
+		t.Errorf("created = %+v", created)
+	}
+	if _, err := runner.Get("bob", created.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other owners must not see the batch, got %v", err)
+	}
+
+	b := waitFor(t, runner, "alice", created.ID)
+	if b.Status != StatusCompleted || b.RequestCounts != (Counts{Total: 3, Completed: 2, Failed: 1}) || b.Metadata["job"] != "nightly" {
+		t.Fatalf("batch = %+v", b)
+	}
+	output := readResults(t, store, "alice", b.OutputFileID)
+	if len(output) != 2 || output["a"].Response.StatusCode != 200 || output["a"].Response.RequestID != "req-phi" {
+		t.Errorf("output = %+v", output)
+	}
+	if flaky.Load() != 3 || output["b"].Response == nil || output["b"].Response.StatusCode != 200 {
+		t.Errorf("5xx responses should be retried: %d attempts, %+v", flaky.Load(), output["b"])
+	}
+	errs := readResults(t, store, "alice", b.ErrorFileID)
+	if got := errs["c"].Response; len(errs) != 1 || got == nil || got.StatusCode != 404 || !strings.Contains(string(got.Body), "model not found") {
+		t.Errorf("errors = %+v", errs)
+	}
+
+	if list, more := runner.List("alice", "", 10); len(list) != 1 || more || list[0].ID != b.ID {
+		t.Errorf("List() = %+v, %v", list, more)
+	}
+	reopened, err := NewRunner(runner.Dir, store, handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := reopened.Get("alice", b.ID); err != nil || again.Status != StatusCompleted || again.OutputFileID != b.OutputFileID {
+		t.Errorf("batch not persisted: %+v %v", again, err)
+	}
+}
+
+func TestBatchRejectsInvalidInput(t *testing.T) {
+	runner, store := newTestRunner(t, http.NotFoundHandler())
+	if _, err := runner.Create("alice", CreateRequest{InputFileID: "file-missing", Endpoint: "/v1/chat/completions"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("missing input file: %v", err)
+	}
+	wrongPurpose := upload(t, store, "alice", "assistants", `{}`)
+	if _, err := runner.Create("alice", CreateRequest{InputFileID: wrongPurpose, Endpoint: "/v1/chat/completions"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("input file purpose: %v", err)
+	}
+	input := upload(t, store, "alice", PurposeInput, `{}`)
+	if _, err := runner.Create("alice", CreateRequest{InputFileID: input, Endpoint: "/admin/workers"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("endpoint: %v", err)
+	}
+	if _, err := runner.Create("bob", CreateRequest{InputFileID: input, Endpoint: "/v1/chat/completions"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("another owner's file: %v", err)
+	}
+
+	input = upload(t, store, "alice", PurposeInput,
+		`{"custom_id": "a", "method": "POST", "url": "/v1/chat/completions", "body": {}}`,
+		`{"custom_id": "a", "method": "POST", "url": "/v1/chat/completions", "body": {}}`,
+		`{"custom_id": "b", "method": "GET", "url": "/v1/chat/completions", "body": {}}`,
+		`{"custom_id": "c", "method": "POST", "url": "/v1/embeddings", "body": {}}`,
+		`{"custom_id": "d", "method": "POST", "url": "/v1/chat/completions", "body": {"stream": true}}`,
+		`not json`,
+	)
+	created, err := runner.Create("alice", CreateRequest{InputFileID: input, Endpoint: "/v1/chat/completions"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := waitFor(t, runner, "alice", created.ID)
+	if b.Status != StatusFailed || b.Errors == nil {
+		t.Fatalf("batch = %+v", b)
+	}
+	var codes []string
+	for _, e := range b.Errors.Data {
+		codes = append(codes, e.Code)
+	}
+	if want := "duplicate_custom_id invalid_method mismatched_url invalid_body invalid_json_line"; strings.Join(codes, " ") != want {
+		t.Errorf("errors %v, want %s", codes, want)
+	}
+}
+
+func TestBatchWaitsForIdleAndCancels(t *testing.T) {
+	var idle atomic.Bool
+	var mu sync.Mutex
+	var calls int
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		io.WriteString(w, `{}`)
+	})
+	runner, store := newTestRunner(t, handler)
+	runner.Idle = idle.Load
+	input := upload(t, store, "alice", PurposeInput,
+		`{"custom_id": "a", "method": "POST", "url": "/v1/embeddings", "body": {"input": "x"}}`,
+		`{"custom_id": "b", "method": "POST", "url": "/v1/embeddings", "body": {"input": "y"}}`,
+	)
+	created, err := runner.Create("alice", CreateRequest{InputFileID: input, Endpoint: "/v1/embeddings"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { runner.Run(ctx); close(done) }()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	if calls != 0 {
+		t.Errorf("%d requests ran while interactive traffic was busy", calls)
+	}
+	mu.Unlock()
+	if b, _ := runner.Get("alice", created.ID); b.Status != StatusInProgress || b.RequestCounts.Total != 2 {
+		t.Errorf("batch = %+v", b)
+	}
+
+	if _, err := runner.Cancel("alice", created.ID); err != nil {
+		t.Fatal(err)
+	}
+	idle.Store(true)
+	cancel()
+	<-done
+	b := waitFor(t, runner, "alice", created.ID)
+	if b.Status != StatusCancelled || b.CancelledAt == 0 || b.OutputFileID != "" {
+		t.Errorf("batch = %+v", b)
+	}
+	if _, err := runner.Cancel("alice", created.ID); !errors.Is(err, ErrFinished) {
+		t.Errorf("cancelling a finished batch: %v", err)
+	}
+}
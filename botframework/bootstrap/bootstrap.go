@@ -0,0 +1,208 @@
+// Package bootstrap provisions an isolated Python virtual environment per inference engine,
+// installs the engine's pinned requirements into it and caches it for later runs.
+package bootstrap
+
+import (
+	"botframework/profiler"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// readyFile marks a venv whose requirements installed completely; it holds the install hash
+const readyFile = ".botframework-ready"
+
+// Bootstrapper creates venvs under CacheDir/<engine>, installing
+// RequirementsDir/<engine>.txt with the interpreter Python
+type Bootstrapper struct {
+	CacheDir        string
+	RequirementsDir string
+	// Python is the base interpreter that creates the venvs
+	Python string
+	// Env is added to pip's environment, e.g. CMAKE_ARGS for llama-cpp-python builds
+	Env    []string
+	Output io.Writer
+}
+
+func New(cacheDir, requirementsDir string) *Bootstrapper {
+	return &Bootstrapper{
+		CacheDir:        cacheDir,
+		RequirementsDir: requirementsDir,
+		Python:          DefaultPython(),
+		Output:          os.Stderr,
+	}
+}
+
+// DefaultCacheDir is ~/.cache/botframework/venvs, or the platform's equivalent
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "botframework", "venvs")
+}
+
+// DefaultRequirementsDir is worker/requirements in the source tree
+func DefaultRequirementsDir() string {
+	_, currentFile, _, ok := runtime.Caller(0)
+	if !ok {
+		return filepath.Join("..", "worker", "requirements")
+	}
+	return filepath.Join(filepath.Dir(currentFile), "..", "worker", "requirements")
+}
+
+// DefaultPython prefers python3.12, the version the worker is developed against
+func DefaultPython() string {
+	for _, name := range []string{"python3.12", "python3", "python"} {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return "python3"
+}
+
+// CMakeArgs returns the llama-cpp-python build flags for the host's accelerator
+func CMakeArgs(profile *profiler.HardwareProfile, engine profiler.Engine) string {
+	switch {
+	case engine == profiler.EngineLlamaCPPSYCL || profile.HasOneAPI:
+		return "-DGGML_SYCL=on -DCMAKE_C_COMPILER=icx -DCMAKE_CXX_COMPILER=icpx"
+	case profile.HasCuda:
+		return "-DLLAMA_CUBLAS=on"
+	case profile.HasROCm:
+		return "-DLLAMA_HIPBLAS=on"
+	case profile.HasMetal:
+		return "-DLLAMA_METAL=on"
+	}
+	return "-DLLAMA_BLAS=off"
+}
+
+// VenvPython returns the interpreter inside a venv
+func VenvPython(venv string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(venv, "Scripts", "python.exe")
+	}
+	return filepath.Join(venv, "bin", "python")
+}
+
+// Dir is the cached venv for engine
+func (b *Bootstrapper) Dir(engine profiler.Engine) string {
+	return filepath.Join(b.CacheDir, string(engine))
+}
+
+// Ready returns the interpreter of engine's venv when it is installed and up to date
+func (b *Bootstrapper) Ready(engine profiler.Engine) (string, bool) {
+	hash, err := b.hash(engine)
+	if err != nil {
+		return "", false
+	}
+	venv := b.Dir(engine)
+	marker, err := os.ReadFile(filepath.Join(venv, readyFile))
+	if err != nil || strings.TrimSpace(string(marker)) != hash {
+		return "", false
+	}
+	return VenvPython(venv), true
+}
+
+// Ensure returns the interpreter of engine's venv, creating the venv and installing its
+// requirements unless a cached copy matches them. A venv whose requirements, base
+// interpreter or build flags changed is rebuilt; a failed install is removed.
+func (b *Bootstrapper) Ensure(ctx context.Context, engine profiler.Engine) (string, error) {
+	if python, ok := b.Ready(engine); ok {
+		return python, nil
+	}
+	hash, err := b.hash(engine)
+	if err != nil {
+		return "", err
+	}
+
+	venv := b.Dir(engine)
+	if err := os.RemoveAll(venv); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(b.CacheDir, 0o755); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(b.Output, "📦 Creating %s venv at %s\n", engine, venv)
+	if err := b.run(ctx, b.Python, "-m", "venv", venv); err != nil {
+		os.RemoveAll(venv)
+		return "", fmt.Errorf("create venv: %w", err)
+	}
+	python := VenvPython(venv)
+	fmt.Fprintf(b.Output, "📥 Installing %s requirements\n", engine)
+	steps := [][]string{
+		{"-m", "pip", "install", "--upgrade", "pip"},
+		{"-m", "pip", "install", "-r", b.requirements(engine)},
+	}
+	for _, args := range steps {
+		if err := b.run(ctx, python, args...); err != nil {
+			os.RemoveAll(venv)
+			return "", fmt.Errorf("pip %s: %w", strings.Join(args[2:], " "), err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(venv, readyFile), []byte(hash+"\n"), 0o644); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(b.Output, "✅ %s venv ready\n", engine)
+	return python, nil
+}
+
+func (b *Bootstrapper) run(ctx context.Context, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = b.Output
+	cmd.Stderr = b.Output
+	cmd.Env = append(os.Environ(), b.Env...)
+	return cmd.Run()
+}
+
+func (b *Bootstrapper) requirements(engine profiler.Engine) string {
+	return filepath.Join(b.RequirementsDir, string(engine)+".txt")
+}
+
+// hash covers everything that decides what gets installed: the requirement files (with
+// those they include via -r), the base interpreter and the build environment
+func (b *Bootstrapper) hash(engine profiler.Engine) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "python=%s\n", b.Python)
+	for _, kv := range b.Env {
+		fmt.Fprintf(h, "env=%s\n", kv)
+	}
+	seen := map[string]bool{}
+	var add func(path string) error
+	add = func(path string) error {
+		if seen[path] {
+			return nil
+		}
+		seen[path] = true
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("no pinned requirements for this engine: %w", err)
+			}
+			return err
+		}
+		h.Write(data)
+		scanner := bufio.NewScanner(strings.NewReader(string(data)))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if included, ok := strings.CutPrefix(line, "-r "); ok {
+				if err := add(filepath.Join(filepath.Dir(path), strings.TrimSpace(included))); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := add(b.requirements(engine)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,118 @@
+package bootstrap
+
+import (
+	"botframework/profiler"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePython acts as both the base interpreter and the venv's: "-m venv DIR" copies itself
+// to DIR/bin/python, "-m pip ..." appends its arguments to the log
+func fakePython(t *testing.T, log string, failPip bool) string {
+	t.Helper()
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("needs /bin/sh")
+	}
+	pip := `echo "$@" >> ` + log
+	if failPip {
+		pip = "exit 1"
+	}
+	script := `#!/bin/sh
+if [ "$2" = venv ]; then mkdir -p "$3/bin" && cp "$0" "$3/bin/python"; exit 0; fi
+if [ "$2" = pip ]; then ` + pip + `; exit 0; fi
+exit 2
+`
+	path := filepath.Join(t.TempDir(), "python3")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newBootstrapper(t *testing.T, python string) *Bootstrapper {
+	t.Helper()
+	requirements := t.TempDir()
+	os.WriteFile(filepath.Join(requirements, "base.txt"), []byte("fastapi==0.115.6\n"), 0o644)
+	os.WriteFile(filepath.Join(requirements, "llama_cpp.txt"), []byte("-r base.txt\nllama-cpp-python==0.3.5\n"), 0o644)
+	b := New(t.TempDir(), requirements)
+	b.Python = python
+	b.Output = io.Discard
+	return b
+}
+
+func TestEnsureCreatesAndCachesVenv(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "pip.log")
+	b := newBootstrapper(t, fakePython(t, log, false))
+
+	python, err := b.Ensure(context.Background(), profiler.EngineLlamaCPP)
+	if err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	if want := VenvPython(filepath.Join(b.CacheDir, "llama_cpp")); python != want {
+		t.Errorf("python = %s, want %s", python, want)
+	}
+	calls, _ := os.ReadFile(log)
+	if !strings.Contains(string(calls), "install -r "+filepath.Join(b.RequirementsDir, "llama_cpp.txt")) {
+		t.Errorf("pip calls = %q", calls)
+	}
+
+	os.Remove(log)
+	if _, err := b.Ensure(context.Background(), profiler.EngineLlamaCPP); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(log); err == nil {
+		t.Error("a cached venv should not be reinstalled")
+	}
+
+	// changing an included requirement file invalidates the venv
+	os.WriteFile(filepath.Join(b.RequirementsDir, "base.txt"), []byte("fastapi==0.115.7\n"), 0o644)
+	if _, ok := b.Ready(profiler.EngineLlamaCPP); ok {
+		t.Error("venv should be stale after its requirements changed")
+	}
+	if _, err := b.Ensure(context.Background(), profiler.EngineLlamaCPP); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(log); err != nil {
+		t.Error("a stale venv should be reinstalled")
+	}
+}
+
+func TestEnsureRemovesFailedInstall(t *testing.T) {
+	b := newBootstrapper(t, fakePython(t, "/dev/null", true))
+	if _, err := b.Ensure(context.Background(), profiler.EngineLlamaCPP); err == nil {
+		t.Fatal("expected the failed pip install to be reported")
+	}
+	if _, err := os.Stat(b.Dir(profiler.EngineLlamaCPP)); !os.IsNotExist(err) {
+		t.Error("a failed venv should be removed")
+	}
+}
+
+func TestEnsureNeedsPinnedRequirements(t *testing.T) {
+	b := newBootstrapper(t, fakePython(t, "/dev/null", false))
+	if _, err := b.Ensure(context.Background(), profiler.EngineVLLM); err == nil || !strings.Contains(err.Error(), "no pinned requirements") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRepositoryPinsEveryEngine(t *testing.T) {
+	for _, engine := range profiler.Engines {
+		data, err := os.ReadFile(filepath.Join("..", "worker", "requirements", string(engine)+".txt"))
+		if err != nil {
+			t.Errorf("%s: %v", engine, err)
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+				continue
+			}
+			if !strings.Contains(line, "==") {
+				t.Errorf("%s: %q is not pinned", engine, line)
+			}
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"botframework/client"
+	"botframework/discovery"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const usage = `botctl manages a remote BotFramework manager.
+
+Usage:
+  botctl [--profile NAME | --url URL] [--token TOKEN] <command> [args]
+
+Commands:
+  status                         show traffic, workers and resource usage
+  models                         list served models
+  load MODEL [--path P] [--engine E]
+  unload MODEL
+  swap MODEL [--path P] [--engine E]
+  workers                        list workers with PID, uptime, restarts and memory
+  restart WORKER | stop WORKER
+  logs WORKER [--tail N] [--follow]
+  usage [--from DATE] [--to DATE] [--group-by FIELD]
+  keys rotate KEY_ID
+  profile list | add NAME --url URL [--token T] | use NAME | remove NAME
+  discover [--timeout D]         find managers advertised on the LAN via mDNS
+`
+
+func main() {
+	global := flag.NewFlagSet("botctl", flag.ExitOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	profileName := global.String("profile", "", "profile to use (defaults to the current profile)")
+	baseURL := global.String("url", "", "manager base URL (overrides the profile)")
+	token := global.String("token", "", "admin token (overrides the profile)")
+	configPath := global.String("config", client.DefaultProfilesPath(), "profiles file")
+	_ = global.Parse(os.Args[1:])
+
+	args := global.Args()
+	if len(args) == 0 {
+		global.Usage()
+		os.Exit(2)
+	}
+
+	profiles, err := client.LoadProfiles(*configPath)
+	if err != nil {
+		fatal(err)
+	}
+
+	if args[0] == "discover" {
+		if err := runDiscover(args[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	if args[0] == "profile" {
+		if err := runProfile(profiles, *configPath, args[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	profile, err := profiles.Resolve(*profileName)
+	if err != nil {
+		fatal(err)
+	}
+	if *baseURL != "" {
+		profile.URL = *baseURL
+	}
+	if *token != "" {
+		profile.Token = *token
+	}
+
+	c := client.New(profile.URL, profile.Token)
+	if err := run(c, args[0], args[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func run(c *client.Client, command string, args []string) error {
+	switch command {
+	case "status":
+		status, err := c.Status()
+		if err != nil {
+			return err
+		}
+		return printJSON(status)
+	case "models":
+		models, err := c.Models()
+		if err != nil {
+			return err
+		}
+		for _, m := range models.Data {
+			fmt.Println(m.ID)
+		}
+		return nil
+	case "load", "swap":
+		fs := flag.NewFlagSet(command, flag.ExitOnError)
+		path := fs.String("path", "", "model file path on the manager host")
+		engineName := fs.String("engine", "", "engine override")
+		model, err := parseWithName(fs, args, "model")
+		if err != nil {
+			return err
+		}
+		req := client.LoadRequest{Model: model, ModelPath: *path, Engine: *engineName}
+		var out json.RawMessage
+		if command == "load" {
+			out, err = c.LoadModel(req)
+		} else {
+			out, err = c.SwapModel(req)
+		}
+		if err != nil {
+			return err
+		}
+		return printJSON(out)
+	case "unload":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: botctl unload MODEL")
+		}
+		out, err := c.UnloadModel(args[0])
+		if err != nil {
+			return err
+		}
+		return printJSON(out)
+	case "workers":
+		workers, err := c.Workers()
+		if err != nil {
+			return err
+		}
+		return printJSON(workers)
+	case "restart", "stop":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: botctl %s WORKER", command)
+		}
+		worker, err := c.WorkerAction(args[0], command)
+		if err != nil {
+			return err
+		}
+		return printJSON(worker)
+	case "logs":
+		fs := flag.NewFlagSet("logs", flag.ExitOnError)
+		tail := fs.Int("tail", 100, "number of lines")
+		follow := fs.Bool("follow", false, "keep polling for new lines")
+		worker, err := parseWithName(fs, args, "worker")
+		if err != nil {
+			return err
+		}
+		return tailLogs(c, worker, *tail, *follow)
+	case "usage":
+		fs := flag.NewFlagSet("usage", flag.ExitOnError)
+		from := fs.String("from", "", "start date (YYYY-MM-DD)")
+		to := fs.String("to", "", "end date (YYYY-MM-DD)")
+		groupBy := fs.String("group-by", "", "aggregate by model or key")
+		_ = fs.Parse(args)
+		query := url.Values{}
+		for name, value := range map[string]string{"from": *from, "to": *to, "group_by": *groupBy} {
+			if value != "" {
+				query.Set(name, value)
+			}
+		}
+		out, err := c.Usage(query)
+		if err != nil {
+			return err
+		}
+		return printJSON(out)
+	case "keys":
+		if len(args) != 2 || args[0] != "rotate" {
+			return fmt.Errorf("usage: botctl keys rotate KEY_ID")
+		}
+		out, err := c.RotateKey(args[1])
+		if err != nil {
+			return err
+		}
+		return printJSON(out)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func runProfile(profiles *client.Profiles, path string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: botctl profile list|add|use|remove")
+	}
+
+	switch args[0] {
+	case "list":
+		for _, name := range profiles.Names() {
+			marker := " "
+			if name == profiles.Current {
+				marker = "*"
+			}
+			fmt.Printf("%s %-12s %s\n", marker, name, profiles.Profiles[name].URL)
+		}
+		return nil
+	case "add":
+		fs := flag.NewFlagSet("profile add", flag.ExitOnError)
+		profileURL := fs.String("url", "", "manager base URL")
+		token := fs.String("token", "", "admin token")
+		name, err := parseWithName(fs, args[1:], "name")
+		if err != nil {
+			return err
+		}
+		if *profileURL == "" {
+			return fmt.Errorf("--url is required")
+		}
+		profiles.Profiles[name] = client.Profile{URL: *profileURL, Token: *token}
+		if profiles.Current == "" {
+			profiles.Current = name
+		}
+	case "use":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: botctl profile use NAME")
+		}
+		if _, ok := profiles.Profiles[args[1]]; !ok {
+			return fmt.Errorf("unknown profile %q", args[1])
+		}
+		profiles.Current = args[1]
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: botctl profile remove NAME")
+		}
+		delete(profiles.Profiles, args[1])
+		if profiles.Current == args[1] {
+			profiles.Current = ""
+		}
+	default:
+		return fmt.Errorf("unknown profile command %q", args[0])
+	}
+
+	return profiles.Save(path)
+}
+
+func runDiscover(args []string) error {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 2*time.Second, "how long to wait for answers")
+	_ = fs.Parse(args)
+
+	services, err := discovery.Browse(context.Background(), *timeout)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		fmt.Println("no managers found")
+		return nil
+	}
+	for _, service := range services {
+		fmt.Printf("%-32s %-28s %s\n", service.Instance, service.URL(), strings.Join(service.Models, ","))
+	}
+	return nil
+}
+
+func tailLogs(c *client.Client, worker string, tail int, follow bool) error {
+	var previous []string
+	for {
+		lines, err := c.Logs(worker, tail)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines[overlap(previous, lines):] {
+			fmt.Println(line)
+		}
+		previous = lines
+
+		if !follow {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// overlap returns how many leading lines of next were already printed at the end of prev
+func overlap(prev, next []string) int {
+	for k := min(len(prev), len(next)); k > 0; k-- {
+		match := true
+		for i := 0; i < k; i++ {
+			if prev[len(prev)-k+i] != next[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return k
+		}
+	}
+	return 0
+}
+
+// parseWithName accepts a leading positional argument followed by flags
+func parseWithName(fs *flag.FlagSet, args []string, what string) (string, error) {
+	if len(args) == 0 || args[0] == "" || args[0][0] == '-' {
+		return "", fmt.Errorf("missing %s argument", what)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return "", err
+	}
+	return args[0], nil
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "botctl: %v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,315 @@
+// Package cache answers exact repeats of inference requests from earlier responses, which
+// makes test suites and demos that replay the same prompts fast and deterministic.
+package cache
+
+import (
+	"botframework/chat"
+	"bytes"
+	"cmp"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header reports hit, miss or bypass on every cacheable response. Requests may send
+// "bypass" to skip the cache entirely, "refresh" to skip the lookup but store the new
+// response, or "no-store" to use a cached response without storing a new one. Cache-Control
+// no-cache and no-store map to refresh and no-store.
+const Header = "X-BotFramework-Cache"
+
+// Paths are the routes whose responses are cached
+var Paths = []string{"/v1/chat/completions", "/v1/completions", "/v1/embeddings"}
+
+// ignored fields do not change a response, so requests differing only in them share an entry
+var ignored = []string{"user", "metadata", "store"}
+
+// Stats counts cache activity since startup
+type Stats struct {
+	Entries   int    `json:"entries"`
+	Bytes     int64  `json:"bytes"`
+	MaxBytes  int64  `json:"max_bytes"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Bypasses  uint64 `json:"bypasses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// Entry is a stored response
+type Entry struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+	Created     int64  `json:"created"`
+}
+
+// Cache is a size-bounded LRU of successful responses keyed by a hash of the normalized
+// request: its route, the session it belongs to and its JSON body with keys sorted and
+// ignored fields removed. With Dir set, entries are also written there and survive restarts.
+type Cache struct {
+	// MaxBytes bounds the stored response bodies; the least recently used are evicted first
+	MaxBytes int64
+	// MaxEntries bounds the entry count; zero means unlimited
+	MaxEntries int
+	// TTL expires entries; zero keeps them until evicted
+	TTL time.Duration
+	Dir string
+
+	mu      sync.Mutex
+	order   *list.List // of *Entry, most recently used first
+	entries map[string]*list.Element
+	bytes   int64
+	stats   Stats
+	now     func() time.Time
+}
+
+// New returns a cache holding up to maxBytes of responses, loading any entries left in dir
+func New(maxBytes int64, dir string) (*Cache, error) {
+	c := &Cache{MaxBytes: maxBytes, Dir: dir, order: list.New(), entries: make(map[string]*list.Element), now: time.Now}
+	if dir == "" {
+		return c, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var loaded []*Entry
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.Key == "" {
+			slog.Warn("dropping unreadable cache entry", "path", path, "err", err)
+			os.Remove(path)
+			continue
+		}
+		loaded = append(loaded, &entry)
+	}
+	// the newest entries are the most recently used
+	slices.SortFunc(loaded, func(a, b *Entry) int { return cmp.Compare(a.Created, b.Created) })
+	for _, entry := range loaded {
+		c.add(entry)
+	}
+	return c, nil
+}
+
+// Key hashes a request to path with body, or returns false when it cannot be cached
+func Key(path, session string, body []byte) (string, bool) {
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", false
+	}
+	for _, field := range ignored {
+		delete(fields, field)
+	}
+	// encoding/json writes map keys sorted, so equal requests encode equally
+	normalized, err := json.Marshal(fields)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.New()
+	io.WriteString(sum, path+"\x00"+session+"\x00")
+	sum.Write(normalized)
+	return hex.EncodeToString(sum.Sum(nil)), true
+}
+
+// Get returns the fresh entry for key
+func (c *Cache) Get(key string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*Entry)
+	if c.TTL > 0 && c.now().Sub(time.Unix(entry.Created, 0)) > c.TTL {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry, true
+}
+
+// Put stores a response, evicting older entries to make room. Responses larger than
+// MaxBytes are not stored.
+func (c *Cache) Put(key, contentType string, body []byte) {
+	if int64(len(body)) > c.MaxBytes {
+		return
+	}
+	entry := &Entry{Key: key, ContentType: contentType, Body: body, Created: c.now().Unix()}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.add(entry)
+	if c.Dir == "" {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = os.WriteFile(c.path(key), data, 0o600)
+	}
+	if err != nil {
+		slog.Warn("cache entry not persisted", "err", err)
+	}
+}
+
+// add inserts entry as the most recently used and evicts down to the limits; callers hold c.mu
+func (c *Cache) add(entry *Entry) {
+	c.entries[entry.Key] = c.order.PushFront(entry)
+	c.bytes += int64(len(entry.Body))
+	for c.order.Len() > 1 && (c.bytes > c.MaxBytes || (c.MaxEntries > 0 && c.order.Len() > c.MaxEntries)) {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops an entry, from disk too; callers hold c.mu
+func (c *Cache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*Entry)
+	delete(c.entries, entry.Key)
+	c.bytes -= int64(len(entry.Body))
+	if c.Dir != "" {
+		os.Remove(c.path(entry.Key))
+	}
+}
+
+func (c *Cache) path(key string) string { return filepath.Join(c.Dir, key+".json") }
+
+// Purge drops every entry
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.order.Len() > 0 {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries, stats.Bytes, stats.MaxBytes = c.order.Len(), c.bytes, c.MaxBytes
+	return stats
+}
+
+func (c *Cache) count(counter *uint64) {
+	c.mu.Lock()
+	*counter++
+	c.mu.Unlock()
+}
+
+// mode reads how a request wants the cache used
+func mode(r *http.Request) string {
+	if mode := strings.ToLower(strings.TrimSpace(r.Header.Get(Header))); mode != "" {
+		return mode
+	}
+	control := strings.ToLower(r.Header.Get("Cache-Control"))
+	switch {
+	case strings.Contains(control, "no-store"):
+		return "no-store"
+	case strings.Contains(control, "no-cache"):
+		return "refresh"
+	}
+	return ""
+}
+
+// Middleware answers repeated POSTs to Paths from the cache and stores successful new
+// responses, streamed ones included
+func (c *Cache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !slices.Contains(Paths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		mode := mode(r)
+		if mode == "bypass" {
+			c.count(&c.stats.Bypasses)
+			w.Header().Set(Header, "bypass")
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		key, ok := Key(r.URL.Path, r.Header.Get(chat.SessionHeader), body)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if mode != "refresh" {
+			if entry, ok := c.Get(key); ok {
+				c.count(&c.stats.Hits)
+				w.Header().Set("Content-Type", entry.ContentType)
+				w.Header().Set(Header, "hit")
+				w.WriteHeader(http.StatusOK)
+				w.Write(entry.Body)
+				return
+			}
+		}
+		c.count(&c.stats.Misses)
+		w.Header().Set(Header, "miss")
+		if mode == "no-store" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		recorder := &recorder{ResponseWriter: w, status: http.StatusOK, limit: c.MaxBytes}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == http.StatusOK && !recorder.overflow && r.Context().Err() == nil {
+			c.Put(key, recorder.Header().Get("Content-Type"), recorder.body.Bytes())
+		}
+	})
+}
+
+// recorder passes a response through while keeping a copy of up to limit bytes
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	limit    int64
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *recorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.overflow && int64(r.body.Len()+len(b)) <= r.limit {
+		r.body.Write(b)
+	} else {
+		r.overflow = true
+		r.body.Reset()
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
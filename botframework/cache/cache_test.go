@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyNormalizesRequests(t *testing.T) {
+	a, _ := Key("/v1/chat/completions", "", []byte(`{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":0}`))
+	b, _ := Key("/v1/chat/completions", "", []byte(`{ "temperature": 0, "user": "alice", "model": "m", "messages": [{"content": "hi", "role": "user"}] }`))
+	if a != b {
+		t.Error("field order, whitespace and ignored fields should not change the key")
+	}
+	for _, other := range []struct{ path, session, body string }{
+		{"/v1/chat/completions", "", `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":1}`},
+		{"/v1/completions", "", `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":0}`},
+		{"/v1/chat/completions", "s1", `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":0}`},
+	} {
+		if key, _ := Key(other.path, other.session, []byte(other.body)); key == a {
+			t.Errorf("%+v should have its own key", other)
+		}
+	}
+	if _, ok := Key("/v1/chat/completions", "", []byte("not json")); ok {
+		t.Error("unparsable bodies are not cacheable")
+	}
+}
+
+func TestMiddlewareServesRepeatsFromCache(t *testing.T) {
+	c, err := New(1<<20, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	send := func(body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"model":"m","messages":[]}`
+	if rec := send(body); rec.Header().Get(Header) != "miss" || rec.Body.String() != body {
+		t.Fatalf("first request: %s %q", rec.Header().Get(Header), rec.Body.String())
+	}
+	rec := send(body)
+	if rec.Header().Get(Header) != "hit" || rec.Body.String() != body || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("repeat: %s %q", rec.Header().Get(Header), rec.Body.String())
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("backend called %d times, want 1", calls.Load())
+	}
+	send(body, Header, "bypass")
+	send(body, "Cache-Control", "no-cache")
+	if calls.Load() != 3 {
+		t.Errorf("bypass and no-cache should reach the backend, %d calls", calls.Load())
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Bypasses != 1 || stats.Entries != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestCacheEvictsAndExpires(t *testing.T) {
+	c, err := New(10, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("a", "", []byte("aaaa"))
+	c.Put("b", "", []byte("bbbb"))
+	c.Get("a") // a is now the most recently used
+	c.Put("c", "", []byte("cccc"))
+	if _, ok := c.Get("b"); ok {
+		t.Error("the least recently used entry should be evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a was used recently and should stay")
+	}
+	c.Put("huge", "", make([]byte, 11))
+	if _, ok := c.Get("huge"); ok {
+		t.Error("entries over MaxBytes are not stored")
+	}
+
+	now := time.Now()
+	c.TTL = time.Minute
+	c.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if _, ok := c.Get("a"); ok {
+		t.Error("expired entries should not be served")
+	}
+}
+
+func TestCachePersistsToDir(t *testing.T) {
+	dir := t.TempDir()
+	c, err := New(1<<20, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Put("k", "text/event-stream", []byte("data: [DONE]\n\n"))
+
+	reopened, err := New(1<<20, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := reopened.Get("k")
+	if !ok || entry.ContentType != "text/event-stream" || string(entry.Body) != "data: [DONE]\n\n" {
+		t.Fatalf("want the persisted entry, got %+v %v", entry, ok)
+	}
+	reopened.Purge()
+	if again, _ := New(1<<20, dir); again.Stats().Entries != 0 {
+		t.Error("purged entries should be removed from disk")
+	}
+}
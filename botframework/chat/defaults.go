@@ -0,0 +1,197 @@
+package chat
+
+import (
+	"botframework/auth"
+	"botframework/engine"
+	"botframework/openai"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Settings that API keys can be allowed to override
+const (
+	OverrideTemperature  = "temperature"
+	OverrideMaxTokens    = "max_tokens"
+	OverrideStop         = "stop"
+	OverrideMaxTokensCap = "max_tokens_cap"
+	OverrideTimeout      = "timeout"
+	OverrideSystemPrefix = "system_prompt_prefix"
+)
+
+// ModelDefaults are applied to requests for one model. Temperature, MaxTokens and Stop fill
+// in values the client omitted, or replace the client's values when listed in Locked.
+// MaxTokensCap, Timeout and SystemPrefix always apply unless the API key may override them.
+type ModelDefaults struct {
+	Temperature  *float64 `json:"temperature,omitempty"`
+	MaxTokens    int      `json:"max_tokens,omitempty"`
+	MaxTokensCap int      `json:"max_tokens_cap,omitempty"`
+	Stop         []string `json:"stop,omitempty"`
+	Timeout      string   `json:"timeout,omitempty"`
+	SystemPrefix string   `json:"system_prompt_prefix,omitempty"`
+	Locked       []string `json:"locked,omitempty"`
+
+	timeout time.Duration
+}
+
+// KeyPermissions lists the settings an API key may override
+type KeyPermissions struct {
+	Allow []string `json:"allow"`
+}
+
+// Defaults holds per-model defaults keyed by model name or a prefix pattern ending in "*"
+// ("*" alone matches every model), and override permissions keyed by API key ID, as named
+// in the BOTFRAMEWORK_API_KEYS file
+type Defaults struct {
+	Models map[string]*ModelDefaults  `json:"models"`
+	Keys   map[string]*KeyPermissions `json:"keys"`
+}
+
+// LoadDefaults reads a Defaults JSON file
+func LoadDefaults(path string) (*Defaults, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var d Defaults
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return &d, d.validate()
+}
+
+func (d *Defaults) validate() error {
+	for name, m := range d.Models {
+		if m.Timeout == "" {
+			continue
+		}
+		timeout, err := time.ParseDuration(m.Timeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("model %q: invalid timeout %q", name, m.Timeout)
+		}
+		m.timeout = timeout
+	}
+	return nil
+}
+
+// For returns the defaults for model: an exact entry, else the longest matching prefix pattern
+func (d *Defaults) For(model string) *ModelDefaults {
+	if m, ok := d.Models[model]; ok {
+		return m
+	}
+	var best *ModelDefaults
+	longest := -1
+	for pattern, m := range d.Models {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if ok && strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			best, longest = m, len(prefix)
+		}
+	}
+	return best
+}
+
+// allowed reports whether the API key r was authenticated with may override setting
+func (d *Defaults) allowed(r *http.Request, setting string) bool {
+	key, ok := auth.KeyFrom(r.Context())
+	if !ok {
+		return false
+	}
+	perms, ok := d.Keys[key.ID]
+	if !ok {
+		return false
+	}
+	for _, allowed := range perms.Allow {
+		if allowed == setting || allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply rewrites req with the model's defaults for the caller of r and reports whether
+// anything changed
+func (d *Defaults) Apply(r *http.Request, m *ModelDefaults, req *Request) bool {
+	present := make(map[string]bool)
+	for _, field := range []string{"temperature", "max_tokens", "max_completion_tokens", "stop"} {
+		var raw json.RawMessage
+		present[field] = req.Get(field, &raw) && string(raw) != "null"
+	}
+	locked := func(setting string) bool {
+		for _, l := range m.Locked {
+			if l == setting {
+				return !d.allowed(r, setting)
+			}
+		}
+		return false
+	}
+
+	changed := false
+	if m.Temperature != nil && (!present["temperature"] || locked(OverrideTemperature)) {
+		_ = req.Set("temperature", *m.Temperature)
+		changed = true
+	}
+	if m.MaxTokens > 0 && (!present["max_tokens"] && !present["max_completion_tokens"] || locked(OverrideMaxTokens)) {
+		req.Delete("max_completion_tokens")
+		_ = req.Set("max_tokens", m.MaxTokens)
+		changed = true
+	}
+	if len(m.Stop) > 0 && (!present["stop"] || locked(OverrideStop)) {
+		_ = req.Set("stop", m.Stop)
+		changed = true
+	}
+	if m.MaxTokensCap > 0 && !d.allowed(r, OverrideMaxTokensCap) {
+		for _, field := range []string{"max_tokens", "max_completion_tokens"} {
+			var n int
+			if req.Get(field, &n) && n > m.MaxTokensCap {
+				_ = req.Set(field, m.MaxTokensCap)
+				changed = true
+			}
+		}
+		if !present["max_tokens"] && !present["max_completion_tokens"] && m.MaxTokens == 0 {
+			_ = req.Set("max_tokens", m.MaxTokensCap)
+			changed = true
+		}
+	}
+	if m.SystemPrefix != "" && !d.allowed(r, OverrideSystemPrefix) {
+		text, isText := "", false
+		if len(req.Messages) > 0 && req.Messages[0].Role == "system" {
+			text, isText = req.Messages[0].Content.(string)
+		}
+		if isText {
+			req.Messages[0].Content = m.SystemPrefix + "\n\n" + text
+		} else {
+			req.Messages = append([]Message{{Role: "system", Content: m.SystemPrefix}}, req.Messages...)
+		}
+		changed = true
+	}
+	return changed
+}
+
+// Middleware applies the requested model's defaults and hands its timeout to the engine,
+// which starts the clock once the worker is awake. API keys with the "timeout" permission
+// may ask for longer with X-Request-Timeout.
+func (d *Defaults) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		model, _ := engine.RequestedModel(r)
+		m := d.For(model)
+		req, err := Read(r)
+
+		var timeout time.Duration
+		if m != nil {
+			timeout = m.timeout
+		}
+		if lifted := d.allowed(r, OverrideTimeout); timeout > 0 || lifted {
+			r = engine.WithTimeoutLimit(r, timeout, lifted)
+		}
+		if err == nil && m != nil && d.Apply(r, m, req) {
+			if err := req.Write(r); err != nil {
+				openai.WriteError(w, http.StatusInternalServerError, "server_error", "", err.Error())
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,149 @@
+package chat
+
+import (
+	"botframework/auth"
+	"botframework/engine"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const defaultsConfig = `{
+  "models": {
+    "coder": {"temperature": 0.2, "max_tokens": 512, "max_tokens_cap": 2048, "stop": ["</code>"], "timeout": "30s",
+              "system_prompt_prefix": "Answer with code only.", "locked": ["temperature"]},
+    "llama-*": {"max_tokens_cap": 100},
+    "*": {"timeout": "2m"}
+  },
+  "keys": {"admin": {"allow": ["temperature", "max_tokens_cap", "system_prompt_prefix", "timeout"]}}
+}`
+
+func loadTestDefaults(t *testing.T) *Defaults {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "defaults.json")
+	if err := os.WriteFile(path, []byte(defaultsConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := LoadDefaults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// forward runs body through the defaults middleware and returns what the backend received
+func forward(t *testing.T, d *Defaults, body, keyID string) (*Request, time.Duration) {
+	t.Helper()
+	var got *Request
+	var timeout time.Duration
+	handler := d.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		if got, err = Read(r); err != nil {
+			t.Fatal(err)
+		}
+		timeout = engine.TimeoutFor(r, 0)
+	}))
+	r := httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(body))
+	if keyID != "" {
+		r = r.WithContext(auth.WithKey(r.Context(), &auth.Key{ID: keyID}))
+	}
+	r.Header.Set(engine.RequestTimeoutHeader, "10m")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	return got, timeout
+}
+
+func TestDefaultsFillOmittedAndEnforceLimits(t *testing.T) {
+	d := loadTestDefaults(t)
+	req, timeout := forward(t, d, `{"model":"coder","temperature":1.5,"messages":[{"role":"user","content":"sort a list"}]}`, "")
+
+	var temperature float64
+	var maxTokens int
+	var stop []string
+	req.Get("temperature", &temperature)
+	req.Get("max_tokens", &maxTokens)
+	req.Get("stop", &stop)
+	if temperature != 0.2 {
+		t.Errorf("locked temperature should be replaced, got %v", temperature)
+	}
+	if maxTokens != 512 || len(stop) != 1 {
+		t.Errorf("want defaults filled in, got max_tokens=%d stop=%v", maxTokens, stop)
+	}
+	if req.Messages[0].Role != "system" || req.Messages[0].Content != "Answer with code only." {
+		t.Errorf("want system prefix inserted, got %+v", req.Messages[0])
+	}
+	if timeout != 30*time.Second {
+		t.Errorf("want 30s timeout (header ignored without permission), got %v", timeout)
+	}
+}
+
+func TestDefaultsCapAndPatterns(t *testing.T) {
+	d := loadTestDefaults(t)
+	req, timeout := forward(t, d, `{"model":"llama-3-8b","max_tokens":5000,"messages":[{"role":"user","content":"hi"}]}`, "")
+	var maxTokens int
+	req.Get("max_tokens", &maxTokens)
+	if maxTokens != 100 {
+		t.Errorf("want max_tokens capped at 100, got %d", maxTokens)
+	}
+	if timeout != 10*time.Minute {
+		t.Errorf("prefix pattern has no timeout and should win over *, leaving the client's, got %v", timeout)
+	}
+
+	_, timeout = forward(t, d, `{"model":"phi-3","messages":[{"role":"user","content":"hi"}]}`, "")
+	if timeout != 2*time.Minute {
+		t.Errorf("want catch-all timeout, got %v", timeout)
+	}
+}
+
+func TestDefaultsKeyOverrides(t *testing.T) {
+	d := loadTestDefaults(t)
+	body := `{"model":"coder","temperature":1.5,"max_tokens":4000,"messages":[{"role":"system","content":"Be terse."},{"role":"user","content":"hi"}]}`
+	req, timeout := forward(t, d, body, "admin")
+
+	var temperature float64
+	var maxTokens int
+	req.Get("temperature", &temperature)
+	req.Get("max_tokens", &maxTokens)
+	if temperature != 1.5 || maxTokens != 4000 {
+		t.Errorf("admin key should keep its values, got temperature=%v max_tokens=%d", temperature, maxTokens)
+	}
+	if req.Messages[0].Content != "Be terse." {
+		t.Errorf("admin key should be exempt from the system prefix, got %q", req.Messages[0].Content)
+	}
+	if timeout != 10*time.Minute {
+		t.Errorf("admin key should choose its timeout, got %v", timeout)
+	}
+
+	req, _ = forward(t, d, body, "user")
+	if req.Messages[0].Content != "Answer with code only.\n\nBe terse." {
+		t.Errorf("want prefix merged into the existing system prompt, got %q", req.Messages[0].Content)
+	}
+}
+
+func TestDefaultsIgnoreUnauthenticatedTokens(t *testing.T) {
+	d := loadTestDefaults(t)
+	var got *Request
+	handler := d.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = Read(r)
+	}))
+	// a token that merely equals a permitted key ID grants nothing
+	r := httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(`{"model":"coder","temperature":1.5,"messages":[]}`))
+	r.Header.Set("Authorization", "Bearer admin")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	var temperature float64
+	got.Get("temperature", &temperature)
+	if temperature != 0.2 {
+		t.Errorf("temperature = %v, want the locked default", temperature)
+	}
+}
+
+func TestLoadDefaultsRejectsBadTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "defaults.json")
+	os.WriteFile(path, []byte(`{"models":{"x":{"timeout":"soon"}}}`), 0o644)
+	if _, err := LoadDefaults(path); err == nil {
+		t.Error("want error for invalid timeout")
+	}
+}
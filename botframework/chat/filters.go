@@ -0,0 +1,113 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultStopTokens are special tokens that some backends leak into generated text when
+// their chat template and stop configuration disagree
+var DefaultStopTokens = []string{
+	"<|eot_id|>", "<|end_of_text|>", "<|im_end|>", "<|im_start|>", "<|endoftext|>",
+	"<|end|>", "<end_of_turn>", "<start_of_turn>", "</s>", "<|return|>",
+}
+
+// StripTokens removes tokens from generated text, holding back partial tokens split across chunks
+func StripTokens(tokens ...string) TransformFactory {
+	return func() Transform { return &tokenStripper{tokens: tokens} }
+}
+
+type tokenStripper struct {
+	tokens []string
+	held   string
+}
+
+func (s *tokenStripper) Push(delta string) string {
+	text := s.held + delta
+	for _, token := range s.tokens {
+		text = strings.ReplaceAll(text, token, "")
+	}
+	keep := 0
+	for _, token := range s.tokens {
+		for n := min(len(token)-1, len(text)); n > keep; n-- {
+			if strings.HasSuffix(text, token[:n]) {
+				keep = n
+				break
+			}
+		}
+	}
+	s.held = text[len(text)-keep:]
+	return text[:len(text)-keep]
+}
+
+func (s *tokenStripper) Flush() string {
+	text := s.held
+	s.held = ""
+	return text
+}
+
+// RedactionPatterns are named patterns for common sensitive values
+var RedactionPatterns = map[string]*regexp.Regexp{
+	"email":  regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"phone":  regexp.MustCompile(`\+?\d{1,3}[ .-]?\(?\d{3}\)?[ .-]?\d{3}[ .-]?\d{4}\b`),
+	"card":   regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	"ipv4":   regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+	"secret": regexp.MustCompile(`\b(?:sk|pk|ghp|gho|xox[bap])[-_][A-Za-z0-9_-]{16,}`),
+}
+
+// Redaction is the text that replaces redacted matches
+const Redaction = "[REDACTED]"
+
+// RedactWindow is how much trailing text Redact holds back so that matches split across
+// chunks are still caught; matches longer than this may slip through
+const RedactWindow = 64
+
+// Redact replaces every match of patterns with Redaction
+func Redact(patterns ...*regexp.Regexp) TransformFactory {
+	return func() Transform { return &redactor{patterns: patterns, window: RedactWindow} }
+}
+
+type redactor struct {
+	patterns []*regexp.Regexp
+	window   int
+	held     string
+}
+
+func (r *redactor) Push(delta string) string {
+	r.held += delta
+	if len(r.held) <= r.window {
+		return ""
+	}
+	cut := len(r.held) - r.window
+	for !utf8.RuneStart(r.held[cut]) {
+		cut--
+	}
+	// never emit part of a match that may continue into the held text
+	for moved := true; moved; {
+		moved = false
+		for _, pattern := range r.patterns {
+			for _, loc := range pattern.FindAllStringIndex(r.held, -1) {
+				if loc[0] < cut && loc[1] >= cut {
+					cut, moved = loc[0], true
+				}
+			}
+		}
+	}
+	text := r.redact(r.held[:cut])
+	r.held = r.held[cut:]
+	return text
+}
+
+func (r *redactor) Flush() string {
+	text := r.redact(r.held)
+	r.held = ""
+	return text
+}
+
+func (r *redactor) redact(text string) string {
+	for _, pattern := range r.patterns {
+		text = pattern.ReplaceAllString(text, Redaction)
+	}
+	return text
+}
@@ -0,0 +1,192 @@
+package chat
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers for session memory: SessionHeader names the conversation, MemoryHeader reports how
+// many turns were replaced by the running summary
+const (
+	SessionHeader = "X-BotFramework-Session"
+	MemoryHeader  = "X-BotFramework-Memory-Summarized"
+)
+
+// Memory keeps a running summary per conversation. Once enough turns accumulate beyond the
+// most recent ones it compresses them in the background, folding the previous summary in;
+// later requests whose history still starts with the summarized turns get those turns
+// replaced by the summary.
+type Memory struct {
+	Summarizer Summarizer
+	// KeepRecent turns are always sent verbatim
+	KeepRecent int
+	// CompressEvery is how many unsummarized turns beyond KeepRecent trigger a compression
+	CompressEvery int
+	// IdleTTL drops sessions that have not been used for this long
+	IdleTTL time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]*memorySession
+	now      func() time.Time
+}
+
+type memorySession struct {
+	summary     string
+	covered     int    // leading turns the summary replaces
+	fingerprint string // hash of those turns, so edited histories are not summarized wrongly
+	compressing bool
+	lastUsed    time.Time
+}
+
+func NewMemory(summarizer Summarizer) *Memory {
+	return &Memory{
+		Summarizer:    summarizer,
+		KeepRecent:    6,
+		CompressEvery: 8,
+		IdleTTL:       24 * time.Hour,
+		sessions:      make(map[string]*memorySession),
+		now:           time.Now,
+	}
+}
+
+// Apply rewrites req with the session's summary and schedules compression when due.
+// It returns the number of turns replaced by the summary.
+func (m *Memory) Apply(sessionID string, req *Request) int {
+	system, turns := splitSystem(req.Messages)
+
+	m.mu.Lock()
+	m.evictIdle()
+	s, ok := m.sessions[sessionID]
+	if !ok {
+		s = &memorySession{}
+		m.sessions[sessionID] = s
+	}
+	s.lastUsed = m.now()
+
+	covered := 0
+	if s.covered > 0 && s.covered <= len(turns) && fingerprint(turns[:s.covered]) == s.fingerprint {
+		covered = s.covered
+	} else if s.covered > 0 {
+		// the client rewrote history; start over
+		s.summary, s.covered, s.fingerprint = "", 0, ""
+	}
+	summary := s.summary
+
+	upTo := compressionBoundary(turns, covered, len(turns)-m.KeepRecent)
+	due := !s.compressing && upTo-covered >= m.CompressEvery
+	if due {
+		s.compressing = true
+	}
+	m.mu.Unlock()
+
+	if due {
+		pending := append([]Message(nil), turns[covered:upTo]...)
+		go m.compress(sessionID, req.Model(), summary, pending, upTo, fingerprint(turns[:upTo]))
+	}
+
+	if covered == 0 {
+		return 0
+	}
+	messages := append([]Message(nil), system...)
+	messages = append(messages, Message{Role: "system", Content: "Summary of the conversation so far: " + summary})
+	req.Messages = append(messages, turns[covered:]...)
+	return covered
+}
+
+func (m *Memory) compress(sessionID, model, previous string, turns []Message, covered int, digest string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	input := turns
+	if previous != "" {
+		input = append([]Message{{Role: "system", Content: "Earlier summary: " + previous}}, turns...)
+	}
+	summary, err := m.Summarizer.Summarize(ctx, model, input)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok {
+		return
+	}
+	s.compressing = false
+	if err != nil {
+		slog.Warn("session summary failed", "session", sessionID, "err", err)
+		return
+	}
+	s.summary, s.covered, s.fingerprint = summary, covered, digest
+	slog.Info("session summarized", "session", sessionID, "turns", covered)
+}
+
+// compressionBoundary moves upTo back so a tool call is never separated from its results
+func compressionBoundary(turns []Message, covered, upTo int) int {
+	for upTo > covered && upTo < len(turns) && turns[upTo].Role == "tool" {
+		upTo--
+	}
+	return max(upTo, covered)
+}
+
+// evictIdle drops stale sessions; callers hold m.mu
+func (m *Memory) evictIdle() {
+	cutoff := m.now().Add(-m.IdleTTL)
+	for id, s := range m.sessions {
+		if s.lastUsed.Before(cutoff) && !s.compressing {
+			delete(m.sessions, id)
+		}
+	}
+}
+
+func splitSystem(messages []Message) ([]Message, []Message) {
+	i := 0
+	for i < len(messages) && messages[i].Role == "system" {
+		i++
+	}
+	return messages[:i], messages[i:]
+}
+
+func fingerprint(messages []Message) string {
+	data, _ := json.Marshal(messages)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Middleware applies session memory to chat completions identified by SessionHeader or a
+// "session_id" body field
+func (m *Memory) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := Read(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sessionID := r.Header.Get(SessionHeader)
+		var bodySession string
+		if req.Get("session_id", &bodySession) {
+			req.Delete("session_id")
+			if sessionID == "" {
+				sessionID = bodySession
+			}
+		}
+		if sessionID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if replaced := m.Apply(sessionID, req); replaced > 0 {
+			w.Header().Set(MemoryHeader, strconv.Itoa(replaced))
+		}
+		if err := req.Write(r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
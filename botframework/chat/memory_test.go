@@ -0,0 +1,134 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSummarizer returns a summary naming how many messages it saw
+type recordingSummarizer struct {
+	mu    sync.Mutex
+	calls [][]Message
+}
+
+func (s *recordingSummarizer) Summarize(ctx context.Context, model string, messages []Message) (string, error) {
+	s.mu.Lock()
+	s.calls = append(s.calls, messages)
+	n := len(s.calls)
+	s.mu.Unlock()
+	return fmt.Sprintf("summary %d of %d messages", n, len(messages)), nil
+}
+
+func turns(n int) []Message {
+	messages := []Message{{Role: "system", Content: "be kind"}}
+	for i := 0; i < n; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		messages = append(messages, Message{Role: role, Content: fmt.Sprintf("turn %d", i)})
+	}
+	return messages
+}
+
+// waitSummary blocks until the session's background compression has finished
+func waitSummary(t *testing.T, m *Memory, sessionID string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		m.mu.Lock()
+		busy := m.sessions[sessionID].compressing
+		m.mu.Unlock()
+		if !busy {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("summary was not generated")
+}
+
+func TestMemoryCompressesAndReplacesOlderTurns(t *testing.T) {
+	summarizer := &recordingSummarizer{}
+	memory := NewMemory(summarizer)
+	memory.KeepRecent, memory.CompressEvery = 2, 4
+
+	// 5 turns: 3 beyond KeepRecent, not yet due
+	req := &Request{Messages: turns(5)}
+	if replaced := memory.Apply("s1", req); replaced != 0 || len(summarizer.calls) != 0 {
+		t.Fatalf("compression should not be due yet")
+	}
+
+	// 6 turns: the first 4 get compressed in the background; this request is untouched
+	req = &Request{Messages: turns(6)}
+	if replaced := memory.Apply("s1", req); replaced != 0 {
+		t.Fatalf("summary should not be used before it exists")
+	}
+	waitSummary(t, memory, "s1")
+
+	req = &Request{Messages: turns(7)}
+	if replaced := memory.Apply("s1", req); replaced != 4 {
+		t.Fatalf("expected 4 turns replaced, got %d", replaced)
+	}
+	if len(req.Messages) != 5 || req.Messages[0].Text() != "be kind" || !strings.Contains(req.Messages[1].Text(), "summary 1 of 4") || req.Messages[2].Text() != "turn 4" {
+		t.Errorf("unexpected messages: %+v", req.Messages)
+	}
+
+	// enough new turns fold the previous summary into the next one
+	req = &Request{Messages: turns(10)}
+	memory.Apply("s1", req)
+	waitSummary(t, memory, "s1")
+	second := summarizer.calls[1]
+	if !strings.Contains(second[0].Text(), "summary 1") || second[1].Text() != "turn 4" {
+		t.Errorf("expected rolling summary input, got %+v", second)
+	}
+}
+
+func TestMemoryResetsWhenHistoryChanges(t *testing.T) {
+	summarizer := &recordingSummarizer{}
+	memory := NewMemory(summarizer)
+	memory.KeepRecent, memory.CompressEvery = 1, 2
+
+	memory.Apply("s1", &Request{Messages: turns(3)})
+	waitSummary(t, memory, "s1")
+
+	edited := turns(4)
+	edited[1].Content = "a different first turn"
+	req := &Request{Messages: edited}
+	if replaced := memory.Apply("s1", req); replaced != 0 || len(req.Messages) != 5 {
+		t.Errorf("edited history must not be replaced by a stale summary: %d %+v", replaced, req.Messages)
+	}
+}
+
+func TestCompressionBoundaryKeepsToolResultsWithCall(t *testing.T) {
+	messages := []Message{
+		{Role: "user"}, {Role: "assistant", ToolCalls: []byte(`[]`)}, {Role: "tool"}, {Role: "tool"}, {Role: "user"},
+	}
+	if got := compressionBoundary(messages, 0, 3); got != 1 {
+		t.Errorf("expected the tool call to stay with its results, got %d", got)
+	}
+}
+
+func TestMemoryMiddlewareStripsSessionField(t *testing.T) {
+	memory := NewMemory(&recordingSummarizer{})
+	var forwarded string
+	handler := memory.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		forwarded = string(body)
+	}))
+
+	body := `{"model":"m","session_id":"abc","messages":[{"role":"user","content":"hi"}]}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(body)))
+	if strings.Contains(forwarded, "session_id") {
+		t.Errorf("session_id should not reach the backend: %s", forwarded)
+	}
+	if _, ok := memory.sessions["abc"]; !ok {
+		t.Error("expected session to be tracked")
+	}
+}
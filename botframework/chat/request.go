@@ -0,0 +1,139 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CompletionsPath is the OpenAI chat completions route that chat middleware rewrites
+const CompletionsPath = "/v1/chat/completions"
+
+// maxBody bounds how much of a request body chat middleware will buffer
+const maxBody = 32 << 20
+
+// Message is an OpenAI chat message. Content is a string or a list of content parts.
+type Message struct {
+	Role       string          `json:"role"`
+	Content    any             `json:"content"`
+	Name       string          `json:"name,omitempty"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// Text returns the message's text, joining text parts of multi-part content
+func (m Message) Text() string {
+	switch content := m.Content.(type) {
+	case string:
+		return content
+	case []any:
+		var parts []string
+		for _, part := range content {
+			if p, ok := part.(map[string]any); ok && p["type"] == "text" {
+				if text, ok := p["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// Request is a decoded chat completion body. Fields other than messages are kept
+// verbatim so rewriting a request never drops parameters the backend understands.
+type Request struct {
+	Messages []Message
+	fields   map[string]json.RawMessage
+}
+
+// ErrNotChat is returned by Read for requests that are not JSON chat completions
+var ErrNotChat = errors.New("not a chat completion request")
+
+// Read decodes a chat completion request body, leaving r.Body readable again
+func Read(r *http.Request) (*Request, error) {
+	if r.Method != http.MethodPost || r.URL.Path != CompletionsPath || r.Body == nil {
+		return nil, ErrNotChat
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("read request body: %w", err)
+	}
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) > maxBody {
+		return nil, errors.New("request body too large")
+	}
+
+	req := &Request{}
+	if err := json.Unmarshal(body, &req.fields); err != nil {
+		return nil, ErrNotChat
+	}
+	if raw, ok := req.fields["messages"]; ok {
+		if err := json.Unmarshal(raw, &req.Messages); err != nil {
+			return nil, fmt.Errorf("invalid messages: %w", err)
+		}
+	}
+	return req, nil
+}
+
+// Get decodes field name into v and reports whether it was present
+func (req *Request) Get(name string, v any) bool {
+	raw, ok := req.fields[name]
+	if !ok {
+		return false
+	}
+	return json.Unmarshal(raw, v) == nil
+}
+
+// Set replaces field name with v
+func (req *Request) Set(name string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req.fields[name] = raw
+	return nil
+}
+
+// Delete removes field name, e.g. a gateway-only extension the backend would reject
+func (req *Request) Delete(name string) {
+	delete(req.fields, name)
+}
+
+// Model returns the requested model name
+func (req *Request) Model() string {
+	var model string
+	req.Get("model", &model)
+	return model
+}
+
+// Write re-encodes the request into r's body
+func (req *Request) Write(r *http.Request) error {
+	if err := req.Set("messages", req.Messages); err != nil {
+		return err
+	}
+	body, err := json.Marshal(req.fields)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// LastUserText returns the text of the most recent user message
+func (req *Request) LastUserText() string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role == "user" {
+			return req.Messages[i].Text()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package chat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadWritePreservesUnknownFields(t *testing.T) {
+	body := `{"model":"llama","temperature":0.2,"messages":[{"role":"system","content":"be brief"},{"role":"user","content":[{"type":"text","text":"hi"},{"type":"image_url","image_url":{"url":"x"}}]}],"rag":{"collection":"docs"}}`
+	r := httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(body))
+
+	req, err := Read(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Model() != "llama" || req.LastUserText() != "hi" {
+		t.Errorf("unexpected model %q / text %q", req.Model(), req.LastUserText())
+	}
+
+	req.Delete("rag")
+	req.Messages = req.Messages[1:]
+	if err := req.Write(r); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := io.ReadAll(r.Body)
+	if strings.Contains(string(out), "rag") || strings.Contains(string(out), "be brief") || !strings.Contains(string(out), `"temperature":0.2`) {
+		t.Errorf("unexpected body: %s", out)
+	}
+	if r.ContentLength != int64(len(out)) {
+		t.Errorf("content length %d does not match body %d", r.ContentLength, len(out))
+	}
+}
+
+func TestReadIgnoresOtherRoutes(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{}`))
+	if _, err := Read(r); err != ErrNotChat {
+		t.Errorf("expected ErrNotChat, got %v", err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(`not json`))
+	if _, err := Read(r); err != ErrNotChat {
+		t.Errorf("expected ErrNotChat, got %v", err)
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != "not json" {
+		t.Errorf("body not restored: %q", body)
+	}
+}
@@ -0,0 +1,345 @@
+package chat
+
+import (
+	"botframework/engine"
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Transform rewrites generated text as it streams. Push receives each content delta and
+// returns the text that is safe to emit now; text held back (for example the start of
+// what may become a stop token) is released by a later Push or by Flush at the end of
+// the choice.
+type Transform interface {
+	Push(delta string) string
+	Flush() string
+}
+
+// TransformFactory creates the state for one choice of one response
+type TransformFactory func() Transform
+
+// Transformer applies output transforms to completion responses. Streaming responses are
+// rewritten one SSE event at a time, so only a partial event is ever buffered.
+type Transformer struct {
+	Factories []TransformFactory
+}
+
+func NewTransformer(factories ...TransformFactory) *Transformer {
+	return &Transformer{Factories: factories}
+}
+
+type transformsKey struct{}
+
+// AddTransform applies factory to the response of r in addition to the Transformer's own
+// transforms. Middleware inside the Transformer uses it for per-request hooks, such as
+// citations for the documents retrieved for this request. It reports false when no
+// Transformer is handling r.
+func AddTransform(r *http.Request, factory TransformFactory) bool {
+	extra, ok := r.Context().Value(transformsKey{}).(*[]TransformFactory)
+	if ok {
+		*extra = append(*extra, factory)
+	}
+	return ok
+}
+
+// TextCompletionsPath is the legacy OpenAI completions route
+const TextCompletionsPath = engine.TextCompletionsPath
+
+// Middleware rewrites the content of successful chat and text completion responses
+func (t *Transformer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != CompletionsPath && r.URL.Path != TextCompletionsPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		extra := &[]TransformFactory{}
+		r = r.WithContext(context.WithValue(r.Context(), transformsKey{}, extra))
+		tw := &transformWriter{ResponseWriter: w, factories: func() []TransformFactory {
+			return append(append([]TransformFactory{}, t.Factories...), *extra...)
+		}}
+		next.ServeHTTP(tw, r)
+		tw.finish()
+	})
+}
+
+// chain runs transforms in order, each consuming the previous one's output
+type chain []Transform
+
+func (c chain) Push(delta string) string {
+	for _, t := range c {
+		delta = t.Push(delta)
+	}
+	return delta
+}
+
+func (c chain) Flush() string {
+	var carry string
+	for _, t := range c {
+		carry = t.Push(carry) + t.Flush()
+	}
+	return carry
+}
+
+const (
+	modeUndecided = iota
+	modePassthrough
+	modeSSE
+	modeJSON
+)
+
+// transformWriter rewrites choice content in SSE events or a buffered JSON body
+type transformWriter struct {
+	http.ResponseWriter
+	factories func() []TransformFactory
+	resolved  []TransformFactory
+	mode      int
+	pending   []byte
+	body      bytes.Buffer
+	choices   map[int]chain
+	flushed   map[int]bool
+	template  map[string]any
+}
+
+func (w *transformWriter) WriteHeader(code int) {
+	if w.mode == modeUndecided {
+		w.resolved = w.factories()
+		contentType := w.Header().Get("Content-Type")
+		switch {
+		case len(w.resolved) == 0 || code < 200 || code > 299:
+			w.mode = modePassthrough
+		case strings.HasPrefix(contentType, "text/event-stream"):
+			w.mode = modeSSE
+		case strings.HasPrefix(contentType, "application/json"):
+			w.mode = modeJSON
+		default:
+			w.mode = modePassthrough
+		}
+		if w.mode != modePassthrough {
+			w.Header().Del("Content-Length")
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *transformWriter) Write(b []byte) (int, error) {
+	if w.mode == modeUndecided {
+		w.WriteHeader(http.StatusOK)
+	}
+	switch w.mode {
+	case modeSSE:
+		w.pending = append(w.pending, b...)
+		for {
+			end := bytes.Index(w.pending, []byte("\n\n"))
+			if end < 0 {
+				break
+			}
+			event := string(w.pending[:end])
+			w.pending = w.pending[end+2:]
+			if _, err := w.ResponseWriter.Write([]byte(w.event(event))); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	case modeJSON:
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *transformWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *transformWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish emits whatever the transforms still hold once the backend is done
+func (w *transformWriter) finish() {
+	switch w.mode {
+	case modeSSE:
+		if len(bytes.TrimSpace(w.pending)) > 0 {
+			w.ResponseWriter.Write([]byte(w.event(string(w.pending))))
+		}
+		w.ResponseWriter.Write([]byte(w.flushAll()))
+	case modeJSON:
+		w.ResponseWriter.Write(w.rewriteJSON(w.body.Bytes()))
+	}
+}
+
+func (w *transformWriter) chain(index int) chain {
+	if w.choices == nil {
+		w.choices = make(map[int]chain)
+		w.flushed = make(map[int]bool)
+	}
+	c, ok := w.choices[index]
+	if !ok {
+		for _, factory := range w.resolved {
+			c = append(c, factory())
+		}
+		w.choices[index] = c
+	}
+	return c
+}
+
+// event rewrites one SSE event and returns it with its terminating blank line
+func (w *transformWriter) event(event string) string {
+	lines := strings.Split(event, "\n")
+	for i, line := range lines {
+		payload, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		payload = strings.TrimSpace(payload)
+		if payload == "[DONE]" {
+			return w.flushAll() + event + "\n\n"
+		}
+		chunk, err := decodeJSON([]byte(payload))
+		if err != nil {
+			return event + "\n\n"
+		}
+		w.rewriteChunk(chunk)
+		lines[i] = "data: " + string(encodeJSON(chunk))
+	}
+	return strings.Join(lines, "\n") + "\n\n"
+}
+
+// rewriteChunk transforms each choice's delta, flushing choices that finish in this chunk
+func (w *transformWriter) rewriteChunk(chunk map[string]any) {
+	choices, _ := chunk["choices"].([]any)
+	for _, raw := range choices {
+		choice, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		index := choiceIndex(choice)
+		if w.flushed[index] {
+			continue
+		}
+		c := w.chain(index)
+		text := c.Push(choiceText(choice, "delta"))
+		if reason, _ := choice["finish_reason"].(string); reason != "" {
+			text += c.Flush()
+			w.flushed[index] = true
+		}
+		setChoiceText(choice, "delta", text)
+	}
+	if w.template == nil {
+		w.template = make(map[string]any)
+		for key, value := range chunk {
+			if key != "choices" && key != "usage" {
+				w.template[key] = value
+			}
+		}
+	}
+}
+
+// flushAll returns an event carrying text still held for choices that never finished
+func (w *transformWriter) flushAll() string {
+	var choices []any
+	for _, index := range slices.Sorted(maps.Keys(w.choices)) {
+		if w.flushed[index] {
+			continue
+		}
+		w.flushed[index] = true
+		if text := w.choices[index].Flush(); text != "" {
+			choice := map[string]any{"index": index, "finish_reason": nil}
+			if w.template["object"] == "text_completion" {
+				choice["text"] = ""
+			}
+			setChoiceText(choice, "delta", text)
+			choices = append(choices, choice)
+		}
+	}
+	if len(choices) == 0 {
+		return ""
+	}
+	chunk := map[string]any{"choices": choices}
+	for key, value := range w.template {
+		chunk[key] = value
+	}
+	return "data: " + string(encodeJSON(chunk)) + "\n\n"
+}
+
+// rewriteJSON transforms the complete content of a non-streaming response
+func (w *transformWriter) rewriteJSON(body []byte) []byte {
+	response, err := decodeJSON(body)
+	if err != nil {
+		return body
+	}
+	choices, ok := response["choices"].([]any)
+	if !ok {
+		return body
+	}
+	for _, raw := range choices {
+		choice, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		c := w.chain(choiceIndex(choice))
+		setChoiceText(choice, "message", c.Push(choiceText(choice, "message"))+c.Flush())
+	}
+	return encodeJSON(response)
+}
+
+func choiceIndex(choice map[string]any) int {
+	if n, ok := choice["index"].(json.Number); ok {
+		index, _ := strconv.Atoi(n.String())
+		return index
+	}
+	return 0
+}
+
+// choiceText returns the content under field ("delta" or "message") for chat completions,
+// or the text of a legacy completion choice
+func choiceText(choice map[string]any, field string) string {
+	if text, ok := choice["text"].(string); ok {
+		return text
+	}
+	if m, ok := choice[field].(map[string]any); ok {
+		text, _ := m["content"].(string)
+		return text
+	}
+	return ""
+}
+
+func setChoiceText(choice map[string]any, field, text string) {
+	if _, ok := choice["text"]; ok {
+		choice["text"] = text
+		return
+	}
+	m, ok := choice[field].(map[string]any)
+	if !ok {
+		m = make(map[string]any)
+		choice[field] = m
+	}
+	if _, ok := m["content"].(string); ok || text != "" {
+		m["content"] = text
+	}
+}
+
+func decodeJSON(data []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v map[string]any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func encodeJSON(v any) []byte {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(v)
+	return bytes.TrimRight(b.Bytes(), "\n")
+}
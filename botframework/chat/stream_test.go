@@ -0,0 +1,123 @@
+package chat
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// sseBackend streams each piece as a chat completion delta, split into separate writes
+func sseBackend(pieces ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, piece := range pieces {
+			event := `data: {"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":` + quote(piece) + `},"finish_reason":null}]}` + "\n\n"
+			// split events mid-way to exercise partial event buffering
+			io.WriteString(w, event[:len(event)/2])
+			io.WriteString(w, event[len(event)/2:])
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, `data: {"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`+"\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+}
+
+func quote(s string) string {
+	return string(encodeJSON(s))
+}
+
+// streamedText concatenates the delta content of every event in an SSE body
+func streamedText(t *testing.T, body string) string {
+	t.Helper()
+	var text strings.Builder
+	for _, event := range strings.Split(body, "\n\n") {
+		payload, ok := strings.CutPrefix(event, "data: ")
+		if !ok || payload == "[DONE]" {
+			continue
+		}
+		chunk, err := decodeJSON([]byte(payload))
+		if err != nil {
+			t.Fatalf("invalid event %q: %v", event, err)
+		}
+		for _, choice := range chunk["choices"].([]any) {
+			text.WriteString(choiceText(choice.(map[string]any), "delta"))
+		}
+	}
+	return text.String()
+}
+
+func TestTransformerStripsSplitStopTokens(t *testing.T) {
+	handler := NewTransformer(StripTokens(DefaultStopTokens...)).Middleware(sseBackend("Hello", " world<|e", "ot_id|>", "<"))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CompletionsPath, nil))
+
+	if got := streamedText(t, rec.Body.String()); got != "Hello world<" {
+		t.Errorf("got %q", got)
+	}
+	if !strings.HasSuffix(rec.Body.String(), "data: [DONE]\n\n") {
+		t.Errorf("stream should still end with [DONE]: %q", rec.Body.String())
+	}
+}
+
+func TestTransformerRedactsAcrossChunks(t *testing.T) {
+	handler := NewTransformer(Redact(RedactionPatterns["email"])).Middleware(sseBackend("Write to jane.d", "oe@example.", "com for details. ", strings.Repeat("x", 100)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CompletionsPath, nil))
+
+	got := streamedText(t, rec.Body.String())
+	if strings.Contains(rec.Body.String(), "example") || !strings.HasPrefix(got, "Write to [REDACTED] for details.") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRedactorEmitsIncrementally(t *testing.T) {
+	r := Redact(regexp.MustCompile(`\d{4}`))()
+	var out string
+	for i := 0; i < 20; i++ {
+		out += r.Push("word ")
+	}
+	if out == "" {
+		t.Fatal("redactor should release text older than its window")
+	}
+	if out+r.Flush() != strings.Repeat("word ", 20) {
+		t.Errorf("text was altered: %q", out)
+	}
+}
+
+func TestAddTransformAppliesToJSONResponses(t *testing.T) {
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddTransform(r, func() Transform { return &suffix{" (checked)"} })
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "999")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"4<|im_end|>"},"finish_reason":"stop"}],"usage":{"total_tokens":12}}`)
+	})
+	handler := NewTransformer(StripTokens(DefaultStopTokens...)).Middleware(backend)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CompletionsPath, nil))
+
+	if !strings.Contains(rec.Body.String(), `"content":"4 (checked)"`) || !strings.Contains(rec.Body.String(), `"total_tokens":12`) {
+		t.Errorf("got %s", rec.Body.String())
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("stale Content-Length should be dropped")
+	}
+}
+
+func TestTransformerPassesErrorsThrough(t *testing.T) {
+	handler := NewTransformer(StripTokens("</s>")).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad </s>", http.StatusBadRequest)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CompletionsPath, nil))
+	if rec.Body.String() != "bad </s>\n" {
+		t.Errorf("got %q", rec.Body.String())
+	}
+}
+
+type suffix struct{ text string }
+
+func (s *suffix) Push(delta string) string { return delta }
+func (s *suffix) Flush() string            { return s.text }
@@ -0,0 +1,83 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const summaryPrompt = "Summarize the conversation below in a few sentences. Keep names, facts, decisions and open questions; omit pleasantries. Reply with the summary only."
+
+// CompletionSummarizer asks a chat model — the active one, or Model when set — to summarize turns
+type CompletionSummarizer struct {
+	URL       string
+	Model     string
+	MaxTokens int
+	Client    *http.Client
+}
+
+func NewCompletionSummarizer(url, model string) *CompletionSummarizer {
+	return &CompletionSummarizer{URL: url, Model: model, MaxTokens: 256, Client: &http.Client{Timeout: 60 * time.Second}}
+}
+
+func (s *CompletionSummarizer) Summarize(ctx context.Context, model string, messages []Message) (string, error) {
+	if s.Model != "" {
+		model = s.Model
+	}
+
+	var transcript strings.Builder
+	for _, m := range messages {
+		if text := m.Text(); text != "" {
+			fmt.Fprintf(&transcript, "%s: %s\n", m.Role, text)
+		}
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"model": model,
+		"messages": []Message{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: transcript.String()},
+		},
+		"max_tokens":  s.MaxTokens,
+		"temperature": 0,
+		"stream":      false,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("summary request failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return "", err
+	}
+	if len(completion.Choices) == 0 || strings.TrimSpace(completion.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("summary request returned no content")
+	}
+	return strings.TrimSpace(completion.Choices[0].Message.Content), nil
+}
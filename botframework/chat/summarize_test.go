@@ -0,0 +1,48 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompletionSummarizerUsesOverrideModel(t *testing.T) {
+	var got struct {
+		Model    string    `json:"model"`
+		Messages []Message `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"  User asked about cats.  "}}]}`))
+	}))
+	defer server.Close()
+
+	summarizer := NewCompletionSummarizer(server.URL, "phi-3-mini")
+	summary, err := summarizer.Summarize(context.Background(), "llama", []Message{
+		{Role: "user", Content: "tell me about cats"},
+		{Role: "assistant", Content: "cats are great"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "User asked about cats." || got.Model != "phi-3-mini" {
+		t.Errorf("unexpected summary %q from model %q", summary, got.Model)
+	}
+	if !strings.Contains(got.Messages[1].Text(), "assistant: cats are great") {
+		t.Errorf("transcript missing turns: %q", got.Messages[1].Text())
+	}
+}
+
+func TestCompletionSummarizerReportsBackendErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if _, err := NewCompletionSummarizer(server.URL, "").Summarize(context.Background(), "m", nil); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,295 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// UsageReport is the token usage a request was counted at. Middleware outside UsageFiller
+// reads it for streams that carry no usage because the client did not ask for it.
+type UsageReport struct {
+	PromptTokens     int
+	CompletionTokens int
+	// Counted is set once the UsageFiller counted the response
+	Counted bool
+}
+
+type usageReportKey struct{}
+
+// WithUsageReport returns r carrying a report the UsageFiller handling it fills in
+func WithUsageReport(r *http.Request) (*http.Request, *UsageReport) {
+	report := &UsageReport{}
+	return r.WithContext(context.WithValue(r.Context(), usageReportKey{}, report)), report
+}
+
+// UsageFiller adds the usage object to chat and text completion responses whose backend
+// left it out, counting the prompt the backend received and the text it generated. It
+// sits next to the backend, so prompts are counted after every rewrite. Streams gain a
+// final usage chunk when the client asked for one with stream_options.include_usage.
+type UsageFiller struct {
+	Counter TokenCounter
+}
+
+func NewUsageFiller(counter TokenCounter) *UsageFiller {
+	return &UsageFiller{Counter: counter}
+}
+
+// usagePrompt is what the filler reads from a completion request
+type usagePrompt struct {
+	Model         string          `json:"model"`
+	Messages      []Message       `json:"messages"`
+	Prompt        json.RawMessage `json:"prompt"`
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+func (u *UsageFiller) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || (r.URL.Path != CompletionsPath && r.URL.Path != TextCompletionsPath) || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var prompt usagePrompt
+		if err != nil || len(body) > maxBody || json.Unmarshal(body, &prompt) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		report, _ := r.Context().Value(usageReportKey{}).(*UsageReport)
+		uw := &usageFillWriter{ResponseWriter: w, filler: u, prompt: prompt, report: report, choices: make(map[int]*strings.Builder)}
+		next.ServeHTTP(uw, r)
+		uw.finish()
+	})
+}
+
+// promptTokens counts the messages of a chat request or the prompts of a text completion
+func (u *UsageFiller) promptTokens(p usagePrompt) int {
+	if p.Messages != nil {
+		return u.Counter.CountTokens(p.Model, p.Messages)
+	}
+	var prompts []string
+	var single string
+	if json.Unmarshal(p.Prompt, &single) == nil {
+		prompts = []string{single}
+	} else {
+		_ = json.Unmarshal(p.Prompt, &prompts)
+	}
+	total := 0
+	for _, prompt := range prompts {
+		total += countText(u.Counter, p.Model, prompt)
+	}
+	return total
+}
+
+// usageFillWriter tracks the text of a streamed response, or buffers a JSON one, to count
+// what the backend generated
+type usageFillWriter struct {
+	http.ResponseWriter
+	filler  *UsageFiller
+	prompt  usagePrompt
+	report  *UsageReport
+	mode    int
+	status  int
+	pending []byte
+	body    bytes.Buffer
+	// choices holds the text generated per choice, reported says the stream carried usage
+	choices  map[int]*strings.Builder
+	reported bool
+	counted  bool
+	template map[string]any
+}
+
+func (w *usageFillWriter) WriteHeader(code int) {
+	if w.mode == modeUndecided {
+		w.status = code
+		contentType := w.Header().Get("Content-Type")
+		switch {
+		case code < 200 || code > 299:
+			w.mode = modePassthrough
+		case strings.HasPrefix(contentType, "text/event-stream"):
+			w.mode = modeSSE
+		case strings.HasPrefix(contentType, "application/json"):
+			// the header is sent with the rewritten body
+			w.mode = modeJSON
+			return
+		default:
+			w.mode = modePassthrough
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *usageFillWriter) Write(b []byte) (int, error) {
+	if w.mode == modeUndecided {
+		w.WriteHeader(http.StatusOK)
+	}
+	switch w.mode {
+	case modeSSE:
+		w.pending = append(w.pending, b...)
+		for {
+			end := bytes.Index(w.pending, []byte("\n\n"))
+			if end < 0 {
+				break
+			}
+			event := string(w.pending[:end])
+			w.pending = w.pending[end+2:]
+			if _, err := w.ResponseWriter.Write([]byte(w.event(event))); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	case modeJSON:
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *usageFillWriter) Flush() {
+	if w.mode == modeJSON {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *usageFillWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish writes the buffered JSON response with its usage, or what is left of a stream
+func (w *usageFillWriter) finish() {
+	switch w.mode {
+	case modeSSE:
+		if len(bytes.TrimSpace(w.pending)) > 0 {
+			w.ResponseWriter.Write([]byte(w.event(string(w.pending))))
+		}
+		if !w.reported && !w.counted {
+			w.count()
+		}
+	case modeJSON:
+		body := w.fillJSON(w.body.Bytes())
+		w.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.status)
+		w.ResponseWriter.Write(body)
+	}
+}
+
+// event records the text of one SSE event and, at [DONE], emits the usage chunk the
+// client asked for when the backend sent none
+func (w *usageFillWriter) event(event string) string {
+	for _, line := range strings.Split(event, "\n") {
+		payload, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		payload = strings.TrimSpace(payload)
+		if payload == "[DONE]" {
+			if w.reported {
+				break
+			}
+			prompt, completion := w.count()
+			if !w.prompt.StreamOptions.IncludeUsage {
+				break
+			}
+			chunk := map[string]any{"choices": []any{}, "usage": usageObject(prompt, completion)}
+			for key, value := range w.template {
+				chunk[key] = value
+			}
+			return "data: " + string(encodeJSON(chunk)) + "\n\n" + event + "\n\n"
+		}
+		chunk, err := decodeJSON([]byte(payload))
+		if err != nil {
+			continue
+		}
+		if usage, ok := chunk["usage"].(map[string]any); ok && len(usage) > 0 {
+			w.reported = true
+		}
+		choices, _ := chunk["choices"].([]any)
+		for _, raw := range choices {
+			if choice, ok := raw.(map[string]any); ok {
+				w.record(choice, "delta")
+			}
+		}
+		if w.template == nil {
+			w.template = make(map[string]any)
+			for key, value := range chunk {
+				if key != "choices" && key != "usage" {
+					w.template[key] = value
+				}
+			}
+		}
+	}
+	return event + "\n\n"
+}
+
+// record appends the generated text of a choice: its content, reasoning and tool call
+// arguments
+func (w *usageFillWriter) record(choice map[string]any, field string) {
+	index := choiceIndex(choice)
+	text, ok := w.choices[index]
+	if !ok {
+		text = &strings.Builder{}
+		w.choices[index] = text
+	}
+	text.WriteString(choiceText(choice, field))
+	m, _ := choice[field].(map[string]any)
+	if reasoning, ok := m["reasoning_content"].(string); ok {
+		text.WriteString(reasoning)
+	}
+	calls, _ := m["tool_calls"].([]any)
+	for _, raw := range calls {
+		call, _ := raw.(map[string]any)
+		function, _ := call["function"].(map[string]any)
+		name, _ := function["name"].(string)
+		arguments, _ := function["arguments"].(string)
+		text.WriteString(name + arguments)
+	}
+}
+
+// count counts the prompt and the recorded completions and reports them
+func (w *usageFillWriter) count() (int, int) {
+	prompt, completion := w.filler.promptTokens(w.prompt), 0
+	for _, text := range w.choices {
+		completion += countText(w.filler.Counter, w.prompt.Model, text.String())
+	}
+	w.counted = true
+	if w.report != nil {
+		w.report.PromptTokens, w.report.CompletionTokens, w.report.Counted = prompt, completion, true
+	}
+	return prompt, completion
+}
+
+// fillJSON adds usage to a complete response that lacks it
+func (w *usageFillWriter) fillJSON(body []byte) []byte {
+	response, err := decodeJSON(body)
+	if err != nil {
+		return body
+	}
+	choices, ok := response["choices"].([]any)
+	if !ok {
+		return body
+	}
+	if usage, ok := response["usage"].(map[string]any); ok && len(usage) > 0 {
+		return body
+	}
+	for _, raw := range choices {
+		if choice, ok := raw.(map[string]any); ok {
+			w.record(choice, "message")
+		}
+	}
+	response["usage"] = usageObject(w.count())
+	return encodeJSON(response)
+}
+
+func usageObject(prompt, completion int) map[string]any {
+	return map[string]any{"prompt_tokens": prompt, "completion_tokens": completion, "total_tokens": prompt + completion}
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fillerBackend answers chat completions without usage, streaming when asked to
+var fillerBackend = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if strings.Contains(string(body), `"stream":true`) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"id\":\"c1\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"four \"}}]}\n\n")
+		io.WriteString(w, "data: {\"id\":\"c1\",\"model\":\"m\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"tokens of text\"}}]}\n\ndata: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", "1")
+	io.WriteString(w, `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"four tokens of text"}}]}`)
+})
+
+func TestUsageFillerCompletesJSONResponses(t *testing.T) {
+	handler := NewUsageFiller(wordCounter{}).Middleware(fillerBackend)
+	rec := httptest.NewRecorder()
+	body := `{"model":"m","messages":[{"role":"user","content":"three word prompt"}]}`
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(body)))
+
+	var response struct {
+		Usage map[string]int `json:"usage"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	// wordCounter has no CountText, so completions are estimated at 4 bytes a token
+	if response.Usage["prompt_tokens"] != 3 || response.Usage["completion_tokens"] != 5 || response.Usage["total_tokens"] != 8 {
+		t.Errorf("usage = %v", response.Usage)
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("the backend's Content-Length was kept for a rewritten body")
+	}
+}
+
+func TestUsageFillerStreams(t *testing.T) {
+	handler := NewUsageFiller(TokenizedCounter{Tokenizer: func(string) TextTokenizer { return runeTokenizer{} }}).Middleware(fillerBackend)
+
+	rec := httptest.NewRecorder()
+	body := `{"model":"m","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(body)))
+	events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+	if len(events) != 4 || events[3] != "data: [DONE]" {
+		t.Fatalf("events = %q", events)
+	}
+	// the prompt is priming, overhead, "user" and "hi"; the completion is 19 runes
+	if !strings.Contains(events[2], `"choices":[]`) || !strings.Contains(events[2], `"id":"c1"`) ||
+		!strings.Contains(events[2], `"usage":{"completion_tokens":19,"prompt_tokens":13,"total_tokens":32}`) {
+		t.Errorf("usage chunk = %s", events[2])
+	}
+
+	// without include_usage the stream is left alone, and the count goes to the report
+	req, report := WithUsageReport(httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(`{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), "usage") {
+		t.Errorf("usage was sent without being asked for: %s", rec.Body.String())
+	}
+	if !report.Counted || report.PromptTokens != 13 || report.CompletionTokens != 19 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestUsageFillerKeepsBackendUsage(t *testing.T) {
+	const response = `{"choices":[{"message":{"content":"x"}}],"usage":{"prompt_tokens":7,"completion_tokens":1,"total_tokens":8}}`
+	handler := NewUsageFiller(EstimateCounter{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, response)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, TextCompletionsPath, strings.NewReader(`{"model":"m","prompt":"hello"}`)))
+	if rec.Body.String() != response {
+		t.Errorf("body = %s", rec.Body.String())
+	}
+}
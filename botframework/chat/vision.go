@@ -0,0 +1,317 @@
+package chat
+
+import (
+	"botframework/engine"
+	"botframework/openai"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// VisionRoutedHeader names the vision model a request was rerouted to
+const VisionRoutedHeader = "X-BotFramework-Vision-Routed"
+
+// Vision input errors, reported to clients as OpenAI-style invalid_request_error codes
+var (
+	ErrNoVisionModel    = errors.New("request contains images but no vision-capable model is loaded")
+	ErrUnsupportedImage = errors.New("unsupported image format (use JPEG, PNG or GIF)")
+	ErrImageTooLarge    = errors.New("image exceeds the size limit")
+	ErrInvalidImage     = errors.New("image could not be read")
+)
+
+// visionNames are substrings of common vision-language model names
+var visionNames = []string{"llava", "vision", "-vl", "vl-", "pixtral", "moondream", "idefics", "minicpm-v", "internvl", "paligemma", "cogvlm"}
+
+// IsVisionModelName guesses from its name whether a model accepts images
+func IsVisionModelName(model string) bool {
+	model = strings.ToLower(model)
+	for _, name := range visionNames {
+		if strings.Contains(model, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Vision prepares image content parts for the backend: remote images are fetched and inlined
+// as data URLs, oversized images are downscaled, and images are re-encoded to Format when set.
+// Requests with images for a text-only model go to a loaded vision model instead.
+type Vision struct {
+	// IsVisionModel reports whether a model accepts images
+	IsVisionModel func(model string) bool
+	// Models lists the loaded models considered for rerouting
+	Models func() []string
+	// MaxBytes bounds each encoded image, MaxDimension its longest side in pixels
+	MaxBytes     int64
+	MaxDimension int
+	// Format is "jpeg" or "png" to convert every image, or empty to keep the source format
+	Format      string
+	FetchRemote bool
+	Client      *http.Client
+}
+
+func NewVision(models func() []string) *Vision {
+	return &Vision{
+		IsVisionModel: IsVisionModelName,
+		Models:        models,
+		MaxBytes:      20 << 20,
+		MaxDimension:  2048,
+		FetchRemote:   true,
+		Client:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// imageParts returns the image_url content parts of req
+func imageParts(req *Request) []map[string]any {
+	var parts []map[string]any
+	for _, msg := range req.Messages {
+		content, ok := msg.Content.([]any)
+		if !ok {
+			continue
+		}
+		for _, part := range content {
+			if p, ok := part.(map[string]any); ok && p["type"] == "image_url" {
+				parts = append(parts, p)
+			}
+		}
+	}
+	return parts
+}
+
+// Route returns the model that should serve a request with images
+func (v *Vision) Route(model string) (string, error) {
+	if model != "" && v.IsVisionModel(model) {
+		return model, nil
+	}
+	if v.Models != nil {
+		for _, candidate := range v.Models() {
+			if v.IsVisionModel(candidate) {
+				return candidate, nil
+			}
+		}
+	}
+	if model == "" {
+		// The default worker may well be a VLM; let it answer
+		return "", nil
+	}
+	return "", ErrNoVisionModel
+}
+
+// Prepare rewrites every image part of req into a validated, size-limited data URL
+func (v *Vision) Prepare(ctx context.Context, req *Request) (int, error) {
+	parts := imageParts(req)
+	for i, part := range parts {
+		var url string
+		detail := ""
+		switch ref := part["image_url"].(type) {
+		case string:
+			url = ref
+		case map[string]any:
+			url, _ = ref["url"].(string)
+			detail, _ = ref["detail"].(string)
+		}
+
+		data, err := v.load(ctx, url)
+		if err != nil {
+			return i, fmt.Errorf("image %d: %w", i+1, err)
+		}
+		encoded, mime, err := v.normalize(data)
+		if err != nil {
+			return i, fmt.Errorf("image %d: %w", i+1, err)
+		}
+
+		ref := map[string]any{"url": "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(encoded)}
+		if detail != "" {
+			ref["detail"] = detail
+		}
+		part["image_url"] = ref
+	}
+	return len(parts), nil
+}
+
+// load returns the raw bytes of a data: or http(s) image URL
+func (v *Vision) load(ctx context.Context, url string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		meta, payload, ok := strings.Cut(rest, ",")
+		if !ok || !strings.HasSuffix(meta, ";base64") {
+			return nil, fmt.Errorf("%w: data URL must be base64 encoded", ErrInvalidImage)
+		}
+		if int64(base64.StdEncoding.DecodedLen(len(payload))) > v.MaxBytes+3 {
+			return nil, ErrImageTooLarge
+		}
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+		}
+		return data, nil
+	}
+
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("%w: expected an http(s) or data URL", ErrInvalidImage)
+	}
+	if !v.FetchRemote {
+		return nil, fmt.Errorf("%w: remote image URLs are disabled, send base64 data URLs", ErrInvalidImage)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	resp, err := v.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: fetching %s returned status %d", ErrInvalidImage, url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, v.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if int64(len(data)) > v.MaxBytes {
+		return nil, ErrImageTooLarge
+	}
+	return data, nil
+}
+
+// normalize downscales and re-encodes data as needed, returning the bytes and MIME type
+func (v *Vision) normalize(data []byte) ([]byte, string, error) {
+	if int64(len(data)) > v.MaxBytes {
+		return nil, "", ErrImageTooLarge
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, "", ErrUnsupportedImage
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	target := v.Format
+	if target == "" {
+		target = format
+		if format == "gif" {
+			// Few backends accept GIF; its first frame is sent as PNG
+			target = "png"
+		}
+	}
+	longest := max(cfg.Width, cfg.Height)
+	if target == format && (v.MaxDimension <= 0 || longest <= v.MaxDimension) {
+		return data, "image/" + format, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if v.MaxDimension > 0 && longest > v.MaxDimension {
+		scale := float64(v.MaxDimension) / float64(longest)
+		img = downscale(img, max(1, int(float64(cfg.Width)*scale)), max(1, int(float64(cfg.Height)*scale)))
+	}
+
+	var out bytes.Buffer
+	switch target {
+	case "jpeg":
+		// JPEG has no alpha channel, so flatten transparent areas onto white
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&out, flat, &jpeg.Options{Quality: 90})
+	case "png":
+		err = png.Encode(&out, img)
+	default:
+		return nil, "", fmt.Errorf("unknown output image format %q", target)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return out.Bytes(), "image/" + target, nil
+}
+
+// downscale resizes src to w×h by averaging the source pixels each target pixel covers
+func downscale(src image.Image, w, h int) image.Image {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/w)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(src.At(sx, sy)).(color.NRGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			dst.Set(x, y, color.NRGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
+
+// Middleware validates image inputs and routes them to a vision-capable model
+func (v *Vision) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := Read(r)
+		if err != nil || len(imageParts(req)) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		model := r.Header.Get(engine.ModelHeader)
+		if model == "" {
+			model = req.Model()
+		}
+		routed, err := v.Route(model)
+		if err != nil {
+			openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "no_vision_model",
+				fmt.Sprintf("model %q does not accept images and no vision-capable model is loaded", model))
+			return
+		}
+		if routed != model {
+			_ = req.Set("model", routed)
+			r.Header.Del(engine.ModelHeader)
+			w.Header().Set(VisionRoutedHeader, routed)
+		}
+
+		if _, err := v.Prepare(r.Context(), req); err != nil {
+			openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", imageErrorCode(err), err.Error())
+			return
+		}
+		if err := req.Write(r); err != nil {
+			openai.WriteError(w, http.StatusInternalServerError, "server_error", "", err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func imageErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrUnsupportedImage):
+		return "unsupported_image_format"
+	case errors.Is(err, ErrImageTooLarge):
+		return "image_too_large"
+	default:
+		return "invalid_image"
+	}
+}
@@ -0,0 +1,142 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func pngDataURL(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 10, B: 10, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func imageRequest(model, url string) *http.Request {
+	body, _ := json.Marshal(map[string]any{
+		"model": model,
+		"messages": []any{map[string]any{"role": "user", "content": []any{
+			map[string]any{"type": "text", "text": "What is this?"},
+			map[string]any{"type": "image_url", "image_url": map[string]any{"url": url, "detail": "low"}},
+		}}},
+	})
+	return httptest.NewRequest(http.MethodPost, CompletionsPath, bytes.NewReader(body))
+}
+
+// forwardedImage runs the middleware and decodes the image the backend received
+func forwardedImage(t *testing.T, v *Vision, r *http.Request) (string, image.Config, string, *httptest.ResponseRecorder) {
+	t.Helper()
+	var model, url string
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := Read(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		model = req.Model()
+		ref := imageParts(req)[0]["image_url"].(map[string]any)
+		url = ref["url"].(string)
+		if ref["detail"] != "low" {
+			t.Errorf("detail lost: %v", ref)
+		}
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if url == "" {
+		return model, image.Config{}, "", rec
+	}
+
+	meta, payload, _ := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model, cfg, strings.TrimSuffix(meta, ";base64"), rec
+}
+
+func TestVisionDownscalesOversizedImages(t *testing.T) {
+	v := NewVision(nil)
+	v.MaxDimension = 64
+	_, cfg, mime, _ := forwardedImage(t, v, imageRequest("llava-1.6-7b", pngDataURL(t, 200, 100)))
+	if cfg.Width != 64 || cfg.Height != 32 || mime != "image/png" {
+		t.Errorf("want 64x32 png, got %dx%d %s", cfg.Width, cfg.Height, mime)
+	}
+}
+
+func TestVisionConvertsFormat(t *testing.T) {
+	v := NewVision(nil)
+	v.Format = "jpeg"
+	_, cfg, mime, _ := forwardedImage(t, v, imageRequest("llava-1.6-7b", pngDataURL(t, 10, 10)))
+	if mime != "image/jpeg" || cfg.Width != 10 {
+		t.Errorf("want 10px jpeg, got %dpx %s", cfg.Width, mime)
+	}
+}
+
+func TestVisionInlinesRemoteImages(t *testing.T) {
+	data, _ := base64.StdEncoding.DecodeString(strings.SplitN(pngDataURL(t, 8, 8), ",", 2)[1])
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	_, cfg, mime, _ := forwardedImage(t, NewVision(nil), imageRequest("pixtral-12b", server.URL+"/cat.png"))
+	if cfg.Width != 8 || mime != "image/png" {
+		t.Errorf("want inlined 8px png, got %dpx %s", cfg.Width, mime)
+	}
+}
+
+func TestVisionRoutesToLoadedVisionModel(t *testing.T) {
+	v := NewVision(func() []string { return []string{"llama-3-8b", "qwen2-vl-7b"} })
+	model, _, _, rec := forwardedImage(t, v, imageRequest("llama-3-8b", pngDataURL(t, 4, 4)))
+	if model != "qwen2-vl-7b" || rec.Header().Get(VisionRoutedHeader) != "qwen2-vl-7b" {
+		t.Errorf("want request routed to qwen2-vl-7b, got %q", model)
+	}
+}
+
+func TestVisionRejectsWithoutVisionModel(t *testing.T) {
+	v := NewVision(func() []string { return []string{"llama-3-8b"} })
+	_, _, _, rec := forwardedImage(t, v, imageRequest("llama-3-8b", pngDataURL(t, 4, 4)))
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(string(body), "no_vision_model") {
+		t.Errorf("want 400 no_vision_model, got %d %s", rec.Code, body)
+	}
+}
+
+func TestVisionRejectsUnsupportedFormats(t *testing.T) {
+	webp := "data:image/webp;base64," + base64.StdEncoding.EncodeToString([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "))
+	_, _, _, rec := forwardedImage(t, NewVision(nil), imageRequest("llava-1.6-7b", webp))
+	body, _ := io.ReadAll(rec.Body)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(string(body), "unsupported_image_format") {
+		t.Errorf("want 400 unsupported_image_format, got %d %s", rec.Code, body)
+	}
+}
+
+func TestVisionPassesTextOnlyRequests(t *testing.T) {
+	called := false
+	handler := NewVision(nil).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	body := `{"model":"llama-3-8b","messages":[{"role":"user","content":"hi"}]}`
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(body)))
+	if !called {
+		t.Error("text-only request was not forwarded")
+	}
+}
@@ -0,0 +1,309 @@
+package chat
+
+import (
+	"botframework/openai"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Headers that tell clients the prompt was shortened to fit the context window
+const (
+	TruncatedHeader  = "X-BotFramework-Context-Truncated"
+	SummarizedHeader = "X-BotFramework-Context-Summarized"
+)
+
+// Overflow strategies
+const (
+	OverflowTruncate  = "truncate"
+	OverflowSummarize = "summarize"
+	// OverflowReject refuses prompts that do not fit rather than dropping turns
+	OverflowReject = "reject"
+)
+
+// ErrContextOverflow means the prompt cannot fit even after dropping every droppable turn
+var ErrContextOverflow = errors.New("prompt exceeds the model's context window")
+
+// TokenCounter counts prompt tokens for a model
+type TokenCounter interface {
+	CountTokens(model string, messages []Message) int
+}
+
+// EstimateCounter approximates token counts without a tokenizer: ~4 bytes per token for
+// ASCII text, one token per non-ASCII rune, plus per-message formatting overhead
+type EstimateCounter struct{}
+
+const (
+	messageOverheadTokens = 4
+	imagePartTokens       = 85
+)
+
+func (EstimateCounter) CountTokens(model string, messages []Message) int {
+	total := 3 // reply priming
+	for _, m := range messages {
+		total += messageOverheadTokens + estimateText(m.Role) + estimateText(m.Text()) + len(m.ToolCalls)/4
+		if parts, ok := m.Content.([]any); ok {
+			for _, part := range parts {
+				if p, ok := part.(map[string]any); ok && p["type"] != "text" {
+					total += imagePartTokens
+				}
+			}
+		}
+	}
+	return total
+}
+
+func estimateText(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// CountText estimates the tokens of generated text
+func (EstimateCounter) CountText(model, text string) int {
+	return estimateText(text)
+}
+
+// TextCounter counts the tokens of a text, such as a completion, for a model
+type TextCounter interface {
+	CountText(model, text string) int
+}
+
+// TextTokenizer counts tokens with one model's vocabulary
+type TextTokenizer interface {
+	Count(text string) int
+}
+
+// TokenizedCounter counts with each model's own tokenizer and falls back to Fallback (an
+// EstimateCounter when nil) for models without one. Messages count their role, text and
+// tool calls, plus the per-message overhead chat templates add.
+type TokenizedCounter struct {
+	// Tokenizer returns the tokenizer of a model, nil when it has none
+	Tokenizer func(model string) TextTokenizer
+	Fallback  TokenCounter
+}
+
+func (c TokenizedCounter) fallback() TokenCounter {
+	if c.Fallback == nil {
+		return EstimateCounter{}
+	}
+	return c.Fallback
+}
+
+func (c TokenizedCounter) CountTokens(model string, messages []Message) int {
+	tokenizer := c.Tokenizer(model)
+	if tokenizer == nil {
+		return c.fallback().CountTokens(model, messages)
+	}
+	total := 3 // reply priming
+	for _, m := range messages {
+		total += messageOverheadTokens + tokenizer.Count(m.Role) + tokenizer.Count(m.Text())
+		if len(m.ToolCalls) > 0 {
+			total += tokenizer.Count(string(m.ToolCalls))
+		}
+		if parts, ok := m.Content.([]any); ok {
+			for _, part := range parts {
+				if p, ok := part.(map[string]any); ok && p["type"] != "text" {
+					total += imagePartTokens
+				}
+			}
+		}
+	}
+	return total
+}
+
+func (c TokenizedCounter) CountText(model, text string) int {
+	if tokenizer := c.Tokenizer(model); tokenizer != nil {
+		return tokenizer.Count(text)
+	}
+	return countText(c.fallback(), model, text)
+}
+
+// countText counts text with counter when it counts texts, and estimates it otherwise
+func countText(counter TokenCounter, model, text string) int {
+	if tc, ok := counter.(TextCounter); ok {
+		return tc.CountText(model, text)
+	}
+	return estimateText(text)
+}
+
+// Summarizer condenses conversation turns into a short text
+type Summarizer interface {
+	Summarize(ctx context.Context, model string, messages []Message) (string, error)
+}
+
+// WindowManager keeps chat prompts within the active model's context window by dropping
+// (or summarizing) the oldest turns, or rejects them under OverflowReject. System messages
+// and the latest turn are always kept.
+type WindowManager struct {
+	// Window returns the context length for a model, 0 when unknown
+	Window        func(model string) int
+	DefaultWindow int
+	// ReserveTokens is kept free for the completion when the request sets no max_tokens
+	ReserveTokens int
+	Counter       TokenCounter
+	Strategy      string
+	Summarizer    Summarizer
+	// SummaryTokens is room left for the summary message when summarizing
+	SummaryTokens int
+}
+
+func NewWindowManager(window func(model string) int) *WindowManager {
+	return &WindowManager{
+		Window:        window,
+		DefaultWindow: 4096,
+		ReserveTokens: 512,
+		Counter:       EstimateCounter{},
+		Strategy:      OverflowTruncate,
+		SummaryTokens: 256,
+	}
+}
+
+// FitResult describes what Fit changed
+type FitResult struct {
+	PromptTokens int
+	Dropped      int
+	Summarized   bool
+}
+
+func (wm *WindowManager) budget(req *Request) int {
+	window := 0
+	if wm.Window != nil {
+		window = wm.Window(req.Model())
+	}
+	if window <= 0 {
+		window = wm.DefaultWindow
+	}
+
+	reserve := wm.ReserveTokens
+	var maxTokens int
+	if req.Get("max_completion_tokens", &maxTokens) || req.Get("max_tokens", &maxTokens) {
+		reserve = maxTokens
+	}
+	return window - reserve
+}
+
+// Fit shortens req.Messages in place until the prompt fits the budget
+func (wm *WindowManager) Fit(ctx context.Context, req *Request) (FitResult, error) {
+	budget := wm.budget(req)
+	model := req.Model()
+	count := func() int { return wm.Counter.CountTokens(model, req.Messages) }
+
+	result := FitResult{PromptTokens: count()}
+	if result.PromptTokens <= budget {
+		return result, nil
+	}
+	if wm.Strategy == OverflowReject {
+		return result, ErrContextOverflow
+	}
+
+	summarize := wm.Strategy == OverflowSummarize && wm.Summarizer != nil
+	target := budget
+	if summarize {
+		target -= wm.SummaryTokens
+	}
+
+	var dropped []Message
+	for result.PromptTokens > target {
+		start, end := oldestTurn(req.Messages)
+		if start < 0 {
+			if result.PromptTokens <= budget {
+				// no room left for a summary, but the prompt itself fits
+				summarize = false
+				break
+			}
+			return result, ErrContextOverflow
+		}
+		dropped = append(dropped, req.Messages[start:end]...)
+		req.Messages = append(req.Messages[:start:start], req.Messages[end:]...)
+		result.PromptTokens = count()
+	}
+	result.Dropped = len(dropped)
+
+	if summarize && len(dropped) > 0 {
+		summary, err := wm.Summarizer.Summarize(ctx, model, dropped)
+		if err != nil {
+			slog.WarnContext(ctx, "context summary failed, truncating instead", "err", err)
+			return result, nil
+		}
+		withSummary := insertAfterSystem(req.Messages, Message{Role: "system", Content: "Earlier conversation summary: " + summary})
+		if tokens := wm.Counter.CountTokens(model, withSummary); tokens <= budget {
+			req.Messages = withSummary
+			result.PromptTokens = tokens
+			result.Summarized = true
+		}
+	}
+	return result, nil
+}
+
+// oldestTurn returns the bounds of the oldest droppable message, extended over the tool
+// results that answer it. The latest turn — the final message, plus the assistant tool call
+// it answers when it is a tool result — is never droppable.
+func oldestTurn(messages []Message) (int, int) {
+	keep := len(messages) - 1
+	for keep > 0 && messages[keep].Role == "tool" {
+		keep--
+	}
+	for i := 0; i < keep; i++ {
+		if messages[i].Role == "system" {
+			continue
+		}
+		end := i + 1
+		for end < keep && messages[end].Role == "tool" {
+			end++
+		}
+		return i, end
+	}
+	return -1, -1
+}
+
+func insertAfterSystem(messages []Message, msg Message) []Message {
+	i := 0
+	for i < len(messages) && messages[i].Role == "system" {
+		i++
+	}
+	out := make([]Message, 0, len(messages)+1)
+	out = append(out, messages[:i]...)
+	out = append(out, msg)
+	return append(out, messages[i:]...)
+}
+
+// Middleware fits chat completion prompts to the model's window and annotates the response
+// with TruncatedHeader / SummarizedHeader when turns were removed
+func (wm *WindowManager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := Read(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := wm.Fit(r.Context(), req)
+		if errors.Is(err, ErrContextOverflow) {
+			openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "context_length_exceeded",
+				fmt.Sprintf("%v: %d prompt tokens, %d available", err, result.PromptTokens, wm.budget(req)))
+			return
+		}
+		if result.Dropped > 0 {
+			if err := req.Write(r); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(TruncatedHeader, strconv.Itoa(result.Dropped))
+			if result.Summarized {
+				w.Header().Set(SummarizedHeader, "true")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
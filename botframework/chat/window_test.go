@@ -0,0 +1,178 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// wordCounter counts one token per word so tests can reason about budgets
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(model string, messages []Message) int {
+	total := 0
+	for _, m := range messages {
+		total += len(strings.Fields(m.Text()))
+	}
+	return total
+}
+
+type fixedSummarizer struct {
+	summary string
+	err     error
+	got     []Message
+}
+
+func (s *fixedSummarizer) Summarize(ctx context.Context, model string, messages []Message) (string, error) {
+	s.got = messages
+	return s.summary, s.err
+}
+
+func newTestWindow(window int) *WindowManager {
+	wm := NewWindowManager(func(string) int { return window })
+	wm.ReserveTokens = 0
+	wm.Counter = wordCounter{}
+	return wm
+}
+
+func conversation() *Request {
+	return &Request{fields: map[string]json.RawMessage{}, Messages: []Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "one two three"},
+		{Role: "assistant", Content: "four five six"},
+		{Role: "user", Content: "seven eight"},
+	}}
+}
+
+func TestFitDropsOldestTurnsAndKeepsSystem(t *testing.T) {
+	req := conversation()
+	result, err := newTestWindow(6).Fit(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Dropped != 2 || result.PromptTokens != 4 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || req.Messages[1].Text() != "seven eight" {
+		t.Errorf("unexpected messages: %+v", req.Messages)
+	}
+}
+
+func TestFitOverflowWhenLatestTurnTooLarge(t *testing.T) {
+	if _, err := newTestWindow(3).Fit(context.Background(), conversation()); !errors.Is(err, ErrContextOverflow) {
+		t.Errorf("expected overflow, got %v", err)
+	}
+}
+
+func TestFitSummarizesDroppedTurns(t *testing.T) {
+	wm := newTestWindow(9)
+	wm.SummaryTokens = 5
+	summarizer := &fixedSummarizer{summary: "greeting"}
+	wm.Strategy, wm.Summarizer = OverflowSummarize, summarizer
+
+	req := conversation()
+	result, err := wm.Fit(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Summarized || len(summarizer.got) != 2 {
+		t.Fatalf("expected the two dropped turns to be summarized: %+v", result)
+	}
+	if req.Messages[1].Role != "system" || !strings.HasSuffix(req.Messages[1].Text(), "greeting") {
+		t.Errorf("summary not inserted after the system prompt: %+v", req.Messages)
+	}
+
+	// a failing summarizer falls back to plain truncation
+	summarizer.err = errors.New("model busy")
+	req = conversation()
+	if result, _ := wm.Fit(context.Background(), req); result.Summarized || len(req.Messages) != 2 {
+		t.Errorf("expected truncation fallback: %+v %+v", result, req.Messages)
+	}
+}
+
+func TestFitRejectKeepsEveryTurn(t *testing.T) {
+	wm := newTestWindow(6)
+	wm.Strategy = OverflowReject
+	req := conversation()
+	if _, err := wm.Fit(context.Background(), req); !errors.Is(err, ErrContextOverflow) || len(req.Messages) != 4 {
+		t.Errorf("err = %v, %d messages left", err, len(req.Messages))
+	}
+}
+
+func TestOldestTurnKeepsToolResultsWithTheirCall(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "weather?"},
+		{Role: "assistant", ToolCalls: []byte(`[{"id":"1"}]`)},
+		{Role: "tool", ToolCallID: "1", Content: "sunny"},
+		{Role: "user", Content: "and tomorrow?"},
+		{Role: "assistant", ToolCalls: []byte(`[{"id":"2"}]`)},
+		{Role: "tool", ToolCallID: "2", Content: "rain"},
+	}
+	if start, end := oldestTurn(messages[1:]); start != 0 || end != 2 {
+		t.Errorf("expected call and result to drop together, got %d..%d", start, end)
+	}
+	if start, _ := oldestTurn(messages[4:]); start != -1 {
+		t.Errorf("latest tool exchange must not be droppable, got %d", start)
+	}
+}
+
+func TestMiddlewareAnnotatesTruncation(t *testing.T) {
+	wm := newTestWindow(5)
+	var forwarded string
+	handler := wm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		forwarded = string(body)
+	}))
+
+	body := `{"model":"m","messages":[{"role":"user","content":"one two three four"},{"role":"user","content":"five six"}]}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(body)))
+	if rec.Header().Get(TruncatedHeader) != "1" || strings.Contains(forwarded, "one two") {
+		t.Errorf("expected first turn dropped: header=%q body=%s", rec.Header().Get(TruncatedHeader), forwarded)
+	}
+
+	body = `{"model":"m","max_tokens":5,"messages":[{"role":"user","content":"five six"}]}`
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, CompletionsPath, strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "context_length_exceeded") {
+		t.Errorf("expected context_length_exceeded, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEstimateCounter(t *testing.T) {
+	short := EstimateCounter{}.CountTokens("m", []Message{{Role: "user", Content: "hello"}})
+	long := EstimateCounter{}.CountTokens("m", []Message{{Role: "user", Content: strings.Repeat("hello ", 100)}})
+	if short <= 0 || long < 140 || long > 170 {
+		t.Errorf("unexpected estimates: %d %d", short, long)
+	}
+}
+
+// runeTokenizer counts one token per rune
+type runeTokenizer struct{}
+
+func (runeTokenizer) Count(text string) int { return len([]rune(text)) }
+
+func TestTokenizedCounter(t *testing.T) {
+	counter := TokenizedCounter{Tokenizer: func(model string) TextTokenizer {
+		if model == "known" {
+			return runeTokenizer{}
+		}
+		return nil
+	}}
+	messages := []Message{{Role: "user", Content: "héllo"}}
+	// priming, overhead, "user" and "héllo"
+	if got, want := counter.CountTokens("known", messages), 3+messageOverheadTokens+4+5; got != want {
+		t.Errorf("CountTokens = %d, want %d", got, want)
+	}
+	if got, want := counter.CountTokens("other", messages), (EstimateCounter{}).CountTokens("other", messages); got != want {
+		t.Errorf("models without a tokenizer: %d, want the estimate %d", got, want)
+	}
+	if got := counter.CountText("known", "héllo"); got != 5 {
+		t.Errorf("CountText = %d, want 5", got)
+	}
+}
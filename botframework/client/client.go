@@ -0,0 +1,166 @@
+package client
+
+import (
+	"botframework/api"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrUnsupported is returned when the manager does not expose the requested admin endpoint
+var ErrUnsupported = errors.New("endpoint not supported by this manager")
+
+// Client talks to a manager's admin API
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// LoadRequest asks the manager to start serving a model
+type LoadRequest struct {
+	Model     string `json:"model"`
+	ModelPath string `json:"model_path,omitempty"`
+	Engine    string `json:"engine,omitempty"`
+}
+
+func (c *Client) Status() (*api.AdminStatus, error) {
+	var status api.AdminStatus
+	if err := c.do(http.MethodGet, "/admin/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func (c *Client) Models() (*api.ModelListResponse, error) {
+	var models api.ModelListResponse
+	if err := c.do(http.MethodGet, "/v1/models", nil, &models); err != nil {
+		return nil, err
+	}
+	return &models, nil
+}
+
+func (c *Client) LoadModel(req LoadRequest) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(http.MethodPost, "/admin/models/load", req, &out)
+	return out, err
+}
+
+func (c *Client) UnloadModel(model string) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(http.MethodPost, "/admin/models/unload", map[string]string{"model": model}, &out)
+	return out, err
+}
+
+func (c *Client) SwapModel(req LoadRequest) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(http.MethodPost, "/admin/models/swap", req, &out)
+	return out, err
+}
+
+func (c *Client) Usage(query url.Values) (json.RawMessage, error) {
+	path := "/admin/usage"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	var out json.RawMessage
+	err := c.do(http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+func (c *Client) RotateKey(keyID string) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := c.do(http.MethodPost, "/admin/keys/"+url.PathEscape(keyID)+"/rotate", nil, &out)
+	return out, err
+}
+
+// Logs returns the recent log lines of a worker
+func (c *Client) Logs(workerID string, tail int) ([]string, error) {
+	var out struct {
+		Lines []string `json:"lines"`
+	}
+	path := fmt.Sprintf("/admin/workers/%s/logs?tail=%d", url.PathEscape(workerID), tail)
+	if err := c.do(http.MethodGet, path, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Lines, nil
+}
+
+// Workers lists the manager's workers
+func (c *Client) Workers() ([]api.WorkerInfo, error) {
+	var out struct {
+		Workers []api.WorkerInfo `json:"workers"`
+	}
+	if err := c.do(http.MethodGet, "/admin/workers", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Workers, nil
+}
+
+// WorkerAction restarts or stops a worker ("restart" or "stop")
+func (c *Client) WorkerAction(workerID, action string) (*api.WorkerInfo, error) {
+	var out api.WorkerInfo
+	if err := c.do(http.MethodPost, "/admin/workers/"+url.PathEscape(workerID)+"/"+action, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return fmt.Errorf("%s %s: %w", method, path, ErrUnsupported)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s returned status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(bytes.TrimSpace(payload)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(payload, out)
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"botframework/api"
+	"botframework/auth"
+	"botframework/engine"
+	"botframework/supervisor"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeEngine is a healthy worker that answers nothing
+type fakeEngine struct{}
+
+func (fakeEngine) Start(context.Context) error                         { return nil }
+func (fakeEngine) ProxyRequest(w http.ResponseWriter, _ *http.Request) {}
+func (fakeEngine) Stop() error                                         { return nil }
+func (fakeEngine) Status() supervisor.WorkerStatus {
+	return supervisor.WorkerStatus{State: supervisor.StateRunning}
+}
+func (fakeEngine) Health() (*supervisor.WorkerHealth, error) {
+	return &supervisor.WorkerHealth{Status: "ok"}, nil
+}
+
+// adminServer serves the manager's model and key routes over a manager with model "phi"
+// loaded and API key "web"
+func adminServer(t *testing.T) (*httptest.Server, *auth.FileStore) {
+	t.Helper()
+	manager := &engine.ModelManager{Engine: fakeEngine{}}
+	manager.Loader = func(string) (engine.InferenceEngine, error) { return fakeEngine{}, nil }
+	manager.Register("phi", fakeEngine{})
+
+	keys := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keys, []byte(`{"keys": [{"id": "web", "token": "secret", "rate_limit": 60}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := auth.LoadFileStore(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.NewAuthenticator(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	api.RegisterModelRoutes(mux, manager)
+	api.RegisterKeyRoutes(mux, authenticator)
+	mux.Handle("/", http.NotFoundHandler())
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts, store
+}
+
+func TestClientModelCommandsReachManagerRoutes(t *testing.T) {
+	ts, _ := adminServer(t)
+	c := New(ts.URL, "")
+
+	if _, err := c.SwapModel(LoadRequest{Model: "llama"}); err != nil {
+		t.Fatalf("swap: %v", err)
+	}
+	out, err := c.UnloadModel("phi")
+	if err != nil {
+		t.Fatalf("unload: %v", err)
+	}
+	var unloaded map[string]string
+	if json.Unmarshal(out, &unloaded); unloaded["status"] != "unloaded" {
+		t.Fatalf("unload = %s", out)
+	}
+	if _, err := c.UnloadModel("phi"); err == nil {
+		t.Fatal("unloading a model twice succeeded")
+	}
+}
+
+func TestClientRotateKey(t *testing.T) {
+	ts, store := adminServer(t)
+	out, err := New(ts.URL, "").RotateKey("web")
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	var rotated struct{ ID, Token string }
+	json.Unmarshal(out, &rotated)
+	if rotated.ID != "web" || rotated.Token == "" {
+		t.Fatalf("rotate = %s", out)
+	}
+	if _, ok := store.Lookup("secret"); ok {
+		t.Error("the old token still works")
+	}
+	if key, ok := store.Lookup(rotated.Token); !ok || key.ID != "web" || key.RateLimit != 60 {
+		t.Errorf("new token: key %+v, found %v", key, ok)
+	}
+}
+
+func TestClientSendsTokenAndDecodes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("expected bearer token, got %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"lines":["a","b"]}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, "secret")
+	lines, err := c.Logs("default", 10)
+	if err != nil {
+		t.Fatalf("logs: %v", err)
+	}
+	if len(lines) != 2 || lines[1] != "b" {
+		t.Fatalf("unexpected lines: %v", lines)
+	}
+}
+
+func TestClientUnsupportedEndpoint(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	_, err := New(ts.URL, "").RotateKey("k1")
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestProfilesRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "botctl.json")
+
+	profiles, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("load missing file: %v", err)
+	}
+	profiles.Profiles["lab"] = Profile{URL: "http://lab:8080", Token: "t"}
+	profiles.Current = "lab"
+	if err := profiles.Save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	reloaded, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, err := reloaded.Resolve("")
+	if err != nil || got.URL != "http://lab:8080" {
+		t.Fatalf("unexpected current profile %+v (%v)", got, err)
+	}
+	if _, err := reloaded.Resolve("missing"); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+}
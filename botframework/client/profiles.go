@@ -0,0 +1,88 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Profile points botctl at one manager
+type Profile struct {
+	URL   string `json:"url"`
+	Token string `json:"token,omitempty"`
+}
+
+// Profiles is the on-disk set of known managers
+type Profiles struct {
+	Current  string             `json:"current"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// DefaultProfilesPath returns ~/.config/botframework/botctl.json (or the OS equivalent)
+func DefaultProfilesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "botctl.json"
+	}
+	return filepath.Join(dir, "botframework", "botctl.json")
+}
+
+// LoadProfiles reads the profiles file; a missing file yields an empty set
+func LoadProfiles(path string) (*Profiles, error) {
+	profiles := &Profiles{Profiles: map[string]Profile{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, profiles); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if profiles.Profiles == nil {
+		profiles.Profiles = map[string]Profile{}
+	}
+	return profiles, nil
+}
+
+func (p *Profiles) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Profiles may hold admin tokens
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Resolve returns the named profile, or the current one when name is empty
+func (p *Profiles) Resolve(name string) (Profile, error) {
+	if name == "" {
+		name = p.Current
+	}
+	if name == "" {
+		return Profile{URL: "http://127.0.0.1:8080"}, nil
+	}
+	profile, ok := p.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("unknown profile %q", name)
+	}
+	return profile, nil
+}
+
+// Names returns the profile names in sorted order
+func (p *Profiles) Names() []string {
+	names := make([]string, 0, len(p.Profiles))
+	for name := range p.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,192 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NodeInfo is what each manager publishes about itself
+type NodeInfo struct {
+	ID        string    `json:"id"`
+	Addr      string    `json:"addr"` // base URL other managers forward to
+	Models    []string  `json:"models"`
+	Heartbeat time.Time `json:"heartbeat"`
+}
+
+// Lease records which node currently leads the cluster
+type Lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// StateBackend is the shared state every manager in the cluster reads and writes
+type StateBackend interface {
+	// AcquireLease takes or renews the leader lease; it reports whether holder now leads
+	AcquireLease(holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(holder string) error
+	Leader() (Lease, error)
+	PutNode(node NodeInfo) error
+	Nodes() ([]NodeInfo, error)
+	SetPlacement(model, nodeID string) error
+	Placements() (map[string]string, error)
+}
+
+// FileBackend keeps cluster state in a directory shared by all managers (e.g. NFS)
+type FileBackend struct {
+	Dir string
+	// LockTimeout breaks a lock left behind by a crashed manager
+	LockTimeout time.Duration
+	now         func() time.Time
+}
+
+func NewFileBackend(dir string) (*FileBackend, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "nodes"), 0o755); err != nil {
+		return nil, err
+	}
+	return &FileBackend{Dir: dir, LockTimeout: 10 * time.Second, now: time.Now}, nil
+}
+
+func (b *FileBackend) AcquireLease(holder string, ttl time.Duration) (bool, error) {
+	var acquired bool
+	err := b.withLock("lease", func() error {
+		var lease Lease
+		if err := b.read("lease.json", &lease); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		now := b.now()
+		if lease.Holder != "" && lease.Holder != holder && now.Before(lease.Expires) {
+			return nil
+		}
+		acquired = true
+		return b.write("lease.json", Lease{Holder: holder, Expires: now.Add(ttl)})
+	})
+	return acquired, err
+}
+
+func (b *FileBackend) ReleaseLease(holder string) error {
+	return b.withLock("lease", func() error {
+		var lease Lease
+		if err := b.read("lease.json", &lease); err != nil {
+			return nil
+		}
+		if lease.Holder != holder {
+			return nil
+		}
+		return os.Remove(filepath.Join(b.Dir, "lease.json"))
+	})
+}
+
+func (b *FileBackend) Leader() (Lease, error) {
+	var lease Lease
+	err := b.read("lease.json", &lease)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && b.now().After(lease.Expires)) {
+		return Lease{}, nil
+	}
+	return lease, err
+}
+
+func (b *FileBackend) PutNode(node NodeInfo) error {
+	return b.write(filepath.Join("nodes", safeName(node.ID)+".json"), node)
+}
+
+func (b *FileBackend) Nodes() ([]NodeInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(b.Dir, "nodes"))
+	if err != nil {
+		return nil, err
+	}
+	var nodes []NodeInfo
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		var node NodeInfo
+		if err := b.read(filepath.Join("nodes", entry.Name()), &node); err != nil {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+func (b *FileBackend) SetPlacement(model, nodeID string) error {
+	return b.withLock("placements", func() error {
+		placements, err := b.Placements()
+		if err != nil {
+			return err
+		}
+		placements[model] = nodeID
+		return b.write("placements.json", placements)
+	})
+}
+
+func (b *FileBackend) Placements() (map[string]string, error) {
+	placements := make(map[string]string)
+	if err := b.read("placements.json", &placements); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return placements, nil
+}
+
+func (b *FileBackend) read(name string, v any) error {
+	data, err := os.ReadFile(filepath.Join(b.Dir, name))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// write replaces a file atomically so readers never see partial state
+func (b *FileBackend) write(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(b.Dir, name)
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// withLock serialises read-modify-write cycles across managers with an O_EXCL lock file
+func (b *FileBackend) withLock(name string, fn func() error) error {
+	path := filepath.Join(b.Dir, name+".lock")
+	deadline := b.now().Add(b.LockTimeout)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_ = file.Close()
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && b.now().Sub(info.ModTime()) > b.LockTimeout {
+			_ = os.Remove(path)
+			continue
+		}
+		if b.now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	defer os.Remove(path)
+	return fn()
+}
+
+func safeName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' {
+			return '_'
+		}
+		return r
+	}, id)
+}
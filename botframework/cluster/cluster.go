@@ -0,0 +1,219 @@
+package cluster
+
+import (
+	"botframework/engine"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ForwardedHeader marks requests already forwarded by another manager so they are served locally
+const ForwardedHeader = "X-BotFramework-Forwarded-By"
+
+// ModelSource reports which models this manager serves
+type ModelSource interface {
+	ListModels() []string
+}
+
+// Node participates in leader election and routes requests to the manager owning each model
+type Node struct {
+	ID      string
+	Addr    string
+	Backend StateBackend
+	Models  ModelSource
+	// LeaseTTL is how long a leader keeps the lease without renewing it
+	LeaseTTL time.Duration
+	// NodeTTL is how long a node is considered alive after its last heartbeat
+	NodeTTL time.Duration
+
+	mu       sync.RWMutex
+	leader   bool
+	proxies  map[string]*httputil.ReverseProxy
+	lastSeen []NodeInfo
+}
+
+func NewNode(id, addr string, backend StateBackend, models ModelSource) *Node {
+	return &Node{
+		ID:       id,
+		Addr:     addr,
+		Backend:  backend,
+		Models:   models,
+		LeaseTTL: 15 * time.Second,
+		NodeTTL:  30 * time.Second,
+		proxies:  make(map[string]*httputil.ReverseProxy),
+	}
+}
+
+// Run heartbeats and contends for leadership until ctx is cancelled, then releases the lease
+func (n *Node) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.LeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		n.tick()
+		select {
+		case <-ctx.Done():
+			if err := n.Backend.ReleaseLease(n.ID); err != nil {
+				slog.Warn("cluster lease release failed", "err", err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (n *Node) tick() {
+	info := NodeInfo{ID: n.ID, Addr: n.Addr, Models: n.Models.ListModels(), Heartbeat: time.Now()}
+	if err := n.Backend.PutNode(info); err != nil {
+		slog.Warn("cluster heartbeat failed", "err", err)
+	}
+
+	leader, err := n.Backend.AcquireLease(n.ID, n.LeaseTTL)
+	if err != nil {
+		slog.Warn("cluster lease failed", "err", err)
+	}
+
+	n.mu.Lock()
+	if leader && !n.leader {
+		slog.Info("node is now the cluster leader", "node", n.ID)
+	}
+	n.leader = leader
+	n.mu.Unlock()
+}
+
+func (n *Node) IsLeader() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.leader
+}
+
+// Status is the cluster view reported by the admin API
+type Status struct {
+	Node   string     `json:"node"`
+	Leader string     `json:"leader"`
+	Nodes  []NodeInfo `json:"nodes"`
+}
+
+func (n *Node) Status() (Status, error) {
+	lease, err := n.Backend.Leader()
+	if err != nil {
+		return Status{}, err
+	}
+	nodes, err := n.liveNodes()
+	if err != nil {
+		return Status{}, err
+	}
+	return Status{Node: n.ID, Leader: lease.Holder, Nodes: nodes}, nil
+}
+
+func (n *Node) liveNodes() ([]NodeInfo, error) {
+	nodes, err := n.Backend.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-n.NodeTTL)
+	live := nodes[:0]
+	for _, node := range nodes {
+		if node.Heartbeat.After(cutoff) {
+			live = append(live, node)
+		}
+	}
+	return live, nil
+}
+
+// Owner returns the node that should serve model: a node already serving it, the recorded
+// placement, or — on the leader — a new placement on the least loaded node
+func (n *Node) Owner(model string) (NodeInfo, error) {
+	nodes, err := n.liveNodes()
+	if err != nil {
+		return NodeInfo{}, err
+	}
+	byID := make(map[string]NodeInfo, len(nodes))
+	for _, node := range nodes {
+		byID[node.ID] = node
+		if slices.Contains(node.Models, model) {
+			return node, nil
+		}
+	}
+
+	placements, err := n.Backend.Placements()
+	if err != nil {
+		return NodeInfo{}, err
+	}
+	if node, ok := byID[placements[model]]; ok {
+		return node, nil
+	}
+
+	if !n.IsLeader() {
+		lease, err := n.Backend.Leader()
+		if err != nil {
+			return NodeInfo{}, err
+		}
+		if leader, ok := byID[lease.Holder]; ok {
+			return leader, nil
+		}
+		return NodeInfo{}, errors.New("no cluster leader available")
+	}
+
+	if len(nodes) == 0 {
+		return NodeInfo{}, errors.New("no live nodes")
+	}
+	target := nodes[0]
+	for _, node := range nodes[1:] {
+		if len(node.Models) < len(target.Models) {
+			target = node
+		}
+	}
+	if err := n.Backend.SetPlacement(model, target.ID); err != nil {
+		return NodeInfo{}, err
+	}
+	slog.Info("placed model", "model", model, "node", target.ID)
+	return target, nil
+}
+
+// Middleware serves models owned by this node locally and forwards everything else
+func (n *Node) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		model, err := engine.RequestedModel(r)
+		if err != nil || model == "" || r.Header.Get(ForwardedHeader) != "" || slices.Contains(n.Models.ListModels(), model) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		owner, err := n.Owner(model)
+		if err != nil || owner.ID == n.ID {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		proxy, err := n.proxyFor(owner)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		r.Header.Set(ForwardedHeader, n.ID)
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+func (n *Node) proxyFor(node NodeInfo) (*httputil.ReverseProxy, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if proxy, ok := n.proxies[node.Addr]; ok {
+		return proxy, nil
+	}
+	target, err := url.Parse(node.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("node %s has invalid address %q: %w", node.ID, node.Addr, err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = -1
+	n.proxies[node.Addr] = proxy
+	return proxy, nil
+}
@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticModels []string
+
+func (m staticModels) ListModels() []string { return m }
+
+func TestLeaseIsExclusiveUntilExpiry(t *testing.T) {
+	backend, err := NewFileBackend(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	backend.now = func() time.Time { return now }
+
+	if ok, err := backend.AcquireLease("a", time.Second); err != nil || !ok {
+		t.Fatalf("expected a to acquire lease, got %v %v", ok, err)
+	}
+	if ok, _ := backend.AcquireLease("b", time.Second); ok {
+		t.Fatal("b acquired a lease held by a")
+	}
+	if ok, _ := backend.AcquireLease("a", time.Second); !ok {
+		t.Fatal("a could not renew its own lease")
+	}
+
+	now = now.Add(2 * time.Second)
+	if ok, _ := backend.AcquireLease("b", time.Second); !ok {
+		t.Fatal("b could not take an expired lease")
+	}
+	if lease, _ := backend.Leader(); lease.Holder != "b" {
+		t.Fatalf("expected leader b, got %q", lease.Holder)
+	}
+}
+
+func TestLeaderPlacesOnLeastLoadedNode(t *testing.T) {
+	backend, _ := NewFileBackend(t.TempDir())
+	node := NewNode("a", "http://a", backend, staticModels{"llama", "qwen"})
+	node.tick()
+	backend.PutNode(NodeInfo{ID: "b", Addr: "http://b", Models: []string{"phi"}, Heartbeat: time.Now()})
+
+	if !node.IsLeader() {
+		t.Fatal("single node should become leader")
+	}
+	owner, err := node.Owner("mistral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.ID != "b" {
+		t.Fatalf("expected placement on b, got %s", owner.ID)
+	}
+	if placements, _ := backend.Placements(); placements["mistral"] != "b" {
+		t.Fatalf("placement not recorded: %v", placements)
+	}
+}
+
+func TestMiddlewareForwardsToOwner(t *testing.T) {
+	var forwardedBy string
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedBy = r.Header.Get(ForwardedHeader)
+		io.WriteString(w, "remote")
+	}))
+	defer remote.Close()
+
+	backend, _ := NewFileBackend(t.TempDir())
+	backend.PutNode(NodeInfo{ID: "b", Addr: remote.URL, Models: []string{"phi"}, Heartbeat: time.Now()})
+	node := NewNode("a", "http://a", backend, staticModels{"llama"})
+	node.tick()
+
+	local := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "local") })
+	handler := node.Middleware(local)
+
+	for model, want := range map[string]string{"phi": "remote", "llama": "local"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"`+model+`"}`))
+		handler.ServeHTTP(rec, req)
+		if rec.Body.String() != want {
+			t.Errorf("model %s: expected %s, got %q", model, want, rec.Body.String())
+		}
+	}
+	if forwardedBy != "a" {
+		t.Errorf("expected forwarded header from a, got %q", forwardedBy)
+	}
+}
@@ -0,0 +1,443 @@
+// Package config loads the manager's settings from botframework.yaml. Every setting can be
+// overridden by its BOTFRAMEWORK_* environment variable, which takes precedence over the file.
+package config
+
+import (
+	"botframework/config/yaml"
+	"botframework/engine"
+	"botframework/listener"
+	"botframework/profiler"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultPath is read when BOTFRAMEWORK_CONFIG does not name a file
+const DefaultPath = "botframework.yaml"
+
+type Config struct {
+	Manager   ManagerConfig `yaml:"manager"`
+	Worker    WorkerConfig  `yaml:"worker"`
+	Engine    EngineConfig  `yaml:"engine"`
+	Registry  string        `yaml:"registry" env:"BOTFRAMEWORK_REGISTRY_PATH"`
+	Remote    RemoteConfig  `yaml:"registry_remote"`
+	LogLevel  string        `yaml:"log_level" env:"BOTFRAMEWORK_LOG_LEVEL"`   // debug, info, warn or error
+	LogFormat string        `yaml:"log_format" env:"BOTFRAMEWORK_LOG_FORMAT"` // text or json
+	Timeouts  TimeoutConfig `yaml:"timeouts"`
+}
+
+type ManagerConfig struct {
+	Listen        string `yaml:"listen" env:"BOTFRAMEWORK_LISTEN"`
+	AdminListen   string `yaml:"admin_listen" env:"BOTFRAMEWORK_ADMIN_LISTEN"`
+	MetricsListen string `yaml:"metrics_listen" env:"BOTFRAMEWORK_METRICS_LISTEN"`
+	// AdminToken is the bearer token /admin/ routes require; empty leaves them open
+	AdminToken string `yaml:"admin_token" env:"BOTFRAMEWORK_ADMIN_TOKEN"`
+	// WSOrigins lists the browser origins besides the manager's own that may open /ws/chat
+	WSOrigins string `yaml:"ws_origins" env:"BOTFRAMEWORK_WS_ORIGINS"`
+	// Middleware names the gateway middlewares in the order requests pass them
+	Middleware string `yaml:"middleware" env:"BOTFRAMEWORK_MIDDLEWARE"`
+	// CORSOrigins lists the origins browsers may call the API from; "*" allows any
+	CORSOrigins  string `yaml:"cors_origins" env:"BOTFRAMEWORK_CORS_ORIGINS"`
+	MaxBodyBytes int    `yaml:"max_body_bytes" env:"BOTFRAMEWORK_MAX_BODY_BYTES"`
+	// APIKeys is a JSON key file; when set every inference request needs one of its keys
+	APIKeys     string `yaml:"api_keys" env:"BOTFRAMEWORK_API_KEYS"`
+	APIKeyUsage string `yaml:"api_key_usage" env:"BOTFRAMEWORK_API_KEY_USAGE"`
+	// OTLPEndpoint is the OpenTelemetry collector traces are exported to; empty disables tracing
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	// TLS is self-signed or acme; setting TLSCert and TLSKey serves those files instead
+	TLS      string `yaml:"tls" env:"BOTFRAMEWORK_TLS"`
+	TLSCert  string `yaml:"tls_cert" env:"BOTFRAMEWORK_TLS_CERT"`
+	TLSKey   string `yaml:"tls_key" env:"BOTFRAMEWORK_TLS_KEY"`
+	TLSHosts string `yaml:"tls_hosts" env:"BOTFRAMEWORK_TLS_HOSTS"`
+	TLSDir   string `yaml:"tls_dir" env:"BOTFRAMEWORK_TLS_DIR"`
+	// ACMEEmail and ACMEDirectory configure the account certificates are ordered with
+	ACMEEmail     string `yaml:"acme_email" env:"BOTFRAMEWORK_ACME_EMAIL"`
+	ACMEDirectory string `yaml:"acme_directory" env:"BOTFRAMEWORK_ACME_DIRECTORY"`
+	// HTTPRedirect lists plain HTTP addresses that redirect to HTTPS
+	HTTPRedirect string `yaml:"http_redirect" env:"BOTFRAMEWORK_HTTP_REDIRECT"`
+}
+
+type WorkerConfig struct {
+	Script string `yaml:"script" env:"BOTFRAMEWORK_WORKER_SCRIPT"`
+	// Venv is a Python virtual environment; its interpreter is used unless Python is set
+	Venv   string `yaml:"venv" env:"BOTFRAMEWORK_VENV"`
+	Python string `yaml:"python" env:"BOTFRAMEWORK_PYTHON"`
+	// Port is the default worker's port; 0 picks a free one
+	Port int `yaml:"port" env:"BOTFRAMEWORK_WORKER_PORT"`
+	// Runtime is python, llama-server, docker or auto (llama-server for llama.cpp when it is installed)
+	Runtime string `yaml:"runtime" env:"BOTFRAMEWORK_WORKER_RUNTIME"`
+	// LlamaServer is the llama-server binary; default: llama-server on PATH
+	LlamaServer string `yaml:"llama_server" env:"BOTFRAMEWORK_LLAMA_SERVER"`
+	// DockerImage is the engine image docker workers run; default: vllm/vllm-openai:latest
+	DockerImage string `yaml:"docker_image" env:"BOTFRAMEWORK_DOCKER_IMAGE"`
+	// DockerGPUs are the GPUs passed to containers: all, a count, device IDs or none
+	DockerGPUs string `yaml:"docker_gpus" env:"BOTFRAMEWORK_DOCKER_GPUS"`
+	// DockerArgs are extra engine arguments for containers
+	DockerArgs string `yaml:"docker_args" env:"BOTFRAMEWORK_DOCKER_ARGS"`
+	// RemoteURL serves the default model from an inference server the manager does not run
+	RemoteURL string `yaml:"remote_url" env:"BOTFRAMEWORK_REMOTE_URL"`
+	// RemoteHeader is set on every request to remote servers, "Name: value"
+	RemoteHeader string `yaml:"remote_header" env:"BOTFRAMEWORK_REMOTE_HEADER"`
+	// RemoteCA, RemoteCert and RemoteKey configure TLS to remote servers
+	RemoteCA         string `yaml:"remote_ca" env:"BOTFRAMEWORK_REMOTE_CA"`
+	RemoteCert       string `yaml:"remote_cert" env:"BOTFRAMEWORK_REMOTE_CERT"`
+	RemoteKey        string `yaml:"remote_key" env:"BOTFRAMEWORK_REMOTE_KEY"`
+	RemoteInsecure   bool   `yaml:"remote_insecure" env:"BOTFRAMEWORK_REMOTE_INSECURE"`
+	RemoteHealthPath string `yaml:"remote_health_path" env:"BOTFRAMEWORK_REMOTE_HEALTH_PATH"`
+	// GPUs pins workers to GPUs by model name, e.g. "default=0;fast=1"
+	GPUs string `yaml:"gpus" env:"BOTFRAMEWORK_WORKER_GPUS"`
+	// Protocol is how the manager talks to Python workers: http or grpc
+	Protocol string `yaml:"protocol" env:"BOTFRAMEWORK_WORKER_PROTOCOL"`
+	// Bootstrap provisions a pinned venv per engine: auto (use one if built), on or off
+	Bootstrap string `yaml:"bootstrap" env:"BOTFRAMEWORK_BOOTSTRAP"`
+	VenvCache string `yaml:"venv_cache" env:"BOTFRAMEWORK_VENV_CACHE"`
+}
+
+type EngineConfig struct {
+	// Override skips the hardware recommendation
+	Override string `yaml:"override" env:"BOTFRAMEWORK_ENGINE"`
+	// ModelSizeGB is the model size the recommendation plans for
+	ModelSizeGB float64 `yaml:"model_size_gb" env:"BOTFRAMEWORK_MODEL_SIZE_GB"`
+	// ContextLength is the context the model recommendations leave KV cache room for
+	ContextLength int `yaml:"context_length" env:"BOTFRAMEWORK_CONTEXT_LENGTH"`
+	// Concurrency is how many sequences the model recommendations leave KV cache room for
+	Concurrency int `yaml:"concurrency" env:"BOTFRAMEWORK_CONCURRENCY"`
+	// Preference weighs latency against quality in model recommendations
+	Preference string `yaml:"preference" env:"BOTFRAMEWORK_PREFERENCE"`
+	ModelPath  string `yaml:"model_path" env:"BOTFRAMEWORK_MODEL_PATH"`
+	// Models are served side by side, each by its own worker: "name=path,name=path"
+	Models   string `yaml:"models" env:"BOTFRAMEWORK_MODELS"`
+	ModelDir string `yaml:"model_dir" env:"BOTFRAMEWORK_MODEL_DIR"`
+	// ModelCache is where `manager download` stores models; on-demand loads search it too
+	ModelCache string `yaml:"model_cache" env:"BOTFRAMEWORK_MODEL_CACHE"`
+	// SpeedProbe measures the default model's tok/s at startup for model recommendations
+	SpeedProbe bool `yaml:"speed_probe" env:"BOTFRAMEWORK_SPEED_PROBE"`
+	// EmbeddingModel is served for /v1/embeddings by its own worker: a GGUF file, a
+	// registry model "<id>[:<quant>]" or "auto" for the best one downloaded
+	EmbeddingModel string `yaml:"embedding_model" env:"BOTFRAMEWORK_EMBEDDING_MODEL"`
+	// DraftModel is llama-server's draft model for speculative decoding: "auto" for the one
+	// the recommender pairs with the target, "off", a GGUF file or a registry model
+	DraftModel string `yaml:"draft_model" env:"BOTFRAMEWORK_DRAFT_MODEL"`
+	// TierDefaults sizes worker launches and request limits for the hardware tier: on or off
+	TierDefaults string `yaml:"tier_defaults" env:"BOTFRAMEWORK_TIER_DEFAULTS"`
+	// IdleTimeout unloads workers after this long without requests: "30m" for every worker
+	// or "default=30m;quality=10m" by model name
+	IdleTimeout string `yaml:"idle_timeout" env:"BOTFRAMEWORK_IDLE_TIMEOUT"`
+	// ColdStart is what requests for an unloaded model get while it starts: queue or loading
+	ColdStart string `yaml:"cold_start" env:"BOTFRAMEWORK_COLD_START"`
+	// ModelPolicy leaves models out of recommendations: "ungated", "commercial" or both
+	ModelPolicy string `yaml:"model_policy" env:"BOTFRAMEWORK_MODEL_POLICY"`
+}
+
+// RemoteConfig syncs the model registry from a published copy instead of Registry
+type RemoteConfig struct {
+	URL string `yaml:"url" env:"BOTFRAMEWORK_REGISTRY_URL"`
+	// PublicKey is a base64 Ed25519 key; when set, registries must be signed with it
+	PublicKey string `yaml:"public_key" env:"BOTFRAMEWORK_REGISTRY_PUBLIC_KEY"`
+	// Refresh is how often the running manager checks for a new registry (default: 6h)
+	Refresh time.Duration `yaml:"refresh" env:"BOTFRAMEWORK_REGISTRY_REFRESH"`
+	Cache   string        `yaml:"cache" env:"BOTFRAMEWORK_REGISTRY_CACHE"`
+}
+
+type TimeoutConfig struct {
+	WorkerReady time.Duration `yaml:"worker_ready" env:"BOTFRAMEWORK_WORKER_READY_TIMEOUT"`
+	Shutdown    time.Duration `yaml:"shutdown" env:"BOTFRAMEWORK_SHUTDOWN_TIMEOUT"`
+	// WorkerStop is how long a worker gets to exit after SIGTERM before it is killed
+	WorkerStop time.Duration `yaml:"worker_stop" env:"BOTFRAMEWORK_WORKER_STOP_TIMEOUT"`
+	// Request bounds how long an inference request may generate; zero leaves it unbounded
+	Request time.Duration `yaml:"request" env:"BOTFRAMEWORK_REQUEST_TIMEOUT"`
+}
+
+// Defaults are the settings used when neither the file nor the environment sets them.
+// Zero values leave the choice to the component that uses the setting.
+func Defaults() *Config {
+	return &Config{
+		Engine:    EngineConfig{ModelSizeGB: 5.5},
+		LogLevel:  "info",
+		LogFormat: "text",
+		Timeouts:  TimeoutConfig{Shutdown: 5 * time.Second},
+	}
+}
+
+// Load reads the file at path (skipped when path is empty), applies environment overrides and
+// validates the result, reporting every problem at once
+func Load(path string) (*Config, error) {
+	c := Defaults()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := yaml.Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := yaml.Decode(tree, reflect.ValueOf(c).Elem(), ""); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var errs []error
+	eachEnv(reflect.ValueOf(c).Elem(), func(name string, field reflect.Value) {
+		if value := os.Getenv(name); value != "" {
+			if err := yaml.SetScalar(field, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	})
+	if c.Worker.Python == "" && c.Worker.Venv != "" {
+		c.Worker.Python = venvPython(c.Worker.Venv)
+	}
+	errs = append(errs, c.validate()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return c, nil
+}
+
+// Path returns the config file to load: BOTFRAMEWORK_CONFIG, else botframework.yaml when it
+// exists in the working directory, else "" for defaults and environment only
+func Path() string {
+	if path := os.Getenv("BOTFRAMEWORK_CONFIG"); path != "" {
+		return path
+	}
+	if _, err := os.Stat(DefaultPath); err == nil {
+		return DefaultPath
+	}
+	return ""
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Export sets the environment variable of every configured setting, so the parts of the
+// manager that read their settings from the environment see values from the file
+func (c *Config) Export() {
+	eachEnv(reflect.ValueOf(c).Elem(), func(name string, field reflect.Value) {
+		if field.IsZero() {
+			return
+		}
+		value := fmt.Sprint(field.Interface())
+		if field.Type() == durationType {
+			value = time.Duration(field.Int()).String()
+		}
+		os.Setenv(name, value)
+	})
+}
+
+// eachEnv calls fn for every field with an `env` tag, descending into nested sections
+func eachEnv(v reflect.Value, fn func(name string, field reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct && field.Type() != durationType {
+			eachEnv(field, fn)
+			continue
+		}
+		if name := v.Type().Field(i).Tag.Get("env"); name != "" {
+			fn(name, field)
+		}
+	}
+}
+
+func venvPython(venv string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(venv, "Scripts", "python.exe")
+	}
+	return filepath.Join(venv, "bin", "python")
+}
+
+var (
+	logLevels       = []string{"debug", "info", "warn", "error"}
+	logFormats      = []string{"text", "json"}
+	workerRuntimes  = []string{"auto", "python", "llama-server", "docker"}
+	workerProtocols = []string{"http", "grpc"}
+	bootstrapModes  = []string{"auto", "on", "off"}
+	tlsModes        = []string{"off", "self-signed", "acme"}
+	onOff           = []string{"on", "off"}
+	coldStarts      = []string{string(engine.ColdStartQueue), string(engine.ColdStartLoading)}
+)
+
+func (c *Config) validate() []error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	for _, setting := range []struct{ name, list string }{
+		{"manager.listen", c.Manager.Listen},
+		{"manager.admin_listen", c.Manager.AdminListen},
+		{"manager.metrics_listen", c.Manager.MetricsListen},
+		{"manager.http_redirect", c.Manager.HTTPRedirect},
+	} {
+		if _, err := listener.ParseList(setting.list); err != nil {
+			invalid("%s: %v", setting.name, err)
+		}
+	}
+
+	if c.Manager.APIKeys != "" {
+		if info, err := os.Stat(c.Manager.APIKeys); err != nil || info.IsDir() {
+			invalid("manager.api_keys: %s is not a file", c.Manager.APIKeys)
+		}
+	}
+	if c.Manager.TLS != "" && !slices.Contains(tlsModes, c.Manager.TLS) {
+		invalid("manager.tls: %q is not one of %v", c.Manager.TLS, tlsModes)
+	}
+	if (c.Manager.TLSCert == "") != (c.Manager.TLSKey == "") {
+		invalid("manager.tls_cert and manager.tls_key must be set together")
+	}
+	for _, file := range []struct{ name, path string }{{"tls_cert", c.Manager.TLSCert}, {"tls_key", c.Manager.TLSKey}} {
+		if file.path == "" {
+			continue
+		}
+		if c.Manager.TLS != "" && c.Manager.TLS != "off" {
+			invalid("manager.%s: cannot be combined with tls: %s", file.name, c.Manager.TLS)
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			invalid("manager.%s: %s does not exist", file.name, file.path)
+		}
+	}
+	if c.Manager.TLS == "acme" && strings.TrimSpace(c.Manager.TLSHosts) == "" {
+		invalid("manager.tls_hosts: acme needs the host names to order a certificate for")
+	}
+	if c.Manager.ACMEDirectory != "" {
+		if u, err := url.Parse(c.Manager.ACMEDirectory); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("manager.acme_directory: %q is not an http(s) URL", c.Manager.ACMEDirectory)
+		}
+	}
+	if c.Manager.MaxBodyBytes < 0 {
+		invalid("manager.max_body_bytes: must not be negative")
+	}
+
+	if c.Worker.Script != "" {
+		if info, err := os.Stat(c.Worker.Script); err != nil || info.IsDir() {
+			invalid("worker.script: %s is not a file", c.Worker.Script)
+		}
+	}
+	if c.Worker.Venv != "" {
+		if info, err := os.Stat(c.Worker.Venv); err != nil || !info.IsDir() {
+			invalid("worker.venv: %s is not a directory", c.Worker.Venv)
+		}
+	}
+	if strings.ContainsRune(c.Worker.Python, filepath.Separator) {
+		if _, err := os.Stat(c.Worker.Python); err != nil {
+			invalid("worker.python: %s does not exist", c.Worker.Python)
+		}
+	}
+	if c.Worker.Port < 0 || c.Worker.Port > 65535 {
+		invalid("worker.port: %d is not a valid port", c.Worker.Port)
+	}
+
+	if c.Worker.Runtime != "" && !slices.Contains(workerRuntimes, c.Worker.Runtime) {
+		invalid("worker.runtime: %q is not one of %v", c.Worker.Runtime, workerRuntimes)
+	}
+	if c.Worker.Protocol != "" && !slices.Contains(workerProtocols, c.Worker.Protocol) {
+		invalid("worker.protocol: %q is not one of %v", c.Worker.Protocol, workerProtocols)
+	}
+	if c.Worker.Bootstrap != "" && !slices.Contains(bootstrapModes, c.Worker.Bootstrap) {
+		invalid("worker.bootstrap: %q is not one of %v", c.Worker.Bootstrap, bootstrapModes)
+	}
+	if c.Manager.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.Manager.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("manager.otlp_endpoint: %q is not an http(s) URL", c.Manager.OTLPEndpoint)
+		}
+	}
+	if c.Worker.RemoteURL != "" {
+		if u, err := url.Parse(c.Worker.RemoteURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("worker.remote_url: %q is not an http(s) URL", c.Worker.RemoteURL)
+		}
+	}
+	if _, err := profiler.ParseGPUAssignments(c.Worker.GPUs); err != nil {
+		invalid("worker.gpus: %v", err)
+	}
+	if c.Worker.RemoteHeader != "" && !strings.Contains(c.Worker.RemoteHeader, ":") {
+		invalid("worker.remote_header: want \"Name: value\", got %q", c.Worker.RemoteHeader)
+	}
+	for _, file := range []struct{ name, path string }{
+		{"remote_ca", c.Worker.RemoteCA}, {"remote_cert", c.Worker.RemoteCert}, {"remote_key", c.Worker.RemoteKey},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			invalid("worker.%s: %s does not exist", file.name, file.path)
+		}
+	}
+	if (c.Worker.RemoteCert == "") != (c.Worker.RemoteKey == "") {
+		invalid("worker.remote_cert and worker.remote_key are set together")
+	}
+	if strings.ContainsRune(c.Worker.LlamaServer, filepath.Separator) {
+		if _, err := os.Stat(c.Worker.LlamaServer); err != nil {
+			invalid("worker.llama_server: %s does not exist", c.Worker.LlamaServer)
+		}
+	}
+
+	if c.Engine.TierDefaults != "" && !slices.Contains(onOff, c.Engine.TierDefaults) {
+		invalid("engine.tier_defaults: %q is not one of %v", c.Engine.TierDefaults, onOff)
+	}
+	if _, err := engine.ParseIdleTimeouts(c.Engine.IdleTimeout); err != nil {
+		invalid("engine.idle_timeout: %v", err)
+	}
+	if c.Engine.ColdStart != "" && !slices.Contains(coldStarts, c.Engine.ColdStart) {
+		invalid("engine.cold_start: %q is not one of %v", c.Engine.ColdStart, coldStarts)
+	}
+	if _, err := profiler.ParseModelPolicy(c.Engine.ModelPolicy); err != nil {
+		invalid("engine.model_policy: %v", err)
+	}
+	if c.Engine.Override != "" && !slices.Contains(profiler.Engines, profiler.Engine(c.Engine.Override)) {
+		invalid("engine.override: unknown engine %q (want one of %v)", c.Engine.Override, profiler.Engines)
+	}
+	if c.Engine.ModelSizeGB <= 0 {
+		invalid("engine.model_size_gb: must be positive")
+	}
+	if c.Engine.ContextLength < 0 {
+		invalid("engine.context_length: must not be negative")
+	}
+	if c.Engine.Concurrency < 0 {
+		invalid("engine.concurrency: must not be negative")
+	}
+	if c.Engine.Preference != "" && !slices.Contains(profiler.Preferences, profiler.Preference(c.Engine.Preference)) {
+		invalid("engine.preference: %q is not one of %v", c.Engine.Preference, profiler.Preferences)
+	}
+	if c.Engine.ModelDir != "" {
+		if info, err := os.Stat(c.Engine.ModelDir); err != nil || !info.IsDir() {
+			invalid("engine.model_dir: %s is not a directory", c.Engine.ModelDir)
+		}
+	}
+	if c.Registry != "" {
+		if _, err := os.Stat(c.Registry); err != nil {
+			invalid("registry: %s does not exist", c.Registry)
+		}
+	}
+	if c.Remote.URL != "" {
+		if u, err := url.Parse(c.Remote.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("registry_remote.url: %q is not an http(s) URL", c.Remote.URL)
+		}
+	}
+	if c.Remote.PublicKey != "" {
+		if key, err := base64.StdEncoding.DecodeString(c.Remote.PublicKey); err != nil || len(key) != ed25519.PublicKeySize {
+			invalid("registry_remote.public_key: not a base64 Ed25519 public key")
+		}
+	}
+	if c.Remote.Refresh < 0 {
+		invalid("registry_remote.refresh: must not be negative")
+	}
+	if !slices.Contains(logLevels, c.LogLevel) {
+		invalid("log_level: %q is not one of %v", c.LogLevel, logLevels)
+	}
+	if !slices.Contains(logFormats, c.LogFormat) {
+		invalid("log_format: %q is not one of %v", c.LogFormat, logFormats)
+	}
+	if c.Timeouts.WorkerReady < 0 || c.Timeouts.Shutdown < 0 || c.Timeouts.WorkerStop < 0 ||
+		c.Timeouts.Request < 0 {
+		invalid("timeouts: durations must not be negative")
+	}
+	return errs
+}
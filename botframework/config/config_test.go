@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "botframework.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileWithEnvironmentOverrides(t *testing.T) {
+	venv := t.TempDir()
+	os.MkdirAll(filepath.Join(venv, "bin"), 0o755)
+	os.WriteFile(filepath.Join(venv, "bin", "python"), nil, 0o755)
+	path := writeConfig(t, `# manager settings
+manager:
+  listen: "0.0.0.0:9090, unix:/tmp/bf.sock"
+  admin_listen: 127.0.0.1:9000   # loopback only
+worker:
+  venv: `+venv+`
+  port: 9191
+engine:
+  override: vllm
+  model_size_gb: 13.5
+log_level: warn
+timeouts:
+  worker_ready: 5m
+  request: 90s
+`)
+	t.Setenv("BOTFRAMEWORK_WORKER_PORT", "9292")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Manager.Listen != "0.0.0.0:9090, unix:/tmp/bf.sock" || cfg.Manager.AdminListen != "127.0.0.1:9000" {
+		t.Fatalf("unexpected listeners: %+v", cfg.Manager)
+	}
+	if cfg.Worker.Port != 9292 {
+		t.Fatalf("expected the environment to override the port, got %d", cfg.Worker.Port)
+	}
+	if cfg.Worker.Python != filepath.Join(venv, "bin", "python") {
+		t.Fatalf("expected the venv interpreter, got %q", cfg.Worker.Python)
+	}
+	if cfg.Engine.Override != "vllm" || cfg.Engine.ModelSizeGB != 13.5 || cfg.LogLevel != "warn" {
+		t.Fatalf("unexpected settings: %+v", cfg)
+	}
+	if cfg.Timeouts.WorkerReady != 5*time.Minute || cfg.Timeouts.Shutdown != 5*time.Second || cfg.Timeouts.Request != 90*time.Second {
+		t.Fatalf("unexpected timeouts: %+v", cfg.Timeouts)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, `
+manager:
+  tls: acme
+  tls_cert: missing.crt
+worker:
+  port: 70000
+  remote_url: gpu-box:8000
+  gpus: "fast=0,0"
+engine:
+  override: tensorrt
+  idle_timeout: soon
+log_level: loud
+`)
+	t.Setenv("BOTFRAMEWORK_SHUTDOWN_TIMEOUT", "soon")
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"BOTFRAMEWORK_SHUTDOWN_TIMEOUT", "worker.port", "worker.remote_url", "worker.gpus", "engine.override", "engine.idle_timeout", "log_level", "manager.tls_cert", "manager.tls_hosts"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %s in %v", want, err)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeConfig(t, "manager:\n  lisen: :8080\n")
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "manager.lisen: unknown key") {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestExportSetsConfiguredSettings(t *testing.T) {
+	t.Setenv("BOTFRAMEWORK_LISTEN", "")
+	t.Setenv("BOTFRAMEWORK_WORKER_READY_TIMEOUT", "")
+	cfg := Defaults()
+	cfg.Manager.Listen = ":9999"
+	cfg.Timeouts.WorkerReady = 90 * time.Second
+	cfg.Export()
+
+	if got := os.Getenv("BOTFRAMEWORK_LISTEN"); got != ":9999" {
+		t.Fatalf("expected listen exported, got %q", got)
+	}
+	if got := os.Getenv("BOTFRAMEWORK_WORKER_READY_TIMEOUT"); got != "1m30s" {
+		t.Fatalf("expected timeout exported, got %q", got)
+	}
+}
@@ -0,0 +1,302 @@
+// Package yaml decodes the small part of YAML botframework's configuration files use into
+// structs with `yaml` field tags.
+package yaml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The manager's configuration needs only a small part of YAML, so this package implements
+// that subset rather than pulling in a dependency: nested mappings, scalars (plain,
+// "double" or 'single' quoted), block sequences of scalars ("- item"), flow sequences
+// ([a, b]) and comments. Anchors, multi-line strings and multiple documents are not
+// supported.
+
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// Unmarshal parses data and stores it into the struct v points at
+func Unmarshal(data []byte, v any) error {
+	tree, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	return Decode(tree, reflect.ValueOf(v).Elem(), "")
+}
+
+// Parse returns a tree of map[string]any, []any and string scalars
+func Parse(data string) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(raw, "---") && len(lines) == 0 {
+			continue
+		}
+		text := stripComment(raw)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: strings.TrimRight(trimmed, " \t")})
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+	node, next, err := parseBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].number)
+	}
+	return node, nil
+}
+
+// stripComment drops a # comment that starts the line or follows whitespace outside quotes
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseBlock(lines []yamlLine, i, indent int) (any, int, error) {
+	if lines[i].text == "-" || strings.HasPrefix(lines[i].text, "- ") {
+		return parseSequence(lines, i, indent)
+	}
+	return parseMapping(lines, i, indent)
+}
+
+func parseSequence(lines []yamlLine, i, indent int) (any, int, error) {
+	var items []any
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		if line.text != "-" && !strings.HasPrefix(line.text, "- ") {
+			break
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if item == "" || isMappingEntry(item) {
+			return nil, i, fmt.Errorf("line %d: only lists of scalars are supported", line.number)
+		}
+		value, err := parseScalar(item, line.number)
+		if err != nil {
+			return nil, i, err
+		}
+		items = append(items, value)
+		i++
+	}
+	return items, i, nil
+}
+
+func parseMapping(lines []yamlLine, i, indent int) (any, int, error) {
+	mapping := make(map[string]any)
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		key, rest, ok := cutMappingEntry(line.text)
+		if !ok {
+			return nil, i, fmt.Errorf("line %d: expected \"key: value\"", line.number)
+		}
+		if _, dup := mapping[key]; dup {
+			return nil, i, fmt.Errorf("line %d: duplicate key %q", line.number, key)
+		}
+		i++
+		if rest != "" {
+			value, err := parseScalar(rest, line.number)
+			if err != nil {
+				return nil, i, err
+			}
+			mapping[key] = value
+			continue
+		}
+		// a nested block is indented further; block sequences may also sit at the key's indent
+		if i < len(lines) && (lines[i].indent > indent || lines[i].indent == indent && strings.HasPrefix(lines[i].text, "- ")) {
+			value, next, err := parseBlock(lines, i, lines[i].indent)
+			if err != nil {
+				return nil, i, err
+			}
+			mapping[key], i = value, next
+			continue
+		}
+		mapping[key] = ""
+	}
+	if i < len(lines) && lines[i].indent > indent {
+		return nil, i, fmt.Errorf("line %d: unexpected indentation", lines[i].number)
+	}
+	return mapping, i, nil
+}
+
+func isMappingEntry(text string) bool {
+	_, _, ok := cutMappingEntry(text)
+	return ok && text[0] != '"' && text[0] != '\''
+}
+
+func cutMappingEntry(text string) (key, rest string, ok bool) {
+	if i := strings.Index(text, ": "); i > 0 {
+		return unquoteKey(text[:i]), strings.TrimSpace(text[i+2:]), true
+	}
+	if strings.HasSuffix(text, ":") && len(text) > 1 {
+		return unquoteKey(text[:len(text)-1]), "", true
+	}
+	return "", "", false
+}
+
+func unquoteKey(key string) string {
+	key = strings.TrimSpace(key)
+	if unquoted, err := strconv.Unquote(key); err == nil {
+		return unquoted
+	}
+	return key
+}
+
+func parseScalar(text string, line int) (any, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: unterminated list", line)
+		}
+		items := []any{}
+		for _, item := range strings.Split(text[1:len(text)-1], ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				value, err := parseScalar(item, line)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, value)
+			}
+		}
+		return items, nil
+	case strings.HasPrefix(text, "\""):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", line, text)
+		}
+		return value, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", line, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case text == "~" || text == "null":
+		return "", nil
+	}
+	return text, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Decode stores a parsed tree into the struct v points at, matching `yaml` field tags.
+// Unknown keys are errors so typos do not silently fall back to defaults.
+func Decode(node any, v reflect.Value, path string) error {
+	if v.Kind() == reflect.Struct {
+		mapping, ok := node.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a mapping", pathOr(path))
+		}
+		fields := make(map[string]reflect.Value)
+		for i := 0; i < v.NumField(); i++ {
+			if name := v.Type().Field(i).Tag.Get("yaml"); name != "" {
+				fields[name] = v.Field(i)
+			}
+		}
+		for key, child := range mapping {
+			field, ok := fields[key]
+			if !ok {
+				return fmt.Errorf("%s: unknown key", join(path, key))
+			}
+			if err := Decode(child, field, join(path, key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if v.Kind() == reflect.Slice {
+		items, ok := node.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a list", path)
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := Decode(item, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	text, ok := node.(string)
+	if !ok {
+		return fmt.Errorf("%s: expected a single value", path)
+	}
+	if err := SetScalar(v, text); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// SetScalar parses text into v, which is a string, bool, number or time.Duration
+func SetScalar(v reflect.Value, text string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (use e.g. 30s or 2m)", text)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(text)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", text)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", text)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", text)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func pathOr(path string) string {
+	if path == "" {
+		return "config"
+	}
+	return path
+}
@@ -0,0 +1,35 @@
+package yaml
+
+import "testing"
+
+func TestParseYAML(t *testing.T) {
+	tree, err := Parse(`
+a:
+  b: 'it''s'
+  list:
+  - one
+  - "two # not a comment"
+  flow: [x, y]
+c: ~
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := tree.(map[string]any)["a"].(map[string]any)
+	if a["b"] != "it's" {
+		t.Fatalf("unexpected b: %v", a["b"])
+	}
+	if list := a["list"].([]any); len(list) != 2 || list[1] != "two # not a comment" {
+		t.Fatalf("unexpected list: %v", list)
+	}
+	if flow := a["flow"].([]any); len(flow) != 2 || flow[0] != "x" {
+		t.Fatalf("unexpected flow list: %v", flow)
+	}
+	if tree.(map[string]any)["c"] != "" {
+		t.Fatal("expected null to decode as empty")
+	}
+
+	if _, err := Parse("a: 1\n    b: 2\n"); err == nil {
+		t.Fatal("expected indentation error")
+	}
+}
@@ -0,0 +1,295 @@
+// Package convert turns model weights into the format an engine loads, by shelling out to
+// the engine's own converter: mlx_lm.convert for MLX and AutoAWQ for AWQ. GGUF files are
+// dequantized into a Hugging Face checkpoint with transformers first.
+package convert
+
+import (
+	"botframework/profiler"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Target is a format weights are converted to
+type Target string
+
+const (
+	TargetMLX Target = "mlx"
+	TargetAWQ Target = "awq"
+)
+
+// ErrUnsupported is returned for conversions no converter performs
+var ErrUnsupported = errors.New("unsupported conversion")
+
+// TargetFor returns the format to convert to for engine: MLX for MLX, AWQ for the engines
+// that load Hugging Face checkpoints
+func TargetFor(engine profiler.Engine) (Target, bool) {
+	switch engine {
+	case profiler.EngineMLX:
+		return TargetMLX, true
+	case profiler.EngineVLLM, profiler.EngineIPEXLLM:
+		return TargetAWQ, true
+	}
+	return "", false
+}
+
+// Quant names the variant converting to t at bits produces, as the registry lists it:
+// MLX-Q4 or AWQ
+func (t Target) Quant(bits int) string {
+	if t == TargetMLX {
+		return fmt.Sprintf("MLX-Q%d", bits)
+	}
+	return strings.ToUpper(string(t))
+}
+
+// Progress reports a conversion's current step and how far the whole conversion is, 0 to 1
+type Progress struct {
+	Step     string
+	Fraction float64
+}
+
+// Converter runs conversions with a Python interpreter that has the converters installed
+type Converter struct {
+	Python string
+	// Bits is the weight width converted to (default: 4)
+	Bits     int
+	Progress func(Progress)
+
+	// run executes python with args, passing each line it prints to line; replaced in tests
+	run func(ctx context.Context, args []string, line func(string)) error
+}
+
+func New(python string) *Converter {
+	if python == "" {
+		python = "python3"
+	}
+	c := &Converter{Python: python, Bits: 4}
+	c.run = c.runPython
+	return c
+}
+
+// Output is where Convert caches source converted to target when no destination is
+// chosen: next to source, as "<name>.<quant>" with the extension dropped
+func (c *Converter) Output(source string, target Target) string {
+	base := strings.TrimSuffix(source, filepath.Ext(source))
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		base = strings.TrimRight(source, string(filepath.Separator))
+	}
+	return base + "." + strings.ToLower(target.Quant(c.bits()))
+}
+
+// Convert writes source, a GGUF file or a Hugging Face checkpoint directory, to dest in the
+// target format and returns dest. A completed conversion already at dest is reused;
+// the work happens in dest.partial, moved into place once the converter succeeds.
+func (c *Converter) Convert(ctx context.Context, source, dest string, target Target) (string, error) {
+	model, err := profiler.InspectModel(source)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case model.Format == profiler.FormatMLX && target == TargetMLX,
+		model.Format == profiler.FormatSafetensors && model.Quant == "awq" && target == TargetAWQ:
+		return "", fmt.Errorf("%w: %s is already %s", ErrUnsupported, filepath.Base(source), model)
+	case model.Format != profiler.FormatGGUF && model.Format != profiler.FormatSafetensors:
+		return "", fmt.Errorf("%w: cannot convert %s weights (%s) to %s; start from GGUF or safetensors weights",
+			ErrUnsupported, formatName(model), filepath.Base(source), target)
+	case target != TargetMLX && target != TargetAWQ:
+		return "", fmt.Errorf("%w: unknown target %q (want mlx or awq)", ErrUnsupported, target)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "config.json")); err == nil {
+		return dest, nil
+	}
+	if err := c.checkModules(ctx, target, model.Format == profiler.FormatGGUF); err != nil {
+		return "", err
+	}
+
+	partial := dest + ".partial"
+	if err := os.RemoveAll(partial); err != nil {
+		return "", err
+	}
+	steps := 1
+	checkpoint := source
+	if model.Format == profiler.FormatGGUF {
+		// the converters read Hugging Face checkpoints, so GGUF weights are dequantized to
+		// f16 first; the checkpoint is dropped once converted
+		steps = 2
+		checkpoint = dest + ".f16"
+		defer os.RemoveAll(checkpoint)
+		if _, err := os.Stat(filepath.Join(checkpoint, "config.json")); err != nil {
+			os.RemoveAll(checkpoint)
+			args := []string{"-c", dequantizeScript, filepath.Dir(source), filepath.Base(source), checkpoint}
+			if err := c.step(ctx, "dequantize", 0, steps, args); err != nil {
+				os.RemoveAll(checkpoint)
+				return "", err
+			}
+		}
+	}
+
+	var args []string
+	switch target {
+	case TargetMLX:
+		// mlx_lm.convert refuses to write into an existing directory
+		args = []string{"-m", "mlx_lm.convert", "--hf-path", checkpoint, "--mlx-path", partial,
+			"-q", "--q-bits", strconv.Itoa(c.bits())}
+	case TargetAWQ:
+		args = []string{"-c", awqScript, checkpoint, partial, strconv.Itoa(c.bits())}
+	}
+	if err := c.step(ctx, "quantize", steps-1, steps, args); err != nil {
+		os.RemoveAll(partial)
+		return "", err
+	}
+	if err := os.Rename(partial, dest); err != nil {
+		return "", err
+	}
+	c.report(Progress{Step: "done", Fraction: 1})
+	return dest, nil
+}
+
+func (c *Converter) bits() int {
+	if c.Bits <= 0 {
+		return 4
+	}
+	return c.Bits
+}
+
+// checkModules fails early, naming the package to install, when the interpreter lacks a
+// converter the conversion needs
+func (c *Converter) checkModules(ctx context.Context, target Target, gguf bool) error {
+	modules := map[Target][]string{TargetMLX: {"mlx_lm"}, TargetAWQ: {"awq", "transformers"}}[target]
+	if gguf {
+		for _, module := range []string{"transformers", "gguf"} {
+			if !slices.Contains(modules, module) {
+				modules = append(modules, module)
+			}
+		}
+	}
+	packages := map[string]string{"mlx_lm": "mlx-lm", "awq": "autoawq", "transformers": "transformers", "gguf": "gguf"}
+	for _, module := range modules {
+		if err := c.run(ctx, []string{"-c", "import " + module}, func(string) {}); err != nil {
+			return fmt.Errorf("%s cannot import %s (pip install %s): %w", c.Python, module, packages[module], err)
+		}
+	}
+	return nil
+}
+
+// step runs one converter invocation, scaling the percentages it prints into the overall
+// progress of step index out of steps
+func (c *Converter) step(ctx context.Context, name string, index, steps int, args []string) error {
+	c.report(Progress{Step: name, Fraction: float64(index) / float64(steps)})
+	var tail []string
+	err := c.run(ctx, args, func(line string) {
+		if match := percentPattern.FindStringSubmatch(line); match != nil {
+			percent, _ := strconv.Atoi(match[1])
+			c.report(Progress{Step: name, Fraction: (float64(index) + float64(min(percent, 100))/100) / float64(steps)})
+		}
+		if tail = append(tail, line); len(tail) > 5 {
+			tail = tail[1:]
+		}
+	})
+	if err != nil {
+		if len(tail) > 0 {
+			return fmt.Errorf("%s failed: %w: %s", name, err, strings.Join(tail, "; "))
+		}
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+func (c *Converter) report(p Progress) {
+	if c.Progress != nil {
+		c.Progress(p)
+	}
+}
+
+// percentPattern matches the progress bars tqdm draws, "Quantizing:  45%|████"
+var percentPattern = regexp.MustCompile(`(\d{1,3})%\|`)
+
+func (c *Converter) runPython(ctx context.Context, args []string, line func(string)) error {
+	cmd := exec.CommandContext(ctx, c.Python, args...)
+	reader, writer := io.Pipe()
+	cmd.Stdout, cmd.Stderr = writer, writer
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		// tqdm redraws its bar with carriage returns
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+				return i + 1, data[:i], nil
+			}
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		})
+		for scanner.Scan() {
+			if text := strings.TrimSpace(scanner.Text()); text != "" {
+				line(text)
+			}
+		}
+		io.Copy(io.Discard, reader)
+	}()
+	err := cmd.Wait()
+	writer.Close()
+	<-done
+	return err
+}
+
+// SizeGB is the size of the weights under dir
+func SizeGB(dir string) float64 {
+	var size int64
+	filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			if info, err := entry.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return float64(size) / (1 << 30)
+}
+
+func formatName(model profiler.ModelFiles) string {
+	if model.Format == "" {
+		return "unrecognised"
+	}
+	return model.String()
+}
+
+// dequantizeScript loads a GGUF file with transformers, which dequantizes it, and saves
+// it as an f16 Hugging Face checkpoint: dir, file name, output directory
+const dequantizeScript = `import sys
+from transformers import AutoModelForCausalLM, AutoTokenizer
+src, name, out = sys.argv[1:4]
+AutoTokenizer.from_pretrained(src, gguf_file=name).save_pretrained(out)
+model = AutoModelForCausalLM.from_pretrained(src, gguf_file=name, torch_dtype="float16")
+model.save_pretrained(out, safe_serialization=True)
+`
+
+// awqScript quantizes a Hugging Face checkpoint with AutoAWQ, which needs a CUDA GPU:
+// checkpoint, output directory, bits
+const awqScript = `import sys
+from awq import AutoAWQForCausalLM
+from transformers import AutoTokenizer
+src, out, bits = sys.argv[1], sys.argv[2], int(sys.argv[3])
+model = AutoAWQForCausalLM.from_pretrained(src, safetensors=True)
+tokenizer = AutoTokenizer.from_pretrained(src)
+model.quantize(tokenizer, quant_config={"zero_point": True, "q_group_size": 128, "w_bit": bits, "version": "GEMM"})
+model.save_quantized(out)
+tokenizer.save_pretrained(out)
+`
@@ -0,0 +1,131 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeConverter records the commands it is asked to run and writes what the real
+// converters would
+func fakeConverter(t *testing.T, fail string) (*Converter, *[][]string, *[]Progress) {
+	t.Helper()
+	var calls [][]string
+	var progress []Progress
+	c := New("python3")
+	c.Progress = func(p Progress) { progress = append(progress, p) }
+	c.run = func(_ context.Context, args []string, line func(string)) error {
+		calls = append(calls, args)
+		if args[0] == "-c" && strings.HasPrefix(args[1], "import ") && !strings.Contains(args[1], "\n") {
+			if args[1] == "import "+fail {
+				return errors.New("exit status 1")
+			}
+			return nil
+		}
+		line("Loading checkpoint shards:  50%|█████     | 1/2")
+		line("Loading checkpoint shards: 100%|██████████| 2/2")
+		out := args[len(args)-1]
+		switch {
+		case slices.Contains(args, "mlx_lm.convert"):
+			out = args[slices.Index(args, "--mlx-path")+1]
+		case strings.Contains(args[1], "AutoAWQ"):
+			out = args[3]
+		}
+		os.MkdirAll(out, 0o755)
+		return os.WriteFile(filepath.Join(out, "config.json"), []byte(`{}`), 0o644)
+	}
+	return c, &calls, &progress
+}
+
+func TestConvertGGUFToMLX(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "llama-3-8b.Q8_0.gguf")
+	os.WriteFile(source, []byte("GGUF"), 0o644)
+	c, calls, progress := fakeConverter(t, "")
+
+	dest := c.Output(source, TargetMLX)
+	if dest != filepath.Join(dir, "llama-3-8b.Q8_0.mlx-q4") {
+		t.Errorf("Output() = %s", dest)
+	}
+	got, err := c.Convert(context.Background(), source, dest, TargetMLX)
+	if err != nil || got != dest {
+		t.Fatalf("Convert() = %s, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "config.json")); err != nil {
+		t.Errorf("converted model missing: %v", err)
+	}
+	for _, leftover := range []string{dest + ".partial", dest + ".f16"} {
+		if _, err := os.Stat(leftover); err == nil {
+			t.Errorf("%s left behind", leftover)
+		}
+	}
+
+	var commands []string
+	for _, args := range *calls {
+		commands = append(commands, strings.SplitN(args[1], "\n", 2)[0])
+	}
+	want := []string{"import mlx_lm", "import transformers", "import gguf", "import sys", "mlx_lm.convert"}
+	if !slices.Equal(commands, want) {
+		t.Errorf("ran %v, want %v", commands, want)
+	}
+	if quantize := (*calls)[4]; !slices.Contains(quantize, dest+".f16") || !slices.Contains(quantize, "--q-bits") {
+		t.Errorf("mlx_lm.convert args %v", quantize)
+	}
+	fractions := make([]float64, len(*progress))
+	for i, p := range *progress {
+		fractions[i] = p.Fraction
+	}
+	if !slices.Equal(fractions, []float64{0, 0.25, 0.5, 0.5, 0.75, 1, 1}) || (*progress)[3].Step != "quantize" {
+		t.Errorf("progress %+v", *progress)
+	}
+
+	// a finished conversion is reused without running anything
+	*calls = nil
+	if _, err := c.Convert(context.Background(), source, dest, TargetMLX); err != nil || len(*calls) != 0 {
+		t.Errorf("cached conversion: %v, ran %v", err, *calls)
+	}
+}
+
+func TestConvertCheckpointToAWQ(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "F16")
+	os.Mkdir(source, 0o755)
+	os.WriteFile(filepath.Join(source, "config.json"), []byte(`{"torch_dtype": "float16"}`), 0o644)
+	c, calls, _ := fakeConverter(t, "")
+
+	dest := c.Output(source, TargetAWQ)
+	if _, err := c.Convert(context.Background(), source, dest, TargetAWQ); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(*calls); n != 3 || !strings.Contains((*calls)[2][1], "AutoAWQ") || (*calls)[2][2] != source {
+		t.Errorf("a checkpoint is quantized directly: %v", *calls)
+	}
+	if _, err := c.Convert(context.Background(), dest, dest+"-again", TargetAWQ); err != nil {
+		t.Errorf("converting the output again: %v", err)
+	}
+}
+
+func TestConvertFailsEarly(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "phi-3.Q4_K_M.gguf")
+	os.WriteFile(source, []byte("GGUF"), 0o644)
+
+	c, calls, _ := fakeConverter(t, "mlx_lm")
+	_, err := c.Convert(context.Background(), source, c.Output(source, TargetMLX), TargetMLX)
+	if err == nil || !strings.Contains(err.Error(), "pip install mlx-lm") || len(*calls) != 1 {
+		t.Errorf("missing converter: %v after %v", err, *calls)
+	}
+
+	mlx := filepath.Join(dir, "phi-mlx")
+	os.Mkdir(mlx, 0o755)
+	os.WriteFile(filepath.Join(mlx, "config.json"), []byte(`{"quantization": {"bits": 4}}`), 0o644)
+	if _, err := c.Convert(context.Background(), mlx, mlx+".awq", TargetAWQ); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("MLX weights to AWQ: %v", err)
+	}
+	if _, err := c.Convert(context.Background(), source, source+".exl2", "exl2"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unknown target: %v", err)
+	}
+}
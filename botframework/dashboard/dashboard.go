@@ -0,0 +1,342 @@
+package dashboard
+
+import (
+	"botframework/api"
+	"botframework/client"
+	"botframework/engine"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const clearScreen = "\033[H\033[2J"
+
+// Frame is what one redraw of the dashboard shows
+type Frame struct {
+	BaseURL string
+	Status  *api.AdminStatus
+	// Workers lists every worker; nil falls back to the status's default worker, for
+	// managers without /admin/workers
+	Workers []api.WorkerInfo
+	// TokensPerSecond is the streamed token rate since the previous frame
+	TokensPerSecond float64
+	// Selected is the worker the keys act on
+	Selected int
+	// Prompt is the swap prompt being typed, shown while Typing
+	Prompt string
+	Typing bool
+	// Message reports the last action
+	Message string
+}
+
+// Render writes one frame of the dashboard
+func Render(w io.Writer, frame Frame) {
+	status := frame.Status
+	fmt.Fprintf(w, "BotFramework top — %s (up %s)\n\n", frame.BaseURL, formatUptime(status.UptimeSeconds))
+
+	t := status.Traffic
+	fmt.Fprintln(w, "TRAFFIC")
+	fmt.Fprintf(w, "  req/s: %-8.2f in-flight: %-5d total: %-8d errors: %d\n",
+		t.RequestsPerSecond, t.InFlight, t.TotalRequests, t.ErrorCount)
+	fmt.Fprintf(w, "  latency p50: %-8.0fms p95: %-8.0fms ttft avg: %.0fms\n",
+		t.LatencyP50Ms, t.LatencyP95Ms, t.TTFTAvgMs)
+	fmt.Fprintf(w, "  streamed tok/s: %-8.1f streamed tokens: %d\n\n", frame.TokensPerSecond, t.StreamTokens)
+
+	u := status.Usage
+	fmt.Fprintln(w, "RESOURCES")
+	fmt.Fprintf(w, "  RAM  %s %d/%d MB\n", bar(u.RAMUsedMB, u.RAMTotalMB), u.RAMUsedMB, u.RAMTotalMB)
+	if u.VRAMTotalMB > 0 {
+		fmt.Fprintf(w, "  VRAM %s %d/%d MB (gpu %d%%)\n", bar(u.VRAMUsedMB, u.VRAMTotalMB), u.VRAMUsedMB, u.VRAMTotalMB, u.GPUUtilPercent)
+	} else {
+		fmt.Fprintln(w, "  VRAM n/a")
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "QUEUES")
+	if len(status.Queues) == 0 {
+		fmt.Fprintln(w, "  idle")
+	} else {
+		fmt.Fprintf(w, "  %-20s %-10s %-8s %-12s %-11s %s\n", "MODEL", "IN-FLIGHT", "QUEUED", "INTERACTIVE", "BACKGROUND", "REJECTED")
+	}
+	for _, q := range status.Queues {
+		fmt.Fprintf(w, "  %-20s %-10s %-8d %-12d %-11d %d\n", q.Model, fmt.Sprintf("%d/%d", q.InFlight, q.Capacity), q.Queued,
+			q.QueuedByPriority[engine.PriorityInteractive], q.QueuedByPriority[engine.PriorityBackground], q.Rejected)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "WORKERS")
+	if frame.Workers == nil {
+		fmt.Fprintf(w, "  %-16s %-12s %s\n", "NAME", "STATUS", "MODEL")
+		for _, worker := range status.Workers {
+			fmt.Fprintf(w, "  %-16s %-12s %s\n", worker.Name, worker.Status, worker.Model)
+			if worker.Error != "" {
+				fmt.Fprintf(w, "    ! %s\n", worker.Error)
+			}
+		}
+	} else {
+		fmt.Fprintf(w, "  %-16s %-10s %-12s %-8s %-10s %-8s %-8s %-9s %s\n", "ID", "STATE", "HEALTH", "PID", "UPTIME", "RESTARTS", "TOK/S", "MEM", "MODEL")
+	}
+	for i, worker := range frame.Workers {
+		marker := " "
+		if i == frame.Selected {
+			marker = ">"
+		}
+		health := worker.Health
+		if worker.Liveness != "" && worker.Liveness != "healthy" {
+			health += "/" + worker.Liveness
+		}
+		fmt.Fprintf(w, "%s %-16s %-10s %-12s %-8s %-10s %-8d %-8s %-9s %s\n", marker, worker.ID, worker.State, health,
+			orDash(worker.PID), formatUptime(worker.UptimeSeconds), worker.Restarts, rate(worker.TokensPerSecond), megabytes(worker.MemoryBytes), worker.Model)
+		if worker.Error != "" {
+			fmt.Fprintf(w, "    ! %s\n", worker.Error)
+		}
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "RECENT ERRORS")
+	if len(t.RecentErrors) == 0 {
+		fmt.Fprintln(w, "  none")
+	}
+	for i := len(t.RecentErrors) - 1; i >= 0 && i >= len(t.RecentErrors)-5; i-- {
+		e := t.RecentErrors[i]
+		fmt.Fprintf(w, "  %s %d %s %s\n", e.Time.Format("15:04:05"), e.Status, e.Method, e.Path)
+	}
+	fmt.Fprintln(w)
+
+	switch {
+	case frame.Typing:
+		fmt.Fprintf(w, "swap the default model to: %s_  (enter to swap, esc to cancel)\n", frame.Prompt)
+	case frame.Message != "":
+		fmt.Fprintln(w, frame.Message)
+	}
+	fmt.Fprintln(w, "↑/↓ select  r restart  s stop  m swap model  q quit")
+}
+
+// Keys are the dashboard's key presses, read from a terminal in cbreak mode
+const (
+	keyUp    = "up"
+	keyDown  = "down"
+	keyEnter = "enter"
+	keyEsc   = "esc"
+	keyBack  = "backspace"
+)
+
+// Run redraws the dashboard every interval until ctx is cancelled or q is pressed. Keys
+// are read from in, nil for a display-only dashboard: arrows or j/k select a worker, r
+// restarts it, s stops it and m prompts for a model to hot-swap the default model to.
+func Run(ctx context.Context, in io.Reader, out io.Writer, c *client.Client, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var keys <-chan string
+	if in != nil {
+		keys = readKeys(in)
+	}
+	results := make(chan string, 1)
+	d := &dashboard{client: c, frame: Frame{BaseURL: c.BaseURL}}
+	d.refresh()
+
+	for {
+		d.draw(out)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			d.refresh()
+		case message := <-results:
+			d.frame.Message = message
+			d.refresh()
+		case key, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			if !d.handle(key, results) {
+				return nil
+			}
+		}
+	}
+}
+
+// dashboard holds the state between frames
+type dashboard struct {
+	client *client.Client
+	frame  Frame
+	err    error
+	// tokens and sampledAt are the streamed token count and when it was read, for the rate
+	tokens    uint64
+	sampledAt time.Time
+}
+
+// refresh reads the manager's status and workers
+func (d *dashboard) refresh() {
+	status, err := d.client.Status()
+	d.err = err
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	if !d.sampledAt.IsZero() && status.Traffic.StreamTokens >= d.tokens {
+		d.frame.TokensPerSecond = float64(status.Traffic.StreamTokens-d.tokens) / now.Sub(d.sampledAt).Seconds()
+	}
+	d.tokens, d.sampledAt = status.Traffic.StreamTokens, now
+	d.frame.Status = status
+
+	workers, err := d.client.Workers()
+	if err != nil {
+		workers = nil
+	}
+	d.frame.Workers = workers
+	d.frame.Selected = min(d.frame.Selected, max(0, len(workers)-1))
+}
+
+func (d *dashboard) draw(out io.Writer) {
+	fmt.Fprint(out, clearScreen)
+	if d.err != nil || d.frame.Status == nil {
+		fmt.Fprintf(out, "BotFramework top — %s\n\n  unable to reach manager: %v\n", d.frame.BaseURL, d.err)
+		return
+	}
+	Render(out, d.frame)
+}
+
+// handle acts on a key press, running admin calls in the background and reporting their
+// outcome on results. It returns false to quit.
+func (d *dashboard) handle(key string, results chan<- string) bool {
+	if d.frame.Typing {
+		switch key {
+		case keyEnter:
+			model := strings.TrimSpace(d.frame.Prompt)
+			d.frame.Typing, d.frame.Prompt = false, ""
+			if model != "" {
+				d.frame.Message = "swapping the default model to " + model + "..."
+				go func() {
+					if _, err := d.client.LoadModel(client.LoadRequest{Model: model}); err != nil {
+						results <- "swap failed: " + err.Error()
+						return
+					}
+					results <- "now serving " + model
+				}()
+			}
+		case keyEsc:
+			d.frame.Typing, d.frame.Prompt = false, ""
+		case keyBack:
+			if n := len(d.frame.Prompt); n > 0 {
+				d.frame.Prompt = d.frame.Prompt[:n-1]
+			}
+		default:
+			if len(key) == 1 && key[0] >= ' ' && key[0] <= '~' {
+				d.frame.Prompt += key
+			}
+		}
+		return true
+	}
+
+	switch key {
+	case "q", "\x03":
+		return false
+	case keyUp, "k":
+		d.frame.Selected = max(0, d.frame.Selected-1)
+	case keyDown, "j":
+		d.frame.Selected = min(d.frame.Selected+1, max(0, len(d.frame.Workers)-1))
+	case "r", "s":
+		if d.frame.Selected >= len(d.frame.Workers) {
+			d.frame.Message = "no worker selected"
+			return true
+		}
+		id, action := d.frame.Workers[d.frame.Selected].ID, map[string]string{"r": "restart", "s": "stop"}[key]
+		d.frame.Message = map[string]string{"restart": "restarting ", "stop": "stopping "}[action] + id + "..."
+		go func() {
+			if _, err := d.client.WorkerAction(id, action); err != nil {
+				results <- action + " " + id + " failed: " + err.Error()
+				return
+			}
+			results <- action + " " + id + ": done"
+		}()
+	case "m":
+		d.frame.Typing, d.frame.Message = true, ""
+	}
+	return true
+}
+
+// readKeys turns the bytes a terminal in cbreak mode sends into key presses; the channel
+// closes when in does
+func readKeys(in io.Reader) <-chan string {
+	keys := make(chan string)
+	go func() {
+		defer close(keys)
+		buf := make([]byte, 16)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, key := range parseKeys(buf[:n]) {
+				keys <- key
+			}
+		}
+	}()
+	return keys
+}
+
+// parseKeys splits one read into keys: arrow escape sequences, enter, escape, backspace
+// and single characters
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		switch {
+		case len(b) >= 3 && b[0] == 0x1b && b[1] == '[' && (b[2] == 'A' || b[2] == 'B'):
+			keys = append(keys, map[byte]string{'A': keyUp, 'B': keyDown}[b[2]])
+			b = b[3:]
+			continue
+		case b[0] == 0x1b:
+			keys = append(keys, keyEsc)
+		case b[0] == '\r' || b[0] == '\n':
+			keys = append(keys, keyEnter)
+		case b[0] == 0x7f || b[0] == 0x08:
+			keys = append(keys, keyBack)
+		default:
+			keys = append(keys, string(b[:1]))
+		}
+		b = b[1:]
+	}
+	return keys
+}
+
+func bar(used, total int) string {
+	const width = 20
+	if total <= 0 {
+		return "[" + strings.Repeat(" ", width) + "]"
+	}
+	filled := used * width / total
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(" ", width-filled) + "]"
+}
+
+func formatUptime(seconds float64) string {
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+func orDash(pid int) string {
+	if pid <= 0 {
+		return "-"
+	}
+	return fmt.Sprint(pid)
+}
+
+func rate(tps float64) string {
+	if tps <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", tps)
+}
+
+func megabytes(bytes int64) string {
+	if bytes <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%dMB", bytes>>20)
+}
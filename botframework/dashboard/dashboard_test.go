@@ -0,0 +1,118 @@
+package dashboard
+
+import (
+	"botframework/api"
+	"botframework/client"
+	"botframework/engine"
+	"botframework/metrics"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/status" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(api.AdminStatus{
+			UptimeSeconds: 90,
+			Traffic:       metrics.Snapshot{TotalRequests: 12, RequestsPerSecond: 0.5},
+			Workers:       []api.WorkerState{{Name: "default", Status: "ok", Model: "qwen.gguf"}},
+		})
+	}))
+	defer ts.Close()
+
+	status, err := client.New(ts.URL, "").Status()
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	Render(&out, Frame{BaseURL: ts.URL, Status: status})
+	for _, want := range []string{"qwen.gguf", "total: 12", "up 1m30s", "VRAM n/a"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected dashboard to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRenderWorkersAndQueues(t *testing.T) {
+	status := &api.AdminStatus{
+		Traffic: metrics.Snapshot{StreamTokens: 4200},
+		Queues: []engine.QueueStats{{Model: "default", Capacity: 4, InFlight: 4, Queued: 3,
+			QueuedByPriority: map[engine.Priority]int{engine.PriorityInteractive: 1, engine.PriorityBackground: 2}}},
+	}
+	workers := []api.WorkerInfo{
+		{ID: "default", State: "running", Health: "ok", PID: 4242, TokensPerSecond: 38.5, MemoryBytes: 5 << 30, Model: "qwen.gguf"},
+		{ID: "embeddings", State: "running", Health: "ok", Liveness: "degraded"},
+	}
+
+	var out bytes.Buffer
+	Render(&out, Frame{Status: status, Workers: workers, TokensPerSecond: 61, Selected: 1, Typing: true, Prompt: "llama"})
+	for _, want := range []string{"streamed tok/s: 61.0", "4/4", "38.5", "5120MB", "> embeddings", "ok/degraded", "swap the default model to: llama_"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected dashboard to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunActsOnKeys(t *testing.T) {
+	actions := make(chan string, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/status":
+			_ = json.NewEncoder(w).Encode(api.AdminStatus{})
+		case "/admin/workers":
+			_ = json.NewEncoder(w).Encode(map[string]any{"workers": []api.WorkerInfo{{ID: "default"}, {ID: "pool-1"}}})
+		case "/admin/workers/pool-1/restart":
+			actions <- r.URL.Path
+			_ = json.NewEncoder(w).Encode(api.WorkerInfo{ID: "pool-1"})
+		case "/admin/models/load":
+			var req client.LoadRequest
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			actions <- "swap " + req.Model
+			_, _ = w.Write([]byte("{}"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	// down, restart, then swap to phi after correcting a typo
+	keys := strings.NewReader("\x1b[Brmphx\x7fi\rq")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var out bytes.Buffer
+	if err := Run(ctx, keys, &out, client.New(ts.URL, ""), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for len(got) < 2 {
+		select {
+		case action := <-actions:
+			got = append(got, action)
+		case <-ctx.Done():
+			t.Fatalf("actions = %v, want a restart and a swap", got)
+		}
+	}
+	slices.Sort(got)
+	if want := []string{"/admin/workers/pool-1/restart", "swap phi"}; !slices.Equal(got, want) {
+		t.Errorf("actions = %v, want %v", got, want)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("\x1b[Aj\x1b\r\x7f"))
+	if want := []string{keyUp, "j", keyEsc, keyEnter, keyBack}; !slices.Equal(got, want) {
+		t.Errorf("parseKeys = %v, want %v", got, want)
+	}
+}
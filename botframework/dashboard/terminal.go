@@ -0,0 +1,28 @@
+package dashboard
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Cbreak puts the terminal on f in cbreak mode, unbuffered and without echo, so key presses
+// reach the dashboard as they are typed; Ctrl-C still interrupts. restore puts the
+// terminal back. It fails where stty is missing or f is not a terminal.
+func Cbreak(f *os.File) (restore func(), err error) {
+	saved, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(f, "-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { _, _ = stty(f, strings.TrimSpace(saved)) }, nil
+}
+
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,97 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConsulRegistration registers the manager with a Consul agent so service-mesh
+// clients and other managers can discover it
+type ConsulRegistration struct {
+	Address    string // Consul agent address, e.g. http://127.0.0.1:8500
+	Token      string
+	ServiceID  string
+	Name       string
+	Host       string
+	Port       int
+	Tags       []string
+	HTTPClient *http.Client
+}
+
+func (c *ConsulRegistration) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return &http.Client{Timeout: 5 * time.Second}
+}
+
+type consulService struct {
+	ID      string            `json:"ID"`
+	Name    string            `json:"Name"`
+	Address string            `json:"Address,omitempty"`
+	Port    int               `json:"Port"`
+	Tags    []string          `json:"Tags,omitempty"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   *consulCheck      `json:"Check,omitempty"`
+}
+
+type consulCheck struct {
+	HTTP                           string `json:"HTTP"`
+	Interval                       string `json:"Interval"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+// Register adds the service with an HTTP health check against /v1/health
+func (c *ConsulRegistration) Register(models []string) error {
+	host := c.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	service := consulService{
+		ID:      c.ServiceID,
+		Name:    c.Name,
+		Address: c.Host,
+		Port:    c.Port,
+		Tags:    c.Tags,
+		Meta:    map[string]string{"models": strings.Join(models, ",")},
+		Check: &consulCheck{
+			HTTP:                           fmt.Sprintf("http://%s:%d/v1/health", host, c.Port),
+			Interval:                       "10s",
+			DeregisterCriticalServiceAfter: "1m",
+		},
+	}
+	body, err := json.Marshal(service)
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodPut, "/v1/agent/service/register", body)
+}
+
+// Deregister removes the service from the Consul agent
+func (c *ConsulRegistration) Deregister() error {
+	return c.do(http.MethodPut, "/v1/agent/service/deregister/"+c.ServiceID, nil)
+}
+
+func (c *ConsulRegistration) do(method, path string, body []byte) error {
+	req, err := http.NewRequest(method, c.Address+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Token)
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("consul %s %s: %s", method, path, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package discovery
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConsulRegisterAndDeregister(t *testing.T) {
+	var paths []string
+	var registered consulService
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Header.Get("X-Consul-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/v1/agent/service/register" {
+			json.NewDecoder(r.Body).Decode(&registered)
+		}
+	}))
+	defer server.Close()
+
+	registration := &ConsulRegistration{Address: server.URL, Token: "secret", ServiceID: "bf-1", Name: "botframework", Host: "lab", Port: 8080}
+	if err := registration.Register([]string{"llama", "qwen"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registration.Deregister(); err != nil {
+		t.Fatal(err)
+	}
+
+	if registered.Meta["models"] != "llama,qwen" || registered.Check.HTTP != "http://lab:8080/v1/health" {
+		t.Errorf("unexpected registration: %+v", registered)
+	}
+	if len(paths) != 2 || paths[1] != "PUT /v1/agent/service/deregister/bf-1" {
+		t.Errorf("unexpected calls: %v", paths)
+	}
+}
@@ -0,0 +1,229 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+// DNS record types used by DNS-SD
+const (
+	typeA    uint16 = 1
+	typePTR  uint16 = 12
+	typeTXT  uint16 = 16
+	typeAAAA uint16 = 28
+	typeSRV  uint16 = 33
+	typeANY  uint16 = 255
+
+	classIN    uint16 = 1
+	cacheFlush uint16 = 0x8000
+	unicastBit uint16 = 0x8000
+)
+
+var errMalformed = errors.New("malformed DNS message")
+
+type question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+type record struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	// Exactly one of the following is set, according to Type
+	Target string   // PTR
+	SRV    srvData  // SRV
+	TXT    []string // TXT
+	IP     net.IP   // A / AAAA
+}
+
+type srvData struct {
+	Priority, Weight, Port uint16
+	Target                 string
+}
+
+type message struct {
+	ID        uint16
+	Response  bool
+	Questions []question
+	Answers   []record
+}
+
+func (m *message) pack() []byte {
+	buf := make([]byte, 12)
+	binary.BigEndian.PutUint16(buf[0:], m.ID)
+	if m.Response {
+		// QR + AA
+		binary.BigEndian.PutUint16(buf[2:], 0x8400)
+	}
+	binary.BigEndian.PutUint16(buf[4:], uint16(len(m.Questions)))
+	binary.BigEndian.PutUint16(buf[6:], uint16(len(m.Answers)))
+
+	for _, q := range m.Questions {
+		buf = appendName(buf, q.Name)
+		buf = binary.BigEndian.AppendUint16(buf, q.Type)
+		buf = binary.BigEndian.AppendUint16(buf, q.Class)
+	}
+	for _, rr := range m.Answers {
+		buf = appendName(buf, rr.Name)
+		buf = binary.BigEndian.AppendUint16(buf, rr.Type)
+		buf = binary.BigEndian.AppendUint16(buf, rr.Class)
+		buf = binary.BigEndian.AppendUint32(buf, rr.TTL)
+
+		var data []byte
+		switch rr.Type {
+		case typePTR:
+			data = appendName(nil, rr.Target)
+		case typeSRV:
+			data = binary.BigEndian.AppendUint16(data, rr.SRV.Priority)
+			data = binary.BigEndian.AppendUint16(data, rr.SRV.Weight)
+			data = binary.BigEndian.AppendUint16(data, rr.SRV.Port)
+			data = appendName(data, rr.SRV.Target)
+		case typeTXT:
+			for _, txt := range rr.TXT {
+				if len(txt) > 255 {
+					txt = txt[:255]
+				}
+				data = append(data, byte(len(txt)))
+				data = append(data, txt...)
+			}
+			if len(data) == 0 {
+				data = []byte{0}
+			}
+		case typeA:
+			data = rr.IP.To4()
+		case typeAAAA:
+			data = rr.IP.To16()
+		}
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(data)))
+		buf = append(buf, data...)
+	}
+	return buf
+}
+
+func appendName(buf []byte, name string) []byte {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		buf = append(buf, byte(len(label)))
+		buf = append(buf, label...)
+	}
+	return append(buf, 0)
+}
+
+func unpack(data []byte) (*message, error) {
+	if len(data) < 12 {
+		return nil, errMalformed
+	}
+	m := &message{
+		ID:       binary.BigEndian.Uint16(data[0:]),
+		Response: data[2]&0x80 != 0,
+	}
+	qd := int(binary.BigEndian.Uint16(data[4:]))
+	an := int(binary.BigEndian.Uint16(data[6:])) + int(binary.BigEndian.Uint16(data[8:])) + int(binary.BigEndian.Uint16(data[10:]))
+
+	off := 12
+	for i := 0; i < qd; i++ {
+		name, next, err := readName(data, off)
+		if err != nil || next+4 > len(data) {
+			return nil, errMalformed
+		}
+		m.Questions = append(m.Questions, question{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(data[next:]),
+			Class: binary.BigEndian.Uint16(data[next+2:]),
+		})
+		off = next + 4
+	}
+
+	for i := 0; i < an; i++ {
+		name, next, err := readName(data, off)
+		if err != nil || next+10 > len(data) {
+			return nil, errMalformed
+		}
+		rr := record{
+			Name:  name,
+			Type:  binary.BigEndian.Uint16(data[next:]),
+			Class: binary.BigEndian.Uint16(data[next+2:]),
+			TTL:   binary.BigEndian.Uint32(data[next+4:]),
+		}
+		length := int(binary.BigEndian.Uint16(data[next+8:]))
+		start := next + 10
+		end := start + length
+		if end > len(data) {
+			return nil, errMalformed
+		}
+
+		switch rr.Type {
+		case typePTR:
+			rr.Target, _, err = readName(data, start)
+		case typeSRV:
+			if length < 7 {
+				return nil, errMalformed
+			}
+			rr.SRV.Priority = binary.BigEndian.Uint16(data[start:])
+			rr.SRV.Weight = binary.BigEndian.Uint16(data[start+2:])
+			rr.SRV.Port = binary.BigEndian.Uint16(data[start+4:])
+			rr.SRV.Target, _, err = readName(data, start+6)
+		case typeTXT:
+			for p := start; p < end; {
+				n := int(data[p])
+				if p+1+n > end {
+					return nil, errMalformed
+				}
+				if n > 0 {
+					rr.TXT = append(rr.TXT, string(data[p+1:p+1+n]))
+				}
+				p += 1 + n
+			}
+		case typeA, typeAAAA:
+			rr.IP = net.IP(append([]byte(nil), data[start:end]...))
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.Answers = append(m.Answers, rr)
+		off = end
+	}
+	return m, nil
+}
+
+// readName decodes a possibly compressed domain name and returns the offset after it
+func readName(data []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; jumps < 32; {
+		if off >= len(data) {
+			return "", 0, errMalformed
+		}
+		n := int(data[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case n&0xC0 == 0xC0:
+			if off+1 >= len(data) {
+				return "", 0, errMalformed
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(data[off:]) & 0x3FFF)
+			jumps++
+		default:
+			if off+1+n > len(data) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(data[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+	return "", 0, errMalformed
+}
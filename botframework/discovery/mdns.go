@@ -0,0 +1,251 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceType is the DNS-SD service type managers advertise under
+const ServiceType = "_botframework._tcp.local."
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Service describes an advertised manager
+type Service struct {
+	Instance string   `json:"instance"`
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Addrs    []net.IP `json:"addrs"`
+	Models   []string `json:"models"`
+	Version  string   `json:"version,omitempty"`
+}
+
+// URL returns the base URL of the advertised manager
+func (s Service) URL() string {
+	host := strings.TrimSuffix(s.Host, ".")
+	if len(s.Addrs) > 0 {
+		host = s.Addrs[0].String()
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(s.Port))
+}
+
+// Advertiser answers mDNS queries for the manager's service records
+type Advertiser struct {
+	Instance string
+	Port     int
+	Version  string
+	// Models is called per response so the advertised model list stays current
+	Models func() []string
+	TTL    time.Duration
+}
+
+func NewAdvertiser(port int, models func() []string) *Advertiser {
+	hostname, _ := os.Hostname()
+	return &Advertiser{
+		Instance: "BotFramework on " + hostname,
+		Port:     port,
+		Models:   models,
+		TTL:      2 * time.Minute,
+	}
+}
+
+func (a *Advertiser) instanceName() string {
+	return strings.ReplaceAll(a.Instance, ".", "-") + "." + ServiceType
+}
+
+func (a *Advertiser) hostName() string {
+	hostname, _ := os.Hostname()
+	hostname, _, _ = strings.Cut(hostname, ".")
+	return hostname + ".local."
+}
+
+// Run announces the service, answers queries until ctx is cancelled, then sends a goodbye
+func (a *Advertiser) Run(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("mdns listen: %w", err)
+	}
+	defer conn.Close()
+
+	slog.Info("advertising via mDNS", "instance", a.instanceName())
+	a.send(conn, mdnsGroup, a.response(0, a.TTL))
+
+	go func() {
+		<-ctx.Done()
+		a.send(conn, mdnsGroup, a.response(0, 0))
+		conn.Close()
+	}()
+
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		query, err := unpack(buf[:n])
+		if err != nil || query.Response || !a.matches(query) {
+			continue
+		}
+
+		dest := mdnsGroup
+		for _, q := range query.Questions {
+			if q.Class&unicastBit != 0 || from.Port != mdnsGroup.Port {
+				dest = from
+			}
+		}
+		a.send(conn, dest, a.response(query.ID, a.TTL))
+	}
+}
+
+func (a *Advertiser) matches(query *message) bool {
+	for _, q := range query.Questions {
+		name := strings.ToLower(q.Name)
+		if name == ServiceType || name == strings.ToLower(a.instanceName()) || name == "_services._dns-sd._udp.local." {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *Advertiser) send(conn *net.UDPConn, dest *net.UDPAddr, msg *message) {
+	if _, err := conn.WriteToUDP(msg.pack(), dest); err != nil {
+		slog.Warn("mdns send failed", "err", err)
+	}
+}
+
+func (a *Advertiser) response(id uint16, ttl time.Duration) *message {
+	seconds := uint32(ttl / time.Second)
+	instance := a.instanceName()
+	host := a.hostName()
+
+	txt := []string{"path=/v1"}
+	if a.Version != "" {
+		txt = append(txt, "version="+a.Version)
+	}
+	if a.Models != nil {
+		txt = append(txt, "models="+strings.Join(a.Models(), ","))
+	}
+
+	answers := []record{
+		{Name: ServiceType, Type: typePTR, Class: classIN, TTL: seconds, Target: instance},
+		{Name: instance, Type: typeSRV, Class: classIN | cacheFlush, TTL: seconds, SRV: srvData{Port: uint16(a.Port), Target: host}},
+		{Name: instance, Type: typeTXT, Class: classIN | cacheFlush, TTL: seconds, TXT: txt},
+	}
+	for _, ip := range localIPv4s() {
+		answers = append(answers, record{Name: host, Type: typeA, Class: classIN | cacheFlush, TTL: seconds, IP: ip})
+	}
+	return &message{ID: id, Response: true, Answers: answers}
+}
+
+func localIPv4s() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		ips = append(ips, ipNet.IP.To4())
+	}
+	return ips
+}
+
+// Browse queries the LAN for advertised managers until timeout elapses
+func Browse(ctx context.Context, timeout time.Duration) ([]Service, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	query := &message{Questions: []question{{Name: ServiceType, Type: typePTR, Class: classIN | unicastBit}}}
+	if _, err := conn.WriteToUDP(query.pack(), mdnsGroup); err != nil {
+		return nil, fmt.Errorf("mdns query: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	var responses []*message
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			break
+		}
+		if msg, err := unpack(buf[:n]); err == nil && msg.Response {
+			responses = append(responses, msg)
+		}
+	}
+	return collectServices(responses), nil
+}
+
+// collectServices joins PTR, SRV, TXT and address records into services
+func collectServices(responses []*message) []Service {
+	var instances []string
+	srv := make(map[string]srvData)
+	txt := make(map[string][]string)
+	addrs := make(map[string][]net.IP)
+
+	for _, msg := range responses {
+		for _, rr := range msg.Answers {
+			switch rr.Type {
+			case typePTR:
+				if strings.EqualFold(rr.Name, ServiceType) && rr.TTL > 0 {
+					instances = append(instances, rr.Target)
+				}
+			case typeSRV:
+				srv[rr.Name] = rr.SRV
+			case typeTXT:
+				txt[rr.Name] = rr.TXT
+			case typeA, typeAAAA:
+				addrs[rr.Name] = append(addrs[rr.Name], rr.IP)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var services []Service
+	for _, instance := range instances {
+		record, ok := srv[instance]
+		if !ok || seen[instance] {
+			continue
+		}
+		seen[instance] = true
+
+		service := Service{
+			Instance: strings.TrimSuffix(strings.TrimSuffix(instance, ServiceType), "."),
+			Host:     record.Target,
+			Port:     int(record.Port),
+			Addrs:    addrs[record.Target],
+		}
+		for _, entry := range txt[instance] {
+			key, value, _ := strings.Cut(entry, "=")
+			switch key {
+			case "models":
+				if value != "" {
+					service.Models = strings.Split(value, ",")
+				}
+			case "version":
+				service.Version = value
+			}
+		}
+		services = append(services, service)
+	}
+	return services
+}
@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseRoundTripsToService(t *testing.T) {
+	advertiser := &Advertiser{
+		Instance: "BotFramework on lab",
+		Port:     8080,
+		Version:  "1.2",
+		Models:   func() []string { return []string{"llama", "qwen"} },
+		TTL:      2 * time.Minute,
+	}
+
+	msg, err := unpack(advertiser.response(0, advertiser.TTL).pack())
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := collectServices([]*message{msg})
+	if len(services) != 1 {
+		t.Fatalf("expected one service, got %d", len(services))
+	}
+
+	service := services[0]
+	if service.Instance != "BotFramework on lab" || service.Port != 8080 || service.Version != "1.2" {
+		t.Errorf("unexpected service: %+v", service)
+	}
+	if len(service.Models) != 2 || service.Models[1] != "qwen" {
+		t.Errorf("unexpected models: %v", service.Models)
+	}
+}
+
+func TestGoodbyeIsNotCollected(t *testing.T) {
+	advertiser := &Advertiser{Instance: "gone", Port: 8080}
+	msg, _ := unpack(advertiser.response(0, 0).pack())
+	if services := collectServices([]*message{msg}); len(services) != 0 {
+		t.Errorf("expected goodbye to be ignored, got %v", services)
+	}
+}
+
+func TestReadNameFollowsCompression(t *testing.T) {
+	// "local." at offset 0, then "a" + pointer to offset 0
+	data := []byte{5, 'l', 'o', 'c', 'a', 'l', 0, 1, 'a', 0xC0, 0}
+	name, next, err := readName(data, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "a.local." || next != len(data) {
+		t.Errorf("got %q next=%d", name, next)
+	}
+
+	loop := []byte{0xC0, 0}
+	if _, _, err := readName(loop, 0); err == nil {
+		t.Error("expected pointer loop to fail")
+	}
+}
+
+func TestMatchesServiceQueries(t *testing.T) {
+	advertiser := &Advertiser{Instance: "lab"}
+	if !advertiser.matches(&message{Questions: []question{{Name: ServiceType, Type: typePTR}}}) {
+		t.Error("expected service type query to match")
+	}
+	if advertiser.matches(&message{Questions: []question{{Name: "_http._tcp.local.", Type: typePTR}}}) {
+		t.Error("unrelated query matched")
+	}
+}
@@ -0,0 +1,471 @@
+// Package download fetches registry models from Hugging Face into a local cache that
+// workers load from.
+package download
+
+import (
+	"botframework/profiler"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Hugging Face Hub
+const DefaultBaseURL = "https://huggingface.co"
+
+// DefaultChunkSize is the size of each ranged request
+const DefaultChunkSize = 64 << 20
+
+var ErrChecksumMismatch = errors.New("sha256 mismatch")
+
+// ErrGated is returned for gated repositories when no Hugging Face token is set
+var ErrGated = errors.New("gated model")
+
+// RemoteFile is one file of a model repository
+type RemoteFile struct {
+	Name   string // path inside the repository
+	Size   int64  // 0 when the hub did not report it
+	SHA256 string // empty when the hub did not report it
+}
+
+// Progress reports how much of a file has been downloaded
+type Progress struct {
+	File       string
+	Downloaded int64
+	Total      int64 // 0 when unknown
+}
+
+// Downloader resolves registry variants to Hugging Face files and downloads them into
+// CacheDir/<model id>/<quant>/. Interrupted downloads resume from the partial file.
+type Downloader struct {
+	CacheDir  string
+	BaseURL   string
+	Token     string // Hugging Face access token for gated repositories
+	Revision  string // default: main
+	ChunkSize int64
+	Retries   int // attempts per chunk
+	Client    *http.Client
+	Progress  func(Progress)
+}
+
+func New(cacheDir string) *Downloader {
+	return &Downloader{
+		CacheDir:  cacheDir,
+		BaseURL:   DefaultBaseURL,
+		Revision:  "main",
+		ChunkSize: DefaultChunkSize,
+		Retries:   3,
+		Client:    &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// DefaultCacheDir returns ~/.cache/botframework/models
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "models"
+	}
+	return filepath.Join(dir, "botframework", "models")
+}
+
+// safetensorsCompanions are the files besides the weights a safetensors model needs
+var safetensorsCompanions = []string{
+	"config.json", "generation_config.json", "tokenizer.json", "tokenizer_config.json",
+	"tokenizer.model", "special_tokens_map.json",
+}
+
+type hubSibling struct {
+	Name string `json:"rfilename"`
+	Size int64  `json:"size"`
+	LFS  *struct {
+		SHA256 string `json:"sha256"`
+		Size   int64  `json:"size"`
+	} `json:"lfs"`
+}
+
+// Resolve lists the files to download for a variant. A variant naming a file gets that
+// file; otherwise GGUF files whose name contains the quant (every shard of a split model),
+// and failing that the repository's safetensors weights with their configs and tokenizer.
+func (d *Downloader) Resolve(ctx context.Context, model profiler.Model, variant profiler.Variant) ([]RemoteFile, error) {
+	if model.HFRepo == "" {
+		return nil, fmt.Errorf("model %s has no hf_repo in the registry", model.ID)
+	}
+	if model.Gated && d.Token == "" {
+		return nil, fmt.Errorf("%w: accept the license of %s on Hugging Face and set HF_TOKEN", ErrGated, model.HFRepo)
+	}
+	siblings, err := d.listFiles(ctx, model.HFRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []RemoteFile
+	if variant.File != "" {
+		for _, s := range siblings {
+			if s.Name == variant.File {
+				files = append(files, s)
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("%s has no file %s", model.HFRepo, variant.File)
+		}
+		if variant.SHA256 != "" {
+			files[0].SHA256 = variant.SHA256
+		}
+		return files, nil
+	}
+
+	quant := strings.ToLower(variant.Quant)
+	for _, s := range siblings {
+		name := strings.ToLower(s.Name)
+		if strings.HasSuffix(name, ".gguf") && strings.Contains(name, quant) {
+			files = append(files, s)
+		}
+	}
+	if len(files) > 0 {
+		return files, nil
+	}
+
+	for _, s := range siblings {
+		if strings.HasSuffix(s.Name, ".safetensors") || (!strings.Contains(s.Name, "/") && slices.Contains(safetensorsCompanions, s.Name)) {
+			files = append(files, s)
+		}
+	}
+	if !hasWeights(files) {
+		return nil, fmt.Errorf("%s has no GGUF file for %s and no safetensors weights", model.HFRepo, variant.Quant)
+	}
+	return files, nil
+}
+
+func (d *Downloader) listFiles(ctx context.Context, repo string) ([]RemoteFile, error) {
+	endpoint := fmt.Sprintf("%s/api/models/%s/revision/%s?blobs=true", d.BaseURL, repo, url.PathEscape(d.revision()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.authorize(req)
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list %s: hub returned status %d", repo, resp.StatusCode)
+	}
+
+	var info struct {
+		Siblings []hubSibling `json:"siblings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("list %s: %w", repo, err)
+	}
+	files := make([]RemoteFile, 0, len(info.Siblings))
+	for _, s := range info.Siblings {
+		f := RemoteFile{Name: s.Name, Size: s.Size}
+		if s.LFS != nil {
+			f.SHA256 = s.LFS.SHA256
+			f.Size = s.LFS.Size
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// Download fetches a variant into the cache and returns the path workers load: the GGUF
+// file (the first shard of a split model) or the directory holding safetensors weights.
+// The variant's mirrors are tried first, in order; Hugging Face is the last resort.
+func (d *Downloader) Download(ctx context.Context, model profiler.Model, variant profiler.Variant) (string, error) {
+	dir := d.variantDir(model.ID, variant.Quant)
+	if path, err := d.fromSources(ctx, dir, variant); err == nil {
+		return path, nil
+	} else if model.HFRepo == "" {
+		return "", err
+	}
+
+	files, err := d.Resolve(ctx, model, variant)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		dest := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := d.fetch(ctx, d.hubURL(model.HFRepo, f.Name), f, dest); err != nil {
+			return "", fmt.Errorf("download %s: %w", f.Name, err)
+		}
+	}
+	if strings.HasSuffix(files[0].Name, ".gguf") {
+		return filepath.Join(dir, filepath.FromSlash(files[0].Name)), nil
+	}
+	return dir, nil
+}
+
+// TokenizerFile is the Hugging Face tokenizer kept beside a model's variants
+const TokenizerFile = "tokenizer.json"
+
+// DownloadTokenizer fetches the model's tokenizer.json from TokenizerRepo, or HFRepo, into
+// CacheDir/<model id>/ and returns its path. GGUF repositories embed the tokenizer in the
+// weights and rarely ship one, so TokenizerRepo usually names the original model.
+func (d *Downloader) DownloadTokenizer(ctx context.Context, model profiler.Model) (string, error) {
+	repo := cmp.Or(model.TokenizerRepo, model.HFRepo)
+	if repo == "" {
+		return "", fmt.Errorf("model %s has no hf_repo or tokenizer_repo in the registry", model.ID)
+	}
+	dest := filepath.Join(d.CacheDir, model.ID, TokenizerFile)
+	if err := d.fetch(ctx, d.hubURL(repo, TokenizerFile), RemoteFile{Name: TokenizerFile}, dest); err != nil {
+		return "", fmt.Errorf("download %s from %s: %w", TokenizerFile, repo, err)
+	}
+	return dest, nil
+}
+
+// fromSources downloads the variant's file from the first of its mirrors that serves it
+// with the right checksum
+func (d *Downloader) fromSources(ctx context.Context, dir string, variant profiler.Variant) (string, error) {
+	if len(variant.Sources) == 0 {
+		return "", errors.New("no mirrors")
+	}
+	var errs []error
+	for _, source := range variant.Sources {
+		name := variant.File
+		if name == "" {
+			u, err := url.Parse(source.URL)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			name = path.Base(u.Path)
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+		err := d.fetch(ctx, source.URL, RemoteFile{Name: name, SHA256: source.SHA256}, dest)
+		if err == nil {
+			return dest, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source.URL, err))
+	}
+	return "", fmt.Errorf("no mirror served %s: %w", variant.Quant, errors.Join(errs...))
+}
+
+func (d *Downloader) hubURL(repo, name string) string {
+	return fmt.Sprintf("%s/%s/resolve/%s/%s", d.BaseURL, repo, url.PathEscape(d.revision()), escapePath(name))
+}
+
+func (d *Downloader) variantDir(modelID, quant string) string {
+	return filepath.Join(d.CacheDir, modelID, quant)
+}
+
+// fetch downloads one file from fileURL into dest in ranged chunks, appending to dest.part
+// so an interrupted download resumes, and renames it into place once the checksum matches
+func (d *Downloader) fetch(ctx context.Context, fileURL string, f RemoteFile, dest string) error {
+	if info, err := os.Stat(dest); err == nil && (f.Size == 0 || info.Size() == f.Size) {
+		d.report(f, info.Size())
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	part := dest + ".part"
+	out, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	info, err := out.Stat()
+	if err != nil {
+		return err
+	}
+	offset := info.Size()
+	if f.Size > 0 && offset > f.Size {
+		// a stale partial from a different revision
+		if err := out.Truncate(0); err != nil {
+			return err
+		}
+		offset = 0
+	}
+
+	for f.Size == 0 || offset < f.Size {
+		var n int64
+		var done bool
+		for attempt := 0; ; attempt++ {
+			n, done, err = d.fetchChunk(ctx, fileURL, f, offset, out)
+			offset += n
+			if err == nil || attempt+1 >= max(1, d.Retries) || ctx.Err() != nil {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if f.SHA256 != "" {
+		sum, err := fileSHA256(part)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, f.SHA256) {
+			os.Remove(part)
+			return fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, sum, f.SHA256)
+		}
+	}
+	return os.Rename(part, dest)
+}
+
+// fetchChunk appends the next chunk from offset to out, returning the bytes written and
+// whether the file is complete
+func (d *Downloader) fetchChunk(ctx context.Context, fileURL string, f RemoteFile, offset int64, out io.Writer) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	d.authorize(req)
+	chunked := f.Size > 0 && d.ChunkSize > 0
+	if chunked {
+		end := min(offset+d.ChunkSize, f.Size) - 1
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, end))
+	} else if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && offset == 0:
+		// the server ignored the range and sends the whole file
+		chunked = false
+	default:
+		return 0, false, fmt.Errorf("hub returned status %d", resp.StatusCode)
+	}
+
+	total := f.Size
+	if total == 0 && resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+	n, err := io.Copy(out, &progressReader{r: resp.Body, done: offset, report: func(done int64) {
+		d.report(RemoteFile{Name: f.Name, Size: total}, done)
+	}})
+	if err != nil {
+		return n, false, err
+	}
+	return n, !chunked || offset+n >= f.Size, nil
+}
+
+func (d *Downloader) report(f RemoteFile, done int64) {
+	if d.Progress != nil {
+		d.Progress(Progress{File: f.Name, Downloaded: done, Total: f.Size})
+	}
+}
+
+// authorize sends the token to the hub only, never to a mirror
+func (d *Downloader) authorize(req *http.Request) {
+	if d.Token == "" {
+		return
+	}
+	if hub, err := url.Parse(d.BaseURL); err == nil && hub.Host == req.URL.Host {
+		req.Header.Set("Authorization", "Bearer "+d.Token)
+	}
+}
+
+func (d *Downloader) revision() string {
+	if d.Revision == "" {
+		return "main"
+	}
+	return d.Revision
+}
+
+// Find returns a cached model path for modelID: the variant for quant, or the first cached
+// variant when quant is empty. GGUF variants resolve to their (first) model file.
+func Find(cacheDir, modelID, quant string) (string, bool) {
+	quants := []string{quant}
+	if quant == "" {
+		entries, err := os.ReadDir(filepath.Join(cacheDir, modelID))
+		if err != nil {
+			return "", false
+		}
+		quants = quants[:0]
+		for _, entry := range entries {
+			if entry.IsDir() {
+				quants = append(quants, entry.Name())
+			}
+		}
+	}
+	for _, q := range quants {
+		dir := filepath.Join(cacheDir, modelID, q)
+		if ggufs, _ := filepath.Glob(filepath.Join(dir, "*.gguf")); len(ggufs) > 0 {
+			sort.Strings(ggufs)
+			return ggufs[0], true
+		}
+		if weights, _ := filepath.Glob(filepath.Join(dir, "*.safetensors")); len(weights) > 0 {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+type progressReader struct {
+	r      io.Reader
+	done   int64
+	report func(done int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.done += int64(n)
+		p.report(p.done)
+	}
+	return n, err
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func escapePath(name string) string {
+	parts := strings.Split(path.Clean(name), "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func hasWeights(files []RemoteFile) bool {
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, ".safetensors") {
+			return true
+		}
+	}
+	return false
+}
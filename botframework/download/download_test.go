@@ -0,0 +1,231 @@
+package download
+
+import (
+	"botframework/profiler"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeHub serves a repository listing and its files, honouring Range requests
+type fakeHub struct {
+	files    map[string][]byte
+	checksum map[string]string // overrides the reported sha256
+	ranges   atomic.Int32
+}
+
+func (h *fakeHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/models/") {
+		var siblings []string
+		for name, data := range h.files {
+			sum := sha256.Sum256(data)
+			checksum := hex.EncodeToString(sum[:])
+			if override, ok := h.checksum[name]; ok {
+				checksum = override
+			}
+			siblings = append(siblings, fmt.Sprintf(`{"rfilename":%q,"size":%d,"lfs":{"sha256":%q,"size":%d}}`, name, len(data), checksum, len(data)))
+		}
+		fmt.Fprintf(w, `{"siblings":[%s]}`, strings.Join(siblings, ","))
+		return
+	}
+	_, name, ok := strings.Cut(r.URL.Path, "/resolve/main/")
+	data, found := h.files[name]
+	if !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Range") != "" {
+		h.ranges.Add(1)
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(data))
+}
+
+func newHub(t *testing.T, files map[string][]byte) (*fakeHub, *Downloader) {
+	t.Helper()
+	hub := &fakeHub{files: files, checksum: map[string]string{}}
+	ts := httptest.NewServer(hub)
+	t.Cleanup(ts.Close)
+	d := New(t.TempDir())
+	d.BaseURL = ts.URL
+	d.Client = ts.Client()
+	return hub, d
+}
+
+var testModel = profiler.Model{ID: "tiny", HFRepo: "org/tiny-GGUF"}
+
+func TestDownloadGGUFInChunks(t *testing.T) {
+	weights := []byte(strings.Repeat("gguf", 1000))
+	hub, d := newHub(t, map[string][]byte{
+		"tiny-Q4_K_M.gguf": weights,
+		"tiny-Q8_0.gguf":   []byte("other"),
+		"README.md":        []byte("readme"),
+	})
+	d.ChunkSize = 1024
+	var last Progress
+	d.Progress = func(p Progress) { last = p }
+
+	path, err := d.Download(context.Background(), testModel, profiler.Variant{Quant: "Q4_K_M"})
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if want := filepath.Join(d.CacheDir, "tiny", "Q4_K_M", "tiny-Q4_K_M.gguf"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(weights) {
+		t.Error("downloaded file differs from the hub's")
+	}
+	if got := hub.ranges.Load(); got != 4 {
+		t.Errorf("ranged requests = %d, want 4 chunks", got)
+	}
+	if last.Downloaded != int64(len(weights)) || last.Total != int64(len(weights)) {
+		t.Errorf("last progress = %+v", last)
+	}
+	if found, ok := Find(d.CacheDir, "tiny", ""); !ok || found != path {
+		t.Errorf("Find = %s, %v", found, ok)
+	}
+}
+
+func TestDownloadResumesPartialFile(t *testing.T) {
+	weights := []byte("0123456789abcdef")
+	hub, d := newHub(t, map[string][]byte{"tiny-q4_k_m.gguf": weights})
+	d.ChunkSize = 0
+	dest := filepath.Join(d.CacheDir, "tiny", "Q4_K_M", "tiny-q4_k_m.gguf")
+	os.MkdirAll(filepath.Dir(dest), 0o755)
+	if err := os.WriteFile(dest+".part", weights[:10], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Download(context.Background(), testModel, profiler.Variant{Quant: "Q4_K_M"}); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != string(weights) {
+		t.Errorf("resumed file = %q", data)
+	}
+	if hub.ranges.Load() != 1 {
+		t.Errorf("expected one ranged request for the remainder, got %d", hub.ranges.Load())
+	}
+}
+
+func TestDownloadRejectsChecksumMismatch(t *testing.T) {
+	hub, d := newHub(t, map[string][]byte{"tiny-Q4_K_M.gguf": []byte("weights")})
+	hub.checksum["tiny-Q4_K_M.gguf"] = strings.Repeat("0", 64)
+
+	_, err := d.Download(context.Background(), testModel, profiler.Variant{Quant: "Q4_K_M"})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want checksum mismatch", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(d.CacheDir, "tiny", "Q4_K_M", "*"))
+	if len(matches) != 0 {
+		t.Errorf("corrupt download left files behind: %v", matches)
+	}
+}
+
+func TestResolveSafetensors(t *testing.T) {
+	_, d := newHub(t, map[string][]byte{
+		"model-00001-of-00002.safetensors": []byte("a"),
+		"model-00002-of-00002.safetensors": []byte("b"),
+		"config.json":                      []byte("{}"),
+		"tokenizer.json":                   []byte("{}"),
+		"README.md":                        []byte("readme"),
+	})
+	files, err := d.Resolve(context.Background(), testModel, profiler.Variant{Quant: "F16"})
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	want := "config.json,model-00001-of-00002.safetensors,model-00002-of-00002.safetensors,tokenizer.json"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("files = %s, want %s", got, want)
+	}
+}
+
+func TestResolvePinnedFile(t *testing.T) {
+	_, d := newHub(t, map[string][]byte{"tiny-q4.gguf": []byte("weights")})
+	files, err := d.Resolve(context.Background(), testModel, profiler.Variant{Quant: "Q4_K_M", File: "tiny-q4.gguf"})
+	if err != nil || len(files) != 1 || files[0].Name != "tiny-q4.gguf" {
+		t.Fatalf("Resolve = %v, %v", files, err)
+	}
+	if _, err := d.Resolve(context.Background(), profiler.Model{ID: "x"}, profiler.Variant{}); err == nil {
+		t.Error("a model without hf_repo should not resolve")
+	}
+}
+
+func TestDownloadFromMirrors(t *testing.T) {
+	weights := []byte("mirrored weights")
+	sum := sha256.Sum256(weights)
+	var leaked atomic.Int32
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			leaked.Add(1)
+		}
+		if r.URL.Path == "/corrupt/tiny.gguf" {
+			w.Write([]byte("corrupted"))
+			return
+		}
+		http.ServeContent(w, r, "tiny.gguf", time.Time{}, bytes.NewReader(weights))
+	}))
+	defer mirror.Close()
+
+	_, d := newHub(t, map[string][]byte{})
+	d.Token = "hf_secret"
+	variant := profiler.Variant{Quant: "Q4_K_M", Sources: []profiler.Source{
+		{URL: mirror.URL + "/corrupt/tiny.gguf", SHA256: hex.EncodeToString(sum[:])},
+		{URL: mirror.URL + "/good/tiny.gguf", SHA256: hex.EncodeToString(sum[:])},
+	}}
+	path, err := d.Download(context.Background(), testModel, variant)
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, weights) || filepath.Base(path) != "tiny.gguf" {
+		t.Errorf("downloaded %s = %q", path, data)
+	}
+	if leaked.Load() != 0 {
+		t.Error("the Hugging Face token was sent to a mirror")
+	}
+}
+
+func TestResolveGatedNeedsToken(t *testing.T) {
+	_, d := newHub(t, map[string][]byte{"tiny-Q4_K_M.gguf": []byte("weights")})
+	gated := testModel
+	gated.Gated = true
+	if _, err := d.Resolve(context.Background(), gated, profiler.Variant{Quant: "Q4_K_M"}); !errors.Is(err, ErrGated) {
+		t.Fatalf("err = %v, want ErrGated", err)
+	}
+	d.Token = "hf_secret"
+	if _, err := d.Resolve(context.Background(), gated, profiler.Variant{Quant: "Q4_K_M"}); err != nil {
+		t.Errorf("with a token: %v", err)
+	}
+}
+
+func TestDownloadTokenizer(t *testing.T) {
+	_, d := newHub(t, map[string][]byte{TokenizerFile: []byte(`{"model":{"type":"BPE"}}`)})
+	model := testModel
+	model.TokenizerRepo = "org/tiny"
+	path, err := d.DownloadTokenizer(context.Background(), model)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(d.CacheDir, "tiny", TokenizerFile); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+	if found, ok := Find(d.CacheDir, "tiny", ""); ok {
+		t.Errorf("the tokenizer was taken for weights: %s", found)
+	}
+	if _, err := d.DownloadTokenizer(context.Background(), profiler.Model{ID: "local"}); err == nil {
+		t.Error("a model without repositories has no tokenizer to download")
+	}
+}
@@ -0,0 +1,187 @@
+package energy
+
+import (
+	"botframework/engine"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxUsageCapture = 1 << 20
+
+// PowerSampler returns the current power draw in watts
+type PowerSampler func() (float64, bool)
+
+// ModelEnergy aggregates estimated energy use for one model
+type ModelEnergy struct {
+	Model          string  `json:"model"`
+	Requests       int     `json:"requests"`
+	Tokens         int     `json:"tokens"`
+	WattHours      float64 `json:"watt_hours"`
+	Cost           float64 `json:"cost"`
+	WhPer1kTokens  float64 `json:"wh_per_1k_tokens"`
+	CostPer1kToken float64 `json:"cost_per_1k_tokens"`
+}
+
+// Meter samples power draw and attributes energy to requests by duration
+type Meter struct {
+	// FallbackWatts is used when no sampler reading is available (e.g. CPU-only hosts)
+	FallbackWatts float64
+	// PricePerKWh converts watt-hours into electricity cost
+	PricePerKWh float64
+
+	sampler PowerSampler
+
+	mu       sync.Mutex
+	watts    float64
+	sampled  bool
+	inFlight int
+	models   map[string]*ModelEnergy
+}
+
+func NewMeter(sampler PowerSampler) *Meter {
+	return &Meter{sampler: sampler, models: make(map[string]*ModelEnergy)}
+}
+
+// Run samples power draw every interval until ctx is cancelled
+func (m *Meter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.sample()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Meter) sample() {
+	if m.sampler == nil {
+		return
+	}
+	watts, ok := m.sampler()
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	m.watts = watts
+	m.sampled = true
+	m.mu.Unlock()
+}
+
+func (m *Meter) currentWatts() float64 {
+	if m.sampled {
+		return m.watts
+	}
+	return m.FallbackWatts
+}
+
+// Middleware attributes the energy of each inference request to its model
+func (m *Meter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		model, _ := engine.RequestedModel(r)
+
+		m.mu.Lock()
+		m.inFlight++
+		startWatts, startConcurrency := m.currentWatts(), m.inFlight
+		m.mu.Unlock()
+
+		start := time.Now()
+		tw := &tokenWriter{ResponseWriter: w}
+		next.ServeHTTP(tw, r)
+		elapsed := time.Since(start)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		endWatts, endConcurrency := m.currentWatts(), m.inFlight
+		m.inFlight--
+
+		if model == "" {
+			model = w.Header().Get(engine.ServedByHeader)
+		}
+		if model == "" {
+			model = "default"
+		}
+
+		// Concurrent requests share the GPU, so split the draw by the average concurrency
+		watts := (startWatts + endWatts) / 2
+		share := float64(startConcurrency+endConcurrency) / 2
+		wh := watts * elapsed.Hours() / share
+		m.add(model, wh, tw.tokens())
+	})
+}
+
+func (m *Meter) add(model string, wh float64, tokens int) {
+	stats, ok := m.models[model]
+	if !ok {
+		stats = &ModelEnergy{Model: model}
+		m.models[model] = stats
+	}
+	stats.Requests++
+	stats.Tokens += tokens
+	stats.WattHours += wh
+	stats.Cost = stats.WattHours / 1000 * m.PricePerKWh
+	if stats.Tokens > 0 {
+		stats.WhPer1kTokens = stats.WattHours / float64(stats.Tokens) * 1000
+		stats.CostPer1kToken = stats.Cost / float64(stats.Tokens) * 1000
+	}
+}
+
+// Snapshot returns per-model energy totals sorted by model name
+func (m *Meter) Snapshot() []ModelEnergy {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ModelEnergy, 0, len(m.models))
+	for _, stats := range m.models {
+		out = append(out, *stats)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Model < out[j].Model })
+	return out
+}
+
+// tokenWriter captures enough of the response to count generated tokens
+type tokenWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	chunks int
+}
+
+func (t *tokenWriter) Write(b []byte) (int, error) {
+	if strings.HasPrefix(t.Header().Get("Content-Type"), "text/event-stream") {
+		t.chunks += bytes.Count(b, []byte("data: "))
+	} else if t.body.Len() < maxUsageCapture {
+		t.body.Write(b[:min(len(b), maxUsageCapture-t.body.Len())])
+	}
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *tokenWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// tokens reads usage.completion_tokens from JSON responses, or counts SSE chunks (≈1 token
+// each), so JSON and streamed responses both count the tokens generated
+func (t *tokenWriter) tokens() int {
+	if t.chunks > 0 {
+		// The final "data: [DONE]" sentinel carries no token
+		return t.chunks - 1
+	}
+	var payload struct {
+		Usage struct {
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(t.body.Bytes(), &payload); err != nil {
+		return 0
+	}
+	return payload.Usage.CompletionTokens
+}
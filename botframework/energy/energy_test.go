@@ -0,0 +1,55 @@
+package energy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareAttributesEnergyToModel(t *testing.T) {
+	meter := NewMeter(func() (float64, bool) { return 360, true })
+	meter.PricePerKWh = 0.30
+	meter.sample()
+
+	h := meter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"usage":{"prompt_tokens":10,"completion_tokens":40,"total_tokens":50}}`))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"qwen"}`)))
+
+	snap := meter.Snapshot()
+	if len(snap) != 1 || snap[0].Model != "qwen" {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+	got := snap[0]
+	// only generated tokens count, as for streamed responses
+	if got.Tokens != 40 || got.Requests != 1 {
+		t.Fatalf("unexpected totals: %+v", got)
+	}
+	// 360W for >=20ms is at least 0.002Wh
+	if got.WattHours < 0.002 || got.Cost <= 0 || got.WhPer1kTokens <= 0 {
+		t.Fatalf("expected positive energy and cost, got %+v", got)
+	}
+}
+
+func TestStreamingTokensCountedFromChunks(t *testing.T) {
+	meter := NewMeter(nil)
+	meter.FallbackWatts = 50
+
+	h := meter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {}\n\ndata: {}\n\n"))
+		_, _ = w.Write([]byte("data: {}\n\ndata: [DONE]\n\n"))
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("X-Model", "phi")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	snap := meter.Snapshot()
+	if len(snap) != 1 || snap[0].Model != "phi" || snap[0].Tokens != 3 {
+		t.Fatalf("unexpected snapshot: %+v", snap)
+	}
+}
@@ -4,21 +4,61 @@ import (
 	"botframework/profiler"
 	"botframework/supervisor"
 	"context"
-	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"time"
 )
 
 type InferenceEngine interface {
 	Start(ctx context.Context) error
 	ProxyRequest(w http.ResponseWriter, r *http.Request)
 	Health() (*supervisor.WorkerHealth, error)
+	// Status reports the lifecycle state of the engine's worker(s)
+	Status() supervisor.WorkerStatus
 	Stop() error
 }
 
+// ModelManager owns the default engine plus any engines registered per model name.
+// It satisfies InferenceEngine itself, routing each request by its model.
 type ModelManager struct {
-	Engine InferenceEngine
+	Engine        InferenceEngine
+	Profile       *profiler.HardwareProfile
+	Backend       profiler.Engine // inference backend chosen for this host
+	UnknownModels UnknownModelPolicy
+	Loader        ModelLoader
+	Queue         QueueConfig
+	// RequestTimeout bounds how long a request may generate once its worker is awake;
+	// chat defaults may replace it per model, and clients may ask for less with
+	// X-Request-Timeout. Zero leaves requests unbounded.
+	RequestTimeout time.Duration
+	// EmbeddingModel is the registered model answering /v1/embeddings requests that do not
+	// name a registered model, so an embedding model can serve beside the chat model
+	EmbeddingModel string
+	// Residency evicts models loaded on demand to make room for the next one; nil keeps
+	// every loaded model resident
+	Residency *Residency
+
+	mu          sync.RWMutex
+	loadMu      sync.Mutex
+	models      map[string]InferenceEngine
+	fallbacks   map[string][]string
+	shadows     map[string]*shadowState
+	shadowLog   io.Writer
+	shadowLogMu sync.Mutex
+
+	swapMu   sync.Mutex
+	inflight sync.Map // InferenceEngine -> *atomic.Int64, requests currently proxied to it
+	// retired engines were swapped out; they are kept so requests that resolved one before
+	// the swap resolve again instead of reaching a stopped worker
+	retired map[InferenceEngine]bool
+	queues  sync.Map // InferenceEngine -> *admission
+	idle    sync.Map // InferenceEngine -> *idleState, engines unloaded when idle
+	// resident holds the models loaded on demand, InferenceEngine -> *residentModel
+	resident sync.Map
 }
 
 func resolveWorkerScript() string {
@@ -31,19 +71,51 @@ func resolveWorkerScript() string {
 }
 
 func NewSmartManager() *ModelManager {
-	fmt.Println("🔍 Scanning Hardware...")
-	profile := profiler.DetectHardware()
-	fmt.Printf("📊 Hardware Profile: %s\n", profile.String())
+	return NewSmartManagerWith(ManagerOptions{})
+}
 
-	tier := profile.ClassifyTier()
-	fmt.Printf("🏷️  System Tier: %s\n", tier)
+// ManagerOptions overrides what NewSmartManagerWith would otherwise pick
+type ManagerOptions struct {
+	WorkerScript string          // default: worker/main.py next to this package
+	WorkerPort   string          // default: 8081
+	Engine       profiler.Engine // skips the hardware recommendation
+	ModelSizeGB  float64         // model size the recommendation plans for (default: 5.5)
+	// Profile is the host's hardware, such as a cached profile (default: detected now)
+	Profile *profiler.HardwareProfile
+}
+
+// NewSmartManagerWith profiles the host and starts the engine recommended for it
+func NewSmartManagerWith(opts ManagerOptions) *ModelManager {
+	profile := opts.Profile
+	if profile == nil {
+		slog.Info("scanning hardware")
+		profile = profiler.DetectHardware()
+	}
+	slog.Info("hardware profile", "profile", profile.String(), "tier", profile.ClassifyTier())
 
-	targetModelSizeGB := 5.5
+	targetModelSizeGB := opts.ModelSizeGB
+	if targetModelSizeGB <= 0 {
+		targetModelSizeGB = 5.5
+	}
 	recommendedEngine := profile.GetRecommendedEngine(targetModelSizeGB)
-	fmt.Printf("⚙️  Recommended Engine: %s\n", recommendedEngine)
+	if opts.Engine != "" {
+		slog.Info("engine overridden", "engine", opts.Engine, "recommended", recommendedEngine)
+		recommendedEngine = opts.Engine
+	} else {
+		slog.Info("engine recommended", "engine", recommendedEngine)
+	}
 
-	workerScript := resolveWorkerScript()
-	return NewManagerForEngine(workerScript, "8081", recommendedEngine)
+	workerScript := opts.WorkerScript
+	if workerScript == "" {
+		workerScript = resolveWorkerScript()
+	}
+	port := opts.WorkerPort
+	if port == "" {
+		port = "8081"
+	}
+	manager := NewManagerForEngine(workerScript, port, recommendedEngine)
+	manager.Profile = profile
+	return manager
 }
 
 func NewManagerForEngine(workerScript, port string, recommendedEngine profiler.Engine) *ModelManager {
@@ -51,18 +123,24 @@ func NewManagerForEngine(workerScript, port string, recommendedEngine profiler.E
 
 	switch recommendedEngine {
 	case profiler.EngineMLX:
-		fmt.Println("🍎 Starting MLX Backend (Apple Silicon)")
+		slog.Info("starting backend", "backend", "MLX (Apple Silicon)")
 		selectedEngine = supervisor.NewPythonWorker(workerScript, port)
 	case profiler.EngineVLLM:
-		fmt.Println("🚀 Starting vLLM Backend (High Performance)")
+		slog.Info("starting backend", "backend", "vLLM (High Performance)")
 		selectedEngine = supervisor.NewPythonWorker(workerScript, port)
 	case profiler.EngineExLlamaV2:
-		fmt.Println("⚡ Starting ExLlamaV2 Backend")
+		slog.Info("starting backend", "backend", "ExLlamaV2")
+		selectedEngine = supervisor.NewPythonWorker(workerScript, port)
+	case profiler.EngineIPEXLLM:
+		slog.Info("starting backend", "backend", "IPEX-LLM (Intel Arc)")
+		selectedEngine = supervisor.NewPythonWorker(workerScript, port)
+	case profiler.EngineLlamaCPPSYCL:
+		slog.Info("starting backend", "backend", "llama.cpp SYCL (Intel Arc)")
 		selectedEngine = supervisor.NewPythonWorker(workerScript, port)
 	default:
-		fmt.Println("🐢 Starting llama.cpp Backend (Universal/CPU)")
+		slog.Info("starting backend", "backend", "llama.cpp (Universal/CPU)")
 		selectedEngine = supervisor.NewPythonWorker(workerScript, port)
 	}
 
-	return &ModelManager{Engine: selectedEngine}
+	return &ModelManager{Engine: selectedEngine, Backend: recommendedEngine}
 }
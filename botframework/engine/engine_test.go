@@ -10,6 +10,14 @@ func TestPythonWorkerSatisfiesInferenceEngine(t *testing.T) {
 	var _ InferenceEngine = (*supervisor.PythonWorker)(nil)
 }
 
+func TestClusterWorkerSatisfiesInferenceEngine(t *testing.T) {
+	var _ InferenceEngine = (*supervisor.ClusterWorker)(nil)
+}
+
+func TestModelManagerSatisfiesInferenceEngine(t *testing.T) {
+	var _ InferenceEngine = (*ModelManager)(nil)
+}
+
 func TestNewManagerForEngineCreatesEngine(t *testing.T) {
 	tests := []struct {
 		name   string
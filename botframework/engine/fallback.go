@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"botframework/openai"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+const (
+	ServedByHeader = "X-BotFramework-Served-By"
+	FallbackHeader = "X-BotFramework-Fallback"
+)
+
+// availability is implemented by engines that can report they are temporarily unable to serve
+type availability interface {
+	Available() bool
+}
+
+// SetFallbacks configures the models tried, in order, when the named model cannot serve a request
+func (m *ModelManager) SetFallbacks(model string, chain []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fallbacks == nil {
+		m.fallbacks = make(map[string][]string)
+	}
+	m.fallbacks[model] = append([]string(nil), chain...)
+}
+
+func (m *ModelManager) fallbackChain(model string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.fallbacks[model]
+}
+
+// retryableStatus reports whether a response means the engine is overloaded or failing
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// proxyWithFallback tries the primary engine and then each fallback model until one accepts the request
+func (m *ModelManager) proxyWithFallback(w http.ResponseWriter, r *http.Request, model string, primary InferenceEngine, chain []string) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "", "failed to read request body")
+			return
+		}
+		_ = r.Body.Close()
+	}
+
+	candidates := append([]string{model}, chain...)
+	for i, name := range candidates {
+		e := primary
+		if i > 0 {
+			resolved, err := m.Resolve(name)
+			if err != nil {
+				continue
+			}
+			e = resolved
+		}
+		last := i == len(candidates)-1
+		if a, ok := e.(availability); ok && !a.Available() && !last {
+			continue
+		}
+
+		attempt := r.Clone(r.Context())
+		attempt.Body = io.NopCloser(bytes.NewReader(body))
+		attempt.ContentLength = int64(len(body))
+
+		fw := &fallbackWriter{w: w, header: make(http.Header), last: last, servedBy: name}
+		if i > 0 {
+			fw.fallbackFrom = model
+		}
+		e.ProxyRequest(fw, attempt)
+		if !fw.retry {
+			return
+		}
+		if err := r.Context().Err(); err != nil {
+			// a request past its deadline or abandoned by its client is not retried elsewhere
+			if errors.Is(err, context.DeadlineExceeded) {
+				openai.WriteError(w, http.StatusGatewayTimeout, "server_error", "timeout", "request timed out")
+			}
+			return
+		}
+	}
+
+	openai.WriteError(w, http.StatusServiceUnavailable, "server_error", "model_unavailable", "no engine in the fallback chain could serve the request")
+}
+
+// fallbackWriter holds back a retryable response so the next engine in the chain can be tried
+type fallbackWriter struct {
+	w            http.ResponseWriter
+	header       http.Header
+	last         bool
+	retry        bool
+	committed    bool
+	servedBy     string
+	fallbackFrom string
+}
+
+func (f *fallbackWriter) Header() http.Header {
+	return f.header
+}
+
+func (f *fallbackWriter) WriteHeader(code int) {
+	if f.committed || f.retry {
+		return
+	}
+	if retryableStatus(code) && !f.last {
+		f.retry = true
+		return
+	}
+
+	dst := f.w.Header()
+	for k, v := range f.header {
+		dst[k] = v
+	}
+	dst.Set(ServedByHeader, f.servedBy)
+	if f.fallbackFrom != "" {
+		dst.Set(FallbackHeader, f.fallbackFrom+" -> "+f.servedBy)
+	}
+	f.committed = true
+	f.w.WriteHeader(code)
+}
+
+func (f *fallbackWriter) Write(b []byte) (int, error) {
+	if !f.committed && !f.retry {
+		f.WriteHeader(http.StatusOK)
+	}
+	if f.retry {
+		return len(b), nil
+	}
+	return f.w.Write(b)
+}
+
+func (f *fallbackWriter) Flush() {
+	if !f.committed {
+		return
+	}
+	if flusher, ok := f.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type statusEngine struct {
+	stubEngine
+	status    int
+	available bool
+	calls     int
+}
+
+func (s *statusEngine) Available() bool { return s.available }
+func (s *statusEngine) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	s.calls++
+	body, _ := io.ReadAll(r.Body)
+	s.body = string(body)
+	w.WriteHeader(s.status)
+	_, _ = w.Write([]byte(s.name))
+}
+
+var _ InferenceEngine = (*statusEngine)(nil)
+
+func TestFallbackOnRetryableStatus(t *testing.T) {
+	big := &statusEngine{stubEngine: stubEngine{name: "big"}, status: http.StatusServiceUnavailable, available: true}
+	small := &statusEngine{stubEngine: stubEngine{name: "small"}, status: http.StatusOK, available: true}
+	m := &ModelManager{Engine: big}
+	m.Register("big", big)
+	m.Register("small", small)
+	m.SetFallbacks("big", []string{"small"})
+
+	body := `{"model":"big"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	m.ProxyRequest(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "small" {
+		t.Fatalf("expected small to serve, got %d %q", rr.Code, rr.Body.String())
+	}
+	if small.body != body {
+		t.Fatalf("expected body replayed to fallback, got %q", small.body)
+	}
+	if got := rr.Header().Get(FallbackHeader); got != "big -> small" {
+		t.Fatalf("unexpected fallback header %q", got)
+	}
+	if got := rr.Header().Get(ServedByHeader); got != "small" {
+		t.Fatalf("unexpected served-by header %q", got)
+	}
+}
+
+func TestFallbackSkipsUnavailableEngine(t *testing.T) {
+	big := &statusEngine{stubEngine: stubEngine{name: "big"}, status: http.StatusOK, available: false}
+	small := &statusEngine{stubEngine: stubEngine{name: "small"}, status: http.StatusOK, available: true}
+	m := &ModelManager{Engine: big}
+	m.Register("big", big)
+	m.Register("small", small)
+	m.SetFallbacks("big", []string{"small"})
+
+	rr := httptest.NewRecorder()
+	m.ProxyRequest(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"model":"big"}`)))
+
+	if big.calls != 0 || small.calls != 1 {
+		t.Fatalf("expected only small to be called, got big=%d small=%d", big.calls, small.calls)
+	}
+}
+
+func TestFallbackLastEngineErrorIsReturned(t *testing.T) {
+	big := &statusEngine{stubEngine: stubEngine{name: "big"}, status: http.StatusBadGateway, available: true}
+	small := &statusEngine{stubEngine: stubEngine{name: "small"}, status: http.StatusBadGateway, available: true}
+	m := &ModelManager{Engine: big}
+	m.Register("big", big)
+	m.Register("small", small)
+	m.SetFallbacks("big", []string{"small"})
+
+	rr := httptest.NewRecorder()
+	m.ProxyRequest(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"model":"big"}`)))
+
+	if rr.Code != http.StatusBadGateway || rr.Body.String() != "small" {
+		t.Fatalf("expected last engine's 502 to pass through, got %d %q", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,378 @@
+package engine
+
+import (
+	"botframework/openai"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// OpenAI routes served by the gateway
+const (
+	ChatCompletionsPath = "/v1/chat/completions"
+	TextCompletionsPath = "/v1/completions"
+	EmbeddingsPath      = "/v1/embeddings"
+)
+
+// Dialect describes how a backend deviates from the OpenAI API, so the gateway can accept
+// standard requests from any OpenAI SDK whichever engine was provisioned
+type Dialect struct {
+	Name string
+	// NativeCompletions is set when the backend serves /v1/completions; otherwise the
+	// gateway emulates it through chat completions
+	NativeCompletions bool
+	// MaxCompletionTokens is set when the backend understands max_completion_tokens;
+	// otherwise it is sent as max_tokens
+	MaxCompletionTokens bool
+	// FlattenContent joins multi-part message content that only carries text into a string
+	FlattenContent bool
+	// Drop lists request fields the backend rejects
+	Drop []string
+	// Model replaces the request's model, for backends that would load any model a
+	// request names rather than serve the one they were started with
+	Model string
+}
+
+var (
+	// DialectWorker is the BotFramework Python worker (llama-cpp-python), which only serves
+	// chat completions with string message content
+	DialectWorker = Dialect{Name: "botframework-worker", FlattenContent: true, Drop: []string{"stream_options", "n"}}
+	// DialectLlamaServer is llama.cpp's llama-server
+	DialectLlamaServer = Dialect{Name: "llama-server", NativeCompletions: true}
+	// DialectVLLM is vLLM's OpenAI-compatible server
+	DialectVLLM = Dialect{Name: "vllm", NativeCompletions: true, MaxCompletionTokens: true}
+	// DialectMLX is mlx_lm.server, which serves the model on its command line as "default_model"
+	DialectMLX = Dialect{Name: "mlx", NativeCompletions: true, Drop: []string{"stream_options", "n"}, Model: "default_model"}
+)
+
+// dialecter is implemented by engines whose backend is not the BotFramework Python worker.
+// Engines report the dialect by name because the supervisor package cannot import engine.
+type dialecter interface {
+	Dialect() string
+}
+
+var dialects = map[string]Dialect{
+	DialectWorker.Name:      DialectWorker,
+	DialectLlamaServer.Name: DialectLlamaServer,
+	DialectVLLM.Name:        DialectVLLM,
+	DialectMLX.Name:         DialectMLX,
+}
+
+// DialectOf returns the dialect spoken by e's backend
+func DialectOf(e InferenceEngine) Dialect {
+	if d, ok := e.(dialecter); ok {
+		if dialect, ok := dialects[d.Dialect()]; ok {
+			return dialect
+		}
+	}
+	return DialectWorker
+}
+
+// Gateway is the manager's OpenAI-compatible API surface. It validates chat and text
+// completion requests, translates them for the backend that serves the requested model,
+// and proxies other /v1/ routes untouched.
+type Gateway struct {
+	Manager *ModelManager
+}
+
+func NewGateway(m *ModelManager) *Gateway {
+	return &Gateway{Manager: m}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == ChatCompletionsPath:
+		g.chat(w, r)
+	case r.URL.Path == TextCompletionsPath:
+		g.completions(w, r)
+	case r.URL.Path == EmbeddingsPath:
+		g.embeddings(w, r)
+	case strings.HasPrefix(r.URL.Path, "/v1/"):
+		g.Manager.ProxyRequest(w, r)
+	default:
+		openai.WriteError(w, http.StatusNotFound, "invalid_request_error", "unknown_url", fmt.Sprintf("unknown route %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// readBody decodes a JSON request body, answering the client itself when it cannot
+func readBody(w http.ResponseWriter, r *http.Request) (map[string]json.RawMessage, bool) {
+	if r.Method != http.MethodPost {
+		openai.WriteError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method_not_allowed", "use POST")
+		return nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRoutingBody+1))
+	if err != nil || len(body) > maxRoutingBody {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "", "could not read request body")
+		return nil, false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "invalid_json", "request body is not a JSON object")
+		return nil, false
+	}
+	return fields, true
+}
+
+// dialectFor resolves the engine serving the request's model. Resolution errors are left for
+// ProxyRequest to report.
+func (g *Gateway) dialectFor(r *http.Request, fields map[string]json.RawMessage) Dialect {
+	model := r.Header.Get(ModelHeader)
+	if model == "" {
+		_ = json.Unmarshal(fields["model"], &model)
+	}
+	e, err := g.Manager.Resolve(model)
+	if err != nil {
+		return DialectWorker
+	}
+	return DialectOf(e)
+}
+
+func (g *Gateway) chat(w http.ResponseWriter, r *http.Request) {
+	fields, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	var messages []map[string]any
+	if err := json.Unmarshal(fields["messages"], &messages); err != nil || len(messages) == 0 {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "missing_messages", "messages must be a non-empty array")
+		return
+	}
+
+	d := g.dialectFor(r, fields)
+	if d.FlattenContent {
+		for _, message := range messages {
+			if text, ok := textOnly(message["content"]); ok {
+				message["content"] = text
+			}
+		}
+		fields["messages"], _ = json.Marshal(messages)
+	}
+	d.translate(fields)
+	g.forward(w, r, ChatCompletionsPath, fields)
+}
+
+func (g *Gateway) completions(w http.ResponseWriter, r *http.Request) {
+	fields, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	d := g.dialectFor(r, fields)
+	if d.NativeCompletions {
+		d.translate(fields)
+		g.forward(w, r, TextCompletionsPath, fields)
+		return
+	}
+
+	prompt, err := singlePrompt(fields["prompt"])
+	if err != nil {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "unsupported_prompt", err.Error())
+		return
+	}
+	for _, name := range []string{"prompt", "suffix", "echo", "best_of", "logprobs"} {
+		delete(fields, name)
+	}
+	fields["messages"], _ = json.Marshal([]map[string]string{{"role": "user", "content": prompt}})
+	d.translate(fields)
+	cw := &completionWriter{ResponseWriter: w}
+	g.forward(cw, r, ChatCompletionsPath, fields)
+	cw.finish()
+}
+
+// embeddings sends requests that do not name a registered model to the embedding model, so
+// clients may pass any model name (as OpenAI SDKs require) and still reach it
+func (g *Gateway) embeddings(w http.ResponseWriter, r *http.Request) {
+	fields, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+	if raw, ok := fields["input"]; !ok || string(raw) == "null" || string(raw) == `""` || string(raw) == "[]" {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "missing_input", "input must be a non-empty string or array")
+		return
+	}
+	model := r.Header.Get(ModelHeader)
+	if model == "" {
+		_ = json.Unmarshal(fields["model"], &model)
+	}
+	if _, err := g.Manager.registered(model); err != nil && g.Manager.EmbeddingModel != "" {
+		r = r.Clone(r.Context())
+		r.Header.Del(ModelHeader)
+		fields["model"], _ = json.Marshal(g.Manager.EmbeddingModel)
+	}
+	g.forward(w, r, EmbeddingsPath, fields)
+}
+
+// forward sends fields to the engine as a request for path
+func (g *Gateway) forward(w http.ResponseWriter, r *http.Request, path string, fields map[string]json.RawMessage) {
+	body, _ := json.Marshal(fields)
+	out := r.Clone(r.Context())
+	out.URL.Path = path
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+	out.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	g.Manager.ProxyRequest(w, out)
+}
+
+// translate rewrites standard request fields into the ones the backend understands
+func (d Dialect) translate(fields map[string]json.RawMessage) {
+	if raw, ok := fields["max_completion_tokens"]; ok && !d.MaxCompletionTokens {
+		if _, set := fields["max_tokens"]; !set {
+			fields["max_tokens"] = raw
+		}
+		delete(fields, "max_completion_tokens")
+	}
+	for _, name := range d.Drop {
+		delete(fields, name)
+	}
+	if d.Model != "" {
+		fields["model"], _ = json.Marshal(d.Model)
+	}
+}
+
+// textOnly joins content made only of text parts; ok is false for strings and content with
+// images or other non-text parts
+func textOnly(content any) (string, bool) {
+	parts, ok := content.([]any)
+	if !ok {
+		return "", false
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		p, ok := part.(map[string]any)
+		if !ok || p["type"] != "text" {
+			return "", false
+		}
+		text, _ := p["text"].(string)
+		texts = append(texts, text)
+	}
+	return strings.Join(texts, "\n"), true
+}
+
+// singlePrompt accepts a string prompt or a one-element list of strings
+func singlePrompt(raw json.RawMessage) (string, error) {
+	var prompt string
+	if err := json.Unmarshal(raw, &prompt); err == nil {
+		return prompt, nil
+	}
+	var prompts []string
+	if err := json.Unmarshal(raw, &prompts); err == nil && len(prompts) == 1 {
+		return prompts[0], nil
+	}
+	return "", fmt.Errorf("prompt must be a string or a single-element list of strings on this backend")
+}
+
+// completionWriter converts chat completion responses into legacy text completion responses
+type completionWriter struct {
+	http.ResponseWriter
+	decided  bool
+	sse      bool
+	buffered bool
+	pending  []byte
+	body     bytes.Buffer
+}
+
+func (c *completionWriter) WriteHeader(code int) {
+	if !c.decided {
+		c.decided = true
+		contentType := c.Header().Get("Content-Type")
+		ok := code >= 200 && code <= 299
+		c.sse = ok && strings.HasPrefix(contentType, "text/event-stream")
+		c.buffered = ok && strings.HasPrefix(contentType, "application/json")
+		if c.sse || c.buffered {
+			c.Header().Del("Content-Length")
+		}
+	}
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *completionWriter) Write(b []byte) (int, error) {
+	if !c.decided {
+		c.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case c.buffered:
+		return c.body.Write(b)
+	case c.sse:
+		c.pending = append(c.pending, b...)
+		for {
+			end := bytes.Index(c.pending, []byte("\n\n"))
+			if end < 0 {
+				return len(b), nil
+			}
+			event := convertEvent(string(c.pending[:end]))
+			c.pending = c.pending[end+2:]
+			if _, err := io.WriteString(c.ResponseWriter, event+"\n\n"); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *completionWriter) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *completionWriter) finish() {
+	switch {
+	case c.buffered:
+		c.ResponseWriter.Write(convertCompletion(c.body.Bytes(), "message"))
+		c.buffered = false
+	case c.sse && len(c.pending) > 0:
+		io.WriteString(c.ResponseWriter, convertEvent(string(c.pending)))
+		c.pending = nil
+	}
+}
+
+func convertEvent(event string) string {
+	lines := strings.Split(event, "\n")
+	for i, line := range lines {
+		payload, ok := strings.CutPrefix(line, "data:")
+		if !ok || strings.TrimSpace(payload) == "[DONE]" {
+			continue
+		}
+		lines[i] = "data: " + string(convertCompletion([]byte(strings.TrimSpace(payload)), "delta"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// convertCompletion turns a chat completion body or chunk into its text completion form,
+// taking each choice's text from field ("message" or "delta")
+func convertCompletion(body []byte, field string) []byte {
+	var response map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&response); err != nil {
+		return body
+	}
+	response["object"] = "text_completion"
+	if id, ok := response["id"].(string); ok {
+		response["id"] = "cmpl-" + strings.TrimPrefix(id, "chatcmpl-")
+	}
+	choices, _ := response["choices"].([]any)
+	for _, raw := range choices {
+		choice, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		text := ""
+		if m, ok := choice[field].(map[string]any); ok {
+			text, _ = m["content"].(string)
+		}
+		delete(choice, field)
+		choice["text"] = text
+		if _, ok := choice["logprobs"]; !ok {
+			choice["logprobs"] = nil
+		}
+	}
+	out, err := json.Marshal(response)
+	if err != nil {
+		return body
+	}
+	return out
+}
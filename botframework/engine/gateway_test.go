@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// chatBackend records what it receives and answers like the Python worker
+type chatBackend struct {
+	stubEngine
+	path   string
+	fields map[string]any
+	stream bool
+}
+
+func (c *chatBackend) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	c.path = r.URL.Path
+	body, _ := io.ReadAll(r.Body)
+	c.fields = nil
+	json.Unmarshal(body, &c.fields)
+	if c.stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hel"},"finish_reason":null}]}`+"\n\n")
+		io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, `{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`)
+}
+
+type vllmBackend struct{ chatBackend }
+
+func (v *vllmBackend) Dialect() string { return DialectVLLM.Name }
+
+type mlxBackend struct{ chatBackend }
+
+func (m *mlxBackend) Dialect() string { return DialectMLX.Name }
+
+func gatewayRequest(g *Gateway, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestGatewayTranslatesChatForWorker(t *testing.T) {
+	worker := &chatBackend{}
+	g := NewGateway(&ModelManager{Engine: worker})
+
+	rec := gatewayRequest(g, ChatCompletionsPath, `{"model":"m","n":1,"max_completion_tokens":64,"messages":[{"role":"user","content":[{"type":"text","text":"a"},{"type":"text","text":"b"}]}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if worker.fields["max_tokens"] != float64(64) || worker.fields["max_completion_tokens"] != nil || worker.fields["n"] != nil {
+		t.Errorf("unexpected forwarded fields: %v", worker.fields)
+	}
+	messages := worker.fields["messages"].([]any)
+	if content := messages[0].(map[string]any)["content"]; content != "a\nb" {
+		t.Errorf("text parts should be flattened, got %v", content)
+	}
+}
+
+func TestGatewayKeepsFieldsForVLLM(t *testing.T) {
+	backend := &vllmBackend{}
+	g := NewGateway(&ModelManager{Engine: backend})
+
+	gatewayRequest(g, ChatCompletionsPath, `{"model":"m","max_completion_tokens":64,"messages":[{"role":"user","content":"hi"}]}`)
+	if backend.fields["max_completion_tokens"] != float64(64) {
+		t.Errorf("vLLM understands max_completion_tokens, got %v", backend.fields)
+	}
+
+	gatewayRequest(g, TextCompletionsPath, `{"model":"m","prompt":"Once"}`)
+	if backend.path != TextCompletionsPath || backend.fields["prompt"] != "Once" {
+		t.Errorf("vLLM serves completions natively, got %s %v", backend.path, backend.fields)
+	}
+}
+
+func TestGatewayNamesDefaultModelForMLX(t *testing.T) {
+	backend := &mlxBackend{}
+	g := NewGateway(&ModelManager{Engine: backend})
+
+	gatewayRequest(g, ChatCompletionsPath, `{"model":"qwen-2.5-7b","n":1,"messages":[{"role":"user","content":"hi"}]}`)
+	if backend.fields["model"] != "default_model" || backend.fields["n"] != nil {
+		t.Errorf("mlx_lm.server should be asked for its own model, got %v", backend.fields)
+	}
+}
+
+func TestGatewayEmulatesCompletions(t *testing.T) {
+	worker := &chatBackend{}
+	g := NewGateway(&ModelManager{Engine: worker})
+
+	rec := gatewayRequest(g, TextCompletionsPath, `{"model":"m","prompt":["Say hello"],"echo":false,"max_tokens":5}`)
+	if worker.path != ChatCompletionsPath || worker.fields["prompt"] != nil || worker.fields["echo"] != nil {
+		t.Fatalf("expected a chat request, got %s %v", worker.path, worker.fields)
+	}
+	var response struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		Choices []struct {
+			Text    string `json:"text"`
+			Message any    `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Object != "text_completion" || response.ID != "cmpl-1" || response.Choices[0].Text != "Hello" || response.Choices[0].Message != nil {
+		t.Errorf("unexpected response: %s", rec.Body.String())
+	}
+
+	worker.stream = true
+	rec = gatewayRequest(g, TextCompletionsPath, `{"model":"m","prompt":"Say hello","stream":true}`)
+	body := rec.Body.String()
+	if strings.Count(body, `"object":"text_completion"`) != 2 || !strings.Contains(body, `"text":"lo"`) || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("unexpected stream: %s", body)
+	}
+
+	rec = gatewayRequest(g, TextCompletionsPath, `{"model":"m","prompt":["a","b"]}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("multiple prompts should be rejected, got %d", rec.Code)
+	}
+}
+
+func TestGatewayValidatesRequests(t *testing.T) {
+	g := NewGateway(&ModelManager{Engine: &chatBackend{}})
+	cases := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, ChatCompletionsPath, "", http.StatusMethodNotAllowed},
+		{http.MethodPost, ChatCompletionsPath, "not json", http.StatusBadRequest},
+		{http.MethodPost, ChatCompletionsPath, `{"model":"m","messages":[]}`, http.StatusBadRequest},
+		{http.MethodGet, "/wp-admin", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		g.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		if rec.Code != c.want {
+			t.Errorf("%s %s: got %d, want %d", c.method, c.path, rec.Code, c.want)
+		}
+		if !strings.Contains(rec.Body.String(), `"error"`) {
+			t.Errorf("%s %s: expected an OpenAI error body, got %s", c.method, c.path, rec.Body.String())
+		}
+	}
+}
+
+func TestGatewayRoutesEmbeddingsToEmbeddingModel(t *testing.T) {
+	chat, embedder := &chatBackend{}, &chatBackend{}
+	m := &ModelManager{Engine: chat, EmbeddingModel: "nomic-embed"}
+	m.Register("llama", chat)
+	m.Register("nomic-embed", embedder)
+	g := NewGateway(m)
+
+	rec := gatewayRequest(g, EmbeddingsPath, `{"model":"text-embedding-3-small","input":["a","b"]}`)
+	if rec.Code != http.StatusOK || embedder.path != EmbeddingsPath || embedder.fields["model"] != "nomic-embed" {
+		t.Fatalf("status %d, embedder got %s %v", rec.Code, embedder.path, embedder.fields)
+	}
+	if chat.path != "" {
+		t.Errorf("chat model should not see the request, got %s", chat.path)
+	}
+
+	// a registered model is served as named
+	embedder.path = ""
+	gatewayRequest(g, EmbeddingsPath, `{"model":"llama","input":"a"}`)
+	if chat.path != EmbeddingsPath || embedder.path != "" {
+		t.Errorf("request naming llama went to chat %q, embedder %q", chat.path, embedder.path)
+	}
+
+	if rec := gatewayRequest(g, EmbeddingsPath, `{"model":"nomic-embed","input":[]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty input: status %d, want 400", rec.Code)
+	}
+}
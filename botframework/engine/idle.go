@@ -0,0 +1,254 @@
+package engine
+
+import (
+	"botframework/openai"
+	"botframework/supervisor"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ColdStart controls what requests for an unloaded model get while its worker starts again
+type ColdStart string
+
+const (
+	ColdStartQueue   ColdStart = "queue"   // hold the request until the worker is ready
+	ColdStartLoading ColdStart = "loading" // answer 503 model_loading with a Retry-After hint
+)
+
+// AllModels keys the idle timeout that applies to models not named in the spec
+const AllModels = "*"
+
+// idleCheckInterval is how often UnloadIdle looks for idle engines
+var idleCheckInterval = 15 * time.Second
+
+// defaultLoadTime is the Retry-After hint before an engine has been timed starting
+const defaultLoadTime = 10 * time.Second
+
+// IdlePolicy unloads an engine that has served no request for Timeout
+type IdlePolicy struct {
+	Timeout   time.Duration
+	ColdStart ColdStart
+}
+
+// relauncher is implemented by workers that can start again after Stop
+type relauncher interface{ Relaunch() error }
+
+// idleState tracks one engine's use. mu is held while the engine is stopped, so a request
+// that arrives meanwhile waits and then starts it again.
+type idleState struct {
+	model  string
+	policy IdlePolicy
+
+	mu       sync.Mutex
+	lastUsed time.Time
+	unloaded bool
+	starting *coldStart
+	loadTime time.Duration // how long the last start took
+}
+
+// coldStart is one start of an unloaded engine; done is closed when it finishes
+type coldStart struct {
+	done chan struct{}
+	err  error
+}
+
+// ParseIdleTimeouts reads an idle timeout spec: a bare duration for every model ("30m"),
+// durations by model name ("default=30m;quality=10m") or both ("30m;quality=10m"). "default"
+// names the default engine and 0 keeps a model loaded.
+func ParseIdleTimeouts(spec string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			model, value = AllModels, entry
+		}
+		model, value = strings.TrimSpace(model), strings.TrimSpace(value)
+		if model == "" {
+			return nil, fmt.Errorf("%q: missing model name", entry)
+		}
+		if _, dup := timeouts[model]; dup {
+			return nil, fmt.Errorf("%q: timeout set twice", model)
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("%q: want a duration like 30m", entry)
+		}
+		timeouts[model] = timeout
+	}
+	return timeouts, nil
+}
+
+// SetIdlePolicy unloads e, served as model, after policy.Timeout without requests; the next
+// request starts it again. Only workers that can be relaunched after stopping qualify.
+func (m *ModelManager) SetIdlePolicy(model string, e InferenceEngine, policy IdlePolicy) error {
+	if _, ok := e.(relauncher); !ok {
+		return errors.New("the worker cannot be restarted once unloaded")
+	}
+	if policy.Timeout <= 0 {
+		m.idle.Delete(e)
+		return nil
+	}
+	if policy.ColdStart == "" {
+		policy.ColdStart = ColdStartQueue
+	}
+	m.idle.Store(e, &idleState{model: model, policy: policy, lastUsed: time.Now()})
+	return nil
+}
+
+// Unloaded reports whether e was stopped for being idle
+func (m *ModelManager) Unloaded(e InferenceEngine) bool {
+	v, ok := m.idle.Load(e)
+	if !ok {
+		return false
+	}
+	state := v.(*idleState)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.unloaded
+}
+
+// UnloadIdle stops engines that outlive their idle timeout until ctx is done
+func (m *ModelManager) UnloadIdle(ctx context.Context) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.unloadIdle(now)
+		}
+	}
+}
+
+func (m *ModelManager) unloadIdle(now time.Time) {
+	m.idle.Range(func(key, value any) bool {
+		e, state := key.(InferenceEngine), value.(*idleState)
+		m.mu.RLock()
+		retired := m.retired[e]
+		m.mu.RUnlock()
+		if retired {
+			m.idle.Delete(e)
+			return true
+		}
+
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		idle := now.Sub(state.lastUsed)
+		if state.unloaded || idle < state.policy.Timeout || m.running(e) > 0 || e.Status().State != supervisor.StateRunning {
+			return true
+		}
+		slog.Info("unloading idle model", "model", state.model, "idle", idle.Round(time.Second))
+		if err := e.Stop(); err != nil {
+			slog.Warn("unloading idle model failed", "model", state.model, "err", err)
+			return true
+		}
+		state.unloaded = true
+		return true
+	})
+}
+
+// running counts the requests currently proxied to e
+func (m *ModelManager) running(e InferenceEngine) int64 {
+	counter, ok := m.inflight.Load(e)
+	if !ok {
+		return 0
+	}
+	return counter.(*atomic.Int64).Load()
+}
+
+// wake marks e as used and, when it was unloaded, starts it again. It reports false once it
+// has answered the request itself: with 503 model_loading in the loading cold-start mode, or
+// with the error that kept the worker from starting.
+func (m *ModelManager) wake(w http.ResponseWriter, r *http.Request, e InferenceEngine) bool {
+	v, ok := m.idle.Load(e)
+	if !ok {
+		return true
+	}
+	state := v.(*idleState)
+	state.mu.Lock()
+	state.lastUsed = time.Now()
+	// an admin relaunch may have started the worker behind our back
+	if state.unloaded && state.starting == nil && e.Status().State == supervisor.StateRunning {
+		state.unloaded = false
+	}
+	if !state.unloaded {
+		state.mu.Unlock()
+		return true
+	}
+	if state.starting == nil {
+		state.starting = &coldStart{done: make(chan struct{})}
+		go state.start(e, state.starting)
+	}
+	start, loadTime := state.starting, state.loadTime
+	state.mu.Unlock()
+
+	if state.policy.ColdStart == ColdStartLoading {
+		if loadTime == 0 {
+			loadTime = defaultLoadTime
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(loadTime.Seconds())))))
+		openai.WriteError(w, http.StatusServiceUnavailable, "server_error", "model_loading",
+			fmt.Sprintf("model %q is loading; retry later", state.model))
+		return false
+	}
+	select {
+	case <-start.done:
+	case <-r.Context().Done():
+		return false
+	}
+	if start.err != nil {
+		openai.WriteError(w, http.StatusServiceUnavailable, "server_error", "model_unavailable",
+			fmt.Sprintf("model %q failed to load: %v", state.model, start.err))
+		return false
+	}
+	return true
+}
+
+// markUsed restarts e's idle timer and, for models loaded on demand, the clock eviction goes by
+// when a request finishes
+func (m *ModelManager) markUsed(e InferenceEngine) {
+	if v, ok := m.idle.Load(e); ok {
+		state := v.(*idleState)
+		state.mu.Lock()
+		state.lastUsed = time.Now()
+		state.mu.Unlock()
+	}
+	if v, ok := m.resident.Load(e); ok {
+		v.(*residentModel).touch()
+	}
+}
+
+// start relaunches an unloaded engine. A failed start leaves it unloaded, so the next
+// request tries again.
+func (s *idleState) start(e InferenceEngine, start *coldStart) {
+	slog.Info("loading idle model", "model", s.model)
+	began := time.Now()
+	start.err = e.(relauncher).Relaunch()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.starting = nil
+	s.lastUsed = time.Now()
+	if start.err != nil {
+		slog.Error("loading idle model failed", "model", s.model, "err", start.err)
+	} else {
+		s.unloaded = false
+		s.loadTime = time.Since(began)
+		slog.Info("idle model loaded", "model", s.model, "load_ms", s.loadTime.Milliseconds())
+	}
+	close(start.done)
+}
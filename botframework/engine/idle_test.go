@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"botframework/supervisor"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// relaunchEngine is a worker that can be stopped and started again
+type relaunchEngine struct {
+	stubEngine
+	running    atomic.Bool
+	relaunches atomic.Int32
+}
+
+func (r *relaunchEngine) Status() supervisor.WorkerStatus {
+	if r.running.Load() {
+		return supervisor.WorkerStatus{State: supervisor.StateRunning}
+	}
+	return supervisor.WorkerStatus{State: supervisor.StateStopped}
+}
+
+func (r *relaunchEngine) Stop() error {
+	r.running.Store(false)
+	return r.stubEngine.Stop()
+}
+
+func (r *relaunchEngine) Relaunch() error {
+	r.relaunches.Add(1)
+	r.running.Store(true)
+	return nil
+}
+
+func TestParseIdleTimeouts(t *testing.T) {
+	timeouts, err := ParseIdleTimeouts("30m; quality=10m ;default=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeouts[AllModels] != 30*time.Minute || timeouts["quality"] != 10*time.Minute || timeouts["default"] != 0 || len(timeouts) != 3 {
+		t.Fatalf("unexpected timeouts %v", timeouts)
+	}
+	for _, spec := range []string{"soon", "=5m", "fast=-1m", "fast=1m;fast=2m", "1m;2m"} {
+		if _, err := ParseIdleTimeouts(spec); err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+}
+
+func TestIdleEngineUnloadsAndStartsAgain(t *testing.T) {
+	worker := &relaunchEngine{stubEngine: stubEngine{name: "quality"}}
+	worker.running.Store(true)
+	m := &ModelManager{Engine: worker}
+	if err := m.SetIdlePolicy("quality", worker, IdlePolicy{Timeout: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+
+	m.unloadIdle(time.Now())
+	if worker.stopped.Load() != 0 {
+		t.Fatal("a recently used worker was unloaded")
+	}
+	m.unloadIdle(time.Now().Add(2 * time.Minute))
+	if worker.stopped.Load() != 1 || !m.Unloaded(worker) {
+		t.Fatal("an idle worker was not unloaded")
+	}
+	if health, err := m.Health(); err != nil || health.Status != "unloaded" {
+		t.Fatalf("Health() = %+v, %v; want unloaded", health, err)
+	}
+
+	rr := serve(m, `{"model":"quality"}`, "")
+	if got := rr.Header().Get("X-Served-By"); got != "quality" || worker.relaunches.Load() != 1 {
+		t.Fatalf("queued request served by %q after %d relaunches", got, worker.relaunches.Load())
+	}
+	if m.Unloaded(worker) {
+		t.Error("worker still reported unloaded after it started")
+	}
+}
+
+func TestIdleEngineLoadingResponse(t *testing.T) {
+	worker := &relaunchEngine{stubEngine: stubEngine{name: "quality"}}
+	worker.running.Store(true)
+	m := &ModelManager{Engine: worker}
+	if err := m.SetIdlePolicy("quality", worker, IdlePolicy{Timeout: time.Minute, ColdStart: ColdStartLoading}); err != nil {
+		t.Fatal(err)
+	}
+	m.unloadIdle(time.Now().Add(2 * time.Minute))
+
+	rr := serve(m, `{}`, "")
+	if rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("cold start answered %d (Retry-After %q), want 503 with a hint", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	deadline := time.Now().Add(time.Second)
+	for m.Unloaded(worker) {
+		if time.Now().After(deadline) {
+			t.Fatal("worker was not started in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if rr := serve(m, `{}`, ""); rr.Header().Get("X-Served-By") != "quality" {
+		t.Fatalf("request after loading was not served: %d", rr.Code)
+	}
+}
+
+func TestIdlePolicyNeedsRelaunch(t *testing.T) {
+	m := &ModelManager{}
+	if err := m.SetIdlePolicy("remote", &stubEngine{}, IdlePolicy{Timeout: time.Minute}); err == nil {
+		t.Error("an engine that cannot be restarted accepted an idle policy")
+	}
+}
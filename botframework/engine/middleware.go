@@ -0,0 +1,28 @@
+package engine
+
+import "net/http"
+
+// Middleware is a step in front of the gateway that can inspect, rewrite, reject or
+// observe requests and their responses: authentication, logging, rate limiting and the
+// like. Components such as chat.Transformer already satisfy it.
+type Middleware interface {
+	Middleware(next http.Handler) http.Handler
+}
+
+// MiddlewareFunc adapts a plain wrapping function to Middleware
+type MiddlewareFunc func(next http.Handler) http.Handler
+
+func (f MiddlewareFunc) Middleware(next http.Handler) http.Handler {
+	return f(next)
+}
+
+// Chain wraps h in middlewares so that requests pass through them in order, the first one
+// outermost. Nil entries are skipped.
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			h = middlewares[i].Middleware(h)
+		}
+	}
+	return h
+}
@@ -0,0 +1,26 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChainRunsMiddlewaresInOrder(t *testing.T) {
+	var order []string
+	step := func(name string) Middleware {
+		return MiddlewareFunc(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { order = append(order, "gateway") }),
+		step("auth"), nil, step("log"))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if strings.Join(order, ",") != "auth,log,gateway" {
+		t.Errorf("order = %v", order)
+	}
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"botframework/auth"
+	"net/http"
+	"strings"
+)
+
+// Priority is a request's class in the queue. Interactive requests are admitted before
+// background ones, which are only overtaken until they have waited QueueConfig.StarveAfter.
+type Priority string
+
+const (
+	PriorityInteractive Priority = "interactive"
+	PriorityBackground  Priority = "background"
+)
+
+// Priorities lists the classes, highest first
+var Priorities = []Priority{PriorityInteractive, PriorityBackground}
+
+// PriorityHeader lets a client mark a request "interactive" or "background"
+const PriorityHeader = "X-BotFramework-Priority"
+
+// ParsePriority reads a priority class; ok is false for anything but the known classes
+func ParsePriority(value string) (Priority, bool) {
+	switch p := Priority(strings.ToLower(strings.TrimSpace(value))); p {
+	case PriorityInteractive, PriorityBackground:
+		return p, true
+	}
+	return "", false
+}
+
+// requestPriority is the class r queues in: the X-BotFramework-Priority header, else the
+// priority of the API key r was authenticated with, else interactive. A key's background
+// priority cannot be raised by the header, so batch keys stay behind chat traffic.
+func requestPriority(r *http.Request) Priority {
+	keyPriority := PriorityInteractive
+	if key, ok := auth.KeyFrom(r.Context()); ok {
+		if p, ok := ParsePriority(key.Priority); ok {
+			keyPriority = p
+		}
+	}
+	if p, ok := ParsePriority(r.Header.Get(PriorityHeader)); ok && keyPriority == PriorityInteractive {
+		return p
+	}
+	return keyPriority
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// probePrompt is long enough that prefill dominates the one-token request's latency
+var probePrompt = strings.Repeat("The quick brown fox jumps over the lazy dog while the farmer counts his sheep. ", 24) +
+	"Continue this story in plain prose."
+
+// probeClock times probe requests; tests replace it
+var probeClock = time.Now
+
+// DefaultProbeTokens is how many tokens Probe asks the engine to generate
+const DefaultProbeTokens = 64
+
+// ProbeResult is the throughput measured by Probe
+type ProbeResult struct {
+	Model            string  `json:"model"`
+	PrefillTPS       float64 `json:"prefill_tps"`
+	DecodeTPS        float64 `json:"decode_tps"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+}
+
+// Probe measures how fast e runs model on this host. It sends the same prompt twice at
+// temperature 0: once for a single token, which times prefill, and once for tokens
+// tokens (DefaultProbeTokens when 0), whose extra time over the first request is decode.
+// The worker must report token usage.
+func Probe(ctx context.Context, e InferenceEngine, model string, tokens int) (ProbeResult, error) {
+	if tokens <= 0 {
+		tokens = DefaultProbeTokens
+	}
+	result := ProbeResult{Model: model}
+
+	prefill, prefillTime, err := probeOnce(ctx, e, model, 1)
+	if err != nil {
+		return result, err
+	}
+	full, fullTime, err := probeOnce(ctx, e, model, tokens)
+	if err != nil {
+		return result, err
+	}
+	result.PromptTokens, result.CompletionTokens = full.PromptTokens, full.CompletionTokens
+	if prefill.PromptTokens == 0 || full.CompletionTokens < 2 {
+		return result, errors.New("probe: worker reported too few tokens to measure")
+	}
+
+	result.PrefillTPS = float64(prefill.PromptTokens) / prefillTime.Seconds()
+	// the first generated token is part of the prefill request's time
+	decodeTime := fullTime - prefillTime
+	if decodeTime <= 0 {
+		return result, errors.New("probe: decode took no measurable time")
+	}
+	result.DecodeTPS = float64(full.CompletionTokens-1) / decodeTime.Seconds()
+	return result, nil
+}
+
+type probeUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// probeOnce sends one non-streaming chat completion through e and times it
+func probeOnce(ctx context.Context, e InferenceEngine, model string, maxTokens int) (probeUsage, time.Duration, error) {
+	body, _ := json.Marshal(map[string]any{
+		"model":       model,
+		"messages":    []map[string]string{{"role": "user", "content": probePrompt}},
+		"max_tokens":  maxTokens,
+		"temperature": 0,
+		"stream":      false,
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return probeUsage{}, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	w := &probeWriter{header: make(http.Header), status: http.StatusOK}
+	start := probeClock()
+	e.ProxyRequest(w, req)
+	elapsed := probeClock().Sub(start)
+	if w.status != http.StatusOK {
+		return probeUsage{}, 0, fmt.Errorf("probe: worker returned %d: %s", w.status, strings.TrimSpace(w.body.String()))
+	}
+	var resp struct {
+		Usage probeUsage `json:"usage"`
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &resp); err != nil {
+		return probeUsage{}, 0, fmt.Errorf("probe: %w", err)
+	}
+	return resp.Usage, elapsed, nil
+}
+
+// probeWriter buffers a proxied response in memory
+type probeWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *probeWriter) Header() http.Header         { return w.header }
+func (w *probeWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+func (w *probeWriter) WriteHeader(status int)      { w.status = status }
+func (w *probeWriter) Flush()                      {}
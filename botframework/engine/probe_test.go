@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// timedEngine takes prefill for the prompt and perToken for every token after the first,
+// on the probe's clock
+type timedEngine struct {
+	stubEngine
+	prefill, perToken time.Duration
+	now               time.Time
+}
+
+func (t *timedEngine) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MaxTokens int `json:"max_tokens"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	t.now = t.now.Add(t.prefill + time.Duration(req.MaxTokens-1)*t.perToken)
+	fmt.Fprintf(w, `{"usage":{"prompt_tokens":200,"completion_tokens":%d}}`, req.MaxTokens)
+}
+
+func TestProbeSeparatesPrefillAndDecode(t *testing.T) {
+	e := &timedEngine{prefill: 100 * time.Millisecond, perToken: 5 * time.Millisecond, now: time.Unix(0, 0)}
+	probeClock = func() time.Time { return e.now }
+	t.Cleanup(func() { probeClock = time.Now })
+	result, err := Probe(context.Background(), e, "phi-3", 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 200 tokens in 100ms and 20 tokens in 100ms
+	if result.PrefillTPS != 2000 {
+		t.Errorf("prefill = %.0f tok/s, want 2000", result.PrefillTPS)
+	}
+	if result.DecodeTPS != 200 {
+		t.Errorf("decode = %.0f tok/s, want 200", result.DecodeTPS)
+	}
+}
+
+func TestProbeReportsWorkerErrors(t *testing.T) {
+	e := &statusEngine{status: http.StatusServiceUnavailable}
+	if _, err := Probe(context.Background(), e, "phi-3", 0); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("err = %v", err)
+	}
+}
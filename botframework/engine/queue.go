@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"botframework/metrics"
+	"botframework/openai"
+	"botframework/supervisor"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// QueueConfig bounds the work sent to each engine. Requests beyond MaxInFlight wait in a
+// queue ordered by priority, then arrival; once MaxQueue requests are waiting, new ones are
+// rejected with 429.
+type QueueConfig struct {
+	MaxInFlight int           // concurrent requests per worker; a pool gets this per member (0 = unlimited)
+	MaxQueue    int           // requests waiting for a slot
+	MaxWait     time.Duration // queued requests give up after this (0 = wait for the client)
+	// StarveAfter is how long a background request can be overtaken by interactive ones
+	// before it is admitted first (0 = DefaultStarveAfter)
+	StarveAfter time.Duration
+}
+
+// DefaultStarveAfter bounds how long interactive traffic holds back a background request
+const DefaultStarveAfter = 30 * time.Second
+
+// Queue admission errors
+var (
+	ErrQueueFull    = errors.New("request queue is full")
+	ErrQueueTimeout = errors.New("timed out waiting in the request queue")
+)
+
+// QueueWaitBuckets are the histogram buckets for queue wait time, in seconds
+var QueueWaitBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+// QueueStats describes one engine's queue
+type QueueStats struct {
+	Model    string `json:"model"`
+	Capacity int    `json:"capacity"`
+	InFlight int    `json:"in_flight"`
+	Queued   int    `json:"queued"`
+	// QueuedByPriority splits Queued by class
+	QueuedByPriority map[Priority]int `json:"queued_by_priority"`
+	Rejected         uint64           `json:"rejected"`
+	TimedOut         uint64           `json:"timed_out"`
+	// Wait is the time admitted requests of each class spent queued, and Latency the time
+	// from joining the queue to finishing
+	Wait    map[Priority]*metrics.Histogram `json:"-"`
+	Latency map[Priority]*metrics.Histogram `json:"-"`
+}
+
+// waiter is a request queued for a slot; ready is closed when it is handed one
+type waiter struct {
+	priority Priority
+	queuedAt time.Time
+	ready    chan struct{}
+}
+
+// admission is the queue in front of one engine. A freed slot goes straight to the next
+// waiter, so the order is decided here rather than by whichever goroutine wakes first.
+type admission struct {
+	capacity    int
+	maxQueue    int
+	starveAfter time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	waiting  []*waiter // in arrival order
+	rejected uint64
+	timedOut uint64
+	wait     map[Priority]*metrics.Histogram
+	latency  map[Priority]*metrics.Histogram
+	service  time.Duration // moving average of time a request holds a slot
+}
+
+func newAdmission(capacity, maxQueue int, starveAfter time.Duration) *admission {
+	if starveAfter <= 0 {
+		starveAfter = DefaultStarveAfter
+	}
+	wait := make(map[Priority]*metrics.Histogram, len(Priorities))
+	latency := make(map[Priority]*metrics.Histogram, len(Priorities))
+	for _, p := range Priorities {
+		wait[p] = metrics.NewHistogram(QueueWaitBuckets)
+		latency[p] = metrics.NewHistogram(QueueWaitBuckets)
+	}
+	return &admission{capacity: capacity, maxQueue: maxQueue, starveAfter: starveAfter, wait: wait, latency: latency}
+}
+
+// enter waits for a slot and returns the func that frees it
+func (a *admission) enter(ctx context.Context, maxWait time.Duration, priority Priority) (func(), error) {
+	start := time.Now()
+	a.mu.Lock()
+	if a.inFlight < a.capacity && len(a.waiting) == 0 {
+		a.inFlight++
+		a.mu.Unlock()
+		return a.admitted(start, priority), nil
+	}
+	if len(a.waiting) >= a.maxQueue {
+		a.rejected++
+		a.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	w := &waiter{priority: priority, queuedAt: start, ready: make(chan struct{})}
+	a.waiting = append(a.waiting, w)
+	a.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-w.ready:
+		return a.admitted(start, priority), nil
+	case <-ctx.Done():
+		a.abandon(w)
+		return nil, ctx.Err()
+	case <-timeout:
+		a.mu.Lock()
+		a.timedOut++
+		a.mu.Unlock()
+		a.abandon(w)
+		return nil, ErrQueueTimeout
+	}
+}
+
+// abandon takes w out of the queue, passing on the slot it was handed meanwhile
+func (a *admission) abandon(w *waiter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if i := slices.Index(a.waiting, w); i >= 0 {
+		a.waiting = slices.Delete(a.waiting, i, i+1)
+		return
+	}
+	a.inFlight--
+	a.handOff()
+}
+
+// handOff gives free slots to the next waiters; the caller holds a.mu
+func (a *admission) handOff() {
+	for a.inFlight < a.capacity && len(a.waiting) > 0 {
+		i := a.next()
+		w := a.waiting[i]
+		a.waiting = slices.Delete(a.waiting, i, i+1)
+		a.inFlight++
+		close(w.ready)
+	}
+}
+
+// next picks the waiter to admit: the oldest background request once it has waited
+// starveAfter, else the oldest interactive one, else the oldest of all
+func (a *admission) next() int {
+	interactive := -1
+	for i, w := range a.waiting {
+		if w.priority != PriorityInteractive && time.Since(w.queuedAt) >= a.starveAfter {
+			return i
+		}
+		if w.priority == PriorityInteractive && interactive < 0 {
+			interactive = i
+		}
+	}
+	return max(interactive, 0)
+}
+
+func (a *admission) admitted(queuedAt time.Time, priority Priority) func() {
+	admittedAt := time.Now()
+	a.mu.Lock()
+	a.wait[priority].Observe(admittedAt.Sub(queuedAt).Seconds())
+	a.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			held := time.Since(admittedAt)
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if a.service == 0 {
+				a.service = held
+			} else {
+				a.service = (a.service*4 + held) / 5
+			}
+			a.latency[priority].Observe(time.Since(queuedAt).Seconds())
+			a.inFlight--
+			a.handOff()
+		})
+	}
+}
+
+// retryAfter estimates how long until a slot frees up for a request joining the queue now
+func (a *admission) retryAfter() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ahead := float64(len(a.waiting) + 1)
+	return time.Duration(math.Ceil(a.service.Seconds()*ahead/float64(a.capacity))) * time.Second
+}
+
+func (a *admission) stats(model string) QueueStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := QueueStats{
+		Model:            model,
+		Capacity:         a.capacity,
+		InFlight:         a.inFlight,
+		Queued:           len(a.waiting),
+		QueuedByPriority: make(map[Priority]int, len(Priorities)),
+		Rejected:         a.rejected,
+		TimedOut:         a.timedOut,
+		Wait:             make(map[Priority]*metrics.Histogram, len(a.wait)),
+		Latency:          make(map[Priority]*metrics.Histogram, len(a.latency)),
+	}
+	for _, p := range Priorities {
+		stats.QueuedByPriority[p] = 0
+	}
+	for _, w := range a.waiting {
+		stats.QueuedByPriority[w.priority]++
+	}
+	for _, p := range Priorities {
+		stats.Wait[p] = a.wait[p].Clone()
+		stats.Latency[p] = a.latency[p].Clone()
+	}
+	return stats
+}
+
+// admit queues the request for e in its priority class, returning a no-op release when
+// queueing is disabled
+func (m *ModelManager) admit(ctx context.Context, e InferenceEngine, priority Priority) (*admission, func(), error) {
+	if m.Queue.MaxInFlight <= 0 {
+		return nil, func() {}, nil
+	}
+	a := m.admissionFor(e)
+	release, err := a.enter(ctx, m.Queue.MaxWait, priority)
+	return a, release, err
+}
+
+func (m *ModelManager) admissionFor(e InferenceEngine) *admission {
+	if a, ok := m.queues.Load(e); ok {
+		return a.(*admission)
+	}
+	capacity := m.Queue.MaxInFlight
+	if pool, ok := e.(*supervisor.WorkerPool); ok {
+		capacity *= max(1, len(pool.Workers()))
+	}
+	a, _ := m.queues.LoadOrStore(e, newAdmission(capacity, m.Queue.MaxQueue, m.Queue.StarveAfter))
+	return a.(*admission)
+}
+
+// QueueStats reports the queue of every engine that has received requests
+func (m *ModelManager) QueueStats() []QueueStats {
+	var stats []QueueStats
+	for name, e := range m.Engines() {
+		if a, ok := m.queues.Load(e); ok {
+			stats = append(stats, a.(*admission).stats(name))
+		}
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Model < stats[j].Model })
+	return stats
+}
+
+// writeQueueError answers a request the queue turned away with 429 and a Retry-After hint
+func writeQueueError(w http.ResponseWriter, a *admission, err error) {
+	code := "queue_full"
+	if errors.Is(err, ErrQueueTimeout) {
+		code = "queue_timeout"
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(a.retryAfter().Seconds()))))
+	openai.WriteError(w, http.StatusTooManyRequests, "requests", code, err.Error()+"; retry later")
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"botframework/auth"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQueueRejectsWhenFull(t *testing.T) {
+	busy := &blockingEngine{stubEngine: stubEngine{name: "busy"}, started: make(chan struct{}, 4), release: make(chan struct{})}
+	m := &ModelManager{Engine: busy, Queue: QueueConfig{MaxInFlight: 1, MaxQueue: 1}}
+
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- serve(m, `{}`, "").Code }()
+	}
+	<-busy.started
+	// wait for the second request to join the queue
+	deadline := time.Now().Add(time.Second)
+	for m.QueueStats()[0].Queued != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("second request never queued")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	rr := serve(m, `{}`, "")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("third request: status %d, Retry-After %q; want 429 with Retry-After", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	close(busy.release)
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("queued request finished with %d", code)
+		}
+	}
+	stats := m.QueueStats()[0]
+	if stats.Rejected != 1 || stats.InFlight != 0 || stats.Queued != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestQueueTimesOut(t *testing.T) {
+	busy := &blockingEngine{stubEngine: stubEngine{name: "busy"}, started: make(chan struct{}, 1), release: make(chan struct{})}
+	m := &ModelManager{Engine: busy, Queue: QueueConfig{MaxInFlight: 1, MaxQueue: 4, MaxWait: 20 * time.Millisecond}}
+	defer close(busy.release)
+
+	go serve(m, `{}`, "")
+	<-busy.started
+	if rr := serve(m, `{}`, ""); rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429 after the queue timeout", rr.Code)
+	}
+	if stats := m.QueueStats()[0]; stats.TimedOut != 1 {
+		t.Errorf("stats = %+v, want one timeout", stats)
+	}
+}
+
+func TestQueueDisabledByDefault(t *testing.T) {
+	m := &ModelManager{Engine: &stubEngine{name: "default"}}
+	if rr := serve(m, `{}`, ""); rr.Code != http.StatusOK {
+		t.Fatalf("status = %d", rr.Code)
+	}
+	if stats := m.QueueStats(); len(stats) != 0 {
+		t.Errorf("queue stats without queueing: %+v", stats)
+	}
+}
+
+// queueBehind fills a's one slot and queues a request of each given class, oldest first,
+// returning the order the queued requests are admitted in once the slot frees up
+func queueBehind(t *testing.T, a *admission, priorities ...Priority) []Priority {
+	t.Helper()
+	release, err := a.enter(context.Background(), 0, PriorityInteractive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admitted := make(chan Priority, len(priorities))
+	for i, p := range priorities {
+		go func() {
+			next, err := a.enter(context.Background(), 0, p)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			admitted <- p
+			next()
+		}()
+		for a.stats("m").Queued != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	release()
+	var order []Priority
+	for range priorities {
+		order = append(order, <-admitted)
+	}
+	return order
+}
+
+func TestQueueAdmitsInteractiveFirst(t *testing.T) {
+	a := newAdmission(1, 4, time.Minute)
+	order := queueBehind(t, a, PriorityBackground, PriorityInteractive)
+	if order[0] != PriorityInteractive || order[1] != PriorityBackground {
+		t.Fatalf("admitted %v, want the interactive request ahead of the older background one", order)
+	}
+}
+
+func TestQueueAdmitsStarvedBackgroundRequest(t *testing.T) {
+	a := newAdmission(1, 4, time.Nanosecond)
+	order := queueBehind(t, a, PriorityBackground, PriorityInteractive)
+	if order[0] != PriorityBackground {
+		t.Fatalf("admitted %v, want the background request first once it has starved", order)
+	}
+}
+
+func TestRequestPriority(t *testing.T) {
+	for _, tt := range []struct {
+		header, key string
+		want        Priority
+	}{
+		{"", "", PriorityInteractive},
+		{"background", "", PriorityBackground},
+		{"", "background", PriorityBackground},
+		{"interactive", "background", PriorityBackground},
+		{"bogus", "", PriorityInteractive},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if tt.header != "" {
+			r.Header.Set(PriorityHeader, tt.header)
+		}
+		if tt.key != "" {
+			r = r.WithContext(auth.WithKey(r.Context(), &auth.Key{ID: "k", Priority: tt.key}))
+		}
+		if got := requestPriority(r); got != tt.want {
+			t.Errorf("header %q, key %q: priority %q, want %q", tt.header, tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Residency decides which models loaded on demand stay resident. Before another model is
+// loaded, the least recently requested ones are evicted until the new model fits in the
+// memory free now and the count stays within MaxModels. Declared models, the default engine
+// and models serving a request are never evicted; an evicted model is loaded again by the
+// next request naming it.
+type Residency struct {
+	// FreeMB samples the memory free for models now: VRAM on GPU hosts, RAM elsewhere
+	FreeMB func() int
+	// NeedMB estimates the memory a model takes once loaded; zero skips the memory check
+	NeedMB func(model string) int
+	// MaxModels caps the models loaded on demand at once; zero leaves it to memory
+	MaxModels int
+	// Settle is how long an evicted worker is given to release its memory before FreeMB is
+	// sampled again
+	Settle time.Duration
+}
+
+// residentModel is a model loaded on demand and when it was last requested
+type residentModel struct {
+	model    string
+	lastUsed atomic.Int64 // unix nanoseconds
+}
+
+func (r *residentModel) touch() {
+	r.lastUsed.Store(time.Now().UnixNano())
+}
+
+// residentNow reports whether e is still registered as r's model and running; engines
+// swapped out or unloaded since are forgotten, idle-unloaded ones are skipped
+func (m *ModelManager) residentNow(e InferenceEngine, r *residentModel) bool {
+	m.mu.RLock()
+	registered := m.models[r.model] == e && !m.retired[e]
+	m.mu.RUnlock()
+	if !registered {
+		m.resident.Delete(e)
+		return false
+	}
+	return !m.Unloaded(e)
+}
+
+// makeRoom evicts least recently used models until model fits, or nothing is left to evict
+func (m *ModelManager) makeRoom(model string) {
+	r := m.Residency
+	need := 0
+	if r.NeedMB != nil {
+		need = r.NeedMB(model)
+	}
+	for {
+		count, victim, victimModel := m.evictionCandidate()
+		full := r.MaxModels > 0 && count >= r.MaxModels
+		free := -1
+		if need > 0 && r.FreeMB != nil {
+			free = r.FreeMB()
+		}
+		if short := free >= 0 && free < need; !full && !short {
+			return
+		}
+		if victim == nil {
+			slog.Warn("no model can be evicted to make room", "model", model, "need_mb", need, "free_mb", free, "resident", count)
+			return
+		}
+		if !m.evict(victim, victimModel) {
+			continue
+		}
+		slog.Info("evicted least recently used model", "model", victimModel.model, "for", model,
+			"idle", time.Since(time.Unix(0, victimModel.lastUsed.Load())).Round(time.Second), "need_mb", need, "free_mb", free)
+		if r.Settle > 0 {
+			time.Sleep(r.Settle)
+		}
+	}
+}
+
+// evictionCandidate counts the resident models and picks the least recently used one that
+// is not serving a request
+func (m *ModelManager) evictionCandidate() (count int, victim InferenceEngine, model *residentModel) {
+	m.mu.RLock()
+	defaultEngine := m.Engine
+	m.mu.RUnlock()
+	m.resident.Range(func(key, value any) bool {
+		e, r := key.(InferenceEngine), value.(*residentModel)
+		if !m.residentNow(e, r) {
+			return true
+		}
+		count++
+		if m.running(e) == 0 && e != defaultEngine && (model == nil || r.lastUsed.Load() < model.lastUsed.Load()) {
+			victim, model = e, r
+		}
+		return true
+	})
+	return count, victim, model
+}
+
+// evict retires e, so requests that resolved it resolve again and load it anew, and stops
+// it. It reports false when a request acquired e in the meantime.
+func (m *ModelManager) evict(e InferenceEngine, r *residentModel) bool {
+	m.mu.Lock()
+	if m.running(e) > 0 {
+		m.mu.Unlock()
+		return false
+	}
+	if m.retired == nil {
+		m.retired = make(map[InferenceEngine]bool)
+	}
+	m.retired[e] = true
+	for name, other := range m.models {
+		if other == e {
+			delete(m.models, name)
+		}
+	}
+	m.mu.Unlock()
+
+	m.resident.Delete(e)
+	m.idle.Delete(e)
+	if err := e.Stop(); err != nil {
+		slog.Warn("stopping evicted model failed", "model", r.model, "err", err)
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+)
+
+// residencyManager loads stub engines on demand, each taking 4000 MB of a 10000 MB device
+func residencyManager(maxModels int) (*ModelManager, map[string][]*stubEngine) {
+	var mu sync.Mutex
+	loaded := map[string][]*stubEngine{}
+	m := &ModelManager{Engine: &stubEngine{name: "default"}, UnknownModels: UnknownModelLoad}
+	m.Loader = func(model string) (InferenceEngine, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		e := &stubEngine{name: model}
+		loaded[model] = append(loaded[model], e)
+		return e, nil
+	}
+	m.Residency = &Residency{
+		FreeMB: func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			free := 10000
+			for _, engines := range loaded {
+				for _, e := range engines {
+					if e.stopped.Load() == 0 {
+						free -= 4000
+					}
+				}
+			}
+			return free
+		},
+		NeedMB:    func(string) int { return 4000 },
+		MaxModels: maxModels,
+	}
+	return m, loaded
+}
+
+func TestResidencyEvictsLeastRecentlyUsed(t *testing.T) {
+	m, loaded := residencyManager(0)
+	pinned := &stubEngine{name: "pinned"}
+	m.Register("pinned", pinned)
+
+	for _, model := range []string{"a", "b", "a", "c"} {
+		if got := serve(m, `{"model":"`+model+`"}`, "").Header().Get("X-Served-By"); got != model {
+			t.Fatalf("request for %s served by %q", model, got)
+		}
+	}
+	if loaded["b"][0].stopped.Load() != 1 {
+		t.Fatal("the least recently used model was not evicted")
+	}
+	if loaded["a"][0].stopped.Load() != 0 || loaded["c"][0].stopped.Load() != 0 || pinned.stopped.Load() != 0 {
+		t.Fatal("a recently used or declared model was evicted")
+	}
+
+	// b loads again and takes the place of a, now the least recently used
+	if got := serve(m, `{"model":"b"}`, "").Header().Get("X-Served-By"); got != "b" || len(loaded["b"]) != 2 {
+		t.Fatalf("evicted model served by %q after %d loads", got, len(loaded["b"]))
+	}
+	if loaded["a"][0].stopped.Load() != 1 || loaded["c"][0].stopped.Load() != 0 {
+		t.Fatal("reloading an evicted model did not evict the least recently used one")
+	}
+}
+
+func TestResidencyKeepsModelsServingRequests(t *testing.T) {
+	m, loaded := residencyManager(1)
+	serve(m, `{"model":"a"}`, "")
+	release, _ := m.acquire(loaded["a"][0])
+
+	if got := serve(m, `{"model":"b"}`, "").Header().Get("X-Served-By"); got != "b" {
+		t.Fatalf("request for b served by %q", got)
+	}
+	if loaded["a"][0].stopped.Load() != 0 {
+		t.Fatal("a model serving a request was evicted")
+	}
+
+	release()
+	serve(m, `{"model":"c"}`, "")
+	if loaded["a"][0].stopped.Load() != 1 || loaded["b"][0].stopped.Load() != 1 || loaded["c"][0].stopped.Load() != 0 {
+		t.Fatal("models over MaxModels were not evicted once idle")
+	}
+}
@@ -0,0 +1,377 @@
+package engine
+
+import (
+	"botframework/supervisor"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RolloutPolicy controls automatic promotion and rollback of a canary
+type RolloutPolicy struct {
+	// Auto enables threshold-based decisions once MinRequests canary requests were observed
+	Auto        bool `json:"auto"`
+	MinRequests int  `json:"min_requests"`
+	// MaxErrorRateDelta is the tolerated canary error rate above the stable error rate (0.05 = 5 points)
+	MaxErrorRateDelta float64 `json:"max_error_rate_delta"`
+	// MaxLatencyRatio is the tolerated canary/stable mean latency ratio (1.2 = 20% slower)
+	MaxLatencyRatio float64 `json:"max_latency_ratio"`
+}
+
+func DefaultRolloutPolicy() RolloutPolicy {
+	return RolloutPolicy{MinRequests: 50, MaxErrorRateDelta: 0.05, MaxLatencyRatio: 1.2}
+}
+
+// ArmStats summarises traffic served by one side of a rollout
+type ArmStats struct {
+	Model       string  `json:"model"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	ErrorRate   float64 `json:"error_rate"`
+	MeanLatency float64 `json:"mean_latency_ms"`
+}
+
+type RolloutStatus struct {
+	Model   string        `json:"model"`
+	Percent int           `json:"percent"`
+	Policy  RolloutPolicy `json:"policy"`
+	Stable  ArmStats      `json:"stable"`
+	Canary  ArmStats      `json:"canary"`
+}
+
+type rolloutArm struct {
+	name     string
+	engine   InferenceEngine
+	requests int
+	errors   int
+	latency  time.Duration
+}
+
+func (a *rolloutArm) stats() ArmStats {
+	s := ArmStats{Model: a.name, Requests: a.requests, Errors: a.errors}
+	if a.requests > 0 {
+		s.ErrorRate = float64(a.errors) / float64(a.requests)
+		s.MeanLatency = float64(a.latency.Milliseconds()) / float64(a.requests)
+	}
+	return s
+}
+
+// Rollout splits traffic for one model between a stable and a canary engine.
+// Setting the percentage to 0 or 100 gives a blue/green switch.
+type Rollout struct {
+	model   string
+	policy  RolloutPolicy
+	decide  func(promote bool)
+	manager *ModelManager // counts each arm's requests in flight, for draining
+
+	mu      sync.Mutex
+	percent int
+	stable  *rolloutArm
+	canary  *rolloutArm
+	decided bool
+}
+
+func (r *Rollout) SetPercent(percent int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.percent = min(100, max(0, percent))
+}
+
+// Progress reports the rollout's split and per-arm statistics
+func (r *Rollout) Progress() RolloutStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RolloutStatus{
+		Model:   r.model,
+		Percent: r.percent,
+		Policy:  r.policy,
+		Stable:  r.stable.stats(),
+		Canary:  r.canary.stats(),
+	}
+}
+
+func (r *Rollout) Start(_ context.Context) error { return nil }
+
+func (r *Rollout) ProxyRequest(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	arm := r.stable
+	if rand.IntN(100) < r.percent {
+		arm = r.canary
+	}
+	// counted under the lock, so once settle returns no request can still pick the loser
+	inflight := r.manager.inflightCounter(arm.engine)
+	inflight.Add(1)
+	r.mu.Unlock()
+	defer inflight.Add(-1)
+
+	start := time.Now()
+	sw := &armWriter{ResponseWriter: w, status: http.StatusOK}
+	arm.engine.ProxyRequest(sw, req)
+	r.observe(arm, sw.status, time.Since(start))
+}
+
+func (r *Rollout) Health() (*supervisor.WorkerHealth, error) {
+	return r.stable.engine.Health()
+}
+
+func (r *Rollout) Status() supervisor.WorkerStatus {
+	return r.stable.engine.Status()
+}
+
+// Stop stops both arms; promotion and rollback stop only the losing arm
+func (r *Rollout) Stop() error {
+	return errors.Join(r.stable.engine.Stop(), r.canary.engine.Stop())
+}
+
+// settle sends every request still reaching the rollout to winner
+func (r *Rollout) settle(winner *rolloutArm) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.decided = true
+	r.percent = 0
+	if winner == r.canary {
+		r.percent = 100
+	}
+}
+
+func (r *Rollout) observe(arm *rolloutArm, status int, latency time.Duration) {
+	r.mu.Lock()
+	arm.requests++
+	arm.latency += latency
+	if status >= http.StatusInternalServerError {
+		arm.errors++
+	}
+
+	if !r.policy.Auto || r.decided || r.canary.requests < r.policy.MinRequests {
+		r.mu.Unlock()
+		return
+	}
+	promote := r.canaryHealthy()
+	r.decided = true
+	r.mu.Unlock()
+
+	// Decisions re-register engines on the manager, so run them outside the request path
+	go r.decide(promote)
+}
+
+// canaryHealthy compares the canary against the stable arm using the policy thresholds
+func (r *Rollout) canaryHealthy() bool {
+	stable, canary := r.stable.stats(), r.canary.stats()
+	if canary.ErrorRate > stable.ErrorRate+r.policy.MaxErrorRateDelta {
+		return false
+	}
+	if r.policy.MaxLatencyRatio > 0 && stable.MeanLatency > 0 && canary.MeanLatency > stable.MeanLatency*r.policy.MaxLatencyRatio {
+		return false
+	}
+	return true
+}
+
+type armWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *armWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *armWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *armWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// StartRollout sends percent of the model's traffic to the candidate model
+func (m *ModelManager) StartRollout(model, candidate string, percent int, policy RolloutPolicy) (*Rollout, error) {
+	stable, err := m.registered(model)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := stable.(*Rollout); ok {
+		return nil, fmt.Errorf("a rollout is already in progress for %q", model)
+	}
+
+	canary, err := m.EnsureLoaded(candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	rollout := &Rollout{
+		model:   model,
+		policy:  policy,
+		manager: m,
+		stable:  &rolloutArm{name: model, engine: stable},
+		canary:  &rolloutArm{name: candidate, engine: canary},
+	}
+	rollout.SetPercent(percent)
+	rollout.decide = func(promote bool) {
+		var err error
+		if promote {
+			slog.Info("auto-promoting rollout", "model", model, "candidate", candidate)
+			err = m.PromoteRollout(model)
+		} else {
+			slog.Info("auto-rolling back rollout", "model", model, "candidate", candidate)
+			err = m.RollbackRollout(model)
+		}
+		if err != nil {
+			slog.Warn("rollout decision failed", "model", model, "err", err)
+		}
+	}
+
+	if !m.replace(model, stable, rollout) {
+		return nil, fmt.Errorf("model %q was replaced while the rollout started", model)
+	}
+	return rollout, nil
+}
+
+// PromoteRollout makes the canary the only engine for the model, then stops the old stable
+// engine once the requests it is serving finish
+func (m *ModelManager) PromoteRollout(model string) error {
+	return m.finishRollout(model, true)
+}
+
+// RollbackRollout restores the stable engine, then stops the canary once the requests it
+// is serving finish
+func (m *ModelManager) RollbackRollout(model string) error {
+	return m.finishRollout(model, false)
+}
+
+// finishRollout routes the model to the winning arm and drains and stops the losing one,
+// like a hot swap. Only one decision wins; the rollout is swapped out atomically, so a
+// manual decision racing an automatic one fails.
+func (m *ModelManager) finishRollout(model string, promote bool) error {
+	rollout, err := m.rollout(model)
+	if err != nil {
+		return err
+	}
+	winner, loser := rollout.stable, rollout.canary
+	if promote {
+		winner, loser = rollout.canary, rollout.stable
+	}
+	if !m.replace(model, rollout, winner.engine) {
+		return fmt.Errorf("the rollout for %q was already decided", model)
+	}
+	rollout.settle(winner)
+	if !promote {
+		m.unregister(loser.name, loser.engine)
+	}
+	m.inflight.Delete(rollout)
+	m.drain(context.Background(), loser.engine, DefaultDrainTimeout)
+	return m.stopUnlessShared(loser.engine)
+}
+
+// Rollouts returns the status of every rollout in progress
+func (m *ModelManager) Rollouts() []RolloutStatus {
+	var statuses []RolloutStatus
+	for _, name := range m.ListModels() {
+		if rollout, err := m.rollout(name); err == nil {
+			statuses = append(statuses, rollout.Progress())
+		}
+	}
+	return statuses
+}
+
+// Rollout returns the rollout in progress for the model
+func (m *ModelManager) Rollout(model string) (*Rollout, error) {
+	return m.rollout(model)
+}
+
+func (m *ModelManager) rollout(model string) (*Rollout, error) {
+	e, err := m.registered(model)
+	if err != nil {
+		return nil, err
+	}
+	rollout, ok := e.(*Rollout)
+	if !ok {
+		return nil, fmt.Errorf("no rollout in progress for %q", model)
+	}
+	return rollout, nil
+}
+
+func (m *ModelManager) registered(model string) (InferenceEngine, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	e, ok := m.models[model]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownModel, model)
+	}
+	return e, nil
+}
+
+// EnsureLoaded returns the registered engine for model, loading it regardless of UnknownModels
+func (m *ModelManager) EnsureLoaded(model string) (InferenceEngine, error) {
+	if e, err := m.registered(model); err == nil {
+		return e, nil
+	}
+	if m.Loader == nil {
+		return nil, fmt.Errorf("%w %q: on-demand loading is not configured", ErrUnknownModel, model)
+	}
+	return m.load(model)
+}
+
+// Unload removes model and stops its engine unless another model name or the default
+// route still uses it
+func (m *ModelManager) Unload(model string) error {
+	e, err := m.registered(model)
+	if err != nil {
+		return err
+	}
+	m.unregister(model, e)
+	slog.Info("unloaded model", "model", model)
+	return m.stopUnlessShared(e)
+}
+
+// replace swaps the engine registered for model from old to next, keeping the default engine
+// pointer in sync. It reports false, changing nothing, when model no longer maps to old.
+func (m *ModelManager) replace(model string, old, next InferenceEngine) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.models[model] != old {
+		return false
+	}
+	m.models[model] = next
+	if m.Engine == old {
+		m.Engine = next
+	}
+	return true
+}
+
+// unregister removes model if it still points at e
+func (m *ModelManager) unregister(model string, e InferenceEngine) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.models[model] == e {
+		delete(m.models, model)
+	}
+}
+
+// stopUnlessShared stops an engine that is no longer registered under any model name
+func (m *ModelManager) stopUnlessShared(e InferenceEngine) error {
+	m.mu.RLock()
+	shared := m.Engine == e
+	for _, other := range m.models {
+		if other == e {
+			shared = true
+		}
+	}
+	m.mu.RUnlock()
+	if shared {
+		return nil
+	}
+	return e.Stop()
+}
@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRolloutManager(t *testing.T) (*ModelManager, *statusEngine, *statusEngine) {
+	t.Helper()
+	stable := &statusEngine{stubEngine: stubEngine{name: "stable"}, status: http.StatusOK, available: true}
+	canary := &statusEngine{stubEngine: stubEngine{name: "canary"}, status: http.StatusOK, available: true}
+	m := &ModelManager{Engine: stable}
+	m.Register("llama", stable)
+	m.Register("llama-q8", canary)
+	return m, stable, canary
+}
+
+func sendN(m *ModelManager, n int) {
+	for i := 0; i < n; i++ {
+		m.ProxyRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"model":"llama"}`)))
+	}
+}
+
+func TestRolloutSplitsTraffic(t *testing.T) {
+	m, stable, canary := newRolloutManager(t)
+	rollout, err := m.StartRollout("llama", "llama-q8", 100, RolloutPolicy{})
+	if err != nil {
+		t.Fatalf("start rollout: %v", err)
+	}
+
+	sendN(m, 5)
+	if canary.calls != 5 || stable.calls != 0 {
+		t.Fatalf("expected all traffic on canary, got stable=%d canary=%d", stable.calls, canary.calls)
+	}
+
+	rollout.SetPercent(0)
+	sendN(m, 3)
+	if stable.calls != 3 {
+		t.Fatalf("expected traffic back on stable, got %d", stable.calls)
+	}
+	if got := rollout.Progress().Canary.Requests; got != 5 {
+		t.Fatalf("expected 5 canary requests recorded, got %d", got)
+	}
+}
+
+func TestRolloutManualPromote(t *testing.T) {
+	m, stable, canary := newRolloutManager(t)
+	if _, err := m.StartRollout("llama", "llama-q8", 10, RolloutPolicy{}); err != nil {
+		t.Fatalf("start rollout: %v", err)
+	}
+	if err := m.PromoteRollout("llama"); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+
+	if e, _ := m.Resolve("llama"); e != canary {
+		t.Fatalf("expected canary to serve llama after promotion, got %T", e)
+	}
+	if e, _ := m.Resolve(""); e != canary {
+		t.Fatal("expected default engine to follow the promotion")
+	}
+	if stable.stopped.Load() != 1 {
+		t.Fatalf("expected stable to be stopped, got %d", stable.stopped.Load())
+	}
+}
+
+func TestRolloutAutoRollbackOnErrors(t *testing.T) {
+	m, stable, canary := newRolloutManager(t)
+	canary.status = http.StatusInternalServerError
+	policy := RolloutPolicy{Auto: true, MinRequests: 3, MaxErrorRateDelta: 0.1}
+	if _, err := m.StartRollout("llama", "llama-q8", 100, policy); err != nil {
+		t.Fatalf("start rollout: %v", err)
+	}
+
+	sendN(m, 3)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if e, _ := m.Resolve("llama"); e == stable {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if e, _ := m.Resolve("llama"); e != stable {
+		t.Fatal("expected automatic rollback to stable")
+	}
+	if canary.stopped.Load() != 1 {
+		t.Fatalf("expected canary to be stopped, got %d", canary.stopped.Load())
+	}
+}
+
+func TestRolloutPromoteDrainsStable(t *testing.T) {
+	stable := &blockingEngine{stubEngine: stubEngine{name: "stable"}, started: make(chan struct{}, 1), release: make(chan struct{})}
+	canary := &stubEngine{name: "canary"}
+	m := &ModelManager{Engine: stable}
+	m.Register("llama", stable)
+	m.Register("llama-q8", canary)
+	if _, err := m.StartRollout("llama", "llama-q8", 0, RolloutPolicy{}); err != nil {
+		t.Fatalf("start rollout: %v", err)
+	}
+
+	served := make(chan string)
+	go func() {
+		served <- serve(m, `{"model":"llama"}`, "").Header().Get("X-Served-By")
+	}()
+	<-stable.started
+
+	promoted := make(chan error)
+	go func() { promoted <- m.PromoteRollout("llama") }()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if e, _ := m.Resolve("llama"); e == canary {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("llama was not switched to the canary")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case err := <-promoted:
+		t.Fatalf("promotion returned (%v) while the stable engine was serving", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if stable.stopped.Load() != 0 {
+		t.Fatal("stable engine stopped before its request finished")
+	}
+
+	close(stable.release)
+	if got := <-served; got != "stable" {
+		t.Errorf("in-flight request served by %q, want stable", got)
+	}
+	if err := <-promoted; err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if stable.stopped.Load() != 1 {
+		t.Fatalf("expected stable to be stopped once drained, got %d", stable.stopped.Load())
+	}
+}
+
+func TestRolloutDecidedOnce(t *testing.T) {
+	m, stable, canary := newRolloutManager(t)
+	if _, err := m.StartRollout("llama", "llama-q8", 50, RolloutPolicy{}); err != nil {
+		t.Fatalf("start rollout: %v", err)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- m.PromoteRollout("llama") }()
+	go func() { errs <- m.RollbackRollout("llama") }()
+	err := errors.Join(<-errs, <-errs)
+	if err == nil || strings.Count(err.Error(), "\n") != 0 {
+		t.Fatalf("err = %v, want exactly one decision refused", err)
+	}
+	if stopped := stable.stopped.Load() + canary.stopped.Load(); stopped != 1 {
+		t.Fatalf("stopped %d engines, want only the loser", stopped)
+	}
+	if e, _ := m.Resolve("llama"); (e == stable) == (stable.stopped.Load() == 1) {
+		t.Fatal("llama is served by the engine that lost")
+	}
+}
@@ -0,0 +1,291 @@
+package engine
+
+import (
+	"botframework/openai"
+	"botframework/supervisor"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+)
+
+// UnknownModelPolicy controls what happens when a request names a model that is not registered
+type UnknownModelPolicy string
+
+const (
+	UnknownModelReject  UnknownModelPolicy = "reject"  // respond 404
+	UnknownModelLoad    UnknownModelPolicy = "load"    // start a worker on demand via Loader
+	UnknownModelDefault UnknownModelPolicy = "default" // serve from the default engine
+)
+
+// ParseUnknownModelPolicy validates a policy name
+func ParseUnknownModelPolicy(s string) (UnknownModelPolicy, error) {
+	switch policy := UnknownModelPolicy(s); policy {
+	case UnknownModelReject, UnknownModelLoad, UnknownModelDefault:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown model policy %q (want reject, load or default)", s)
+}
+
+// ModelHeader lets clients pick a model without touching the request body
+const ModelHeader = "X-Model"
+
+// maxRoutingBody bounds how much of a request body is buffered to read the model field
+const maxRoutingBody = 32 << 20
+
+var ErrUnknownModel = errors.New("unknown model")
+
+// ModelLoader starts an engine serving the named model
+type ModelLoader func(model string) (InferenceEngine, error)
+
+// Register makes an engine reachable under the given model name
+func (m *ModelManager) Register(model string, e InferenceEngine) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.models == nil {
+		m.models = make(map[string]InferenceEngine)
+	}
+	m.models[model] = e
+}
+
+// ListModels returns the registered model names in sorted order
+func (m *ModelManager) ListModels() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.models))
+	for name := range m.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Engines returns the engine serving each registered model, plus the default engine under
+// "default" when no model name refers to it
+func (m *ModelManager) Engines() map[string]InferenceEngine {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	engines := make(map[string]InferenceEngine, len(m.models)+1)
+	named := false
+	for name, e := range m.models {
+		engines[name] = e
+		named = named || e == m.Engine
+	}
+	if m.Engine != nil && !named {
+		engines["default"] = m.Engine
+	}
+	return engines
+}
+
+// Resolve returns the engine that should serve the named model
+func (m *ModelManager) Resolve(model string) (InferenceEngine, error) {
+	if model == "" {
+		return m.defaultEngine()
+	}
+
+	m.mu.RLock()
+	e, ok := m.models[model]
+	m.mu.RUnlock()
+	if ok {
+		return e, nil
+	}
+
+	switch m.UnknownModels {
+	case UnknownModelLoad:
+		if m.Loader == nil {
+			return nil, fmt.Errorf("%w %q: on-demand loading is not configured", ErrUnknownModel, model)
+		}
+		return m.load(model)
+	case UnknownModelReject:
+		return nil, fmt.Errorf("%w %q", ErrUnknownModel, model)
+	default:
+		return m.defaultEngine()
+	}
+}
+
+func (m *ModelManager) defaultEngine() (InferenceEngine, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.Engine == nil {
+		return nil, errors.New("no default engine configured")
+	}
+	return m.Engine, nil
+}
+
+func (m *ModelManager) load(model string) (InferenceEngine, error) {
+	// Serialise loads so concurrent requests for the same model start one worker
+	m.loadMu.Lock()
+	defer m.loadMu.Unlock()
+
+	m.mu.RLock()
+	e, ok := m.models[model]
+	m.mu.RUnlock()
+	if ok {
+		return e, nil
+	}
+
+	if m.Residency != nil {
+		m.makeRoom(model)
+	}
+	slog.Info("loading model on demand", "model", model)
+	e, err := m.Loader(model)
+	if err != nil {
+		return nil, fmt.Errorf("load model %q: %w", model, err)
+	}
+	m.Register(model, e)
+	if m.Residency != nil {
+		resident := &residentModel{model: model}
+		resident.touch()
+		m.resident.Store(e, resident)
+	}
+	return e, nil
+}
+
+// Start starts the default engine; additional engines are started when registered or loaded
+func (m *ModelManager) Start(ctx context.Context) error {
+	e, err := m.defaultEngine()
+	if err != nil {
+		return err
+	}
+	return e.Start(ctx)
+}
+
+// ProxyRequest routes the request to the engine selected by the X-Model header or body "model" field
+func (m *ModelManager) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	model, err := RequestedModel(r)
+	if err != nil {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	priority := requestPriority(r)
+	var e InferenceEngine
+	for {
+		e, err = m.Resolve(model)
+		if err != nil {
+			if errors.Is(err, ErrUnknownModel) {
+				openai.WriteError(w, http.StatusNotFound, "invalid_request_error", "model_not_found", err.Error())
+				return
+			}
+			openai.WriteError(w, http.StatusServiceUnavailable, "server_error", "model_unavailable", err.Error())
+			return
+		}
+		queue, leave, err := m.admit(r.Context(), e, priority)
+		if err != nil {
+			if r.Context().Err() == nil {
+				writeQueueError(w, queue, err)
+			}
+			return
+		}
+		// a hot swap or an eviction may retire e while the request is queued or before it is acquired
+		if release, ok := m.acquire(e); ok {
+			defer leave()
+			defer release()
+			break
+		}
+		leave()
+	}
+	if !m.wake(w, r, e) {
+		return
+	}
+	defer m.markUsed(e)
+	// the deadline starts after the queue and any cold start, so it bounds generation alone;
+	// workers see it as the request's context ending, as when the client disconnects
+	if timeout := TimeoutFor(r, m.RequestTimeout); timeout > 0 {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
+	serve := e.ProxyRequest
+	if chain := m.fallbackChain(model); len(chain) > 0 {
+		serve = func(w http.ResponseWriter, r *http.Request) {
+			m.proxyWithFallback(w, r, model, e, chain)
+		}
+	}
+
+	if shadow, ok := m.shadowFor(model); ok {
+		m.proxyWithShadow(w, r, model, shadow, serve)
+		return
+	}
+	serve(w, r)
+}
+
+// Health reports the default engine's health; an engine unloaded for being idle is healthy,
+// since the next request starts it
+func (m *ModelManager) Health() (*supervisor.WorkerHealth, error) {
+	e, err := m.defaultEngine()
+	if err != nil {
+		return nil, err
+	}
+	if m.Unloaded(e) {
+		return &supervisor.WorkerHealth{Status: "unloaded"}, nil
+	}
+	return e.Health()
+}
+
+// Status reports the default engine's lifecycle state
+func (m *ModelManager) Status() supervisor.WorkerStatus {
+	e, err := m.defaultEngine()
+	if err != nil {
+		return supervisor.WorkerStatus{State: supervisor.StateStopped}
+	}
+	return e.Status()
+}
+
+// Stop stops every engine the manager knows about
+func (m *ModelManager) Stop() error {
+	m.mu.RLock()
+	engines := []InferenceEngine{m.Engine}
+	for _, e := range m.models {
+		engines = append(engines, e)
+	}
+	m.mu.RUnlock()
+
+	seen := make(map[InferenceEngine]bool)
+	var errs []error
+	for _, e := range engines {
+		if e == nil || seen[e] {
+			continue
+		}
+		seen[e] = true
+		if err := e.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RequestedModel reads the model from the X-Model header or the JSON body, restoring the body afterwards
+func RequestedModel(r *http.Request) (string, error) {
+	if model := r.Header.Get(ModelHeader); model != "" {
+		return model, nil
+	}
+	if r.Body == nil || r.Method != http.MethodPost {
+		return "", nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRoutingBody+1))
+	if err != nil {
+		return "", fmt.Errorf("read request body: %w", err)
+	}
+	_ = r.Body.Close()
+	if len(body) > maxRoutingBody {
+		return "", errors.New("request body too large")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		Model string `json:"model"`
+	}
+	// Non-JSON bodies are proxied untouched to the default engine
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return "", nil
+	}
+	return payload.Model, nil
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"botframework/supervisor"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type stubEngine struct {
+	name    string
+	body    string
+	stopped atomic.Int32
+}
+
+func (s *stubEngine) Start(_ context.Context) error { return nil }
+func (s *stubEngine) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.body = string(body)
+	w.Header().Set("X-Served-By", s.name)
+}
+func (s *stubEngine) Health() (*supervisor.WorkerHealth, error) {
+	return &supervisor.WorkerHealth{Status: "ok", Model: s.name}, nil
+}
+func (s *stubEngine) Status() supervisor.WorkerStatus {
+	return supervisor.WorkerStatus{State: supervisor.StateRunning}
+}
+func (s *stubEngine) Stop() error {
+	s.stopped.Add(1)
+	return nil
+}
+
+func serve(m *ModelManager, body string, header string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	if header != "" {
+		req.Header.Set(ModelHeader, header)
+	}
+	rr := httptest.NewRecorder()
+	m.ProxyRequest(rr, req)
+	return rr
+}
+
+func TestProxyRequestRoutesByBodyModel(t *testing.T) {
+	small := &stubEngine{name: "small"}
+	m := &ModelManager{Engine: &stubEngine{name: "default"}}
+	m.Register("small", small)
+
+	body := `{"model":"small","messages":[]}`
+	rr := serve(m, body, "")
+	if got := rr.Header().Get("X-Served-By"); got != "small" {
+		t.Fatalf("expected small engine, got %q", got)
+	}
+	if small.body != body {
+		t.Fatalf("expected body to be forwarded intact, got %q", small.body)
+	}
+}
+
+func TestProxyRequestHeaderOverridesBody(t *testing.T) {
+	m := &ModelManager{Engine: &stubEngine{name: "default"}}
+	m.Register("small", &stubEngine{name: "small"})
+	m.Register("large", &stubEngine{name: "large"})
+
+	rr := serve(m, `{"model":"small"}`, "large")
+	if got := rr.Header().Get("X-Served-By"); got != "large" {
+		t.Fatalf("expected header to win, got %q", got)
+	}
+}
+
+func TestUnknownModelPolicies(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		m := &ModelManager{Engine: &stubEngine{name: "default"}, UnknownModels: UnknownModelDefault}
+		if got := serve(m, `{"model":"nope"}`, "").Header().Get("X-Served-By"); got != "default" {
+			t.Fatalf("expected default engine, got %q", got)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		m := &ModelManager{Engine: &stubEngine{name: "default"}, UnknownModels: UnknownModelReject}
+		rr := serve(m, `{"model":"nope"}`, "")
+		if rr.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), "model_not_found") {
+			t.Fatalf("expected OpenAI-style error body, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("load", func(t *testing.T) {
+		loads := 0
+		m := &ModelManager{
+			Engine:        &stubEngine{name: "default"},
+			UnknownModels: UnknownModelLoad,
+			Loader: func(model string) (InferenceEngine, error) {
+				loads++
+				if model == "broken" {
+					return nil, errors.New("boom")
+				}
+				return &stubEngine{name: model}, nil
+			},
+		}
+		for i := 0; i < 2; i++ {
+			if got := serve(m, `{"model":"fresh"}`, "").Header().Get("X-Served-By"); got != "fresh" {
+				t.Fatalf("expected loaded engine, got %q", got)
+			}
+		}
+		if loads != 1 {
+			t.Fatalf("expected a single load, got %d", loads)
+		}
+		if rr := serve(m, `{"model":"broken"}`, ""); rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected 503 for failed load, got %d", rr.Code)
+		}
+	})
+}
+
+func TestParseUnknownModelPolicy(t *testing.T) {
+	if policy, err := ParseUnknownModelPolicy("load"); err != nil || policy != UnknownModelLoad {
+		t.Errorf("got %q, %v", policy, err)
+	}
+	if _, err := ParseUnknownModelPolicy("Load"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestStopStopsEachEngineOnce(t *testing.T) {
+	def := &stubEngine{name: "default"}
+	other := &stubEngine{name: "other"}
+	m := &ModelManager{Engine: def}
+	m.Register("default-model", def)
+	m.Register("other", other)
+
+	if err := m.Stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+	if def.stopped.Load() != 1 || other.stopped.Load() != 1 {
+		t.Fatalf("expected each engine stopped once, got default=%d other=%d", def.stopped.Load(), other.stopped.Load())
+	}
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"botframework/openai"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+const (
+	shadowTimeout   = 5 * time.Minute
+	maxShadowOutput = 4096
+)
+
+// ShadowConfig mirrors a share of a model's traffic to a candidate model
+type ShadowConfig struct {
+	Shadow  string `json:"shadow"`
+	Percent int    `json:"percent"`
+}
+
+// ShadowStats compares the shadow model against the primary on mirrored requests
+type ShadowStats struct {
+	Model              string  `json:"model"`
+	Shadow             string  `json:"shadow"`
+	Percent            int     `json:"percent"`
+	Mirrored           int     `json:"mirrored"`
+	ShadowErrors       int     `json:"shadow_errors"`
+	PrimaryErrors      int     `json:"primary_errors"`
+	ShadowMeanLatency  float64 `json:"shadow_mean_latency_ms"`
+	PrimaryMeanLatency float64 `json:"primary_mean_latency_ms"`
+}
+
+// ShadowRecord is one mirrored request written to the shadow log
+type ShadowRecord struct {
+	Time             time.Time `json:"time"`
+	Model            string    `json:"model"`
+	Shadow           string    `json:"shadow"`
+	PrimaryStatus    int       `json:"primary_status"`
+	PrimaryLatencyMs int64     `json:"primary_latency_ms"`
+	ShadowStatus     int       `json:"shadow_status"`
+	ShadowLatencyMs  int64     `json:"shadow_latency_ms"`
+	ShadowOutput     string    `json:"shadow_output"`
+}
+
+type shadowState struct {
+	config         ShadowConfig
+	mirrored       int
+	shadowErrors   int
+	primaryErrors  int
+	shadowLatency  time.Duration
+	primaryLatency time.Duration
+}
+
+type shadowResult struct {
+	status  int
+	latency time.Duration
+}
+
+// SetShadow mirrors percent of the model's requests to the shadow model; an empty shadow disables it
+func (m *ModelManager) SetShadow(model string, config ShadowConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if config.Shadow == "" {
+		delete(m.shadows, model)
+		return
+	}
+	if m.shadows == nil {
+		m.shadows = make(map[string]*shadowState)
+	}
+	config.Percent = min(100, max(0, config.Percent))
+	m.shadows[model] = &shadowState{config: config}
+}
+
+// SetShadowLog sets where mirrored request records are written as JSON lines
+func (m *ModelManager) SetShadowLog(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.shadowLog = w
+}
+
+// ShadowStats reports comparison stats for every configured shadow
+func (m *ModelManager) ShadowStats() []ShadowStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var stats []ShadowStats
+	for model, state := range m.shadows {
+		s := ShadowStats{
+			Model:         model,
+			Shadow:        state.config.Shadow,
+			Percent:       state.config.Percent,
+			Mirrored:      state.mirrored,
+			ShadowErrors:  state.shadowErrors,
+			PrimaryErrors: state.primaryErrors,
+		}
+		if state.mirrored > 0 {
+			s.ShadowMeanLatency = float64(state.shadowLatency.Milliseconds()) / float64(state.mirrored)
+			s.PrimaryMeanLatency = float64(state.primaryLatency.Milliseconds()) / float64(state.mirrored)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// shadowFor decides whether this request should be mirrored and returns the shadow model name
+func (m *ModelManager) shadowFor(model string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, ok := m.shadows[model]
+	if !ok || rand.IntN(100) >= state.config.Percent {
+		return "", false
+	}
+	return state.config.Shadow, true
+}
+
+// proxyWithShadow serves the request from primary while a copy runs against the shadow model
+func (m *ModelManager) proxyWithShadow(w http.ResponseWriter, r *http.Request, model, shadow string, serve func(http.ResponseWriter, *http.Request)) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "", "failed to read request body")
+			return
+		}
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	primaryDone := make(chan shadowResult, 1)
+	mirror := r.Clone(context.WithoutCancel(r.Context()))
+	mirror.Body = io.NopCloser(bytes.NewReader(body))
+	go m.runShadow(mirror, model, shadow, primaryDone)
+
+	start := time.Now()
+	sw := &armWriter{ResponseWriter: w, status: http.StatusOK}
+	serve(sw, r)
+	primaryDone <- shadowResult{status: sw.status, latency: time.Since(start)}
+}
+
+func (m *ModelManager) runShadow(r *http.Request, model, shadow string, primaryDone <-chan shadowResult) {
+	ctx, cancel := context.WithTimeout(r.Context(), shadowTimeout)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	record := ShadowRecord{Time: time.Now(), Model: model, Shadow: shadow, ShadowStatus: http.StatusServiceUnavailable}
+	start := time.Now()
+	if e, err := m.registered(shadow); err == nil {
+		capture := &captureWriter{header: make(http.Header), status: http.StatusOK}
+		e.ProxyRequest(capture, r)
+		record.ShadowStatus = capture.status
+		record.ShadowOutput = capture.body.String()
+	} else {
+		record.ShadowOutput = err.Error()
+	}
+	shadowLatency := time.Since(start)
+	record.ShadowLatencyMs = shadowLatency.Milliseconds()
+
+	primary := <-primaryDone
+	record.PrimaryStatus = primary.status
+	record.PrimaryLatencyMs = primary.latency.Milliseconds()
+
+	m.mu.Lock()
+	if state, ok := m.shadows[model]; ok && state.config.Shadow == shadow {
+		state.mirrored++
+		state.shadowLatency += shadowLatency
+		state.primaryLatency += primary.latency
+		if record.ShadowStatus >= http.StatusInternalServerError {
+			state.shadowErrors++
+		}
+		if primary.status >= http.StatusInternalServerError {
+			state.primaryErrors++
+		}
+	}
+	logWriter := m.shadowLog
+	m.mu.Unlock()
+
+	if logWriter == nil {
+		slog.Info("shadow request", "model", model, "shadow", shadow, "primary_status", record.PrimaryStatus,
+			"primary_ms", record.PrimaryLatencyMs, "shadow_status", record.ShadowStatus, "shadow_ms", record.ShadowLatencyMs)
+		return
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	m.shadowLogMu.Lock()
+	_, _ = logWriter.Write(append(line, '\n'))
+	m.shadowLogMu.Unlock()
+}
+
+// captureWriter collects a shadow response so it can be logged instead of sent to the client
+type captureWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (c *captureWriter) Header() http.Header  { return c.header }
+func (c *captureWriter) WriteHeader(code int) { c.status = code }
+func (c *captureWriter) Flush()               {}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if room := maxShadowOutput - c.body.Len(); room > 0 {
+		c.body.Write(b[:min(len(b), room)])
+	}
+	return len(b), nil
+}
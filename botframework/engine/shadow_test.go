@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestShadowMirrorsRequestAndLogsOutput(t *testing.T) {
+	primary := &statusEngine{stubEngine: stubEngine{name: "primary"}, status: http.StatusOK, available: true}
+	candidate := &statusEngine{stubEngine: stubEngine{name: "candidate"}, status: http.StatusOK, available: true}
+	m := &ModelManager{Engine: primary}
+	m.Register("llama", primary)
+	m.Register("llama-next", candidate)
+	m.SetShadow("llama", ShadowConfig{Shadow: "llama-next", Percent: 100})
+	logBuf := &syncBuffer{}
+	m.SetShadowLog(logBuf)
+
+	rr := httptest.NewRecorder()
+	m.ProxyRequest(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"model":"llama"}`)))
+	if rr.Body.String() != "primary" {
+		t.Fatalf("client must only see the primary response, got %q", rr.Body.String())
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && logBuf.String() == "" {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	var record ShadowRecord
+	if err := json.Unmarshal([]byte(logBuf.String()), &record); err != nil {
+		t.Fatalf("expected a JSON shadow record, got %q: %v", logBuf.String(), err)
+	}
+	if record.ShadowOutput != "candidate" || record.PrimaryStatus != http.StatusOK {
+		t.Fatalf("unexpected shadow record: %+v", record)
+	}
+
+	stats := m.ShadowStats()
+	if len(stats) != 1 || stats[0].Mirrored != 1 {
+		t.Fatalf("unexpected shadow stats: %+v", stats)
+	}
+}
+
+func TestShadowDisabled(t *testing.T) {
+	m := &ModelManager{}
+	m.SetShadow("llama", ShadowConfig{Shadow: "other", Percent: 100})
+	m.SetShadow("llama", ShadowConfig{})
+	if _, ok := m.shadowFor("llama"); ok {
+		t.Fatal("expected shadow to be disabled")
+	}
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDrainTimeout bounds how long a swap waits for requests on the old engine
+const DefaultDrainTimeout = 5 * time.Minute
+
+// SwapResult describes a completed hot swap
+type SwapResult struct {
+	Model   string   `json:"model"`
+	Retired []string `json:"retired,omitempty"` // model names that pointed at the replaced engines
+	LoadMs  float64  `json:"load_ms"`
+	DrainMs float64  `json:"drain_ms"`
+	// Drained is false when requests were still running on the old engine at the drain timeout
+	Drained bool `json:"drained"`
+}
+
+// SwapModel makes model the default engine without dropping requests: it starts a fresh engine
+// via Loader (which waits for the worker to become ready), checks its health, switches the
+// default route and the model's route to it, waits for requests already sent to the replaced
+// engines to finish and then stops them. Swaps are serialised.
+func (m *ModelManager) SwapModel(ctx context.Context, model string, drainTimeout time.Duration) (SwapResult, error) {
+	if m.Loader == nil {
+		return SwapResult{}, fmt.Errorf("%w %q: on-demand loading is not configured", ErrUnknownModel, model)
+	}
+	m.swapMu.Lock()
+	defer m.swapMu.Unlock()
+
+	slog.InfoContext(ctx, "hot-swapping model", "model", model)
+	result := SwapResult{Model: model}
+	start := time.Now()
+	next, err := m.Loader(model)
+	if err != nil {
+		return result, fmt.Errorf("load model %q: %w", model, err)
+	}
+	if _, err := next.Health(); err != nil {
+		_ = next.Stop()
+		return result, fmt.Errorf("model %q is not healthy: %w", model, err)
+	}
+	result.LoadMs = float64(time.Since(start)) / float64(time.Millisecond)
+
+	old := m.switchTo(model, next, &result)
+
+	start = time.Now()
+	result.Drained = true
+	for _, e := range old {
+		if !m.drain(ctx, e, drainTimeout) {
+			result.Drained = false
+		}
+		if err := m.stopUnlessShared(e); err != nil {
+			slog.WarnContext(ctx, "stopping replaced engine failed", "err", err)
+		}
+	}
+	result.DrainMs = float64(time.Since(start)) / float64(time.Millisecond)
+	slog.InfoContext(ctx, "now serving model", "model", model, "drain_ms", result.DrainMs)
+	return result, nil
+}
+
+// switchTo atomically points the default route and model at next and retires the engines
+// they served before, returning them for draining
+func (m *ModelManager) switchTo(model string, next InferenceEngine, result *SwapResult) []InferenceEngine {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var old []InferenceEngine
+	for _, e := range []InferenceEngine{m.Engine, m.models[model]} {
+		if e != nil && e != next && !m.retired[e] {
+			if m.retired == nil {
+				m.retired = make(map[InferenceEngine]bool)
+			}
+			m.retired[e] = true
+			old = append(old, e)
+		}
+	}
+	for name, e := range m.models {
+		if m.retired[e] {
+			delete(m.models, name)
+			if name != model {
+				result.Retired = append(result.Retired, name)
+			}
+		}
+	}
+	if m.models == nil {
+		m.models = make(map[string]InferenceEngine)
+	}
+	m.models[model] = next
+	m.Engine = next
+	return old
+}
+
+// acquire counts a request against e until release is called. It fails when e was retired
+// after the request resolved it, in which case the caller resolves again.
+func (m *ModelManager) acquire(e InferenceEngine) (release func(), ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.retired[e] {
+		return nil, false
+	}
+	n := m.inflightCounter(e)
+	n.Add(1)
+	return func() { n.Add(-1) }, true
+}
+
+// inflightCounter returns the count of requests running on e
+func (m *ModelManager) inflightCounter(e InferenceEngine) *atomic.Int64 {
+	counter, _ := m.inflight.LoadOrStore(e, new(atomic.Int64))
+	return counter.(*atomic.Int64)
+}
+
+// drain waits until no requests are running on e, reporting false on timeout
+func (m *ModelManager) drain(ctx context.Context, e InferenceEngine, timeout time.Duration) bool {
+	counter, ok := m.inflight.Load(e)
+	if !ok {
+		return true
+	}
+	n := counter.(*atomic.Int64)
+	defer m.inflight.Delete(e)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for n.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-deadline.C:
+			slog.WarnContext(ctx, "requests still running after drain, stopping anyway", "requests", n.Load(), "timeout", timeout)
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// blockingEngine holds each request until release is closed
+type blockingEngine struct {
+	stubEngine
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingEngine) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Served-By", b.name)
+	b.started <- struct{}{}
+	<-b.release
+}
+
+func TestSwapModelDrainsOldEngine(t *testing.T) {
+	old := &blockingEngine{stubEngine: stubEngine{name: "old"}, started: make(chan struct{}, 1), release: make(chan struct{})}
+	next := &stubEngine{name: "next"}
+	m := &ModelManager{Engine: old, Loader: func(model string) (InferenceEngine, error) { return next, nil }}
+	m.Register("old.gguf", old)
+
+	inflight := make(chan string)
+	go func() {
+		rr := serve(m, `{"model":"old.gguf"}`, "")
+		inflight <- rr.Header().Get("X-Served-By")
+	}()
+	<-old.started
+
+	swapped := make(chan SwapResult)
+	go func() {
+		result, err := m.SwapModel(context.Background(), "next", time.Second)
+		if err != nil {
+			t.Errorf("swap: %v", err)
+		}
+		swapped <- result
+	}()
+
+	// new requests reach the new engine while the old one drains
+	deadline := time.Now().Add(time.Second)
+	for {
+		if e, _ := m.Resolve(""); e == next {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("default engine was not switched")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := serve(m, `{"model":"next"}`, "").Header().Get("X-Served-By"); got != "next" {
+		t.Fatalf("expected next to serve new requests, got %q", got)
+	}
+	if old.stopped.Load() != 0 {
+		t.Fatal("old engine stopped before its request finished")
+	}
+
+	close(old.release)
+	if got := <-inflight; got != "old" {
+		t.Fatalf("expected in-flight request to finish on old, got %q", got)
+	}
+	result := <-swapped
+	if !result.Drained || old.stopped.Load() != 1 {
+		t.Fatalf("expected old drained and stopped, got drained=%v stopped=%d", result.Drained, old.stopped.Load())
+	}
+	if len(result.Retired) != 1 || result.Retired[0] != "old.gguf" {
+		t.Fatalf("expected old.gguf retired, got %v", result.Retired)
+	}
+	if _, err := m.registered("old.gguf"); err == nil {
+		t.Fatal("expected old.gguf to be unregistered")
+	}
+}
+
+func TestSwapModelDrainTimeout(t *testing.T) {
+	old := &blockingEngine{stubEngine: stubEngine{name: "old"}, started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(old.release)
+	m := &ModelManager{Engine: old, Loader: func(model string) (InferenceEngine, error) { return &stubEngine{name: model}, nil }}
+
+	go serve(m, `{}`, "")
+	<-old.started
+
+	result, err := m.SwapModel(context.Background(), "next", 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("swap: %v", err)
+	}
+	if result.Drained || old.stopped.Load() != 1 {
+		t.Fatalf("expected old stopped after drain timeout, got drained=%v stopped=%d", result.Drained, old.stopped.Load())
+	}
+}
+
+func TestSwapModelKeepsEngineWhenLoadFails(t *testing.T) {
+	old := &stubEngine{name: "old"}
+	m := &ModelManager{Engine: old, Loader: func(model string) (InferenceEngine, error) { return nil, errors.New("no such file") }}
+
+	if _, err := m.SwapModel(context.Background(), "next", time.Second); err == nil {
+		t.Fatal("expected load error")
+	}
+	if e, _ := m.Resolve(""); e != old || old.stopped.Load() != 0 {
+		t.Fatal("expected old engine to keep serving")
+	}
+}
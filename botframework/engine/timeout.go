@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestTimeoutHeader lets a client bound its own request below the limit, in seconds
+// ("30", "2.5") or as a Go duration ("90s")
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+type timeoutLimitKey struct{}
+
+// timeoutLimit is the limit set for one request by WithTimeoutLimit
+type timeoutLimit struct {
+	limit  time.Duration
+	lifted bool
+}
+
+// WithTimeoutLimit returns r with limit in place of the manager's RequestTimeout, as the
+// per-model defaults set it; zero keeps the manager's. When lifted, the client's
+// X-Request-Timeout may exceed the limit.
+func WithTimeoutLimit(r *http.Request, limit time.Duration, lifted bool) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), timeoutLimitKey{}, timeoutLimit{limit, lifted}))
+}
+
+// TimeoutFor is how long r may take to generate once its worker is awake: the client's
+// X-Request-Timeout when it is shorter than the limit, else the limit, which is limit
+// unless WithTimeoutLimit replaced it. Zero means no deadline.
+func TimeoutFor(r *http.Request, limit time.Duration) time.Duration {
+	set, _ := r.Context().Value(timeoutLimitKey{}).(timeoutLimit)
+	if set.limit > 0 {
+		limit = set.limit
+	}
+	value := strings.TrimSpace(r.Header.Get(RequestTimeoutHeader))
+	if value == "" {
+		return limit
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, serr := strconv.ParseFloat(value, 64)
+		if serr != nil {
+			return limit
+		}
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	if timeout <= 0 || (limit > 0 && timeout > limit && !set.lifted) {
+		return limit
+	}
+	return timeout
+}
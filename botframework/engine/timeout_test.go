@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	cases := []struct {
+		header string
+		limit  time.Duration
+		want   time.Duration
+	}{
+		{"", time.Minute, time.Minute},
+		{"", 0, 0},
+		{"30", time.Minute, 30 * time.Second},
+		{"2.5", 0, 2500 * time.Millisecond},
+		{"90s", 0, 90 * time.Second},
+		{"5m", time.Minute, time.Minute}, // a client cannot lift the limit
+		{"-1", time.Minute, time.Minute},
+		{"soon", time.Minute, time.Minute},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if c.header != "" {
+			req.Header.Set(RequestTimeoutHeader, c.header)
+		}
+		if got := TimeoutFor(req, c.limit); got != c.want {
+			t.Errorf("TimeoutFor(%q, %v) = %v, want %v", c.header, c.limit, got, c.want)
+		}
+	}
+}
+
+func TestTimeoutForWithLimit(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set(RequestTimeoutHeader, "5m")
+	if got := TimeoutFor(WithTimeoutLimit(req, 30*time.Second, false), time.Minute); got != 30*time.Second {
+		t.Errorf("a model's limit should replace the manager's, got %v", got)
+	}
+	if got := TimeoutFor(WithTimeoutLimit(req, 0, false), time.Minute); got != time.Minute {
+		t.Errorf("a zero limit should keep the manager's, got %v", got)
+	}
+	if got := TimeoutFor(WithTimeoutLimit(req, 30*time.Second, true), time.Minute); got != 5*time.Minute {
+		t.Errorf("a lifted limit should let the client ask for more, got %v", got)
+	}
+}
+
+// deadlineEngine reports the context error its request ended with
+type deadlineEngine struct {
+	stubEngine
+	err chan error
+}
+
+func (d *deadlineEngine) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+	d.err <- r.Context().Err()
+}
+
+func TestProxyRequestCancelsAtDeadline(t *testing.T) {
+	e := &deadlineEngine{err: make(chan error, 1)}
+	m := &ModelManager{Engine: e, RequestTimeout: time.Minute}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`))
+	req.Header.Set(RequestTimeoutHeader, "0.05")
+	start := time.Now()
+	m.ProxyRequest(httptest.NewRecorder(), req)
+	if err := <-e.err; err != context.DeadlineExceeded {
+		t.Fatalf("request ended with %v, want the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("request ran for %v past its 50ms timeout", elapsed)
+	}
+}
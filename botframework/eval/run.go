@@ -0,0 +1,170 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options controls a benchmark run against an OpenAI-compatible endpoint
+type Options struct {
+	BaseURL     string
+	Model       string
+	APIKey      string
+	Limit       int // run only the first Limit items of each suite when set
+	Concurrency int
+	Client      *http.Client
+}
+
+// ItemResult is the outcome of one question
+type ItemResult struct {
+	ID        string `json:"id"`
+	Correct   bool   `json:"correct"`
+	Reply     string `json:"reply,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// Result summarises one suite run
+type Result struct {
+	Suite        string       `json:"suite"`
+	Kind         string       `json:"kind"`
+	Model        string       `json:"model"`
+	Score        float64      `json:"score"` // percent correct, comparable to published benchmark numbers
+	Correct      int          `json:"correct"`
+	Total        int          `json:"total"`
+	Errors       int          `json:"errors"`
+	AvgLatencyMs int64        `json:"avg_latency_ms"`
+	Duration     string       `json:"duration"`
+	Items        []ItemResult `json:"items,omitempty"`
+}
+
+// Run asks opts.Model every question in suite at temperature 0 and grades the replies.
+// Requests that fail count as wrong answers and are reported in Errors.
+func Run(ctx context.Context, suite *Suite, opts Options) Result {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	items := suite.Items
+	if opts.Limit > 0 && opts.Limit < len(items) {
+		items = items[:opts.Limit]
+	}
+
+	results := make([]ItemResult, len(items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				results[index] = ask(ctx, opts, items[index])
+			}
+		}()
+	}
+
+	started := time.Now()
+feed:
+	for i := range items {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	result := Result{Suite: suite.Name, Kind: suite.Kind, Model: opts.Model, Duration: time.Since(started).Round(time.Millisecond).String()}
+	var latency int64
+	for _, item := range results {
+		if item.ID == "" {
+			continue // cancelled before it was sent
+		}
+		result.Total++
+		latency += item.LatencyMs
+		if item.Error != "" {
+			result.Errors++
+		}
+		if item.Correct {
+			result.Correct++
+		}
+		result.Items = append(result.Items, item)
+	}
+	if result.Total > 0 {
+		result.Score = 100 * float64(result.Correct) / float64(result.Total)
+		result.AvgLatencyMs = latency / int64(result.Total)
+	}
+	return result
+}
+
+// ask sends one question and grades the reply
+func ask(ctx context.Context, opts Options, item Item) ItemResult {
+	result := ItemResult{ID: item.ID}
+	maxTokens := item.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 256
+	}
+	body, _ := json.Marshal(map[string]any{
+		"model":       opts.Model,
+		"messages":    []map[string]string{{"role": "user", "content": item.Prompt}},
+		"temperature": 0,
+		"max_tokens":  maxTokens,
+	})
+
+	start := time.Now()
+	reply, err := complete(ctx, opts, body)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Reply = reply
+	result.Correct = Grade(item, reply)
+	return result
+}
+
+func complete(ctx context.Context, opts Options, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(opts.BaseURL, "/")+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.APIKey)
+	}
+
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return "", err
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("response has no choices")
+	}
+	return completion.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,59 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunScoresReplies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model       string  `json:"model"`
+			Temperature float64 `json:"temperature"`
+			Messages    []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "tiny" || req.Temperature != 0 {
+			t.Errorf("unexpected request %+v", req)
+		}
+
+		prompt := req.Messages[0].Content
+		if strings.Contains(prompt, "broken") {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		reply := "B"
+		if strings.Contains(prompt, "capital") {
+			reply = "A"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		})
+	}))
+	defer server.Close()
+
+	suite := &Suite{Name: "test", Kind: KindCustom, Items: []Item{
+		{ID: "1", Prompt: "capital of France", Expected: "A", Match: "choice"},
+		{ID: "2", Prompt: "2+2", Expected: "B", Match: "choice"},
+		{ID: "3", Prompt: "3+3", Expected: "C", Match: "choice"},
+		{ID: "4", Prompt: "broken", Expected: "A", Match: "choice"},
+		{ID: "5", Prompt: "skipped by limit", Expected: "A", Match: "choice"},
+	}}
+
+	result := Run(context.Background(), suite, Options{BaseURL: server.URL, Model: "tiny", Limit: 4, Concurrency: 2})
+	if result.Total != 4 || result.Correct != 2 || result.Errors != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if result.Score != 50 {
+		t.Errorf("want score 50, got %v", result.Score)
+	}
+	if result.Items[3].Error == "" {
+		t.Error("want error recorded for failed request")
+	}
+}
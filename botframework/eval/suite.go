@@ -0,0 +1,199 @@
+package eval
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Suite kinds
+const (
+	KindMMLU   = "mmlu"
+	KindGSM8K  = "gsm8k"
+	KindCustom = "custom"
+)
+
+// Item is one benchmark question with the prompt sent to the model and how to grade the reply
+type Item struct {
+	ID       string `json:"id"`
+	Prompt   string `json:"prompt"`
+	Expected string `json:"expected"`
+	// Match is exact, contains, regex, choice (a multiple-choice letter) or number
+	Match     string `json:"match"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
+}
+
+// Suite is a named set of items
+type Suite struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Items []Item `json:"items"`
+}
+
+//go:embed data/*.jsonl
+var builtin embed.FS
+
+// Builtin returns the small sample bundled for kind. The samples are written in MMLU and
+// GSM8K format for quick smoke tests; pass the official subsets with LoadSuite for
+// numbers comparable to published results.
+func Builtin(kind string) (*Suite, error) {
+	data, err := builtin.ReadFile("data/" + kind + "_sample.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("no built-in %q suite (have mmlu, gsm8k)", kind)
+	}
+	return parseSuite(kind, kind+"-sample", bytes.NewReader(data))
+}
+
+// LoadSuite reads a JSONL file in the format for kind:
+//
+//	mmlu:   {"question": ..., "choices": [...], "answer": 2 | "C"}
+//	gsm8k:  {"question": ..., "answer": "reasoning ... #### 42"}
+//	custom: {"prompt": ..., "expected": ..., "match": "contains"}
+func LoadSuite(kind, path string) (*Suite, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseSuite(kind, path, file)
+}
+
+func parseSuite(kind, name string, r io.Reader) (*Suite, error) {
+	suite := &Suite{Name: name, Kind: kind}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var item Item
+		var err error
+		switch kind {
+		case KindMMLU:
+			item, err = parseMMLU([]byte(text))
+		case KindGSM8K:
+			item, err = parseGSM8K([]byte(text))
+		case KindCustom:
+			err = json.Unmarshal([]byte(text), &item)
+			if item.Match == "" {
+				item.Match = "contains"
+			}
+		default:
+			return nil, fmt.Errorf("unknown suite kind %q", kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+		if item.ID == "" {
+			item.ID = fmt.Sprintf("%s-%d", kind, line)
+		}
+		suite.Items = append(suite.Items, item)
+	}
+	return suite, scanner.Err()
+}
+
+var letters = []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+
+func parseMMLU(data []byte) (Item, error) {
+	var row struct {
+		Question string          `json:"question"`
+		Choices  []string        `json:"choices"`
+		Answer   json.RawMessage `json:"answer"`
+	}
+	if err := json.Unmarshal(data, &row); err != nil {
+		return Item{}, err
+	}
+	if len(row.Choices) < 2 || len(row.Choices) > len(letters) {
+		return Item{}, fmt.Errorf("expected 2-%d choices, got %d", len(letters), len(row.Choices))
+	}
+
+	var index int
+	var letter string
+	if json.Unmarshal(row.Answer, &index) == nil {
+		if index < 0 || index >= len(row.Choices) {
+			return Item{}, fmt.Errorf("answer index %d out of range", index)
+		}
+		letter = letters[index]
+	} else if json.Unmarshal(row.Answer, &letter) != nil || len(letter) != 1 {
+		return Item{}, fmt.Errorf("answer must be a choice index or letter")
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString(row.Question + "\n")
+	for i, choice := range row.Choices {
+		fmt.Fprintf(&prompt, "%s. %s\n", letters[i], choice)
+	}
+	prompt.WriteString("Answer with the letter of the correct choice only.")
+	return Item{Prompt: prompt.String(), Expected: strings.ToUpper(letter), Match: "choice", MaxTokens: 8}, nil
+}
+
+func parseGSM8K(data []byte) (Item, error) {
+	var row struct {
+		Question string `json:"question"`
+		Answer   string `json:"answer"`
+	}
+	if err := json.Unmarshal(data, &row); err != nil {
+		return Item{}, err
+	}
+	_, final, ok := strings.Cut(row.Answer, "####")
+	if !ok {
+		return Item{}, fmt.Errorf("answer has no #### final value")
+	}
+	prompt := row.Question + "\nSolve the problem step by step, then give the final number on the last line as \"Answer: <number>\"."
+	return Item{Prompt: prompt, Expected: normalizeNumber(final), Match: "number", MaxTokens: 512}, nil
+}
+
+var (
+	choiceLead   = regexp.MustCompile(`^\s*\(?([A-H])\)?(?:[.:)\s]|$)`)
+	choiceStated = regexp.MustCompile(`(?i)answer(?:\s+is)?\s*:?\s*\(?([A-H])\b`)
+	choiceAny    = regexp.MustCompile(`\b([A-H])\b`)
+	numberStated = regexp.MustCompile(`(?i)(?:answer\s*:?|####)\s*\$?\s*(-?[\d,]*\.?\d+)`)
+	numberAny    = regexp.MustCompile(`-?[\d,]*\.?\d+`)
+)
+
+// Grade reports whether reply answers item correctly
+func Grade(item Item, reply string) bool {
+	switch item.Match {
+	case "choice":
+		for _, re := range []*regexp.Regexp{choiceLead, choiceStated, choiceAny} {
+			if m := re.FindStringSubmatch(reply); m != nil {
+				return strings.EqualFold(m[1], item.Expected)
+			}
+		}
+		return false
+	case "number":
+		if m := numberStated.FindAllStringSubmatch(reply, -1); len(m) > 0 {
+			return normalizeNumber(m[len(m)-1][1]) == item.Expected
+		}
+		if m := numberAny.FindAllString(reply, -1); len(m) > 0 {
+			return normalizeNumber(m[len(m)-1]) == item.Expected
+		}
+		return false
+	case "exact":
+		return strings.TrimSpace(reply) == strings.TrimSpace(item.Expected)
+	case "regex":
+		re, err := regexp.Compile(item.Expected)
+		return err == nil && re.MatchString(reply)
+	default:
+		return strings.Contains(strings.ToLower(reply), strings.ToLower(item.Expected))
+	}
+}
+
+func normalizeNumber(s string) string {
+	s = strings.TrimSpace(strings.NewReplacer(",", "", "$", "").Replace(s))
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinSuitesParse(t *testing.T) {
+	for _, kind := range []string{KindMMLU, KindGSM8K} {
+		suite, err := Builtin(kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(suite.Items) < 10 {
+			t.Errorf("%s: want at least 10 items, got %d", kind, len(suite.Items))
+		}
+	}
+	if _, err := Builtin("hellaswag"); err == nil {
+		t.Error("want error for unknown built-in suite")
+	}
+}
+
+func TestLoadSuiteFormats(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	mmlu, err := LoadSuite(KindMMLU, write("mmlu.jsonl", `{"question":"2+2?","choices":["3","4","5","6"],"answer":"b"}`+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := mmlu.Items[0]; item.Expected != "B" || item.Match != "choice" {
+		t.Errorf("unexpected mmlu item %+v", item)
+	}
+
+	gsm, err := LoadSuite(KindGSM8K, write("gsm.jsonl", `{"question":"q","answer":"3 * 400 = 1,200\n#### 1,200"}`+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := gsm.Items[0]; item.Expected != "1200" || item.Match != "number" {
+		t.Errorf("unexpected gsm8k item %+v", item)
+	}
+
+	custom, err := LoadSuite(KindCustom, write("custom.jsonl", `{"id":"greet","prompt":"Say hi","expected":"hi"}`+"\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(custom.Items) != 1 || custom.Items[0].Match != "contains" || custom.Items[0].ID != "greet" {
+		t.Errorf("unexpected custom suite %+v", custom.Items)
+	}
+
+	if _, err := LoadSuite(KindGSM8K, write("bad.jsonl", `{"question":"q","answer":"no marker"}`)); err == nil {
+		t.Error("want error for gsm8k answer without ####")
+	}
+}
+
+func TestGrade(t *testing.T) {
+	choice := Item{Expected: "C", Match: "choice"}
+	number := Item{Expected: "1200", Match: "number"}
+
+	cases := []struct {
+		item  Item
+		reply string
+		want  bool
+	}{
+		{choice, "C", true},
+		{choice, "(C) 3x^2", true},
+		{choice, "a good guess would be D", false},
+		{choice, "The answer is C because the power rule applies.", true},
+		{choice, "B. x^2", false},
+		{choice, "I am not sure", false},
+		{number, "3 boxes cost 400 each, so 3 * 400 = 1200.\nAnswer: $1,200", true},
+		{number, "Answer: 1200.00", true},
+		{number, "The total is 1200", true},
+		{number, "Answer: 1100", false},
+		{Item{Expected: "Paris", Match: "exact"}, " Paris\n", true},
+		{Item{Expected: `^\d{3}$`, Match: "regex"}, "123", true},
+		{Item{Expected: "hello", Match: "contains"}, "Well, HELLO there", true},
+	}
+	for _, c := range cases {
+		if got := Grade(c.item, c.reply); got != c.want {
+			t.Errorf("Grade(%s %q, %q) = %v, want %v", c.item.Match, c.item.Expected, c.reply, got, c.want)
+		}
+	}
+}
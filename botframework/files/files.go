@@ -0,0 +1,312 @@
+package files
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by Store
+var (
+	ErrNotFound      = errors.New("file not found")
+	ErrTooLarge      = errors.New("file exceeds the upload size limit")
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// File is the OpenAI-style metadata of an uploaded file
+type File struct {
+	ID        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Owner     string `json:"-"`
+}
+
+// stored is the on-disk metadata, which keeps the owner hidden from API responses
+type stored struct {
+	File
+	Owner string `json:"owner"`
+}
+
+// Store keeps uploaded files on disk, one data file and one metadata file per upload.
+// Every file belongs to the owner that uploaded it and is invisible to other owners.
+type Store struct {
+	Dir string
+	// MaxFileBytes bounds a single upload, QuotaBytes the total stored per owner (0 = unlimited)
+	MaxFileBytes int64
+	QuotaBytes   int64
+	// DefaultTTL expires files that were uploaded without an explicit expiry (0 = keep)
+	DefaultTTL time.Duration
+
+	mu    sync.Mutex
+	files map[string]File
+	now   func() time.Time
+}
+
+// NewStore opens (or creates) a file store in dir, loading existing metadata
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &Store{
+		Dir:          dir,
+		MaxFileBytes: 512 << 20,
+		files:        make(map[string]File),
+		now:          time.Now,
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var meta stored
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		meta.File.Owner = meta.Owner
+		s.files[meta.ID] = meta.File
+	}
+	return s, nil
+}
+
+// Owner identifies the caller of r by a hash of its bearer token, so files are tied to
+// API keys without storing the keys themselves. Unauthenticated callers share one owner.
+func Owner(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (s *Store) dataPath(id string) string { return filepath.Join(s.Dir, id) }
+func (s *Store) metaPath(id string) string { return filepath.Join(s.Dir, id+".json") }
+
+// Usage returns the bytes stored by owner
+func (s *Store) Usage(owner string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage(owner)
+}
+
+func (s *Store) usage(owner string) int64 {
+	var total int64
+	for _, f := range s.files {
+		if f.Owner == owner {
+			total += f.Bytes
+		}
+	}
+	return total
+}
+
+// Create stores the contents of r for owner. A zero ttl applies DefaultTTL.
+func (s *Store) Create(owner, filename, purpose string, ttl time.Duration, r io.Reader) (File, error) {
+	id, err := newID()
+	if err != nil {
+		return File{}, err
+	}
+
+	// Write to a temporary name first so partial uploads are never visible
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return File{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if s.MaxFileBytes > 0 {
+		r = io.LimitReader(r, s.MaxFileBytes+1)
+	}
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return File{}, err
+	}
+	if s.MaxFileBytes > 0 && n > s.MaxFileBytes {
+		return File{}, ErrTooLarge
+	}
+
+	now := s.now()
+	file := File{
+		ID:        id,
+		Object:    "file",
+		Bytes:     n,
+		CreatedAt: now.Unix(),
+		Filename:  filepath.Base(filename),
+		Purpose:   purpose,
+		Owner:     owner,
+	}
+	if ttl == 0 {
+		ttl = s.DefaultTTL
+	}
+	if ttl > 0 {
+		file.ExpiresAt = now.Add(ttl).Unix()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.QuotaBytes > 0 && s.usage(owner)+n > s.QuotaBytes {
+		return File{}, ErrQuotaExceeded
+	}
+	meta, err := json.Marshal(stored{File: file, Owner: owner})
+	if err != nil {
+		return File{}, err
+	}
+	if err := os.Rename(tmp.Name(), s.dataPath(id)); err != nil {
+		return File{}, err
+	}
+	if err := os.WriteFile(s.metaPath(id), meta, 0o600); err != nil {
+		os.Remove(s.dataPath(id))
+		return File{}, err
+	}
+	s.files[id] = file
+	return file, nil
+}
+
+// Get returns the metadata of one of owner's files
+func (s *Store) Get(owner, id string) (File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.files[id]
+	if !ok || file.Owner != owner || s.expired(file) {
+		return File{}, ErrNotFound
+	}
+	return file, nil
+}
+
+// Open returns the contents of one of owner's files
+func (s *Store) Open(owner, id string) (io.ReadCloser, File, error) {
+	file, err := s.Get(owner, id)
+	if err != nil {
+		return nil, File{}, err
+	}
+	f, err := os.Open(s.dataPath(id))
+	if err != nil {
+		return nil, File{}, err
+	}
+	return f, file, nil
+}
+
+// ReadAll returns the contents of one of owner's files
+func (s *Store) ReadAll(owner, id string) ([]byte, File, error) {
+	rc, file, err := s.Open(owner, id)
+	if err != nil {
+		return nil, File{}, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return data, file, err
+}
+
+// List returns owner's files, newest first, optionally filtered by purpose
+func (s *Store) List(owner, purpose string) []File {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []File{}
+	for _, file := range s.files {
+		if file.Owner == owner && (purpose == "" || file.Purpose == purpose) && !s.expired(file) {
+			out = append(out, file)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt != out[j].CreatedAt {
+			return out[i].CreatedAt > out[j].CreatedAt
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Delete removes one of owner's files
+func (s *Store) Delete(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file, ok := s.files[id]
+	if !ok || file.Owner != owner {
+		return ErrNotFound
+	}
+	return s.remove(id)
+}
+
+func (s *Store) remove(id string) error {
+	delete(s.files, id)
+	err := os.Remove(s.dataPath(id))
+	if metaErr := os.Remove(s.metaPath(id)); err == nil {
+		err = metaErr
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) expired(file File) bool {
+	return file.ExpiresAt > 0 && s.now().Unix() >= file.ExpiresAt
+}
+
+// Cleanup deletes expired files and returns how many were removed
+func (s *Store) Cleanup() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := 0
+	for id, file := range s.files {
+		if s.expired(file) {
+			if err := s.remove(id); err != nil {
+				slog.Warn("failed to remove expired file", "file", id, "err", err)
+				continue
+			}
+			removed++
+		}
+	}
+	return removed
+}
+
+// Run removes expired files every interval until ctx is cancelled
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if removed := s.Cleanup(); removed > 0 {
+			slog.Info("removed expired files", "count", removed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "file-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,106 @@
+package files
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreOwnershipAndPersistence(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := store.Create("alice", "../notes.txt", "assistants", 0, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Bytes != 5 || file.Filename != "notes.txt" || !strings.HasPrefix(file.ID, "file-") {
+		t.Errorf("unexpected file %+v", file)
+	}
+	if _, err := store.Get("bob", file.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other owners must not see the file, got %v", err)
+	}
+	if err := store.Delete("bob", file.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other owners must not delete the file, got %v", err)
+	}
+
+	reopened, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, got, err := reopened.ReadAll("alice", file.ID)
+	if err != nil || string(data) != "hello" || got.Purpose != "assistants" {
+		t.Fatalf("want persisted file, got %q %+v %v", data, got, err)
+	}
+	if list := reopened.List("alice", "batch"); len(list) != 0 {
+		t.Errorf("purpose filter failed: %+v", list)
+	}
+	if err := reopened.Delete("alice", file.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.List("alice", "")) != 0 {
+		t.Error("file still listed after delete")
+	}
+}
+
+func TestStoreLimits(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.MaxFileBytes = 10
+	store.QuotaBytes = 15
+
+	if _, err := store.Create("alice", "big.bin", "batch", 0, strings.NewReader(strings.Repeat("x", 11))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("want ErrTooLarge, got %v", err)
+	}
+	if _, err := store.Create("alice", "a.bin", "batch", 0, strings.NewReader(strings.Repeat("x", 10))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create("alice", "b.bin", "batch", 0, strings.NewReader(strings.Repeat("x", 10))); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("want ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := store.Create("bob", "b.bin", "batch", 0, strings.NewReader(strings.Repeat("x", 10))); err != nil {
+		t.Errorf("quota is per owner, got %v", err)
+	}
+}
+
+func TestStoreExpiresFiles(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	store.DefaultTTL = time.Hour
+
+	short, _ := store.Create("alice", "a.txt", "batch", time.Minute, strings.NewReader("a"))
+	long, _ := store.Create("alice", "b.txt", "batch", 0, strings.NewReader("b"))
+
+	now = now.Add(2 * time.Minute)
+	if _, err := store.Get("alice", short.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired file still readable: %v", err)
+	}
+	if removed := store.Cleanup(); removed != 1 {
+		t.Errorf("want 1 file removed, got %d", removed)
+	}
+	if _, err := store.Get("alice", long.ID); err != nil {
+		t.Errorf("file with default TTL expired early: %v", err)
+	}
+}
+
+func TestOwnerHashesBearerToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/files", nil)
+	if Owner(r) != "" {
+		t.Error("want anonymous owner without credentials")
+	}
+	r.Header.Set("Authorization", "Bearer sk-secret")
+	owner := Owner(r)
+	if owner == "" || strings.Contains(owner, "secret") {
+		t.Errorf("unexpected owner %q", owner)
+	}
+}
@@ -0,0 +1,133 @@
+package gputune
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Profile describes how GPUs are tuned while workers run
+type Profile struct {
+	Name string
+	// PowerLimitPercent scales the default board power limit; 0 leaves it unchanged
+	PowerLimitPercent int
+	// LockMaxClocks pins graphics clocks to the maximum supported clock
+	LockMaxClocks bool
+	// Persistence keeps the driver loaded between worker restarts
+	Persistence bool
+}
+
+var Profiles = map[string]Profile{
+	"quiet":           {Name: "quiet", PowerLimitPercent: 70, Persistence: true},
+	"balanced":        {Name: "balanced", PowerLimitPercent: 100, Persistence: true},
+	"max-performance": {Name: "max-performance", PowerLimitPercent: 110, LockMaxClocks: true, Persistence: true},
+}
+
+// Runner executes nvidia-smi with the given arguments
+type Runner func(args ...string) ([]byte, error)
+
+func nvidiaSMI(args ...string) ([]byte, error) {
+	return exec.Command("nvidia-smi", args...).CombinedOutput()
+}
+
+type gpuState struct {
+	index        string
+	powerLimit   float64
+	defaultLimit float64
+	minLimit     float64
+	maxLimit     float64
+	persistence  bool
+	maxClock     int
+}
+
+// Tuner applies a profile and remembers the original settings so they can be restored
+type Tuner struct {
+	run      Runner
+	original []gpuState
+	locked   bool
+}
+
+func NewTuner() *Tuner {
+	return &Tuner{run: nvidiaSMI}
+}
+
+// Apply records the current GPU settings, then applies the profile to every GPU
+func (t *Tuner) Apply(profile Profile) error {
+	states, err := t.query()
+	if err != nil {
+		return err
+	}
+	t.original = states
+
+	var errs []error
+	for _, gpu := range states {
+		if profile.Persistence && !gpu.persistence {
+			errs = append(errs, t.exec("-i", gpu.index, "-pm", "1"))
+		}
+		if profile.PowerLimitPercent > 0 && gpu.defaultLimit > 0 {
+			limit := gpu.defaultLimit * float64(profile.PowerLimitPercent) / 100
+			limit = min(gpu.maxLimit, max(gpu.minLimit, limit))
+			errs = append(errs, t.exec("-i", gpu.index, "-pl", strconv.FormatFloat(limit, 'f', 0, 64)))
+		}
+		if profile.LockMaxClocks && gpu.maxClock > 0 {
+			errs = append(errs, t.exec("-i", gpu.index, "-lgc", fmt.Sprintf("%d,%d", gpu.maxClock, gpu.maxClock)))
+			t.locked = true
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Restore puts back the power limits, clocks and persistence mode captured by Apply
+func (t *Tuner) Restore() error {
+	var errs []error
+	for _, gpu := range t.original {
+		if gpu.powerLimit > 0 {
+			errs = append(errs, t.exec("-i", gpu.index, "-pl", strconv.FormatFloat(gpu.powerLimit, 'f', 0, 64)))
+		}
+		if t.locked {
+			errs = append(errs, t.exec("-i", gpu.index, "-rgc"))
+		}
+		if !gpu.persistence {
+			errs = append(errs, t.exec("-i", gpu.index, "-pm", "0"))
+		}
+	}
+	t.original = nil
+	t.locked = false
+	return errors.Join(errs...)
+}
+
+func (t *Tuner) query() ([]gpuState, error) {
+	out, err := t.run("--query-gpu=index,power.limit,power.default_limit,power.min_limit,power.max_limit,persistence_mode,clocks.max.graphics", "--format=csv,noheader,nounits")
+	if err != nil {
+		return nil, fmt.Errorf("query gpu settings: %w", err)
+	}
+
+	var states []gpuState
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.Split(line, ",")
+		if len(parts) < 7 {
+			continue
+		}
+		field := func(i int) string { return strings.TrimSpace(parts[i]) }
+		state := gpuState{index: field(0), persistence: strings.EqualFold(field(5), "Enabled")}
+		state.powerLimit, _ = strconv.ParseFloat(field(1), 64)
+		state.defaultLimit, _ = strconv.ParseFloat(field(2), 64)
+		state.minLimit, _ = strconv.ParseFloat(field(3), 64)
+		state.maxLimit, _ = strconv.ParseFloat(field(4), 64)
+		state.maxClock, _ = strconv.Atoi(field(6))
+		states = append(states, state)
+	}
+	if len(states) == 0 {
+		return nil, errors.New("no NVIDIA GPUs found")
+	}
+	return states, nil
+}
+
+func (t *Tuner) exec(args ...string) error {
+	if out, err := t.run(args...); err != nil {
+		return fmt.Errorf("nvidia-smi %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package gputune
+
+import (
+	"strings"
+	"testing"
+)
+
+func fakeRunner(calls *[]string) Runner {
+	return func(args ...string) ([]byte, error) {
+		if strings.HasPrefix(args[0], "--query-gpu") {
+			return []byte("0, 350.00, 350.00, 100.00, 400.00, Disabled, 2100\n1, 300.00, 350.00, 100.00, 400.00, Enabled, 2100\n"), nil
+		}
+		*calls = append(*calls, strings.Join(args, " "))
+		return nil, nil
+	}
+}
+
+func TestApplyQuietProfile(t *testing.T) {
+	var calls []string
+	tuner := &Tuner{run: fakeRunner(&calls)}
+
+	if err := tuner.Apply(Profiles["quiet"]); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	want := []string{"-i 0 -pm 1", "-i 0 -pl 245", "-i 1 -pl 245"}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected calls:\n got %v\nwant %v", calls, want)
+	}
+}
+
+func TestRestoreRevertsOriginalSettings(t *testing.T) {
+	var calls []string
+	tuner := &Tuner{run: fakeRunner(&calls)}
+	if err := tuner.Apply(Profiles["max-performance"]); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if !strings.Contains(strings.Join(calls, "|"), "-i 0 -lgc 2100,2100") {
+		t.Fatalf("expected clocks to be locked, got %v", calls)
+	}
+	// 110% of 350W is clamped to the 400W maximum only when it exceeds it
+	if !strings.Contains(strings.Join(calls, "|"), "-i 0 -pl 385") {
+		t.Fatalf("expected raised power limit, got %v", calls)
+	}
+
+	calls = nil
+	if err := tuner.Restore(); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	want := []string{"-i 0 -pl 350", "-i 0 -rgc", "-i 0 -pm 0", "-i 1 -pl 300", "-i 1 -rgc"}
+	if strings.Join(calls, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected restore calls:\n got %v\nwant %v", calls, want)
+	}
+}
@@ -0,0 +1,400 @@
+// Package guardrails enforces limits and content rules on completion requests: a maximum
+// prompt size, and regular expressions or deny-listed words that reject or redact prompts
+// and streamed output.
+package guardrails
+
+import (
+	"botframework/chat"
+	"botframework/openai"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// What a rule does with a match
+const (
+	ActionReject = "reject"
+	ActionRedact = "redact"
+)
+
+// Where a rule applies
+const (
+	ApplyInput  = "input"
+	ApplyOutput = "output"
+	ApplyBoth   = "both"
+)
+
+// MaxPromptTokensRule names the prompt size limit in Stats
+const MaxPromptTokensRule = "max_prompt_tokens"
+
+// maxBody bounds how much of a text completion body is buffered
+const maxBody = 32 << 20
+
+// outputWindow is how much trailing output is held back so matches split across chunks are
+// still caught
+const outputWindow = 64
+
+// Rule matches a regular expression or any of a list of words (whole words, ignoring case)
+type Rule struct {
+	Name    string   `json:"name"`
+	Pattern string   `json:"pattern,omitempty"`
+	Words   []string `json:"words,omitempty"`
+	// Apply is input, output or both (the default)
+	Apply string `json:"apply,omitempty"`
+	// Action is reject (the default) or redact. Rejected prompts fail with a 400; rejected
+	// output ends at the match.
+	Action string `json:"action,omitempty"`
+	// Replacement replaces redacted matches (default: [REDACTED])
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// Config is the guardrails file
+type Config struct {
+	// MaxPromptTokens rejects prompts estimated above it; 0 is no limit
+	MaxPromptTokens int    `json:"max_prompt_tokens,omitempty"`
+	Rules           []Rule `json:"rules"`
+}
+
+type rule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+func (r *rule) applies(direction string) bool {
+	return r.Apply == ApplyBoth || r.Apply == direction
+}
+
+// Stat counts the requests or responses a rule matched
+type Stat struct {
+	Rule      string `json:"rule"`
+	Direction string `json:"direction"`
+	Action    string `json:"action"`
+	Hits      uint64 `json:"hits"`
+}
+
+type statKey struct{ rule, direction, action string }
+
+// Guard applies guardrails to chat and text completions
+type Guard struct {
+	MaxPromptTokens int
+	Counter         chat.TokenCounter
+	rules           []*rule
+
+	mu   sync.Mutex
+	hits map[statKey]uint64
+}
+
+// New compiles the rules of config
+func New(config Config) (*Guard, error) {
+	g := &Guard{MaxPromptTokens: config.MaxPromptTokens, Counter: chat.EstimateCounter{}, hits: make(map[statKey]uint64)}
+	for i, r := range config.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		r.Apply = strings.ToLower(r.Apply)
+		if r.Apply == "" {
+			r.Apply = ApplyBoth
+		}
+		r.Action = strings.ToLower(r.Action)
+		if r.Action == "" {
+			r.Action = ActionReject
+		}
+		if r.Replacement == "" {
+			r.Replacement = chat.Redaction
+		}
+		if !slices.Contains([]string{ApplyInput, ApplyOutput, ApplyBoth}, r.Apply) {
+			return nil, fmt.Errorf("rule %s: apply must be input, output or both, not %q", r.Name, r.Apply)
+		}
+		if r.Action != ActionReject && r.Action != ActionRedact {
+			return nil, fmt.Errorf("rule %s: action must be reject or redact, not %q", r.Name, r.Action)
+		}
+		pattern := r.Pattern
+		if len(r.Words) > 0 {
+			if pattern != "" {
+				return nil, fmt.Errorf("rule %s: set pattern or words, not both", r.Name)
+			}
+			quoted := make([]string, len(r.Words))
+			for j, word := range r.Words {
+				quoted[j] = regexp.QuoteMeta(word)
+			}
+			pattern = `(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("rule %s: needs a pattern or words", r.Name)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		g.rules = append(g.rules, &rule{Rule: r, re: re})
+	}
+	return g, nil
+}
+
+// Load reads a guardrails file
+func Load(path string) (*Guard, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return New(config)
+}
+
+// Middleware checks prompts before they reach next and filters the output of completions.
+// Output rules are applied through the chat.Transformer, which must wrap the Guard.
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || (r.URL.Path != chat.CompletionsPath && r.URL.Path != chat.TextCompletionsPath) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if status, code, message := g.checkInput(r); status != 0 {
+			openai.WriteError(w, status, openai.ErrorType(status), code, message)
+			return
+		}
+		if g.hasOutputRules() {
+			chat.AddTransform(r, func() chat.Transform { return &outputFilter{guard: g} })
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkInput applies the prompt limit and input rules to r, redacting its body in place.
+// It returns a non-zero status when the request is rejected.
+func (g *Guard) checkInput(r *http.Request) (status int, code, message string) {
+	if r.URL.Path == chat.TextCompletionsPath {
+		return g.checkPrompt(r)
+	}
+	req, err := chat.Read(r)
+	if err != nil {
+		return 0, "", ""
+	}
+	if status, code, message = g.checkSize(req.Model(), req.Messages); status != 0 {
+		return status, code, message
+	}
+	redacted := false
+	for i := range req.Messages {
+		if req.Messages[i].Role == "assistant" {
+			continue
+		}
+		var rejected *rule
+		req.Messages[i].Content = mapText(req.Messages[i].Content, func(text string) string {
+			out, matched := g.filterInput(text)
+			if matched != nil && rejected == nil {
+				rejected = matched
+			}
+			redacted = redacted || out != text
+			return out
+		})
+		if rejected != nil {
+			return http.StatusBadRequest, "content_policy_violation", "prompt matches guardrail " + rejected.Name
+		}
+	}
+	if redacted {
+		if err := req.Write(r); err != nil {
+			return http.StatusInternalServerError, "server_error", err.Error()
+		}
+	}
+	return 0, "", ""
+}
+
+// checkPrompt applies the input rules to the string prompt of a text completion
+func (g *Guard) checkPrompt(r *http.Request) (status int, code, message string) {
+	body, fields, ok := readFields(r)
+	if !ok {
+		return 0, "", ""
+	}
+	var prompt string
+	if json.Unmarshal(fields["prompt"], &prompt) != nil {
+		return 0, "", ""
+	}
+	var model string
+	json.Unmarshal(fields["model"], &model)
+	if status, code, message = g.checkSize(model, []chat.Message{{Role: "user", Content: prompt}}); status != 0 {
+		return status, code, message
+	}
+	out, rejected := g.filterInput(prompt)
+	if rejected != nil {
+		return http.StatusBadRequest, "content_policy_violation", "prompt matches guardrail " + rejected.Name
+	}
+	if out != prompt {
+		fields["prompt"], _ = json.Marshal(out)
+		body, _ = json.Marshal(fields)
+	}
+	setBody(r, body)
+	return 0, "", ""
+}
+
+// checkSize rejects prompts estimated above MaxPromptTokens
+func (g *Guard) checkSize(model string, messages []chat.Message) (status int, code, message string) {
+	if g.MaxPromptTokens <= 0 {
+		return 0, "", ""
+	}
+	if tokens := g.Counter.CountTokens(model, messages); tokens > g.MaxPromptTokens {
+		g.count(MaxPromptTokensRule, ApplyInput, ActionReject)
+		return http.StatusBadRequest, "prompt_too_long",
+			fmt.Sprintf("prompt is about %d tokens, over the limit of %d", tokens, g.MaxPromptTokens)
+	}
+	return 0, "", ""
+}
+
+// filterInput redacts text with the input rules, stopping at the first rule that rejects it
+func (g *Guard) filterInput(text string) (string, *rule) {
+	for _, r := range g.rules {
+		if !r.applies(ApplyInput) || !r.re.MatchString(text) {
+			continue
+		}
+		g.count(r.Name, ApplyInput, r.Action)
+		if r.Action == ActionReject {
+			return text, r
+		}
+		text = r.re.ReplaceAllLiteralString(text, r.Replacement)
+	}
+	return text, nil
+}
+
+func (g *Guard) hasOutputRules() bool {
+	return slices.ContainsFunc(g.rules, func(r *rule) bool { return r.applies(ApplyOutput) })
+}
+
+func (g *Guard) count(name, direction, action string) {
+	g.mu.Lock()
+	g.hits[statKey{name, direction, action}]++
+	g.mu.Unlock()
+}
+
+// Stats returns how often each rule matched, by direction and action
+func (g *Guard) Stats() []Stat {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := make([]Stat, 0, len(g.hits))
+	for key, hits := range g.hits {
+		stats = append(stats, Stat{Rule: key.rule, Direction: key.direction, Action: key.action, Hits: hits})
+	}
+	slices.SortFunc(stats, func(a, b Stat) int {
+		return strings.Compare(a.Rule+"\x00"+a.Direction, b.Rule+"\x00"+b.Direction)
+	})
+	return stats
+}
+
+// outputFilter applies the output rules to one choice as it streams. The last
+// outputWindow bytes are held back so matches split across chunks are caught; after a
+// rejecting rule matches, the rest of the choice is dropped.
+type outputFilter struct {
+	guard   *Guard
+	held    string
+	blocked bool
+}
+
+func (f *outputFilter) Push(delta string) string {
+	if f.blocked {
+		return ""
+	}
+	f.held += delta
+	if len(f.held) <= outputWindow {
+		return ""
+	}
+	cut := len(f.held) - outputWindow
+	for !utf8.RuneStart(f.held[cut]) {
+		cut--
+	}
+	// never emit part of a match that may continue into the held text
+	for moved := true; moved; {
+		moved = false
+		for _, r := range f.guard.rules {
+			if !r.applies(ApplyOutput) {
+				continue
+			}
+			for _, loc := range r.re.FindAllStringIndex(f.held, -1) {
+				if loc[0] < cut && loc[1] >= cut {
+					cut, moved = loc[0], true
+				}
+			}
+		}
+	}
+	text := f.filter(f.held[:cut])
+	f.held = f.held[cut:]
+	return text
+}
+
+func (f *outputFilter) Flush() string {
+	if f.blocked {
+		return ""
+	}
+	text := f.filter(f.held)
+	f.held = ""
+	return text
+}
+
+func (f *outputFilter) filter(text string) string {
+	for _, r := range f.guard.rules {
+		if !r.applies(ApplyOutput) {
+			continue
+		}
+		loc := r.re.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		f.guard.count(r.Name, ApplyOutput, r.Action)
+		if r.Action == ActionReject {
+			f.blocked = true
+			return text[:loc[0]]
+		}
+		text = r.re.ReplaceAllLiteralString(text, r.Replacement)
+	}
+	return text
+}
+
+// mapText applies fn to a message's text: string content, or the text of each text part
+func mapText(content any, fn func(string) string) any {
+	switch c := content.(type) {
+	case string:
+		return fn(c)
+	case []any:
+		for _, part := range c {
+			if p, ok := part.(map[string]any); ok && p["type"] == "text" {
+				if text, ok := p["text"].(string); ok {
+					p["text"] = fn(text)
+				}
+			}
+		}
+	}
+	return content
+}
+
+// readFields decodes a JSON body into its top-level fields, leaving r.Body readable again
+func readFields(r *http.Request) ([]byte, map[string]json.RawMessage, bool) {
+	if r.Body == nil {
+		return nil, nil, false
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	_ = r.Body.Close()
+	setBody(r, body)
+	if err != nil || len(body) > maxBody {
+		return nil, nil, false
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return nil, nil, false
+	}
+	return body, fields, true
+}
+
+func setBody(r *http.Request, body []byte) {
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+}
@@ -0,0 +1,131 @@
+package guardrails
+
+import (
+	"botframework/chat"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testConfig = Config{
+	MaxPromptTokens: 50,
+	Rules: []Rule{
+		{Name: "ssn", Pattern: `\b\d{3}-\d{2}-\d{4}\b`, Action: ActionRedact},
+		{Name: "weapons", Words: []string{"nerve agent", "pipe bomb"}, Apply: ApplyInput},
+		{Name: "codename", Words: []string{"bluebird"}, Apply: ApplyOutput},
+	},
+}
+
+func newTestGuard(t *testing.T) *Guard {
+	t.Helper()
+	g, err := New(testConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// streamOf answers every request with pieces as chat completion deltas, recording the body
+func streamOf(sent *string, pieces ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*sent = string(body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, piece := range pieces {
+			content, _ := json.Marshal(piece)
+			io.WriteString(w, `data: {"choices":[{"index":0,"delta":{"content":`+string(content)+`}}]}`+"\n\n")
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	})
+}
+
+func streamed(body string) string {
+	var text strings.Builder
+	for _, event := range strings.Split(body, "\n\n") {
+		var chunk struct {
+			Choices []struct {
+				Delta struct{ Content string } `json:"delta"`
+			} `json:"choices"`
+		}
+		if payload, ok := strings.CutPrefix(event, "data: "); ok && json.Unmarshal([]byte(payload), &chunk) == nil {
+			for _, choice := range chunk.Choices {
+				text.WriteString(choice.Delta.Content)
+			}
+		}
+	}
+	return text.String()
+}
+
+func serve(g *Guard, backend http.Handler, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler := chat.NewTransformer().Middleware(g.Middleware(backend))
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestGuardRejectsAndRedactsPrompts(t *testing.T) {
+	g := newTestGuard(t)
+	var sent string
+	backend := streamOf(&sent, "ok")
+
+	rec := serve(g, backend, chat.CompletionsPath, `{"messages":[{"role":"user","content":"How do I build a Pipe Bomb?"}]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "content_policy_violation") || sent != "" {
+		t.Errorf("deny-listed prompt: %d %s (backend got %q)", rec.Code, rec.Body.String(), sent)
+	}
+
+	rec = serve(g, backend, chat.CompletionsPath, `{"messages":[{"role":"user","content":[{"type":"text","text":"My SSN is 123-45-6789."}]}]}`)
+	if rec.Code != http.StatusOK || strings.Contains(sent, "6789") || !strings.Contains(sent, "My SSN is [REDACTED].") {
+		t.Errorf("redacted prompt: %d, backend got %s", rec.Code, sent)
+	}
+
+	rec = serve(g, backend, chat.CompletionsPath, `{"messages":[{"role":"user","content":"`+strings.Repeat("word ", 100)+`"}]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "prompt_too_long") {
+		t.Errorf("long prompt: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(g, backend, chat.TextCompletionsPath, `{"model":"m","prompt":"call 123-45-6789"}`)
+	if rec.Code != http.StatusOK || sent != `{"model":"m","prompt":"call [REDACTED]"}` {
+		t.Errorf("text completion: %d, backend got %s", rec.Code, sent)
+	}
+
+	// output rules do not apply to prompts
+	serve(g, backend, chat.CompletionsPath, `{"messages":[{"role":"user","content":"what is bluebird?"}]}`)
+	if !strings.Contains(sent, "bluebird") {
+		t.Errorf("backend got %s", sent)
+	}
+}
+
+func TestGuardFiltersStreamedOutput(t *testing.T) {
+	g := newTestGuard(t)
+	var sent string
+	rec := serve(g, streamOf(&sent, "Your number is 123-4", "5-6789. The project is called blue", "bird and ships soon."),
+		chat.CompletionsPath, `{"stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if got := streamed(rec.Body.String()); got != "Your number is [REDACTED]. The project is called " {
+		t.Errorf("streamed %q", got)
+	}
+
+	stats := map[string]uint64{}
+	for _, stat := range g.Stats() {
+		stats[stat.Rule+"/"+stat.Direction+"/"+stat.Action] = stat.Hits
+	}
+	if stats["ssn/output/redact"] != 1 || stats["codename/output/reject"] != 1 || len(stats) != 2 {
+		t.Errorf("stats = %v", stats)
+	}
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	for _, rule := range []Rule{
+		{Name: "empty"},
+		{Name: "both", Pattern: "a", Words: []string{"b"}},
+		{Name: "regex", Pattern: "("},
+		{Name: "action", Pattern: "a", Action: "block"},
+		{Name: "apply", Pattern: "a", Apply: "prompt"},
+	} {
+		if _, err := New(Config{Rules: []Rule{rule}}); err == nil || !strings.Contains(err.Error(), rule.Name) {
+			t.Errorf("rule %s: err = %v", rule.Name, err)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package listener
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LetsEncrypt is the production directory of Let's Encrypt
+const LetsEncrypt = "https://acme-v02.api.letsencrypt.org/directory"
+
+// ACME obtains a certificate for Hosts from an ACME (RFC 8555) certificate authority such as
+// Let's Encrypt and renews it before it expires. The CA checks control of each host with an
+// HTTP-01 challenge on port 80, which ChallengeHandler answers.
+type ACME struct {
+	// DirectoryURL is the CA's directory; default: LetsEncrypt
+	DirectoryURL string
+	// Email is the account's contact address for expiry notices; optional
+	Email string
+	Hosts []string
+	// Dir keeps the account key and the issued certificate between runs
+	Dir    string
+	Client *http.Client
+
+	cert   atomic.Pointer[tls.Certificate]
+	tokens sync.Map // challenge token → key authorization
+
+	// account and protocol state, owned by the goroutine obtaining a certificate
+	mu        sync.Mutex
+	key       *ecdsa.PrivateKey
+	kid       string
+	nonce     string
+	directory acmeDirectory
+}
+
+type acmeDirectory struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type acmeOrder struct {
+	Status         string       `json:"status"`
+	Authorizations []string     `json:"authorizations"`
+	Finalize       string       `json:"finalize"`
+	Certificate    string       `json:"certificate"`
+	Error          *acmeProblem `json:"error"`
+}
+
+type acmeAuthorization struct {
+	Status     string `json:"status"`
+	Identifier struct {
+		Value string `json:"value"`
+	} `json:"identifier"`
+	Challenges []struct {
+		Type  string       `json:"type"`
+		URL   string       `json:"url"`
+		Token string       `json:"token"`
+		Error *acmeProblem `json:"error"`
+	} `json:"challenges"`
+}
+
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+func (p *acmeProblem) Error() string {
+	return fmt.Sprintf("%s: %s", strings.TrimPrefix(p.Type, "urn:ietf:params:acme:error:"), p.Detail)
+}
+
+// GetCertificate returns the current certificate; set it as tls.Config.GetCertificate
+func (a *ACME) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := a.cert.Load(); cert != nil {
+		return cert, nil
+	}
+	return nil, errors.New("acme: certificate not issued yet")
+}
+
+// ChallengeHandler answers the CA's HTTP-01 challenges and passes other requests to next
+func (a *ACME) ChallengeHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		keyAuth, ok := a.tokens.Load(token)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, keyAuth.(string))
+	})
+}
+
+// Run keeps a valid certificate until ctx is cancelled: it loads the one cached in Dir, and
+// orders a new one when there is none or it is within 30 days of expiring. Failed orders
+// are retried with backoff.
+func (a *ACME) Run(ctx context.Context) {
+	if cert, err := tls.LoadX509KeyPair(a.certFiles()); err == nil && usable(cert, a.Hosts, 0) {
+		a.cert.Store(&cert)
+	}
+	retry := time.Minute
+	for {
+		wait := 12 * time.Hour
+		if cert := a.cert.Load(); cert == nil || !usable(*cert, a.Hosts, renewBefore) {
+			if err := a.Obtain(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				slog.Error("acme certificate order failed", "hosts", a.Hosts, "retry_in", retry, "err", err)
+				wait, retry = retry, min(2*retry, time.Hour)
+			} else {
+				retry = time.Minute
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (a *ACME) certFiles() (certFile, keyFile string) {
+	return filepath.Join(a.Dir, a.Hosts[0]+".crt"), filepath.Join(a.Dir, a.Hosts[0]+".key")
+}
+
+// Obtain orders a certificate for Hosts, stores it in Dir and serves it from then on
+func (a *ACME) Obtain(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.register(ctx); err != nil {
+		return fmt.Errorf("acme account: %w", err)
+	}
+
+	identifiers := make([]map[string]string, len(a.Hosts))
+	for i, host := range a.Hosts {
+		identifiers[i] = map[string]string{"type": "dns", "value": host}
+	}
+	var order acmeOrder
+	resp, err := a.post(ctx, a.directory.NewOrder, map[string]any{"identifiers": identifiers}, &order)
+	if err != nil {
+		return fmt.Errorf("acme order: %w", err)
+	}
+	orderURL := resp.Header.Get("Location")
+	for _, authzURL := range order.Authorizations {
+		if err := a.authorize(ctx, authzURL); err != nil {
+			return err
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: a.Hosts[0]},
+		DNSNames: a.Hosts,
+	}, key)
+	if err != nil {
+		return err
+	}
+	if _, err := a.post(ctx, order.Finalize, map[string]string{"csr": b64(csr)}, &order); err != nil {
+		return fmt.Errorf("acme finalize: %w", err)
+	}
+	if err := a.poll(ctx, orderURL, &order, func() (bool, error) {
+		switch order.Status {
+		case "valid":
+			return true, nil
+		case "invalid":
+			if order.Error != nil {
+				return false, order.Error
+			}
+			return false, errors.New("order is invalid")
+		}
+		return false, nil
+	}); err != nil {
+		return fmt.Errorf("acme finalize: %w", err)
+	}
+
+	var chain bytes.Buffer
+	if _, err := a.post(ctx, order.Certificate, nil, &chain); err != nil {
+		return fmt.Errorf("acme certificate: %w", err)
+	}
+	certFile, keyFile := a.certFiles()
+	if err := writeKeyPair(certFile, keyFile, chain.Bytes(), key); err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("acme certificate: %w", err)
+	}
+	a.cert.Store(&cert)
+	slog.Info("acme certificate issued", "hosts", a.Hosts, "expires", cert.Leaf.NotAfter)
+	return nil
+}
+
+// register loads or creates the account key and looks up or creates its account
+func (a *ACME) register(ctx context.Context) error {
+	if a.kid != "" {
+		return nil
+	}
+	if a.DirectoryURL == "" {
+		a.DirectoryURL = LetsEncrypt
+	}
+	if a.Client == nil {
+		a.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.DirectoryURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&a.directory); err != nil {
+		return fmt.Errorf("directory %s: %w", a.DirectoryURL, err)
+	}
+
+	keyFile := filepath.Join(a.Dir, "account.key")
+	if a.key, err = readKey(keyFile); errors.Is(err, os.ErrNotExist) {
+		if a.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err == nil {
+			err = writeKey(keyFile, a.key)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	account := map[string]any{"termsOfServiceAgreed": true}
+	if a.Email != "" {
+		account["contact"] = []string{"mailto:" + a.Email}
+	}
+	resp, err = a.post(ctx, a.directory.NewAccount, account, nil)
+	if err != nil {
+		return err
+	}
+	a.kid = resp.Header.Get("Location")
+	return nil
+}
+
+// authorize proves control of an authorization's host with its HTTP-01 challenge
+func (a *ACME) authorize(ctx context.Context, authzURL string) error {
+	var authz acmeAuthorization
+	if _, err := a.post(ctx, authzURL, nil, &authz); err != nil {
+		return fmt.Errorf("acme authorization: %w", err)
+	}
+	if authz.Status == "valid" {
+		return nil
+	}
+	host := authz.Identifier.Value
+	for _, challenge := range authz.Challenges {
+		if challenge.Type != "http-01" {
+			continue
+		}
+		a.tokens.Store(challenge.Token, challenge.Token+"."+a.thumbprint())
+		defer a.tokens.Delete(challenge.Token)
+		if _, err := a.post(ctx, challenge.URL, struct{}{}, nil); err != nil {
+			return fmt.Errorf("acme challenge for %s: %w", host, err)
+		}
+		return a.poll(ctx, authzURL, &authz, func() (bool, error) {
+			switch authz.Status {
+			case "valid":
+				return true, nil
+			case "pending":
+				return false, nil
+			}
+			for _, c := range authz.Challenges {
+				if c.Error != nil {
+					return false, fmt.Errorf("acme challenge for %s: %w", host, c.Error)
+				}
+			}
+			return false, fmt.Errorf("acme challenge for %s: authorization is %s", host, authz.Status)
+		})
+	}
+	return fmt.Errorf("acme: the CA offers no http-01 challenge for %s", host)
+}
+
+// poll fetches url into v until done reports true or an error, for up to two minutes
+func (a *ACME) poll(ctx context.Context, url string, v any, done func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	for {
+		if ok, err := done(); ok || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+		if _, err := a.post(ctx, url, nil, v); err != nil {
+			return err
+		}
+	}
+}
+
+// post sends payload to url signed with the account key, or a POST-as-GET when payload is
+// nil, and decodes the response into out: JSON, or the raw body when out is a *bytes.Buffer.
+// A rejected nonce is retried with the fresh one the error carries.
+func (a *ACME) post(ctx context.Context, url string, payload any, out any) (*http.Response, error) {
+	body := []byte{}
+	if payload != nil {
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		signed, err := a.sign(ctx, url, body)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(signed))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/jose+json")
+		resp, err := a.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		a.nonce = resp.Header.Get("Replay-Nonce")
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= 400 {
+			problem := &acmeProblem{}
+			if json.Unmarshal(data, problem) != nil || problem.Type == "" {
+				return nil, fmt.Errorf("%s: %s", url, resp.Status)
+			}
+			if problem.Type == "urn:ietf:params:acme:error:badNonce" && attempt < 3 {
+				continue
+			}
+			return nil, problem
+		}
+		switch out := out.(type) {
+		case nil:
+		case *bytes.Buffer:
+			out.Write(data)
+		default:
+			if err := json.Unmarshal(data, out); err != nil {
+				return nil, fmt.Errorf("%s: %w", url, err)
+			}
+		}
+		return resp, nil
+	}
+}
+
+// sign wraps payload in a flattened JWS signed with ES256. The account is named by its key
+// until it has an account URL.
+func (a *ACME) sign(ctx context.Context, url string, payload []byte) ([]byte, error) {
+	if a.nonce == "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, a.directory.NewNonce, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := a.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		if a.nonce = resp.Header.Get("Replay-Nonce"); a.nonce == "" {
+			return nil, errors.New("acme: no nonce from " + a.directory.NewNonce)
+		}
+	}
+	protected := map[string]any{"alg": "ES256", "nonce": a.nonce, "url": url}
+	if a.kid != "" {
+		protected["kid"] = a.kid
+	} else {
+		protected["jwk"] = a.jwk()
+	}
+	a.nonce = ""
+	header, err := json.Marshal(protected)
+	if err != nil {
+		return nil, err
+	}
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+	return json.Marshal(map[string]string{
+		"protected": b64(header),
+		"payload":   b64(payload),
+		"signature": b64(signature),
+	})
+}
+
+// jwk is the account's public key as a JSON Web Key, its members in the lexicographic
+// order RFC 7638 thumbprints need
+func (a *ACME) jwk() map[string]string {
+	public, _ := a.key.PublicKey.ECDH()
+	point := public.Bytes() // 0x04 || X || Y
+	return map[string]string{"crv": "P-256", "kty": "EC", "x": b64(point[1:33]), "y": b64(point[33:])}
+}
+
+func (a *ACME) thumbprint() string {
+	// encoding/json sorts map keys, which is the canonical order
+	data, _ := json.Marshal(a.jwk())
+	sum := sha256.Sum256(data)
+	return b64(sum[:])
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
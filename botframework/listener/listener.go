@@ -0,0 +1,129 @@
+// Package listener opens the manager's HTTP listeners: TCP addresses, Unix sockets, and
+// SO_REUSEPORT sockets shared by several gateway processes.
+package listener
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Addr is a parsed bind address
+type Addr struct {
+	Network string // "tcp" or "unix"
+	Address string
+}
+
+func (a Addr) String() string {
+	if a.Network == "unix" {
+		return "unix:" + a.Address
+	}
+	return a.Address
+}
+
+// Port returns the TCP port, or "" for Unix sockets
+func (a Addr) Port() string {
+	if a.Network != "tcp" {
+		return ""
+	}
+	_, port, err := net.SplitHostPort(a.Address)
+	if err != nil {
+		return ""
+	}
+	return port
+}
+
+// Parse reads a bind address: "unix:/run/bf.sock", "127.0.0.1:8080", ":8080", "[::1]:8080",
+// or a bare port such as "8080"
+func Parse(s string) (Addr, error) {
+	s = strings.TrimSpace(s)
+	if path, ok := strings.CutPrefix(s, "unix:"); ok {
+		path = strings.TrimPrefix(path, "//")
+		if path == "" {
+			return Addr{}, fmt.Errorf("unix address %q has no socket path", s)
+		}
+		return Addr{Network: "unix", Address: path}, nil
+	}
+	if !strings.Contains(s, ":") {
+		s = ":" + s
+	}
+	if _, _, err := net.SplitHostPort(s); err != nil {
+		return Addr{}, fmt.Errorf("invalid listen address %q: %w", s, err)
+	}
+	return Addr{Network: "tcp", Address: s}, nil
+}
+
+// ParseList reads a comma-separated list of bind addresses
+func ParseList(s string) ([]Addr, error) {
+	var addrs []Addr
+	for _, field := range strings.Split(s, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		addr, err := Parse(field)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// Listen opens addr. Stale Unix sockets left by a crashed process are removed first; with
+// reusePort, TCP sockets set SO_REUSEPORT so the kernel balances connections across every
+// process bound to the same port.
+func Listen(ctx context.Context, addr Addr, reusePort bool) (net.Listener, error) {
+	var config net.ListenConfig
+	switch addr.Network {
+	case "unix":
+		if info, err := os.Stat(addr.Address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(addr.Address); err != nil {
+				return nil, err
+			}
+		}
+	case "tcp":
+		if reusePort {
+			config.Control = reusePortControl
+		}
+	default:
+		return nil, fmt.Errorf("unsupported network %q", addr.Network)
+	}
+	return config.Listen(ctx, addr.Network, addr.Address)
+}
+
+// Only serves the paths under any of prefixes and answers 404 for everything else
+func Only(next http.Handler, prefixes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !hasPrefix(r.URL.Path, prefixes) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Except answers 404 for the paths under any of prefixes and serves everything else
+func Except(next http.Handler, prefixes ...string) http.Handler {
+	if len(prefixes) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasPrefix(r.URL.Path, prefixes) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if path == strings.TrimSuffix(prefix, "/") || strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
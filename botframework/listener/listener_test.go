@@ -0,0 +1,125 @@
+package listener
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in, network, address, port string
+	}{
+		{"8080", "tcp", ":8080", "8080"},
+		{":9090", "tcp", ":9090", "9090"},
+		{"127.0.0.1:8081", "tcp", "127.0.0.1:8081", "8081"},
+		{"[::1]:8082", "tcp", "[::1]:8082", "8082"},
+		{"unix:/run/bf.sock", "unix", "/run/bf.sock", ""},
+		{"unix:///run/bf.sock", "unix", "/run/bf.sock", ""},
+	}
+	for _, c := range cases {
+		addr, err := Parse(c.in)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", c.in, err)
+		}
+		if addr.Network != c.network || addr.Address != c.address || addr.Port() != c.port {
+			t.Errorf("Parse(%q) = %+v port %q", c.in, addr, addr.Port())
+		}
+	}
+	for _, bad := range []string{"unix:", "1.2.3.4:5:6"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+
+	addrs, err := ParseList(" :8080, unix:/tmp/a.sock ,")
+	if err != nil || len(addrs) != 2 {
+		t.Fatalf("ParseList = %v, %v", addrs, err)
+	}
+}
+
+func TestListenUnixReplacesStaleSocket(t *testing.T) {
+	addr := Addr{Network: "unix", Address: filepath.Join(t.TempDir(), "bf.sock")}
+	stale, err := net.Listen("unix", addr.Address)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := Listen(context.Background(), addr, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})}
+	go server.Serve(ln)
+	defer server.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", addr.Address)
+		},
+	}}
+	resp, err := client.Get("http://unix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("got %q", body)
+	}
+}
+
+func TestListenReusePort(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("SO_REUSEPORT load balancing is only exercised on linux")
+	}
+	first, err := Listen(context.Background(), Addr{Network: "tcp", Address: "127.0.0.1:0"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	second, err := Listen(context.Background(), Addr{Network: "tcp", Address: first.Addr().String()}, true)
+	if err != nil {
+		t.Fatalf("second listener on the same port: %v", err)
+	}
+	second.Close()
+
+	if ln, err := Listen(context.Background(), Addr{Network: "tcp", Address: first.Addr().String()}, false); err == nil {
+		ln.Close()
+		t.Error("binding without SO_REUSEPORT should fail")
+	}
+}
+
+func TestOnlyAndExcept(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	cases := []struct {
+		handler http.Handler
+		path    string
+		want    int
+	}{
+		{Only(ok, "/admin/"), "/admin/status", http.StatusOK},
+		{Only(ok, "/admin/"), "/admin", http.StatusOK},
+		{Only(ok, "/admin/"), "/v1/models", http.StatusNotFound},
+		{Except(ok, "/admin/", "/metrics"), "/admin/status", http.StatusNotFound},
+		{Except(ok, "/admin/", "/metrics"), "/metrics", http.StatusNotFound},
+		{Except(ok, "/admin/", "/metrics"), "/v1/chat/completions", http.StatusOK},
+		{Except(ok), "/admin/status", http.StatusOK},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		c.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if rec.Code != c.want {
+			t.Errorf("%s: got %d, want %d", c.path, rec.Code, c.want)
+		}
+	}
+}
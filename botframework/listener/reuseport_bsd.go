@@ -0,0 +1,7 @@
+//go:build darwin || freebsd
+
+package listener
+
+import "syscall"
+
+const soReusePort = syscall.SO_REUSEPORT
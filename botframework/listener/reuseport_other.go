@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd)
+
+package listener
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on %s", runtime.GOOS)
+}
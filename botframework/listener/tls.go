@@ -0,0 +1,264 @@
+package listener
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// NewTLSListener serves TLS on inner. Plain HTTP is still served to loopback clients, so
+// the manager's calls to its own routes and local health checks need no certificate; other
+// plain HTTP clients are told to use HTTPS.
+func NewTLSListener(inner net.Listener, config *tls.Config) net.Listener {
+	l := &tlsListener{
+		Listener: inner,
+		config:   config,
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+	}
+	go l.accept()
+	return l
+}
+
+// sniffTimeout bounds the wait for a new connection's first byte
+const sniffTimeout = 10 * time.Second
+
+// tlsListener sorts accepted connections by their first byte, a TLS handshake record or
+// the start of a plain HTTP request. Sorting happens off the Accept path so a client that
+// sends nothing does not hold up the others.
+type tlsListener struct {
+	net.Listener
+	config *tls.Config
+	conns  chan net.Conn
+	errs   chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (l *tlsListener) accept() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		go l.sniff(conn)
+	}
+}
+
+func (l *tlsListener) sniff(conn net.Conn) {
+	first := make([]byte, 1)
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	if _, err := io.ReadFull(conn, first); err != nil {
+		conn.Close()
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	var sorted net.Conn = &peekedConn{Conn: conn, first: first}
+	switch {
+	case first[0] == 0x16: // TLS handshake record
+		sorted = tls.Server(sorted, l.config)
+	case !isLoopback(conn.RemoteAddr()):
+		io.WriteString(conn, "HTTP/1.0 400 Bad Request\r\nContent-Type: text/plain; charset=utf-8\r\nConnection: close\r\n\r\nClient sent an HTTP request to an HTTPS server.\n")
+		conn.Close()
+		return
+	}
+	select {
+	case l.conns <- sorted:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+func (l *tlsListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *tlsListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// peekedConn replays the byte the listener read to sort the connection
+type peekedConn struct {
+	net.Conn
+	first []byte
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	if len(c.first) > 0 && len(p) > 0 {
+		n := copy(p, c.first)
+		c.first = c.first[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
+
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
+
+// Redirect answers plain HTTP requests with a permanent redirect to the same URL over HTTPS
+// on port. ACME HTTP-01 challenges are answered first when acme is set.
+func Redirect(port string, acme *ACME) http.Handler {
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			// 308 keeps the method and body
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+	if acme != nil {
+		handler = acme.ChallengeHandler(handler)
+	}
+	return handler
+}
+
+// selfSignedValidity is how long a generated certificate lasts; it is replaced once less
+// than renewBefore of it remains
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// SelfSigned returns the self-signed certificate kept in dir, generating one that covers
+// hosts (DNS names or IP addresses) when there is none, it expires soon or it does not
+// cover every host. created reports whether a new certificate was written.
+func SelfSigned(dir string, hosts []string) (cert tls.Certificate, created bool, err error) {
+	certFile, keyFile := filepath.Join(dir, "self-signed.crt"), filepath.Join(dir, "self-signed.key")
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && usable(cert, hosts, renewBefore) {
+		return cert, false, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, false, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, false, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"BotFramework self-signed"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		// a CA certificate, so clients can trust it with --cacert and the like
+		IsCA: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, false, err
+	}
+	if err := writeKeyPair(certFile, keyFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key); err != nil {
+		return tls.Certificate{}, false, err
+	}
+	cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	return cert, err == nil, err
+}
+
+// renewBefore is how long before expiry a certificate is replaced
+const renewBefore = 30 * 24 * time.Hour
+
+// usable reports whether cert covers every host and stays valid for longer than margin
+func usable(cert tls.Certificate, hosts []string, margin time.Duration) bool {
+	if cert.Leaf == nil || time.Until(cert.Leaf.NotAfter) < margin {
+		return false
+	}
+	for _, host := range hosts {
+		if cert.Leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// writeKeyPair stores a PEM certificate chain and its private key, the key readable only
+// by its owner
+func writeKeyPair(certFile, keyFile string, certPEM []byte, key *ecdsa.PrivateKey) error {
+	if err := os.MkdirAll(filepath.Dir(certFile), 0o700); err != nil {
+		return err
+	}
+	if err := writeKey(keyFile, key); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, certPEM, 0o644)
+}
+
+func writeKey(path string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+}
+
+func readKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM key", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ECDSA key", path)
+	}
+	return key, nil
+}
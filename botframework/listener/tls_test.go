@@ -0,0 +1,316 @@
+package listener
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedIsKeptBetweenRuns(t *testing.T) {
+	dir := t.TempDir()
+	cert, created, err := SelfSigned(dir, []string{"localhost", "127.0.0.1"})
+	if err != nil || !created {
+		t.Fatalf("SelfSigned() = %v, created %v", err, created)
+	}
+	if cert.Leaf.VerifyHostname("localhost") != nil || cert.Leaf.VerifyHostname("127.0.0.1") != nil {
+		t.Errorf("certificate does not cover its hosts: %v %v", cert.Leaf.DNSNames, cert.Leaf.IPAddresses)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "self-signed.key")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file: %v %v", info.Mode(), err)
+	}
+
+	again, created, err := SelfSigned(dir, []string{"localhost"})
+	if err != nil || created || !again.Leaf.Equal(cert.Leaf) {
+		t.Errorf("the stored certificate should be reused: created %v, err %v", created, err)
+	}
+	if _, created, _ := SelfSigned(dir, []string{"bot.example.com"}); !created {
+		t.Error("a host the certificate does not cover should replace it")
+	}
+}
+
+func TestTLSListenerServesTLSAndLoopbackHTTP(t *testing.T) {
+	cert, _, err := SelfSigned(t.TempDir(), []string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.TLS != nil)
+	})}
+	go server.Serve(NewTLSListener(inner, &tls.Config{Certificates: []tls.Certificate{cert}}))
+	defer server.Close()
+
+	// a client that never writes must not hold up the others
+	idle, err := net.Dial("tcp", inner.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+	secure := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	for client, url := range map[*http.Client]string{
+		secure:             "https://" + inner.Addr().String(),
+		http.DefaultClient: "http://" + inner.Addr().String(),
+	} {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("%s: %v", url, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want := fmt.Sprint(strings.HasPrefix(url, "https")); string(body) != want {
+			t.Errorf("%s: TLS %s, want %s", url, body, want)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	cases := []struct {
+		method, target, port, location string
+		status                         int
+	}{
+		{http.MethodGet, "http://bot.example.com/v1/models?x=1", "443", "https://bot.example.com/v1/models?x=1", http.StatusMovedPermanently},
+		{http.MethodGet, "http://bot.example.com:80/", "8443", "https://bot.example.com:8443/", http.StatusMovedPermanently},
+		{http.MethodPost, "http://bot.example.com/v1/chat/completions", "443", "https://bot.example.com/v1/chat/completions", http.StatusPermanentRedirect},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		Redirect(c.port, nil).ServeHTTP(rec, httptest.NewRequest(c.method, c.target, nil))
+		if rec.Code != c.status || rec.Header().Get("Location") != c.location {
+			t.Errorf("%s %s: %d %q, want %d %q", c.method, c.target, rec.Code, rec.Header().Get("Location"), c.status, c.location)
+		}
+	}
+}
+
+func TestACMEObtainsAndCachesCertificate(t *testing.T) {
+	acme := &ACME{Email: "ops@example.com", Hosts: []string{"bot.example.com"}, Dir: t.TempDir()}
+	ca := newFakeCA(t, acme)
+	acme.DirectoryURL, acme.Client = ca.URL+"/directory", ca.Client()
+
+	if _, err := acme.GetCertificate(nil); err == nil {
+		t.Error("there is no certificate before the order")
+	}
+	if err := acme.Obtain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := acme.GetCertificate(nil)
+	if err != nil || cert.Leaf.VerifyHostname("bot.example.com") != nil {
+		t.Fatalf("GetCertificate() = %v", err)
+	}
+	if !ca.validated {
+		t.Error("the challenge was not answered")
+	}
+	rec := httptest.NewRecorder()
+	Redirect("443", acme).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://bot.example.com/.well-known/acme-challenge/http-token", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("finished challenges are forgotten, got %d", rec.Code)
+	}
+
+	// a restart serves the stored certificate without a new order
+	ca.Close()
+	restarted := &ACME{Hosts: acme.Hosts, Dir: acme.Dir, DirectoryURL: acme.DirectoryURL}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go restarted.Run(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if cached, err := restarted.GetCertificate(nil); err == nil {
+			if !cached.Leaf.Equal(cert.Leaf) {
+				t.Error("a different certificate was loaded")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the stored certificate was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// fakeCA is an ACME server that checks signatures and nonces, validates HTTP-01 challenges
+// through the client's ChallengeHandler and issues certificates from its own key
+type fakeCA struct {
+	*httptest.Server
+	t         *testing.T
+	acme      *ACME
+	mu        sync.Mutex
+	nonces    map[string]bool
+	next      int
+	account   *ecdsa.PublicKey
+	badNonce  bool
+	validated bool
+	order     map[string]any
+	key       *ecdsa.PrivateKey
+	chain     []byte
+}
+
+func newFakeCA(t *testing.T, acme *ACME) *fakeCA {
+	ca := &fakeCA{t: t, acme: acme, nonces: make(map[string]bool)}
+	ca.key, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca.Server = httptest.NewServer(http.HandlerFunc(ca.serve))
+	t.Cleanup(ca.Close)
+	return ca
+}
+
+func (ca *fakeCA) serve(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	ca.next++
+	nonce := fmt.Sprint("nonce-", ca.next)
+	ca.nonces[nonce] = true
+	w.Header().Set("Replay-Nonce", nonce)
+
+	switch r.URL.Path {
+	case "/directory":
+		json.NewEncoder(w).Encode(map[string]string{
+			"newNonce": ca.URL + "/nonce", "newAccount": ca.URL + "/account", "newOrder": ca.URL + "/order",
+		})
+		return
+	case "/nonce":
+		return
+	}
+
+	payload, err := ca.verify(r)
+	if err != nil {
+		ca.t.Errorf("%s: %v", r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.URL.Path == "/account" && !ca.badNonce {
+		ca.badNonce = true
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(acmeProblem{Type: "urn:ietf:params:acme:error:badNonce", Detail: "stale"})
+		return
+	}
+
+	switch r.URL.Path {
+	case "/account":
+		w.Header().Set("Location", ca.URL+"/account/1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("{}"))
+	case "/order":
+		ca.order = map[string]any{"status": "pending", "authorizations": []string{ca.URL + "/authz"}, "finalize": ca.URL + "/finalize"}
+		w.Header().Set("Location", ca.URL+"/order/1")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ca.order)
+	case "/authz":
+		status := "pending"
+		if ca.validated {
+			status = "valid"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": "bot.example.com"},
+			"challenges": []map[string]string{
+				{"type": "dns-01", "url": ca.URL + "/dns", "token": "dns-token"},
+				{"type": "http-01", "url": ca.URL + "/challenge", "token": "http-token"},
+			},
+		})
+	case "/challenge":
+		rec := httptest.NewRecorder()
+		ca.acme.ChallengeHandler(http.NotFoundHandler()).ServeHTTP(rec,
+			httptest.NewRequest(http.MethodGet, "http://bot.example.com/.well-known/acme-challenge/http-token", nil))
+		public, _ := ca.account.ECDH()
+		point := public.Bytes()
+		jwk, _ := json.Marshal(map[string]string{"crv": "P-256", "kty": "EC", "x": b64(point[1:33]), "y": b64(point[33:])})
+		thumbprint := sha256.Sum256(jwk)
+		ca.validated = rec.Body.String() == "http-token."+b64(thumbprint[:])
+		w.Write([]byte("{}"))
+	case "/finalize":
+		var body struct{ CSR string }
+		json.Unmarshal(payload, &body)
+		der, _ := base64.RawURLEncoding.DecodeString(body.CSR)
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil || !ca.validated {
+			http.Error(w, fmt.Sprint("bad finalize: ", err), http.StatusForbidden)
+			return
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: csr.DNSNames[0]},
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		}
+		issuer := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "fake CA"}}
+		leaf, err := x509.CreateCertificate(rand.Reader, template, issuer, csr.PublicKey, ca.key)
+		if err != nil {
+			ca.t.Fatal(err)
+		}
+		ca.chain = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf})
+		ca.order["status"], ca.order["certificate"] = "valid", ca.URL+"/certificate"
+		json.NewEncoder(w).Encode(ca.order)
+	case "/order/1":
+		json.NewEncoder(w).Encode(ca.order)
+	case "/certificate":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(ca.chain)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// verify checks a request's JWS and returns its payload
+func (ca *fakeCA) verify(r *http.Request) ([]byte, error) {
+	var jws struct{ Protected, Payload, Signature string }
+	if err := json.NewDecoder(r.Body).Decode(&jws); err != nil {
+		return nil, err
+	}
+	header, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	var protected struct {
+		Alg, Nonce, URL, Kid string
+		JWK                  map[string]string
+	}
+	if err := json.Unmarshal(header, &protected); err != nil {
+		return nil, err
+	}
+	if !ca.nonces[protected.Nonce] {
+		return nil, fmt.Errorf("nonce %q was not issued or was used", protected.Nonce)
+	}
+	delete(ca.nonces, protected.Nonce)
+	if protected.URL != ca.URL+r.URL.Path || protected.Alg != "ES256" {
+		return nil, fmt.Errorf("protected header %+v", protected)
+	}
+	if protected.JWK != nil {
+		x, _ := base64.RawURLEncoding.DecodeString(protected.JWK["x"])
+		y, _ := base64.RawURLEncoding.DecodeString(protected.JWK["y"])
+		key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, err
+		}
+		ca.account = key
+	} else if protected.Kid != ca.URL+"/account/1" {
+		return nil, fmt.Errorf("unknown account %q", protected.Kid)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	if len(signature) != 64 || !ecdsa.Verify(ca.account, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+		return nil, fmt.Errorf("bad signature")
+	}
+	return base64.RawURLEncoding.DecodeString(jws.Payload)
+}
@@ -0,0 +1,272 @@
+// Package logging configures the manager's structured logs (log/slog), tags them with the
+// ID of the request being served and folds worker output into the same stream.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestIDHeader carries the request ID from clients, to workers and back in responses
+const RequestIDHeader = "X-Request-ID"
+
+// ParseLevel accepts debug, info, warn and error
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(name))
+	return level, err
+}
+
+// Setup installs the default logger writing to w in format text or json (default: text) at
+// level (default: info). Output of the standard log package goes through it too, at INFO.
+func Setup(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		var err error
+		if lvl, err = ParseLevel(level); err != nil {
+			return nil, fmt.Errorf("log level %q: %w", level, err)
+		}
+	}
+	options := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch format {
+	case "", "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, fmt.Errorf("log format %q is not text or json", format)
+	}
+	logger := slog.New(contextHandler{handler})
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+	return logger, nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request ID id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random 16-byte hex ID
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// contextHandler adds the request_id of the record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Middleware assigns every request an ID, keeping a valid one sent by the client. The ID is
+// set on the request headers, so proxies forward it to workers, echoed in the response and
+// added to logs written with the request's context. Each request is logged at debug level
+// once it completes.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validID(id) {
+			id = NewRequestID()
+		}
+		r.Header.Set(RequestIDHeader, id)
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(WithRequestID(r.Context(), id))
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		slog.DebugContext(r.Context(), "request", "method", r.Method, "path", r.URL.Path,
+			"status", sw.status, "duration_ms", time.Since(start).Milliseconds())
+	})
+}
+
+// validID accepts client IDs that are safe to log and forward
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status, s.wroteHeader = code, true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// maxLine bounds a buffered worker output line; longer lines are logged in pieces
+const maxLine = 64 << 10
+
+// lineWriter logs each line written to it
+type lineWriter struct {
+	logger *slog.Logger
+	mu     sync.Mutex
+	buf    []byte
+}
+
+// Writer returns a writer for a worker's stdout or stderr (stream) that logs each line,
+// tagged with the worker's name. Python writes progress and errors alike to stderr, so the
+// level comes from the line's own prefix (ERROR, WARNING, DEBUG, ...), defaulting to INFO.
+func Writer(worker, stream string) io.Writer {
+	return &lineWriter{logger: slog.Default().With("worker", worker, "stream", stream)}
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	for {
+		end := bytes.IndexByte(l.buf, '\n')
+		if end < 0 {
+			if len(l.buf) >= maxLine {
+				l.log(l.buf)
+				l.buf = l.buf[:0]
+			}
+			return len(p), nil
+		}
+		l.log(l.buf[:end])
+		l.buf = l.buf[end+1:]
+	}
+}
+
+func (l *lineWriter) log(line []byte) {
+	text := strings.TrimRight(string(line), "\r")
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	l.logger.Log(context.Background(), lineLevel(text), text)
+}
+
+var linePrefixes = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"CRITICAL", slog.LevelError},
+	{"ERROR", slog.LevelError},
+	{"Traceback", slog.LevelError},
+	{"WARNING", slog.LevelWarn},
+	{"WARN", slog.LevelWarn},
+	{"DEBUG", slog.LevelDebug},
+}
+
+// lineLevel reads the level of a Python logging or uvicorn line, e.g. "ERROR:    ..."
+func lineLevel(text string) slog.Level {
+	for _, p := range linePrefixes {
+		if strings.HasPrefix(text, p.prefix) {
+			return p.level
+		}
+	}
+	return slog.LevelInfo
+}
+
+// Tail keeps the last lines written to it, for showing recent worker output
+type Tail struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int
+	full    bool
+	partial []byte
+}
+
+// NewTail keeps up to n lines
+func NewTail(n int) *Tail {
+	return &Tail{lines: make([]string, max(n, 1))}
+}
+
+func (t *Tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partial = append(t.partial, p...)
+	for {
+		end := bytes.IndexByte(t.partial, '\n')
+		if end < 0 {
+			if len(t.partial) >= maxLine {
+				t.add(string(t.partial))
+				t.partial = t.partial[:0]
+			}
+			return len(p), nil
+		}
+		t.add(strings.TrimRight(string(t.partial[:end]), "\r"))
+		t.partial = t.partial[end+1:]
+	}
+}
+
+func (t *Tail) add(line string) {
+	t.lines[t.next] = line
+	t.next = (t.next + 1) % len(t.lines)
+	t.full = t.full || t.next == 0
+}
+
+// Lines returns up to the last n lines (all kept lines when n <= 0), oldest first
+func (t *Tail) Lines(n int) []string {
+	if t == nil {
+		return []string{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	kept := append([]string{}, t.lines[:t.next]...)
+	if t.full {
+		kept = append(append([]string{}, t.lines[t.next:]...), kept...)
+	}
+	if n > 0 && len(kept) > n {
+		kept = kept[len(kept)-n:]
+	}
+	return kept
+}
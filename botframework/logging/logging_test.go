@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func setup(t *testing.T, level string) *bytes.Buffer {
+	t.Helper()
+	previous := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	var buf bytes.Buffer
+	if _, err := Setup(&buf, level, "json"); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestMiddlewareAssignsAndForwardsRequestIDs(t *testing.T) {
+	buf := setup(t, "debug")
+	var forwarded string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(RequestIDHeader)
+		slog.InfoContext(r.Context(), "handling")
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	id := rec.Header().Get(RequestIDHeader)
+	if len(id) != 32 || forwarded != id {
+		t.Fatalf("response ID %q, forwarded %q", id, forwarded)
+	}
+	logged := records(t, buf)
+	if len(logged) != 2 || logged[0]["request_id"] != id || logged[1]["status"] != 418.0 || logged[1]["path"] != "/v1/models" {
+		t.Errorf("records = %v", logged)
+	}
+
+	// a client's ID is kept, an unprintable one replaced
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "trace-42")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if forwarded != "trace-42" {
+		t.Errorf("forwarded %q, want the client's ID", forwarded)
+	}
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if forwarded == "bad id\n" {
+		t.Error("an invalid client ID should be replaced")
+	}
+}
+
+func TestWriterLogsWorkerLines(t *testing.T) {
+	buf := setup(t, "info")
+	w := Writer("worker:8081", "stderr")
+	w.Write([]byte("INFO:     Started server\nERROR:    Model lo"))
+	w.Write([]byte("ad failed\n\n"))
+
+	logged := records(t, buf)
+	if len(logged) != 2 {
+		t.Fatalf("records = %v", logged)
+	}
+	if logged[0]["level"] != "INFO" {
+		t.Errorf("record = %v", logged[0])
+	}
+	if logged[1]["msg"] != "ERROR:    Model load failed" || logged[1]["worker"] != "worker:8081" || logged[1]["stream"] != "stderr" || logged[1]["level"] != "ERROR" {
+		t.Errorf("record = %v", logged[1])
+	}
+}
+
+func TestSetupRejectsUnknownSettings(t *testing.T) {
+	if _, err := Setup(&bytes.Buffer{}, "loud", "text"); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+	if _, err := Setup(&bytes.Buffer{}, "info", "xml"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+func TestTailKeepsLastLines(t *testing.T) {
+	tail := NewTail(3)
+	tail.Write([]byte("one\ntwo\nthree\nfo"))
+	tail.Write([]byte("ur\nfive\n"))
+	if got := strings.Join(tail.Lines(0), ","); got != "three,four,five" {
+		t.Errorf("lines = %s", got)
+	}
+	if got := strings.Join(tail.Lines(2), ","); got != "four,five" {
+		t.Errorf("last 2 = %s", got)
+	}
+	if lines := (*Tail)(nil).Lines(5); len(lines) != 0 {
+		t.Errorf("nil tail = %v", lines)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"botframework/accounting"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// newLedger records the model, API key, tokens and duration of every inference request
+// in BOTFRAMEWORK_USAGE_DIR (default: the user config directory), for /admin/usage
+// reports. BOTFRAMEWORK_USAGE_ACCOUNTING=off turns it off and returns nil.
+//
+//	BOTFRAMEWORK_USAGE_STORE  sqlite (usage.db, through the sqlite3 shell) or jsonl (a
+//	                          file per UTC day); default: sqlite when sqlite3 is installed
+func newLedger() (*accounting.Ledger, io.Closer, error) {
+	if os.Getenv("BOTFRAMEWORK_USAGE_ACCOUNTING") == "off" {
+		return nil, nil, nil
+	}
+	dir := os.Getenv("BOTFRAMEWORK_USAGE_DIR")
+	if dir == "" {
+		config, err := os.UserConfigDir()
+		if err != nil {
+			config = os.TempDir()
+		}
+		dir = filepath.Join(config, "botframework", "usage")
+	}
+	kind := os.Getenv("BOTFRAMEWORK_USAGE_STORE")
+	if kind == "" {
+		kind = "sqlite"
+		if _, err := exec.LookPath("sqlite3"); err != nil {
+			slog.Warn("sqlite3 not found; recording usage in JSON Lines files", "dir", dir)
+			kind = "jsonl"
+		}
+	}
+	switch kind {
+	case "sqlite":
+		store, err := accounting.NewSQLiteStore(filepath.Join(dir, "usage.db"))
+		if err != nil {
+			return nil, nil, err
+		}
+		slog.Debug("recording usage", "db", store.Path)
+		return accounting.NewLedger(store), store, nil
+	case "jsonl":
+		store, err := accounting.NewFileStore(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		slog.Debug("recording usage", "dir", dir)
+		return accounting.NewLedger(store), store, nil
+	}
+	return nil, nil, fmt.Errorf("BOTFRAMEWORK_USAGE_STORE: unknown store %q (want sqlite or jsonl)", kind)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"botframework/anthropic"
+	"botframework/chat"
+	"net/http"
+	"os"
+)
+
+// newAnthropicServer serves the Anthropic Messages API (/v1/messages and
+// /v1/messages/count_tokens) on top of backend, the OpenAI-compatible inference handler,
+// unless BOTFRAMEWORK_ANTHROPIC=off. Counter answers count_tokens.
+func newAnthropicServer(backend http.Handler, counter chat.TokenCounter) *anthropic.Server {
+	if os.Getenv("BOTFRAMEWORK_ANTHROPIC") == "off" {
+		return nil
+	}
+	return anthropic.NewServer(backend, counter)
+}
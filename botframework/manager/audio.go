@@ -0,0 +1,47 @@
+package main
+
+import (
+	"botframework/audio"
+	"botframework/engine"
+	"botframework/files"
+	"botframework/supervisor"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// newTranscriber serves the OpenAI audio routes. Uploads go to BOTFRAMEWORK_STT_URL (an
+// OpenAI-compatible speech-to-text server) or, when unset, to the worker serving the requested
+// model. BOTFRAMEWORK_AUDIO_MAX_MB and BOTFRAMEWORK_AUDIO_MAX_SECONDS override the upload limits.
+// Requests may reference an upload from /v1/files with file_id instead of sending the audio.
+func newTranscriber(manager *engine.ModelManager, store *files.Store) *audio.Transcriber {
+	var backend http.Handler = http.HandlerFunc(manager.ProxyRequest)
+	if raw := os.Getenv("BOTFRAMEWORK_STT_URL"); raw != "" {
+		target, err := url.Parse(raw)
+		if err != nil {
+			slog.Warn("invalid BOTFRAMEWORK_STT_URL, using workers", "url", raw, "err", err)
+		} else {
+			proxy := supervisor.NewStreamingProxy(target)
+			backend = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				supervisor.ServeStreaming(proxy, w, r)
+			})
+			slog.Info("forwarding audio requests", "target", target)
+		}
+	}
+
+	transcriber := audio.NewTranscriber(backend)
+	transcriber.Files = func(r *http.Request, id string) (string, []byte, error) {
+		data, file, err := store.ReadAll(files.Owner(r), id)
+		return file.Filename, data, err
+	}
+	if mb, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_AUDIO_MAX_MB")); err == nil && mb > 0 {
+		transcriber.MaxBytes = int64(mb) << 20
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_AUDIO_MAX_SECONDS")); err == nil && seconds > 0 {
+		transcriber.MaxDuration = time.Duration(seconds) * time.Second
+	}
+	return transcriber
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"botframework/auth"
+	"botframework/websocket"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// newAuthenticator requires an API key on every route but health, metrics and, when
+// adminToken protects it, /admin/ when BOTFRAMEWORK_API_KEYS names a key file, and returns
+// nil otherwise. Usage is kept in BOTFRAMEWORK_API_KEY_USAGE, by default next to the key
+// file.
+func newAuthenticator(adminToken string) (*auth.Authenticator, error) {
+	path := os.Getenv("BOTFRAMEWORK_API_KEYS")
+	if path == "" {
+		return nil, nil
+	}
+	store, err := auth.LoadFileStore(path)
+	if err != nil {
+		return nil, err
+	}
+	usagePath := os.Getenv("BOTFRAMEWORK_API_KEY_USAGE")
+	if usagePath == "" {
+		usagePath = strings.TrimSuffix(path, filepath.Ext(path)) + ".usage.json"
+	}
+	authenticator, err := auth.NewAuthenticator(store, usagePath)
+	if err != nil {
+		return nil, err
+	}
+	// /ws/chat checks the key of each message rather than the handshake
+	authenticator.Public = []string{"/metrics", "/v1/health", websocket.ChatPath}
+	if adminToken != "" {
+		authenticator.Public = append(authenticator.Public, "/admin/")
+	}
+	slog.Info("api keys required", "path", path, "keys", len(store.Keys()))
+	return authenticator, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuthenticatorLeavesAdminToItsToken(t *testing.T) {
+	keys := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(keys, []byte(`{"keys": [{"id": "web", "token": "secret"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BOTFRAMEWORK_API_KEYS", keys)
+	t.Setenv("BOTFRAMEWORK_API_KEY_USAGE", filepath.Join(t.TempDir(), "usage.json"))
+
+	for _, tc := range []struct {
+		adminToken string
+		want       int
+	}{
+		{"", http.StatusUnauthorized},
+		{"s3cret", http.StatusOK},
+	} {
+		authenticator, err := newAuthenticator(tc.adminToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/models/load", nil))
+		if rec.Code != tc.want {
+			t.Errorf("admin token %q: /admin/ without an API key: status %d, want %d", tc.adminToken, rec.Code, tc.want)
+		}
+	}
+}
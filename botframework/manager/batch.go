@@ -0,0 +1,49 @@
+package main
+
+import (
+	"botframework/batch"
+	"botframework/engine"
+	"botframework/files"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// newBatchRunner runs /v1/batches jobs, keeping them in BOTFRAMEWORK_BATCH_DIR (default: the
+// user cache directory). The caller sets the runner's Handler to the inference chain before
+// running it. A batch request is sent only while no interactive request is waiting in an
+// engine's queue.
+//
+//	BOTFRAMEWORK_BATCH_CONCURRENCY  requests of a batch in flight at once (default: 1)
+//	BOTFRAMEWORK_BATCH_RETRIES      retries of requests answered with 429 or a 5xx (default: 3)
+func newBatchRunner(manager *engine.ModelManager, store *files.Store) (*batch.Runner, error) {
+	dir := os.Getenv("BOTFRAMEWORK_BATCH_DIR")
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			cache = os.TempDir()
+		}
+		dir = filepath.Join(cache, "botframework", "batches")
+	}
+	runner, err := batch.NewRunner(dir, store, nil)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_BATCH_CONCURRENCY")); err == nil && n > 0 {
+		runner.Concurrency = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_BATCH_RETRIES")); err == nil && n >= 0 {
+		runner.Retries = n
+	}
+	runner.Idle = func() bool {
+		for _, queue := range manager.QueueStats() {
+			if queue.QueuedByPriority[engine.PriorityInteractive] > 0 {
+				return false
+			}
+		}
+		return true
+	}
+	slog.Info("running batches", "dir", dir, "concurrency", runner.Concurrency)
+	return runner, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"botframework/bootstrap"
+	"botframework/profiler"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// newBootstrapper reads BOTFRAMEWORK_VENV_CACHE (default: ~/.cache/botframework/venvs) and
+// builds llama-cpp-python with the CMAKE_ARGS matching the host
+func newBootstrapper(profile *profiler.HardwareProfile, engine profiler.Engine) *bootstrap.Bootstrapper {
+	cacheDir := os.Getenv("BOTFRAMEWORK_VENV_CACHE")
+	if cacheDir == "" {
+		cacheDir = bootstrap.DefaultCacheDir()
+	}
+	b := bootstrap.New(cacheDir, bootstrap.DefaultRequirementsDir())
+	if engine == profiler.EngineLlamaCPP || engine == profiler.EngineLlamaCPPSYCL {
+		b.Env = []string{"CMAKE_ARGS=" + bootstrap.CMakeArgs(profile, engine)}
+	}
+	return b
+}
+
+// configurePython points BOTFRAMEWORK_PYTHON at the engine's venv unless an interpreter is
+// configured. BOTFRAMEWORK_BOOTSTRAP=auto (the default) uses a venv `manager bootstrap`
+// already built, on builds it at startup, off leaves the choice to the worker supervisor.
+func configurePython(ctx context.Context, profile *profiler.HardwareProfile, engine profiler.Engine) {
+	if os.Getenv("BOTFRAMEWORK_PYTHON") != "" {
+		return
+	}
+	b := newBootstrapper(profile, engine)
+	switch mode := os.Getenv("BOTFRAMEWORK_BOOTSTRAP"); mode {
+	case "off":
+		return
+	case "on":
+		python, err := b.Ensure(ctx, engine)
+		if err != nil {
+			slog.Error("worker venv bootstrap failed", "err", err)
+			return
+		}
+		os.Setenv("BOTFRAMEWORK_PYTHON", python)
+	case "", "auto":
+		python, ok := b.Ready(engine)
+		if !ok {
+			slog.Info("run `go run ./manager bootstrap` to give the worker its own venv", "engine", engine)
+			return
+		}
+		slog.Info("using worker venv", "engine", engine, "dir", b.Dir(engine))
+		os.Setenv("BOTFRAMEWORK_PYTHON", python)
+	default:
+		slog.Warn("unknown bootstrap mode, leaving the worker's Python unchanged", "mode", mode)
+	}
+}
+
+// runBootstrap builds the worker venv for the configured or recommended engine, or for the
+// engines named with --engine
+func runBootstrap(args []string) error {
+	fs := flag.NewFlagSet("bootstrap", flag.ExitOnError)
+	names := fs.String("engine", "", "comma-separated engines to provision (default: the one this host runs)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	profile := detectHardware()
+	engines := []profiler.Engine{profiler.Engine(cfg.Engine.Override)}
+	if cfg.Engine.Override == "" {
+		engines[0] = profile.GetRecommendedEngine(cfg.Engine.ModelSizeGB)
+	}
+	if *names != "" {
+		engines = nil
+		for _, name := range strings.Split(*names, ",") {
+			engine := profiler.Engine(strings.TrimSpace(name))
+			if !slices.Contains(profiler.Engines, engine) {
+				return fmt.Errorf("unknown engine %q (want one of %v)", engine, profiler.Engines)
+			}
+			engines = append(engines, engine)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	var errs []error
+	for _, engine := range engines {
+		python, err := newBootstrapper(profile, engine).Ensure(ctx, engine)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", engine, err))
+			continue
+		}
+		fmt.Println(python)
+	}
+	return errors.Join(errs...)
+}
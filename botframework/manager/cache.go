@@ -0,0 +1,41 @@
+package main
+
+import (
+	"botframework/cache"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// newResponseCache caches completions and embeddings when BOTFRAMEWORK_CACHE=on, in memory
+// or, with BOTFRAMEWORK_CACHE_DIR, on disk as well. BOTFRAMEWORK_CACHE_MAX_MB (default 256)
+// and BOTFRAMEWORK_CACHE_MAX_ENTRIES bound it, and BOTFRAMEWORK_CACHE_TTL expires entries.
+// Returns nil when disabled.
+func newResponseCache() (*cache.Cache, error) {
+	if os.Getenv("BOTFRAMEWORK_CACHE") != "on" {
+		return nil, nil
+	}
+	maxMB := 256
+	if mb, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_CACHE_MAX_MB")); err == nil && mb > 0 {
+		maxMB = mb
+	}
+	dir := os.Getenv("BOTFRAMEWORK_CACHE_DIR")
+	c, err := cache.New(int64(maxMB)<<20, dir)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_CACHE_MAX_ENTRIES")); err == nil && n > 0 {
+		c.MaxEntries = n
+	}
+	if raw := os.Getenv("BOTFRAMEWORK_CACHE_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			slog.Warn("invalid BOTFRAMEWORK_CACHE_TTL, keeping responses until evicted", "ttl", raw, "err", err)
+		} else {
+			c.TTL = ttl
+		}
+	}
+	slog.Info("caching responses", "max_mb", maxMB, "dir", dir, "entries", c.Stats().Entries)
+	return c, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"botframework/convert"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// workerEngine is the engine local workers run. llama-server workers are llama.cpp and
+// the default Docker image is vLLM, whichever engine the host was recommended; a custom
+// image is unknown and returns "".
+func workerEngine(backend profiler.Engine, llamaServer, vllm, mlx, docker bool) profiler.Engine {
+	switch {
+	case llamaServer:
+		return profiler.EngineLlamaCPP
+	case vllm:
+		return profiler.EngineVLLM
+	case mlx:
+		return profiler.EngineMLX
+	case docker && os.Getenv("BOTFRAMEWORK_DOCKER_IMAGE") != "":
+		return ""
+	case docker:
+		return profiler.EngineVLLM
+	}
+	return backend
+}
+
+// checkWorkerModel fails a worker for modelPath in mode before it launches when engine
+// cannot load the model, see profiler.EngineCapabilities. BOTFRAMEWORK_CAPABILITY_CHECK=off
+// launches it anyway, for engine builds that load more than the table says.
+func checkWorkerModel(engine profiler.Engine, modelPath, mode string) error {
+	if engine == "" || os.Getenv("BOTFRAMEWORK_CAPABILITY_CHECK") == "off" {
+		return nil
+	}
+	err := profiler.CheckModel(engine, modelPath, mode == supervisor.ModeEmbedding)
+	if target, ok := convert.TargetFor(engine); ok && errors.Is(err, profiler.ErrUnsupportedModel) && mode == "" {
+		return fmt.Errorf("%w; or convert it with `manager convert --to %s %s`", err, target, modelPath)
+	}
+	return err
+}
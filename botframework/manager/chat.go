@@ -0,0 +1,258 @@
+package main
+
+import (
+	"botframework/chat"
+	"botframework/engine"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"botframework/tools"
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// newWindowManager keeps prompts within each model's context window, using context lengths
+// from the model registry (BOTFRAMEWORK_REGISTRY_PATH).
+//
+//	BOTFRAMEWORK_CONTEXT_STRATEGY  truncate | summarize | reject | off (default: truncate)
+//	BOTFRAMEWORK_CONTEXT_WINDOW    window for models missing from the registry (default: 4096)
+//	BOTFRAMEWORK_SUMMARY_MODEL     model that writes summaries (default: the requested model)
+//
+// Prompts are counted with counter. Windows are capped at the context size the hardware
+// tier launches workers with, see tierDefaults. Returns nil when context management is off.
+func newWindowManager(port string, profile *profiler.HardwareProfile, counter chat.TokenCounter) *chat.WindowManager {
+	strategy := os.Getenv("BOTFRAMEWORK_CONTEXT_STRATEGY")
+	if strategy == "off" {
+		return nil
+	}
+
+	defaults, tiered := tierDefaults(profile)
+	wm := chat.NewWindowManager(func(model string) int {
+		window := currentRegistry().ContextWindow(model)
+		if tiered && window > 0 {
+			window = min(window, defaults.ContextSize)
+		}
+		return window
+	})
+	wm.Counter = counter
+	if tiered {
+		wm.DefaultWindow = min(wm.DefaultWindow, defaults.ContextSize)
+	}
+	if window, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_CONTEXT_WINDOW")); err == nil {
+		wm.DefaultWindow = window
+	}
+
+	switch strategy {
+	case "", chat.OverflowTruncate:
+	case chat.OverflowSummarize:
+		wm.Strategy = chat.OverflowSummarize
+		wm.Summarizer = newSummarizer(port)
+		slog.Info("summarizing turns that overflow the context window")
+	case chat.OverflowReject:
+		wm.Strategy = chat.OverflowReject
+		slog.Info("rejecting prompts that overflow the context window")
+	default:
+		slog.Warn("unknown context strategy, truncating", "strategy", strategy)
+	}
+	return wm
+}
+
+// newSummarizer asks BOTFRAMEWORK_SUMMARY_MODEL (default: the requested model) for summaries
+// through this manager's chat completions route
+func newSummarizer(port string) chat.Summarizer {
+	return chat.NewCompletionSummarizer("http://127.0.0.1:"+port+chat.CompletionsPath, os.Getenv("BOTFRAMEWORK_SUMMARY_MODEL"))
+}
+
+// newMemory enables rolling session summaries when BOTFRAMEWORK_MEMORY=on.
+// BOTFRAMEWORK_MEMORY_KEEP_TURNS and BOTFRAMEWORK_MEMORY_COMPRESS_EVERY tune when turns are folded
+// into the summary. Returns nil when disabled.
+func newMemory(port string) *chat.Memory {
+	if os.Getenv("BOTFRAMEWORK_MEMORY") != "on" {
+		return nil
+	}
+	memory := chat.NewMemory(newSummarizer(port))
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_MEMORY_KEEP_TURNS")); err == nil {
+		memory.KeepRecent = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_MEMORY_COMPRESS_EVERY")); err == nil && n > 0 {
+		memory.CompressEvery = n
+	}
+	slog.Info("rolling conversation memory enabled")
+	return memory
+}
+
+// newToolValidator repairs tool calls in non-streaming responses unless BOTFRAMEWORK_TOOL_REPAIR=off;
+// BOTFRAMEWORK_TOOL_REASKS bounds how often the model is asked to correct an invalid call
+func newToolValidator() *tools.Validator {
+	if os.Getenv("BOTFRAMEWORK_TOOL_REPAIR") == "off" {
+		return nil
+	}
+	validator := tools.NewValidator()
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_TOOL_REASKS")); err == nil && n >= 0 {
+		validator.MaxReasks = n
+	}
+	return validator
+}
+
+// newToolEmulator emulates tool calling for workers that do not parse tool calls themselves
+// (the Python worker and llama-server) unless BOTFRAMEWORK_TOOL_EMULATION=off. vLLM and other
+// Docker images, remote servers and the models listed in BOTFRAMEWORK_NATIVE_TOOL_MODELS
+// receive tools as sent.
+func newToolEmulator(manager *engine.ModelManager) *tools.Emulator {
+	if os.Getenv("BOTFRAMEWORK_TOOL_EMULATION") == "off" {
+		return nil
+	}
+	native := make(map[string]bool)
+	for _, model := range splitList(os.Getenv("BOTFRAMEWORK_NATIVE_TOOL_MODELS")) {
+		native[model] = true
+	}
+	return tools.NewEmulator(func(model string) bool {
+		if native[model] {
+			return true
+		}
+		e, err := manager.Resolve(model)
+		if err != nil {
+			return false
+		}
+		switch e.(type) {
+		case *supervisor.DockerWorker, *supervisor.RemoteEngine:
+			return true
+		}
+		return false
+	})
+}
+
+// newVision validates image inputs and routes them to a vision model unless BOTFRAMEWORK_VISION=off.
+//
+//	BOTFRAMEWORK_VISION_MODELS     comma-separated models that accept images, besides names like llava or *-vl
+//	BOTFRAMEWORK_IMAGE_MAX_DIM     longest image side in pixels before downscaling (default: 2048)
+//	BOTFRAMEWORK_IMAGE_FORMAT      jpeg | png to convert every image for the backend (default: keep)
+//	BOTFRAMEWORK_IMAGE_FETCH       off to reject remote image URLs and accept only base64 data URLs
+func newVision(manager *engine.ModelManager) *chat.Vision {
+	if os.Getenv("BOTFRAMEWORK_VISION") == "off" {
+		return nil
+	}
+	vision := chat.NewVision(manager.ListModels)
+	if list := os.Getenv("BOTFRAMEWORK_VISION_MODELS"); list != "" {
+		explicit := make(map[string]bool)
+		for _, model := range strings.Split(list, ",") {
+			explicit[strings.TrimSpace(model)] = true
+		}
+		vision.IsVisionModel = func(model string) bool {
+			return explicit[model] || chat.IsVisionModelName(model)
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_IMAGE_MAX_DIM")); err == nil && n > 0 {
+		vision.MaxDimension = n
+	}
+	switch format := os.Getenv("BOTFRAMEWORK_IMAGE_FORMAT"); format {
+	case "", "keep":
+	case "jpeg", "png":
+		vision.Format = format
+	default:
+		slog.Warn("unknown image format, keeping source formats", "format", format)
+	}
+	vision.FetchRemote = os.Getenv("BOTFRAMEWORK_IMAGE_FETCH") != "off"
+	return vision
+}
+
+// newDefaults loads per-model request defaults from the JSON file at BOTFRAMEWORK_MODEL_DEFAULTS.
+// Unless the file has a "*" entry, the hardware tier's max_tokens and max_tokens_cap apply
+// to every other model. Returns nil when there are no defaults to apply.
+func newDefaults(profile *profiler.HardwareProfile) *chat.Defaults {
+	var defaults *chat.Defaults
+	if path := os.Getenv("BOTFRAMEWORK_MODEL_DEFAULTS"); path != "" {
+		loaded, err := chat.LoadDefaults(path)
+		if err != nil {
+			slog.Warn("model defaults disabled", "err", err)
+		} else {
+			slog.Info("applying model defaults", "path", path)
+			defaults = loaded
+		}
+	}
+	tier, tiered := tierDefaults(profile)
+	if !tiered {
+		return defaults
+	}
+	if defaults == nil {
+		defaults = &chat.Defaults{}
+	}
+	if defaults.Models == nil {
+		defaults.Models = make(map[string]*chat.ModelDefaults)
+	}
+	// models without an entry of their own get the tier's completion lengths
+	if _, ok := defaults.Models["*"]; !ok {
+		defaults.Models["*"] = &chat.ModelDefaults{MaxTokens: tier.MaxTokens, MaxTokensCap: tier.MaxTokensCap}
+	}
+	return defaults
+}
+
+// newTransformer post-processes completion output. Leaked special tokens are stripped unless
+// BOTFRAMEWORK_STRIP_TOKENS=off; a comma-separated value strips those tokens as well.
+//
+//	BOTFRAMEWORK_REDACT       comma-separated built-in patterns to redact: email, phone, card, ipv4, secret
+//	BOTFRAMEWORK_REDACT_FILE  file with one additional regular expression to redact per line
+func newTransformer() *chat.Transformer {
+	transformer := chat.NewTransformer()
+	if strip := os.Getenv("BOTFRAMEWORK_STRIP_TOKENS"); strip != "off" {
+		tokens := append([]string{}, chat.DefaultStopTokens...)
+		for _, token := range strings.Split(strip, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+		transformer.Factories = append(transformer.Factories, chat.StripTokens(tokens...))
+	}
+
+	var patterns []*regexp.Regexp
+	for _, name := range strings.Split(os.Getenv("BOTFRAMEWORK_REDACT"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if pattern, ok := chat.RedactionPatterns[name]; ok {
+			patterns = append(patterns, pattern)
+		} else {
+			slog.Warn("unknown redaction pattern", "pattern", name)
+		}
+	}
+	if path := os.Getenv("BOTFRAMEWORK_REDACT_FILE"); path != "" {
+		custom, err := loadPatterns(path)
+		if err != nil {
+			slog.Warn("custom redaction disabled", "err", err)
+		}
+		patterns = append(patterns, custom...)
+	}
+	if len(patterns) > 0 {
+		slog.Info("redacting model output", "patterns", len(patterns))
+		transformer.Factories = append(transformer.Factories, chat.Redact(patterns...))
+	}
+	return transformer
+}
+
+// loadPatterns reads one regular expression per line, skipping blanks and # comments
+func loadPatterns(path string) ([]*regexp.Regexp, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []*regexp.Regexp
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, scanner.Err()
+}
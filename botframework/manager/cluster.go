@@ -0,0 +1,45 @@
+package main
+
+import (
+	"botframework/supervisor"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// newScheduler returns the cluster scheduler selected by the environment, or nil to run workers locally:
+//
+//	BOTFRAMEWORK_EXECUTOR         local | slurm | ray (default: local)
+//	BOTFRAMEWORK_CLUSTER_GPUS     GPUs requested per worker job
+//	BOTFRAMEWORK_SLURM_PARTITION  sbatch --partition
+//	BOTFRAMEWORK_SLURM_ARGS       extra sbatch arguments, space separated
+//	BOTFRAMEWORK_RAY_ADDRESS      Ray dashboard address, e.g. http://head:8265
+func newScheduler() (supervisor.Scheduler, error) {
+	gpus, _ := strconv.Atoi(os.Getenv("BOTFRAMEWORK_CLUSTER_GPUS"))
+
+	switch executor := os.Getenv("BOTFRAMEWORK_EXECUTOR"); executor {
+	case "", "local":
+		return nil, nil
+	case "slurm":
+		return &supervisor.SlurmScheduler{
+			Partition: os.Getenv("BOTFRAMEWORK_SLURM_PARTITION"),
+			GPUs:      gpus,
+			ExtraArgs: strings.Fields(os.Getenv("BOTFRAMEWORK_SLURM_ARGS")),
+		}, nil
+	case "ray":
+		return &supervisor.RayScheduler{Address: os.Getenv("BOTFRAMEWORK_RAY_ADDRESS"), GPUs: gpus}, nil
+	default:
+		return nil, fmt.Errorf("unknown executor %q (want local, slurm or ray)", executor)
+	}
+}
+
+// newClusterWorker mirrors a local worker's settings onto a scheduler job
+func newClusterWorker(scheduler supervisor.Scheduler, scriptPath, port, modelPath string) *supervisor.ClusterWorker {
+	worker := supervisor.NewClusterWorker(scriptPath, port, scheduler)
+	worker.ModelPath = modelPath
+	if python := os.Getenv("BOTFRAMEWORK_PYTHON"); python != "" {
+		worker.Python = python
+	}
+	return worker
+}
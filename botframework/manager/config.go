@@ -0,0 +1,52 @@
+package main
+
+import (
+	"botframework/config"
+	"botframework/engine"
+	"botframework/logging"
+	"botframework/profiler"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// loadConfig reads BOTFRAMEWORK_CONFIG (default: ./botframework.yaml when present) and exports
+// its settings to the environment, where the rest of the manager reads them
+func loadConfig() (*config.Config, error) {
+	path := config.Path()
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := logging.Setup(os.Stderr, cfg.LogLevel, cfg.LogFormat); err != nil {
+		return nil, err
+	}
+	if path != "" {
+		slog.Info("loaded configuration", "path", path)
+	}
+	cfg.Export()
+	return cfg, nil
+}
+
+func managerOptions(cfg *config.Config) engine.ManagerOptions {
+	return engine.ManagerOptions{
+		WorkerScript: cfg.Worker.Script,
+		Engine:       profiler.Engine(cfg.Engine.Override),
+		ModelSizeGB:  cfg.Engine.ModelSizeGB,
+	}
+}
+
+// recommendationRequest is the workload model recommendations are scored for:
+//
+//	BOTFRAMEWORK_CONTEXT_LENGTH  context each sequence needs, 0 for the scorer's default
+//	BOTFRAMEWORK_CONCURRENCY     sequences served at once, each with its own KV cache
+//	BOTFRAMEWORK_PREFERENCE      latency, balanced (the default) or quality
+func recommendationRequest() profiler.RecommendationRequest {
+	tokens, _ := strconv.Atoi(os.Getenv("BOTFRAMEWORK_CONTEXT_LENGTH"))
+	concurrency, _ := strconv.Atoi(os.Getenv("BOTFRAMEWORK_CONCURRENCY"))
+	return profiler.RecommendationRequest{
+		ContextLength: tokens,
+		Concurrency:   concurrency,
+		Preference:    profiler.Preference(os.Getenv("BOTFRAMEWORK_PREFERENCE")),
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"botframework/convert"
+	"botframework/download"
+	"botframework/profiler"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// localVariantsPath returns BOTFRAMEWORK_VARIANTS_PATH or the per-user default, where
+// `manager convert` records the variants it makes
+func localVariantsPath() string {
+	if path := os.Getenv("BOTFRAMEWORK_VARIANTS_PATH"); path != "" {
+		return path
+	}
+	return profiler.DefaultLocalVariantsPath()
+}
+
+// runConvert converts a cached registry model, or a model file or checkpoint directory, to
+// the format the engine this host runs loads. Conversions of cached models land in the
+// cache as a new variant of the model ("llama-3-8b-instruct:MLX-Q4"), recorded in the
+// local variants so recommendations rank it; other models are converted next
+// to their weights. The converters run under BOTFRAMEWORK_CONVERT_PYTHON, else
+// BOTFRAMEWORK_PYTHON, else python3.
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := fs.String("to", "", "format to convert to: mlx or awq (default: the one this host's engine loads)")
+	bits := fs.Int("bits", 4, "weight width of the converted model")
+	cacheDir := fs.String("cache", modelCacheDir(), "model cache directory")
+	python := fs.String("python", cmp.Or(os.Getenv("BOTFRAMEWORK_CONVERT_PYTHON"), os.Getenv("BOTFRAMEWORK_PYTHON")), "Python interpreter with the converters installed")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: manager convert [--to mlx|awq] [--bits 4] <model id[:quant] | path>")
+	}
+
+	registry := loadRegistry()
+	source, model, variant, err := conversionSource(registry, *cacheDir, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	target := convert.Target(*to)
+	if target == "" {
+		if target, err = defaultTarget(variant.SizeGB); err != nil {
+			return err
+		}
+	}
+
+	converter := convert.New(*python)
+	converter.Bits = *bits
+	converter.Progress = conversionPrinter()
+	dest := converter.Output(source, target)
+	if model != nil {
+		dest = filepath.Join(*cacheDir, model.ID, target.Quant(*bits))
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	fmt.Fprintf(os.Stderr, "🔄 Converting %s to %s\n", source, target.Quant(*bits))
+	path, err := converter.Convert(ctx, source, dest, target)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	if model != nil {
+		if err := recordConversion(model.ID, variant, target, *bits, path); err != nil {
+			return fmt.Errorf("converted to %s, but the variant was not recorded: %w", path, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "✅ Stored in %s\n", path)
+	fmt.Println(path)
+	return nil
+}
+
+// conversionSource resolves what to convert: an existing path, or a registry model in the
+// cache ("<id>" or "<id>:<quant>"). Without a quant the largest cached GGUF variant is
+// converted, as requantizing loses the least from it. model is nil for paths outside the
+// cache's layout.
+func conversionSource(registry *profiler.ModelRegistry, cacheDir, name string) (string, *profiler.Model, profiler.Variant, error) {
+	if _, err := os.Stat(name); err == nil {
+		source, _ := filepath.Abs(name)
+		cacheDir, _ = filepath.Abs(cacheDir)
+		// a path into the cache is a variant of the model it is cached under
+		if rel, err := filepath.Rel(cacheDir, source); err == nil && !strings.HasPrefix(rel, "..") {
+			parts := strings.Split(rel, string(filepath.Separator))
+			if model := registry.Lookup(parts[0]); model != nil && len(parts) > 1 && model.ID == parts[0] {
+				return source, model, findVariant(model, parts[1]), nil
+			}
+		}
+		return source, nil, profiler.Variant{}, nil
+	}
+
+	id, quant, _ := strings.Cut(name, ":")
+	model := registry.Lookup(id)
+	if model == nil {
+		return "", nil, profiler.Variant{}, fmt.Errorf("%q is neither a file nor a registry model", name)
+	}
+	variants := slices.Clone(model.Variants)
+	slices.SortFunc(variants, func(a, b profiler.Variant) int { return cmp.Compare(b.SizeGB, a.SizeGB) })
+	for _, variant := range variants {
+		if (quant != "" && !strings.EqualFold(variant.Quant, quant)) || (quant == "" && variant.Format != "") {
+			continue
+		}
+		if path, ok := download.Find(cacheDir, model.ID, variant.Quant); ok {
+			return path, model, variant, nil
+		}
+	}
+	if quant == "" {
+		quant = "any"
+	}
+	return "", nil, profiler.Variant{}, fmt.Errorf("model %s (%s) is not in the model cache; run `manager download %s` first", model.ID, quant, model.ID)
+}
+
+func findVariant(model *profiler.Model, quant string) profiler.Variant {
+	for _, variant := range model.Variants {
+		if variant.Quant == quant {
+			return variant
+		}
+	}
+	return profiler.Variant{Quant: quant}
+}
+
+// defaultTarget is the format the engine this host runs loads, for a model of sizeGB
+func defaultTarget(sizeGB float64) (convert.Target, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	engine := profiler.Engine(cfg.Engine.Override)
+	if engine == "" {
+		engine = detectHardware().GetRecommendedEngine(cmp.Or(sizeGB, cfg.Engine.ModelSizeGB))
+	}
+	target, ok := convert.TargetFor(engine)
+	if !ok {
+		return "", fmt.Errorf("%s loads GGUF weights, so there is nothing to convert; choose a format with --to", engine)
+	}
+	return target, nil
+}
+
+// recordConversion adds the converted weights at path to the local variants of modelID.
+// They keep at most the accuracy of the variant they were made from.
+func recordConversion(modelID string, from profiler.Variant, target convert.Target, bits int, path string) error {
+	variants, err := profiler.LoadLocalVariants(localVariantsPath())
+	if err != nil {
+		return err
+	}
+	format := profiler.FormatSafetensors
+	if target == convert.TargetMLX {
+		format = profiler.FormatMLX
+	}
+	retention := 0.97
+	if bits >= 8 {
+		retention = 0.995
+	}
+	if from.AccuracyRetention > 0 {
+		retention = min(retention, from.AccuracyRetention)
+	}
+	variants.Add(modelID, profiler.Variant{Quant: target.Quant(bits), SizeGB: convert.SizeGB(path),
+		AccuracyRetention: retention, Format: format})
+	return variants.Save(localVariantsPath())
+}
+
+// conversionPrinter redraws one progress line per conversion step
+func conversionPrinter() func(convert.Progress) {
+	var step string
+	last := -1
+	return func(p convert.Progress) {
+		if p.Step != step {
+			if step != "" {
+				fmt.Fprintln(os.Stderr)
+			}
+			step, last = p.Step, -1
+		}
+		if percent := int(p.Fraction * 100); percent != last {
+			last = percent
+			fmt.Fprintf(os.Stderr, "\r   %s: %3d%%", p.Step, percent)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"botframework/discovery"
+	"botframework/engine"
+	"context"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// startDiscovery advertises the manager on the network. BOTFRAMEWORK_DISCOVERY is a comma
+// separated list of mechanisms:
+//
+//	mdns    answer mDNS/DNS-SD queries for _botframework._tcp on the LAN
+//	consul  register with the Consul agent at BOTFRAMEWORK_CONSUL_ADDR (default http://127.0.0.1:8500),
+//	        authenticating with BOTFRAMEWORK_CONSUL_TOKEN
+//
+// The returned func deregisters from Consul and should run on shutdown.
+func startDiscovery(ctx context.Context, manager *engine.ModelManager, port string) func() {
+	mechanisms := os.Getenv("BOTFRAMEWORK_DISCOVERY")
+	if mechanisms == "" {
+		return func() {}
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	cleanup := func() {}
+	for _, mechanism := range strings.Split(mechanisms, ",") {
+		switch strings.TrimSpace(mechanism) {
+		case "mdns":
+			advertiser := discovery.NewAdvertiser(portNum, manager.ListModels)
+			go func() {
+				if err := advertiser.Run(ctx); err != nil {
+					slog.Warn("mDNS advertisement stopped", "err", err)
+				}
+			}()
+		case "consul":
+			address := os.Getenv("BOTFRAMEWORK_CONSUL_ADDR")
+			if address == "" {
+				address = "http://127.0.0.1:8500"
+			}
+			hostname, _ := os.Hostname()
+			registration := &discovery.ConsulRegistration{
+				Address:   address,
+				Token:     os.Getenv("BOTFRAMEWORK_CONSUL_TOKEN"),
+				ServiceID: "botframework-" + hostname + "-" + port,
+				Name:      "botframework",
+				Host:      hostname,
+				Port:      portNum,
+				Tags:      []string{"openai-compatible"},
+			}
+			if err := registration.Register(manager.ListModels()); err != nil {
+				slog.Warn("consul registration failed", "err", err)
+				continue
+			}
+			slog.Info("registered with Consul", "address", address)
+			cleanup = func() {
+				if err := registration.Deregister(); err != nil {
+					slog.Warn("consul deregistration failed", "err", err)
+				}
+			}
+		default:
+			slog.Warn("unknown discovery mechanism", "mechanism", mechanism)
+		}
+	}
+	return cleanup
+}
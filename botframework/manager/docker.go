@@ -0,0 +1,69 @@
+package main
+
+import (
+	"botframework/profiler"
+	"botframework/supervisor"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// dockerClient decides whether workers run as containers, which BOTFRAMEWORK_WORKER_RUNTIME=docker
+// asks for. The Docker daemon is reached through DOCKER_HOST, or its default socket.
+func dockerClient() (*supervisor.DockerClient, bool) {
+	if os.Getenv("BOTFRAMEWORK_WORKER_RUNTIME") != "docker" {
+		return nil, false
+	}
+	client, err := supervisor.NewDockerClient(os.Getenv("DOCKER_HOST"))
+	if err != nil {
+		slog.Warn("docker unavailable; using the Python worker", "err", err)
+		return nil, false
+	}
+	return client, true
+}
+
+// newDockerWorker creates a container worker for modelPath in mode, running
+// BOTFRAMEWORK_DOCKER_IMAGE (default: vLLM's OpenAI server) with the model cache mounted.
+// BOTFRAMEWORK_DOCKER_GPUS passes GPUs through ("all", a count or device IDs; default: all
+// on NVIDIA hosts, "none" for none) and BOTFRAMEWORK_DOCKER_ARGS adds engine arguments.
+// vLLM chat workers parse tool calls with BOTFRAMEWORK_VLLM_TOOL_PARSER, see toolCallParser.
+func newDockerWorker(client *supervisor.DockerClient, port, modelPath, mode string, profile *profiler.HardwareProfile) *supervisor.DockerWorker {
+	worker := supervisor.NewDockerWorker(client, os.Getenv("BOTFRAMEWORK_DOCKER_IMAGE"), port, modelPath)
+	worker.ModelDir = modelCacheDir()
+	switch gpus := os.Getenv("BOTFRAMEWORK_DOCKER_GPUS"); gpus {
+	case "":
+		if profile != nil && profile.HasCuda {
+			worker.GPUs = "all"
+		}
+	case "none":
+	default:
+		worker.GPUs = gpus
+	}
+	worker.Args = strings.Fields(os.Getenv("BOTFRAMEWORK_DOCKER_ARGS"))
+	if mode == supervisor.ModeEmbedding {
+		worker.Args = append(worker.Args, "--task", "embed")
+	} else if worker.Image == supervisor.DefaultDockerImage && !slices.Contains(worker.Args, "--tool-call-parser") {
+		worker.Args = append(worker.Args, "--enable-auto-tool-choice", "--tool-call-parser", toolCallParser(modelPath))
+	}
+	slog.Info("using docker", "image", worker.Image, "model", modelPath)
+	return worker
+}
+
+// toolCallParser is the vLLM parser that reads tool calls in the output format of the model
+// at modelPath: BOTFRAMEWORK_VLLM_TOOL_PARSER, else llama3_json for Llama 3, mistral for
+// Mistral models and hermes, which Qwen and Hermes models write, for the rest
+func toolCallParser(modelPath string) string {
+	if parser := os.Getenv("BOTFRAMEWORK_VLLM_TOOL_PARSER"); parser != "" {
+		return parser
+	}
+	name := strings.ToLower(filepath.Base(modelPath))
+	switch {
+	case strings.Contains(name, "llama-3") || strings.Contains(name, "llama3"):
+		return "llama3_json"
+	case strings.Contains(name, "mistral") || strings.Contains(name, "mixtral"):
+		return "mistral"
+	}
+	return "hermes"
+}
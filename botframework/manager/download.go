@@ -0,0 +1,127 @@
+package main
+
+import (
+	"botframework/download"
+	"botframework/profiler"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// modelCacheDir returns BOTFRAMEWORK_MODEL_CACHE or the per-user default
+func modelCacheDir() string {
+	if dir := os.Getenv("BOTFRAMEWORK_MODEL_CACHE"); dir != "" {
+		return dir
+	}
+	return download.DefaultCacheDir()
+}
+
+// detectModelDisk profiles the model cache's volume so recommendations skip variants that
+// do not fit on it and flag slow loads
+func detectModelDisk(profile *profiler.HardwareProfile) {
+	profile.DetectDisk(modelCacheDir())
+	disk := profile.Disk
+	slog.Info("model disk", "path", disk.Path, "class", disk.Class, "free_gb", int(disk.FreeGB), "read_mbps", int(disk.ReadMBps), "measured", disk.Measured)
+}
+
+// runDownload fetches a registry model from Hugging Face into the model cache
+func runDownload(args []string) error {
+	fs := flag.NewFlagSet("download", flag.ExitOnError)
+	quant := fs.String("quant", "", "variant to download (default: the best fit for this host)")
+	cacheDir := fs.String("cache", modelCacheDir(), "model cache directory")
+	revision := fs.String("revision", "main", "repository branch, tag or commit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: manager download [--quant Q4_K_M] <model id>")
+	}
+
+	registry := loadRegistry()
+	model := registry.Lookup(fs.Arg(0))
+	if model == nil {
+		return fmt.Errorf("model %q is not in the registry", fs.Arg(0))
+	}
+	variant, err := pickVariant(model, *quant)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	downloader := download.New(*cacheDir)
+	downloader.Revision = *revision
+	downloader.Token = os.Getenv("HF_TOKEN")
+	downloader.Progress = progressPrinter()
+	fmt.Fprintf(os.Stderr, "📥 Downloading %s %s from %s\n", model.ID, variant.Quant, model.HFRepo)
+	path, err := downloader.Download(ctx, *model, variant)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Stored in %s\n", path)
+	_, err = downloader.DownloadTokenizer(ctx, *model)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  No tokenizer, token counts will be estimated: %v\n", err)
+	}
+	fmt.Println(path)
+	return nil
+}
+
+// pickVariant returns the named variant, or the one that scores best on this host
+func pickVariant(model *profiler.Model, quant string) (profiler.Variant, error) {
+	if quant != "" {
+		for _, variant := range model.Variants {
+			if strings.EqualFold(variant.Quant, quant) {
+				return variant, nil
+			}
+		}
+		return profiler.Variant{}, fmt.Errorf("model %s has no %s variant", model.ID, quant)
+	}
+	if len(model.Variants) == 0 {
+		return profiler.Variant{}, fmt.Errorf("model %s has no variants", model.ID)
+	}
+	profile := detectHardware()
+	detectModelDisk(profile)
+	var ranked []profiler.ScoredVariant
+	if model.IsEmbedding() {
+		ranked = profile.RecommendEmbeddingModels(&profiler.ModelRegistry{EmbeddingModels: []profiler.Model{*model}})
+	} else {
+		reserveEmbeddingMemory(profile)
+		ranked = profile.Recommend(&profiler.ModelRegistry{Models: []profiler.Model{*model}}, recommendationRequest())
+	}
+	if len(ranked) > 0 {
+		return ranked[0].Variant, nil
+	}
+	return model.Variants[0], nil
+}
+
+// progressPrinter redraws one progress line per file whenever the percentage changes
+func progressPrinter() func(download.Progress) {
+	var file string
+	last := -1
+	return func(p download.Progress) {
+		if p.File != file {
+			if file != "" {
+				fmt.Fprintln(os.Stderr)
+			}
+			file, last = p.File, -1
+		}
+		if p.Total <= 0 {
+			fmt.Fprintf(os.Stderr, "\r   %s: %d MB", p.File, p.Downloaded>>20)
+			return
+		}
+		if percent := int(p.Downloaded * 100 / p.Total); percent != last {
+			last = percent
+			fmt.Fprintf(os.Stderr, "\r   %s: %3d%% (%d/%d MB)", p.File, percent, p.Downloaded>>20, p.Total>>20)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"botframework/download"
+	"botframework/profiler"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// draftModel finds the draft model llama-server pairs with modelPath for speculative
+// decoding, per BOTFRAMEWORK_DRAFT_MODEL: "auto" (the default) drafts with the model the
+// recommender pairs with the target on High and Elite hosts, when it is downloaded; "off"
+// never drafts; anything else is a GGUF file or a registry model "<id>[:<quant>]" under
+// BOTFRAMEWORK_MODEL_DIR or in the model cache. tokens is 0 when llama-server's default
+// draft length applies.
+func draftModel(profile *profiler.HardwareProfile, modelPath string) (path string, tokens int, ok bool) {
+	spec := os.Getenv("BOTFRAMEWORK_DRAFT_MODEL")
+	switch spec {
+	case "off":
+		return "", 0, false
+	case "", "auto":
+		if profile == nil {
+			return "", 0, false
+		}
+	default:
+		if _, err := os.Stat(spec); err == nil {
+			return spec, 0, true
+		}
+		path, err := findModel(os.Getenv("BOTFRAMEWORK_MODEL_DIR"), modelCacheDir(), spec)
+		if err != nil {
+			slog.Warn("draft model not found; speculative decoding off", "draft", spec, "err", err)
+			return "", 0, false
+		}
+		return path, 0, true
+	}
+
+	registry := loadRegistry()
+	target, variant, found := identifyModel(registry, modelPath)
+	if !found {
+		return "", 0, false
+	}
+	plan, planned := profile.PlanDraft(registry, *target, variant, 0)
+	if !planned {
+		return "", 0, false
+	}
+	path, found = download.Find(modelCacheDir(), plan.ModelID, plan.Variant.Quant)
+	if !found {
+		slog.Info("draft model not downloaded; speculative decoding off", "draft", plan.ModelID,
+			"fetch", "manager download --quant "+plan.Variant.Quant+" "+plan.ModelID)
+		return "", 0, false
+	}
+	slog.Info("speculative decoding", "target", target.ID, "draft", plan.ModelID, "quant", plan.Variant.Quant,
+		"tokens", plan.Tokens, "speedup", plan.Speedup, "extra_gb", plan.ExtraGB)
+	return path, plan.Tokens, true
+}
+
+// identifyModel finds the registry model and variant a model file holds: downloads are
+// cached as <id>/<quant>/<file>, other files are matched by name
+func identifyModel(registry *profiler.ModelRegistry, path string) (*profiler.Model, profiler.Variant, bool) {
+	quant := filepath.Base(filepath.Dir(path))
+	model := registry.Lookup(filepath.Base(filepath.Dir(filepath.Dir(path))))
+	if model == nil {
+		quant = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		model = registry.Lookup(quant)
+	}
+	if model == nil {
+		return nil, profiler.Variant{}, false
+	}
+	for _, variant := range model.Variants {
+		if strings.Contains(strings.ToLower(quant), strings.ToLower(variant.Quant)) {
+			return model, variant, true
+		}
+	}
+	return nil, profiler.Variant{}, false
+}
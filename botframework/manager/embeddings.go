@@ -0,0 +1,122 @@
+package main
+
+import (
+	"botframework/download"
+	"botframework/engine"
+	"botframework/profiler"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// embeddingModel is the model serving /v1/embeddings beside the chat model
+type embeddingModel struct {
+	name    string // registered name; requests naming no registered model reach it too
+	path    string
+	variant profiler.Variant // sized from the registry, or from the file
+}
+
+// resolveEmbeddingModel finds the model BOTFRAMEWORK_EMBEDDING_MODEL names: a GGUF file, a
+// registry model "<id>[:<quant>]" under BOTFRAMEWORK_MODEL_DIR or in the model cache, or
+// "auto" for the best-scoring embedding model already downloaded. ok is false when unset.
+func resolveEmbeddingModel(profile *profiler.HardwareProfile, modelDir, cacheDir string) (model embeddingModel, ok bool, err error) {
+	spec := os.Getenv("BOTFRAMEWORK_EMBEDDING_MODEL")
+	if spec == "" {
+		return embeddingModel{}, false, nil
+	}
+	registry := loadRegistry()
+	if spec == "auto" {
+		if cacheDir == "" {
+			return embeddingModel{}, false, errors.New("no model cache to pick an embedding model from")
+		}
+		for _, ranked := range profile.RecommendEmbeddingModels(registry) {
+			if path, found := download.Find(cacheDir, ranked.ModelID, ranked.Variant.Quant); found {
+				return embeddingModel{name: ranked.ModelID, path: path, variant: ranked.Variant}, true, nil
+			}
+		}
+		return embeddingModel{}, false, errors.New("no embedding model is downloaded; fetch one with `manager download nomic-embed-text-v1.5`")
+	}
+
+	if info, statErr := os.Stat(spec); statErr == nil {
+		return embeddingModel{name: modelName(spec), path: spec, variant: profiler.Variant{SizeGB: float64(info.Size()) / (1 << 30)}}, true, nil
+	}
+	path, err := findModel(modelDir, cacheDir, spec)
+	if err != nil {
+		return embeddingModel{}, false, err
+	}
+	id, quant, _ := strings.Cut(spec, ":")
+	model = embeddingModel{name: id, path: path}
+	if registered := registry.Lookup(id); registered != nil {
+		for _, variant := range registered.Variants {
+			if quant == "" || strings.EqualFold(variant.Quant, quant) {
+				model.variant = variant
+				break
+			}
+		}
+	}
+	if model.variant.SizeGB == 0 {
+		if info, err := os.Stat(path); err == nil {
+			model.variant.SizeGB = float64(info.Size()) / (1 << 30)
+		}
+	}
+	return model, true, nil
+}
+
+// startEmbeddingModel launches the embedding worker, if one is configured, registers it as
+// the manager's embedding model and takes its memory off what chat models are scored against
+func startEmbeddingModel(manager *engine.ModelManager, modelDir, cacheDir string, launch func(name, path string) (engine.InferenceEngine, error)) {
+	model, ok, err := resolveEmbeddingModel(manager.Profile, modelDir, cacheDir)
+	if err != nil {
+		slog.Error("embedding model not started", "err", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	slog.Info("starting embedding model", "model", model.name, "path", model.path)
+	e, err := launch(model.name, model.path)
+	if err != nil {
+		slog.Error("embedding model not started", "model", model.name, "err", err)
+		return
+	}
+	manager.Register(model.name, e)
+	manager.EmbeddingModel = model.name
+	if manager.Profile != nil {
+		manager.Profile.ReserveEmbedding(model.variant)
+	}
+}
+
+// reserveEmbeddingMemory accounts for the configured embedding model in profile, so
+// recommendations made outside the running manager leave it room
+func reserveEmbeddingMemory(profile *profiler.HardwareProfile) {
+	cacheDir := modelCacheDir()
+	if _, err := os.Stat(cacheDir); err != nil {
+		cacheDir = ""
+	}
+	model, ok, err := resolveEmbeddingModel(profile, os.Getenv("BOTFRAMEWORK_MODEL_DIR"), cacheDir)
+	if err != nil {
+		slog.Warn("embedding model memory not reserved", "err", err)
+		return
+	}
+	if ok {
+		profile.ReserveEmbedding(model.variant)
+	}
+}
+
+// embeddingRecommendations lists the registry's embedding models ranked for profile
+func embeddingRecommendations(profile *profiler.HardwareProfile, registry *profiler.ModelRegistry) []modelRecommendation {
+	recommendations := []modelRecommendation{}
+	for _, ranked := range profile.RecommendEmbeddingModels(registry) {
+		recommendations = append(recommendations, modelRecommendation{
+			ID:             ranked.ModelID,
+			Name:           ranked.ModelName,
+			Quant:          ranked.Variant.Quant,
+			SizeGB:         ranked.Variant.SizeGB,
+			Score:          ranked.Score,
+			Reason:         ranked.Reason,
+			RelativeEnergy: ranked.RelativeEnergy,
+		})
+	}
+	return recommendations
+}
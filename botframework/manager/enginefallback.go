@@ -0,0 +1,215 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+)
+
+// startDefaultEngine starts the default worker. When its engine fails to start, for example
+// vLLM on a driver it was not built for, the manager tries the next engine of the host's
+// fallback chain, see profiler.EngineFallbacks, instead of exiting: the failure is logged
+// with its reason and the worker's last output, and the first engine that starts serves the
+// default model. base is the Python worker configureRouting started from; its port, script
+// and environment carry over.
+//
+//	BOTFRAMEWORK_ENGINE_FALLBACK  off exits on the first failure; a list such as
+//	                              "exllamav2,llama_cpp" replaces the chain
+func startDefaultEngine(ctx context.Context, manager *engine.ModelManager, base *supervisor.PythonWorker, gpus profiler.GPUAssignments, idle idleConfig) error {
+	err := manager.Start(ctx)
+	recordDefaultLoad(manager, err)
+	if err == nil || base == nil || !fallbackWorker(manager.Engine) {
+		return err
+	}
+	chain, chainErr := engineFallbackChain(manager.Profile, manager.Backend)
+	if chainErr != nil {
+		slog.Warn("engine fallback disabled", "err", chainErr)
+	}
+	if len(chain) == 0 {
+		return err
+	}
+
+	preferred := manager.Backend
+	failures := []error{logStartFailure(manager.Backend, manager.Engine, err)}
+	for _, next := range chain {
+		failed := manager.Engine
+		_ = failed.Stop()
+		_ = manager.SetIdlePolicy("default", failed, engine.IdlePolicy{})
+
+		worker, err := newFallbackWorker(manager.Profile, next, base, gpus)
+		if err != nil {
+			failures = append(failures, logStartFailure(next, nil, err))
+			continue
+		}
+		manager.Engine, manager.Backend = worker, next
+		err = manager.Start(ctx)
+		recordDefaultLoad(manager, err)
+		if err != nil {
+			failures = append(failures, logStartFailure(next, worker, err))
+			continue
+		}
+
+		if base.ModelPath != "" {
+			manager.Register(filepath.Base(base.ModelPath), worker)
+		}
+		idle.unloadWhenIdle(manager, "default", worker)
+		manager.Queue = queueConfig(next)
+		slog.Warn("serving with fallback engine", "engine", next, "preferred", preferred, "attempts", len(failures)+1)
+		return nil
+	}
+	return fmt.Errorf("%s and its fallbacks failed to start: %w", preferred, errors.Join(failures...))
+}
+
+// engineFallbackChain reads BOTFRAMEWORK_ENGINE_FALLBACK, defaulting to the tier's chain
+func engineFallbackChain(profile *profiler.HardwareProfile, primary profiler.Engine) ([]profiler.Engine, error) {
+	switch spec := os.Getenv("BOTFRAMEWORK_ENGINE_FALLBACK"); spec {
+	case "off":
+		return nil, nil
+	case "", "auto":
+		if profile == nil {
+			return nil, nil
+		}
+		return profile.EngineFallbacks(primary), nil
+	default:
+		chain, err := profiler.ParseEngines(spec)
+		if err != nil {
+			return nil, fmt.Errorf("BOTFRAMEWORK_ENGINE_FALLBACK: %w", err)
+		}
+		return chain, nil
+	}
+}
+
+// fallbackWorker reports whether e is a single local worker another engine can replace;
+// remote, clustered, pooled and containerised workers are left as configured
+func fallbackWorker(e engine.InferenceEngine) bool {
+	switch e.(type) {
+	case *supervisor.PythonWorker, *supervisor.GrpcWorker, *supervisor.LlamaCppWorker, *supervisor.VLLMWorker, *supervisor.MLXWorker:
+		return true
+	}
+	return false
+}
+
+// errNoLauncher is returned for fallback engines the manager cannot start a worker for
+var errNoLauncher = errors.New("no worker can run this engine here")
+
+// newFallbackWorker creates the default worker for engine next: llama-server or vllm serve
+// when installed, mlx_lm.server when its Python has mlx_lm, otherwise the Python worker for
+// the llama.cpp engines, which runs on llama-cpp-python. The SYCL build needs the venv
+// `manager bootstrap` built for it. Engines without a worker (ExLlamaV2, IPEX-LLM) are
+// skipped, and models next cannot load are refused before anything launches.
+func newFallbackWorker(profile *profiler.HardwareProfile, next profiler.Engine, base *supervisor.PythonWorker, gpus profiler.GPUAssignments) (engine.InferenceEngine, error) {
+	modelPath := base.ModelPath
+	if modelPath != "" {
+		if err := checkWorkerModel(next, modelPath, ""); err != nil {
+			return nil, err
+		}
+	}
+	var worker engine.InferenceEngine
+	switch next {
+	case profiler.EngineLlamaCPP, profiler.EngineLlamaCPPSYCL:
+		if llamaServer, err := findLlamaServer(); err == nil && next == profiler.EngineLlamaCPP && modelPath != "" {
+			llama := newLlamaCppWorker(llamaServer, base.Port, modelPath, "", profile)
+			llama.Env = base.Env
+			worker = llama
+			break
+		}
+		python := supervisor.NewPythonWorker(base.ScriptPath, base.Port)
+		python.ModelPath = modelPath
+		python.Env = slices.Clone(base.Env)
+		// each attempt runs from its own engine's venv, without changing the manager's
+		// environment for the attempts after it
+		venv, ok := "", false
+		if os.Getenv("BOTFRAMEWORK_BOOTSTRAP") != "off" {
+			venv, ok = newBootstrapper(profile, next).Ready(next)
+		}
+		switch {
+		case ok:
+			slog.Info("using worker venv", "engine", next, "python", venv)
+			python.Env = append(python.Env, "BOTFRAMEWORK_PYTHON="+venv)
+		case next == profiler.EngineLlamaCPPSYCL:
+			return nil, fmt.Errorf("%w: run `go run ./manager bootstrap` to build llama-cpp-python for SYCL", errNoLauncher)
+		}
+		worker = python
+	case profiler.EngineVLLM:
+		vllm, err := findVLLM()
+		if err != nil {
+			return nil, err
+		}
+		if modelPath == "" {
+			return nil, fmt.Errorf("%w: vllm serve needs a model path", errNoLauncher)
+		}
+		vllmWorker, err := newVLLMWorker(vllm, base.Port, modelPath, "", profile)
+		if err != nil {
+			return nil, err
+		}
+		vllmWorker.Env = base.Env
+		worker = vllmWorker
+	case profiler.EngineMLX:
+		python, err := findMLXPython()
+		if err != nil {
+			return nil, fmt.Errorf("mlx_lm: %w", err)
+		}
+		if modelPath == "" {
+			return nil, fmt.Errorf("%w: mlx_lm.server needs a model path", errNoLauncher)
+		}
+		mlxWorker, err := newMLXWorker(python, base.Port, modelPath, profile)
+		if err != nil {
+			return nil, err
+		}
+		mlxWorker.Env = base.Env
+		worker = mlxWorker
+	default:
+		return nil, errNoLauncher
+	}
+
+	if devices, ok := gpus[profiler.DefaultWorkerGPUs]; ok {
+		pinGPUs(profiler.DefaultWorkerGPUs, worker, devices)
+	}
+	if defaults, tiered := tierDefaults(profile); tiered {
+		applyTierDefaults(worker, defaults)
+	}
+	return worker, nil
+}
+
+// logStartFailure logs why engine e did not start, with the last lines it printed, and
+// returns the error naming the engine
+func logStartFailure(name profiler.Engine, e engine.InferenceEngine, err error) error {
+	attrs := []any{"engine", name, "reason", startFailureReason(err), "err", err}
+	if logs, ok := e.(interface{ Logs(n int) []string }); ok {
+		if tail := logs.Logs(10); len(tail) > 0 {
+			attrs = append(attrs, "output", tail)
+		}
+	}
+	slog.Error("engine failed to start", attrs...)
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// startFailureReason classifies a worker's startup error for the diagnostic
+func startFailureReason(err error) string {
+	switch {
+	case errors.Is(err, supervisor.ErrOutOfMemory):
+		return "out_of_memory"
+	case errors.Is(err, supervisor.ErrModelLoadFailed):
+		return "model_load_failed"
+	case errors.Is(err, profiler.ErrUnsupportedModel):
+		return "unsupported_model"
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return "not_installed"
+	case errors.Is(err, errNoLauncher):
+		return "no_launcher"
+	case errors.Is(err, supervisor.ErrPortInUse):
+		return "port_in_use"
+	case errors.Is(err, supervisor.ErrWorkerNeverReady):
+		return "never_ready"
+	}
+	return "exited"
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"testing"
+)
+
+func TestNewFallbackWorkerSkipsEnginesWithoutLauncher(t *testing.T) {
+	t.Setenv("BOTFRAMEWORK_BOOTSTRAP", "off")
+	t.Setenv("BOTFRAMEWORK_LLAMA_SERVER", "/nonexistent/llama-server")
+	t.Setenv("BOTFRAMEWORK_PYTHON", "")
+	base := supervisor.NewPythonWorker("worker/main.py", "8081")
+	base.Env = []string{"A=1"}
+	profile := &profiler.HardwareProfile{}
+
+	for _, next := range []profiler.Engine{profiler.EngineExLlamaV2, profiler.EngineIPEXLLM, profiler.EngineLlamaCPPSYCL} {
+		if _, err := newFallbackWorker(profile, next, base, nil); !errors.Is(err, errNoLauncher) {
+			t.Errorf("%s: err = %v, want errNoLauncher", next, err)
+		}
+	}
+
+	worker, err := newFallbackWorker(profile, profiler.EngineLlamaCPP, base, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	python, ok := worker.(*supervisor.PythonWorker)
+	if !ok {
+		t.Fatalf("llama_cpp without llama-server: worker = %T, want the Python worker", worker)
+	}
+	if !slices.Equal(python.Env, []string{"A=1"}) || os.Getenv("BOTFRAMEWORK_PYTHON") != "" {
+		t.Errorf("env = %v, BOTFRAMEWORK_PYTHON = %q", python.Env, os.Getenv("BOTFRAMEWORK_PYTHON"))
+	}
+}
+
+func TestEngineFallbackChain(t *testing.T) {
+	cuda := &profiler.HardwareProfile{HasCuda: true}
+	for _, tc := range []struct {
+		spec    string
+		profile *profiler.HardwareProfile
+		want    []profiler.Engine
+		wantErr bool
+	}{
+		{"", cuda, []profiler.Engine{profiler.EngineExLlamaV2, profiler.EngineLlamaCPP}, false},
+		{"auto", &profiler.HardwareProfile{}, []profiler.Engine{profiler.EngineLlamaCPP}, false},
+		{"", nil, nil, false},
+		{"off", cuda, nil, false},
+		{"llama_cpp_sycl, llama_cpp", cuda, []profiler.Engine{profiler.EngineLlamaCPPSYCL, profiler.EngineLlamaCPP}, false},
+		{"tensorrt", cuda, nil, true},
+	} {
+		t.Setenv("BOTFRAMEWORK_ENGINE_FALLBACK", tc.spec)
+		chain, err := engineFallbackChain(tc.profile, profiler.EngineVLLM)
+		if (err != nil) != tc.wantErr || !slices.Equal(chain, tc.want) {
+			t.Errorf("%q: chain = %v, err = %v; want %v", tc.spec, chain, err, tc.want)
+		}
+	}
+}
+
+func TestStartDefaultEngineSkipsFallbacksWithoutLauncher(t *testing.T) {
+	t.Setenv("BOTFRAMEWORK_BOOTSTRAP", "off")
+	t.Setenv("BOTFRAMEWORK_FAILURE_FEEDBACK", "off")
+	t.Setenv("BOTFRAMEWORK_ENGINE_FALLBACK", "exllamav2,llama_cpp_sycl")
+	launchErr := errors.New("python not found")
+	base := supervisor.NewPythonWorker("worker/main.py", "8081")
+	base.Command = func(context.Context) (*exec.Cmd, error) { return nil, launchErr }
+	manager := &engine.ModelManager{Engine: base, Profile: &profiler.HardwareProfile{HasCuda: true}, Backend: profiler.EngineLlamaCPP}
+
+	err := startDefaultEngine(context.Background(), manager, base, nil, idleConfig{})
+	if !errors.Is(err, launchErr) || !errors.Is(err, errNoLauncher) {
+		t.Fatalf("err = %v, want the launch error and errNoLauncher for each fallback", err)
+	}
+	if manager.Engine != base || manager.Backend != profiler.EngineLlamaCPP {
+		t.Errorf("engine = %T %s, want the preferred worker kept", manager.Engine, manager.Backend)
+	}
+}
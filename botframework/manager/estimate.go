@@ -0,0 +1,94 @@
+package main
+
+import (
+	"botframework/profiler"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// variantEstimate is one variant's memory budget in `manager estimate --json` output
+type variantEstimate struct {
+	Model  string                `json:"model"`
+	Quant  string                `json:"quant"`
+	Score  float64               `json:"score"`
+	Reason string                `json:"reason"`
+	Budget profiler.MemoryBudget `json:"budget"`
+}
+
+// runEstimate prints the memory budget of a registry model on this host (or the one
+// BOTFRAMEWORK_SIMULATE_PROFILE simulates): weights, KV cache, activations and OS buffer
+// against the memory available, with a pass or fail naming what limits it. Without a
+// quant every variant is estimated. It exits non-zero when none fits.
+func runEstimate(args []string) error {
+	workload := recommendationRequest()
+	fs := flag.NewFlagSet("estimate", flag.ExitOnError)
+	tokens := fs.Int("context", workload.ContextLength, "context length in tokens (default: BOTFRAMEWORK_CONTEXT_LENGTH, else 4096)")
+	concurrency := fs.Int("concurrency", workload.Concurrency, "sequences served at once, each with its own KV cache")
+	asJSON := fs.Bool("json", false, "print the budgets as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: manager estimate [--context 8192] [--concurrency 1] [--json] <model id[:quant]>")
+	}
+	workload.ContextLength, workload.Concurrency = *tokens, *concurrency
+
+	id, quant, _ := strings.Cut(fs.Arg(0), ":")
+	model := loadRegistry().Lookup(id)
+	if model == nil {
+		return fmt.Errorf("%q is not a registry model", id)
+	}
+	var variants []profiler.Variant
+	for _, variant := range model.Variants {
+		if quant == "" || strings.EqualFold(variant.Quant, quant) {
+			variants = append(variants, variant)
+		}
+	}
+	if len(variants) == 0 {
+		return fmt.Errorf("model %s has no %s variant", model.ID, quant)
+	}
+
+	profile, simulated, err := hardwareProfile()
+	if err != nil {
+		return err
+	}
+	reserveEmbeddingMemory(profile)
+
+	var estimates []variantEstimate
+	fits := false
+	for _, variant := range variants {
+		score, reason := profile.CalculateScoreFor(*model, variant, workload)
+		budget := profile.Budget(*model, variant, workload)
+		fits = fits || budget.Fits
+		estimates = append(estimates, variantEstimate{Model: model.ID, Quant: variant.Quant, Score: score, Reason: reason, Budget: budget})
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(estimates); err != nil {
+			return err
+		}
+	} else {
+		host := "this host"
+		if simulated != "" {
+			host = "simulated " + simulated
+		}
+		fmt.Printf("%s on %s (%s)\n", model.ID, host, profile)
+		for _, estimate := range estimates {
+			fmt.Printf("\n%s (score %.1f)\n", estimate.Quant, estimate.Score)
+			if workload.ContextLength > estimate.Budget.ContextTokens {
+				fmt.Printf("  context capped at the model's %d-token window\n", estimate.Budget.ContextTokens)
+			}
+			estimate.Budget.Write(os.Stdout)
+		}
+	}
+	if !fits {
+		return fmt.Errorf("no variant of %s fits", model.ID)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"botframework/eval"
+	"botframework/profiler"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// minEvalSamples is the smallest run whose score replaces a published benchmark number
+const minEvalSamples = 10
+
+// runEval benchmarks a loaded model and records the scores for the model registry
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	url := fs.String("url", "http://127.0.0.1:8080", "target base URL")
+	model := fs.String("model", "", "model to evaluate")
+	apiKey := fs.String("api-key", "", "bearer token for the target")
+	suites := fs.String("suite", "mmlu,gsm8k", "comma-separated built-in samples to run (mmlu, gsm8k)")
+	mmluFile := fs.String("mmlu", "", "MMLU-format JSONL subset to run instead of the built-in sample")
+	gsm8kFile := fs.String("gsm8k", "", "GSM8K-format JSONL subset to run instead of the built-in sample")
+	customFile := fs.String("custom", "", "custom prompt suite (JSONL of prompt/expected/match)")
+	limit := fs.Int("limit", 0, "run only the first N questions of each suite")
+	concurrency := fs.Int("concurrency", 1, "number of concurrent requests")
+	record := fs.String("record", measurementsPath(), "measurements file to update (empty to skip)")
+	verbose := fs.Bool("verbose", false, "include per-question replies in the report")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *model == "" {
+		return errors.New("--model is required")
+	}
+
+	files := map[string]string{eval.KindMMLU: *mmluFile, eval.KindGSM8K: *gsm8kFile, eval.KindCustom: *customFile}
+	var selected []*eval.Suite
+	for _, kind := range []string{eval.KindMMLU, eval.KindGSM8K, eval.KindCustom} {
+		var suite *eval.Suite
+		var err error
+		switch {
+		case files[kind] != "":
+			suite, err = eval.LoadSuite(kind, files[kind])
+		case kind != eval.KindCustom && containsName(*suites, kind):
+			suite, err = eval.Builtin(kind)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		selected = append(selected, suite)
+	}
+	if len(selected) == 0 {
+		return errors.New("no suites selected")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	opts := eval.Options{BaseURL: *url, Model: *model, APIKey: *apiKey, Limit: *limit, Concurrency: *concurrency}
+	var results []eval.Result
+	for _, suite := range selected {
+		questions := len(suite.Items)
+		if *limit > 0 {
+			questions = min(questions, *limit)
+		}
+		fmt.Fprintf(os.Stderr, "🧪 Running %s (%d questions) against %s\n", suite.Name, questions, *model)
+		result := eval.Run(ctx, suite, opts)
+		fmt.Fprintf(os.Stderr, "   %s: %.1f%% (%d/%d, %d errors)\n", suite.Kind, result.Score, result.Correct, result.Total, result.Errors)
+		if !*verbose {
+			result.Items = nil
+		}
+		results = append(results, result)
+	}
+
+	if *record != "" {
+		if err := recordResults(*record, *model, results); err != nil {
+			return err
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// recordResults stores each suite score under the model name. Custom suites are keyed
+// by file name so several prompt suites can be tracked side by side.
+func recordResults(path, model string, results []eval.Result) error {
+	measurements, err := profiler.LoadMeasurements(path)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Total == 0 {
+			continue
+		}
+		benchmark := result.Kind
+		if benchmark == eval.KindCustom {
+			benchmark = "custom:" + strings.TrimSuffix(filepath.Base(result.Suite), filepath.Ext(result.Suite))
+		}
+		measurements.Record(model, benchmark, result.Score, result.Total-result.Errors)
+	}
+	if err := measurements.Save(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "📝 Recorded scores in %s\n", path)
+	return nil
+}
+
+// measurementsPath returns BOTFRAMEWORK_MEASUREMENTS_PATH or the per-user default
+func measurementsPath() string {
+	if path := os.Getenv("BOTFRAMEWORK_MEASUREMENTS_PATH"); path != "" {
+		return path
+	}
+	return profiler.DefaultMeasurementsPath()
+}
+
+// loadRegistry reads the model registry (the remote copy when BOTFRAMEWORK_REGISTRY_URL is
+// set, else BOTFRAMEWORK_REGISTRY_PATH) with published benchmark numbers replaced by scores
+// recorded by `manager eval` on this host, the throughput probed at startup attached, and
+// the variants `manager convert` made added
+func loadRegistry() *profiler.ModelRegistry {
+	var registry *profiler.ModelRegistry
+	if source := registrySource(); source != nil {
+		registry = source.Current()
+	}
+	if registry == nil {
+		registryPath := os.Getenv("BOTFRAMEWORK_REGISTRY_PATH")
+		if registryPath == "" {
+			registryPath = "profiler/model_classification.json"
+		}
+		var err error
+		if registry, err = profiler.LoadRegistry(registryPath); err != nil {
+			slog.Warn("model registry unavailable", "err", err)
+			return &profiler.ModelRegistry{}
+		}
+	}
+
+	if local, err := profiler.LoadLocalVariants(localVariantsPath()); err != nil {
+		slog.Warn("ignoring converted variants", "err", err)
+	} else if added := local.Apply(registry); added > 0 {
+		slog.Info("using converted variants", "variants", added)
+	}
+
+	measurements, err := profiler.LoadMeasurements(measurementsPath())
+	if err != nil {
+		slog.Warn("ignoring measured benchmark scores", "err", err)
+		return registry
+	}
+	if applied := measurements.Apply(registry, minEvalSamples); applied > 0 {
+		slog.Info("using locally measured benchmark scores", "scores", applied)
+	}
+	if applied := measurements.ApplySpeed(registry); applied > 0 {
+		slog.Info("using locally measured throughput", "variants", applied)
+	}
+	if applied := measurements.ApplyFailures(registry); applied > 0 {
+		slog.Info("penalising variants that failed to load here", "failures", applied)
+	}
+	return registry
+}
+
+func containsName(list, name string) bool {
+	for _, item := range strings.Split(list, ",") {
+		if strings.TrimSpace(item) == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// recordLoad remembers how loading the model at path on this host went, with the
+// measured benchmark scores. A worker that ran out of memory or failed to load its model
+// is recorded against its variant, context and hardware, and recommendations penalise or
+// rule that combination out; a successful load clears the failures it disproves.
+// BOTFRAMEWORK_FAILURE_FEEDBACK=off turns this off.
+func recordLoad(profile *profiler.HardwareProfile, path string, loadErr error) {
+	if path == "" || profile == nil || os.Getenv("BOTFRAMEWORK_FAILURE_FEEDBACK") == "off" {
+		return
+	}
+	kind := ""
+	switch {
+	case errors.Is(loadErr, supervisor.ErrOutOfMemory):
+		kind = profiler.FailureOOM
+	case errors.Is(loadErr, supervisor.ErrModelLoadFailed):
+		kind = profiler.FailureLoadFailed
+	case loadErr != nil:
+		// failures unrelated to the model, such as a missing binary, say nothing about it
+		return
+	}
+
+	name := variantName(path)
+	contextTokens := loadContext(profile)
+	hardware := profile.Fingerprint()
+	measurementsFile := measurementsPath()
+	measurements, err := profiler.LoadMeasurements(measurementsFile)
+	if err != nil {
+		slog.Warn("load outcome not recorded", "path", measurementsFile, "err", err)
+		return
+	}
+	if kind == "" {
+		if !measurements.ClearFailures(name, contextTokens, hardware) {
+			return
+		}
+	} else {
+		slog.Warn("recording load failure", "model", name, "kind", kind, "context", contextTokens, "hardware", hardware)
+		measurements.RecordFailure(name, contextTokens, hardware, kind)
+	}
+	if err := measurements.Save(measurementsFile); err != nil {
+		slog.Warn("load outcome not recorded", "path", measurementsFile, "err", err)
+		return
+	}
+	current.Store(loadRegistry())
+}
+
+// recordDefaultLoad records how the default worker's model loaded; remote and cluster
+// workers run on other hardware and are left out
+func recordDefaultLoad(manager *engine.ModelManager, loadErr error) {
+	switch manager.Engine.(type) {
+	case *supervisor.RemoteEngine, *supervisor.ClusterWorker:
+		return
+	}
+	recordLoad(manager.Profile, os.Getenv("BOTFRAMEWORK_MODEL_PATH"), loadErr)
+}
+
+// variantName names the model at path the way registry lookups match it: "<id>:<quant>"
+// for a downloaded model in the cache, else its file name
+func variantName(path string) string {
+	cacheDir, _ := filepath.Abs(modelCacheDir())
+	abs, _ := filepath.Abs(path)
+	if rel, err := filepath.Rel(cacheDir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		if parts := strings.Split(rel, string(filepath.Separator)); len(parts) > 1 {
+			return parts[0] + ":" + parts[1]
+		}
+	}
+	return filepath.Base(path)
+}
+
+// loadContext is the context workers are launched with: the tier's, else the workload's,
+// else the scorer's default
+func loadContext(profile *profiler.HardwareProfile) int {
+	if defaults, tiered := tierDefaults(profile); tiered && defaults.ContextSize > 0 {
+		return defaults.ContextSize
+	}
+	if tokens := recommendationRequest().ContextLength; tokens > 0 {
+		return tokens
+	}
+	return profiler.DefaultScoringContext
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"botframework/files"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// newFileStore opens the /v1/files store in BOTFRAMEWORK_FILES_DIR (default: the user cache
+// directory). BOTFRAMEWORK_FILES_MAX_MB caps single uploads, BOTFRAMEWORK_FILES_QUOTA_MB the
+// storage per API key, and BOTFRAMEWORK_FILES_TTL expires uploads without an explicit expiry.
+func newFileStore() (*files.Store, error) {
+	dir := os.Getenv("BOTFRAMEWORK_FILES_DIR")
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			cache = os.TempDir()
+		}
+		dir = filepath.Join(cache, "botframework", "files")
+	}
+	store, err := files.NewStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if mb, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_FILES_MAX_MB")); err == nil && mb >= 0 {
+		store.MaxFileBytes = int64(mb) << 20
+	}
+	if mb, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_FILES_QUOTA_MB")); err == nil && mb >= 0 {
+		store.QuotaBytes = int64(mb) << 20
+	}
+	if raw := os.Getenv("BOTFRAMEWORK_FILES_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			slog.Warn("invalid BOTFRAMEWORK_FILES_TTL, keeping files until deleted", "ttl", raw, "err", err)
+		} else {
+			store.DefaultTTL = ttl
+		}
+	}
+	slog.Info("storing uploaded files", "dir", dir)
+	return store, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"botframework/profiler"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// parseFlags applies the manager's command-line options. Each one sets the environment
+// variable of the matching setting, so flags win over botframework.yaml and the environment.
+//
+//	--engine NAME    BOTFRAMEWORK_ENGINE, skipping the hardware recommendation
+//	--port N         BOTFRAMEWORK_LISTEN=:N
+//	--model PATH     BOTFRAMEWORK_MODEL_PATH
+//	--registry PATH  BOTFRAMEWORK_REGISTRY_PATH
+//	--context N      BOTFRAMEWORK_CONTEXT_LENGTH, the context recommendations leave KV cache room for
+//	--config PATH    BOTFRAMEWORK_CONFIG
+//	--profile-only   print the hardware profile and recommendations as JSON and exit
+//	--force-redetect BOTFRAMEWORK_FORCE_REDETECT, profiling the hardware instead of using the cached profile
+//	--simulate-profile NAME|PATH
+//	                 BOTFRAMEWORK_SIMULATE_PROFILE, --profile-only for a preset machine or a
+//	                 JSON or YAML hardware spec instead of this host
+func parseFlags(args []string) (profileOnly bool, err error) {
+	fs := flag.NewFlagSet("manager", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: manager [flags]\n       manager top|replay|eval|download|bootstrap [flags]\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	engine := fs.String("engine", "", fmt.Sprintf("force an inference backend: %v", profiler.Engines))
+	port := fs.Int("port", 0, "serve the public API on this port")
+	model := fs.String("model", "", "model file to load")
+	registry := fs.String("registry", "", "model registry JSON")
+	context := fs.Int("context", 0, "context length the model recommendations plan for")
+	configPath := fs.String("config", "", "configuration file (default: ./botframework.yaml when present)")
+	fs.BoolVar(&profileOnly, "profile-only", false, "print the hardware profile and recommendations as JSON and exit")
+	redetect := fs.Bool("force-redetect", false, "detect the hardware again instead of using the cached profile")
+	simulate := fs.String("simulate-profile", "", fmt.Sprintf("like --profile-only, for a preset %v or a JSON/YAML hardware spec file", slices.Sorted(maps.Keys(profiler.SpecPresets))))
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if fs.NArg() > 0 {
+		return false, fmt.Errorf("unknown command %q", fs.Arg(0))
+	}
+	if *port != 0 && (*port < 1 || *port > 65535) {
+		return false, fmt.Errorf("--port: %d is not a valid port", *port)
+	}
+
+	overrides := map[string]string{
+		"BOTFRAMEWORK_ENGINE":        *engine,
+		"BOTFRAMEWORK_MODEL_PATH":    *model,
+		"BOTFRAMEWORK_REGISTRY_PATH": *registry,
+		"BOTFRAMEWORK_CONFIG":        *configPath,
+		// simulated hardware cannot run workers, so it only changes what is printed
+		"BOTFRAMEWORK_SIMULATE_PROFILE": *simulate,
+	}
+	if *redetect {
+		overrides["BOTFRAMEWORK_FORCE_REDETECT"] = "true"
+	}
+	if *context > 0 {
+		overrides["BOTFRAMEWORK_CONTEXT_LENGTH"] = strconv.Itoa(*context)
+	}
+	if *port != 0 {
+		overrides["BOTFRAMEWORK_LISTEN"] = ":" + strconv.Itoa(*port)
+	}
+	for name, value := range overrides {
+		if value != "" {
+			os.Setenv(name, value)
+		}
+	}
+	return profileOnly || *simulate != "", nil
+}
+
+type profileReport struct {
+	Profile *profiler.HardwareProfile `json:"profile"`
+	// Simulated names the preset or spec file the profile was simulated from
+	Simulated   string          `json:"simulated,omitempty"`
+	Tier        profiler.Tier   `json:"tier"`
+	ModelSizeGB float64         `json:"model_size_gb"`
+	Engine      profiler.Engine `json:"engine"`
+	// Workload is what the models below are ranked for
+	Workload profiler.RecommendationRequest `json:"workload"`
+	// Overridden is set when the engine was forced rather than recommended
+	Overridden bool                  `json:"overridden"`
+	Models     []modelRecommendation `json:"models"`
+	// EmbeddingModels are ranked on their own; the chat models above leave room for the
+	// one BOTFRAMEWORK_EMBEDDING_MODEL configures
+	EmbeddingModels []modelRecommendation `json:"embedding_models"`
+}
+
+type modelRecommendation struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Quant          string  `json:"quant"`
+	SizeGB         float64 `json:"size_gb"`
+	Score          float64 `json:"score"`
+	Reason         string  `json:"reason"`
+	RelativeEnergy float64 `json:"relative_energy"`
+}
+
+// printProfile writes the hardware profile, the engine the manager would run and the ranked
+// registry models to w as JSON. Progress messages go to stderr so the output stays parseable.
+func printProfile(w io.Writer) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	profile, simulated, err := hardwareProfile()
+	if err != nil {
+		return err
+	}
+	applyModelPolicy(profile)
+	report := profileReport{
+		Profile:     profile,
+		Simulated:   simulated,
+		Tier:        profile.ClassifyTier(),
+		ModelSizeGB: cfg.Engine.ModelSizeGB,
+		Workload:    recommendationRequest(),
+		Engine:      profile.GetRecommendedEngine(cfg.Engine.ModelSizeGB),
+		Models:      []modelRecommendation{},
+	}
+	if cfg.Engine.Override != "" {
+		report.Engine, report.Overridden = profiler.Engine(cfg.Engine.Override), true
+	}
+	registry := loadRegistry()
+	report.EmbeddingModels = embeddingRecommendations(profile, registry)
+	reserveEmbeddingMemory(profile)
+	for _, ranked := range profile.Recommend(registry, report.Workload) {
+		report.Models = append(report.Models, modelRecommendation{
+			ID:             ranked.ModelID,
+			Name:           ranked.ModelName,
+			Quant:          ranked.Variant.Quant,
+			SizeGB:         ranked.Variant.SizeGB,
+			Score:          ranked.Score,
+			Reason:         ranked.Reason,
+			RelativeEnergy: ranked.RelativeEnergy,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
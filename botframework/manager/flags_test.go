@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// flagEnv lists the variables parseFlags may set
+var flagEnv = []string{
+	"BOTFRAMEWORK_ENGINE",
+	"BOTFRAMEWORK_LISTEN",
+	"BOTFRAMEWORK_MODEL_PATH",
+	"BOTFRAMEWORK_REGISTRY_PATH",
+	"BOTFRAMEWORK_CONTEXT_LENGTH",
+	"BOTFRAMEWORK_CONFIG",
+	"BOTFRAMEWORK_FORCE_REDETECT",
+	"BOTFRAMEWORK_SIMULATE_PROFILE",
+}
+
+// restoreEnv puts the whole environment back when the test ends, for code that exports
+// settings with os.Setenv
+func restoreEnv(t *testing.T) {
+	saved := os.Environ()
+	t.Cleanup(func() {
+		os.Clearenv()
+		for _, entry := range saved {
+			name, value, _ := strings.Cut(entry, "=")
+			os.Setenv(name, value)
+		}
+	})
+}
+
+func TestParseFlagsSetsEnvironment(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		env         map[string]string
+		profileOnly bool
+	}{
+		{name: "none"},
+		{
+			name: "engine and port",
+			args: []string{"--engine", "vllm", "--port", "9000"},
+			env:  map[string]string{"BOTFRAMEWORK_ENGINE": "vllm", "BOTFRAMEWORK_LISTEN": ":9000"},
+		},
+		{
+			name: "paths",
+			args: []string{"--model", "/models/phi-3.gguf", "--registry", "reg.json", "--config", "bf.yaml"},
+			env: map[string]string{
+				"BOTFRAMEWORK_MODEL_PATH":    "/models/phi-3.gguf",
+				"BOTFRAMEWORK_REGISTRY_PATH": "reg.json",
+				"BOTFRAMEWORK_CONFIG":        "bf.yaml",
+			},
+		},
+		{
+			name: "context and redetect",
+			args: []string{"--context", "8192", "--force-redetect"},
+			env:  map[string]string{"BOTFRAMEWORK_CONTEXT_LENGTH": "8192", "BOTFRAMEWORK_FORCE_REDETECT": "true"},
+		},
+		{name: "profile only", args: []string{"--profile-only"}, profileOnly: true},
+		{
+			name:        "simulated profile implies profile only",
+			args:        []string{"--simulate-profile", "rtx-4090"},
+			env:         map[string]string{"BOTFRAMEWORK_SIMULATE_PROFILE": "rtx-4090"},
+			profileOnly: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range flagEnv {
+				t.Setenv(name, "")
+			}
+			profileOnly, err := parseFlags(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if profileOnly != tt.profileOnly {
+				t.Errorf("profileOnly = %v, want %v", profileOnly, tt.profileOnly)
+			}
+			for _, name := range flagEnv {
+				if got := os.Getenv(name); got != tt.env[name] {
+					t.Errorf("%s = %q, want %q", name, got, tt.env[name])
+				}
+			}
+		})
+	}
+}
+
+func TestParseFlagsRejectsBadArguments(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--port", "70000"}, "not a valid port"},
+		{[]string{"--port", "-1"}, "not a valid port"},
+		{[]string{"serve"}, `unknown command "serve"`},
+		{[]string{"--no-such-flag"}, "flag provided but not defined"},
+	}
+	for _, tt := range tests {
+		for _, name := range flagEnv {
+			t.Setenv(name, "")
+		}
+		_, err := parseFlags(tt.args)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: err %v, want %q", tt.args, err, tt.want)
+		}
+		if got := os.Getenv("BOTFRAMEWORK_LISTEN"); got != "" {
+			t.Errorf("%v: BOTFRAMEWORK_LISTEN = %q after a rejected command line", tt.args, got)
+		}
+	}
+}
+
+func TestPrintProfileSimulated(t *testing.T) {
+	restoreEnv(t)
+	for _, name := range flagEnv {
+		t.Setenv(name, "")
+	}
+	t.Setenv("BOTFRAMEWORK_REGISTRY_URL", "")
+	if _, err := parseFlags([]string{"--simulate-profile", "rtx-4090", "--registry", "../profiler/model_classification.json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := printProfile(&out); err != nil {
+		t.Fatal(err)
+	}
+	var report profileReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("output is not a profile report: %v\n%s", err, out.String())
+	}
+	if report.Simulated != "rtx-4090" {
+		t.Errorf("simulated = %q, want rtx-4090", report.Simulated)
+	}
+	if report.Profile == nil || report.Engine == "" || report.Tier == "" {
+		t.Errorf("incomplete report: %s", out.String())
+	}
+	if report.Overridden {
+		t.Error("engine reported as overridden without --engine")
+	}
+	if len(report.Models) == 0 {
+		t.Errorf("no models recommended for an RTX 4090 from the bundled registry")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"botframework/profiler"
+	"botframework/supervisor"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadGPUAssignments reads BOTFRAMEWORK_WORKER_GPUS, which pins workers to GPUs by the name
+// their model is served under ("default" for the default worker), e.g.
+// "default=0;fast=1;quality=2,3", and checks it against the GPUs in profile
+func loadGPUAssignments(profile *profiler.HardwareProfile) (profiler.GPUAssignments, error) {
+	assignments, err := profiler.ParseGPUAssignments(os.Getenv("BOTFRAMEWORK_WORKER_GPUS"))
+	if err != nil || len(assignments) == 0 {
+		return assignments, err
+	}
+	if profile == nil {
+		return nil, errors.New("no hardware profile to check GPU assignments against")
+	}
+	if err := profile.ValidateGPUAssignments(assignments); err != nil {
+		return nil, err
+	}
+	if _, ok := assignments[profiler.DefaultWorkerGPUs]; ok && os.Getenv("BOTFRAMEWORK_MIG_DEVICE") != "" {
+		slog.Warn("BOTFRAMEWORK_WORKER_GPUS overrides BOTFRAMEWORK_MIG_DEVICE for the default worker")
+	}
+	return assignments, nil
+}
+
+// pinGPUs limits the workers behind e to devices. Cluster and remote workers run elsewhere
+// and are left alone.
+func pinGPUs(name string, e any, devices []int) {
+	switch worker := e.(type) {
+	case *supervisor.PythonWorker:
+		worker.GPUs = devices
+	case *supervisor.LlamaCppWorker:
+		worker.GPUs = devices
+	case *supervisor.VLLMWorker:
+		worker.GPUs = devices
+	case *supervisor.GrpcWorker:
+		worker.GPUs = devices
+	case *supervisor.DockerWorker:
+		ids := make([]string, len(devices))
+		for i, device := range devices {
+			ids[i] = strconv.Itoa(device)
+		}
+		worker.GPUs = strings.Join(ids, ",")
+	case *supervisor.WorkerPool:
+		for _, member := range worker.Workers() {
+			pinGPUs(name, member, devices)
+		}
+		return
+	default:
+		slog.Warn("GPU assignment ignored: the worker does not run on this host", "model", name)
+		return
+	}
+	slog.Info("pinning worker to GPUs", "model", name, "gpus", fmt.Sprint(devices))
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"botframework/supervisor"
+	"log/slog"
+	"os"
+)
+
+// useGrpcWorkers reports whether BOTFRAMEWORK_WORKER_PROTOCOL selects the gRPC protocol for
+// Python workers (http, the default, proxies requests to the worker's HTTP API)
+func useGrpcWorkers() bool {
+	switch protocol := os.Getenv("BOTFRAMEWORK_WORKER_PROTOCOL"); protocol {
+	case "", "http":
+		return false
+	case "grpc":
+		return true
+	default:
+		slog.Warn("unknown worker protocol, using http", "protocol", protocol)
+		return false
+	}
+}
+
+// newGrpcWorker replaces a Python worker with one speaking gRPC on the same port
+func newGrpcWorker(worker *supervisor.PythonWorker) *supervisor.GrpcWorker {
+	grpcWorker := supervisor.NewGrpcWorker(worker.ScriptPath, worker.Port)
+	grpcWorker.ModelPath = worker.ModelPath
+	grpcWorker.Env = worker.Env
+	grpcWorker.Args = worker.Args
+	grpcWorker.GPUs = worker.GPUs
+	grpcWorker.Warmup = worker.Warmup
+	slog.Info("worker speaks gRPC", "port", worker.Port)
+	return grpcWorker
+}
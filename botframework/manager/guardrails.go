@@ -0,0 +1,42 @@
+package main
+
+import (
+	"botframework/chat"
+	"botframework/guardrails"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// newGuard loads the guardrails applied to prompts and completion output:
+//
+//	BOTFRAMEWORK_GUARDRAILS         JSON file with max_prompt_tokens and reject or redact rules
+//	BOTFRAMEWORK_MAX_PROMPT_TOKENS  prompt size limit, overriding the file's
+//
+// It returns nil when neither is set. A file that does not load is an error rather than
+// being skipped, so a typo never serves requests unguarded.
+func newGuard(counter chat.TokenCounter) (*guardrails.Guard, error) {
+	path := os.Getenv("BOTFRAMEWORK_GUARDRAILS")
+	limit := os.Getenv("BOTFRAMEWORK_MAX_PROMPT_TOKENS")
+	if path == "" && limit == "" {
+		return nil, nil
+	}
+	guard, err := guardrails.New(guardrails.Config{})
+	if path != "" {
+		guard, err = guardrails.Load(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if limit != "" {
+		tokens, err := strconv.Atoi(limit)
+		if err != nil || tokens < 0 {
+			return nil, fmt.Errorf("BOTFRAMEWORK_MAX_PROMPT_TOKENS: %q is not a token count", limit)
+		}
+		guard.MaxPromptTokens = tokens
+	}
+	guard.Counter = counter
+	slog.Info("applying guardrails", "path", path, "max_prompt_tokens", guard.MaxPromptTokens)
+	return guard, nil
+}
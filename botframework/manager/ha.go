@@ -0,0 +1,40 @@
+package main
+
+import (
+	"botframework/cluster"
+	"botframework/engine"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// newClusterNode joins the manager cluster when BOTFRAMEWORK_CLUSTER_DIR points at state shared
+// by every manager (e.g. an NFS mount). Returns nil when clustering is disabled.
+//
+//	BOTFRAMEWORK_CLUSTER_DIR     shared state directory
+//	BOTFRAMEWORK_NODE_ID         unique node name (default: hostname)
+//	BOTFRAMEWORK_ADVERTISE_ADDR  URL other managers use to reach this one (default: http://HOSTNAME:8080, https with TLS)
+func newClusterNode(manager *engine.ModelManager, scheme, port string) (*cluster.Node, error) {
+	dir := os.Getenv("BOTFRAMEWORK_CLUSTER_DIR")
+	if dir == "" {
+		return nil, nil
+	}
+
+	backend, err := cluster.NewFileBackend(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	id := os.Getenv("BOTFRAMEWORK_NODE_ID")
+	if id == "" {
+		id = hostname
+	}
+	addr := os.Getenv("BOTFRAMEWORK_ADVERTISE_ADDR")
+	if addr == "" {
+		addr = fmt.Sprintf("%s://%s:%s", scheme, hostname, port)
+	}
+
+	slog.Info("joining manager cluster", "node", id, "addr", addr)
+	return cluster.NewNode(id, addr, backend, manager), nil
+}
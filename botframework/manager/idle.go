@@ -0,0 +1,61 @@
+package main
+
+import (
+	"botframework/engine"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// idleConfig unloads workers that go without requests:
+//
+//	BOTFRAMEWORK_IDLE_TIMEOUT  how long a worker may sit idle, for every worker ("30m") or by
+//	                           model name ("default=30m;quality=10m"), see engine.ParseIdleTimeouts
+//	BOTFRAMEWORK_COLD_START    queue | loading, what requests for an unloaded model get while
+//	                           its worker starts again (default: queue)
+type idleConfig struct {
+	timeouts  map[string]time.Duration
+	coldStart engine.ColdStart
+}
+
+func loadIdleConfig() (idleConfig, error) {
+	timeouts, err := engine.ParseIdleTimeouts(os.Getenv("BOTFRAMEWORK_IDLE_TIMEOUT"))
+	if err != nil {
+		return idleConfig{}, err
+	}
+	config := idleConfig{timeouts: timeouts, coldStart: engine.ColdStartQueue}
+	switch mode := engine.ColdStart(os.Getenv("BOTFRAMEWORK_COLD_START")); mode {
+	case "":
+	case engine.ColdStartQueue, engine.ColdStartLoading:
+		config.coldStart = mode
+	default:
+		return idleConfig{}, fmt.Errorf("BOTFRAMEWORK_COLD_START: %q is not queue or loading", mode)
+	}
+	return config, nil
+}
+
+// timeout returns how long the worker serving name may sit idle, 0 for as long as it likes
+func (c idleConfig) timeout(name string) (time.Duration, bool) {
+	if timeout, ok := c.timeouts[name]; ok {
+		return timeout, true
+	}
+	timeout, ok := c.timeouts[engine.AllModels]
+	return timeout, ok
+}
+
+// unloadWhenIdle hands the worker serving name to the manager's idle unloader
+func (c idleConfig) unloadWhenIdle(manager *engine.ModelManager, name string, e engine.InferenceEngine) {
+	timeout, ok := c.timeout(name)
+	if !ok || timeout == 0 {
+		return
+	}
+	if err := manager.SetIdlePolicy(name, e, engine.IdlePolicy{Timeout: timeout, ColdStart: c.coldStart}); err != nil {
+		// a blanket timeout quietly skips remote and pooled workers
+		if _, named := c.timeouts[name]; named {
+			slog.Warn("idle timeout ignored", "model", name, "err", err)
+		}
+		return
+	}
+	slog.Info("unloading worker when idle", "model", name, "timeout", timeout, "cold_start", c.coldStart)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"botframework/listener"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Routes that move off the public listeners when a dedicated listener is configured
+var (
+	adminPrefixes   = []string{"/admin/"}
+	metricsPrefixes = []string{"/metrics", "/admin/status", "/admin/energy"}
+)
+
+// listenConfig holds the manager's bind addresses.
+//
+//	BOTFRAMEWORK_LISTEN          comma-separated public API addresses, host:port or unix:/path (default: :8080)
+//	BOTFRAMEWORK_ADMIN_LISTEN    addresses serving /admin/ routes, which are then hidden from the public listeners
+//	BOTFRAMEWORK_METRICS_LISTEN  addresses serving /metrics, /admin/status and /admin/energy
+//	BOTFRAMEWORK_REUSEPORT       on to set SO_REUSEPORT so several gateway processes can share a port
+//	BOTFRAMEWORK_SHUTDOWN_TIMEOUT  how long in-flight requests get to finish on shutdown (default: 5s)
+//
+// TLS settings are read by loadTLSConfig.
+type listenConfig struct {
+	public, admin, metrics []listener.Addr
+	reusePort              bool
+	shutdownTimeout        time.Duration
+	// tls is nil when the listeners serve plain HTTP
+	tls *tlsConfig
+}
+
+func loadListenConfig() (listenConfig, error) {
+	var config listenConfig
+	var err error
+	public := os.Getenv("BOTFRAMEWORK_LISTEN")
+	if public == "" {
+		public = ":8080"
+	}
+	if config.public, err = listener.ParseList(public); err != nil {
+		return config, err
+	}
+	if len(config.public) == 0 {
+		return config, fmt.Errorf("BOTFRAMEWORK_LISTEN has no addresses")
+	}
+	if config.admin, err = listener.ParseList(os.Getenv("BOTFRAMEWORK_ADMIN_LISTEN")); err != nil {
+		return config, err
+	}
+	if config.metrics, err = listener.ParseList(os.Getenv("BOTFRAMEWORK_METRICS_LISTEN")); err != nil {
+		return config, err
+	}
+	if config.tls, err = loadTLSConfig(); err != nil {
+		return config, err
+	}
+	config.reusePort = os.Getenv("BOTFRAMEWORK_REUSEPORT") == "on"
+	config.shutdownTimeout = 5 * time.Second
+	if timeout, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_SHUTDOWN_TIMEOUT")); err == nil && timeout > 0 {
+		config.shutdownTimeout = timeout
+	}
+	return config, nil
+}
+
+// selfPort returns the port of the first public TCP listener, which the manager uses to call
+// its own routes (summaries, embeddings) and to advertise itself
+func (c listenConfig) selfPort() string {
+	if port := c.publicPort(); port != "" {
+		return port
+	}
+	slog.Warn("no public TCP listener; summaries, embeddings and discovery assume port 8080")
+	return "8080"
+}
+
+func (c listenConfig) publicPort() string {
+	for _, addr := range c.public {
+		if port := addr.Port(); port != "" {
+			return port
+		}
+	}
+	return ""
+}
+
+// selfScheme is the scheme other hosts reach the public listeners with
+func (c listenConfig) selfScheme() string {
+	if c.tls != nil {
+		return "https"
+	}
+	return "http"
+}
+
+type binding struct {
+	role    string
+	addr    listener.Addr
+	handler http.Handler
+}
+
+// bindings assigns each configured address the routes it serves
+func (c listenConfig) bindings(mux http.Handler) []binding {
+	var hidden, adminHidden []string
+	if len(c.metrics) > 0 {
+		hidden = append(hidden, metricsPrefixes...)
+		adminHidden = metricsPrefixes
+	}
+	if len(c.admin) > 0 {
+		hidden = append(hidden, adminPrefixes...)
+	}
+
+	var out []binding
+	for _, addr := range c.public {
+		out = append(out, binding{"api", addr, listener.Except(mux, hidden...)})
+	}
+	for _, addr := range c.admin {
+		out = append(out, binding{"admin", addr, listener.Only(listener.Except(mux, adminHidden...), adminPrefixes...)})
+	}
+	for _, addr := range c.metrics {
+		out = append(out, binding{"metrics", addr, listener.Only(mux, metricsPrefixes...)})
+	}
+	if c.tls != nil {
+		for _, addr := range c.tls.redirect {
+			out = append(out, binding{"redirect", addr, listener.Redirect(c.publicPort(), c.tls.acme)})
+		}
+	}
+	return out
+}
+
+// serve runs mux on every configured listener until ctx is cancelled or one of them fails
+func serve(ctx context.Context, config listenConfig, mux http.Handler) error {
+	bindings := config.bindings(mux)
+	servers := make([]*http.Server, 0, len(bindings))
+	errs := make(chan error, len(bindings))
+	// shutdown stops accepting connections and waits up to shutdownTimeout for in-flight
+	// requests, streamed responses included, on every listener at once
+	shutdown := func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), config.shutdownTimeout)
+		defer shutdownCancel()
+		var wg sync.WaitGroup
+		for _, server := range servers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := server.Shutdown(shutdownCtx); err != nil {
+					slog.Error("manager shutdown error", "err", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	for _, b := range bindings {
+		ln, err := listener.Listen(ctx, b.addr, config.reusePort)
+		if err != nil {
+			shutdown()
+			return fmt.Errorf("listen %s: %w", b.addr, err)
+		}
+		secure := config.tls != nil && b.role != "redirect" && b.addr.Network == "tcp"
+		if secure {
+			ln = listener.NewTLSListener(ln, config.tls.config)
+		}
+		server := &http.Server{Handler: b.handler, ReadHeaderTimeout: 5 * time.Second}
+		servers = append(servers, server)
+		go func() { errs <- server.Serve(ln) }()
+		slog.Info("BotFramework manager listening", "addr", b.addr, "role", b.role, "tls", secure)
+	}
+	if config.tls != nil && config.tls.acme != nil {
+		// the redirect listeners are open, so the CA's challenges can be answered
+		go config.tls.acme.Run(ctx)
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down, draining requests", "timeout", config.shutdownTimeout)
+	case err = <-errs:
+	}
+	shutdown()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
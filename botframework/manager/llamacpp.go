@@ -0,0 +1,70 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"cmp"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+)
+
+// llamaServerBinary decides whether workers run llama-server instead of the Python worker.
+// BOTFRAMEWORK_WORKER_RUNTIME=llama-server always does; auto (the default) does when the
+// recommended engine is llama.cpp and the binary is installed. BOTFRAMEWORK_LLAMA_SERVER
+// names the binary, defaulting to llama-server on PATH.
+func llamaServerBinary(manager *engine.ModelManager) (string, bool) {
+	runtime := os.Getenv("BOTFRAMEWORK_WORKER_RUNTIME")
+	switch runtime {
+	case "python", "docker", "vllm", "mlx":
+		return "", false
+	case "", "auto":
+		if manager.Backend != profiler.EngineLlamaCPP {
+			return "", false
+		}
+	}
+
+	path, err := findLlamaServer()
+	if err != nil {
+		if runtime == "llama-server" {
+			slog.Warn("llama-server not found; using the Python worker", "err", err)
+		}
+		return "", false
+	}
+	return path, true
+}
+
+// findLlamaServer looks up BOTFRAMEWORK_LLAMA_SERVER, default llama-server
+func findLlamaServer() (string, error) {
+	return exec.LookPath(cmp.Or(os.Getenv("BOTFRAMEWORK_LLAMA_SERVER"), "llama-server"))
+}
+
+// newLlamaCppWorker creates a llama-server worker for modelPath in mode, sizing its flags
+// from the hardware profile, the model file and, when the registry knows it, the model's
+// architecture. Chat workers draft with the model draftModel pairs with theirs.
+func newLlamaCppWorker(binary, port, modelPath, mode string, profile *profiler.HardwareProfile) *supervisor.LlamaCppWorker {
+	sizeGB := 0.0
+	if info, err := os.Stat(modelPath); err == nil {
+		sizeGB = float64(info.Size()) / (1 << 30)
+	}
+	var model profiler.Model
+	if known, _, found := identifyModel(loadRegistry(), modelPath); found {
+		model = *known
+	}
+	flags := supervisor.LlamaCppFlagsFor(profile, sizeGB, model)
+	if profile != nil && flags.GPULayers > 0 {
+		if plan := profile.PlanOffload(model, sizeGB, flags.ContextSize); !plan.Full() {
+			slog.Info("model does not fit in VRAM; offloading part of it", "gpu_layers", plan.GPULayers, "layers", plan.Layers,
+				"decode_tps", fmt.Sprintf("%.0f", plan.DecodeTPS), "full_offload_tps", fmt.Sprintf("%.0f", plan.FullDecodeTPS))
+		}
+	}
+	if mode == "" {
+		flags.DraftModel, flags.DraftMax, _ = draftModel(profile, modelPath)
+	}
+	slog.Info("using llama-server", "binary", binary, "model", modelPath)
+	worker := supervisor.NewLlamaCppWorker(binary, port, modelPath, flags)
+	worker.Mode = mode
+	return worker
+}
@@ -1,62 +1,403 @@
 package main
 
 import (
+	"botframework/anthropic"
 	"botframework/api"
+	"botframework/audio"
+	"botframework/chat"
+	"botframework/energy"
 	"botframework/engine"
+	"botframework/gputune"
+	"botframework/logging"
+	"botframework/metrics"
+	"botframework/profiler"
+	"botframework/rag"
+	"botframework/replay"
+	"botframework/supervisor"
+	"botframework/websocket"
 	"context"
-	"errors"
-	"fmt"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 )
 
+var subcommands = map[string]func(args []string) error{
+	"top":       runTop,
+	"replay":    runReplay,
+	"eval":      runEval,
+	"download":  runDownload,
+	"convert":   runConvert,
+	"bootstrap": runBootstrap,
+	"estimate":  runEstimate,
+	"service":   runService,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := subcommands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	profileOnly, err := parseFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if profileOnly {
+		if err := printProfile(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	startedAt := time.Now()
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
+	go func() {
+		<-ctx.Done()
+		// restore default signal handling so a second Ctrl-C exits without draining
+		cancel()
+	}()
+	// Workers outlive ctx: on shutdown they are stopped only after in-flight requests drain
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
 
-	manager := engine.NewSmartManager()
+	listen, err := loadListenConfig()
+	if err != nil {
+		log.Fatalf("Invalid listen configuration: %v", err)
+	}
+	if err := reserveListenPorts(listen); err != nil {
+		log.Fatalf("Cannot listen: %v", err)
+	}
+	middlewares, err := middlewareChain()
+	if err != nil {
+		log.Fatalf("Invalid middleware configuration: %v", err)
+	}
+	authenticator, err := newAuthenticator(cfg.Manager.AdminToken)
+	if err != nil {
+		log.Fatalf("Failed to load API keys: %v", err)
+	}
+	remote, err := loadRemoteConfig()
+	if err != nil {
+		log.Fatalf("Invalid remote worker configuration: %v", err)
+	}
+	opts := managerOptions(cfg)
+	opts.Profile = detectHardware()
+	if opts.WorkerPort, err = workerPort(cfg.Worker.Port, "default worker"); err != nil {
+		log.Fatalf("Cannot start worker: %v", err)
+	}
 
-	err := manager.Engine.Start(ctx)
+	manager := engine.NewSmartManagerWith(opts)
+	detectModelDisk(manager.Profile)
+	detectPower(manager.Profile)
+	applyModelPolicy(manager.Profile)
+	configurePython(ctx, manager.Profile, manager.Backend)
+	gpus, err := loadGPUAssignments(manager.Profile)
 	if err != nil {
+		log.Fatalf("Invalid GPU assignment: %v", err)
+	}
+	idle, err := loadIdleConfig()
+	if err != nil {
+		log.Fatalf("Invalid idle unloading configuration: %v", err)
+	}
+	stores, err := newVectorStores()
+	if err != nil {
+		log.Fatalf("Failed to configure vector stores: %v", err)
+	}
+	fileStore, err := newFileStore()
+	if err != nil {
+		log.Fatalf("Failed to open file store: %v", err)
+	}
+	counter := newTokenCounter()
+	chatSessions, err := newSessions(listen.selfPort(), counter)
+	if err != nil {
+		log.Fatalf("Invalid session configuration: %v", err)
+	}
+	responseCache, err := newResponseCache()
+	if err != nil {
+		log.Fatalf("Failed to open response cache: %v", err)
+	}
+	guard, err := newGuard(counter)
+	if err != nil {
+		log.Fatalf("Failed to load guardrails: %v", err)
+	}
+	ledger, usageStore, err := newLedger()
+	if err != nil {
+		log.Fatalf("Failed to open usage store: %v", err)
+	}
+	if usageStore != nil {
+		defer usageStore.Close()
+	}
+	batches, err := newBatchRunner(manager, fileStore)
+	if err != nil {
+		log.Fatalf("Failed to open batch store: %v", err)
+	}
+
+	// the Python worker the manager starts from, which engine fallbacks are launched like
+	base, _ := manager.Engine.(*supervisor.PythonWorker)
+	configureRouting(workerCtx, manager, remote, gpus, idle)
+	slog.Debug("ports assigned", "ports", workerPorts.Assignments())
+	manager.Queue = queueConfig(manager.Backend)
+	manager.RequestTimeout = requestTimeout()
+
+	if restore := applyGPUProfile(os.Getenv("BOTFRAMEWORK_GPU_PROFILE")); restore != nil {
+		defer restore()
+	}
+
+	if err := startDefaultEngine(workerCtx, manager, base, gpus, idle); err != nil {
 		log.Fatalf("Failed to start engine: %v", err)
 	}
+	if cfg.Engine.SpeedProbe {
+		probeSpeed(ctx, manager)
+	}
+	if len(idle.timeouts) > 0 {
+		go manager.UnloadIdle(ctx)
+	}
 
 	defer func() {
-		if err := manager.Engine.Stop(); err != nil {
-			log.Printf("Error stopping engine: %v", err)
+		if err := manager.Stop(); err != nil {
+			slog.Error("error stopping engine", "err", err)
 		}
 	}()
 
+	port := listen.selfPort()
+	node, err := newClusterNode(manager, listen.selfScheme(), port)
+	if err != nil {
+		slog.Warn("clustering disabled", "err", err)
+	}
+	if node != nil {
+		go node.Run(ctx)
+	}
+
+	defer startDiscovery(ctx, manager, port)()
+
+	recorder := metrics.NewRecorder()
+	meter := newEnergyMeter()
+	go meter.Run(ctx, 5*time.Second)
+	collectors := []func(*metrics.Exposition){api.WorkerMetrics(manager), api.QueueMetrics(manager), api.HardwareMetrics(manager.Profile, manager.Backend)}
+	monitor, interval := newVRAMMonitor(manager.Profile)
+	if monitor != nil {
+		go monitor.Run(ctx, interval)
+		collectors = append(collectors, api.VRAMMetrics(monitor))
+	}
+	if responseCache != nil {
+		collectors = append(collectors, api.CacheMetrics(responseCache))
+	}
+	if guard != nil {
+		collectors = append(collectors, api.GuardrailMetrics(guard))
+	}
+
+	embedder := newEmbedder(port)
+	ingester := rag.NewIngester(ctx, stores, embedder, 2)
+	retriever := newRetriever(stores, embedder, port)
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/v1/health", api.HandleHealth(manager.Engine))
-	mux.HandleFunc("/v1/models", api.HandleModels(manager.Engine))
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Printf("📥 Request: %s %s\n", r.Method, r.URL.Path)
-		manager.Engine.ProxyRequest(w, r)
+	mux.HandleFunc("/v1/health", api.HandleHealth(manager))
+	mux.HandleFunc("/v1/models", api.HandleModels(manager))
+	mux.HandleFunc("/v1/models/{model}", api.HandleModel(manager))
+	mux.HandleFunc("/admin/status", api.HandleAdminStatus(manager, recorder, startedAt))
+	mux.HandleFunc("/metrics", recorder.PrometheusHandler(collectors...))
+	api.RegisterModelRoutes(mux, manager)
+	mux.HandleFunc("/admin/workers", api.HandleWorkers(manager))
+	mux.HandleFunc("/admin/workers/{id}", api.HandleWorker(manager))
+	mux.HandleFunc("/admin/workers/{id}/{action}", api.HandleWorker(manager))
+	mux.HandleFunc("/admin/rollouts", api.HandleRollouts(manager))
+	mux.HandleFunc("/admin/rollouts/{model}/{action}", api.HandleRolloutAction(manager))
+	mux.HandleFunc("/admin/shadows", api.HandleShadows(manager))
+	mux.HandleFunc("/admin/energy", api.HandleAdminEnergy(meter))
+	if monitor != nil {
+		mux.HandleFunc("/admin/vram", api.HandleAdminVRAM(monitor))
+	}
+	if responseCache != nil {
+		mux.HandleFunc("/admin/cache", api.HandleAdminCache(responseCache))
+	}
+	mux.HandleFunc("/v1/collections/{name}/query", api.HandleCollectionQuery(stores))
+	mux.HandleFunc("/v1/collections/{name}/documents", api.HandleCollectionDocuments(stores))
+	mux.HandleFunc("/v1/collections/{name}/search", api.HandleCollectionSearch(retriever))
+	mux.HandleFunc("/v1/collections/{name}/ingest", api.HandleIngest(ingester, fileStore))
+	mux.HandleFunc("/v1/ingest/jobs", api.HandleIngestJobs(ingester))
+	mux.HandleFunc("/v1/ingest/jobs/{id}", api.HandleIngestJobs(ingester))
+	if node != nil {
+		mux.HandleFunc("/admin/cluster", api.HandleClusterStatus(node))
+	}
+	if pool, ok := manager.Engine.(*supervisor.WorkerPool); ok {
+		mux.HandleFunc("/admin/pool", api.HandleWorkerPool(pool))
+	}
+	go fileStore.Run(ctx, time.Hour)
+	mux.HandleFunc("/v1/files", api.HandleFiles(fileStore))
+	mux.HandleFunc("/v1/files/{id}", api.HandleFile(fileStore))
+	mux.HandleFunc("/v1/files/{id}/content", api.HandleFileContent(fileStore))
+	if chatSessions != nil {
+		mux.HandleFunc("/v1/sessions", api.HandleSessions(chatSessions.Store))
+		mux.HandleFunc("/v1/sessions/{id}", api.HandleSession(chatSessions.Store))
+	}
+	if scheduler := newModelScheduler(manager); scheduler != nil {
+		go scheduler.Run(ctx)
+		mux.HandleFunc("/admin/schedule", api.HandleSchedule(scheduler))
+	}
+	transcriber := newTranscriber(manager, fileStore)
+	mux.Handle(audio.TranscriptionsPath, recorder.Middleware(transcriber))
+	mux.Handle(audio.TranslationsPath, recorder.Middleware(transcriber))
+	mux.HandleFunc("/admin/usage/audio", api.HandleAudioUsage(transcriber))
+	if authenticator != nil {
+		go authenticator.Run(ctx, time.Minute)
+		defer authenticator.Save()
+		api.RegisterKeyRoutes(mux, authenticator)
+	}
+	if ledger != nil {
+		mux.HandleFunc("/admin/usage", api.HandleUsage(ledger))
+	}
+	collector := newTelemetry(manager)
+	if collector != nil {
+		go collector.Run(ctx)
+		mux.HandleFunc("/admin/telemetry", api.HandleTelemetryPreview(collector))
+	}
+	var inference http.Handler = chat.NewUsageFiller(counter).Middleware(engine.NewGateway(manager))
+	if window := newWindowManager(port, manager.Profile, counter); window != nil {
+		if monitor != nil {
+			window.Window = monitor.Window(window.Window, window.DefaultWindow)
+		}
+		inference = window.Middleware(inference)
+	}
+	if memory := newMemory(port); memory != nil {
+		inference = memory.Middleware(inference)
+	}
+	if emulator := newToolEmulator(manager); emulator != nil {
+		inference = emulator.Middleware(inference)
+	}
+	if validator := newToolValidator(); validator != nil {
+		inference = validator.Middleware(inference)
+	}
+	inference = retriever.Middleware(inference)
+	if vision := newVision(manager); vision != nil {
+		inference = vision.Middleware(inference)
+	}
+	if guard != nil {
+		inference = guard.Middleware(inference)
+	}
+	inference = newTransformer().Middleware(inference)
+	if responseCache != nil {
+		inference = responseCache.Middleware(inference)
+	}
+	if chatSessions != nil {
+		inference = chatSessions.Middleware(inference)
+	}
+	if defaults := newDefaults(manager.Profile); defaults != nil {
+		inference = defaults.Middleware(inference)
+	}
+	if path := os.Getenv("BOTFRAMEWORK_RECORD_PATH"); path != "" {
+		traceFile, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			slog.Warn("request recording disabled", "err", err)
+		} else {
+			defer traceFile.Close()
+			slog.Info("recording request traces", "path", path)
+			inference = replay.NewRecorder(traceFile).Middleware(inference)
+		}
+	}
+	if collector != nil {
+		inference = collector.Middleware(inference)
+	}
+	if monitor != nil {
+		inference = monitor.Middleware(inference)
+	}
+	if node != nil {
+		inference = node.Middleware(inference)
+	}
+	inference = engine.Chain(inference, middlewares...)
+	if tracer, exporter := newTracer(); tracer != nil {
+		go exporter.Run(ctx, 5*time.Second)
+		inference = tracer.Middleware(inference)
+	}
+	if ledger != nil {
+		inference = ledger.Middleware(inference)
+	}
+	mux.Handle("/", recorder.Middleware(meter.Middleware(inference)))
+	batches.Handler = recorder.Middleware(meter.Middleware(inference))
+	go batches.Run(ctx)
+	mux.HandleFunc("/v1/batches", api.HandleBatches(batches))
+	mux.HandleFunc("/v1/batches/{id}", api.HandleBatch(batches))
+	mux.HandleFunc("/v1/batches/{id}/cancel", api.HandleBatchCancel(batches))
+	socketBackend := recorder.Middleware(meter.Middleware(inference))
+	if authenticator != nil {
+		socketBackend = authenticator.Middleware(socketBackend)
+	}
+	mux.Handle(websocket.ChatPath, newChatSocket(ctx, socketBackend))
+	ollamaServer := newOllamaServer(manager, meter.Middleware(inference))
+	if ollamaServer != nil {
+		mux.Handle("/api/", recorder.Middleware(ollamaServer))
+	}
+	if anthropicServer := newAnthropicServer(meter.Middleware(inference), counter); anthropicServer != nil {
+		mux.Handle(anthropic.MessagesPath, recorder.Middleware(anthropicServer))
+		mux.Handle(anthropic.CountTokensPath, recorder.Middleware(anthropicServer))
+	}
+	go syncRegistry(ctx, func(registry *profiler.ModelRegistry) {
+		if ollamaServer != nil {
+			ollamaServer.SetRegistry(registry)
+		}
 	})
 
-	port := "8080"
-	server := &http.Server{
-		Addr:              ":" + port,
-		Handler:           mux,
-		ReadHeaderTimeout: 5 * time.Second,
+	if cfg.Manager.AdminToken == "" {
+		slog.Warn("no admin token set: /admin/ is open, and workers, models and keys cannot be changed through it; set BOTFRAMEWORK_ADMIN_TOKEN")
+	}
+	handler := api.RequireAdminToken(cfg.Manager.AdminToken, mux)
+	if authenticator != nil {
+		handler = authenticator.Middleware(handler)
+	}
+	if err := serve(ctx, listen, logging.Middleware(handler)); err != nil {
+		// fall through so the deferred cleanup still stops the workers
+		slog.Error("manager error", "err", err)
+	}
+}
+
+// newEnergyMeter samples GPU power; BOTFRAMEWORK_KWH_PRICE sets the electricity price and
+// BOTFRAMEWORK_POWER_WATTS the assumed draw on hosts without GPU power telemetry
+func newEnergyMeter() *energy.Meter {
+	meter := energy.NewMeter(profiler.SamplePowerDraw)
+	if price, err := strconv.ParseFloat(os.Getenv("BOTFRAMEWORK_KWH_PRICE"), 64); err == nil {
+		meter.PricePerKWh = price
 	}
+	if watts, err := strconv.ParseFloat(os.Getenv("BOTFRAMEWORK_POWER_WATTS"), 64); err == nil {
+		meter.FallbackWatts = watts
+	}
+	return meter
+}
 
-	go func() {
-		<-ctx.Done()
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutdownCancel()
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			log.Printf("manager shutdown error: %v", err)
-		}
-	}()
+// applyGPUProfile tunes NVIDIA GPUs for the named profile and returns a func restoring the
+// original settings, or nil when no profile is configured
+func applyGPUProfile(name string) func() {
+	if name == "" {
+		return nil
+	}
+	profile, ok := gputune.Profiles[name]
+	if !ok {
+		slog.Warn("unknown GPU profile, leaving GPU settings unchanged", "profile", name)
+		return nil
+	}
 
-	fmt.Printf("🌟 BotFramework Manager listening on :%s\n", port)
-	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Fatal(err)
+	slog.Info("applying GPU profile", "profile", name)
+	tuner := gputune.NewTuner()
+	if err := tuner.Apply(profile); err != nil {
+		slog.Warn("GPU profile partially applied (root is usually required)", "err", err)
+	}
+	return func() {
+		if err := tuner.Restore(); err != nil {
+			slog.Warn("failed to restore GPU settings", "err", err)
+		}
 	}
 }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"botframework/convert"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// manifest declares the models served at boot, beside those in BOTFRAMEWORK_MODELS:
+//
+//	{"models": [
+//	  {"id": "fast", "model": "phi-3-mini:Q4_K_M", "engine": "llama-server", "gpus": [0], "max_context": 8192},
+//	  {"id": "quality", "model": "/models/llama-3-70b", "engine": "vllm", "gpus": [1, 2], "warmup": ["Summarise: ..."]}
+//	]}
+type manifest struct {
+	Models []manifestModel `json:"models"`
+}
+
+type manifestModel struct {
+	// ID is the name the model is served under
+	ID string `json:"id"`
+	// Model is a model file or checkpoint directory, "<model id>[:<quant>]" in the model dir
+	// or download cache, or the URL of a remote server
+	Model string `json:"model"`
+	// Engine is the worker runtime, as BOTFRAMEWORK_WORKER_RUNTIME names them; empty uses
+	// the manager's
+	Engine string `json:"engine,omitempty"`
+	// GPUs pins the worker; without them the manager places it
+	GPUs []int `json:"gpus,omitempty"`
+	// MaxContext caps the context the worker is launched with, and is what its KV cache is
+	// sized for when checking that the manifest fits
+	MaxContext int `json:"max_context,omitempty"`
+	// Warmup replaces the prompts the worker is warmed up with once ready; an empty list
+	// turns warmup off
+	Warmup []string `json:"warmup,omitempty"`
+}
+
+// loadManifest reads the JSON file BOTFRAMEWORK_MANIFEST names and returns its models,
+// none when it is unset. Every problem in the file is reported at once.
+func loadManifest(profile *profiler.HardwareProfile) ([]declaredModel, error) {
+	path := os.Getenv("BOTFRAMEWORK_MANIFEST")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var errs []error
+	var models []declaredModel
+	seen := make(map[string]bool)
+	for i, entry := range m.Models {
+		problem := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("%s: models[%d]: %s", path, i, fmt.Sprintf(format, args...)))
+		}
+		switch {
+		case entry.ID == "":
+			problem("no id")
+		case seen[entry.ID]:
+			problem("duplicate id %q", entry.ID)
+		}
+		seen[entry.ID] = true
+		if entry.Model == "" {
+			problem("no model")
+		}
+		if entry.MaxContext < 0 {
+			problem("max_context %d is negative", entry.MaxContext)
+		}
+		model := declaredModel{name: entry.ID, path: entry.Model, gpus: entry.GPUs, maxContext: entry.MaxContext, warmup: entry.Warmup}
+		if entry.GPUs != nil {
+			if profile == nil {
+				problem("no hardware profile to check GPUs against")
+			} else if err := profile.ValidateGPUAssignments(profiler.GPUAssignments{entry.ID: entry.GPUs}); err != nil {
+				problem("%v", err)
+			}
+		}
+		if entry.Engine != "" {
+			runtime, err := findRuntime(entry.Engine)
+			if err != nil {
+				problem("%v", err)
+			}
+			model.runtime = &runtime
+		}
+		models = append(models, model)
+	}
+	if len(m.Models) == 0 {
+		errs = append(errs, fmt.Errorf("%s: no models", path))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	slog.Info("serving the model manifest", "path", path, "models", len(models))
+	return models, nil
+}
+
+// findRuntime looks up the worker runtime a manifest names
+func findRuntime(name string) (workerRuntime, error) {
+	var runtime workerRuntime
+	var err error
+	switch name {
+	case "python":
+	case "llama-server":
+		runtime.llamaServer, err = findLlamaServer()
+	case "vllm":
+		runtime.vllm, err = findVLLM()
+	case "mlx":
+		runtime.mlx, err = findMLXPython()
+	case "docker":
+		runtime.docker, err = supervisor.NewDockerClient(os.Getenv("DOCKER_HOST"))
+	default:
+		return runtime, fmt.Errorf("unknown engine %q (want python, llama-server, vllm, mlx or docker)", name)
+	}
+	if err != nil {
+		return workerRuntime{}, fmt.Errorf("engine %s: %w", name, err)
+	}
+	return runtime, nil
+}
+
+// modelDemand is the memory model takes at the context it is capped at, else context: its
+// weights' size on disk, with the KV cache and activations of its architecture as read from
+// its checkpoint or the registry
+func modelDemand(registry *profiler.ModelRegistry, model declaredModel, context int) profiler.ModelDemand {
+	demand := profiler.ModelDemand{Name: model.name, WeightsGB: convert.SizeGB(model.path), Context: cmp.Or(model.maxContext, context), GPUs: model.gpus}
+	if checkpoint, err := profiler.CheckpointModel(model.path); err == nil {
+		demand.Model = checkpoint
+	} else if known := registry.Lookup(strings.TrimSuffix(filepath.Base(model.path), filepath.Ext(model.path))); known != nil {
+		demand.Model = *known
+	}
+	return demand
+}
+
+// placeModels checks that the local models fit the hardware together, at the context each
+// is capped at (else the tier's), and pins those without GPUs to the GPUs the placement
+// gave them on multi-GPU hosts. It returns a *profiler.PlacementError listing every device
+// when they do not fit.
+func placeModels(profile *profiler.HardwareProfile, models []declaredModel, context int) error {
+	if profile == nil {
+		return errors.New("no hardware profile to place the models with")
+	}
+	registry := loadRegistry()
+	demands := make([]profiler.ModelDemand, 0, len(models))
+	for _, model := range models {
+		demands = append(demands, modelDemand(registry, model, context))
+	}
+
+	placement, err := profile.Place(demands)
+	if err != nil {
+		return err
+	}
+	for _, device := range placement.Devices {
+		names := slices.Sorted(maps.Keys(device.Models))
+		slog.Info("placing models", "device", device.Name, "used_gb", fmt.Sprintf("%.1f", device.UsedGB),
+			"capacity_gb", fmt.Sprintf("%.1f", device.CapacityGB), "models", strings.Join(names, ","))
+	}
+	if len(profile.GPUs) > 1 {
+		for i := range models {
+			if models[i].gpus == nil {
+				models[i].gpus = placement.GPUs[models[i].name]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/middleware"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultMiddleware is the chain run when BOTFRAMEWORK_MIDDLEWARE is unset
+const defaultMiddleware = "sanitize_headers,body_limit,cors"
+
+// The built-in middlewares, configured from the environment:
+//
+//	BOTFRAMEWORK_CORS_ORIGINS    comma-separated origins browsers may call the API from ("*" for any); cors is off without it
+//	BOTFRAMEWORK_MAX_BODY_BYTES  largest request body accepted (default: 32 MiB)
+func init() {
+	middleware.Register("cors", func() (engine.Middleware, error) {
+		origins := splitList(os.Getenv("BOTFRAMEWORK_CORS_ORIGINS"))
+		if len(origins) == 0 {
+			return nil, nil
+		}
+		return &middleware.CORS{Origins: origins}, nil
+	})
+	middleware.Register("body_limit", func() (engine.Middleware, error) {
+		limit := int64(32 << 20)
+		if value := os.Getenv("BOTFRAMEWORK_MAX_BODY_BYTES"); value != "" {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("BOTFRAMEWORK_MAX_BODY_BYTES: %q is not a positive byte count", value)
+			}
+			limit = n
+		}
+		return &middleware.BodyLimit{MaxBytes: limit}, nil
+	})
+	middleware.Register("sanitize_headers", func() (engine.Middleware, error) {
+		return &middleware.SanitizeHeaders{}, nil
+	})
+}
+
+// middlewareChain builds the middlewares named in BOTFRAMEWORK_MIDDLEWARE, which run in
+// front of the inference handler, the first one outermost. Custom ones are added by
+// registering them with middleware.Register from a package imported by the manager.
+func middlewareChain() ([]engine.Middleware, error) {
+	names := defaultMiddleware
+	if value, set := os.LookupEnv("BOTFRAMEWORK_MIDDLEWARE"); set {
+		names = value
+	}
+	return middleware.Build(splitList(names))
+}
+
+// splitList splits a comma-separated setting, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
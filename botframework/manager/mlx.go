@@ -0,0 +1,55 @@
+package main
+
+import (
+	"botframework/convert"
+	"botframework/engine"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"cmp"
+	"log/slog"
+	"os"
+	"os/exec"
+)
+
+// mlxPython decides whether chat workers run mlx_lm.server instead of the Python worker.
+// BOTFRAMEWORK_WORKER_RUNTIME=mlx always does; auto (the default) does when the recommended
+// engine is MLX and the interpreter can import mlx_lm. BOTFRAMEWORK_MLX_PYTHON names the
+// interpreter, defaulting to BOTFRAMEWORK_PYTHON, else python3.
+func mlxPython(manager *engine.ModelManager) (string, bool) {
+	runtime := os.Getenv("BOTFRAMEWORK_WORKER_RUNTIME")
+	switch runtime {
+	case "python", "docker", "llama-server", "vllm":
+		return "", false
+	case "", "auto":
+		if manager.Backend != profiler.EngineMLX {
+			return "", false
+		}
+	}
+
+	python, err := findMLXPython()
+	if err != nil {
+		if runtime == "mlx" {
+			slog.Warn("mlx_lm not importable; using the Python worker", "python", python, "err", err)
+		}
+		return "", false
+	}
+	return python, true
+}
+
+// findMLXPython checks that BOTFRAMEWORK_MLX_PYTHON, else BOTFRAMEWORK_PYTHON, else python3
+// imports mlx_lm
+func findMLXPython() (string, error) {
+	python := cmp.Or(os.Getenv("BOTFRAMEWORK_MLX_PYTHON"), os.Getenv("BOTFRAMEWORK_PYTHON"), "python3")
+	return python, exec.Command(python, "-c", "import mlx_lm").Run()
+}
+
+// newMLXWorker creates an mlx_lm.server worker for modelPath, wiring the GPU's share of
+// unified memory for it
+func newMLXWorker(python, port, modelPath string, profile *profiler.HardwareProfile) (*supervisor.MLXWorker, error) {
+	flags, err := supervisor.MLXFlagsFor(profile, convert.SizeGB(modelPath))
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("using mlx_lm.server", "python", python, "model", modelPath)
+	return supervisor.NewMLXWorker(python, port, modelPath, flags), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"botframework/api"
+	"botframework/download"
+	"botframework/engine"
+	"botframework/ollama"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// newOllamaServer serves the Ollama API under /api/ on top of backend, the OpenAI-compatible
+// inference handler, unless BOTFRAMEWORK_OLLAMA=off. /api/pull downloads registry models into
+// the model cache, as `manager download` does; names may end in a quant, e.g. phi-3-mini-4k-q4_k_m.
+func newOllamaServer(manager *engine.ModelManager, backend http.Handler) *ollama.Server {
+	if os.Getenv("BOTFRAMEWORK_OLLAMA") == "off" {
+		return nil
+	}
+	server := ollama.NewServer(backend, func() ([]string, error) {
+		return api.ModelIDs(manager)
+	})
+	server.Registry = currentRegistry()
+	server.Pull = func(ctx context.Context, name string, progress func(completed, total int64)) error {
+		model := currentRegistry().Lookup(name)
+		if model == nil {
+			return fmt.Errorf("model %q is not in the registry", name)
+		}
+		quant := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(name), strings.ToLower(model.ID)), "-")
+		variant, err := pickVariant(model, quant)
+		if err != nil {
+			return err
+		}
+		downloader := download.New(modelCacheDir())
+		downloader.Token = os.Getenv("HF_TOKEN")
+		downloader.Progress = func(p download.Progress) {
+			progress(p.Downloaded, p.Total)
+		}
+		slog.InfoContext(ctx, "pulling model for an Ollama client", "model", model.ID, "quant", variant.Quant)
+		_, err = downloader.Download(ctx, *model, variant)
+		return err
+	}
+	return server
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"botframework/supervisor"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// newWorkerPool replaces the default worker with BOTFRAMEWORK_WORKERS copies, balanced by
+// BOTFRAMEWORK_BALANCE (round-robin | least-pending). With BOTFRAMEWORK_WORKER_PORT set the
+// copies take consecutive ports from it, otherwise free ones. Returns nil when a single
+// worker is configured or the ports cannot be had.
+func newWorkerPool(worker *supervisor.PythonWorker, migSlots *migAllocator) *supervisor.WorkerPool {
+	count, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_WORKERS"))
+	if err != nil || count < 2 {
+		return nil
+	}
+	strategy := supervisor.RoundRobin
+	if name := os.Getenv("BOTFRAMEWORK_BALANCE"); name != "" {
+		if strategy, err = supervisor.ParseBalanceStrategy(name); err != nil {
+			slog.Warn("using round-robin", "err", err)
+			strategy = supervisor.RoundRobin
+		}
+	}
+	base, err := strconv.Atoi(worker.Port)
+	if err != nil {
+		slog.Warn("worker pool disabled: port is not numeric", "port", worker.Port)
+		return nil
+	}
+
+	consecutive := os.Getenv("BOTFRAMEWORK_WORKER_PORT") != ""
+	ports := []string{worker.Port}
+	for i := 1; i < count; i++ {
+		owner := fmt.Sprintf("pool worker %d", i)
+		port := strconv.Itoa(base + i)
+		if consecutive {
+			err = workerPorts.Reserve(port, owner)
+		} else {
+			port, err = workerPorts.Allocate(owner)
+		}
+		if err != nil {
+			slog.Error("worker pool disabled", "err", err)
+			for _, taken := range ports[1:] {
+				workerPorts.Release(taken)
+			}
+			return nil
+		}
+		ports = append(ports, port)
+	}
+	pool := supervisor.NewPythonWorkerPool(worker.ScriptPath, worker.ModelPath, ports, strategy)
+	for i, member := range pool.Workers() {
+		python := member.(*supervisor.PythonWorker)
+		if i == 0 {
+			// the first member inherits any MIG slice pinned for the default worker
+			python.Env = append(python.Env, worker.Env...)
+			continue
+		}
+		migSlots.assignNext(python)
+	}
+	return pool
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"botframework/supervisor"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// workerPorts records the port of every worker the manager starts and of its own listeners
+var workerPorts = supervisor.NewPortAllocator()
+
+// reserveListenPorts fails fast when one of the manager's TCP listeners is taken, before
+// any worker is started. Shared ports (BOTFRAMEWORK_REUSEPORT=on) are not checked.
+func reserveListenPorts(listen listenConfig) error {
+	if listen.reusePort {
+		return nil
+	}
+	for _, b := range listen.bindings(nil) {
+		if b.addr.Port() == "" {
+			continue
+		}
+		if err := workerPorts.Reserve(b.addr.Address, "manager "+b.role+" listener"); err != nil {
+			if errors.Is(err, supervisor.ErrPortInUse) {
+				return fmt.Errorf("%w; free it or choose another address with --port or BOTFRAMEWORK_LISTEN", err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// workerPort returns the configured worker port after checking nothing holds it, or a free
+// one when none is configured
+func workerPort(configured int, owner string) (string, error) {
+	if configured == 0 {
+		port, err := workerPorts.Allocate(owner)
+		if err == nil {
+			slog.Debug("worker port allocated", "worker", owner, "port", port)
+		}
+		return port, err
+	}
+	port := strconv.Itoa(configured)
+	if err := workerPorts.Reserve(port, owner); err != nil {
+		return "", fmt.Errorf("%w; choose another with BOTFRAMEWORK_WORKER_PORT or leave it unset to pick a free one", err)
+	}
+	return port, nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"botframework/profiler"
+	"log/slog"
+	"os"
+)
+
+// detectPower records whether this host runs on battery or throttles for heat, which
+// down-ranks heavy variants and halves llama-server's threads. BOTFRAMEWORK_POWER_AWARE=off
+// plans for the hardware as if it were plugged in and cool. Downloads ignore it: a variant
+// picked to last the battery would outlive it.
+func detectPower(profile *profiler.HardwareProfile) {
+	if os.Getenv("BOTFRAMEWORK_POWER_AWARE") == "off" {
+		return
+	}
+	profile.Power = profiler.DetectPower()
+	if profile.Power.Constrained() {
+		slog.Info("power constrained, preferring lighter variants", "power", profile.Power.String())
+	}
+}
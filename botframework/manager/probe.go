@@ -0,0 +1,47 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/profiler"
+	"context"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// probeTimeout bounds the startup probe so a slow host still comes up
+const probeTimeout = 2 * time.Minute
+
+// probeSpeed measures the default engine's prefill and decode tok/s and records them with
+// the measured benchmark scores, where model recommendations pick them up as a speed term.
+// A failed probe is logged and startup continues.
+func probeSpeed(ctx context.Context, manager *engine.ModelManager) {
+	health, err := manager.Engine.Health()
+	if err != nil || health.Model == "" {
+		slog.Warn("speed probe skipped, the worker did not report its model", "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	slog.Info("probing model speed", "model", health.Model)
+	result, err := engine.Probe(ctx, manager.Engine, health.Model, 0)
+	if err != nil {
+		slog.Warn("speed probe failed", "model", health.Model, "err", err)
+		return
+	}
+	slog.Info("model speed measured", "model", health.Model,
+		"prefill_tps", math.Round(result.PrefillTPS*10)/10, "decode_tps", math.Round(result.DecodeTPS*10)/10)
+
+	path := measurementsPath()
+	measurements, err := profiler.LoadMeasurements(path)
+	if err != nil {
+		slog.Warn("speed not recorded", "path", path, "err", err)
+		return
+	}
+	measurements.RecordSpeed(health.Model, result.PrefillTPS, result.DecodeTPS)
+	if err := measurements.Save(path); err != nil {
+		slog.Warn("speed not recorded", "path", path, "err", err)
+		return
+	}
+	current.Store(loadRegistry())
+}
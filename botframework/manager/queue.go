@@ -0,0 +1,48 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/profiler"
+	"os"
+	"strconv"
+	"time"
+)
+
+// queueConfig reads the per-worker request queue settings:
+//
+//	BOTFRAMEWORK_MAX_INFLIGHT   concurrent requests per worker, 0 to disable queueing
+//	                            (default: 32 for vLLM, which batches, 2 for the other backends)
+//	BOTFRAMEWORK_MAX_QUEUE      requests waiting per worker before 429s (default: 64)
+//	BOTFRAMEWORK_QUEUE_TIMEOUT  longest a request waits for a slot (default: 2m)
+//	BOTFRAMEWORK_QUEUE_STARVE_AFTER
+//	                            how long a background request may be overtaken by
+//	                            interactive ones (default: 30s)
+func queueConfig(backend profiler.Engine) engine.QueueConfig {
+	config := engine.QueueConfig{MaxInFlight: 2, MaxQueue: 64, MaxWait: 2 * time.Minute}
+	if backend == profiler.EngineVLLM {
+		config.MaxInFlight = 32
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_MAX_INFLIGHT")); err == nil && n >= 0 {
+		config.MaxInFlight = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_MAX_QUEUE")); err == nil && n >= 0 {
+		config.MaxQueue = n
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_QUEUE_TIMEOUT")); err == nil && timeout >= 0 {
+		config.MaxWait = timeout
+	}
+	if starve, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_QUEUE_STARVE_AFTER")); err == nil && starve > 0 {
+		config.StarveAfter = starve
+	}
+	return config
+}
+
+// requestTimeout reads BOTFRAMEWORK_REQUEST_TIMEOUT, the longest an inference request may
+// generate before it is aborted with a 504 (default: none). Clients may ask for less with
+// an X-Request-Timeout header.
+func requestTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_REQUEST_TIMEOUT")); err == nil && timeout >= 0 {
+		return timeout
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"botframework/rag"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// newVectorStores loads per-collection vector store settings from BOTFRAMEWORK_VECTOR_CONFIG
+// (JSON, see rag.Config). Without it every collection uses the built-in store, persisted under
+// BOTFRAMEWORK_VECTOR_DIR or kept in memory when that is unset.
+func newVectorStores() (*rag.Stores, error) {
+	if path := os.Getenv("BOTFRAMEWORK_VECTOR_CONFIG"); path != "" {
+		slog.Info("loading vector store config", "path", path)
+		return rag.LoadStores(path)
+	}
+	return rag.NewStores(rag.Config{Default: rag.StoreConfig{Type: "local", Path: os.Getenv("BOTFRAMEWORK_VECTOR_DIR")}})
+}
+
+// newEmbedder sends ingestion embeddings to BOTFRAMEWORK_EMBEDDINGS_URL, defaulting to this
+// manager's own /v1/embeddings route, using BOTFRAMEWORK_EMBEDDINGS_MODEL when set
+func newEmbedder(port string) rag.Embedder {
+	url := os.Getenv("BOTFRAMEWORK_EMBEDDINGS_URL")
+	if url == "" {
+		url = "http://127.0.0.1:" + port + "/v1/embeddings"
+	}
+	return rag.NewHTTPEmbedder(url, os.Getenv("BOTFRAMEWORK_EMBEDDINGS_MODEL"), os.Getenv("BOTFRAMEWORK_EMBEDDINGS_API_KEY"))
+}
+
+// newRetriever builds the retrieval pipeline. BOTFRAMEWORK_RERANK_MODEL enables the rerank stage,
+// served through BOTFRAMEWORK_RERANK_URL (default: this manager's /v1/rerank route); the
+// BOTFRAMEWORK_RAG_TOP_K_IN, _TOP_K_OUT and _RERANK_BUDGET_MS variables override the defaults.
+func newRetriever(stores *rag.Stores, embedder rag.Embedder, port string) *rag.Retriever {
+	retriever := &rag.Retriever{Stores: stores, Embedder: embedder, Defaults: rag.DefaultRetrievalOptions()}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_RAG_TOP_K_IN")); err == nil {
+		retriever.Defaults.TopKIn = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_RAG_TOP_K_OUT")); err == nil {
+		retriever.Defaults.TopKOut = n
+	}
+	if ms, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_RAG_RERANK_BUDGET_MS")); err == nil {
+		retriever.Defaults.RerankBudget = time.Duration(ms) * time.Millisecond
+	}
+
+	if model := os.Getenv("BOTFRAMEWORK_RERANK_MODEL"); model != "" {
+		url := os.Getenv("BOTFRAMEWORK_RERANK_URL")
+		if url == "" {
+			url = "http://127.0.0.1:" + port + "/v1/rerank"
+		}
+		slog.Info("reranking retrieved documents", "model", model)
+		retriever.Reranker = rag.NewHTTPReranker(url, model)
+	}
+	return retriever
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"botframework/profiler"
+	"botframework/registry"
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	remoteOnce   sync.Once
+	remoteSource *registry.Source
+	// current is the registry serving components read, replaced when the remote copy changes
+	current atomic.Pointer[profiler.ModelRegistry]
+)
+
+// registrySource returns the remote registry (BOTFRAMEWORK_REGISTRY_URL), synced on first
+// use, or nil when none is configured. BOTFRAMEWORK_REGISTRY_PUBLIC_KEY requires signed
+// registries and BOTFRAMEWORK_REGISTRY_CACHE moves the offline copy.
+func registrySource() *registry.Source {
+	remoteOnce.Do(func() {
+		url := os.Getenv("BOTFRAMEWORK_REGISTRY_URL")
+		if url == "" {
+			return
+		}
+		cache := os.Getenv("BOTFRAMEWORK_REGISTRY_CACHE")
+		if cache == "" {
+			cache = registry.DefaultCachePath()
+		}
+		source := registry.New(url, cache)
+		if key := os.Getenv("BOTFRAMEWORK_REGISTRY_PUBLIC_KEY"); key != "" {
+			publicKey, err := registry.ParsePublicKey(key)
+			if err != nil {
+				slog.Error("remote registry disabled", "err", err)
+				return
+			}
+			source.PublicKey = publicKey
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := source.Sync(ctx); err != nil {
+			slog.Warn("registry sync failed, using the cached copy", "url", url, "err", err)
+		}
+		remoteSource = source
+	})
+	return remoteSource
+}
+
+// currentRegistry returns the registry long-running components score and look models up in
+func currentRegistry() *profiler.ModelRegistry {
+	if registry := current.Load(); registry != nil {
+		return registry
+	}
+	current.CompareAndSwap(nil, loadRegistry())
+	return current.Load()
+}
+
+// syncRegistry refreshes the remote registry every BOTFRAMEWORK_REGISTRY_REFRESH (default:
+// 6h) until ctx is done, passing each new one to onChange
+func syncRegistry(ctx context.Context, onChange func(*profiler.ModelRegistry)) {
+	source := registrySource()
+	if source == nil {
+		return
+	}
+	interval, _ := time.ParseDuration(os.Getenv("BOTFRAMEWORK_REGISTRY_REFRESH"))
+	source.Run(ctx, interval, func(*profiler.ModelRegistry) {
+		// reload so locally measured scores still replace the published ones
+		updated := loadRegistry()
+		current.Store(updated)
+		onChange(updated)
+	})
+}
+
+// applyModelPolicy reads BOTFRAMEWORK_MODEL_POLICY, which keeps gated ("ungated") or
+// non-commercial ("commercial") models out of the recommendations made for profile
+func applyModelPolicy(profile *profiler.HardwareProfile) {
+	policy, err := profiler.ParseModelPolicy(os.Getenv("BOTFRAMEWORK_MODEL_POLICY"))
+	if err != nil {
+		slog.Warn("model policy ignored", "err", err)
+		return
+	}
+	profile.ModelPolicy = policy
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"botframework/supervisor"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// remoteConfig is how the manager reaches inference servers it does not run:
+//
+//	BOTFRAMEWORK_REMOTE_URL          serve the default model from this server, e.g. http://gpu-box:8000
+//	BOTFRAMEWORK_REMOTE_HEADER       header set on every request, e.g. "Authorization: Bearer sk-..."
+//	BOTFRAMEWORK_REMOTE_CA           PEM bundle the server's certificate is checked against
+//	BOTFRAMEWORK_REMOTE_CERT/_KEY    client certificate for servers requiring mutual TLS
+//	BOTFRAMEWORK_REMOTE_INSECURE     true skips certificate verification
+//	BOTFRAMEWORK_REMOTE_HEALTH_PATH  health endpoint (default: /health, then /v1/models)
+//
+// Models declared in BOTFRAMEWORK_MODELS with an http(s) URL are served remotely too.
+type remoteConfig struct {
+	url     string
+	options supervisor.RemoteOptions
+}
+
+func loadRemoteConfig() (remoteConfig, error) {
+	config := remoteConfig{url: os.Getenv("BOTFRAMEWORK_REMOTE_URL")}
+	config.options.HealthPath = os.Getenv("BOTFRAMEWORK_REMOTE_HEALTH_PATH")
+
+	if header := os.Getenv("BOTFRAMEWORK_REMOTE_HEADER"); header != "" {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return config, fmt.Errorf("BOTFRAMEWORK_REMOTE_HEADER: want \"Name: value\", got %q", header)
+		}
+		config.options.Header = http.Header{}
+		config.options.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	tlsConfig := &tls.Config{}
+	configured := false
+	if path := os.Getenv("BOTFRAMEWORK_REMOTE_CA"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("BOTFRAMEWORK_REMOTE_CA: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return config, fmt.Errorf("BOTFRAMEWORK_REMOTE_CA: no certificates in %s", path)
+		}
+		configured = true
+	}
+	certFile, keyFile := os.Getenv("BOTFRAMEWORK_REMOTE_CERT"), os.Getenv("BOTFRAMEWORK_REMOTE_KEY")
+	if (certFile == "") != (keyFile == "") {
+		return config, errors.New("BOTFRAMEWORK_REMOTE_CERT and BOTFRAMEWORK_REMOTE_KEY are set together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return config, fmt.Errorf("remote client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		configured = true
+	}
+	if insecure := os.Getenv("BOTFRAMEWORK_REMOTE_INSECURE"); insecure != "" {
+		skip, err := strconv.ParseBool(insecure)
+		if err != nil {
+			return config, fmt.Errorf("BOTFRAMEWORK_REMOTE_INSECURE: %w", err)
+		}
+		tlsConfig.InsecureSkipVerify = skip
+		configured = configured || skip
+	}
+	if configured {
+		config.options.TLS = tlsConfig
+	}
+
+	if config.url != "" {
+		if _, err := supervisor.NewRemoteEngine(config.url, config.options); err != nil {
+			return config, err
+		}
+	}
+	return config, nil
+}
+
+// isRemoteModel reports whether a declared model's path is a server URL
+func isRemoteModel(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
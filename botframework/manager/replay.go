@@ -0,0 +1,40 @@
+package main
+
+import (
+	"botframework/replay"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runReplay replays recorded traces against a manager or worker
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	file := fs.String("file", "", "JSONL trace file recorded with BOTFRAMEWORK_RECORD_PATH")
+	url := fs.String("url", "http://127.0.0.1:8080", "target base URL")
+	model := fs.String("model", "", "replay every request against this model")
+	concurrency := fs.Int("concurrency", 1, "number of concurrent requests")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("--file is required")
+	}
+
+	traces, err := replay.Load(*file)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	report := replay.Run(ctx, traces, replay.Options{BaseURL: *url, Model: *model, Concurrency: *concurrency})
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/profiler"
+	"os"
+	"strconv"
+	"time"
+)
+
+// newResidency schedules the models loaded on demand, unless BOTFRAMEWORK_RESIDENCY=off:
+// when the next model does not fit in the memory free now, the least recently requested
+// ones are evicted to make room. Free memory is sampled from the GPUs on GPU hosts and from
+// RAM elsewhere; a model's need is estimated from its weights and architecture at the
+// context workers launch with.
+//
+//	BOTFRAMEWORK_MAX_LOADED_MODELS      models loaded on demand at once (default: as many as fit)
+//	BOTFRAMEWORK_RESIDENCY_HEADROOM_MB  memory left free beside a new model (default: 512)
+func newResidency(profile *profiler.HardwareProfile, modelDir, cacheDir string) *engine.Residency {
+	if os.Getenv("BOTFRAMEWORK_RESIDENCY") == "off" {
+		return nil
+	}
+	headroom := 512
+	if mb, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_RESIDENCY_HEADROOM_MB")); err == nil && mb >= 0 {
+		headroom = mb
+	}
+	residency := &engine.Residency{FreeMB: freeMemoryMB(profile), Settle: time.Second}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_MAX_LOADED_MODELS")); err == nil && n > 0 {
+		residency.MaxModels = n
+	}
+	context := loadContext(profile)
+	residency.NeedMB = func(model string) int {
+		path, err := findModel(modelDir, cacheDir, model)
+		if err != nil {
+			// the load fails on its own; nothing is evicted for it
+			return 0
+		}
+		demand := modelDemand(loadRegistry(), declaredModel{name: model, path: path}, context)
+		return int(demand.NeedGB()*1024) + headroom
+	}
+	return residency
+}
+
+// freeMemoryMB samples the memory models load into: the GPUs' summed on GPU hosts, RAM
+// elsewhere. It returns -1 when the memory cannot be read, which skips the memory check.
+func freeMemoryMB(profile *profiler.HardwareProfile) func() int {
+	if profile != nil && (profile.HasCuda || profile.HasROCm || profile.HasMetal) {
+		return func() int {
+			gpus := profiler.SampleVRAM()
+			if len(gpus) == 0 {
+				return -1
+			}
+			free := 0
+			for _, gpu := range gpus {
+				free += gpu.FreeMB()
+			}
+			return free
+		}
+	}
+	return func() int {
+		usage := profiler.SampleUsage()
+		if usage.RAMTotalMB == 0 {
+			return -1
+		}
+		return usage.RAMTotalMB - usage.RAMUsedMB
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"botframework/profiler"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewResidency(t *testing.T) {
+	modelDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(modelDir, "phi-3.gguf"), make([]byte, 1<<20), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BOTFRAMEWORK_REGISTRY_URL", "")
+	t.Setenv("BOTFRAMEWORK_REGISTRY_PATH", filepath.Join(modelDir, "no-registry.json"))
+	profile := &profiler.HardwareProfile{}
+
+	t.Setenv("BOTFRAMEWORK_RESIDENCY", "off")
+	if residency := newResidency(profile, modelDir, ""); residency != nil {
+		t.Errorf("BOTFRAMEWORK_RESIDENCY=off: residency = %+v, want nil", residency)
+	}
+	t.Setenv("BOTFRAMEWORK_RESIDENCY", "")
+
+	for _, tc := range []struct {
+		maxModels, headroom  string
+		wantMax, wantAtLeast int
+	}{
+		{"", "", 0, 512},
+		{"3", "2048", 3, 2048},
+		{"0", "-1", 0, 512},
+		{"many", "lots", 0, 512},
+	} {
+		t.Setenv("BOTFRAMEWORK_MAX_LOADED_MODELS", tc.maxModels)
+		t.Setenv("BOTFRAMEWORK_RESIDENCY_HEADROOM_MB", tc.headroom)
+		residency := newResidency(profile, modelDir, "")
+		if residency == nil || residency.FreeMB == nil || residency.NeedMB == nil {
+			t.Fatalf("%+v: residency = %+v", tc, residency)
+		}
+		if residency.MaxModels != tc.wantMax {
+			t.Errorf("%+v: MaxModels = %d, want %d", tc, residency.MaxModels, tc.wantMax)
+		}
+		if need := residency.NeedMB("phi-3"); need < tc.wantAtLeast {
+			t.Errorf("%+v: NeedMB(phi-3) = %d, want at least the %d MB headroom", tc, need, tc.wantAtLeast)
+		}
+		// models that cannot be found fail to load on their own and evict nothing
+		for _, model := range []string{"missing", "../phi-3"} {
+			if need := residency.NeedMB(model); need != 0 {
+				t.Errorf("%+v: NeedMB(%q) = %d, want 0", tc, model, need)
+			}
+		}
+	}
+}
+
+func TestFreeMemoryMB(t *testing.T) {
+	usage := profiler.SampleUsage()
+	free := freeMemoryMB(nil)()
+	switch {
+	case usage.RAMTotalMB == 0 && free != -1:
+		t.Errorf("RAM unreadable: free = %d, want -1", free)
+	case usage.RAMTotalMB > 0 && (free < 0 || free > usage.RAMTotalMB):
+		t.Errorf("free RAM = %d MB of %d MB", free, usage.RAMTotalMB)
+	}
+
+	if len(profiler.SampleVRAM()) > 0 {
+		t.Skip("host has GPUs to sample")
+	}
+	if free := freeMemoryMB(&profiler.HardwareProfile{HasCuda: true})(); free != -1 {
+		t.Errorf("CUDA profile without readable GPUs: free = %d, want -1", free)
+	}
+}
@@ -0,0 +1,556 @@
+package main
+
+import (
+	"botframework/download"
+	"botframework/engine"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// configureRouting applies the model routing settings from the environment:
+//
+//	BOTFRAMEWORK_MODEL_PATH     model file for the default worker
+//	BOTFRAMEWORK_MODELS         models served side by side, e.g. "fast=/models/phi-3.gguf,quality=llama-2-13b"
+//	BOTFRAMEWORK_MANIFEST       models served at boot with their engine, GPUs and context, see loadManifest
+//	BOTFRAMEWORK_UNKNOWN_MODEL  reject | load | default (default: reject with BOTFRAMEWORK_MODELS, else default)
+//	BOTFRAMEWORK_MODEL_DIR      directory searched for <model>.gguf when loading on demand
+//	BOTFRAMEWORK_MODEL_CACHE    cache filled by `manager download`, searched after the model dir
+//	BOTFRAMEWORK_FALLBACKS      fallback chains, e.g. "llama-13b=llama-8b,phi-3;qwen=phi-3"
+//	BOTFRAMEWORK_SHADOW_LOG     JSONL file receiving mirrored shadow responses
+//	BOTFRAMEWORK_MIG_DEVICE     MIG slice for the default worker ("0:1", a MIG UUID or a profile like "1g.10gb")
+//	BOTFRAMEWORK_WORKERS        number of workers serving the default model (default: 1)
+//	BOTFRAMEWORK_BALANCE        round-robin | least-pending, how requests spread across them
+//	BOTFRAMEWORK_WORKER_RUNTIME python | llama-server | vllm | mlx | docker | auto, see llamaServerBinary, vllmBinary,
+//	                            mlxPython and dockerClient
+//	BOTFRAMEWORK_WORKER_PROTOCOL http | grpc, how the manager talks to Python workers
+//	BOTFRAMEWORK_EMBEDDING_MODEL embedding model served beside the chat model, see resolveEmbeddingModel
+//	BOTFRAMEWORK_REMOTE_URL     server the default model is served from, see remoteConfig
+//	BOTFRAMEWORK_DRAFT_MODEL    auto | off | draft model for llama-server's speculative decoding, see draftModel
+//	BOTFRAMEWORK_WORKER_GPUS    GPUs each worker may use, see loadGPUAssignments
+//	BOTFRAMEWORK_TIER_DEFAULTS  off launches workers without the hardware tier's limits, see tierDefaults
+//	BOTFRAMEWORK_IDLE_TIMEOUT   how long workers stay loaded without requests, see idleConfig
+//	BOTFRAMEWORK_CAPABILITY_CHECK off launches models the engine is not known to load, see checkWorkerModel
+func configureRouting(ctx context.Context, manager *engine.ModelManager, remote remoteConfig, gpus profiler.GPUAssignments, idle idleConfig) {
+	migSlots := newMIGAllocator(manager.Profile)
+	defaults, tiered := tierDefaults(manager.Profile)
+	if tiered {
+		slog.Info("applying tier defaults", "tier", manager.Profile.ClassifyTier(), "context", defaults.ContextSize,
+			"batch", defaults.BatchSize, "gpu_layers", defaults.GPULayers, "max_tokens", defaults.MaxTokens)
+	}
+	if spec := os.Getenv("BOTFRAMEWORK_MIG_DEVICE"); spec != "" {
+		if worker, ok := manager.Engine.(*supervisor.PythonWorker); ok {
+			if err := migSlots.assign(worker, spec); err != nil {
+				slog.Warn("MIG assignment skipped", "err", err)
+			}
+		}
+	}
+
+	if path := os.Getenv("BOTFRAMEWORK_SHADOW_LOG"); path != "" {
+		// the mirrored responses answer users' prompts, so only the manager's user reads them
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			slog.Warn("cannot open shadow log", "path", path, "err", err)
+		} else {
+			manager.SetShadowLog(file)
+		}
+	}
+
+	for model, chain := range parseFallbacks(os.Getenv("BOTFRAMEWORK_FALLBACKS")) {
+		manager.SetFallbacks(model, chain)
+	}
+
+	modelPath := os.Getenv("BOTFRAMEWORK_MODEL_PATH")
+	scheduler, err := newScheduler()
+	if err != nil {
+		slog.Warn("running workers locally", "err", err)
+	}
+	llamaServer, useLlamaServer := llamaServerBinary(manager)
+	useLlamaServer = useLlamaServer && scheduler == nil
+	vllm, useVLLM := vllmBinary(manager)
+	useVLLM = useVLLM && scheduler == nil
+	mlxInterpreter, useMLX := mlxPython(manager)
+	useMLX = useMLX && scheduler == nil
+	docker, useDocker := dockerClient()
+	useDocker = useDocker && scheduler == nil
+	if spec := os.Getenv("BOTFRAMEWORK_DRAFT_MODEL"); !useLlamaServer && spec != "" && spec != "auto" && spec != "off" {
+		slog.Warn("speculative decoding needs llama-server; draft model ignored", "draft", spec)
+	}
+	useGrpc := useGrpcWorkers() && scheduler == nil
+	var defaultRuntime workerRuntime
+	switch {
+	case useLlamaServer:
+		defaultRuntime.llamaServer = llamaServer
+	case useVLLM:
+		defaultRuntime.vllm = vllm
+	case useMLX:
+		defaultRuntime.mlx = mlxInterpreter
+	case useDocker:
+		defaultRuntime.docker = docker
+	}
+	runtimeEngine := defaultRuntime.engine(manager.Backend)
+	workerScript := ""
+	if worker, ok := manager.Engine.(*supervisor.PythonWorker); ok && remote.url != "" {
+		// the URL was checked when the configuration was loaded
+		manager.Engine, _ = supervisor.NewRemoteEngine(remote.url, remote.options)
+		workerPorts.Release(worker.Port)
+		workerScript = worker.ScriptPath
+	} else if ok {
+		if modelPath != "" && scheduler == nil {
+			if err := checkWorkerModel(runtimeEngine, modelPath, ""); err != nil {
+				log.Fatalf("Cannot start worker: %v", err)
+			}
+		}
+		worker.ModelPath = modelPath
+		workerScript = worker.ScriptPath
+		if scheduler != nil {
+			manager.Engine = newClusterWorker(scheduler, worker.ScriptPath, worker.Port, modelPath)
+		} else if useLlamaServer && modelPath != "" {
+			llama := newLlamaCppWorker(llamaServer, worker.Port, modelPath, "", manager.Profile)
+			llama.Env = worker.Env
+			manager.Engine = llama
+		} else if useVLLM && modelPath != "" {
+			vllmWorker, err := newVLLMWorker(vllm, worker.Port, modelPath, "", manager.Profile)
+			if err != nil {
+				log.Fatalf("Cannot start worker: %v", err)
+			}
+			vllmWorker.Env = worker.Env
+			manager.Engine = vllmWorker
+		} else if useMLX && modelPath != "" {
+			mlxWorker, err := newMLXWorker(mlxInterpreter, worker.Port, modelPath, manager.Profile)
+			if err != nil {
+				log.Fatalf("Cannot start worker: %v", err)
+			}
+			mlxWorker.Env = worker.Env
+			manager.Engine = mlxWorker
+		} else if useDocker && modelPath != "" {
+			manager.Engine = newDockerWorker(docker, worker.Port, modelPath, "", manager.Profile)
+		} else if pool := newWorkerPool(worker, migSlots); pool != nil {
+			manager.Engine = pool
+		} else if useGrpc {
+			manager.Engine = newGrpcWorker(worker)
+		}
+	}
+	if devices, ok := gpus[profiler.DefaultWorkerGPUs]; ok {
+		pinGPUs(profiler.DefaultWorkerGPUs, manager.Engine, devices)
+	}
+	if tiered {
+		applyTierDefaults(manager.Engine, defaults)
+	}
+	idle.unloadWhenIdle(manager, "default", manager.Engine)
+	if modelPath != "" {
+		manager.Register(modelName(modelPath), manager.Engine)
+	}
+
+	// with models declared up front, requests for any other model are rejected by default
+	models := parseModels(os.Getenv("BOTFRAMEWORK_MODELS"))
+	manifest, err := loadManifest(manager.Profile)
+	if err != nil {
+		log.Fatalf("Invalid model manifest:\n%v", err)
+	}
+	models = append(models, manifest...)
+	manager.UnknownModels = engine.UnknownModelDefault
+	if len(models) > 0 {
+		manager.UnknownModels = engine.UnknownModelReject
+	}
+	if spec := os.Getenv("BOTFRAMEWORK_UNKNOWN_MODEL"); spec != "" {
+		policy, err := engine.ParseUnknownModelPolicy(spec)
+		if err != nil {
+			log.Fatalf("BOTFRAMEWORK_UNKNOWN_MODEL: %v", err)
+		}
+		manager.UnknownModels = policy
+	}
+
+	modelDir := os.Getenv("BOTFRAMEWORK_MODEL_DIR")
+	cacheDir := modelCacheDir()
+	if _, err := os.Stat(cacheDir); err != nil {
+		cacheDir = ""
+	}
+
+	// each on-demand or declared worker gets a free port, given back if it fails to start.
+	// Embedding workers always run locally; they are small next to the chat model.
+	launch := func(model declaredModel, mode string) (e engine.InferenceEngine, err error) {
+		name, path := model.name, model.path
+		runtime := defaultRuntime
+		if model.runtime != nil {
+			runtime = *model.runtime
+		}
+		if scheduler == nil || mode != "" {
+			if err := checkWorkerModel(runtime.engine(manager.Backend), path, mode); err != nil {
+				return nil, err
+			}
+		}
+		port, err := workerPorts.Allocate(filepath.Base(path))
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				workerPorts.Release(port)
+			}
+		}()
+		if scheduler != nil && mode == "" {
+			worker := newClusterWorker(scheduler, workerScript, port, path)
+			if err := worker.Start(ctx); err != nil {
+				return nil, err
+			}
+			return worker, nil
+		}
+		defer func() {
+			if err == nil {
+				idle.unloadWhenIdle(manager, name, e)
+			}
+			if mode == "" {
+				recordLoad(manager.Profile, path, err)
+			}
+		}()
+
+		devices, pinned := gpus[name]
+		if model.gpus != nil {
+			devices, pinned = model.gpus, true
+		}
+		if runtime.docker != nil {
+			worker := newDockerWorker(runtime.docker, port, path, mode, manager.Profile)
+			if pinned {
+				pinGPUs(name, worker, devices)
+			}
+			if tiered {
+				applyTierDefaults(worker, defaults)
+			}
+			limitContext(worker, model.maxContext)
+			warmWith(worker, model.warmup)
+			if err := worker.Start(ctx); err != nil {
+				return nil, err
+			}
+			return worker, nil
+		}
+
+		if runtime.llamaServer != "" {
+			worker := newLlamaCppWorker(runtime.llamaServer, port, path, mode, manager.Profile)
+			if pinned {
+				pinGPUs(name, worker, devices)
+			} else {
+				migSlots.assignNext(worker.PythonWorker)
+			}
+			if tiered {
+				applyTierDefaults(worker, defaults)
+			}
+			limitContext(worker, model.maxContext)
+			warmWith(worker, model.warmup)
+			if err := worker.Start(ctx); err != nil {
+				return nil, err
+			}
+			return worker, nil
+		}
+
+		if runtime.vllm != "" {
+			worker, err := newVLLMWorker(runtime.vllm, port, path, mode, manager.Profile)
+			if err != nil {
+				return nil, err
+			}
+			if pinned {
+				pinGPUs(name, worker, devices)
+			} else if worker.GPUs == nil {
+				migSlots.assignNext(worker.PythonWorker)
+			}
+			if tiered {
+				applyTierDefaults(worker, defaults)
+			}
+			limitContext(worker, model.maxContext)
+			warmWith(worker, model.warmup)
+			if err := worker.Start(ctx); err != nil {
+				return nil, err
+			}
+			return worker, nil
+		}
+
+		if runtime.mlx != "" && mode == "" {
+			// mlx_lm.server only serves chat; embedding models stay on the Python worker
+			worker, err := newMLXWorker(runtime.mlx, port, path, manager.Profile)
+			if err != nil {
+				return nil, err
+			}
+			warmWith(worker, model.warmup)
+			if err := worker.Start(ctx); err != nil {
+				return nil, err
+			}
+			return worker, nil
+		}
+
+		worker := supervisor.NewPythonWorker(workerScript, port)
+		worker.ModelPath = path
+		worker.Mode = mode
+		if pinned {
+			pinGPUs(name, worker, devices)
+		}
+		if tiered {
+			applyTierDefaults(worker, defaults)
+		}
+		limitContext(worker, model.maxContext)
+		warmWith(worker, model.warmup)
+		var loaded engine.InferenceEngine = worker
+		if useGrpc {
+			grpcWorker := newGrpcWorker(worker)
+			worker, loaded = grpcWorker.PythonWorker, grpcWorker
+		}
+		// the slice is taken as the worker starts, so it goes on the worker that runs
+		if !pinned {
+			migSlots.assignNext(worker)
+		}
+		if err := loaded.Start(ctx); err != nil {
+			return nil, err
+		}
+		return loaded, nil
+	}
+
+	var local []declaredModel
+	for _, model := range models {
+		if isRemoteModel(model.path) {
+			e, err := supervisor.NewRemoteEngine(model.path, remote.options)
+			if err == nil {
+				slog.Info("attaching to remote model", "model", model.name, "url", model.path)
+				err = e.Start(ctx)
+			}
+			if err != nil {
+				slog.Error("declared model not started", "model", model.name, "err", err)
+				continue
+			}
+			manager.Register(model.name, e)
+			continue
+		}
+		if _, err := os.Stat(model.path); err != nil {
+			path, err := findModel(modelDir, cacheDir, model.path)
+			if err != nil {
+				if manifest != nil {
+					log.Fatalf("Invalid model manifest: %s: %v", model.name, err)
+				}
+				slog.Error("declared model not started", "model", model.name, "err", err)
+				continue
+			}
+			model.path = path
+		}
+		if devices, ok := gpus[model.name]; ok && model.gpus == nil {
+			model.gpus = devices
+		}
+		local = append(local, model)
+	}
+	// a manifest is provisioned only when everything it serves fits, with the default model
+	if manifest != nil && scheduler == nil {
+		placed := local
+		if modelPath != "" && remote.url == "" {
+			placed = append(slices.Clone(local), declaredModel{name: profiler.DefaultWorkerGPUs, path: modelPath, gpus: gpus[profiler.DefaultWorkerGPUs]})
+		}
+		if err := placeModels(manager.Profile, placed, defaults.ContextSize); err != nil {
+			log.Fatalf("Cannot serve the model manifest: %v", err)
+		}
+	}
+	for _, model := range local {
+		slog.Info("starting declared model", "model", model.name, "path", model.path)
+		e, err := launch(model, "")
+		if err != nil {
+			slog.Error("declared model not started", "model", model.name, "err", err)
+			continue
+		}
+		manager.Register(model.name, e)
+	}
+
+	startEmbeddingModel(manager, modelDir, cacheDir, func(name, path string) (engine.InferenceEngine, error) {
+		return launch(declaredModel{name: name, path: path}, supervisor.ModeEmbedding)
+	})
+
+	if modelDir == "" && cacheDir == "" {
+		return
+	}
+	manager.Loader = func(model string) (engine.InferenceEngine, error) {
+		path, err := findModel(modelDir, cacheDir, model)
+		if err != nil {
+			return nil, err
+		}
+		return launch(declaredModel{name: model, path: path}, "")
+	}
+	manager.Residency = newResidency(manager.Profile, modelDir, cacheDir)
+}
+
+type declaredModel struct {
+	name string
+	path string // model file, or a name findModel resolves
+	// set by the manifest: the worker runtime (nil for the manager's), the GPUs, the
+	// context cap and the warmup prompts (nil for BOTFRAMEWORK_WARMUP's)
+	runtime    *workerRuntime
+	gpus       []int
+	maxContext int
+	warmup     []string
+}
+
+// workerRuntime is the program local workers run; the zero value runs the Python worker
+type workerRuntime struct {
+	llamaServer string // llama-server binary
+	vllm        string // vllm binary
+	mlx         string // Python with mlx_lm
+	docker      *supervisor.DockerClient
+}
+
+// engine is the inference engine the runtime loads models with
+func (r workerRuntime) engine(backend profiler.Engine) profiler.Engine {
+	return workerEngine(backend, r.llamaServer != "", r.vllm != "", r.mlx != "", r.docker != nil)
+}
+
+// parseModels reads BOTFRAMEWORK_MODELS, "fast=/models/phi-3.gguf,quality=llama-2-13b:Q4_K_M":
+// each entry names a model and the file or cached download serving it. A bare entry is
+// served under its own name. Entries missing a name or path, and later entries reusing a
+// name, are skipped with a warning.
+func parseModels(spec string) []declaredModel {
+	var models []declaredModel
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(entry, "=")
+		if !ok {
+			name, path = entry, entry
+		}
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		switch {
+		case name == "" || path == "":
+			slog.Warn("ignoring BOTFRAMEWORK_MODELS entry without a name and path", "entry", entry)
+		case seen[name]:
+			slog.Warn("ignoring duplicate BOTFRAMEWORK_MODELS entry", "model", name, "path", path)
+		default:
+			seen[name] = true
+			models = append(models, declaredModel{name: name, path: path})
+		}
+	}
+	return models
+}
+
+// modelName is the name a model file is served under, the one requests use to load it on
+// demand: "/models/phi-3.gguf" is "phi-3"
+func modelName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".gguf")
+}
+
+// findModel locates <model>.gguf in the model dir, then a downloaded copy in the cache,
+// addressed as "<model id>" or "<model id>:<quant>". Names come from requests, so each part
+// must be a single path element that stays inside the directory.
+func findModel(modelDir, cacheDir, model string) (string, error) {
+	id, quant, _ := strings.Cut(model, ":")
+	if !localName(id) || (quant != "" && !localName(quant)) {
+		return "", fmt.Errorf("invalid model name %q", model)
+	}
+	var statErr error
+	if modelDir != "" {
+		path := filepath.Join(modelDir, model+".gguf")
+		if _, statErr = os.Stat(path); statErr == nil {
+			return path, nil
+		}
+	}
+	if cacheDir != "" {
+		if path, ok := download.Find(cacheDir, id, quant); ok {
+			return path, nil
+		}
+	}
+	if statErr != nil {
+		return "", fmt.Errorf("model file not found: %w", statErr)
+	}
+	return "", fmt.Errorf("model %q is not in the model cache", model)
+}
+
+// localName reports whether name is one path element naming something inside a directory
+func localName(name string) bool {
+	return filepath.IsLocal(name) && !strings.ContainsAny(name, `/\`) && name != "."
+}
+
+func parseFallbacks(spec string) map[string][]string {
+	chains := make(map[string][]string)
+	for _, entry := range strings.Split(spec, ";") {
+		model, rest, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || model == "" {
+			continue
+		}
+		for _, fallback := range strings.Split(rest, ",") {
+			if fallback = strings.TrimSpace(fallback); fallback != "" {
+				chains[model] = append(chains[model], fallback)
+			}
+		}
+	}
+	return chains
+}
+
+// migAllocator hands out MIG slices so each worker gets its own hardware-isolated partition
+type migAllocator struct {
+	mu      sync.Mutex
+	profile *profiler.HardwareProfile
+	used    map[string]bool
+}
+
+func newMIGAllocator(profile *profiler.HardwareProfile) *migAllocator {
+	return &migAllocator{profile: profile, used: make(map[string]bool)}
+}
+
+// assign pins the worker to the slice spec names, reserved for it for as long as the
+// manager runs
+func (a *migAllocator) assign(worker *supervisor.PythonWorker, spec string) error {
+	if a.profile == nil {
+		return fmt.Errorf("no hardware profile available")
+	}
+	device, err := a.profile.FindMIGDevice(spec)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.used[device.UUID] = true
+	a.mu.Unlock()
+	pinToMIG(worker, device)
+	return nil
+}
+
+// assignNext pins the worker to the first unused MIG slice, if the host has any, each time
+// it starts, and frees the slice when it stops or fails to start: on idle unload, eviction
+// or a failed launch. A worker started while every slice is taken runs unpinned.
+func (a *migAllocator) assignNext(worker *supervisor.PythonWorker) {
+	if a.profile == nil || len(a.profile.MIGDevices) == 0 {
+		return
+	}
+	var held string
+	var env []string
+	worker.Acquire = func() error {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if held != "" {
+			return nil
+		}
+		for _, device := range a.profile.MIGDevices {
+			if !a.used[device.UUID] {
+				a.used[device.UUID] = true
+				held, env = device.UUID, worker.Env
+				pinToMIG(worker, device)
+				return nil
+			}
+		}
+		return nil
+	}
+	worker.Release = func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if held == "" {
+			return
+		}
+		delete(a.used, held)
+		held, worker.Env = "", env
+	}
+}
+
+func pinToMIG(worker *supervisor.PythonWorker, device profiler.MIGDevice) {
+	slog.Info("pinning worker to MIG device", "port", worker.Port, "profile", device.Profile, "uuid", device.UUID)
+	worker.Env = append(worker.Env, "CUDA_VISIBLE_DEVICES="+device.UUID)
+}
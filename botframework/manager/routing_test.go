@@ -0,0 +1,137 @@
+package main
+
+import (
+	"botframework/profiler"
+	"botframework/supervisor"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindModelStaysInsideItsDirectories(t *testing.T) {
+	root := t.TempDir()
+	modelDir, cacheDir := filepath.Join(root, "models"), filepath.Join(root, "cache")
+	for _, path := range []string{
+		filepath.Join(modelDir, "phi-3.gguf"),
+		filepath.Join(root, "outside.gguf"),
+		filepath.Join(cacheDir, "llama-3-8b", "Q4_K_M", "llama-3-8b.Q4_K_M.gguf"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if path, err := findModel(modelDir, cacheDir, "phi-3"); err != nil || path != filepath.Join(modelDir, "phi-3.gguf") {
+		t.Errorf("phi-3: path %q, err %v", path, err)
+	}
+	if path, err := findModel(modelDir, cacheDir, "llama-3-8b:Q4_K_M"); err != nil || !strings.HasPrefix(path, cacheDir) {
+		t.Errorf("llama-3-8b:Q4_K_M: path %q, err %v", path, err)
+	}
+	for _, name := range []string{"../outside", "../../x", "/etc/passwd", "sub/phi-3", `..\outside`, "..", "llama-3-8b:../Q4_K_M", "..:Q4_K_M"} {
+		if path, err := findModel(modelDir, cacheDir, name); err == nil || !strings.Contains(err.Error(), "invalid model name") {
+			t.Errorf("%q: path %q, err %v; want an invalid name", name, path, err)
+		}
+	}
+}
+
+func TestModelNameMatchesFindModel(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "phi-3.gguf")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	name := modelName(path)
+	if name != "phi-3" {
+		t.Fatalf("modelName(%q) = %q, want phi-3", path, name)
+	}
+	if found, err := findModel(dir, "", name); err != nil || found != path {
+		t.Errorf("findModel(%q) = %q, %v; want %q", name, found, err, path)
+	}
+}
+
+func TestParseModels(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want []declaredModel
+	}{
+		{"", nil},
+		{" , ,", nil},
+		{"fast=/models/phi-3.gguf,quality=llama-2-13b:Q4_K_M", []declaredModel{
+			{name: "fast", path: "/models/phi-3.gguf"},
+			{name: "quality", path: "llama-2-13b:Q4_K_M"},
+		}},
+		{" fast = /models/phi-3.gguf ,, phi-3 ", []declaredModel{
+			{name: "fast", path: "/models/phi-3.gguf"},
+			{name: "phi-3", path: "phi-3"},
+		}},
+		{"fast=,=/models/phi-3.gguf,=,quality=llama-2-13b", []declaredModel{
+			{name: "quality", path: "llama-2-13b"},
+		}},
+		{"fast=/models/phi-3.gguf,fast=/models/llama.gguf,phi-3,phi-3", []declaredModel{
+			{name: "fast", path: "/models/phi-3.gguf"},
+			{name: "phi-3", path: "phi-3"},
+		}},
+	} {
+		got := parseModels(tc.spec)
+		if !slices.EqualFunc(got, tc.want, func(a, b declaredModel) bool { return a.name == b.name && a.path == b.path }) {
+			t.Errorf("%q: models = %+v, want %+v", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestMIGSlicesFreedWhenWorkersStop(t *testing.T) {
+	profile := &profiler.HardwareProfile{MIGDevices: []profiler.MIGDevice{{UUID: "MIG-a"}, {UUID: "MIG-b"}}}
+	slots := newMIGAllocator(profile)
+	pinned := func(worker *supervisor.PythonWorker) string {
+		for _, entry := range worker.Env {
+			if uuid, ok := strings.CutPrefix(entry, "CUDA_VISIBLE_DEVICES="); ok {
+				return uuid
+			}
+		}
+		return ""
+	}
+
+	// a launch that fails gives its slice back
+	failed := supervisor.NewPythonWorker("worker.py", "0")
+	failed.Command = func(ctx context.Context) (*exec.Cmd, error) { return nil, errors.New("no such engine") }
+	slots.assignNext(failed)
+	if err := failed.Start(context.Background()); err == nil {
+		t.Fatal("want the start to fail")
+	}
+	if got := pinned(failed); got != "" {
+		t.Errorf("failed worker still pinned to %q", got)
+	}
+
+	workers := make([]*supervisor.PythonWorker, 3)
+	for i := range workers {
+		workers[i] = supervisor.NewPythonWorker("worker.py", "0")
+		slots.assignNext(workers[i])
+		if err := workers[i].Acquire(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a, b, c := pinned(workers[0]), pinned(workers[1]), pinned(workers[2]); a != "MIG-a" || b != "MIG-b" || c != "" {
+		t.Fatalf("pinned to %q, %q, %q; want MIG-a, MIG-b and none", a, b, c)
+	}
+
+	// an idle unload or eviction stops the worker, freeing its slice for the next start
+	workers[0].Stop()
+	if got := pinned(workers[0]); got != "" {
+		t.Errorf("stopped worker still pinned to %q", got)
+	}
+	workers[2].Release()
+	if err := workers[2].Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	if got := pinned(workers[2]); got != "MIG-a" {
+		t.Errorf("restarted worker pinned to %q, want the freed MIG-a", got)
+	}
+}
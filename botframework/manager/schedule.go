@@ -0,0 +1,39 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/schedule"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// newModelScheduler applies time-based warm/unload policies from BOTFRAMEWORK_MODEL_SCHEDULE
+// (see schedule.Parse), evaluated in BOTFRAMEWORK_SCHEDULE_TZ (default: local time).
+// Returns nil when no policies are configured.
+func newModelScheduler(manager *engine.ModelManager) *schedule.Scheduler {
+	spec := os.Getenv("BOTFRAMEWORK_MODEL_SCHEDULE")
+	if spec == "" {
+		return nil
+	}
+	policies, err := schedule.Parse(spec)
+	if err != nil {
+		slog.Warn("model schedule disabled", "err", err)
+		return nil
+	}
+	if manager.Loader == nil {
+		slog.Warn("model schedule: warm policies need BOTFRAMEWORK_MODEL_DIR to load models")
+	}
+
+	scheduler := schedule.New(manager, policies)
+	if tz := os.Getenv("BOTFRAMEWORK_SCHEDULE_TZ"); tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			slog.Warn("unknown BOTFRAMEWORK_SCHEDULE_TZ, using local time", "tz", tz, "err", err)
+		} else {
+			scheduler.Location = location
+		}
+	}
+	slog.Info("loaded model schedule", "policies", len(policies))
+	return scheduler
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"botframework/service"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// serviceEnvPrefixes are the variables a service install carries over from the installing
+// shell, beside PATH and HOME, which find the engines and the model cache
+var serviceEnvPrefixes = []string{"BOTFRAMEWORK_", "OTEL_", "HF_", "CUDA_VISIBLE_DEVICES", "HIP_VISIBLE_DEVICES", "ROCR_VISIBLE_DEVICES", "DOCKER_HOST"}
+
+// envFlag collects repeated --env KEY=VALUE flags
+type envFlag map[string]string
+
+func (e envFlag) String() string { return fmt.Sprint(map[string]string(e)) }
+
+func (e envFlag) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("want KEY=VALUE, got %q", value)
+	}
+	e[name] = val
+	return nil
+}
+
+// runService registers the manager with the init system or controls the registered one:
+//
+//	manager service install [--user] [--name N] [--log-dir D] [--env KEY=VALUE]... [-- manager flags]
+//	manager service uninstall|start|stop [--user] [--name N]
+//
+// install records the working directory, the manager flags after --, and the BOTFRAMEWORK_,
+// OTEL_ and HF_ variables, GPU visibility, DOCKER_HOST, PATH and HOME of the current shell.
+func runService(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: manager service install|uninstall|start|stop [flags]")
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	name := fs.String("name", "botframework", "service name")
+	user := fs.Bool("user", false, "install a per-user service (systemd user unit, or a Windows task that starts at logon)")
+	logDir := fs.String("log-dir", "", "directory for manager.log (default: the platform's log directory)")
+	env := envFlag{}
+	fs.Var(env, "env", "extra environment variable for the service, KEY=VALUE (repeatable)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	cfg := service.Config{Name: *name, Args: fs.Args(), LogDir: *logDir, User: *user, Env: serviceEnv(env)}
+	var err error
+	if cfg.Executable, err = os.Executable(); err != nil {
+		return err
+	}
+	if cfg.Executable, err = filepath.EvalSymlinks(cfg.Executable); err != nil {
+		return err
+	}
+	if cfg.WorkDir, err = os.Getwd(); err != nil {
+		return err
+	}
+	if cfg.Home, err = os.UserHomeDir(); err != nil {
+		return err
+	}
+	plan, err := service.For(runtime.GOOS, cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	switch action {
+	case "install":
+		// go run builds into a temporary directory that is deleted when it exits
+		if strings.Contains(cfg.Executable, "go-build") {
+			return fmt.Errorf("%s is a temporary go run build; build the manager (go build -o botframework ./manager) and install with that binary", cfg.Executable)
+		}
+		if err := plan.WriteFiles(); err != nil {
+			return err
+		}
+		if err := service.Run(ctx, os.Stdout, plan.Install); err != nil {
+			return err
+		}
+		fmt.Printf("installed %s; it logs to %s\n", cfg.Name, plan.LogPath)
+		return nil
+	case "uninstall":
+		if err := service.Run(ctx, os.Stdout, plan.Uninstall); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+		if err := plan.RemoveFiles(); err != nil {
+			return err
+		}
+		return service.Run(ctx, os.Stdout, plan.Reload)
+	case "start":
+		return service.Run(ctx, os.Stdout, plan.Start)
+	case "stop":
+		return service.Run(ctx, os.Stdout, plan.Stop)
+	}
+	return fmt.Errorf("unknown service action %q (want install, uninstall, start or stop)", action)
+}
+
+// serviceEnv is the environment the service runs with: the variables the manager reads from
+// the current shell, overridden by --env
+func serviceEnv(extra map[string]string) map[string]string {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		if name == "PATH" || name == "HOME" {
+			env[name] = value
+			continue
+		}
+		for _, prefix := range serviceEnvPrefixes {
+			if strings.HasPrefix(name, prefix) {
+				env[name] = value
+			}
+		}
+	}
+	for name, value := range extra {
+		env[name] = value
+	}
+	return env
+}
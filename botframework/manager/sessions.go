@@ -0,0 +1,54 @@
+package main
+
+import (
+	"botframework/chat"
+	"botframework/sessions"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// newSessions stores chat sessions when BOTFRAMEWORK_SESSIONS=on, in BOTFRAMEWORK_SESSIONS_DIR
+// (default: the user cache directory). BOTFRAMEWORK_SESSION_HISTORY picks how much of a session
+// is sent with each request (window, tokens or summarize), bounded by
+// BOTFRAMEWORK_SESSION_MAX_MESSAGES and BOTFRAMEWORK_SESSION_MAX_TOKENS. Returns nil when disabled.
+func newSessions(port string, counter chat.TokenCounter) (*sessions.Sessions, error) {
+	if os.Getenv("BOTFRAMEWORK_SESSIONS") != "on" {
+		return nil, nil
+	}
+	dir := os.Getenv("BOTFRAMEWORK_SESSIONS_DIR")
+	if dir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			cache = os.TempDir()
+		}
+		dir = filepath.Join(cache, "botframework", "sessions")
+	}
+	store, err := sessions.NewFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	history := sessions.NewHistory()
+	history.Counter = counter
+	switch strategy := os.Getenv("BOTFRAMEWORK_SESSION_HISTORY"); strategy {
+	case "", sessions.StrategyTokens:
+	case sessions.StrategyWindow:
+		history.Strategy = sessions.StrategyWindow
+	case sessions.StrategySummarize:
+		history.Strategy = sessions.StrategySummarize
+		history.Summarizer = newSummarizer(port)
+	default:
+		return nil, fmt.Errorf("BOTFRAMEWORK_SESSION_HISTORY: unknown strategy %q", strategy)
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_SESSION_MAX_MESSAGES")); err == nil && n > 0 {
+		history.MaxMessages = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_SESSION_MAX_TOKENS")); err == nil && n > 0 {
+		history.MaxTokens = n
+	}
+	slog.Info("storing chat sessions", "dir", dir, "history", history.Strategy)
+	return &sessions.Sessions{Store: store, History: history}, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"botframework/profiler"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// hardwareProfile detects this host, its model disk and power state, or simulates the machine
+// BOTFRAMEWORK_SIMULATE_PROFILE names: a preset such as rtx-4090, or a JSON or YAML spec file
+func hardwareProfile() (profile *profiler.HardwareProfile, simulated string, err error) {
+	simulated = os.Getenv("BOTFRAMEWORK_SIMULATE_PROFILE")
+	if simulated == "" {
+		profile = detectHardware()
+		detectModelDisk(profile)
+		detectPower(profile)
+		return profile, "", nil
+	}
+	if spec, ok := profiler.SpecPresets[simulated]; ok {
+		profile, err = spec.Profile()
+		return profile, simulated, err
+	}
+	data, err := os.ReadFile(simulated)
+	if err != nil {
+		return nil, "", fmt.Errorf("simulated profile %q is neither a preset (%v) nor a spec file: %w",
+			simulated, slices.Sorted(maps.Keys(profiler.SpecPresets)), err)
+	}
+	profile, err = profiler.FromSpec(data)
+	return profile, simulated, err
+}
+
+// detectHardware profiles this host, reusing the profile cached by an earlier run while the
+// hardware's fingerprint (CPU model, GPU PCI IDs, RAM size) is unchanged:
+//
+//	BOTFRAMEWORK_PROFILE_CACHE      cache file (default: ~/.cache/botframework/hardware.json), off to detect every time
+//	BOTFRAMEWORK_PROFILE_CACHE_TTL  how long a cached profile is trusted (default: 168h)
+//	BOTFRAMEWORK_FORCE_REDETECT     true detects the hardware again and refreshes the cache (--force-redetect)
+func detectHardware() *profiler.HardwareProfile {
+	path := os.Getenv("BOTFRAMEWORK_PROFILE_CACHE")
+	if path == "off" {
+		slog.Info("scanning hardware")
+		return profiler.DetectHardware()
+	}
+	if path == "" {
+		path = profiler.DefaultProfileCachePath()
+	}
+	ttl := profiler.DefaultProfileCacheTTL
+	if value := os.Getenv("BOTFRAMEWORK_PROFILE_CACHE_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			slog.Warn("invalid BOTFRAMEWORK_PROFILE_CACHE_TTL, using the default", "value", value, "default", ttl)
+		} else {
+			ttl = parsed
+		}
+	}
+	force, _ := strconv.ParseBool(os.Getenv("BOTFRAMEWORK_FORCE_REDETECT"))
+	profile, cached := profiler.DetectHardwareCached(path, ttl, force)
+	if cached {
+		slog.Info("using cached hardware profile", "path", path)
+	} else {
+		slog.Info("hardware scanned", "cache", path)
+	}
+	return profile
+}
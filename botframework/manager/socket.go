@@ -0,0 +1,22 @@
+package main
+
+import (
+	"botframework/websocket"
+	"context"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// newChatSocket streams chat completions over WebSocket at /ws/chat on top of backend, the
+// OpenAI-compatible inference handler. BOTFRAMEWORK_WS_ORIGINS lists the browser origins
+// besides the manager's own that may connect ("*" for any).
+func newChatSocket(ctx context.Context, backend http.Handler) *websocket.ChatHandler {
+	handler := websocket.NewChatHandler(ctx, backend)
+	for _, origin := range strings.Split(os.Getenv("BOTFRAMEWORK_WS_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			handler.Origins = append(handler.Origins, origin)
+		}
+	}
+	return handler
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"botframework/engine"
+	"botframework/telemetry"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// newTelemetry builds the opt-in throughput telemetry collector. Nothing is collected
+// unless BOTFRAMEWORK_TELEMETRY is set, and nothing is sent without an endpoint:
+//
+//	BOTFRAMEWORK_TELEMETRY           preview (collect only, inspect /admin/telemetry) | on
+//	BOTFRAMEWORK_TELEMETRY_ENDPOINT  URL that receives the JSON report (required for on)
+//	BOTFRAMEWORK_TELEMETRY_INTERVAL  reporting interval (default: 24h)
+func newTelemetry(manager *engine.ModelManager) *telemetry.Collector {
+	mode := os.Getenv("BOTFRAMEWORK_TELEMETRY")
+	if mode == "" || mode == "off" {
+		return nil
+	}
+
+	endpoint := ""
+	switch mode {
+	case "on":
+		endpoint = os.Getenv("BOTFRAMEWORK_TELEMETRY_ENDPOINT")
+		if endpoint == "" {
+			slog.Warn("BOTFRAMEWORK_TELEMETRY=on without BOTFRAMEWORK_TELEMETRY_ENDPOINT, collecting for preview only")
+		}
+	case "preview":
+	default:
+		slog.Warn("unknown telemetry mode, telemetry disabled", "mode", mode)
+		return nil
+	}
+
+	tier := ""
+	if manager.Profile != nil {
+		tier = string(manager.Profile.ClassifyTier())
+	}
+	family := func(model string) string {
+		if m := currentRegistry().Lookup(model); m != nil {
+			return m.Family
+		}
+		return ""
+	}
+
+	collector := telemetry.NewCollector(tier, string(manager.Backend), endpoint, family)
+	if interval, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_TELEMETRY_INTERVAL")); err == nil && interval > 0 {
+		collector.Interval = interval
+	}
+	if endpoint != "" {
+		slog.Info("sending anonymous throughput telemetry (preview: /admin/telemetry)", "endpoint", endpoint, "interval", collector.Interval)
+	} else {
+		slog.Info("collecting telemetry for local preview only (/admin/telemetry)")
+	}
+	return collector
+}
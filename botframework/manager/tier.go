@@ -0,0 +1,77 @@
+package main
+
+import (
+	"botframework/profiler"
+	"botframework/supervisor"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// tierDefaults returns the generation settings for the host's hardware tier, which size
+// worker launches and bound requests, unless BOTFRAMEWORK_TIER_DEFAULTS=off
+func tierDefaults(profile *profiler.HardwareProfile) (profiler.TierDefaults, bool) {
+	if profile == nil || os.Getenv("BOTFRAMEWORK_TIER_DEFAULTS") == "off" {
+		return profiler.TierDefaults{}, false
+	}
+	return profile.TierDefaults(), true
+}
+
+// applyTierDefaults launches the workers behind e within the tier's context size. Python
+// workers, whose script would otherwise offload every layer, take the tier's batch size and
+// offload too; llama-server workers keep what LlamaCppFlagsFor sized for the hardware.
+func applyTierDefaults(e any, defaults profiler.TierDefaults) {
+	switch worker := e.(type) {
+	case *supervisor.PythonWorker:
+		worker.Args = append(worker.Args,
+			"--n-ctx", strconv.Itoa(defaults.ContextSize),
+			"--n-batch", strconv.Itoa(defaults.BatchSize),
+			"--n-gpu-layers", strconv.Itoa(defaults.GPULayers))
+	case *supervisor.GrpcWorker:
+		applyTierDefaults(worker.PythonWorker, defaults)
+	case *supervisor.LlamaCppWorker:
+		worker.Flags.ContextSize = min(worker.Flags.ContextSize, defaults.ContextSize)
+	case *supervisor.VLLMWorker:
+		worker.Flags.MaxModelLen = min(worker.Flags.MaxModelLen, defaults.ContextSize)
+	case *supervisor.DockerWorker:
+		if !slices.Contains(worker.Args, "--max-model-len") {
+			worker.Args = append(worker.Args, "--max-model-len", strconv.Itoa(defaults.ContextSize))
+		}
+	case *supervisor.WorkerPool:
+		for _, member := range worker.Workers() {
+			applyTierDefaults(member, defaults)
+		}
+	}
+}
+
+// limitContext caps the context the workers behind e are launched with at tokens, as a
+// manifest's max_context does; 0 leaves them as they are
+func limitContext(e any, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	switch worker := e.(type) {
+	case *supervisor.PythonWorker:
+		worker.Args = capFlag(worker.Args, "--n-ctx", tokens)
+	case *supervisor.GrpcWorker:
+		limitContext(worker.PythonWorker, tokens)
+	case *supervisor.LlamaCppWorker:
+		worker.Flags.ContextSize = min(worker.Flags.ContextSize, tokens)
+	case *supervisor.VLLMWorker:
+		worker.Flags.MaxModelLen = min(worker.Flags.MaxModelLen, tokens)
+	case *supervisor.DockerWorker:
+		worker.Args = capFlag(worker.Args, "--max-model-len", tokens)
+	}
+}
+
+// capFlag lowers the value of flag in args to limit, adding the flag when it is missing
+func capFlag(args []string, flag string, limit int) []string {
+	if i := slices.Index(args, flag); i >= 0 && i+1 < len(args) {
+		if n, err := strconv.Atoi(args[i+1]); err == nil && n <= limit {
+			return args
+		}
+		args[i+1] = strconv.Itoa(limit)
+		return args
+	}
+	return append(args, flag, strconv.Itoa(limit))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"botframework/listener"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tlsConfig is how the manager's TCP listeners serve HTTPS. Unix sockets stay plain, and so
+// do connections from loopback, which the manager uses to call its own routes.
+//
+//	BOTFRAMEWORK_TLS_CERT, BOTFRAMEWORK_TLS_KEY  PEM certificate chain and private key to serve
+//	BOTFRAMEWORK_TLS             self-signed to generate a certificate on first run, or acme to obtain
+//	                             one from Let's Encrypt; implied by BOTFRAMEWORK_TLS_CERT
+//	BOTFRAMEWORK_TLS_HOSTS       comma-separated names the certificate covers; acme needs them
+//	BOTFRAMEWORK_TLS_DIR         keeps generated certificates and the ACME account (default: the
+//	                             user config directory)
+//	BOTFRAMEWORK_ACME_EMAIL      contact address for certificate expiry notices
+//	BOTFRAMEWORK_ACME_DIRECTORY  ACME directory URL (default: Let's Encrypt production)
+//	BOTFRAMEWORK_HTTP_REDIRECT   plain HTTP addresses redirecting to HTTPS; acme answers its challenges
+//	                             there and defaults it to :80
+type tlsConfig struct {
+	config   *tls.Config
+	acme     *listener.ACME
+	redirect []listener.Addr
+}
+
+// loadTLSConfig returns nil when TLS is off
+func loadTLSConfig() (*tlsConfig, error) {
+	mode := os.Getenv("BOTFRAMEWORK_TLS")
+	certFile, keyFile := os.Getenv("BOTFRAMEWORK_TLS_CERT"), os.Getenv("BOTFRAMEWORK_TLS_KEY")
+	if certFile != "" || keyFile != "" {
+		if mode != "" {
+			return nil, fmt.Errorf("BOTFRAMEWORK_TLS=%s cannot be combined with BOTFRAMEWORK_TLS_CERT", mode)
+		}
+		mode = "files"
+	}
+	if mode == "" || mode == "off" {
+		return nil, nil
+	}
+
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("BOTFRAMEWORK_TLS_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	dir := os.Getenv("BOTFRAMEWORK_TLS_DIR")
+	if dir == "" {
+		config, err := os.UserConfigDir()
+		if err != nil {
+			config = os.TempDir()
+		}
+		dir = filepath.Join(config, "botframework", "tls")
+	}
+	redirect, err := listener.ParseList(os.Getenv("BOTFRAMEWORK_HTTP_REDIRECT"))
+	if err != nil {
+		return nil, fmt.Errorf("BOTFRAMEWORK_HTTP_REDIRECT: %w", err)
+	}
+
+	c := &tlsConfig{config: &tls.Config{MinVersion: tls.VersionTLS12}, redirect: redirect}
+	switch mode {
+	case "files":
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("BOTFRAMEWORK_TLS_CERT and BOTFRAMEWORK_TLS_KEY must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("TLS certificate: %w", err)
+		}
+		c.config.Certificates = []tls.Certificate{cert}
+		slog.Info("serving TLS", "cert", certFile, "expires", cert.Leaf.NotAfter)
+	case "self-signed":
+		hostname, _ := os.Hostname()
+		hosts = append(hosts, "localhost", "127.0.0.1", "::1")
+		if hostname != "" {
+			hosts = append(hosts, hostname)
+		}
+		cert, created, err := listener.SelfSigned(dir, hosts)
+		if err != nil {
+			return nil, fmt.Errorf("self-signed certificate: %w", err)
+		}
+		c.config.Certificates = []tls.Certificate{cert}
+		fingerprint := sha256.Sum256(cert.Leaf.Raw)
+		slog.Info("serving TLS with a self-signed certificate", "generated", created,
+			"cert", filepath.Join(dir, "self-signed.crt"), "sha256", hex.EncodeToString(fingerprint[:]))
+	case "acme":
+		if len(hosts) == 0 {
+			return nil, fmt.Errorf("BOTFRAMEWORK_TLS=acme needs the public host names in BOTFRAMEWORK_TLS_HOSTS")
+		}
+		c.acme = &listener.ACME{
+			DirectoryURL: os.Getenv("BOTFRAMEWORK_ACME_DIRECTORY"),
+			Email:        os.Getenv("BOTFRAMEWORK_ACME_EMAIL"),
+			Hosts:        hosts,
+			Dir:          filepath.Join(dir, "acme"),
+		}
+		c.config.GetCertificate = c.acme.GetCertificate
+		if len(c.redirect) == 0 {
+			// the CA connects to port 80 for HTTP-01 challenges
+			c.redirect = []listener.Addr{{Network: "tcp", Address: ":80"}}
+		}
+		slog.Info("serving TLS with an ACME certificate", "hosts", hosts, "dir", c.acme.Dir)
+	default:
+		return nil, fmt.Errorf("BOTFRAMEWORK_TLS: unknown mode %q (want self-signed or acme)", mode)
+	}
+	return c, nil
+}
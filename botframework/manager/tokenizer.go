@@ -0,0 +1,53 @@
+package main
+
+import (
+	"botframework/chat"
+	"botframework/download"
+	"botframework/tokenizer"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// newTokenCounter counts tokens with each model's tokenizer.json, which manager download
+// fetches into the model cache, and estimates them for models without one. Context
+// windows, guardrails, session history and the usage filled into responses all count
+// with it. BOTFRAMEWORK_TOKENIZER=off estimates every count.
+func newTokenCounter() chat.TokenCounter {
+	if os.Getenv("BOTFRAMEWORK_TOKENIZER") == "off" {
+		return chat.EstimateCounter{}
+	}
+	cache := tokenizer.NewCache(tokenizerPath)
+	slog.Info("counting tokens with model tokenizers", "cache", modelCacheDir())
+	return chat.TokenizedCounter{Tokenizer: func(model string) chat.TextTokenizer {
+		if t := cache.Get(model); t != nil {
+			return t
+		}
+		return nil
+	}}
+}
+
+// tokenizerPath finds the tokenizer.json of a model, by its registry ID, in the model cache:
+// the one downloaded beside the model's variants, or one inside a safetensors variant
+func tokenizerPath(model string) string {
+	id := model
+	if m := currentRegistry().Lookup(model); m != nil {
+		id = m.ID
+	}
+	if id == "" || filepath.Base(id) != id {
+		return ""
+	}
+	dir := filepath.Join(modelCacheDir(), id)
+	if path := filepath.Join(dir, download.TokenizerFile); fileExists(path) {
+		return path
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*", download.TokenizerFile)); len(matches) > 0 {
+		return matches[0]
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
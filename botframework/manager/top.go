@@ -0,0 +1,35 @@
+package main
+
+import (
+	"botframework/client"
+	"botframework/dashboard"
+	"context"
+	"flag"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runTop starts the terminal dashboard against a running manager. Keys work when stdin
+// is a terminal; otherwise the dashboard only displays.
+func runTop(args []string) error {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	url := fs.String("url", "http://127.0.0.1:8080", "manager base URL")
+	token := fs.String("token", "", "admin token")
+	interval := fs.Duration("interval", 2*time.Second, "refresh interval")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var keys io.Reader
+	if restore, err := dashboard.Cbreak(os.Stdin); err == nil {
+		defer restore()
+		keys = os.Stdin
+	}
+	return dashboard.Run(ctx, keys, os.Stdout, client.New(*url, *token), *interval)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"botframework/tracing"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// newTracer builds the OpenTelemetry tracer from the standard OTLP exporter settings.
+// Tracing is off unless an endpoint is set:
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT         collector base URL, e.g. http://localhost:4318
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT  full traces URL, used as is (overrides the above)
+//	OTEL_EXPORTER_OTLP_HEADERS          headers for the collector, "api-key=secret,x-tenant=a"
+//	OTEL_SERVICE_NAME                   service name on every span (default: botframework)
+func newTracer() (*tracing.Tracer, *tracing.Exporter) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	traces := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" && traces == "" {
+		return nil, nil
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "botframework"
+	}
+	exporter := tracing.NewExporter(endpoint, service)
+	if traces != "" {
+		exporter.Endpoint = traces
+	}
+	for _, header := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+		name, value, ok := strings.Cut(header, "=")
+		if name = strings.TrimSpace(name); ok && name != "" {
+			exporter.Header.Set(name, strings.TrimSpace(value))
+		}
+	}
+	slog.Info("exporting traces", "endpoint", exporter.Endpoint, "service", service)
+	return tracing.NewTracer(exporter), exporter
+}
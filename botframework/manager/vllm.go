@@ -0,0 +1,103 @@
+package main
+
+import (
+	"botframework/convert"
+	"botframework/engine"
+	"botframework/profiler"
+	"botframework/supervisor"
+	"cmp"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// vllmBinary decides whether workers run `vllm serve` instead of the Python worker.
+// BOTFRAMEWORK_WORKER_RUNTIME=vllm always does; auto (the default) does when the recommended
+// engine is vLLM and the binary is installed. BOTFRAMEWORK_VLLM names the binary, defaulting
+// to vllm on PATH.
+func vllmBinary(manager *engine.ModelManager) (string, bool) {
+	runtime := os.Getenv("BOTFRAMEWORK_WORKER_RUNTIME")
+	switch runtime {
+	case "python", "docker", "llama-server", "mlx":
+		return "", false
+	case "", "auto":
+		if manager.Backend != profiler.EngineVLLM {
+			return "", false
+		}
+	}
+
+	path, err := findVLLM()
+	if err != nil {
+		if runtime == "vllm" {
+			slog.Warn("vllm not found; using the Python worker", "err", err)
+		}
+		return "", false
+	}
+	return path, true
+}
+
+// findVLLM looks up BOTFRAMEWORK_VLLM, default vllm
+func findVLLM() (string, error) {
+	return exec.LookPath(cmp.Or(os.Getenv("BOTFRAMEWORK_VLLM"), "vllm"))
+}
+
+// newVLLMWorker creates a vLLM worker for modelPath in mode. Its parallelism, memory share
+// and context are sized from the hardware profile, the weights' size and the checkpoint's
+// config.json (or the registry), then overridden by:
+//
+//	BOTFRAMEWORK_VLLM_TENSOR_PARALLEL       --tensor-parallel-size
+//	BOTFRAMEWORK_VLLM_PIPELINE_PARALLEL     --pipeline-parallel-size
+//	BOTFRAMEWORK_VLLM_GPU_MEMORY_UTILIZATION --gpu-memory-utilization
+//	BOTFRAMEWORK_VLLM_MAX_MODEL_LEN         --max-model-len
+//	BOTFRAMEWORK_VLLM_ARGS                  further vllm serve arguments
+//
+// The worker validates the flags against the profile before it launches. Chat workers parse
+// tool calls like Docker's vLLM workers, see toolCallParser.
+func newVLLMWorker(binary, port, modelPath, mode string, profile *profiler.HardwareProfile) (*supervisor.VLLMWorker, error) {
+	model, err := profiler.CheckpointModel(modelPath)
+	if err != nil {
+		if known := currentRegistry().Lookup(variantName(modelPath)); known != nil {
+			model = *known
+		}
+	}
+	flags, err := supervisor.VLLMFlagsFor(profile, convert.SizeGB(modelPath), model)
+	if err != nil {
+		return nil, fmt.Errorf("sizing vllm for %s: %w", modelPath, err)
+	}
+	for _, override := range []struct {
+		env   string
+		value *int
+	}{
+		{"BOTFRAMEWORK_VLLM_TENSOR_PARALLEL", &flags.TensorParallel},
+		{"BOTFRAMEWORK_VLLM_PIPELINE_PARALLEL", &flags.PipelineParallel},
+		{"BOTFRAMEWORK_VLLM_MAX_MODEL_LEN", &flags.MaxModelLen},
+	} {
+		if value := os.Getenv(override.env); value != "" {
+			if *override.value, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("%s: %q is not a number", override.env, value)
+			}
+		}
+	}
+	if value := os.Getenv("BOTFRAMEWORK_VLLM_GPU_MEMORY_UTILIZATION"); value != "" {
+		if flags.GPUMemoryUtilization, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("BOTFRAMEWORK_VLLM_GPU_MEMORY_UTILIZATION: %q is not a number", value)
+		}
+	}
+	if flags.TensorParallel*flags.PipelineParallel != len(flags.Devices) {
+		// an overridden plan no longer spans the derived devices; let it use the first GPUs
+		flags.Devices = nil
+	}
+
+	slog.Info("using vllm", "binary", binary, "model", modelPath)
+	worker := supervisor.NewVLLMWorker(binary, port, modelPath, flags, profile)
+	worker.Mode = mode
+	worker.ExtraArgs = strings.Fields(os.Getenv("BOTFRAMEWORK_VLLM_ARGS"))
+	if mode == "" && !slices.Contains(worker.ExtraArgs, "--tool-call-parser") {
+		worker.ExtraArgs = append(worker.ExtraArgs, "--enable-auto-tool-choice", "--tool-call-parser", toolCallParser(modelPath))
+	}
+	return worker, nil
+}
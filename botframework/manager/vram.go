@@ -0,0 +1,53 @@
+package main
+
+import (
+	"botframework/profiler"
+	"botframework/vram"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// newVRAMMonitor watches GPU memory on hosts with a GPU, unless BOTFRAMEWORK_VRAM_MONITOR=off:
+//
+//	BOTFRAMEWORK_VRAM_MIN_FREE_MB        free memory per GPU below which actions engage
+//	BOTFRAMEWORK_VRAM_MIN_FREE_PERCENT   the same as a share of the GPU's total (default: 5)
+//	BOTFRAMEWORK_VRAM_ACTIONS            pause, shrink and/or alert, comma-separated (default: alert)
+//	BOTFRAMEWORK_VRAM_ALERT_URL          receives a JSON POST when pressure starts and ends
+//	BOTFRAMEWORK_VRAM_SHRINK_MAX_TOKENS  max_tokens cap while shrinking (default: 256)
+//	BOTFRAMEWORK_VRAM_INTERVAL           polling interval (default: 5s)
+func newVRAMMonitor(profile *profiler.HardwareProfile) (*vram.Monitor, time.Duration) {
+	interval := 5 * time.Second
+	if os.Getenv("BOTFRAMEWORK_VRAM_MONITOR") == "off" || profile == nil {
+		return nil, interval
+	}
+	if !profile.HasCuda && !profile.HasROCm && !profile.HasMetal {
+		return nil, interval
+	}
+
+	monitor := vram.NewMonitor(profiler.SampleVRAM)
+	if mb, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_VRAM_MIN_FREE_MB")); err == nil && mb >= 0 {
+		monitor.MinFreeMB = mb
+	}
+	if percent, err := strconv.ParseFloat(os.Getenv("BOTFRAMEWORK_VRAM_MIN_FREE_PERCENT"), 64); err == nil && percent >= 0 {
+		monitor.MinFreePercent = percent
+	}
+	if list := os.Getenv("BOTFRAMEWORK_VRAM_ACTIONS"); list != "" {
+		actions, err := vram.ParseActions(list)
+		if err != nil {
+			slog.Warn("keeping the default VRAM actions", "err", err)
+		} else {
+			monitor.Actions = actions
+		}
+	}
+	monitor.AlertURL = os.Getenv("BOTFRAMEWORK_VRAM_ALERT_URL")
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_VRAM_SHRINK_MAX_TOKENS")); err == nil && n > 0 {
+		monitor.ShrinkMaxTokens = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_VRAM_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	slog.Info("monitoring VRAM", "interval", interval, "actions", monitor.Actions)
+	return monitor, interval
+}
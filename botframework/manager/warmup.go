@@ -0,0 +1,25 @@
+package main
+
+import "botframework/supervisor"
+
+// warmWith replaces the prompts the worker behind e is warmed up with, as a manifest's
+// warmup does; nil keeps BOTFRAMEWORK_WARMUP's, see supervisor.DefaultWarmup
+func warmWith(e any, prompts []string) {
+	if prompts == nil {
+		return
+	}
+	switch worker := e.(type) {
+	case *supervisor.PythonWorker:
+		worker.Warmup.Prompts = prompts
+	case *supervisor.GrpcWorker:
+		worker.Warmup.Prompts = prompts
+	case *supervisor.LlamaCppWorker:
+		worker.Warmup.Prompts = prompts
+	case *supervisor.VLLMWorker:
+		worker.Warmup.Prompts = prompts
+	case *supervisor.MLXWorker:
+		worker.Warmup.Prompts = prompts
+	case *supervisor.DockerWorker:
+		worker.Warmup.Prompts = prompts
+	}
+}
@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxSamples      = 1024
+	maxRecentErrors = 20
+	rateWindow      = time.Minute
+)
+
+// ErrorEvent describes a failed request kept for the dashboard
+type ErrorEvent struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Status int       `json:"status"`
+}
+
+// Snapshot is a point-in-time summary of gateway traffic
+type Snapshot struct {
+	TotalRequests     uint64       `json:"total_requests"`
+	ErrorCount        uint64       `json:"error_count"`
+	InFlight          int64        `json:"in_flight"`
+	RequestsPerSecond float64      `json:"requests_per_second"`
+	LatencyP50Ms      float64      `json:"latency_p50_ms"`
+	LatencyP95Ms      float64      `json:"latency_p95_ms"`
+	TTFTAvgMs         float64      `json:"ttft_avg_ms"`
+	RecentErrors      []ErrorEvent `json:"recent_errors"`
+	// StreamTokens counts the tokens delivered in streamed responses since startup
+	StreamTokens uint64 `json:"stream_tokens"`
+}
+
+type sample struct {
+	at      time.Time
+	latency time.Duration
+	ttft    time.Duration
+}
+
+// Recorder tracks request rate, latency and time-to-first-token for proxied traffic
+type Recorder struct {
+	mu           sync.Mutex
+	now          func() time.Time
+	total        uint64
+	errors       uint64
+	inFlight     int64
+	samples      []sample
+	next         int
+	recentErrors []ErrorEvent
+	// cumulative per-route figures for /metrics
+	requests map[requestKey]uint64
+	routes   map[string]*routeStats
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{now: time.Now}
+}
+
+// Middleware wraps a handler and records every request that passes through it
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		rec.inFlight++
+		rec.mu.Unlock()
+
+		start := rec.now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK, now: rec.now}
+		next.ServeHTTP(sw, r)
+
+		ttft := time.Duration(0)
+		if !sw.firstWrite.IsZero() {
+			ttft = sw.firstWrite.Sub(start)
+		}
+		rec.Observe(r.Method, r.URL.Path, sw.status, rec.now().Sub(start), ttft)
+		if sw.events > 0 {
+			rec.ObserveStream(r.URL.Path, sw.events, rec.now().Sub(sw.firstWrite))
+		}
+
+		rec.mu.Lock()
+		rec.inFlight--
+		rec.mu.Unlock()
+	})
+}
+
+// Observe records a completed request
+func (rec *Recorder) Observe(method, path string, status int, latency, ttft time.Duration) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	now := rec.now()
+	route := Route(path)
+	rec.route(route).latency.Observe(latency.Seconds())
+	rec.requests[requestKey{route: route, method: method, status: status}]++
+	rec.total++
+	s := sample{at: now, latency: latency, ttft: ttft}
+	if len(rec.samples) < maxSamples {
+		rec.samples = append(rec.samples, s)
+	} else {
+		rec.samples[rec.next] = s
+		rec.next = (rec.next + 1) % maxSamples
+	}
+
+	if status >= http.StatusInternalServerError {
+		rec.errors++
+		rec.recentErrors = append(rec.recentErrors, ErrorEvent{Time: now, Method: method, Path: path, Status: status})
+		if len(rec.recentErrors) > maxRecentErrors {
+			rec.recentErrors = rec.recentErrors[len(rec.recentErrors)-maxRecentErrors:]
+		}
+	}
+}
+
+// Snapshot summarises the samples recorded within the last minute
+func (rec *Recorder) Snapshot() Snapshot {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	snap := Snapshot{
+		TotalRequests: rec.total,
+		ErrorCount:    rec.errors,
+		InFlight:      rec.inFlight,
+		RecentErrors:  append([]ErrorEvent(nil), rec.recentErrors...),
+	}
+	for _, route := range rec.routes {
+		snap.StreamTokens += route.streamTokens
+	}
+
+	cutoff := rec.now().Add(-rateWindow)
+	var latencies []float64
+	var ttftTotal float64
+	var ttftCount int
+	for _, s := range rec.samples {
+		if s.at.Before(cutoff) {
+			continue
+		}
+		latencies = append(latencies, float64(s.latency)/float64(time.Millisecond))
+		if s.ttft > 0 {
+			ttftTotal += float64(s.ttft) / float64(time.Millisecond)
+			ttftCount++
+		}
+	}
+
+	snap.RequestsPerSecond = float64(len(latencies)) / rateWindow.Seconds()
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		snap.LatencyP50Ms = percentile(latencies, 0.50)
+		snap.LatencyP95Ms = percentile(latencies, 0.95)
+	}
+	if ttftCount > 0 {
+		snap.TTFTAvgMs = ttftTotal / float64(ttftCount)
+	}
+
+	return snap
+}
+
+func percentile(sorted []float64, q float64) float64 {
+	idx := int(q * float64(len(sorted)-1))
+	return sorted[idx]
+}
+
+// statusWriter captures the response status and the time of the first body write
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	firstWrite  time.Time
+	now         func() time.Time
+	stream      *bool
+	events      int // SSE data events, roughly one token each, excluding [DONE]
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.firstWrite.IsZero() {
+		w.firstWrite = w.now()
+	}
+	w.wroteHeader = true
+	if w.stream == nil {
+		stream := strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+		w.stream = &stream
+	}
+	if *w.stream {
+		w.events += bytes.Count(b, []byte("data: ")) - bytes.Count(b, []byte("data: [DONE]"))
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
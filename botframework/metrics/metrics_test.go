@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareRecordsRequests(t *testing.T) {
+	rec := NewRecorder()
+	h := rec.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	for _, path := range []string{"/ok", "/ok", "/fail"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, nil))
+	}
+
+	snap := rec.Snapshot()
+	if snap.TotalRequests != 3 {
+		t.Fatalf("expected 3 requests, got %d", snap.TotalRequests)
+	}
+	if snap.ErrorCount != 1 || len(snap.RecentErrors) != 1 {
+		t.Fatalf("expected 1 error, got %d (%d recent)", snap.ErrorCount, len(snap.RecentErrors))
+	}
+	if snap.RecentErrors[0].Status != http.StatusBadGateway || snap.RecentErrors[0].Path != "/fail" {
+		t.Fatalf("unexpected error event: %+v", snap.RecentErrors[0])
+	}
+	if snap.InFlight != 0 {
+		t.Fatalf("expected no in-flight requests, got %d", snap.InFlight)
+	}
+}
+
+func TestSnapshotIgnoresSamplesOutsideWindow(t *testing.T) {
+	now := time.Now()
+	rec := NewRecorder()
+	rec.now = func() time.Time { return now.Add(-2 * time.Minute) }
+	rec.Observe(http.MethodPost, "/old", http.StatusOK, time.Second, 0)
+
+	rec.now = func() time.Time { return now }
+	rec.Observe(http.MethodPost, "/new", http.StatusOK, 100*time.Millisecond, 20*time.Millisecond)
+
+	snap := rec.Snapshot()
+	if snap.TotalRequests != 2 {
+		t.Fatalf("expected total of 2, got %d", snap.TotalRequests)
+	}
+	if snap.LatencyP95Ms != 100 {
+		t.Fatalf("expected p95 of 100ms from recent sample only, got %.1f", snap.LatencyP95Ms)
+	}
+	if snap.TTFTAvgMs != 20 {
+		t.Fatalf("expected ttft avg of 20ms, got %.1f", snap.TTFTAvgMs)
+	}
+}
+
+func TestPrometheusExposition(t *testing.T) {
+	rec := NewRecorder()
+	h := rec.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/chat/completions" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: {\"a\":1}\n\ndata: {\"a\":2}\n\n"))
+			time.Sleep(5 * time.Millisecond)
+			_, _ = w.Write([]byte("data: {\"a\":3}\n\ndata: [DONE]\n\n"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/files/file-123", nil))
+
+	extra := func(e *Exposition) {
+		e.Describe("botframework_test_gauge", "gauge", "A collector metric.")
+		e.Sample("botframework_test_gauge", Labels{"model": "phi"}, 2)
+	}
+	w := httptest.NewRecorder()
+	rec.PrometheusHandler(extra)(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	for _, want := range []string{
+		"# TYPE botframework_requests_total counter",
+		`botframework_requests_total{code="200",method="POST",route="/v1/chat/completions"} 1`,
+		`botframework_requests_total{code="404",method="GET",route="/v1/other"} 1`,
+		`botframework_request_duration_seconds_bucket{le="+Inf",route="/v1/chat/completions"} 1`,
+		`botframework_request_duration_seconds_count{route="/v1/other"} 1`,
+		`botframework_stream_tokens_total{route="/v1/chat/completions"} 3`,
+		`botframework_stream_tokens_per_second_count{route="/v1/chat/completions"} 1`,
+		`botframework_test_gauge{model="phi"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition is missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Histogram buckets for request latency (seconds) and streaming throughput (tokens/s)
+var (
+	LatencyBuckets    = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+	ThroughputBuckets = []float64{1, 5, 10, 20, 40, 80, 160, 320}
+)
+
+// routes get their own label; everything else is grouped so label cardinality stays bounded
+var routes = []string{
+	"/v1/chat/completions", "/v1/completions", "/v1/embeddings",
+	"/v1/audio/transcriptions", "/v1/audio/translations",
+}
+
+// Route returns the route label for a request path
+func Route(path string) string {
+	if slices.Contains(routes, path) {
+		return path
+	}
+	if strings.HasPrefix(path, "/v1/") {
+		return "/v1/other"
+	}
+	return "other"
+}
+
+// Histogram counts observations into buckets for exposition. It is not safe for concurrent
+// use; callers guard it with their own lock.
+type Histogram struct {
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative; the last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// Clone copies the histogram, so it can be written after the caller's lock is released
+func (h *Histogram) Clone() *Histogram {
+	clone := *h
+	clone.counts = append([]uint64(nil), h.counts...)
+	return &clone
+}
+
+type requestKey struct {
+	route, method string
+	status        int
+}
+
+type routeStats struct {
+	latency      *Histogram
+	streamTokens uint64
+	streamTime   float64
+	throughput   *Histogram
+}
+
+// Labels are a metric's label pairs, written in key order
+type Labels map[string]string
+
+// Exposition writes metrics in the Prometheus text format
+type Exposition struct {
+	w io.Writer
+}
+
+func NewExposition(w io.Writer) *Exposition {
+	return &Exposition{w: w}
+}
+
+// Describe writes a metric's HELP and TYPE lines; call it once before its samples
+func (e *Exposition) Describe(name, kind, help string) {
+	fmt.Fprintf(e.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// Sample writes one sample
+func (e *Exposition) Sample(name string, labels Labels, value float64) {
+	fmt.Fprintf(e.w, "%s%s %s\n", name, formatLabels(labels), formatValue(value))
+}
+
+// Histogram writes a histogram's buckets, sum and count
+func (e *Exposition) Histogram(name string, labels Labels, h *Histogram) {
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		e.Sample(name+"_bucket", withLabel(labels, "le", formatValue(bound)), float64(cumulative))
+	}
+	e.Sample(name+"_bucket", withLabel(labels, "le", "+Inf"), float64(h.count))
+	e.Sample(name+"_sum", labels, h.sum)
+	e.Sample(name+"_count", labels, float64(h.count))
+}
+
+func withLabel(labels Labels, key, value string) Labels {
+	out := Labels{key: value}
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + strconv.Quote(labels[k])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// ObserveStream records a streamed response that produced tokens over elapsed
+func (rec *Recorder) ObserveStream(path string, tokens int, elapsed time.Duration) {
+	if tokens <= 0 || elapsed <= 0 {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	stats := rec.route(Route(path))
+	stats.streamTokens += uint64(tokens)
+	stats.streamTime += elapsed.Seconds()
+	stats.throughput.Observe(float64(tokens) / elapsed.Seconds())
+}
+
+// route returns the stats for a route label; callers hold rec.mu
+func (rec *Recorder) route(label string) *routeStats {
+	if rec.routes == nil {
+		rec.routes = make(map[string]*routeStats)
+		rec.requests = make(map[requestKey]uint64)
+	}
+	stats, ok := rec.routes[label]
+	if !ok {
+		stats = &routeStats{latency: NewHistogram(LatencyBuckets), throughput: NewHistogram(ThroughputBuckets)}
+		rec.routes[label] = stats
+	}
+	return stats
+}
+
+// WritePrometheus writes the request counters, latency histograms and streaming throughput
+func (rec *Recorder) WritePrometheus(e *Exposition) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	keys := make([]requestKey, 0, len(rec.requests))
+	for k := range rec.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	labels := make([]string, 0, len(rec.routes))
+	for label := range rec.routes {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	e.Describe("botframework_requests_total", "counter", "Requests served, by route, method and status code.")
+	for _, k := range keys {
+		e.Sample("botframework_requests_total", Labels{"route": k.route, "method": k.method, "code": strconv.Itoa(k.status)}, float64(rec.requests[k]))
+	}
+	e.Describe("botframework_requests_in_flight", "gauge", "Requests currently being served.")
+	e.Sample("botframework_requests_in_flight", nil, float64(rec.inFlight))
+
+	e.Describe("botframework_request_duration_seconds", "histogram", "Request latency by route.")
+	for _, label := range labels {
+		e.Histogram("botframework_request_duration_seconds", Labels{"route": label}, rec.routes[label].latency)
+	}
+
+	e.Describe("botframework_stream_tokens_total", "counter", "Tokens delivered in streamed responses.")
+	for _, label := range labels {
+		e.Sample("botframework_stream_tokens_total", Labels{"route": label}, float64(rec.routes[label].streamTokens))
+	}
+	e.Describe("botframework_stream_seconds_total", "counter", "Time spent streaming tokens, from the first chunk to the last.")
+	for _, label := range labels {
+		e.Sample("botframework_stream_seconds_total", Labels{"route": label}, rec.routes[label].streamTime)
+	}
+	e.Describe("botframework_stream_tokens_per_second", "histogram", "Per-response streaming throughput.")
+	for _, label := range labels {
+		e.Histogram("botframework_stream_tokens_per_second", Labels{"route": label}, rec.routes[label].throughput)
+	}
+}
+
+// PrometheusHandler serves the recorder's metrics followed by those of the collectors
+func (rec *Recorder) PrometheusHandler(collectors ...func(*Exposition)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		e := NewExposition(w)
+		rec.WritePrometheus(e)
+		for _, collect := range collectors {
+			collect(e)
+		}
+	}
+}
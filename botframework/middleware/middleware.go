@@ -0,0 +1,193 @@
+// Package middleware holds the built-in gateway middlewares (CORS, request size limits and
+// header sanitization) and a registry of named ones, so custom logic can be plugged into
+// the manager's request path by name.
+package middleware
+
+import (
+	"botframework/engine"
+	"botframework/openai"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Factory builds a configured middleware. It returns nil when the middleware has nothing
+// to do, for example CORS without allowed origins.
+type Factory func() (engine.Middleware, error)
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a middleware available under name. Packages register from init, so a
+// blank import is enough to offer one to BOTFRAMEWORK_MIDDLEWARE. Registering a name twice
+// replaces the earlier factory.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = factory
+}
+
+// Names lists the registered middlewares
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build creates the named middlewares in order, skipping those with nothing to do
+func Build(names []string) ([]engine.Middleware, error) {
+	var chain []engine.Middleware
+	for _, name := range names {
+		mu.RLock()
+		factory, ok := factories[name]
+		mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware %q (registered: %s)", name, strings.Join(Names(), ", "))
+		}
+		m, err := factory()
+		if err != nil {
+			return nil, fmt.Errorf("middleware %s: %w", name, err)
+		}
+		if m != nil {
+			chain = append(chain, m)
+		}
+	}
+	return chain, nil
+}
+
+// CORS lets browser pages on Origins call the API. Preflight requests are answered
+// directly; other requests get the Access-Control headers on their response.
+type CORS struct {
+	// Origins lists the allowed origins; "*" allows any
+	Origins []string
+	// Headers lists the request headers pages may send (default: Authorization,
+	// Content-Type, X-Request-ID)
+	Headers []string
+	MaxAge  time.Duration // how long browsers may cache a preflight answer (default: 10m)
+}
+
+func (c *CORS) allowed(origin string) bool {
+	return origin != "" && (slices.Contains(c.Origins, "*") || slices.Contains(c.Origins, origin))
+}
+
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	headers := c.Headers
+	if len(headers) == 0 {
+		headers = []string{"Authorization", "Content-Type", "X-Request-ID"}
+	}
+	maxAge := c.MaxAge
+	if maxAge <= 0 {
+		maxAge = 10 * time.Minute
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if !c.allowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Retry-After")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// BodyLimit rejects request bodies larger than MaxBytes with 413
+type BodyLimit struct {
+	MaxBytes int64
+}
+
+func (b *BodyLimit) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > b.MaxBytes {
+			openai.WriteError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "request_too_large",
+				fmt.Sprintf("request body is larger than %d bytes", b.MaxBytes))
+			return
+		}
+		// bodies without a declared length fail to read past the limit
+		r.Body = http.MaxBytesReader(w, r.Body, b.MaxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SanitizeHeaders drops request headers clients must not pass to the backends and
+// response headers that reveal the backend software
+type SanitizeHeaders struct {
+	Request  []string
+	Response []string
+}
+
+// Headers SanitizeHeaders drops when none are configured
+var (
+	DefaultDroppedRequestHeaders  = []string{"Proxy-Authorization", "Proxy-Connection", "Keep-Alive", "Upgrade"}
+	DefaultDroppedResponseHeaders = []string{"Server", "X-Powered-By", "Via"}
+)
+
+func (s *SanitizeHeaders) Middleware(next http.Handler) http.Handler {
+	request, response := s.Request, s.Response
+	if request == nil {
+		request = DefaultDroppedRequestHeaders
+	}
+	if response == nil {
+		response = DefaultDroppedResponseHeaders
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, name := range request {
+			r.Header.Del(name)
+		}
+		next.ServeHTTP(&headerFilter{ResponseWriter: w, drop: response}, r)
+	})
+}
+
+// headerFilter removes headers just before the response is sent
+type headerFilter struct {
+	http.ResponseWriter
+	drop        []string
+	wroteHeader bool
+}
+
+func (h *headerFilter) WriteHeader(code int) {
+	if !h.wroteHeader {
+		h.wroteHeader = true
+		for _, name := range h.drop {
+			h.Header().Del(name)
+		}
+	}
+	h.ResponseWriter.WriteHeader(code)
+}
+
+func (h *headerFilter) Write(p []byte) (int, error) {
+	if !h.wroteHeader {
+		h.WriteHeader(http.StatusOK)
+	}
+	return h.ResponseWriter.Write(p)
+}
+
+func (h *headerFilter) Flush() {
+	if f, ok := h.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (h *headerFilter) Unwrap() http.ResponseWriter {
+	return h.ResponseWriter
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"botframework/engine"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// backend echoes the request body and answers like uvicorn
+var backend = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "uvicorn")
+	w.Header().Set("X-Saw-Proxy-Auth", r.Header.Get("Proxy-Authorization"))
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Write(body)
+})
+
+func TestCORS(t *testing.T) {
+	h := (&CORS{Origins: []string{"https://chat.example.com"}}).Middleware(backend)
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/v1/chat/completions", nil)
+	preflight.Header.Set("Origin", "https://chat.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, preflight)
+	if rr.Code != http.StatusNoContent || rr.Header().Get("Access-Control-Allow-Origin") != "https://chat.example.com" ||
+		!strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Errorf("preflight: %d %v", rr.Code, rr.Header())
+	}
+
+	foreign := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}"))
+	foreign.Header.Set("Origin", "https://evil.example")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, foreign)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "" || rr.Body.String() != "{}" {
+		t.Errorf("foreign origin: %v %q", rr.Header(), rr.Body)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	h := (&BodyLimit{MaxBytes: 4}).Middleware(backend)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader("too long")))
+	if rr.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rr.Body.String(), "request_too_large") {
+		t.Errorf("declared length: %d %s", rr.Code, rr.Body)
+	}
+
+	// a streamed body has no length to check up front
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", io.NopCloser(strings.NewReader("too long")))
+	req.ContentLength = -1
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("streamed body: %d", rr.Code)
+	}
+}
+
+func TestSanitizeHeaders(t *testing.T) {
+	h := engine.Chain(backend, &SanitizeHeaders{})
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader("{}"))
+	req.Header.Set("Proxy-Authorization", "Basic c2VjcmV0")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Header().Get("Server") != "" || rr.Header().Get("X-Saw-Proxy-Auth") != "" {
+		t.Errorf("headers = %v", rr.Header())
+	}
+}
+
+func TestBuild(t *testing.T) {
+	Register("test_noop", func() (engine.Middleware, error) { return nil, nil })
+	Register("test_cors", func() (engine.Middleware, error) { return &CORS{Origins: []string{"*"}}, nil })
+	chain, err := Build([]string{"test_noop", "test_cors"})
+	if err != nil || len(chain) != 1 {
+		t.Fatalf("chain = %v, err %v", chain, err)
+	}
+	if _, err := Build([]string{"missing"}); err == nil || !strings.Contains(err.Error(), "test_cors") {
+		t.Errorf("err = %v", err)
+	}
+}
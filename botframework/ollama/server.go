@@ -0,0 +1,276 @@
+// Package ollama serves the Ollama REST API (/api/chat, /api/generate, /api/tags, ...) on top
+// of the manager's OpenAI-compatible routes, so Ollama clients work whichever engine serves
+// the model.
+package ollama
+
+import (
+	"botframework/profiler"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is reported by /api/version; clients gate features on Ollama's version
+const Version = "0.6.0"
+
+// Server translates Ollama requests into calls of Backend, the OpenAI-compatible inference
+// handler, and converts the responses back
+type Server struct {
+	Backend http.Handler
+	// Models lists the served model names
+	Models func() ([]string, error)
+	// Registry describes models in /api/tags and /api/show, when set
+	Registry *profiler.ModelRegistry
+	// Pull downloads a registry model, reporting bytes received; nil disables /api/pull
+	Pull func(ctx context.Context, name string, progress func(completed, total int64)) error
+
+	mu sync.RWMutex // guards Registry once serving
+}
+
+func NewServer(backend http.Handler, models func() ([]string, error)) *Server {
+	return &Server{Backend: backend, Models: models}
+}
+
+// SetRegistry replaces Registry while the server is serving
+func (s *Server) SetRegistry(registry *profiler.ModelRegistry) {
+	s.mu.Lock()
+	s.Registry = registry
+	s.mu.Unlock()
+}
+
+func (s *Server) registry() *profiler.ModelRegistry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Registry
+}
+
+// ServeHTTP routes the /api/ endpoints
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route := map[string]func(http.ResponseWriter, *http.Request){
+		"/api/chat":       s.handleChat,
+		"/api/generate":   s.handleGenerate,
+		"/api/embed":      s.handleEmbed,
+		"/api/embeddings": s.handleEmbeddings,
+		"/api/show":       s.handleShow,
+		"/api/pull":       s.handlePull,
+	}
+	switch handler, ok := route[r.URL.Path]; {
+	case r.URL.Path == "/api/tags" || r.URL.Path == "/api/version":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		if r.URL.Path == "/api/version" {
+			writeJSON(w, http.StatusOK, map[string]string{"version": Version})
+			return
+		}
+		s.handleTags(w, r)
+	case ok:
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		handler(w, r)
+	default:
+		writeError(w, http.StatusNotFound, r.URL.Path+" is not supported")
+	}
+}
+
+// ModelDetails is the "details" object of /api/tags and /api/show
+type ModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+type tagModel struct {
+	Name       string       `json:"name"`
+	Model      string       `json:"model"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	Details    ModelDetails `json:"details"`
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	names, err := s.Models()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	models := make([]tagModel, 0, len(names))
+	for _, name := range names {
+		details, size := s.describe(name)
+		digest := sha256.Sum256([]byte(name))
+		models = append(models, tagModel{
+			Name:       name,
+			Model:      name,
+			ModifiedAt: time.Now().UTC(),
+			Size:       size,
+			Digest:     hex.EncodeToString(digest[:]),
+			Details:    details,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"models": models})
+}
+
+// describe fills the details of a served model from its registry entry; names such as
+// "llama-3-8b-instruct-q4_k_m" carry the variant's quant after the model ID
+func (s *Server) describe(name string) (ModelDetails, int64) {
+	details := ModelDetails{Format: "gguf", Families: []string{}}
+	registry := s.registry()
+	if registry == nil {
+		return details, 0
+	}
+	model := registry.Lookup(name)
+	if model == nil {
+		return details, 0
+	}
+	details.Family, details.Families = model.Family, []string{model.Family}
+	details.ParameterSize = fmt.Sprintf("%.1fB", model.ParamsB)
+	for _, variant := range model.Variants {
+		if strings.HasSuffix(strings.ToLower(name), strings.ToLower(variant.Quant)) {
+			details.QuantizationLevel = variant.Quant
+			return details, int64(variant.SizeGB * 1e9)
+		}
+	}
+	return details, 0
+}
+
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string `json:"model"`
+		Name  string `json:"name"` // older clients
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	name := modelName(req.Model, req.Name)
+	names, err := s.Models()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	found := false
+	for _, served := range names {
+		found = found || served == name
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("model '%s' not found", name))
+		return
+	}
+
+	details, _ := s.describe(name)
+	info := map[string]any{"general.architecture": details.Family}
+	if registry := s.registry(); registry != nil {
+		if model := registry.Lookup(name); model != nil {
+			info["general.parameter_count"] = int64(model.ParamsB * 1e9)
+			if model.ContextWindow > 0 {
+				info[model.Family+".context_length"] = model.ContextWindow
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"modelfile":    "",
+		"parameters":   "",
+		"template":     "",
+		"details":      details,
+		"model_info":   info,
+		"capabilities": []string{"completion", "tools"},
+		"modified_at":  time.Now().UTC(),
+	})
+}
+
+// handlePull downloads a model, streaming Ollama's progress lines unless stream is false
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model  string `json:"model"`
+		Name   string `json:"name"`
+		Stream *bool  `json:"stream"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if s.Pull == nil {
+		writeError(w, http.StatusNotImplemented, "pulling models is not enabled")
+		return
+	}
+	name := modelName(req.Model, req.Name)
+	stream := req.Stream == nil || *req.Stream
+	if !stream {
+		if err := s.Pull(r.Context(), name, func(int64, int64) {}); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+		return
+	}
+
+	lines := newLineWriter(w)
+	lines.send(map[string]string{"status": "pulling manifest"})
+	last := time.Time{}
+	err := s.Pull(r.Context(), name, func(completed, total int64) {
+		if time.Since(last) < 200*time.Millisecond && completed < total {
+			return
+		}
+		last = time.Now()
+		lines.send(map[string]any{"status": "pulling " + name, "digest": name, "total": total, "completed": completed})
+	})
+	if err != nil {
+		lines.send(map[string]string{"error": err.Error()})
+		return
+	}
+	lines.send(map[string]string{"status": "success"})
+}
+
+// modelName prefers model over the deprecated name field and drops the ":latest" tag
+// Ollama clients add to untagged names
+func modelName(model, name string) string {
+	if model == "" {
+		model = name
+	}
+	return strings.TrimSuffix(model, ":latest")
+}
+
+// lineWriter streams newline-delimited JSON, flushing every line
+type lineWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func newLineWriter(w http.ResponseWriter) *lineWriter {
+	return &lineWriter{w: w}
+}
+
+func (l *lineWriter) send(v any) {
+	if !l.started {
+		l.started = true
+		l.w.Header().Set("Content-Type", "application/x-ndjson")
+		l.w.WriteHeader(http.StatusOK)
+	}
+	_ = json.NewEncoder(l.w).Encode(v)
+	if f, ok := l.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError uses Ollama's error shape, {"error": "..."}
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
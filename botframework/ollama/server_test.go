@@ -0,0 +1,188 @@
+package ollama
+
+import (
+	"botframework/profiler"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeBackend answers chat completions with "hello there" (streamed as two chunks when
+// asked), embeddings with a fixed vector and model "missing" with a 404
+func fakeBackend(t *testing.T, seen *map[string]any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if seen != nil {
+			*seen = body
+		}
+		if body["model"] == "missing" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"model missing is not loaded","type":"invalid_request_error"}}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/embeddings":
+			fmt.Fprint(w, `{"data":[{"index":0,"embedding":[0.5,1.5]}],"usage":{"prompt_tokens":2}}`)
+		case "/v1/chat/completions":
+			if body["stream"] != true {
+				fmt.Fprint(w, `{"choices":[{"message":{"content":"hello there","tool_calls":[{"function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":5,"completion_tokens":2}}`)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"},\"finish_reason\":null}]}\n\n")
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\" there\"},\"finish_reason\":\"length\"}]}\n\n")
+			fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2}}\n\ndata: [DONE]\n\n")
+		default:
+			t.Errorf("unexpected backend call %s", r.URL.Path)
+		}
+	})
+}
+
+func newTestServer(t *testing.T, seen *map[string]any) *Server {
+	server := NewServer(fakeBackend(t, seen), func() ([]string, error) {
+		return []string{"llama-3-8b-instruct-q4_k_m"}, nil
+	})
+	server.Registry = &profiler.ModelRegistry{Models: []profiler.Model{{
+		ID: "llama-3-8b-instruct", Family: "llama", ParamsB: 8, ContextWindow: 8192,
+		Variants: []profiler.Variant{{Quant: "Q4_K_M", SizeGB: 4.9}},
+	}}}
+	return server
+}
+
+func post(server *Server, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestChatTranslatesRequestAndToolCalls(t *testing.T) {
+	var seen map[string]any
+	server := newTestServer(t, &seen)
+	rec := post(server, "/api/chat", `{"model":"llama-3-8b-instruct-q4_k_m:latest","stream":false,"format":"json",
+		"messages":[{"role":"user","content":"what is this?","images":["aGk="]}],"options":{"num_predict":64,"temperature":0.2}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	if seen["model"] != "llama-3-8b-instruct-q4_k_m" || seen["max_tokens"] != 64.0 || seen["temperature"] != 0.2 {
+		t.Errorf("backend request = %v", seen)
+	}
+	if format, _ := json.Marshal(seen["response_format"]); string(format) != `{"type":"json_object"}` {
+		t.Errorf("response_format = %s", format)
+	}
+	content := seen["messages"].([]any)[0].(map[string]any)["content"].([]any)
+	if len(content) != 2 || content[1].(map[string]any)["image_url"].(map[string]any)["url"] != "data:image/png;base64,aGk=" {
+		t.Errorf("content = %v", content)
+	}
+
+	var resp struct {
+		Message struct {
+			Content   string
+			ToolCalls []ToolCall `json:"tool_calls"`
+		}
+		Done       bool
+		DoneReason string `json:"done_reason"`
+		EvalCount  int    `json:"eval_count"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if !resp.Done || resp.Message.Content != "hello there" || resp.DoneReason != "tool_calls" || resp.EvalCount != 2 {
+		t.Errorf("response = %s", rec.Body)
+	}
+	if len(resp.Message.ToolCalls) != 1 || string(resp.Message.ToolCalls[0].Function.Arguments) != `{"city":"Paris"}` {
+		t.Errorf("tool calls = %+v", resp.Message.ToolCalls)
+	}
+}
+
+func TestGenerateStreamsNewlineDelimitedJSON(t *testing.T) {
+	var seen map[string]any
+	server := newTestServer(t, &seen)
+	rec := post(server, "/api/generate", `{"model":"llama-3-8b-instruct-q4_k_m","prompt":"hi","system":"be brief"}`)
+	if rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %s, body %s", rec.Header().Get("Content-Type"), rec.Body)
+	}
+	if messages := seen["messages"].([]any); len(messages) != 2 || messages[0].(map[string]any)["role"] != "system" {
+		t.Errorf("messages = %v", messages)
+	}
+
+	var text strings.Builder
+	var last map[string]any
+	lines := 0
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		lines++
+		last = nil
+		if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		text.WriteString(last["response"].(string))
+	}
+	if lines != 3 || text.String() != "hello there" {
+		t.Errorf("%d lines, text %q", lines, text.String())
+	}
+	if last["done"] != true || last["done_reason"] != "length" || last["eval_count"] != 2.0 || last["prompt_eval_count"] != 5.0 {
+		t.Errorf("final line = %v", last)
+	}
+}
+
+func TestBackendErrorsUseOllamaShape(t *testing.T) {
+	rec := post(newTestServer(t, nil), "/api/chat", `{"model":"missing","messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusNotFound || strings.TrimSpace(rec.Body.String()) != `{"error":"model missing is not loaded"}` {
+		t.Errorf("status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestTagsDescribeRegistryModels(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestServer(t, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tags", nil))
+	var resp struct{ Models []tagModel }
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Models) != 1 {
+		t.Fatalf("body %s", rec.Body)
+	}
+	model := resp.Models[0]
+	if model.Name != "llama-3-8b-instruct-q4_k_m" || model.Size != 4.9e9 || model.Details.Family != "llama" ||
+		model.Details.QuantizationLevel != "Q4_K_M" || model.Details.ParameterSize != "8.0B" {
+		t.Errorf("model = %+v", model)
+	}
+}
+
+func TestEmbedAndLegacyEmbeddings(t *testing.T) {
+	server := newTestServer(t, nil)
+	rec := post(server, "/api/embed", `{"model":"llama-3-8b-instruct-q4_k_m","input":["hi"]}`)
+	if !strings.Contains(rec.Body.String(), `"embeddings":[[0.5,1.5]]`) {
+		t.Errorf("embed: %s", rec.Body)
+	}
+	rec = post(server, "/api/embeddings", `{"model":"llama-3-8b-instruct-q4_k_m","prompt":"hi"}`)
+	if strings.TrimSpace(rec.Body.String()) != `{"embedding":[0.5,1.5]}` {
+		t.Errorf("embeddings: %s", rec.Body)
+	}
+}
+
+func TestPullStreamsProgress(t *testing.T) {
+	server := newTestServer(t, nil)
+	server.Pull = func(ctx context.Context, name string, progress func(completed, total int64)) error {
+		if name != "phi-3-mini-4k" {
+			return fmt.Errorf("model %q is not in the registry", name)
+		}
+		progress(100, 100)
+		return nil
+	}
+	rec := post(server, "/api/pull", `{"model":"phi-3-mini-4k:latest"}`)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[1], `"completed":100`) || lines[2] != `{"status":"success"}` {
+		t.Errorf("lines = %q", lines)
+	}
+
+	rec = post(server, "/api/pull", `{"model":"unknown","stream":false}`)
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "not in the registry") {
+		t.Errorf("status %d, body %s", rec.Code, rec.Body)
+	}
+}
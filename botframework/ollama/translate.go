@@ -0,0 +1,510 @@
+package ollama
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Options are the Ollama generation options the OpenAI routes understand
+type Options struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	TopK             *int     `json:"top_k,omitempty"`
+	NumPredict       *int     `json:"num_predict,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	RepeatPenalty    *float64 `json:"repeat_penalty,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Images are base64-encoded, without a data: prefix
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall carries arguments as an object, where OpenAI uses a JSON string
+type ToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	// Tools use the OpenAI function schema and are passed through
+	Tools   json.RawMessage `json:"tools,omitempty"`
+	Format  json.RawMessage `json:"format,omitempty"`
+	Options Options         `json:"options"`
+	Stream  *bool           `json:"stream,omitempty"`
+}
+
+type GenerateRequest struct {
+	Model   string          `json:"model"`
+	Prompt  string          `json:"prompt"`
+	System  string          `json:"system,omitempty"`
+	Images  []string        `json:"images,omitempty"`
+	Format  json.RawMessage `json:"format,omitempty"`
+	Options Options         `json:"options"`
+	Stream  *bool           `json:"stream,omitempty"`
+	// Raw sends the prompt to /v1/completions without a chat template
+	Raw bool `json:"raw,omitempty"`
+}
+
+// openAIBody builds the chat or text completion request for the backend
+func openAIBody(model string, messages []Message, prompt string, tools, format json.RawMessage, options Options, stream bool) map[string]any {
+	body := map[string]any{"model": model, "stream": stream}
+	if messages != nil {
+		converted := make([]map[string]any, 0, len(messages))
+		for _, m := range messages {
+			converted = append(converted, toOpenAIMessage(m))
+		}
+		body["messages"] = converted
+	} else {
+		body["prompt"] = prompt
+	}
+	if stream {
+		body["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	if len(tools) > 0 && string(tools) != "null" {
+		body["tools"] = tools
+	}
+	switch format := strings.TrimSpace(string(format)); {
+	case format == `"json"`:
+		body["response_format"] = map[string]string{"type": "json_object"}
+	case strings.HasPrefix(format, "{"):
+		body["response_format"] = map[string]any{"type": "json_schema", "json_schema": map[string]any{"name": "response", "schema": json.RawMessage(format)}}
+	}
+
+	set := func(name string, value any, ok bool) {
+		if ok {
+			body[name] = value
+		}
+	}
+	set("temperature", options.Temperature, options.Temperature != nil)
+	set("top_p", options.TopP, options.TopP != nil)
+	set("top_k", options.TopK, options.TopK != nil)
+	set("max_tokens", options.NumPredict, options.NumPredict != nil && *options.NumPredict >= 0)
+	set("seed", options.Seed, options.Seed != nil)
+	set("stop", options.Stop, len(options.Stop) > 0)
+	set("repeat_penalty", options.RepeatPenalty, options.RepeatPenalty != nil)
+	set("presence_penalty", options.PresencePenalty, options.PresencePenalty != nil)
+	set("frequency_penalty", options.FrequencyPenalty, options.FrequencyPenalty != nil)
+	return body
+}
+
+func toOpenAIMessage(m Message) map[string]any {
+	message := map[string]any{"role": m.Role, "content": m.Content}
+	if len(m.Images) > 0 {
+		parts := []map[string]any{{"type": "text", "text": m.Content}}
+		for _, image := range m.Images {
+			parts = append(parts, map[string]any{"type": "image_url", "image_url": map[string]string{"url": "data:image/png;base64," + image}})
+		}
+		message["content"] = parts
+	}
+	if len(m.ToolCalls) > 0 {
+		calls := make([]map[string]any, 0, len(m.ToolCalls))
+		for i, call := range m.ToolCalls {
+			calls = append(calls, map[string]any{
+				"id":       fmt.Sprintf("call_%d", i),
+				"type":     "function",
+				"function": map[string]string{"name": call.Function.Name, "arguments": string(call.Function.Arguments)},
+			})
+		}
+		message["tool_calls"] = calls
+	}
+	return message
+}
+
+// openAICompletion is the part of chat and text completions, and of their chunks, that is
+// translated back
+type openAICompletion struct {
+	Choices []struct {
+		Text    string        `json:"text"`
+		Message openAIMessage `json:"message"`
+		Delta   openAIMessage `json:"delta"`
+		Finish  *string       `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+type openAIMessage struct {
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		Index    int `json:"index"`
+		Function struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls"`
+}
+
+// reply accumulates a completion into Ollama's final message and statistics
+type reply struct {
+	model        string
+	chat         bool
+	start        time.Time
+	firstToken   time.Time
+	content      strings.Builder
+	toolNames    []string
+	toolArgs     []string
+	doneReason   string
+	promptTokens int
+	evalTokens   int
+	chunks       int
+}
+
+func newReply(model string, chat bool) *reply {
+	return &reply{model: model, chat: chat, start: time.Now(), doneReason: "stop"}
+}
+
+// add folds in a completion or chunk and returns its new text
+func (rp *reply) add(c openAICompletion) string {
+	if c.Usage != nil {
+		rp.promptTokens, rp.evalTokens = c.Usage.PromptTokens, c.Usage.CompletionTokens
+	}
+	var text strings.Builder
+	for _, choice := range c.Choices {
+		message, delta := choice.Message, false
+		if choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0 {
+			message, delta = choice.Delta, true
+		}
+		text.WriteString(choice.Text + message.Content)
+		for _, call := range message.ToolCalls {
+			// streamed calls arrive in fragments keyed by index; complete ones are listed in order
+			index := len(rp.toolNames)
+			if delta {
+				index = call.Index
+			}
+			for len(rp.toolNames) <= index {
+				rp.toolNames = append(rp.toolNames, "")
+				rp.toolArgs = append(rp.toolArgs, "")
+			}
+			rp.toolNames[index] += call.Function.Name
+			rp.toolArgs[index] += call.Function.Arguments
+		}
+		if choice.Finish != nil && *choice.Finish != "" {
+			rp.doneReason = *choice.Finish
+		}
+	}
+	if text.Len() > 0 {
+		if rp.firstToken.IsZero() {
+			rp.firstToken = time.Now()
+		}
+		rp.chunks++
+		rp.content.WriteString(text.String())
+	}
+	return text.String()
+}
+
+// toolCalls converts the accumulated calls, decoding each argument string into an object
+func (rp *reply) toolCalls() []ToolCall {
+	var calls []ToolCall
+	for i, name := range rp.toolNames {
+		var call ToolCall
+		call.Function.Name = name
+		args := json.RawMessage(rp.toolArgs[i])
+		if !json.Valid(args) {
+			args = json.RawMessage("{}")
+		}
+		call.Function.Arguments = args
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// message is one response line; the final line (done) carries the statistics
+func (rp *reply) message(text string, done bool) map[string]any {
+	line := map[string]any{"model": rp.model, "created_at": time.Now().UTC(), "done": done}
+	if rp.chat {
+		message := map[string]any{"role": "assistant", "content": text}
+		if done && len(rp.toolNames) > 0 {
+			message["tool_calls"] = rp.toolCalls()
+		}
+		line["message"] = message
+	} else {
+		line["response"] = text
+	}
+	if !done {
+		return line
+	}
+	end := time.Now()
+	firstToken := rp.firstToken
+	if firstToken.IsZero() {
+		firstToken = end
+	}
+	evalTokens := rp.evalTokens
+	if evalTokens == 0 {
+		// backends that drop stream_options send no usage; count chunks instead
+		evalTokens = rp.chunks
+	}
+	line["done_reason"] = rp.doneReason
+	line["total_duration"] = end.Sub(rp.start).Nanoseconds()
+	line["load_duration"] = 0
+	line["prompt_eval_count"] = rp.promptTokens
+	line["prompt_eval_duration"] = firstToken.Sub(rp.start).Nanoseconds()
+	line["eval_count"] = evalTokens
+	line["eval_duration"] = end.Sub(firstToken).Nanoseconds()
+	return line
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	model := modelName(req.Model, "")
+	stream := req.Stream == nil || *req.Stream
+	if len(req.Messages) == 0 {
+		// Ollama loads the model for an empty conversation
+		writeJSON(w, http.StatusOK, map[string]any{"model": model, "created_at": time.Now().UTC(),
+			"message": map[string]string{"role": "assistant", "content": ""}, "done": true, "done_reason": "load"})
+		return
+	}
+	body := openAIBody(model, req.Messages, "", req.Tools, req.Format, req.Options, stream)
+	s.complete(w, r, "/v1/chat/completions", body, newReply(model, true), stream)
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var req GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	model := modelName(req.Model, "")
+	stream := req.Stream == nil || *req.Stream
+	if req.Prompt == "" && len(req.Images) == 0 {
+		writeJSON(w, http.StatusOK, map[string]any{"model": model, "created_at": time.Now().UTC(),
+			"response": "", "done": true, "done_reason": "load"})
+		return
+	}
+	if req.Raw {
+		body := openAIBody(model, nil, req.Prompt, nil, req.Format, req.Options, stream)
+		s.complete(w, r, "/v1/completions", body, newReply(model, false), stream)
+		return
+	}
+	var messages []Message
+	if req.System != "" {
+		messages = append(messages, Message{Role: "system", Content: req.System})
+	}
+	messages = append(messages, Message{Role: "user", Content: req.Prompt, Images: req.Images})
+	body := openAIBody(model, messages, "", nil, req.Format, req.Options, stream)
+	s.complete(w, r, "/v1/chat/completions", body, newReply(model, false), stream)
+}
+
+// complete calls the backend at path and answers with one JSON object, or with a line per
+// chunk followed by a final line holding the statistics
+func (s *Server) complete(w http.ResponseWriter, r *http.Request, path string, body map[string]any, rp *reply, stream bool) {
+	var lines *lineWriter
+	bw := &backendWriter{header: http.Header{}}
+	if stream {
+		lines = newLineWriter(w)
+		bw.onEvent = func(data []byte) {
+			var chunk openAICompletion
+			if json.Unmarshal(data, &chunk) != nil {
+				return
+			}
+			if text := rp.add(chunk); text != "" {
+				lines.send(rp.message(text, false))
+			}
+		}
+	}
+	if !s.call(w, r, path, body, bw) {
+		return
+	}
+	if stream {
+		if bw.streamed {
+			lines.send(rp.message("", true))
+			return
+		}
+		// the backend answered a stream request with a single completion
+		var completion openAICompletion
+		json.Unmarshal(bw.body.Bytes(), &completion)
+		text := rp.add(completion)
+		lines.send(rp.message(text, false))
+		lines.send(rp.message("", true))
+		return
+	}
+	var completion openAICompletion
+	if err := json.Unmarshal(bw.body.Bytes(), &completion); err != nil {
+		writeError(w, http.StatusBadGateway, "invalid backend response: "+err.Error())
+		return
+	}
+	rp.add(completion)
+	writeJSON(w, http.StatusOK, rp.message(rp.content.String(), true))
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	model := modelName(req.Model, "")
+	start := time.Now()
+	embeddings, tokens, ok := s.embed(w, r, model, req.Input)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"model":             model,
+		"embeddings":        embeddings,
+		"total_duration":    time.Since(start).Nanoseconds(),
+		"prompt_eval_count": tokens,
+	})
+}
+
+// handleEmbeddings serves the legacy single-prompt route
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	input, _ := json.Marshal(req.Prompt)
+	embeddings, _, ok := s.embed(w, r, modelName(req.Model, ""), input)
+	if !ok {
+		return
+	}
+	embedding := []float64{}
+	if len(embeddings) > 0 {
+		embedding = embeddings[0]
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"embedding": embedding})
+}
+
+func (s *Server) embed(w http.ResponseWriter, r *http.Request, model string, input json.RawMessage) ([][]float64, int, bool) {
+	bw := &backendWriter{header: http.Header{}}
+	if !s.call(w, r, "/v1/embeddings", map[string]any{"model": model, "input": input}, bw) {
+		return nil, 0, false
+	}
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(bw.body.Bytes(), &resp); err != nil {
+		writeError(w, http.StatusBadGateway, "invalid backend response: "+err.Error())
+		return nil, 0, false
+	}
+	embeddings := make([][]float64, len(resp.Data))
+	for i, item := range resp.Data {
+		if item.Index >= 0 && item.Index < len(embeddings) {
+			i = item.Index
+		}
+		embeddings[i] = item.Embedding
+	}
+	return embeddings, resp.Usage.PromptTokens, true
+}
+
+// call sends body to the backend at path. Failures are answered in Ollama's error shape,
+// with the backend's status and message, and reported as false.
+func (s *Server) call(w http.ResponseWriter, r *http.Request, path string, body map[string]any, bw *backendWriter) bool {
+	data, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	forward, err := http.NewRequestWithContext(r.Context(), http.MethodPost, path, bytes.NewReader(data))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	for _, name := range []string{"Authorization", "X-Api-Key", "X-Request-Id"} {
+		if value := r.Header.Get(name); value != "" {
+			forward.Header.Set(name, value)
+		}
+	}
+	forward.Header.Set("Content-Type", "application/json")
+	forward.RemoteAddr = r.RemoteAddr
+	s.Backend.ServeHTTP(bw, forward)
+
+	if bw.status >= 300 {
+		var openAIError struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		message := strings.TrimSpace(bw.body.String())
+		if json.Unmarshal(bw.body.Bytes(), &openAIError) == nil && openAIError.Error.Message != "" {
+			message = openAIError.Error.Message
+		}
+		if retry := bw.header.Get("Retry-After"); retry != "" {
+			w.Header().Set("Retry-After", retry)
+		}
+		writeError(w, bw.status, message)
+		return false
+	}
+	return true
+}
+
+// backendWriter captures a backend response. Successful event streams are parsed as they
+// arrive and each data payload passed to onEvent; everything else is buffered.
+type backendWriter struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	onEvent  func(data []byte)
+	streamed bool
+	pending  []byte
+}
+
+func (b *backendWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *backendWriter) WriteHeader(code int) {
+	if b.status != 0 {
+		return
+	}
+	b.status = code
+	b.streamed = b.onEvent != nil && code < 300 && strings.HasPrefix(b.header.Get("Content-Type"), "text/event-stream")
+}
+
+func (b *backendWriter) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	if !b.streamed {
+		return b.body.Write(p)
+	}
+	b.pending = append(b.pending, p...)
+	for {
+		end := bytes.IndexByte(b.pending, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		line := bytes.TrimSpace(b.pending[:end])
+		b.pending = b.pending[end+1:]
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = bytes.TrimSpace(data)
+			if string(data) != "[DONE]" {
+				b.onEvent(data)
+			}
+		}
+	}
+}
+
+// Flush is a no-op: each translated line is flushed to the client as it is sent
+func (b *backendWriter) Flush() {}
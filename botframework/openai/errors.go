@@ -0,0 +1,26 @@
+// Package openai writes errors in the OpenAI API's shape, which every inference route of
+// the gateway answers with whichever layer refuses the request.
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ErrorType is the type an error with status is reported as when nothing more specific
+// applies: server_error for 5xx statuses, else invalid_request_error
+func ErrorType(status int) string {
+	if status >= http.StatusInternalServerError {
+		return "server_error"
+	}
+	return "invalid_request_error"
+}
+
+// WriteError answers with status and {"error": {"message": ..., "type": ..., "code": ...}}
+func WriteError(w http.ResponseWriter, status int, errType, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{
+		"message": message, "type": errType, "code": code,
+	}})
+}
@@ -0,0 +1,30 @@
+package openai
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	WriteError(rec, http.StatusNotFound, ErrorType(http.StatusNotFound), "model_not_found", "model x does not exist")
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var body struct {
+		Error struct {
+			Message, Type, Code string
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Type != "invalid_request_error" || body.Error.Code != "model_not_found" || body.Error.Message != "model x does not exist" {
+		t.Errorf("error = %+v", body.Error)
+	}
+	if got := ErrorType(http.StatusBadGateway); got != "server_error" {
+		t.Errorf("ErrorType(502) = %q, want server_error", got)
+	}
+}
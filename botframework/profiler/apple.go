@@ -0,0 +1,122 @@
+package profiler
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AppleChip identifies an Apple Silicon SoC. Decoding on unified memory is bound by its
+// bandwidth, which differs fourfold between the base chips and the Max and Ultra parts.
+type AppleChip struct {
+	// Name is the marketing name, e.g. "M2 Pro"
+	Name       string `json:"name"`
+	Generation int    `json:"generation"`
+	// Variant is "", "Pro", "Max" or "Ultra"
+	Variant string `json:"variant,omitempty"`
+	// BandwidthGBs is the memory bandwidth of the fastest configuration, in GB/s
+	BandwidthGBs float64 `json:"bandwidth_gbs"`
+}
+
+// appleBandwidthGBs is the memory bandwidth of each chip, by generation and variant. Binned
+// Max parts have less; the full part's is assumed.
+var appleBandwidthGBs = map[int]map[string]float64{
+	1: {"": 68, "Pro": 200, "Max": 400, "Ultra": 800},
+	2: {"": 100, "Pro": 200, "Max": 400, "Ultra": 800},
+	3: {"": 100, "Pro": 150, "Max": 400, "Ultra": 819},
+	4: {"": 120, "Pro": 273, "Max": 546},
+}
+
+var appleChipPattern = regexp.MustCompile(`(?i)\bM(\d)(?:\s+(Pro|Max|Ultra))?\b`)
+
+// ParseAppleChip reads a chip from a CPU brand string such as "Apple M3 Max" or a name like
+// "m2 ultra". ok is false for chips it does not know.
+func ParseAppleChip(brand string) (chip AppleChip, ok bool) {
+	m := appleChipPattern.FindStringSubmatch(brand)
+	if m == nil {
+		return AppleChip{}, false
+	}
+	generation, _ := strconv.Atoi(m[1])
+	variant := ""
+	if m[2] != "" {
+		variant = strings.ToUpper(m[2][:1]) + strings.ToLower(m[2][1:])
+	}
+	bandwidth, ok := appleBandwidthGBs[generation][variant]
+	if !ok {
+		return AppleChip{}, false
+	}
+	chip = AppleChip{Name: strings.TrimSpace("M" + m[1] + " " + variant), Generation: generation, Variant: variant, BandwidthGBs: bandwidth}
+	return chip, true
+}
+
+// detectAppleChip reads the chip from sysctl's CPU brand string
+func detectAppleChip() *AppleChip {
+	out, err := exec.Command("sysctl", "-n", "machdep.cpu.brand_string").Output()
+	if err != nil {
+		return nil
+	}
+	if chip, ok := ParseAppleChip(string(out)); ok {
+		return &chip
+	}
+	return nil
+}
+
+// decodeEfficiency is the share of the memory bandwidth decoding achieves; each token
+// streams every weight once
+const decodeEfficiency = 0.6
+
+// EstimatedDecodeTPS is the decode rate a variant of sizeGB reaches on the chip's
+// bandwidth, until a probe measures it
+func (c AppleChip) EstimatedDecodeTPS(sizeGB float64) float64 {
+	if sizeGB <= 0 {
+		return 0
+	}
+	return c.BandwidthGBs * decodeEfficiency / sizeGB
+}
+
+// bandwidthScore is the speed term of CalculateScore for an unprobed variant on Apple
+// Silicon, judged like a measured decode rate but from the chip's bandwidth
+func (p *HardwareProfile) bandwidthScore(variant Variant) (float64, string) {
+	if p.Apple == nil || variant.Measured != nil {
+		return 0, ""
+	}
+	tps := p.Apple.EstimatedDecodeTPS(variant.SizeGB)
+	if tps <= 0 {
+		return 0, ""
+	}
+	score := math.Max(-20, math.Min(10, 10*math.Log2(tps/referenceDecodeTPS)))
+	return score, fmt.Sprintf(", Speed: %+.1f (~%.0f tok/s decode estimated from %s's %.0fGB/s)", score, tps, p.Apple.Name, p.Apple.BandwidthGBs)
+}
+
+// sampleAppleUsage fills in the RAM in use, the GPU share of unified memory as VRAM and the
+// kernel's memory pressure level
+func sampleAppleUsage(usage *ResourceUsage) {
+	if vmstat, err := exec.Command("vm_stat").Output(); err == nil {
+		ramMB, _ := detectSystemRAM()
+		if availableMB, ok := parseVMStat(vmstat); ok && ramMB > 0 {
+			usage.RAMTotalMB, usage.RAMUsedMB = ramMB, max(0, ramMB-availableMB)
+		}
+	}
+	for _, gpu := range sampleUnifiedMemory() {
+		usage.VRAMTotalMB, usage.VRAMUsedMB = gpu.TotalMB, gpu.UsedMB
+	}
+	if out, err := exec.Command("sysctl", "-n", "kern.memorystatus_vm_pressure_level").Output(); err == nil {
+		usage.MemoryPressure = parseMemoryPressure(string(out))
+	}
+}
+
+// parseMemoryPressure names a kern.memorystatus_vm_pressure_level value
+func parseMemoryPressure(level string) string {
+	switch strings.TrimSpace(level) {
+	case "1":
+		return "normal"
+	case "2":
+		return "warn"
+	case "4":
+		return "critical"
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAppleChip(t *testing.T) {
+	for brand, want := range map[string]AppleChip{
+		"Apple M1":       {Name: "M1", Generation: 1, BandwidthGBs: 68},
+		"Apple M2 Pro":   {Name: "M2 Pro", Generation: 2, Variant: "Pro", BandwidthGBs: 200},
+		"Apple M3 Max\n": {Name: "M3 Max", Generation: 3, Variant: "Max", BandwidthGBs: 400},
+		"m2 ultra":       {Name: "M2 Ultra", Generation: 2, Variant: "Ultra", BandwidthGBs: 800},
+		"Apple M4 Pro":   {Name: "M4 Pro", Generation: 4, Variant: "Pro", BandwidthGBs: 273},
+	} {
+		if got, ok := ParseAppleChip(brand); !ok || got != want {
+			t.Errorf("ParseAppleChip(%q) = %+v, %v; want %+v", brand, got, ok, want)
+		}
+	}
+	for _, brand := range []string{"Intel(R) Core(TM) i9-9880H", "Apple M9", "Apple M4 Ultra"} {
+		if chip, ok := ParseAppleChip(brand); ok {
+			t.Errorf("ParseAppleChip(%q) = %+v, want unknown", brand, chip)
+		}
+	}
+}
+
+func TestBandwidthScoresUnprobedVariants(t *testing.T) {
+	model := Model{ID: "llama-3-8b", ParamsB: 8, ContextWindow: 8192, Benchmarks: Benchmarks{MMLU: 66}}
+	variant := Variant{Quant: "Q8_0", SizeGB: 8.5, AccuracyRetention: 0.99}
+	base := &HardwareProfile{HasMetal: true, SystemRAM_MB: 64 * 1024, VRAM_MB: 45875}
+	ultra := *base
+	ultra.Apple = &AppleChip{Name: "M2 Ultra", Generation: 2, Variant: "Ultra", BandwidthGBs: 800}
+	m1 := *base
+	m1.Apple = &AppleChip{Name: "M1", Generation: 1, BandwidthGBs: 68}
+
+	plain, _ := base.CalculateScore(model, variant)
+	fast, reason := ultra.CalculateScore(model, variant)
+	slow, _ := m1.CalculateScore(model, variant)
+	if !(slow < plain && plain < fast) {
+		t.Errorf("scores: M1 %.1f, unknown chip %.1f, M2 Ultra %.1f; want bandwidth to order them", slow, plain, fast)
+	}
+	if !strings.Contains(reason, "estimated from M2 Ultra's 800GB/s") {
+		t.Errorf("reason %q does not explain the estimate", reason)
+	}
+
+	variant.Measured = &SpeedMeasurement{DecodeTPS: 20}
+	if measured, reason := ultra.CalculateScore(model, variant); measured >= fast || strings.Contains(reason, "estimated") {
+		t.Errorf("a probed variant should be scored by its measurement: %.1f, %s", measured, reason)
+	}
+}
+
+func TestParseMemoryPressure(t *testing.T) {
+	for level, want := range map[string]string{"1\n": "normal", "2": "warn", "4": "critical", "": ""} {
+		if got := parseMemoryPressure(level); got != want {
+			t.Errorf("parseMemoryPressure(%q) = %q, want %q", level, got, want)
+		}
+	}
+}
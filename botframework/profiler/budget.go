@@ -0,0 +1,138 @@
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// osBufferGB is the memory left to the OS and display when sizing a model
+const osBufferGB = 2.0
+
+// tightHeadroomGB is the headroom below which a variant risks running out of memory
+const tightHeadroomGB = 0.5
+
+// What limits a variant that does not fit
+const (
+	LimitWeights  = "weights"
+	LimitKVCache  = "KV cache"
+	LimitHeadroom = "headroom"
+)
+
+// MemoryBudget breaks down the memory a variant needs for a workload against what the
+// host offers. It is the arithmetic CalculateScoreFor scores, laid out for diagnostics.
+type MemoryBudget struct {
+	// AvailableGB is the memory models may use: VRAM, the GPUs of a tensor-parallel
+	// plan, or system RAM on CPU hosts
+	AvailableGB float64 `json:"available_gb"`
+	OSBufferGB  float64 `json:"os_buffer_gb"`
+	WeightsGB   float64 `json:"weights_gb"`
+	// KVCacheGB covers ContextTokens for each of Sequences, stored as KVCacheType
+	KVCacheGB     float64 `json:"kv_cache_gb"`
+	KVCacheType   string  `json:"kv_cache_type"`
+	ContextTokens int     `json:"context_tokens"`
+	Sequences     int     `json:"sequences"`
+	// ActivationsGB estimates the engine's compute buffers; it is drawn from the headroom
+	ActivationsGB float64 `json:"activations_gb"`
+	// HeadroomGB is what is left after the OS buffer, weights and KV cache
+	HeadroomGB     float64             `json:"headroom_gb"`
+	TensorParallel *TensorParallelPlan `json:"tensor_parallel,omitempty"`
+	// Fits is false when the weights or the KV cache exceed the available memory
+	Fits bool `json:"fits"`
+	// Limit names what stops the variant fitting (LimitWeights, LimitKVCache), or with
+	// LimitHeadroom what makes a fitting variant tight. Empty when there is room to spare.
+	Limit string `json:"limit,omitempty"`
+}
+
+// Budget works out the memory budget of variant serving the workload req describes. Like
+// scoring, requests beyond the model's window are sized at the window, and a variant too
+// large for one GPU is given the memory of a tensor-parallel plan when one holds it.
+func (p *HardwareProfile) Budget(model Model, variant Variant, req RecommendationRequest) MemoryBudget {
+	b := MemoryBudget{
+		AvailableGB:   p.modelMemoryGB(),
+		OSBufferGB:    osBufferGB,
+		WeightsGB:     variant.SizeGB,
+		KVCacheType:   "f16",
+		ContextTokens: model.scoringContext(req.ContextLength),
+		Sequences:     req.sequences(),
+	}
+	if variant.SizeGB > b.AvailableGB && (p.HasCuda || p.HasROCm) {
+		if plan, ok := p.PlanTensorParallel(variant.SizeGB); ok {
+			b.AvailableGB = float64(plan.PerGPU_MB*len(plan.GPUs)-p.ReservedMB) / 1024.0
+			b.TensorParallel = &plan
+		}
+	}
+	safeMemGB := b.AvailableGB - osBufferGB
+	if safeMemGB < 0 {
+		safeMemGB = 0.5 // Minimal fallback
+	}
+
+	var kvNote string
+	b.KVCacheGB, kvNote = model.kvCacheBeside(b.ContextTokens*b.Sequences, safeMemGB-variant.SizeGB)
+	if kvNote != "" {
+		b.KVCacheType = "q8_0"
+	}
+	b.ActivationsGB = model.activationsGB(b.ContextTokens)
+	b.HeadroomGB = safeMemGB - variant.SizeGB - b.KVCacheGB
+
+	switch {
+	case variant.SizeGB > b.AvailableGB:
+		b.Limit = LimitWeights
+	case variant.SizeGB+b.KVCacheGB > b.AvailableGB:
+		b.Limit = LimitKVCache
+	case b.HeadroomGB-b.ActivationsGB <= tightHeadroomGB:
+		b.Fits, b.Limit = true, LimitHeadroom
+	default:
+		b.Fits = true
+	}
+	return b
+}
+
+// kvNote describes the KV cache in score reasons: " q8_0" for the quantized cache and the
+// sequence count when there are several
+func (b MemoryBudget) kvNote() string {
+	note := ""
+	if b.KVCacheType != "f16" {
+		note = " " + b.KVCacheType
+	}
+	if b.Sequences > 1 {
+		note += fmt.Sprintf(" x%d", b.Sequences)
+	}
+	return note
+}
+
+// activationsGB estimates the compute buffers an engine allocates for contextTokens: about
+// 0.1GB plus 20MB per billion parameters, and 50MB per 4k tokens of context
+func (m Model) activationsGB(contextTokens int) float64 {
+	return 0.1 + 0.02*m.ParamsB + 0.05*float64(contextTokens)/DefaultScoringContext
+}
+
+// Write prints the budget as a table, one line per component, ending in the verdict
+func (b MemoryBudget) Write(w io.Writer) {
+	row := func(label string, gb float64, note string) {
+		fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("  %-22s %8.2f GB  %s", label, gb, note), " "))
+	}
+	available := "model memory on this host"
+	if b.TensorParallel != nil {
+		available = b.TensorParallel.String()
+	}
+	row("Available", b.AvailableGB, available)
+	row("Weights", b.WeightsGB, "")
+	row("KV cache", b.KVCacheGB, fmt.Sprintf("%s, %d tokens x %d sequences", b.KVCacheType, b.ContextTokens, b.Sequences))
+	row("Activations (est.)", b.ActivationsGB, "compute buffers")
+	row("OS buffer", b.OSBufferGB, "")
+	row("Headroom", b.HeadroomGB-b.ActivationsGB, "after all of the above")
+
+	switch b.Limit {
+	case LimitWeights:
+		fmt.Fprintf(w, "FAIL: the weights need %.2fGB of the %.2fGB available\n", b.WeightsGB, b.AvailableGB)
+	case LimitKVCache:
+		fmt.Fprintf(w, "FAIL: the KV cache needs %.2fGB beside the weights, %.2fGB more than is available\n",
+			b.KVCacheGB, b.WeightsGB+b.KVCacheGB-b.AvailableGB)
+	case LimitHeadroom:
+		fmt.Fprintf(w, "PASS (tight): %.2fGB of headroom risks running out of memory; shorten the context or pick a smaller quant\n",
+			b.HeadroomGB-b.ActivationsGB)
+	default:
+		fmt.Fprintln(w, "PASS")
+	}
+}
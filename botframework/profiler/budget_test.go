@@ -0,0 +1,41 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBudgetNamesTheLimit(t *testing.T) {
+	model := Model{ParamsB: 7, ContextWindow: 65536, Benchmarks: Benchmarks{MMLU: 60},
+		Architecture: &Architecture{HiddenSize: 4096, Layers: 32, KVHeads: 8, HeadDim: 128}}
+	variant := Variant{Quant: "Q4_K_M", SizeGB: 4.3, AccuracyRetention: 0.97}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 10240}
+
+	budget := profile.Budget(model, variant, RecommendationRequest{ContextLength: 8192})
+	if !budget.Fits || budget.Limit != "" || budget.KVCacheGB != 1.0 || budget.AvailableGB != 10 {
+		t.Errorf("8k budget = %+v", budget)
+	}
+	var out strings.Builder
+	budget.Write(&out)
+	if !strings.HasSuffix(out.String(), "PASS\n") || !strings.Contains(out.String(), "8192 tokens x 1 sequences") {
+		t.Errorf("table:\n%s", out.String())
+	}
+
+	// 64k needs 8GB of cache beside the 4.3GB of weights
+	budget = profile.Budget(model, variant, RecommendationRequest{ContextLength: 65536})
+	if budget.Fits || budget.Limit != LimitKVCache {
+		t.Errorf("64k budget = %+v", budget)
+	}
+	if score, _ := profile.CalculateScoreFor(model, variant, RecommendationRequest{ContextLength: 65536}); score != 0 {
+		t.Errorf("scored %.1f a variant whose budget does not fit", score)
+	}
+	out.Reset()
+	budget.Write(&out)
+	if !strings.Contains(out.String(), "FAIL: the KV cache") {
+		t.Errorf("table:\n%s", out.String())
+	}
+
+	if budget := profile.Budget(model, Variant{SizeGB: 12}, RecommendationRequest{}); budget.Limit != LimitWeights {
+		t.Errorf("12GB of weights on a 10GB GPU: %+v", budget)
+	}
+}
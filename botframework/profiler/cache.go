@@ -0,0 +1,147 @@
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultProfileCacheTTL is how long a cached hardware profile is trusted when its
+// fingerprint still matches
+const DefaultProfileCacheTTL = 7 * 24 * time.Hour
+
+// cachedProfile is the file DetectHardwareCached keeps between runs
+type cachedProfile struct {
+	Fingerprint string           `json:"fingerprint"`
+	DetectedAt  time.Time        `json:"detected_at"`
+	Profile     *HardwareProfile `json:"profile"`
+}
+
+// DefaultProfileCachePath returns ~/.cache/botframework/hardware.json
+func DefaultProfileCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "botframework", "hardware.json")
+}
+
+// DetectHardwareCached returns the profile cached at path while the host's fingerprint
+// matches it and it is younger than ttl, sparing the external tools DetectHardware runs.
+// Otherwise, or with force, it detects the hardware again and caches the result. The
+// memory available to new processes changes from run to run and is always read afresh.
+// cached reports whether the profile came from the cache.
+func DetectHardwareCached(path string, ttl time.Duration, force bool) (profile *HardwareProfile, cached bool) {
+	fingerprint := HostFingerprint()
+	if !force {
+		if entry, err := readProfileCache(path); err != nil {
+			slog.Warn("ignoring cached hardware profile", "path", path, "err", err)
+		} else if entry != nil && entry.Fingerprint == fingerprint && time.Since(entry.DetectedAt) < ttl {
+			_, entry.Profile.SystemRAMAvailable_MB = detectSystemRAM()
+			return entry.Profile, true
+		} else if entry != nil && entry.Fingerprint != fingerprint {
+			slog.Info("hardware changed, detecting it again", "was", entry.Fingerprint, "now", fingerprint)
+		}
+	}
+
+	profile = DetectHardware()
+	if err := writeProfileCache(path, cachedProfile{Fingerprint: fingerprint, DetectedAt: time.Now().UTC(), Profile: profile}); err != nil {
+		slog.Warn("hardware profile not cached", "path", path, "err", err)
+	}
+	return profile, false
+}
+
+// readProfileCache returns nil without an error when nothing is cached yet
+func readProfileCache(path string) (*cachedProfile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry cachedProfile
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if entry.Profile == nil {
+		return nil, errors.New("no profile in the cache")
+	}
+	return &entry, nil
+}
+
+func writeProfileCache(path string, entry cachedProfile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// HostFingerprint summarises the hardware cheaply enough to check on every startup: the
+// OS and architecture, the CPU model, the PCI IDs of the display controllers (Linux only)
+// and the system RAM in GB. A new GPU, CPU or memory changes it.
+func HostFingerprint() string {
+	ramMB, _ := detectSystemRAM()
+	parts := []string{runtime.GOOS + "/" + runtime.GOARCH, "cpu=" + cpuModel()}
+	if gpus := displayPCIIDs("/sys/bus/pci/devices"); len(gpus) > 0 {
+		parts = append(parts, "gpus="+strings.Join(gpus, ","))
+	}
+	parts = append(parts, fmt.Sprintf("ram=%dGB", (ramMB+512)/1024))
+	return strings.Join(parts, " ")
+}
+
+// cpuModel reads the CPU's model name without the topology and clock probing of detectCPU
+func cpuModel() string {
+	cpu := CPUInfo{}
+	switch runtime.GOOS {
+	case "linux":
+		if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+			parseCPUInfo(string(data), &cpu)
+		}
+	case "darwin":
+		out, _ := exec.Command("sysctl", "-n", "machdep.cpu.brand_string").Output()
+		cpu.Model = strings.TrimSpace(string(out))
+	case "windows":
+		cpu.Model = os.Getenv("PROCESSOR_IDENTIFIER")
+	}
+	return cpu.Model
+}
+
+// displayPCIIDs lists the vendor:device IDs of the PCI display controllers (class 0x03)
+// under root, sorted
+func displayPCIIDs(root string) []string {
+	var ids []string
+	devices, _ := os.ReadDir(root)
+	for _, device := range devices {
+		dir := filepath.Join(root, device.Name())
+		class, err := os.ReadFile(filepath.Join(dir, "class"))
+		if err != nil || !strings.HasPrefix(strings.TrimSpace(string(class)), "0x03") {
+			continue
+		}
+		vendor, err1 := os.ReadFile(filepath.Join(dir, "vendor"))
+		id, err2 := os.ReadFile(filepath.Join(dir, "device"))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		ids = append(ids, strings.TrimPrefix(strings.TrimSpace(string(vendor)), "0x")+":"+strings.TrimPrefix(strings.TrimSpace(string(id)), "0x"))
+	}
+	slices.Sort(ids)
+	return ids
+}
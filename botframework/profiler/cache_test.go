@@ -0,0 +1,62 @@
+package profiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDetectHardwareCachedReusesMatchingProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hardware.json")
+	fake := &HardwareProfile{HasCuda: true, VRAM_MB: 81920, SystemRAM_MB: 1 << 20,
+		GPUs: []GPUInfo{{Index: 0, Name: "H100", VRAM_MB: 81920}}}
+	cache := func(fingerprint string, age time.Duration) {
+		t.Helper()
+		if err := writeProfileCache(path, cachedProfile{Fingerprint: fingerprint, DetectedAt: time.Now().Add(-age), Profile: fake}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache(HostFingerprint(), time.Hour)
+	profile, cached := DetectHardwareCached(path, DefaultProfileCacheTTL, false)
+	if !cached || profile.VRAM_MB != 81920 || len(profile.GPUs) != 1 || profile.GPUs[0].Name != "H100" {
+		t.Errorf("matching cache: cached=%v profile=%+v", cached, profile)
+	}
+	if _, cached := DetectHardwareCached(path, DefaultProfileCacheTTL, true); cached {
+		t.Error("--force-redetect used the cache")
+	}
+
+	for name, age := range map[string]time.Duration{"changed": time.Hour, "expired": 8 * 24 * time.Hour} {
+		fingerprint := HostFingerprint()
+		if name == "changed" {
+			fingerprint += " gpus=10de:2330"
+		}
+		cache(fingerprint, age)
+		if profile, cached := DetectHardwareCached(path, DefaultProfileCacheTTL, false); cached || profile.VRAM_MB == 81920 {
+			t.Errorf("%s cache was used", name)
+		}
+		entry, err := readProfileCache(path)
+		if err != nil || entry.Fingerprint != HostFingerprint() || time.Since(entry.DetectedAt) > time.Minute {
+			t.Errorf("%s cache was not replaced: %+v %v", name, entry, err)
+		}
+	}
+}
+
+func TestDisplayPCIIDs(t *testing.T) {
+	root := t.TempDir()
+	for name, files := range map[string][3]string{
+		"0000:01:00.0": {"0x030000", "0x10de", "0x2684"}, // VGA
+		"0000:00:1f.3": {"0x040300", "0x8086", "0x7a50"}, // audio
+		"0000:41:00.0": {"0x038000", "0x1002", "0x744c"}, // display controller
+	} {
+		dir := filepath.Join(root, name)
+		os.MkdirAll(dir, 0o755)
+		for i, file := range []string{"class", "vendor", "device"} {
+			os.WriteFile(filepath.Join(dir, file), []byte(files[i]+"\n"), 0o644)
+		}
+	}
+	if got := displayPCIIDs(root); len(got) != 2 || got[0] != "1002:744c" || got[1] != "10de:2684" {
+		t.Errorf("displayPCIIDs = %v", got)
+	}
+}
@@ -0,0 +1,225 @@
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ModelFormat is how a model's weights are stored on disk
+type ModelFormat string
+
+const (
+	FormatGGUF ModelFormat = "gguf"
+	// FormatSafetensors is a Hugging Face checkpoint, unquantized or quantized with AWQ,
+	// GPTQ or FP8
+	FormatSafetensors ModelFormat = "safetensors"
+	FormatMLX         ModelFormat = "mlx"
+	FormatEXL2        ModelFormat = "exl2"
+)
+
+// Capabilities is what an engine can load and serve
+type Capabilities struct {
+	Formats []ModelFormat
+	// Quants lists the weight quantizations the engine loads, lowercase; nil loads every
+	// quantization of its formats
+	Quants     []string
+	Embeddings bool
+	// SpeculativeDecoding is drafting with a smaller model of the same family
+	SpeculativeDecoding bool
+}
+
+// EngineCapabilities maps every engine to what it can run. Workers are checked against it
+// before they launch, so a model the engine cannot load fails with an error naming an
+// engine that can instead of a worker crashing on startup.
+var EngineCapabilities = map[Engine]Capabilities{
+	EngineVLLM: {Formats: []ModelFormat{FormatSafetensors},
+		Quants: []string{"f16", "bf16", "fp8", "awq", "gptq"}, Embeddings: true},
+	EngineExLlamaV2: {Formats: []ModelFormat{FormatEXL2, FormatSafetensors},
+		Quants: []string{"exl2", "gptq", "f16", "bf16"}},
+	EngineMLX: {Formats: []ModelFormat{FormatMLX, FormatSafetensors},
+		Quants: []string{"q4", "q8", "f16", "bf16"}},
+	EngineLlamaCPP:     {Formats: []ModelFormat{FormatGGUF}, Embeddings: true, SpeculativeDecoding: true},
+	EngineLlamaCPPSYCL: {Formats: []ModelFormat{FormatGGUF}, Embeddings: true},
+	EngineIPEXLLM: {Formats: []ModelFormat{FormatSafetensors},
+		Quants: []string{"f16", "bf16", "awq", "gptq"}},
+}
+
+// ErrUnsupportedModel is returned by CheckModel for a model the engine cannot serve
+var ErrUnsupportedModel = errors.New("unsupported model")
+
+// ModelFiles describes the weights at a path, as InspectModel finds them
+type ModelFiles struct {
+	Format ModelFormat
+	// Quant is the weight quantization, lowercase: q4_k_m, awq, q4 for 4-bit MLX weights;
+	// empty when unknown
+	Quant string
+}
+
+func (m ModelFiles) String() string {
+	if m.Quant == "" {
+		return string(m.Format)
+	}
+	if m.Format == FormatGGUF {
+		return "GGUF " + strings.ToUpper(m.Quant)
+	}
+	return string(m.Format) + " " + m.Quant
+}
+
+// Supports reports whether the engine loads model; a model of unknown format is assumed
+// to load, as is an unknown quantization of a supported format
+func (c Capabilities) Supports(model ModelFiles) bool {
+	if model.Format == "" {
+		return true
+	}
+	if !slices.Contains(c.Formats, model.Format) {
+		return false
+	}
+	return model.Quant == "" || c.Quants == nil || slices.Contains(c.Quants, model.Quant)
+}
+
+// CheckModel returns an ErrUnsupportedModel error when engine cannot serve the weights at
+// path, in embedding mode if embedding is set. The error names the engines that can.
+func CheckModel(engine Engine, path string, embedding bool) error {
+	caps, ok := EngineCapabilities[engine]
+	if !ok {
+		return nil
+	}
+	model, err := InspectModel(path)
+	if err != nil {
+		return err
+	}
+	if embedding && !caps.Embeddings {
+		return fmt.Errorf("%w: %s cannot serve embeddings (%s)%s", ErrUnsupportedModel, engine, filepath.Base(path),
+			alternatives(model, true))
+	}
+	if caps.Supports(model) {
+		return nil
+	}
+	formats := make([]string, len(caps.Formats))
+	for i, format := range caps.Formats {
+		formats[i] = string(format)
+	}
+	return fmt.Errorf("%w: %s cannot load %s weights (%s); it loads %s%s", ErrUnsupportedModel, engine, model,
+		filepath.Base(path), strings.Join(formats, " or "), alternatives(model, embedding))
+}
+
+// alternatives suggests the engines that can serve model
+func alternatives(model ModelFiles, embedding bool) string {
+	var engines []string
+	for _, engine := range Engines {
+		caps := EngineCapabilities[engine]
+		if caps.Supports(model) && (!embedding || caps.Embeddings) {
+			engines = append(engines, string(engine))
+		}
+	}
+	if len(engines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("; run it with --engine %s", strings.Join(engines, " or --engine "))
+}
+
+var ggufQuant = regexp.MustCompile(`(?i)(?:^|[-_.])((?:iq|q)\d(?:_[a-z0-9]+)*|f16|bf16|f32)(?:[-_.]|$)`)
+
+// InspectModel finds the format and quantization of the weights at path: a GGUF file, or a
+// Hugging Face checkpoint directory whose config.json tells AWQ, GPTQ, EXL2 and MLX
+// weights apart. Anything else has an empty format.
+func InspectModel(path string) (ModelFiles, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ModelFiles{}, err
+	}
+	if !info.IsDir() {
+		return inspectFile(path)
+	}
+
+	data, err := os.ReadFile(filepath.Join(path, "config.json"))
+	if err != nil {
+		// a directory holding a single GGUF file, as downloads are cached
+		ggufs, _ := filepath.Glob(filepath.Join(path, "*.gguf"))
+		if len(ggufs) == 1 {
+			return inspectFile(ggufs[0])
+		}
+		return ModelFiles{}, nil
+	}
+	var config struct {
+		TorchDtype   string `json:"torch_dtype"`
+		Quantization *struct {
+			Bits int `json:"bits"`
+		} `json:"quantization"`
+		QuantizationConfig *struct {
+			QuantMethod string `json:"quant_method"`
+		} `json:"quantization_config"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ModelFiles{}, fmt.Errorf("%s: %w", filepath.Join(path, "config.json"), err)
+	}
+	switch {
+	case config.Quantization != nil:
+		// mlx_lm.convert writes its group size and bits under "quantization"
+		return ModelFiles{Format: FormatMLX, Quant: fmt.Sprintf("q%d", config.Quantization.Bits)}, nil
+	case config.QuantizationConfig != nil && config.QuantizationConfig.QuantMethod == "exl2":
+		return ModelFiles{Format: FormatEXL2, Quant: "exl2"}, nil
+	case config.QuantizationConfig != nil:
+		return ModelFiles{Format: FormatSafetensors, Quant: strings.ToLower(config.QuantizationConfig.QuantMethod)}, nil
+	}
+	quant := map[string]string{"float16": "f16", "bfloat16": "bf16"}[config.TorchDtype]
+	return ModelFiles{Format: FormatSafetensors, Quant: quant}, nil
+}
+
+// CheckpointModel reads the context window and the attention dimensions that size the KV
+// cache from the config.json of the Hugging Face checkpoint in dir
+func CheckpointModel(dir string) (Model, error) {
+	path := filepath.Join(dir, "config.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Model{}, err
+	}
+	var config struct {
+		MaxPositionEmbeddings int `json:"max_position_embeddings"`
+		HiddenSize            int `json:"hidden_size"`
+		Layers                int `json:"num_hidden_layers"`
+		AttentionHeads        int `json:"num_attention_heads"`
+		KVHeads               int `json:"num_key_value_heads"`
+		HeadDim               int `json:"head_dim"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Model{}, fmt.Errorf("%s: %w", path, err)
+	}
+	arch := &Architecture{HiddenSize: config.HiddenSize, Layers: config.Layers, KVHeads: config.KVHeads, HeadDim: config.HeadDim}
+	if arch.KVHeads == 0 {
+		arch.KVHeads = config.AttentionHeads
+	}
+	if arch.HeadDim == 0 && config.AttentionHeads > 0 {
+		arch.HeadDim = config.HiddenSize / config.AttentionHeads
+	}
+	return Model{ContextWindow: config.MaxPositionEmbeddings, Architecture: arch}, nil
+}
+
+// inspectFile recognises GGUF files by their magic number, taking the quantization from
+// the file name, and single safetensors files by their extension
+func inspectFile(path string) (ModelFiles, error) {
+	if strings.EqualFold(filepath.Ext(path), ".safetensors") {
+		return ModelFiles{Format: FormatSafetensors}, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return ModelFiles{}, err
+	}
+	defer file.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil || string(magic) != "GGUF" {
+		return ModelFiles{}, nil
+	}
+	model := ModelFiles{Format: FormatGGUF}
+	if match := ggufQuant.FindStringSubmatch(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))); match != nil {
+		model.Quant = strings.ToLower(match[1])
+	}
+	return model, nil
+}
@@ -0,0 +1,103 @@
+package profiler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInspectModel(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(content), 0o644)
+		return path
+	}
+	checkpoint := func(name, config string) string {
+		file(filepath.Join(name, "model.safetensors"), "")
+		return filepath.Dir(file(filepath.Join(name, "config.json"), config))
+	}
+
+	for path, want := range map[string]ModelFiles{
+		file("llama-3-8b.Q4_K_M.gguf", "GGUF\x03\x00\x00\x00"):                                      {Format: FormatGGUF, Quant: "q4_k_m"},
+		file("phi-3-mini-4k-instruct-f16.gguf", "GGUF"):                                             {Format: FormatGGUF, Quant: "f16"},
+		file("model.bin", "GGUF"):                                                                   {Format: FormatGGUF},
+		file("notes.txt", "hello"):                                                                  {},
+		file("model.safetensors", ""):                                                               {Format: FormatSafetensors},
+		checkpoint("llama-fp16", `{"torch_dtype": "bfloat16"}`):                                     {Format: FormatSafetensors, Quant: "bf16"},
+		checkpoint("llama-awq", `{"quantization_config": {"quant_method": "AWQ", "bits": 4}}`):      {Format: FormatSafetensors, Quant: "awq"},
+		checkpoint("llama-exl2", `{"quantization_config": {"quant_method": "exl2", "bits": 4.65}}`): {Format: FormatEXL2, Quant: "exl2"},
+		checkpoint("llama-mlx", `{"quantization": {"group_size": 64, "bits": 4}}`):                  {Format: FormatMLX, Quant: "q4"},
+	} {
+		got, err := InspectModel(path)
+		if err != nil || got != want {
+			t.Errorf("InspectModel(%s) = %+v, %v; want %+v", filepath.Base(path), got, err, want)
+		}
+	}
+	if _, err := InspectModel(filepath.Join(dir, "missing.gguf")); err == nil {
+		t.Error("a missing model should be an error")
+	}
+}
+
+func TestCheckpointModel(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"max_position_embeddings": 8192, "hidden_size": 4096,
+		"num_hidden_layers": 32, "num_attention_heads": 32, "num_key_value_heads": 8}`), 0o644)
+	model, err := CheckpointModel(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.ContextWindow != 8192 || *model.Architecture != (Architecture{HiddenSize: 4096, Layers: 32, KVHeads: 8, HeadDim: 128}) {
+		t.Errorf("CheckpointModel() = %+v, %+v", model, *model.Architecture)
+	}
+	// 32 layers x a key and a value of 8 heads x 128 f16 values
+	if kb := model.KVCacheGB(1, 2) * (1 << 20); kb != 128 {
+		t.Errorf("KV cache per token = %.0fKB, want 128KB", kb)
+	}
+	if _, err := CheckpointModel(t.TempDir()); err == nil {
+		t.Error("a directory without config.json should be an error")
+	}
+}
+
+func TestCheckModel(t *testing.T) {
+	dir := t.TempDir()
+	gguf := filepath.Join(dir, "llama-3-8b.Q4_K_M.gguf")
+	os.WriteFile(gguf, []byte("GGUF"), 0o644)
+	mlx := filepath.Join(dir, "llama-mlx")
+	os.Mkdir(mlx, 0o755)
+	os.WriteFile(filepath.Join(mlx, "config.json"), []byte(`{"quantization": {"group_size": 64, "bits": 4}}`), 0o644)
+
+	for _, engine := range []Engine{EngineLlamaCPP, EngineLlamaCPPSYCL} {
+		if err := CheckModel(engine, gguf, true); err != nil {
+			t.Errorf("%s should serve GGUF embeddings: %v", engine, err)
+		}
+	}
+	err := CheckModel(EngineVLLM, gguf, false)
+	if !errors.Is(err, ErrUnsupportedModel) {
+		t.Fatalf("vLLM loading GGUF: %v", err)
+	}
+	for _, want := range []string{"vllm cannot load GGUF Q4_K_M", "it loads safetensors", "--engine llama_cpp or --engine llama_cpp_sycl"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	if err := CheckModel(EngineMLX, mlx, false); err != nil {
+		t.Errorf("MLX loading its own weights: %v", err)
+	}
+	if err := CheckModel(EngineLlamaCPP, mlx, false); !errors.Is(err, ErrUnsupportedModel) || !strings.Contains(err.Error(), "--engine mlx") {
+		t.Errorf("llama.cpp loading MLX weights: %v", err)
+	}
+	if err := CheckModel(EngineMLX, mlx, true); !errors.Is(err, ErrUnsupportedModel) || !strings.Contains(err.Error(), "embeddings") {
+		t.Errorf("MLX embeddings: %v", err)
+	}
+
+	for _, engine := range Engines {
+		if _, ok := EngineCapabilities[engine]; !ok {
+			t.Errorf("%s has no capabilities", engine)
+		}
+	}
+}
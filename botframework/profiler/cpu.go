@@ -0,0 +1,196 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CPUInfo describes the host CPU. x86 features come from CPUID and count only when the OS
+// saves the matching register state; fields stay zero when the platform does not report them.
+type CPUInfo struct {
+	Model         string
+	Arch          string // GOARCH
+	PhysicalCores int
+	LogicalCores  int
+	BaseMHz       int
+	AVX           bool
+	AVX2          bool
+	AVX512F       bool
+	AVX512BW      bool
+	AVX512VNNI    bool
+	AVX512BF16    bool
+	AMX           bool // AMX tiles with INT8 or BF16 multiplication
+	NEON          bool
+	SVE           bool
+}
+
+// detectCPU combines CPUID with the platform's topology and clock reporting
+func detectCPU() CPUInfo {
+	cpu := CPUInfo{Arch: runtime.GOARCH, LogicalCores: runtime.NumCPU()}
+	detectX86Features(&cpu)
+	// NEON (Advanced SIMD) is mandatory on ARMv8
+	cpu.NEON = runtime.GOARCH == "arm64"
+
+	switch runtime.GOOS {
+	case "linux":
+		if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+			parseCPUInfo(string(data), &cpu)
+		}
+		if mhz, ok := readSysfsKHz("/sys/devices/system/cpu/cpu0/cpufreq/base_frequency"); ok {
+			cpu.BaseMHz = mhz
+		}
+	case "darwin":
+		sysctl := func(name string) string {
+			out, _ := exec.Command("sysctl", "-n", name).Output()
+			return strings.TrimSpace(string(out))
+		}
+		cpu.PhysicalCores, _ = strconv.Atoi(sysctl("hw.physicalcpu"))
+		if logical, err := strconv.Atoi(sysctl("hw.logicalcpu")); err == nil {
+			cpu.LogicalCores = logical
+		}
+		if brand := sysctl("machdep.cpu.brand_string"); brand != "" {
+			cpu.Model = brand
+		}
+		// only Intel Macs report a nominal frequency
+		if hz, err := strconv.ParseInt(sysctl("hw.cpufrequency"), 10, 64); err == nil && hz > 0 {
+			cpu.BaseMHz = int(hz / 1e6)
+		}
+	case "windows":
+		out, err := exec.Command("powershell", "-NoProfile", "-Command",
+			`Get-CimInstance Win32_Processor | ForEach-Object { "$($_.NumberOfCores),$($_.NumberOfLogicalProcessors),$($_.MaxClockSpeed)" }`).Output()
+		if err == nil {
+			parseWin32Processor(string(out), &cpu)
+		}
+	}
+	return cpu
+}
+
+// parseCPUInfo reads /proc/cpuinfo: logical CPUs, physical cores as distinct (package,
+// core) pairs, the model name and ARM feature flags
+func parseCPUInfo(data string, cpu *CPUInfo) {
+	cores := map[string]bool{}
+	logical := 0
+	physicalID := ""
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "processor":
+			logical++
+		case "physical id":
+			physicalID = value
+		case "core id":
+			cores[physicalID+"/"+value] = true
+		case "model name":
+			if cpu.Model == "" {
+				cpu.Model = value
+			}
+		case "Features":
+			for _, feature := range strings.Fields(value) {
+				switch feature {
+				case "asimd":
+					cpu.NEON = true
+				case "sve":
+					cpu.SVE = true
+				}
+			}
+		}
+	}
+	if logical > 0 {
+		cpu.LogicalCores = logical
+	}
+	if len(cores) > 0 {
+		cpu.PhysicalCores = len(cores)
+	} else if logical > 0 {
+		// ARM kernels list no core ids; their cores run one thread each
+		cpu.PhysicalCores = logical
+	}
+}
+
+// parseWin32Processor sums "cores,logical,MHz" lines, one per socket
+func parseWin32Processor(out string, cpu *CPUInfo) {
+	physical, logical := 0, 0
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if len(fields) != 3 {
+			continue
+		}
+		cores, err1 := strconv.Atoi(fields[0])
+		threads, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		physical += cores
+		logical += threads
+		if mhz, err := strconv.Atoi(fields[2]); err == nil {
+			cpu.BaseMHz = mhz
+		}
+	}
+	if physical > 0 {
+		cpu.PhysicalCores, cpu.LogicalCores = physical, logical
+	}
+}
+
+func readSysfsKHz(path string) (int, bool) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return 0, false
+	}
+	khz, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	return khz / 1000, err == nil && khz > 0
+}
+
+// HasVectorUnit reports whether llama.cpp's fast CPU kernels apply: AVX2 on x86, NEON on ARM
+func (c CPUInfo) HasVectorUnit() bool {
+	return c.AVX2 || c.NEON
+}
+
+// InferenceThreads is the thread count for CPU inference. Token generation is bound by memory
+// bandwidth, and a second hyper-thread per core only adds contention, so use physical cores.
+func (c CPUInfo) InferenceThreads() int {
+	if c.PhysicalCores > 0 {
+		return c.PhysicalCores
+	}
+	// unknown topology: assume two threads per core
+	return max(1, c.LogicalCores/2)
+}
+
+// InferenceBatch is the llama.cpp micro-batch (-ub) for prompt processing on the CPU. Wide
+// matrix units process larger batches efficiently; scalar code is better served by small ones.
+func (c CPUInfo) InferenceBatch() int {
+	switch {
+	case c.AMX || c.AVX512F:
+		return 512
+	case c.HasVectorUnit():
+		return 256
+	}
+	return 128
+}
+
+// String summarises the CPU, e.g. "16C/32T 3000MHz AVX2 AVX-512F VNNI"
+func (c CPUInfo) String() string {
+	summary := fmt.Sprintf("%dC/%dT", c.PhysicalCores, c.LogicalCores)
+	if c.BaseMHz > 0 {
+		summary += fmt.Sprintf(" %dMHz", c.BaseMHz)
+	}
+	features := []struct {
+		name string
+		ok   bool
+	}{{"AVX2", c.AVX2}, {"AVX-512F", c.AVX512F}, {"VNNI", c.AVX512VNNI}, {"BF16", c.AVX512BF16}, {"AMX", c.AMX}, {"NEON", c.NEON}, {"SVE", c.SVE}}
+	for _, f := range features {
+		if f.ok {
+			summary += " " + f.name
+		}
+	}
+	return summary
+}
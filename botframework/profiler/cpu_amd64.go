@@ -0,0 +1,62 @@
+package profiler
+
+import "strings"
+
+// cpuid and xgetbv are implemented in cpu_amd64.s
+func cpuid(leaf, subleaf uint32) (eax, ebx, ecx, edx uint32)
+func xgetbv() (eax, edx uint32)
+
+// XCR0 bits the OS sets when it saves the register state a feature needs
+const (
+	xcr0AVX    = 1<<1 | 1<<2        // XMM and YMM
+	xcr0AVX512 = 1<<5 | 1<<6 | 1<<7 // opmask, ZMM0-15 upper halves, ZMM16-31
+	xcr0AMX    = 1<<17 | 1<<18      // tile config and tile data
+)
+
+func detectX86Features(cpu *CPUInfo) {
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 1 {
+		return
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	const osxsave, avx = 1 << 27, 1 << 28
+	var xcr0 uint32
+	if ecx1&osxsave != 0 {
+		xcr0, _ = xgetbv()
+	}
+	osAVX := xcr0&xcr0AVX == xcr0AVX
+	osAVX512 := osAVX && xcr0&xcr0AVX512 == xcr0AVX512
+	osAMX := xcr0&xcr0AMX == xcr0AMX
+	cpu.AVX = osAVX && ecx1&avx != 0
+
+	if maxLeaf >= 7 {
+		eax7, ebx7, ecx7, edx7 := cpuid(7, 0)
+		bit := func(reg uint32, n uint) bool { return reg&(1<<n) != 0 }
+		cpu.AVX2 = cpu.AVX && bit(ebx7, 5)
+		cpu.AVX512F = osAVX512 && bit(ebx7, 16)
+		cpu.AVX512BW = cpu.AVX512F && bit(ebx7, 30)
+		cpu.AVX512VNNI = cpu.AVX512F && bit(ecx7, 11)
+		cpu.AMX = osAMX && bit(edx7, 24) && (bit(edx7, 25) || bit(edx7, 22))
+		if eax7 >= 1 {
+			eax71, _, _, _ := cpuid(7, 1)
+			cpu.AVX512BF16 = cpu.AVX512F && bit(eax71, 5)
+		}
+	}
+	if maxLeaf >= 0x16 {
+		// processor frequency leaf, Intel only; EAX is the base clock in MHz
+		if base, _, _, _ := cpuid(0x16, 0); base > 0 {
+			cpu.BaseMHz = int(base)
+		}
+	}
+
+	if maxExt, _, _, _ := cpuid(0x80000000, 0); maxExt >= 0x80000004 {
+		var brand []byte
+		for leaf := uint32(0x80000002); leaf <= 0x80000004; leaf++ {
+			a, b, c, d := cpuid(leaf, 0)
+			for _, reg := range []uint32{a, b, c, d} {
+				brand = append(brand, byte(reg), byte(reg>>8), byte(reg>>16), byte(reg>>24))
+			}
+		}
+		cpu.Model = strings.TrimSpace(strings.TrimRight(string(brand), "\x00"))
+	}
+}
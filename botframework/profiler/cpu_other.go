@@ -0,0 +1,5 @@
+//go:build !amd64
+
+package profiler
+
+func detectX86Features(cpu *CPUInfo) {}
@@ -0,0 +1,65 @@
+package profiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseCPUInfoCountsPhysicalCores(t *testing.T) {
+	// two sockets of two cores with two hyper-threads each
+	var b strings.Builder
+	n := 0
+	for socket := 0; socket < 2; socket++ {
+		for thread := 0; thread < 2; thread++ {
+			for core := 0; core < 2; core++ {
+				fmt.Fprintf(&b, "processor\t: %d\nmodel name\t: Intel(R) Xeon(R) Gold 6430\nphysical id\t: %d\ncore id\t\t: %d\n\n", n, socket, core)
+				n++
+			}
+		}
+	}
+	var cpu CPUInfo
+	parseCPUInfo(b.String(), &cpu)
+	if cpu.LogicalCores != 8 || cpu.PhysicalCores != 4 || cpu.Model != "Intel(R) Xeon(R) Gold 6430" {
+		t.Errorf("cpu = %+v", cpu)
+	}
+	if cpu.InferenceThreads() != 4 {
+		t.Errorf("threads = %d, want one per physical core", cpu.InferenceThreads())
+	}
+}
+
+func TestParseCPUInfoARMFeatures(t *testing.T) {
+	info := "processor\t: 0\nFeatures\t: fp asimd evtstrm aes sve sve2\n\nprocessor\t: 1\nFeatures\t: fp asimd evtstrm aes sve sve2\n"
+	var cpu CPUInfo
+	parseCPUInfo(info, &cpu)
+	if !cpu.NEON || !cpu.SVE || cpu.PhysicalCores != 2 || cpu.LogicalCores != 2 {
+		t.Errorf("cpu = %+v", cpu)
+	}
+}
+
+func TestParseWin32ProcessorSumsSockets(t *testing.T) {
+	var cpu CPUInfo
+	parseWin32Processor("16,32,2100\r\n16,32,2100\r\n", &cpu)
+	if cpu.PhysicalCores != 32 || cpu.LogicalCores != 64 || cpu.BaseMHz != 2100 {
+		t.Errorf("cpu = %+v", cpu)
+	}
+}
+
+func TestClassifyTierWeighsCPUFeatures(t *testing.T) {
+	cases := []struct {
+		name    string
+		profile HardwareProfile
+		want    Tier
+	}{
+		{"32GB, no CPU details", HardwareProfile{SystemRAM_MB: 32768}, TierBalanced},
+		{"32GB AVX2", HardwareProfile{SystemRAM_MB: 32768, CPU: CPUInfo{LogicalCores: 8, AVX2: true}}, TierBalanced},
+		{"32GB without AVX2", HardwareProfile{SystemRAM_MB: 32768, CPU: CPUInfo{LogicalCores: 8, AVX: true}}, TierLegacy},
+		{"16GB AMX server", HardwareProfile{SystemRAM_MB: 16384, CPU: CPUInfo{LogicalCores: 32, PhysicalCores: 16, AVX2: true, AVX512F: true, AMX: true}}, TierBalanced},
+		{"16GB AVX2 laptop", HardwareProfile{SystemRAM_MB: 16384, CPU: CPUInfo{LogicalCores: 8, PhysicalCores: 4, AVX2: true}}, TierLegacy},
+	}
+	for _, c := range cases {
+		if got := c.profile.ClassifyTier(); got != c.want {
+			t.Errorf("%s: %s, want %s", c.name, got, c.want)
+		}
+	}
+}
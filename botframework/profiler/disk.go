@@ -0,0 +1,294 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DiskClass is the kind of drive the model cache lives on
+type DiskClass string
+
+const (
+	DiskNVMe    DiskClass = "nvme"
+	DiskSSD     DiskClass = "ssd"
+	DiskHDD     DiskClass = "hdd"
+	DiskUnknown DiskClass = "unknown"
+)
+
+// typicalReadMBps is assumed for sequential reads when nothing could be measured
+var typicalReadMBps = map[DiskClass]float64{DiskNVMe: 2000, DiskSSD: 450, DiskHDD: 120}
+
+// slowLoad is the model load time above which recommendations warn
+const slowLoad = 60 * time.Second
+
+// Read benchmark budget: at most benchmarkBytes or benchmarkTime of the largest cached
+// file, which must hold at least benchmarkMinBytes for a meaningful number
+const (
+	benchmarkBytes    = 256 << 20
+	benchmarkMinBytes = 32 << 20
+	benchmarkTime     = 2 * time.Second
+)
+
+// DiskInfo describes the volume models are stored on
+type DiskInfo struct {
+	Path   string    `json:"path"`
+	FreeGB float64   `json:"free_gb"` // 0 when unknown
+	Class  DiskClass `json:"class"`
+	// ReadMBps is the sequential read speed, measured on a cached model when one is large
+	// enough and otherwise typical for Class (0 when unknown). Files still in the page cache
+	// read faster than the disk, so a measurement only errs on the optimistic side.
+	ReadMBps float64 `json:"read_mbps"`
+	Measured bool    `json:"measured"`
+	// cached holds the "model/quant" directories already downloaded, which need no space
+	cached map[string]bool
+}
+
+// DetectDisk profiles the volume holding dir, the model cache: free space, drive class
+// and read speed. dir need not exist yet; its nearest existing parent is used.
+func (p *HardwareProfile) DetectDisk(dir string) {
+	p.Disk = detectDisk(dir)
+}
+
+func detectDisk(dir string) *DiskInfo {
+	info := &DiskInfo{Path: dir, Class: DiskUnknown, cached: make(map[string]bool)}
+	existing := dir
+	for {
+		if _, err := os.Stat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return info
+		}
+		existing = parent
+	}
+	if free, ok := freeBytes(existing); ok {
+		info.FreeGB = float64(free) / (1 << 30)
+	}
+	switch runtime.GOOS {
+	case "linux":
+		info.Class = classifyLinux(existing, "/proc/self/mountinfo", "/sys")
+	case "darwin":
+		if out, err := exec.Command("diskutil", "info", existing).Output(); err == nil {
+			info.Class = parseDiskutil(string(out))
+		}
+	}
+
+	largest := scanCache(dir, info.cached)
+	if speed, err := benchmarkRead(largest); err == nil && speed > 0 {
+		info.ReadMBps, info.Measured = speed, true
+	} else {
+		info.ReadMBps = typicalReadMBps[info.Class]
+	}
+	return info
+}
+
+// scanCache records the model/quant directories under dir and returns its largest file
+func scanCache(dir string, cached map[string]bool) string {
+	var largest string
+	var largestSize int64
+	models, _ := os.ReadDir(dir)
+	for _, model := range models {
+		if !model.IsDir() {
+			continue
+		}
+		quants, _ := os.ReadDir(filepath.Join(dir, model.Name()))
+		for _, quant := range quants {
+			if !quant.IsDir() {
+				continue
+			}
+			files, _ := os.ReadDir(filepath.Join(dir, model.Name(), quant.Name()))
+			for _, file := range files {
+				stat, err := file.Info()
+				if err != nil || !stat.Mode().IsRegular() {
+					continue
+				}
+				cached[strings.ToLower(model.Name()+"/"+quant.Name())] = true
+				if stat.Size() > largestSize {
+					largest, largestSize = filepath.Join(dir, model.Name(), quant.Name(), file.Name()), stat.Size()
+				}
+			}
+		}
+	}
+	if largestSize < benchmarkMinBytes {
+		return ""
+	}
+	return largest
+}
+
+// benchmarkRead times a sequential read of the start of path in MB/s
+func benchmarkRead(path string) (float64, error) {
+	if path == "" {
+		return 0, os.ErrNotExist
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	buf := make([]byte, 4<<20)
+	var read int64
+	start := time.Now()
+	for read < benchmarkBytes && time.Since(start) < benchmarkTime {
+		n, err := f.Read(buf)
+		read += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	elapsed := time.Since(start).Seconds()
+	if read < benchmarkMinBytes || elapsed <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return float64(read) / (1 << 20) / elapsed, nil
+}
+
+// classifyLinux finds the block device mounted at path in mountinfo and asks sysfs what
+// kind of drive backs it. Device-mapper and md devices are classified by their first
+// member; network and overlay filesystems are unknown.
+func classifyLinux(path, mountinfo, sys string) DiskClass {
+	device := mountDevice(path, mountinfo)
+	if device == "" {
+		return DiskUnknown
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(sys, "dev", "block", device))
+	if err != nil {
+		return DiskUnknown
+	}
+	return classifyBlock(resolved)
+}
+
+// mountDevice returns the major:minor of the filesystem mounted closest above path
+func mountDevice(path, mountinfo string) string {
+	f, err := os.Open(mountinfo)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	var device, best string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mount := unescapeMount(fields[4])
+		if !within(path, mount) || len(mount) < len(best) {
+			continue
+		}
+		device, best = fields[2], mount
+	}
+	return device
+}
+
+// unescapeMount decodes the octal escapes mountinfo uses for spaces and the like
+func unescapeMount(s string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace(s)
+}
+
+func within(path, mount string) bool {
+	return mount == "/" || path == mount || strings.HasPrefix(path, mount+"/")
+}
+
+// classifyBlock classifies a resolved /sys/devices/.../block/<dev>[/<partition>] directory
+func classifyBlock(dir string) DiskClass {
+	// a partition has no queue of its own; its disk is the parent directory
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		dir = filepath.Dir(dir)
+	}
+	if members, _ := os.ReadDir(filepath.Join(dir, "slaves")); len(members) > 0 {
+		if resolved, err := filepath.EvalSymlinks(filepath.Join(dir, "slaves", members[0].Name())); err == nil {
+			return classifyBlock(resolved)
+		}
+	}
+	name := filepath.Base(dir)
+	if strings.HasPrefix(name, "nvme") {
+		return DiskNVMe
+	}
+	rotational, err := os.ReadFile(filepath.Join(dir, "queue", "rotational"))
+	if err != nil {
+		return DiskUnknown
+	}
+	if strings.TrimSpace(string(rotational)) == "1" {
+		return DiskHDD
+	}
+	return DiskSSD
+}
+
+// parseDiskutil classifies the output of `diskutil info`
+func parseDiskutil(out string) DiskClass {
+	var solid, pcie bool
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Solid State":
+			solid = value == "Yes"
+		case "Protocol":
+			pcie = value == "PCI-Express" || value == "Apple Fabric" || value == "NVMe"
+		}
+	}
+	switch {
+	case solid && pcie:
+		return DiskNVMe
+	case solid:
+		return DiskSSD
+	case strings.Contains(out, "Solid State:"):
+		return DiskHDD
+	}
+	return DiskUnknown
+}
+
+// Cached reports whether a variant of modelID is already in the model cache
+func (d *DiskInfo) Cached(modelID, quant string) bool {
+	return d != nil && d.cached[strings.ToLower(modelID+"/"+quant)]
+}
+
+// fits reports whether a variant that is not cached yet has room on the disk
+func (d *DiskInfo) fits(modelID string, variant Variant) bool {
+	return d == nil || d.FreeGB <= 0 || d.Cached(modelID, variant.Quant) || variant.SizeGB <= d.FreeGB
+}
+
+// loadNote warns when reading a variant of sizeGB from this disk takes longer than slowLoad
+func (d *DiskInfo) loadNote(sizeGB float64) string {
+	if d == nil || d.ReadMBps <= 0 {
+		return ""
+	}
+	load := time.Duration(sizeGB * 1024 / d.ReadMBps * float64(time.Second))
+	if load <= slowLoad {
+		return ""
+	}
+	return fmt.Sprintf(", slow load: ~%s from %s at %.0fMB/s", load.Round(time.Second), d.Class, d.ReadMBps)
+}
+
+func (d *DiskInfo) String() string {
+	summary := fmt.Sprintf("Disk: %s", d.Class)
+	if d.FreeGB > 0 {
+		summary += fmt.Sprintf(", %.0fGB free", d.FreeGB)
+	}
+	if d.ReadMBps > 0 {
+		kind := "typical"
+		if d.Measured {
+			kind = "measured"
+		}
+		summary += fmt.Sprintf(", %.0fMB/s read (%s)", d.ReadMBps, kind)
+	}
+	return summary
+}
@@ -0,0 +1,14 @@
+//go:build linux || darwin || freebsd
+
+package profiler
+
+import "syscall"
+
+// freeBytes returns the space on path's volume available to unprivileged users
+func freeBytes(path string) (uint64, bool) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, false
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), true
+}
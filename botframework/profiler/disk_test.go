@@ -0,0 +1,115 @@
+package profiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyLinux(t *testing.T) {
+	root := t.TempDir()
+	devices := filepath.Join(root, "devices")
+	disk := func(path, rotational string) string {
+		dir := filepath.Join(devices, path)
+		os.MkdirAll(filepath.Join(dir, "queue"), 0o755)
+		os.WriteFile(filepath.Join(dir, "queue", "rotational"), []byte(rotational+"\n"), 0o644)
+		return dir
+	}
+	partition := func(disk, name string) string {
+		dir := filepath.Join(disk, name)
+		os.MkdirAll(dir, 0o755)
+		os.WriteFile(filepath.Join(dir, "partition"), []byte("1\n"), 0o644)
+		return dir
+	}
+	nvme := partition(disk("pci0/nvme/nvme0/nvme0n1", "0"), "nvme0n1p2")
+	hdd := partition(disk("pci0/ata1/sda", "1"), "sda1")
+	ssd := partition(disk("pci0/ata2/sdb", "0"), "sdb1")
+	lvm := disk("virtual/block/dm-0", "0")
+	os.MkdirAll(filepath.Join(lvm, "slaves"), 0o755)
+	os.Symlink(hdd, filepath.Join(lvm, "slaves", "sda1"))
+
+	block := filepath.Join(root, "dev", "block")
+	os.MkdirAll(block, 0o755)
+	for device, target := range map[string]string{"259:2": nvme, "8:1": hdd, "8:17": ssd, "253:0": lvm} {
+		os.Symlink(target, filepath.Join(block, device))
+	}
+	mountinfo := filepath.Join(root, "mountinfo")
+	os.WriteFile(mountinfo, []byte(strings.Join([]string{
+		"22 1 259:2 / / rw,relatime shared:1 - ext4 /dev/nvme0n1p2 rw",
+		"40 22 8:1 / /data rw,relatime shared:2 - xfs /dev/sda1 rw",
+		"41 22 8:17 / /fast\\040models rw,relatime shared:3 - ext4 /dev/sdb1 rw",
+		"42 40 253:0 / /data/archive rw,relatime shared:4 - ext4 /dev/mapper/vg-archive rw",
+		"43 22 0:45 / /mnt/share rw,relatime shared:5 - nfs server:/export rw",
+	}, "\n")), 0o644)
+
+	for path, want := range map[string]DiskClass{
+		"/home/me/.cache/botframework/models": DiskNVMe,
+		"/data/models":                        DiskHDD,
+		"/database":                           DiskNVMe,
+		"/fast models/llama":                  DiskSSD,
+		"/data/archive/models":                DiskHDD,
+		"/mnt/share/models":                   DiskUnknown,
+	} {
+		if got := classifyLinux(path, mountinfo, root); got != want {
+			t.Errorf("%s: %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestParseDiskutil(t *testing.T) {
+	internal := "   Device Node:              /dev/disk3s1\n   Protocol:                 Apple Fabric\n   Solid State:              Yes\n"
+	usb := "   Protocol:                 USB\n   Solid State:              No\n"
+	if got := parseDiskutil(internal); got != DiskNVMe {
+		t.Errorf("internal = %s", got)
+	}
+	if got := parseDiskutil(usb); got != DiskHDD {
+		t.Errorf("usb = %s", got)
+	}
+}
+
+func TestDetectDiskMeasuresCachedModel(t *testing.T) {
+	dir := t.TempDir()
+	variant := filepath.Join(dir, "phi-3-mini-4k", "Q4_K_M")
+	os.MkdirAll(variant, 0o755)
+	os.WriteFile(filepath.Join(variant, "phi-3-mini-4k-q4_k_m.gguf"), make([]byte, benchmarkMinBytes), 0o644)
+
+	disk := detectDisk(filepath.Join(dir))
+	if !disk.Cached("phi-3-mini-4k", "q4_k_m") || disk.Cached("phi-3-mini-4k", "Q8_0") {
+		t.Errorf("cached = %v", disk.cached)
+	}
+	if !disk.Measured || disk.ReadMBps <= 0 {
+		t.Errorf("read speed = %.0f MB/s, measured %v", disk.ReadMBps, disk.Measured)
+	}
+	if missing := detectDisk(filepath.Join(dir, "not", "created")); missing.FreeGB <= 0 {
+		t.Errorf("a missing cache dir should be profiled through its parent: %+v", missing)
+	}
+}
+
+func TestRecommendationsRespectDisk(t *testing.T) {
+	model := Model{ID: "llama-3-8b", ParamsB: 8, Benchmarks: Benchmarks{MMLU: 66}, Variants: []Variant{
+		{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98},
+		{Quant: "Q8_0", SizeGB: 8.5, AccuracyRetention: 0.99},
+		{Quant: "F16", SizeGB: 16, AccuracyRetention: 1},
+	}}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 48 * 1024, Disk: &DiskInfo{
+		Class: DiskHDD, FreeGB: 10, ReadMBps: 120, cached: map[string]bool{"llama-3-8b/f16": true},
+	}}
+	quants := map[string]string{}
+	for _, ranked := range profile.RecommendModels(&ModelRegistry{Models: []Model{model}}) {
+		quants[ranked.Variant.Quant] = ranked.Reason
+	}
+	if len(quants) != 3 {
+		t.Fatalf("want every variant (F16 is cached), got %v", quants)
+	}
+	if strings.Contains(quants["Q4_K_M"], "slow load") || !strings.Contains(quants["Q8_0"], "slow load: ~1m13s from hdd") {
+		t.Errorf("reasons = %v", quants)
+	}
+
+	profile.Disk.FreeGB = 6
+	for _, ranked := range profile.RecommendModels(&ModelRegistry{Models: []Model{model}}) {
+		if ranked.Variant.Quant == "Q8_0" {
+			t.Error("Q8_0 does not fit 6GB of free disk")
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package profiler
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// freeBytes returns the space on path's volume available to the current user
+func freeBytes(path string) (uint64, bool) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, false
+	}
+	var available uint64
+	if ok, _, _ := procGetDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&available)), 0, 0); ok == 0 {
+		return 0, false
+	}
+	return available, true
+}
@@ -0,0 +1,83 @@
+package profiler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// embeddingBufferGB is the compute buffer an embedding worker allocates for a batch of
+// inputs on top of its weights; embedding models keep no KV cache between requests
+const embeddingBufferGB = 0.25
+
+// EmbeddingFootprintGB is the memory a worker serving variant of an embedding model holds
+func EmbeddingFootprintGB(variant Variant) float64 {
+	return variant.SizeGB*1.1 + embeddingBufferGB
+}
+
+// RecommendEmbeddingModels ranks the registry's embedding models for this host. They are
+// scored on their own: an embedding model runs next to the chat model, so a small footprint
+// is worth more than a few points of retrieval quality.
+func (p *HardwareProfile) RecommendEmbeddingModels(registry *ModelRegistry) []ScoredVariant {
+	var recommendations []ScoredVariant
+	for _, model := range registry.EmbeddingModels {
+		if !p.ModelPolicy.Allows(model) {
+			continue
+		}
+		for _, variant := range model.Variants {
+			if !p.Disk.fits(model.ID, variant) {
+				continue
+			}
+			score, reason := p.CalculateEmbeddingScore(model, variant)
+			if score <= 0 {
+				continue
+			}
+			recommendations = append(recommendations, ScoredVariant{
+				ModelID:        model.ID,
+				ModelName:      model.Name,
+				Variant:        variant,
+				Score:          score,
+				Reason:         reason,
+				RelativeEnergy: 1,
+			})
+		}
+	}
+	sort.Slice(recommendations, func(i, j int) bool {
+		return recommendations[i].Score > recommendations[j].Score
+	})
+	return recommendations
+}
+
+// CalculateEmbeddingScore scores an embedding variant by its MTEB average and by the share
+// of model memory it would take from the chat model
+func (p *HardwareProfile) CalculateEmbeddingScore(model Model, variant Variant) (float64, string) {
+	availableMemGB := p.modelMemoryGB() - 2.0 // OS/display buffer, as for chat models
+	footprintGB := EmbeddingFootprintGB(variant)
+	if availableMemGB <= 0 || footprintGB > availableMemGB {
+		return 0, "Insufficient Memory"
+	}
+
+	baseScore := model.Benchmarks.MTEB * variant.AccuracyRetention
+	share := footprintGB / availableMemGB
+	memoryScore := 0.0
+	switch {
+	case share <= 0.05:
+		memoryScore = 10.0
+	case share <= 0.15:
+		memoryScore = 0.0
+	default:
+		// leaves the chat model noticeably less room
+		memoryScore = -20.0
+	}
+
+	finalScore := math.Min(100, math.Max(0, baseScore+memoryScore))
+	reason := fmt.Sprintf("MTEB: %.1f, MemBonus: %.1f (Footprint: %.2fGB, %.0f%% of model memory, %d dims)",
+		baseScore, memoryScore, footprintGB, share*100, model.Dimensions)
+	return finalScore, reason
+}
+
+// ReserveEmbedding takes the memory of an embedding worker serving variant off what chat
+// models are scored against
+func (p *HardwareProfile) ReserveEmbedding(variant Variant) {
+	p.ReservedMB += int(math.Ceil(EmbeddingFootprintGB(variant) * 1024))
+}
@@ -0,0 +1,71 @@
+package profiler
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddingScoringFavoursSmallFootprintOnTightHosts(t *testing.T) {
+	registry := &ModelRegistry{EmbeddingModels: []Model{
+		{ID: "large-embed", Dimensions: 1024, Benchmarks: Benchmarks{MTEB: 64.7},
+			Variants: []Variant{{Quant: "F16", SizeGB: 0.67, AccuracyRetention: 1}}},
+		{ID: "small-embed", Dimensions: 384, Benchmarks: Benchmarks{MTEB: 62.2},
+			Variants: []Variant{{Quant: "F16", SizeGB: 0.07, AccuracyRetention: 1}}},
+	}}
+
+	roomy := &HardwareProfile{HasCuda: true, VRAM_MB: 24576}
+	if ranked := roomy.RecommendEmbeddingModels(registry); len(ranked) != 2 || ranked[0].ModelID != "large-embed" {
+		t.Fatalf("with 24GB the better model should lead: %+v", ranked)
+	}
+
+	tight := &HardwareProfile{HasCuda: true, VRAM_MB: 6144}
+	ranked := tight.RecommendEmbeddingModels(registry)
+	if len(ranked) != 2 || ranked[0].ModelID != "small-embed" {
+		t.Fatalf("with 6GB the smaller model should lead: %+v", ranked)
+	}
+	if !strings.Contains(ranked[0].Reason, "384 dims") {
+		t.Errorf("reason = %q", ranked[0].Reason)
+	}
+}
+
+func TestReservedEmbeddingMemoryShrinksChatHeadroom(t *testing.T) {
+	model := Model{ParamsB: 8, ContextWindow: 8192, Benchmarks: Benchmarks{MMLU: 68},
+		Architecture: &Architecture{HiddenSize: 4096, Layers: 32, KVHeads: 8, HeadDim: 128}}
+	variant := Variant{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 10240}
+
+	alone, _ := profile.CalculateScoreAt(model, variant, 4096)
+	profile.ReserveEmbedding(Variant{Quant: "F16", SizeGB: 1.2})
+	if profile.ReservedMB != 1608 {
+		t.Fatalf("ReservedMB = %d, want 1608", profile.ReservedMB)
+	}
+	shared, _ := profile.CalculateScoreAt(model, variant, 4096)
+	if shared >= alone {
+		t.Errorf("a co-resident embedding worker should lower the chat score: %.1f vs %.1f", shared, alone)
+	}
+}
+
+func TestBundledRegistryHasEmbeddingModels(t *testing.T) {
+	data, err := os.ReadFile("model_classification.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := ParseRegistry(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.EmbeddingModels) == 0 {
+		t.Fatal("no embedding models in the bundled registry")
+	}
+	if model := registry.Lookup("nomic-embed-text-v1.5-q8_0"); model == nil || !model.IsEmbedding() {
+		t.Errorf("Lookup should find embedding models, got %+v", model)
+	}
+}
+
+func TestParseRegistryRejectsEmbeddingModelsWithoutDimensions(t *testing.T) {
+	_, err := ParseRegistry([]byte(`{"models": [], "embedding_models": [{"id": "e5"}]}`))
+	if err == nil {
+		t.Fatal("expected an error for an embedding model without dimensions")
+	}
+}
@@ -0,0 +1,130 @@
+package profiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Kinds of load failure
+const (
+	FailureOOM        = "oom"
+	FailureLoadFailed = "load_failed"
+)
+
+// failureTTL is how long a load failure counts against a variant; drivers and engines
+// are upgraded, so old failures are forgotten
+const failureTTL = 30 * 24 * time.Hour
+
+// LoadFailure records a worker that ran out of memory or failed to load its model
+type LoadFailure struct {
+	// Model is the served model name, matched to its registry variant like probed speeds
+	Model         string `json:"model"`
+	ContextTokens int    `json:"context_tokens"`
+	// Hardware is the Fingerprint of the host it failed on
+	Hardware string    `json:"hardware"`
+	Kind     string    `json:"kind"`
+	Count    int       `json:"count"`
+	LastAt   time.Time `json:"last_at"`
+}
+
+// RecordFailure stores a load failure, counting repeats of the same model, context,
+// hardware and kind
+func (m *Measurements) RecordFailure(model string, contextTokens int, hardware, kind string) {
+	now := time.Now().UTC()
+	for i := range m.Failures {
+		f := &m.Failures[i]
+		if f.Model == model && f.ContextTokens == contextTokens && f.Hardware == hardware && f.Kind == kind {
+			f.Count++
+			f.LastAt = now
+			return
+		}
+	}
+	m.Failures = append(m.Failures, LoadFailure{Model: model, ContextTokens: contextTokens, Hardware: hardware, Kind: kind, Count: 1, LastAt: now})
+}
+
+// ClearFailures forgets the failures of model on hardware that a successful load at
+// contextTokens disproves: load failures, and OOMs at that context or shorter. It reports
+// whether any were removed.
+func (m *Measurements) ClearFailures(model string, contextTokens int, hardware string) bool {
+	before := len(m.Failures)
+	m.Failures = slices.DeleteFunc(m.Failures, func(f LoadFailure) bool {
+		return f.Model == model && f.Hardware == hardware && (f.Kind != FailureOOM || f.ContextTokens <= contextTokens)
+	})
+	return len(m.Failures) < before
+}
+
+// ApplyFailures attaches recorded load failures to the registry variants they happened
+// on, so CalculateScore penalises or rules out the variants that failed on this host.
+// Failures older than 30 days are ignored.
+func (m *Measurements) ApplyFailures(registry *ModelRegistry) int {
+	applied := 0
+	for _, failure := range m.Failures {
+		if time.Since(failure.LastAt) > failureTTL {
+			continue
+		}
+		model := registry.Lookup(failure.Model)
+		if model == nil {
+			continue
+		}
+		if variant := model.variantNamed(failure.Model); variant != nil {
+			variant.Failures = append(variant.Failures, failure)
+			applied++
+		}
+	}
+	return applied
+}
+
+// Fingerprint identifies the hardware a load failure happened on: the GPU backend, the
+// devices' memory and the system RAM in GB. Profiles of the same machine match across runs.
+func (p *HardwareProfile) Fingerprint() string {
+	backend := "cpu"
+	switch {
+	case p.HasCuda:
+		backend = "cuda"
+	case p.HasROCm:
+		backend = "rocm"
+	case p.HasMetal:
+		backend = "metal"
+	case p.hasArc():
+		backend = "oneapi"
+	}
+	parts := []string{backend}
+	if backend != "cpu" {
+		parts = append(parts, fmt.Sprintf("%dx%dMB", max(len(p.GPUs), 1), p.VRAM_MB))
+	}
+	parts = append(parts, fmt.Sprintf("ram%dGB", (p.SystemRAM_MB+512)/1024))
+	return strings.Join(parts, "/")
+}
+
+// Penalties for load failures that do not rule a variant out
+const (
+	largerContextOOMPenalty = 15.0
+	loadFailurePenalty      = 20.0
+)
+
+// failureScore is the observed-failure term of CalculateScore on the host with the given
+// fingerprint. A variant that ran out of memory at the scored context or a shorter one, or
+// failed to load twice, is ruled out (excluded). One that ran out of memory only at a longer
+// context, or failed to load once, is penalised.
+func (v Variant) failureScore(hardware string, contextTokens int) (score float64, note string, excluded bool) {
+	for _, f := range v.Failures {
+		if f.Hardware != hardware {
+			continue
+		}
+		switch {
+		case f.Kind == FailureOOM && f.ContextTokens <= contextTokens:
+			return 0, fmt.Sprintf("Ran out of memory on this host at %dk", f.ContextTokens/1024), true
+		case f.Kind == FailureOOM:
+			score -= largerContextOOMPenalty
+			note = fmt.Sprintf(", Failures: -%.0f (ran out of memory at %dk)", largerContextOOMPenalty, f.ContextTokens/1024)
+		case f.Count >= 2:
+			return 0, fmt.Sprintf("Failed to load on this host %d times", f.Count), true
+		default:
+			score -= loadFailurePenalty
+			note = fmt.Sprintf(", Failures: -%.0f (failed to load once)", loadFailurePenalty)
+		}
+	}
+	return max(score, -largerContextOOMPenalty-loadFailurePenalty), note, false
+}
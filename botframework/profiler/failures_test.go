@@ -0,0 +1,69 @@
+package profiler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFailuresRuleOutVariants(t *testing.T) {
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 24576, SystemRAM_MB: 65536}
+	hardware := profile.Fingerprint()
+	path := filepath.Join(t.TempDir(), "measurements.json")
+	m, _ := LoadMeasurements(path)
+	m.RecordFailure("llama-3-8b:Q8_0", 8192, hardware, FailureOOM)
+	m.RecordFailure("llama-3-8b:Q4_K_M", 32768, hardware, FailureOOM)
+	m.RecordFailure("llama-3-8b:F16", 4096, "metal/1x16384MB/ram16GB", FailureOOM)
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadMeasurements(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &ModelRegistry{Models: []Model{{ID: "llama-3-8b", ParamsB: 8, ContextWindow: 65536, Benchmarks: Benchmarks{MMLU: 66},
+		Variants: []Variant{{Quant: "F16", SizeGB: 16, AccuracyRetention: 1}, {Quant: "Q8_0", SizeGB: 8.5, AccuracyRetention: 0.99},
+			{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.97}}}}}
+	if applied := loaded.ApplyFailures(registry); applied != 3 {
+		t.Fatalf("applied %d failures, want 3", applied)
+	}
+	scores := map[string]string{}
+	for _, ranked := range profile.Recommend(registry, RecommendationRequest{ContextLength: 8192}) {
+		scores[ranked.Variant.Quant] = ranked.Reason
+	}
+	if _, ok := scores["Q8_0"]; ok {
+		t.Error("Q8_0 ran out of memory at 8k and was still recommended")
+	}
+	if !strings.Contains(scores["Q4_K_M"], "ran out of memory at 32k") {
+		t.Errorf("Q4_K_M reason = %q, want the 32k failure noted", scores["Q4_K_M"])
+	}
+	if strings.Contains(scores["F16"], "Failures") {
+		t.Errorf("a failure on other hardware counted: %q", scores["F16"])
+	}
+
+	// a load at 8k disproves the OOM at 8k but not the one at 32k
+	if !loaded.ClearFailures("llama-3-8b:Q8_0", 8192, hardware) || loaded.ClearFailures("llama-3-8b:Q4_K_M", 8192, hardware) {
+		t.Errorf("failures left: %+v", loaded.Failures)
+	}
+}
+
+func TestRepeatedLoadFailuresExclude(t *testing.T) {
+	model := Model{ID: "phi-3", Benchmarks: Benchmarks{MMLU: 60}}
+	variant := Variant{Quant: "Q4_K_M", SizeGB: 2, AccuracyRetention: 1}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 16384}
+	m := &Measurements{}
+	m.RecordFailure("phi-3-q4_k_m", 4096, profile.Fingerprint(), FailureLoadFailed)
+
+	clean, _ := profile.CalculateScore(model, variant)
+	variant.Failures = m.Failures
+	once, _ := profile.CalculateScore(model, variant)
+	if once != clean-loadFailurePenalty {
+		t.Errorf("one load failure scored %.1f, want %.1f", once, clean-loadFailurePenalty)
+	}
+	m.RecordFailure("phi-3-q4_k_m", 4096, profile.Fingerprint(), FailureLoadFailed)
+	variant.Failures = m.Failures
+	if score, reason := profile.CalculateScore(model, variant); score != 0 || !strings.Contains(reason, "2 times") {
+		t.Errorf("two load failures scored %.1f: %q", score, reason)
+	}
+}
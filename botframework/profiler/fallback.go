@@ -0,0 +1,43 @@
+package profiler
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// EngineFallbacks returns the engines tried, in order, when primary fails to start on this
+// host, such as vLLM on a driver it was not built for. Each tier falls back to engines that
+// run on its hardware and ends at llama.cpp, which also runs on the CPU: CUDA hosts go
+// vLLM → ExLlamaV2 → llama.cpp, ROCm hosts skip ExLlamaV2, Intel Arc goes IPEX-LLM → the
+// SYCL build → llama.cpp and Apple Silicon MLX → llama.cpp.
+func (p *HardwareProfile) EngineFallbacks(primary Engine) []Engine {
+	var chain []Engine
+	switch primary {
+	case EngineVLLM:
+		if p.HasCuda {
+			chain = append(chain, EngineExLlamaV2)
+		}
+	case EngineIPEXLLM:
+		chain = append(chain, EngineLlamaCPPSYCL)
+	case EngineLlamaCPP:
+		return nil
+	}
+	return append(chain, EngineLlamaCPP)
+}
+
+// ParseEngines reads a comma-separated list of engine names, e.g. "exllamav2,llama_cpp"
+func ParseEngines(spec string) ([]Engine, error) {
+	var engines []Engine
+	for _, name := range strings.Split(spec, ",") {
+		engine := Engine(strings.TrimSpace(name))
+		if engine == "" {
+			continue
+		}
+		if !slices.Contains(Engines, engine) {
+			return nil, fmt.Errorf("unknown engine %q", engine)
+		}
+		engines = append(engines, engine)
+	}
+	return engines, nil
+}
@@ -0,0 +1,36 @@
+package profiler
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEngineFallbacks(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		profile HardwareProfile
+		primary Engine
+		want    []Engine
+	}{
+		{"cuda", HardwareProfile{HasCuda: true}, EngineVLLM, []Engine{EngineExLlamaV2, EngineLlamaCPP}},
+		{"rocm", HardwareProfile{HasROCm: true}, EngineVLLM, []Engine{EngineLlamaCPP}},
+		{"exllamav2", HardwareProfile{HasCuda: true}, EngineExLlamaV2, []Engine{EngineLlamaCPP}},
+		{"arc", HardwareProfile{HasOneAPI: true}, EngineIPEXLLM, []Engine{EngineLlamaCPPSYCL, EngineLlamaCPP}},
+		{"apple", HardwareProfile{HasMetal: true}, EngineMLX, []Engine{EngineLlamaCPP}},
+		{"cpu", HardwareProfile{}, EngineLlamaCPP, nil},
+	} {
+		if got := tc.profile.EngineFallbacks(tc.primary); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: fallbacks of %s = %v, want %v", tc.name, tc.primary, got, tc.want)
+		}
+	}
+}
+
+func TestParseEngines(t *testing.T) {
+	engines, err := ParseEngines(" exllamav2, llama_cpp ,")
+	if err != nil || !slices.Equal(engines, []Engine{EngineExLlamaV2, EngineLlamaCPP}) {
+		t.Errorf("ParseEngines = %v, %v", engines, err)
+	}
+	if _, err := ParseEngines("exllama"); err == nil {
+		t.Error("unknown engine accepted")
+	}
+}
@@ -0,0 +1,71 @@
+package profiler
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// GPUAssignments pins workers to GPUs, by the name their model is served under
+type GPUAssignments map[string][]int
+
+// DefaultWorkerGPUs is the assignment key of the default worker
+const DefaultWorkerGPUs = "default"
+
+// ParseGPUAssignments reads "default=0;fast=1;quality=2,3": each entry names a model and
+// the indexes of the GPUs its worker may use
+func ParseGPUAssignments(spec string) (GPUAssignments, error) {
+	assignments := GPUAssignments{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, list, ok := strings.Cut(entry, "=")
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("GPU assignment %q: want model=index,...", entry)
+		}
+		if _, dup := assignments[model]; dup {
+			return nil, fmt.Errorf("GPU assignment for %s given twice", model)
+		}
+		var devices []int
+		for _, field := range strings.Split(list, ",") {
+			index, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("GPU assignment for %s: %q is not a GPU index", model, field)
+			}
+			if slices.Contains(devices, index) {
+				return nil, fmt.Errorf("GPU assignment for %s lists GPU %d twice", model, index)
+			}
+			devices = append(devices, index)
+		}
+		assignments[model] = devices
+	}
+	return assignments, nil
+}
+
+// ValidateGPUAssignments checks that every assigned GPU was detected. Only NVIDIA and AMD
+// GPUs can be pinned, through CUDA_VISIBLE_DEVICES and HIP_VISIBLE_DEVICES.
+func (p *HardwareProfile) ValidateGPUAssignments(assignments GPUAssignments) error {
+	if len(assignments) == 0 {
+		return nil
+	}
+	if !p.HasCuda && !p.HasROCm {
+		return fmt.Errorf("GPUs can only be assigned on NVIDIA or AMD hosts")
+	}
+	models := make([]string, 0, len(assignments))
+	for model := range assignments {
+		models = append(models, model)
+	}
+	slices.Sort(models)
+	for _, model := range models {
+		for _, index := range assignments[model] {
+			if !slices.ContainsFunc(p.GPUs, func(gpu GPUInfo) bool { return gpu.Index == index }) {
+				return fmt.Errorf("GPU %d assigned to %s was not detected (%d GPUs found)", index, model, len(p.GPUs))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package profiler
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseGPUAssignments(t *testing.T) {
+	assignments, err := ParseGPUAssignments("default=0; fast=1 ;quality=2,3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(assignments[DefaultWorkerGPUs], []int{0}) || !slices.Equal(assignments["quality"], []int{2, 3}) || len(assignments) != 3 {
+		t.Fatalf("unexpected assignments %v", assignments)
+	}
+	for _, spec := range []string{"fast", "=0", "fast=", "fast=a", "fast=-1", "fast=0,0", "fast=0;fast=1"} {
+		if _, err := ParseGPUAssignments(spec); err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+}
+
+func TestValidateGPUAssignments(t *testing.T) {
+	profile := &HardwareProfile{HasCuda: true, GPUs: []GPUInfo{{Index: 0, VRAM_MB: 24576}, {Index: 1, VRAM_MB: 24576}}}
+	if err := profile.ValidateGPUAssignments(GPUAssignments{"a": {0}, "b": {1}}); err != nil {
+		t.Fatalf("valid assignment rejected: %v", err)
+	}
+	if err := profile.ValidateGPUAssignments(GPUAssignments{"a": {2}}); err == nil {
+		t.Error("an undetected GPU should be rejected")
+	}
+	metal := &HardwareProfile{HasMetal: true}
+	if err := metal.ValidateGPUAssignments(GPUAssignments{"a": {0}}); err == nil {
+		t.Error("GPUs cannot be pinned without CUDA or ROCm")
+	}
+}
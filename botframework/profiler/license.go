@@ -0,0 +1,65 @@
+package profiler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Source is a mirror a variant's file can be downloaded from instead of Hugging Face
+type Source struct {
+	URL string `json:"url"`
+	// SHA256 is required: mirrors are trusted only as far as their checksum
+	SHA256 string `json:"sha256"`
+}
+
+// CommercialUse reports whether the model's license allows commercial use: not when the
+// registry says otherwise, nor under a Creative Commons NonCommercial license
+func (m Model) CommercialUse() bool {
+	return !m.NonCommercial && !strings.HasPrefix(strings.ToLower(m.License), "cc-by-nc")
+}
+
+// ModelPolicy narrows recommendations to the models an operator may use
+type ModelPolicy struct {
+	// Ungated leaves out models whose download needs an accepted license and a token
+	Ungated bool `json:"ungated,omitempty"`
+	// Commercial leaves out models whose license forbids commercial use
+	Commercial bool `json:"commercial,omitempty"`
+}
+
+// ParseModelPolicy reads a comma-separated policy: "ungated", "commercial" or both
+func ParseModelPolicy(spec string) (ModelPolicy, error) {
+	var policy ModelPolicy
+	for _, rule := range strings.Split(spec, ",") {
+		switch rule = strings.TrimSpace(rule); rule {
+		case "":
+		case "ungated":
+			policy.Ungated = true
+		case "commercial":
+			policy.Commercial = true
+		default:
+			return ModelPolicy{}, fmt.Errorf("unknown model policy %q (want ungated or commercial)", rule)
+		}
+	}
+	return policy, nil
+}
+
+// Allows reports whether the policy lets model be recommended
+func (p ModelPolicy) Allows(model Model) bool {
+	return (!p.Ungated || !model.Gated) && (!p.Commercial || model.CommercialUse())
+}
+
+// validateSources checks that every mirror is an http(s) URL with a SHA-256 checksum
+func validateSources(model Model) error {
+	for _, variant := range model.Variants {
+		for _, source := range variant.Sources {
+			if u, err := url.Parse(source.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("registry model %s %s: source %q is not an http(s) URL", model.ID, variant.Quant, source.URL)
+			}
+			if len(source.SHA256) != 64 || strings.Trim(strings.ToLower(source.SHA256), "0123456789abcdef") != "" {
+				return fmt.Errorf("registry model %s %s: source %s needs a sha256 checksum", model.ID, variant.Quant, source.URL)
+			}
+		}
+	}
+	return nil
+}
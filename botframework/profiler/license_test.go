@@ -0,0 +1,56 @@
+package profiler
+
+import "testing"
+
+func TestModelPolicyFiltersRecommendations(t *testing.T) {
+	variants := []Variant{{Quant: "Q4_K_M", SizeGB: 4, AccuracyRetention: 0.98}}
+	registry := &ModelRegistry{Models: []Model{
+		{ID: "open", ParamsB: 7, License: "apache-2.0", Benchmarks: Benchmarks{MMLU: 60}, Variants: variants},
+		{ID: "gated", ParamsB: 7, License: "llama3", Gated: true, Benchmarks: Benchmarks{MMLU: 60}, Variants: variants},
+		{ID: "research", ParamsB: 7, License: "CC-BY-NC-4.0", Benchmarks: Benchmarks{MMLU: 60}, Variants: variants},
+	}}
+	profile := &HardwareProfile{VRAM_MB: 24576, SystemRAM_MB: 65536, HasCuda: true}
+	recommended := func() map[string]bool {
+		ids := make(map[string]bool)
+		for _, ranked := range profile.RecommendModels(registry) {
+			ids[ranked.ModelID] = true
+		}
+		return ids
+	}
+	if ids := recommended(); len(ids) != 3 {
+		t.Fatalf("without a policy every model should be ranked, got %v", ids)
+	}
+
+	var err error
+	if profile.ModelPolicy, err = ParseModelPolicy("ungated, commercial"); err != nil {
+		t.Fatal(err)
+	}
+	if ids := recommended(); len(ids) != 1 || !ids["open"] {
+		t.Errorf("policy ungated,commercial ranked %v, want only open", ids)
+	}
+	if _, err := ParseModelPolicy("free"); err == nil {
+		t.Error("an unknown policy should be rejected")
+	}
+}
+
+func TestParseRegistryChecksSources(t *testing.T) {
+	const checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	valid := `{"models":[{"id":"m","variants":[{"quant":"Q4_K_M","sources":[{"url":"https://mirror.example/m.gguf","sha256":"` + checksum + `"}]}]}]}`
+	registry, err := ParseRegistry([]byte(valid))
+	if err != nil {
+		t.Fatalf("a mirror with a checksum should load: %v", err)
+	}
+	if sources := registry.Models[0].Variants[0].Sources; len(sources) != 1 || sources[0].SHA256 != checksum {
+		t.Errorf("unexpected sources %+v", sources)
+	}
+	for _, source := range []string{
+		`{"url":"https://mirror.example/m.gguf"}`,
+		`{"url":"ftp://mirror.example/m.gguf","sha256":"` + checksum + `"}`,
+		`{"url":"https://mirror.example/m.gguf","sha256":"not-hex"}`,
+	} {
+		data := `{"models":[{"id":"m","variants":[{"quant":"Q4_K_M","sources":[` + source + `]}]}]}`
+		if _, err := ParseRegistry([]byte(data)); err == nil {
+			t.Errorf("source %s should be rejected", source)
+		}
+	}
+}
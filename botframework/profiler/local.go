@@ -0,0 +1,86 @@
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// LocalVariants are variants made on this host, such as weights `manager convert` produced,
+// keyed by the registry model they were made from. They join the registry as if published.
+type LocalVariants struct {
+	Models map[string][]Variant `json:"models"`
+}
+
+// DefaultLocalVariantsPath returns ~/.config/botframework/variants.json
+func DefaultLocalVariantsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "variants.json"
+	}
+	return filepath.Join(dir, "botframework", "variants.json")
+}
+
+// LoadLocalVariants reads the variants made on this host. A missing file yields none.
+func LoadLocalVariants(path string) (*LocalVariants, error) {
+	l := &LocalVariants{Models: make(map[string][]Variant)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+	if l.Models == nil {
+		l.Models = make(map[string][]Variant)
+	}
+	return l, nil
+}
+
+// Save writes the variants to path, creating its directory
+func (l *LocalVariants) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Add records variant for the registry model modelID, replacing one of the same quant
+func (l *LocalVariants) Add(modelID string, variant Variant) {
+	variants := slices.DeleteFunc(l.Models[modelID], func(v Variant) bool { return v.Quant == variant.Quant })
+	l.Models[modelID] = append(variants, variant)
+}
+
+// Apply adds the local variants to their registry models, skipping quants a model
+// already publishes. It returns how many were added.
+func (l *LocalVariants) Apply(registry *ModelRegistry) int {
+	added := 0
+	for id, variants := range l.Models {
+		model := registry.Lookup(id)
+		if model == nil {
+			continue
+		}
+		for _, variant := range variants {
+			published := slices.ContainsFunc(model.Variants, func(v Variant) bool { return v.Quant == variant.Quant })
+			if !published {
+				model.Variants = append(model.Variants, variant)
+				added++
+			}
+		}
+	}
+	return added
+}
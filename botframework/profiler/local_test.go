@@ -0,0 +1,47 @@
+package profiler
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalVariants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "variants.json")
+	local, err := LoadLocalVariants(path)
+	if err != nil || len(local.Models) != 0 {
+		t.Fatalf("missing file: %+v, %v", local, err)
+	}
+	local.Add("llama-3-8b", Variant{Quant: "MLX-Q4", SizeGB: 4.0, AccuracyRetention: 0.9, Format: FormatMLX})
+	local.Add("llama-3-8b", Variant{Quant: "MLX-Q4", SizeGB: 4.2, AccuracyRetention: 0.97, Format: FormatMLX})
+	local.Add("llama-3-8b", Variant{Quant: "Q4_K_M", SizeGB: 4.9})
+	local.Add("unknown", Variant{Quant: "AWQ", SizeGB: 5, Format: FormatSafetensors})
+	if err := local.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if local, err = LoadLocalVariants(path); err != nil || len(local.Models["llama-3-8b"]) != 2 {
+		t.Fatalf("reloaded %+v, %v", local, err)
+	}
+
+	registry := &ModelRegistry{Models: []Model{{ID: "llama-3-8b", ContextWindow: 8192, Benchmarks: Benchmarks{MMLU: 66},
+		Variants: []Variant{{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98}}}}}
+	if added := local.Apply(registry); added != 1 || len(registry.Models[0].Variants) != 2 {
+		t.Fatalf("Apply() = %d: %+v", added, registry.Models[0].Variants)
+	}
+	if added := local.Apply(registry); added != 0 {
+		t.Errorf("applying twice added %d", added)
+	}
+
+	quants := func(profile *HardwareProfile) []string {
+		var quants []string
+		for _, ranked := range profile.Recommend(registry, RecommendationRequest{}) {
+			quants = append(quants, ranked.Variant.Quant)
+		}
+		return quants
+	}
+	if got := quants(&HardwareProfile{HasMetal: true, VRAM_MB: 24 * 1024}); len(got) != 2 {
+		t.Errorf("MLX runs both variants on Apple silicon, got %v", got)
+	}
+	if got := quants(&HardwareProfile{SystemRAM_MB: 32 * 1024}); len(got) != 1 || got[0] != "Q4_K_M" {
+		t.Errorf("llama.cpp cannot load the MLX variant, got %v", got)
+	}
+}
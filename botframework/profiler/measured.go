@@ -0,0 +1,198 @@
+package profiler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Measurement is a locally measured benchmark score for one served model
+type Measurement struct {
+	Score      float64   `json:"score"`
+	Samples    int       `json:"samples"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// SpeedMeasurement is the throughput the startup probe measured for one served model
+type SpeedMeasurement struct {
+	PrefillTPS float64   `json:"prefill_tps"`
+	DecodeTPS  float64   `json:"decode_tps"`
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// Measurements holds scores recorded by `manager eval`, keyed by served model name and
+// benchmark ("mmlu", "gsm8k" or a custom suite name), probed throughput keyed by served
+// model name, and the workers that ran out of memory or failed to load
+type Measurements struct {
+	Models   map[string]map[string]Measurement `json:"models"`
+	Speed    map[string]SpeedMeasurement       `json:"speed,omitempty"`
+	Failures []LoadFailure                     `json:"failures,omitempty"`
+}
+
+// DefaultMeasurementsPath returns ~/.config/botframework/measurements.json
+func DefaultMeasurementsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "measurements.json"
+	}
+	return filepath.Join(dir, "botframework", "measurements.json")
+}
+
+// LoadMeasurements reads recorded scores. A missing file yields an empty set.
+func LoadMeasurements(path string) (*Measurements, error) {
+	m := &Measurements{Models: make(map[string]map[string]Measurement), Speed: make(map[string]SpeedMeasurement)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.Models == nil {
+		m.Models = make(map[string]map[string]Measurement)
+	}
+	if m.Speed == nil {
+		m.Speed = make(map[string]SpeedMeasurement)
+	}
+	return m, nil
+}
+
+// Save writes the measurements to path, creating its directory
+func (m *Measurements) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Record stores a score for model, replacing the previous run of the same benchmark
+func (m *Measurements) Record(model, benchmark string, score float64, samples int) {
+	benchmark = strings.ToLower(benchmark)
+	if m.Models[model] == nil {
+		m.Models[model] = make(map[string]Measurement)
+	}
+	m.Models[model][benchmark] = Measurement{Score: score, Samples: samples, MeasuredAt: time.Now().UTC()}
+}
+
+// RecordSpeed stores the throughput probed for model, replacing the previous probe
+func (m *Measurements) RecordSpeed(model string, prefillTPS, decodeTPS float64) {
+	if m.Speed == nil {
+		m.Speed = make(map[string]SpeedMeasurement)
+	}
+	m.Speed[model] = SpeedMeasurement{PrefillTPS: prefillTPS, DecodeTPS: decodeTPS, MeasuredAt: time.Now().UTC()}
+}
+
+// Apply overrides the published MMLU and GSM8K numbers of registry models with scores
+// measured on this host, so recommendations rank models by how they actually perform here.
+// Runs with fewer than minSamples questions are ignored as too noisy. When several served
+// names map to the same registry model, the most recent measurement wins.
+func (m *Measurements) Apply(registry *ModelRegistry, minSamples int) int {
+	type key struct {
+		model     *Model
+		benchmark string
+	}
+	latest := make(map[key]Measurement)
+	for name, benchmarks := range m.Models {
+		model := registry.Lookup(name)
+		if model == nil {
+			continue
+		}
+		for _, benchmark := range []string{"mmlu", "gsm8k"} {
+			b, ok := benchmarks[benchmark]
+			if !ok || b.Samples < minSamples {
+				continue
+			}
+			k := key{model, benchmark}
+			if prev, seen := latest[k]; !seen || b.MeasuredAt.After(prev.MeasuredAt) {
+				latest[k] = b
+			}
+		}
+	}
+
+	for k, b := range latest {
+		if k.benchmark == "mmlu" {
+			k.model.Benchmarks.MMLU = b.Score
+		} else {
+			k.model.Benchmarks.GSM8K = b.Score
+		}
+	}
+	return len(latest)
+}
+
+// ApplySpeed attaches probed throughput to the registry variants it was measured on, so
+// CalculateScore can weigh how fast a variant actually runs here. A served name picks its
+// variant by the quant it contains ("llama-3-8b-instruct-q4_k_m" is llama-3-8b Q4_K_M);
+// names without one are skipped. The most recent probe of a variant wins.
+func (m *Measurements) ApplySpeed(registry *ModelRegistry) int {
+	applied := 0
+	for name, speed := range m.Speed {
+		model := registry.Lookup(name)
+		if model == nil || speed.DecodeTPS <= 0 {
+			continue
+		}
+		variant := model.variantNamed(name)
+		if variant == nil {
+			continue
+		}
+		if variant.Measured == nil {
+			applied++
+		} else if !speed.MeasuredAt.After(variant.Measured.MeasuredAt) {
+			continue
+		}
+		measured := speed
+		variant.Measured = &measured
+	}
+	return applied
+}
+
+// variantNamed returns the variant whose quant appears in a served model name, preferring
+// the longest match so "q4_k_m" is not taken for "q4_k"
+func (m *Model) variantNamed(name string) *Variant {
+	name = strings.ToLower(name)
+	var found *Variant
+	for i := range m.Variants {
+		quant := strings.ToLower(m.Variants[i].Quant)
+		if quant != "" && strings.Contains(name, quant) && (found == nil || len(quant) > len(found.Quant)) {
+			found = &m.Variants[i]
+		}
+	}
+	return found
+}
+
+// Speed term bounds: decoding at referenceDecodeTPS scores 0, each doubling or halving
+// moves the score by 10 points within [-20, +10]; a context that takes longer than
+// slowPrefill to read costs another 10
+const (
+	referenceDecodeTPS = 20.0
+	slowPrefill        = 30 * time.Second
+)
+
+// score is the empirical speed term of CalculateScore and its note for the reason; an
+// unprobed variant scores 0
+func (s *SpeedMeasurement) score(contextTokens int) (float64, string) {
+	if s == nil || s.DecodeTPS <= 0 {
+		return 0, ""
+	}
+	score := math.Max(-20, math.Min(10, 10*math.Log2(s.DecodeTPS/referenceDecodeTPS)))
+	if s.PrefillTPS > 0 && float64(contextTokens)/s.PrefillTPS > slowPrefill.Seconds() {
+		score -= 10
+	}
+	return score, fmt.Sprintf(", Speed: %+.1f (%.0f tok/s decode, %.0f tok/s prefill measured)", score, s.DecodeTPS, s.PrefillTPS)
+}
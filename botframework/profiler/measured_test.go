@@ -0,0 +1,73 @@
+package profiler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeasurementsRoundTripAndApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "measurements.json")
+	m, err := LoadMeasurements(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Record("llama-3-8b-instruct-q4_k_m", "MMLU", 61.5, 100)
+	m.Record("llama-3-8b-instruct-q4_k_m", "gsm8k", 70, 5)
+	m.Record("unknown-model", "mmlu", 10, 100)
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadMeasurements(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := &ModelRegistry{Models: []Model{{ID: "llama-3-8b", Benchmarks: Benchmarks{MMLU: 66, GSM8K: 79}}}}
+	if applied := loaded.Apply(registry, 10); applied != 1 {
+		t.Errorf("want 1 score applied, got %d", applied)
+	}
+	if b := registry.Models[0].Benchmarks; b.MMLU != 61.5 || b.GSM8K != 79 {
+		t.Errorf("want measured MMLU and published GSM8K (too few samples), got %+v", b)
+	}
+}
+
+func TestMeasurementsApplyPrefersLatest(t *testing.T) {
+	now := time.Now()
+	m := &Measurements{Models: map[string]map[string]Measurement{
+		"llama-3-8b-q8_0":   {"mmlu": {Score: 64, Samples: 50, MeasuredAt: now}},
+		"llama-3-8b-q4_k_m": {"mmlu": {Score: 60, Samples: 50, MeasuredAt: now.Add(-time.Hour)}},
+	}}
+	registry := &ModelRegistry{Models: []Model{{ID: "llama-3-8b"}}}
+	m.Apply(registry, 10)
+	if got := registry.Models[0].Benchmarks.MMLU; got != 64 {
+		t.Errorf("want latest measurement 64, got %v", got)
+	}
+}
+
+func TestApplySpeedFeedsScore(t *testing.T) {
+	m := &Measurements{Speed: map[string]SpeedMeasurement{}}
+	m.RecordSpeed("llama-3-8b-instruct-q4_k_m", 400, 5)
+	m.RecordSpeed("llama-3-8b-instruct", 400, 80) // no quant, no variant to attach to
+	model := Model{ID: "llama-3-8b", ParamsB: 8, Benchmarks: Benchmarks{MMLU: 66},
+		Variants: []Variant{{Quant: "Q4_K", SizeGB: 4.5, AccuracyRetention: 0.97}, {Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98}}}
+	registry := &ModelRegistry{Models: []Model{model}}
+	if applied := m.ApplySpeed(registry); applied != 1 {
+		t.Fatalf("want 1 speed applied, got %d", applied)
+	}
+	if registry.Models[0].Variants[0].Measured != nil || registry.Models[0].Variants[1].Measured == nil {
+		t.Fatalf("speed attached to the wrong variant: %+v", registry.Models[0].Variants)
+	}
+
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024}
+	probed := registry.Models[0].Variants[1]
+	unprobed := probed
+	unprobed.Measured = nil
+	published, _ := profile.CalculateScore(model, unprobed)
+	measured, reason := profile.CalculateScore(model, probed)
+	// 5 tok/s is two halvings below 20 tok/s: -20
+	if published-measured != 20 || !strings.Contains(reason, "5 tok/s decode") {
+		t.Errorf("published %.1f, measured %.1f (%s)", published, measured, reason)
+	}
+}
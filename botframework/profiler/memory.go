@@ -0,0 +1,58 @@
+package profiler
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// fallbackRAM_MB is assumed when the platform reports nothing
+const fallbackRAM_MB = 8192
+
+// detectSystemRAM returns the physical memory and the part of it available to new processes
+// in MB. available is 0 when the platform does not report it.
+func detectSystemRAM() (total, available int) {
+	switch runtime.GOOS {
+	case "linux":
+		total, available, _ = readMeminfo("/proc/meminfo")
+		// inside a container the cgroup limit is what a worker can actually use
+		if limit, ok := readCgroupLimitMB("/sys/fs/cgroup"); ok && (total == 0 || limit < total) {
+			total, available = limit, min(available, limit)
+		}
+	case "darwin":
+		if out, err := exec.Command("sysctl", "-n", "hw.memsize").Output(); err == nil {
+			bytes, _ := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+			total = int(bytes >> 20)
+		}
+		if out, err := exec.Command("vm_stat").Output(); err == nil {
+			available, _ = parseVMStat(out)
+		}
+	case "windows":
+		total, available = globalMemoryStatus()
+	}
+	if total == 0 {
+		return fallbackRAM_MB, 0
+	}
+	return total, min(available, total)
+}
+
+// readCgroupLimitMB reads the memory limit of the process's cgroup, v2 (memory.max) or
+// v1 (memory/memory.limit_in_bytes); unlimited cgroups report false
+func readCgroupLimitMB(root string) (int, bool) {
+	for _, path := range []string{root + "/memory.max", root + "/memory/memory.limit_in_bytes"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		value := strings.TrimSpace(string(data))
+		bytes, err := strconv.ParseInt(value, 10, 64)
+		// v1 reports "no limit" as a huge page-aligned number
+		if err != nil || bytes <= 0 || bytes >= 1<<60 {
+			return 0, false
+		}
+		return int(bytes >> 20), true
+	}
+	return 0, false
+}
@@ -0,0 +1,5 @@
+//go:build !windows
+
+package profiler
+
+func globalMemoryStatus() (int, int) { return 0, 0 }
@@ -0,0 +1,41 @@
+package profiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCgroupLimit(t *testing.T) {
+	v2 := t.TempDir()
+	os.WriteFile(filepath.Join(v2, "memory.max"), []byte("4294967296\n"), 0o644)
+	if limit, ok := readCgroupLimitMB(v2); !ok || limit != 4096 {
+		t.Errorf("v2 limit = %d, %v", limit, ok)
+	}
+
+	os.WriteFile(filepath.Join(v2, "memory.max"), []byte("max\n"), 0o644)
+	if _, ok := readCgroupLimitMB(v2); ok {
+		t.Error("an unlimited v2 cgroup has no limit")
+	}
+
+	v1 := t.TempDir()
+	os.Mkdir(filepath.Join(v1, "memory"), 0o755)
+	os.WriteFile(filepath.Join(v1, "memory", "memory.limit_in_bytes"), []byte("9223372036854771712\n"), 0o644)
+	if _, ok := readCgroupLimitMB(v1); ok {
+		t.Error("an unlimited v1 cgroup has no limit")
+	}
+}
+
+func TestCPUScoringUsesAvailableRAM(t *testing.T) {
+	model := Model{ParamsB: 8, Benchmarks: Benchmarks{MMLU: 68}}
+	variant := Variant{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98}
+
+	idle := &HardwareProfile{SystemRAM_MB: 16384, SystemRAMAvailable_MB: 14336}
+	busy := &HardwareProfile{SystemRAM_MB: 16384, SystemRAMAvailable_MB: 2048}
+	if score, _ := idle.CalculateScore(model, variant); score == 0 {
+		t.Error("a 4.9GB model should fit 14GB of free RAM")
+	}
+	if score, reason := busy.CalculateScore(model, variant); score != 0 {
+		t.Errorf("a 4.9GB model should not fit 2GB of free RAM, got %.1f (%s)", score, reason)
+	}
+}
@@ -0,0 +1,31 @@
+package profiler
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// memoryStatusEx is MEMORYSTATUSEX from sysinfoapi.h
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+var procGlobalMemoryStatusEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalMemoryStatusEx")
+
+// globalMemoryStatus returns total and available physical memory in MB
+func globalMemoryStatus() (int, int) {
+	status := memoryStatusEx{}
+	status.Length = uint32(unsafe.Sizeof(status))
+	if ok, _, _ := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status))); ok == 0 {
+		return 0, 0
+	}
+	return int(status.TotalPhys >> 20), int(status.AvailPhys >> 20)
+}
@@ -0,0 +1,76 @@
+package profiler
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MIGDevice is one MIG slice of a partitioned NVIDIA GPU
+type MIGDevice struct {
+	GPUIndex int    `json:"gpu_index"`
+	Index    int    `json:"index"`
+	Profile  string `json:"profile"` // e.g. "1g.10gb"
+	UUID     string `json:"uuid"`
+	MemoryMB int    `json:"memory_mb"`
+}
+
+var (
+	gpuLinePattern = regexp.MustCompile(`^GPU (\d+):`)
+	migLinePattern = regexp.MustCompile(`^\s+MIG (\S+)\s+Device\s+(\d+): \(UUID: (MIG-[^)]+)\)`)
+	migMemPattern  = regexp.MustCompile(`\.(\d+)gb`)
+)
+
+// detectMIG lists MIG slices reported by nvidia-smi -L
+func detectMIG() []MIGDevice {
+	out, err := exec.Command("nvidia-smi", "-L").Output()
+	if err != nil {
+		return nil
+	}
+	return parseMIGList(string(out))
+}
+
+func parseMIGList(output string) []MIGDevice {
+	var devices []MIGDevice
+	gpu := -1
+	for _, line := range strings.Split(output, "\n") {
+		if m := gpuLinePattern.FindStringSubmatch(line); m != nil {
+			gpu, _ = strconv.Atoi(m[1])
+			continue
+		}
+		m := migLinePattern.FindStringSubmatch(line)
+		if m == nil || gpu < 0 {
+			continue
+		}
+		index, _ := strconv.Atoi(m[2])
+		device := MIGDevice{GPUIndex: gpu, Index: index, Profile: m[1], UUID: m[3]}
+		if mem := migMemPattern.FindStringSubmatch(m[1]); mem != nil {
+			gb, _ := strconv.Atoi(mem[1])
+			device.MemoryMB = gb * 1024
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// FindMIGDevice resolves "GPU:INDEX" (e.g. "0:1"), a MIG UUID, or a profile name
+// (first match, e.g. "1g.10gb") to a MIG slice
+func (p *HardwareProfile) FindMIGDevice(spec string) (MIGDevice, error) {
+	for _, d := range p.MIGDevices {
+		if spec == d.UUID || spec == fmt.Sprintf("%d:%d", d.GPUIndex, d.Index) || spec == d.Profile {
+			return d, nil
+		}
+	}
+	return MIGDevice{}, fmt.Errorf("no MIG device matches %q", spec)
+}
+
+// largestMIGMemoryMB returns the memory of the biggest slice, which bounds what one worker can load
+func largestMIGMemoryMB(devices []MIGDevice) int {
+	largest := 0
+	for _, d := range devices {
+		largest = max(largest, d.MemoryMB)
+	}
+	return largest
+}
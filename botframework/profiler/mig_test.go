@@ -0,0 +1,39 @@
+package profiler
+
+import "testing"
+
+const migListing = `GPU 0: NVIDIA A100-SXM4-40GB (UUID: GPU-5d5ba0d6-d33d-2b2c-524d-9e3d8d2b8a77)
+  MIG 3g.20gb     Device  0: (UUID: MIG-4b6a9a49-0e8b-5a7e-9e0f-3f4c6d1d2a11)
+  MIG 1g.5gb      Device  1: (UUID: MIG-8c1e7f3a-2b9d-5c4e-8a7f-1d2e3f4a5b66)
+GPU 1: NVIDIA A100-SXM4-40GB (UUID: GPU-0aa1bb2c-3dd4-5ee6-7ff8-9a0b1c2d3e4f)
+`
+
+func TestParseMIGList(t *testing.T) {
+	devices := parseMIGList(migListing)
+	if len(devices) != 2 {
+		t.Fatalf("expected 2 MIG devices, got %d", len(devices))
+	}
+	if devices[0].Profile != "3g.20gb" || devices[0].MemoryMB != 20*1024 || devices[0].GPUIndex != 0 {
+		t.Fatalf("unexpected first device: %+v", devices[0])
+	}
+	if devices[1].UUID != "MIG-8c1e7f3a-2b9d-5c4e-8a7f-1d2e3f4a5b66" || devices[1].Index != 1 {
+		t.Fatalf("unexpected second device: %+v", devices[1])
+	}
+	if got := largestMIGMemoryMB(devices); got != 20*1024 {
+		t.Fatalf("expected largest slice of 20GB, got %d", got)
+	}
+}
+
+func TestFindMIGDevice(t *testing.T) {
+	profile := &HardwareProfile{MIGDevices: parseMIGList(migListing)}
+
+	for _, spec := range []string{"0:1", "1g.5gb", "MIG-8c1e7f3a-2b9d-5c4e-8a7f-1d2e3f4a5b66"} {
+		device, err := profile.FindMIGDevice(spec)
+		if err != nil || device.Index != 1 {
+			t.Fatalf("spec %q: got %+v, %v", spec, device, err)
+		}
+	}
+	if _, err := profile.FindMIGDevice("7g.80gb"); err == nil {
+		t.Fatal("expected error for unknown MIG profile")
+	}
+}
@@ -0,0 +1,106 @@
+package profiler
+
+import (
+	"fmt"
+	"math"
+)
+
+// Bandwidths assumed when predicting the decode rate of a split model: a discrete GPU's
+// VRAM, and dual-channel DDR5 system RAM. Each token streams every weight once from
+// wherever its layer lives, so the layers left in system RAM dominate.
+const (
+	gpuBandwidthGBs       = 500.0
+	systemRAMBandwidthGBs = 60.0
+)
+
+// fallbackLayers is assumed for models without architecture metadata
+const fallbackLayers = 32
+
+// minOffloadTPS is the slowest predicted decode rate, about reading speed, at which a split
+// model is still recommended
+const minOffloadTPS = 5.0
+
+// OffloadPlan splits a model too large for VRAM between the GPU and the CPU, as llama.cpp
+// does with -ngl
+type OffloadPlan struct {
+	// GPULayers of the model's Layers go on the GPU, the rest run on the CPU
+	GPULayers int `json:"gpu_layers"`
+	Layers    int `json:"layers"`
+	// CPUWeightsGB is the share of the weights left in system RAM
+	CPUWeightsGB float64 `json:"cpu_weights_gb"`
+	// DecodeTPS is the decode rate predicted for the split, FullDecodeTPS the rate with
+	// every layer on the GPU
+	DecodeTPS     float64 `json:"decode_tps"`
+	FullDecodeTPS float64 `json:"full_decode_tps"`
+}
+
+// Full reports whether every layer fits on the GPU
+func (o OffloadPlan) Full() bool {
+	return o.GPULayers >= o.Layers
+}
+
+func (o OffloadPlan) String() string {
+	return fmt.Sprintf("%d/%d layers on the GPU, ~%.0f tok/s decode vs ~%.0f fully offloaded", o.GPULayers, o.Layers, o.DecodeTPS, o.FullDecodeTPS)
+}
+
+// PlanOffload works out how many layers of model, whose weights take weightsGB, fit in VRAM
+// beside the KV cache of contextTokens and the compute buffers, which llama.cpp keeps on
+// the GPU. Layers are taken to weigh the same. model may be the zero Model, which
+// estimates the layer count and the KV cache.
+func (p *HardwareProfile) PlanOffload(model Model, weightsGB float64, contextTokens int) OffloadPlan {
+	plan := OffloadPlan{Layers: fallbackLayers}
+	if model.Architecture != nil && model.Architecture.Layers > 0 {
+		plan.Layers = model.Architecture.Layers
+	}
+	if weightsGB <= 0 {
+		plan.GPULayers = plan.Layers
+		return plan
+	}
+	perLayerGB := weightsGB / float64(plan.Layers)
+	context := model.scoringContext(contextTokens)
+	vramGB := float64(p.VRAM_MB-p.ReservedMB)/1024.0 - model.KVCacheGB(context, kvBytesF16) - model.activationsGB(context)
+	plan.GPULayers = min(plan.Layers, max(0, int(vramGB/perLayerGB)))
+	plan.CPUWeightsGB = perLayerGB * float64(plan.Layers-plan.GPULayers)
+
+	gpuBandwidth, ramBandwidth := gpuBandwidthGBs, systemRAMBandwidthGBs
+	if p.Apple != nil {
+		gpuBandwidth, ramBandwidth = p.Apple.BandwidthGBs, p.Apple.BandwidthGBs
+	}
+	seconds := (weightsGB-plan.CPUWeightsGB)/gpuBandwidth + plan.CPUWeightsGB/ramBandwidth
+	plan.DecodeTPS = decodeEfficiency / seconds
+	plan.FullDecodeTPS = gpuBandwidth * decodeEfficiency / weightsGB
+	return plan
+}
+
+// partialOffload plans llama.cpp's split of a GGUF variant too large for the discrete GPU,
+// when the layers left on the CPU fit in system RAM beside the OS buffer and the split
+// still decodes at reading speed. headroomGB is what RAM they leave.
+func (p *HardwareProfile) partialOffload(model Model, variant Variant, contextTokens int) (plan OffloadPlan, headroomGB float64, ok bool) {
+	if !p.HasCuda && !p.HasROCm && !p.hasArc() {
+		return plan, 0, false
+	}
+	if variant.Format != "" && variant.Format != FormatGGUF {
+		return plan, 0, false
+	}
+	plan = p.PlanOffload(model, variant.SizeGB, contextTokens)
+	ramGB := float64(p.SystemRAM_MB) / 1024.0
+	if p.SystemRAMAvailable_MB > 0 {
+		ramGB = math.Min(ramGB, float64(p.SystemRAMAvailable_MB)/1024.0+osBufferGB)
+	}
+	headroomGB = ramGB - osBufferGB - plan.CPUWeightsGB
+	if plan.GPULayers == 0 || headroomGB < 0 || plan.DecodeTPS < minOffloadTPS {
+		return plan, 0, false
+	}
+	return plan, headroomGB, true
+}
+
+// score is the speed term of CalculateScore for a partially offloaded variant, judged like
+// a measured decode rate but from the predicted one; measured variants keep their own
+func (o OffloadPlan) score(measured *SpeedMeasurement) (float64, string) {
+	note := ", Offload: " + o.String()
+	if measured != nil || o.DecodeTPS <= 0 {
+		return 0, note
+	}
+	score := math.Max(-20, math.Min(10, 10*math.Log2(o.DecodeTPS/referenceDecodeTPS)))
+	return score, fmt.Sprintf(", Offload: %+.1f (%s)", score, o)
+}
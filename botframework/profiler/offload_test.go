@@ -0,0 +1,48 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanOffload(t *testing.T) {
+	model := Model{ParamsB: 34, ContextWindow: 16384, Benchmarks: Benchmarks{MMLU: 75},
+		Architecture: &Architecture{HiddenSize: 7168, Layers: 48, KVHeads: 8, HeadDim: 128}}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 16384, SystemRAM_MB: 65536}
+
+	// 48 layers of 0.375GB: 16GB less the 0.75GB KV cache of 4k tokens and 0.83GB of
+	// compute buffers holds 38 of them
+	plan := profile.PlanOffload(model, 18, 4096)
+	if plan.GPULayers != 38 || plan.Layers != 48 || plan.CPUWeightsGB != 3.75 || plan.Full() {
+		t.Fatalf("plan = %+v", plan)
+	}
+	if plan.DecodeTPS >= plan.FullDecodeTPS || plan.DecodeTPS < minOffloadTPS {
+		t.Errorf("predicted %.1f tok/s split, %.1f fully offloaded", plan.DecodeTPS, plan.FullDecodeTPS)
+	}
+	if full := profile.PlanOffload(model, 8, 4096); !full.Full() || full.CPUWeightsGB != 0 {
+		t.Errorf("8GB of weights on a 16GB GPU: %+v", full)
+	}
+
+	variant := Variant{Quant: "Q4_K_M", SizeGB: 18, AccuracyRetention: 0.98}
+	score, reason := profile.CalculateScore(model, variant)
+	if score <= 0 || !strings.Contains(reason, "Offload: ") || !strings.Contains(reason, "38/48 layers on the GPU") {
+		t.Errorf("partially offloaded variant: %.1f %q", score, reason)
+	}
+	fits, _ := profile.CalculateScore(model, Variant{Quant: "Q4_K_M", SizeGB: 12, AccuracyRetention: 0.98})
+	if score >= fits {
+		t.Errorf("the split variant scored %.1f, not below the %.1f of one that fits", score, fits)
+	}
+
+	// the layers left on the CPU must fit in system RAM and decode at reading speed, and
+	// formats llama.cpp does not load cannot be split
+	if score, reason := (&HardwareProfile{HasCuda: true, VRAM_MB: 16384, SystemRAM_MB: 4096}).CalculateScore(model, variant); score != 0 {
+		t.Errorf("4GB of RAM: %.1f %q", score, reason)
+	}
+	if score, _ := profile.CalculateScore(model, Variant{Quant: "Q8_0", SizeGB: 30, AccuracyRetention: 0.99}); score != 0 {
+		t.Errorf("a split leaving 16GB on the CPU scored %.1f", score)
+	}
+	variant.Format = FormatSafetensors
+	if score, _ := profile.CalculateScore(model, variant); score != 0 {
+		t.Errorf("safetensors variant scored %.1f", score)
+	}
+}
@@ -0,0 +1,137 @@
+package profiler
+
+import (
+	"encoding/json"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// intelGPU is one Intel GPU found by detectOneAPI
+type intelGPU struct {
+	Name     string
+	VRAM_MB  int // 0 for integrated GPUs, which share system RAM
+	Discrete bool
+}
+
+// arcVRAM_MB gives the memory of Arc cards by marketing name, for hosts where only lspci is
+// available. Cards sold with two memory sizes are listed with the smaller one.
+var arcVRAM_MB = map[string]int{
+	"A310": 4096, "A380": 6144, "A580": 8192, "A750": 8192, "A770": 8192,
+	"B570": 10240, "B580": 12288,
+	"Pro A40": 6144, "Pro A50": 6144, "Pro A60": 16384, "Pro B50": 16384, "Pro B60": 24576,
+}
+
+var (
+	lspciIntelPattern = regexp.MustCompile(`(?i)(VGA compatible|Display|3D) controller \[03[0-9a-f]{2}\]: Intel Corporation (.*) \[8086:([0-9a-f]{4})\]`)
+	arcModelPattern   = regexp.MustCompile(`(Pro [AB]\d{2}|[AB]\d{2,3})[MEG]?\b`)
+)
+
+// detectOneAPI finds Intel GPUs via xpu-smi, falling back to lspci. It prefers a discrete
+// Arc card over the integrated GPU.
+func detectOneAPI() (gpu intelGPU, ok bool) {
+	gpus := detectXPUSMI()
+	if len(gpus) == 0 {
+		if out, err := exec.Command("lspci", "-nn").Output(); err == nil {
+			gpus = parseLspciIntel(string(out))
+		}
+	}
+	for _, candidate := range gpus {
+		if !ok || candidate.Discrete && (!gpu.Discrete || candidate.VRAM_MB > gpu.VRAM_MB) {
+			gpu, ok = candidate, true
+		}
+	}
+	return gpu, ok
+}
+
+func detectXPUSMI() []intelGPU {
+	out, err := exec.Command("xpu-smi", "discovery", "-j").Output()
+	if err != nil {
+		return nil
+	}
+	var gpus []intelGPU
+	for _, id := range parseXPUSMIDiscovery(out) {
+		details, err := exec.Command("xpu-smi", "discovery", "-d", strconv.Itoa(id), "-j").Output()
+		if err != nil {
+			continue
+		}
+		if gpu, ok := parseXPUSMIDevice(details); ok {
+			gpus = append(gpus, gpu)
+		}
+	}
+	return gpus
+}
+
+// parseXPUSMIDiscovery returns the device IDs listed by `xpu-smi discovery -j`
+func parseXPUSMIDiscovery(out []byte) []int {
+	var listing struct {
+		DeviceList []struct {
+			DeviceID int `json:"device_id"`
+		} `json:"device_list"`
+	}
+	if err := json.Unmarshal(out, &listing); err != nil {
+		return nil
+	}
+	ids := make([]int, len(listing.DeviceList))
+	for i, device := range listing.DeviceList {
+		ids[i] = device.DeviceID
+	}
+	return ids
+}
+
+// parseXPUSMIDevice reads `xpu-smi discovery -d N -j`, which reports sizes as strings
+func parseXPUSMIDevice(out []byte) (intelGPU, bool) {
+	var device struct {
+		Name       string `json:"device_name"`
+		Type       string `json:"device_type"`
+		MemoryByte string `json:"memory_physical_size_byte"`
+	}
+	if err := json.Unmarshal(out, &device); err != nil || device.Name == "" {
+		return intelGPU{}, false
+	}
+	bytes, _ := strconv.ParseInt(device.MemoryByte, 10, 64)
+	gpu := intelGPU{Name: device.Name, Discrete: isDiscreteIntel(device.Name)}
+	if gpu.Discrete {
+		gpu.VRAM_MB = int(bytes / 1024 / 1024)
+	}
+	return gpu, true
+}
+
+// parseLspciIntel finds Intel display controllers in `lspci -nn` output, estimating Arc
+// memory from the model name
+func parseLspciIntel(output string) []intelGPU {
+	var gpus []intelGPU
+	for _, line := range strings.Split(output, "\n") {
+		m := lspciIntelPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		gpu := intelGPU{Name: strings.TrimSpace(m[2]), Discrete: isDiscreteIntel(m[2])}
+		if gpu.Discrete {
+			if model := arcModelPattern.FindStringSubmatch(m[2]); model != nil {
+				gpu.VRAM_MB = arcVRAM_MB[model[1]]
+			}
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+// isDiscreteIntel recognises Arc (Alchemist "DG2", Battlemage "BMG") and Data Center GPUs.
+// Integrated Xe graphics are also branded "Arc" on Meteor Lake and later, but without a
+// model number.
+func isDiscreteIntel(name string) bool {
+	switch {
+	case strings.Contains(name, "DG2"), strings.Contains(name, "Battlemage"), strings.Contains(name, "Data Center GPU"):
+		return true
+	case strings.Contains(name, "Arc"):
+		return arcModelPattern.MatchString(name)
+	}
+	return false
+}
+
+// hasArc reports a discrete Intel GPU with its own memory
+func (p *HardwareProfile) hasArc() bool {
+	return p.HasOneAPI && p.VRAM_MB > 0
+}
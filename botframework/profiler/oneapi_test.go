@@ -0,0 +1,66 @@
+package profiler
+
+import "testing"
+
+const lspciListing = `00:02.0 VGA compatible controller [0300]: Intel Corporation Alder Lake-P GT2 [Iris Xe Graphics] [8086:46a6] (rev 0c)
+00:14.0 USB controller [0c03]: Intel Corporation Alder Lake PCH USB 3.2 xHCI Host Controller [8086:51ed] (rev 01)
+03:00.0 VGA compatible controller [0300]: Intel Corporation DG2 [Arc A750] [8086:56a1] (rev 08)
+`
+
+func TestParseLspciIntel(t *testing.T) {
+	gpus := parseLspciIntel(lspciListing)
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 Intel GPUs, got %+v", gpus)
+	}
+	if gpus[0].Discrete || gpus[0].VRAM_MB != 0 {
+		t.Fatalf("expected integrated Iris Xe, got %+v", gpus[0])
+	}
+	if !gpus[1].Discrete || gpus[1].VRAM_MB != 8192 {
+		t.Fatalf("expected 8GB Arc A750, got %+v", gpus[1])
+	}
+}
+
+func TestIsDiscreteIntel(t *testing.T) {
+	cases := map[string]bool{
+		"Intel(R) Arc(TM) A770 Graphics":       true,
+		"Intel(R) Arc(TM) B580 Graphics":       true,
+		"Meteor Lake-P [Intel Arc Graphics]":   false,
+		"Intel(R) Data Center GPU Flex 170":    true,
+		"Raptor Lake-S GT1 [UHD Graphics 770]": false,
+	}
+	for name, want := range cases {
+		if got := isDiscreteIntel(name); got != want {
+			t.Errorf("%s: want %v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestParseXPUSMI(t *testing.T) {
+	ids := parseXPUSMIDiscovery([]byte(`{"device_list": [{"device_id": 0, "device_name": "Intel(R) Arc(TM) A770 Graphics", "device_type": "GPU"}]}`))
+	if len(ids) != 1 || ids[0] != 0 {
+		t.Fatalf("unexpected device IDs: %v", ids)
+	}
+	gpu, ok := parseXPUSMIDevice([]byte(`{"device_name": "Intel(R) Arc(TM) A770 Graphics", "device_type": "GPU", "memory_physical_size_byte": "17079205888"}`))
+	if !ok || !gpu.Discrete || gpu.VRAM_MB != 16288 {
+		t.Fatalf("unexpected device: %+v", gpu)
+	}
+}
+
+func TestRecommendedEngineOnArc(t *testing.T) {
+	a770 := &HardwareProfile{HasOneAPI: true, VRAM_MB: 16 * 1024, SystemRAM_MB: 32 * 1024}
+	if got := a770.GetRecommendedEngine(5.5); got != EngineIPEXLLM {
+		t.Fatalf("expected IPEX-LLM with headroom, got %s", got)
+	}
+	if got := a770.GetRecommendedEngine(15); got != EngineLlamaCPPSYCL {
+		t.Fatalf("expected llama.cpp SYCL for a tight fit, got %s", got)
+	}
+	if tier := a770.ClassifyTier(); tier != TierHigh {
+		t.Fatalf("expected High tier, got %s", tier)
+	}
+
+	// integrated graphics share system RAM and stay on the CPU path
+	iGPU := &HardwareProfile{HasOneAPI: true, SystemRAM_MB: 16 * 1024}
+	if got := iGPU.GetRecommendedEngine(5.5); got != EngineLlamaCPP {
+		t.Fatalf("expected llama.cpp on integrated graphics, got %s", got)
+	}
+}
@@ -0,0 +1,172 @@
+package profiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ModelDemand is a model to be placed in memory: its weights, and the KV cache and compute
+// buffers of Context tokens
+type ModelDemand struct {
+	Name string
+	// Model sizes the KV cache; the zero Model estimates it
+	Model     Model
+	WeightsGB float64
+	Context   int
+	// GPUs pins the model to those GPUs, split evenly between them; nil lets Place choose
+	GPUs []int
+}
+
+// NeedGB is the memory the model takes: weights, KV cache and compute buffers
+func (d ModelDemand) NeedGB() float64 {
+	context := d.Model.scoringContext(d.Context)
+	return d.WeightsGB + d.Model.KVCacheGB(context, kvBytesF16) + d.Model.activationsGB(context)
+}
+
+// DevicePlacement is what a placement puts on one GPU, or in the memory of a host without
+// discrete GPUs
+type DevicePlacement struct {
+	Name       string  `json:"name"`
+	CapacityGB float64 `json:"capacity_gb"`
+	UsedGB     float64 `json:"used_gb"`
+	// Models lists the models on the device with the memory each takes there
+	Models map[string]float64 `json:"models"`
+}
+
+// Over is how far the device is overcommitted, 0 when its models fit
+func (d DevicePlacement) Over() float64 {
+	return max(0, d.UsedGB-d.CapacityGB)
+}
+
+// Placement is where a set of models goes
+type Placement struct {
+	Devices []DevicePlacement `json:"devices"`
+	// GPUs are the GPUs each model was given, by name; empty on hosts without discrete GPUs
+	GPUs GPUAssignments `json:"gpus,omitempty"`
+}
+
+// Fits reports whether every device holds its models
+func (p Placement) Fits() bool {
+	for _, device := range p.Devices {
+		if device.Over() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Report lays the placement out one device per line, marking the overcommitted ones
+func (p Placement) Report() string {
+	var b strings.Builder
+	for _, device := range p.Devices {
+		names := make([]string, 0, len(device.Models))
+		for name := range device.Models {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		models := make([]string, len(names))
+		for i, name := range names {
+			models[i] = fmt.Sprintf("%s %.1fGB", name, device.Models[name])
+		}
+		verdict := "ok"
+		if over := device.Over(); over > 0 {
+			verdict = fmt.Sprintf("%.1fGB over", over)
+		}
+		fmt.Fprintf(&b, "  %-7s %6.1fGB of %6.1fGB  %-12s %s\n", device.Name, device.UsedGB, device.CapacityGB, verdict, strings.Join(models, ", "))
+	}
+	return b.String()
+}
+
+// PlacementError is returned for models that do not fit; its message is the placement's report
+type PlacementError struct {
+	Placement Placement
+}
+
+func (e *PlacementError) Error() string {
+	return "the models need more memory than the host has:\n" + strings.TrimRight(e.Placement.Report(), "\n")
+}
+
+// Place puts every demand in memory, checking the total against the hardware. On NVIDIA and
+// AMD hosts each GPU is filled separately: pinned models are split evenly over their GPUs,
+// and the others, largest first, go to the GPU with the most room, or across the smallest
+// power-of-two group of the emptiest GPUs when none holds them alone. Elsewhere every model
+// shares the memory models may use. Memory reserved for the embedding model is taken from
+// the first GPU. It returns a *PlacementError when any device is overcommitted.
+func (p *HardwareProfile) Place(demands []ModelDemand) (Placement, error) {
+	var placement Placement
+	if (!p.HasCuda && !p.HasROCm) || len(p.GPUs) == 0 {
+		device := DevicePlacement{Name: "memory", CapacityGB: p.modelMemoryGB() - osBufferGB, Models: map[string]float64{}}
+		for _, demand := range demands {
+			need := demand.NeedGB()
+			device.UsedGB += need
+			device.Models[demand.Name] += need
+		}
+		placement.Devices = []DevicePlacement{device}
+		if !placement.Fits() {
+			return placement, &PlacementError{placement}
+		}
+		return placement, nil
+	}
+
+	placement.GPUs = GPUAssignments{}
+	devices := make([]DevicePlacement, len(p.GPUs))
+	byIndex := make(map[int]*DevicePlacement, len(p.GPUs))
+	for i, gpu := range p.GPUs {
+		devices[i] = DevicePlacement{Name: fmt.Sprintf("gpu%d", gpu.Index), CapacityGB: float64(gpu.VRAM_MB) / 1024.0, Models: map[string]float64{}}
+		byIndex[gpu.Index] = &devices[i]
+	}
+	devices[0].CapacityGB -= float64(p.ReservedMB) / 1024.0
+	put := func(demand ModelDemand, gpus []int) {
+		share := demand.NeedGB() / float64(len(gpus))
+		for _, index := range gpus {
+			device := byIndex[index]
+			device.UsedGB += share
+			device.Models[demand.Name] += share
+		}
+		placement.GPUs[demand.Name] = gpus
+	}
+
+	var unpinned []ModelDemand
+	for _, demand := range demands {
+		if demand.GPUs == nil {
+			unpinned = append(unpinned, demand)
+			continue
+		}
+		for _, index := range demand.GPUs {
+			if byIndex[index] == nil {
+				return placement, fmt.Errorf("%s: GPU %d was not detected", demand.Name, index)
+			}
+		}
+		put(demand, demand.GPUs)
+	}
+	sort.SliceStable(unpinned, func(i, j int) bool { return unpinned[i].NeedGB() > unpinned[j].NeedGB() })
+	for _, demand := range unpinned {
+		put(demand, emptiestGPUs(p.GPUs, byIndex, demand.NeedGB()))
+	}
+
+	placement.Devices = devices
+	if !placement.Fits() {
+		return placement, &PlacementError{placement}
+	}
+	return placement, nil
+}
+
+// emptiestGPUs picks the GPUs for needGB: the one with the most room when it holds it, else
+// the smallest power-of-two group of the emptiest GPUs that does, else the emptiest alone
+func emptiestGPUs(gpus []GPUInfo, devices map[int]*DevicePlacement, needGB float64) []int {
+	free := func(index int) float64 { return devices[index].CapacityGB - devices[index].UsedGB }
+	order := make([]int, len(gpus))
+	for i, gpu := range gpus {
+		order[i] = gpu.Index
+	}
+	sort.SliceStable(order, func(i, j int) bool { return free(order[i]) > free(order[j]) })
+	for n := 1; n <= len(order); n *= 2 {
+		if free(order[n-1]) >= needGB/float64(n) {
+			group := append([]int(nil), order[:n]...)
+			sort.Ints(group)
+			return group
+		}
+	}
+	return order[:1]
+}
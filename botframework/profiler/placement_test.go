@@ -0,0 +1,48 @@
+package profiler
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPlaceSpreadsModelsOverGPUs(t *testing.T) {
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 24576,
+		GPUs: []GPUInfo{{Index: 0, VRAM_MB: 24576}, {Index: 1, VRAM_MB: 24576}, {Index: 2, VRAM_MB: 24576}}}
+	placement, err := profile.Place([]ModelDemand{
+		{Name: "pinned", WeightsGB: 10, GPUs: []int{0}},
+		{Name: "small", WeightsGB: 8},
+		{Name: "large", WeightsGB: 30},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// large needs two GPUs and takes the two empty ones; small then joins pinned on GPU 0
+	if got := placement.GPUs["large"]; !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("large on %v, want GPUs 1 and 2", got)
+	}
+	if got := placement.GPUs["small"]; !slices.Equal(got, []int{0}) {
+		t.Errorf("small on %v, want GPU 0", got)
+	}
+}
+
+func TestPlaceReportsWhatDoesNotFit(t *testing.T) {
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 24576, GPUs: []GPUInfo{{Index: 0, VRAM_MB: 24576}, {Index: 1, VRAM_MB: 24576}}}
+	_, err := profile.Place([]ModelDemand{
+		{Name: "a", WeightsGB: 20, GPUs: []int{1}},
+		{Name: "b", WeightsGB: 10, GPUs: []int{1}},
+	})
+	var placementErr *PlacementError
+	if !errors.As(err, &placementErr) {
+		t.Fatalf("err = %v, want a placement error", err)
+	}
+	if report := err.Error(); !strings.Contains(report, "gpu1") || !strings.Contains(report, "over") || !strings.Contains(report, "b 10.") {
+		t.Errorf("report:\n%s", report)
+	}
+
+	cpu := &HardwareProfile{SystemRAM_MB: 16384}
+	if _, err := cpu.Place([]ModelDemand{{Name: "a", WeightsGB: 8}, {Name: "b", WeightsGB: 8}}); err == nil {
+		t.Error("16GB of weights placed in 16GB of RAM")
+	}
+}
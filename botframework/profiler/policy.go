@@ -0,0 +1,38 @@
+package profiler
+
+// TierDefaults are the generation settings a hardware tier can sustain. Workers are launched
+// with them and requests are held to them, so a Legacy laptop is never asked for an 8k
+// context that would push it into swap.
+type TierDefaults struct {
+	// ContextSize is the context window workers are launched with, in tokens
+	ContextSize int `json:"context_size"`
+	// BatchSize is how many prompt tokens a worker processes per step
+	BatchSize int `json:"batch_size"`
+	// GPULayers is how many layers are offloaded to the GPU: -1 for all, 0 for none
+	GPULayers int `json:"gpu_layers"`
+	// MaxTokens is the completion length of requests that set none
+	MaxTokens int `json:"max_tokens"`
+	// MaxTokensCap bounds the completion length a request may ask for
+	MaxTokensCap int `json:"max_tokens_cap"`
+}
+
+var tierDefaults = map[Tier]TierDefaults{
+	TierElite:    {ContextSize: 8192, BatchSize: 2048, GPULayers: -1, MaxTokens: 1024, MaxTokensCap: 8192},
+	TierHigh:     {ContextSize: 4096, BatchSize: 1024, GPULayers: -1, MaxTokens: 1024, MaxTokensCap: 4096},
+	TierApple:    {ContextSize: 4096, BatchSize: 512, GPULayers: -1, MaxTokens: 1024, MaxTokensCap: 4096},
+	TierBalanced: {ContextSize: 4096, BatchSize: 512, GPULayers: 0, MaxTokens: 512, MaxTokensCap: 2048},
+	TierLegacy:   {ContextSize: 2048, BatchSize: 256, GPULayers: 0, MaxTokens: 256, MaxTokensCap: 1024},
+}
+
+// DefaultsForTier returns the settings for tier; unknown tiers get Legacy's
+func DefaultsForTier(tier Tier) TierDefaults {
+	if defaults, ok := tierDefaults[tier]; ok {
+		return defaults
+	}
+	return tierDefaults[TierLegacy]
+}
+
+// TierDefaults returns the settings for the profile's tier
+func (p *HardwareProfile) TierDefaults() TierDefaults {
+	return DefaultsForTier(p.ClassifyTier())
+}
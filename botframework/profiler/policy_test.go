@@ -0,0 +1,17 @@
+package profiler
+
+import "testing"
+
+func TestTierDefaultsShrinkWithTheHardware(t *testing.T) {
+	legacy := (&HardwareProfile{SystemRAM_MB: 8 * 1024}).TierDefaults()
+	if legacy.ContextSize != 2048 || legacy.GPULayers != 0 || legacy.MaxTokensCap > legacy.ContextSize {
+		t.Errorf("Legacy defaults %+v, want a 2048 context on the CPU", legacy)
+	}
+	elite := (&HardwareProfile{HasCuda: true, VRAM_MB: 48 * 1024, SystemRAM_MB: 128 * 1024}).TierDefaults()
+	if elite.GPULayers != -1 || elite.ContextSize <= legacy.ContextSize || elite.MaxTokens <= legacy.MaxTokens {
+		t.Errorf("Elite defaults %+v should offload everything and allow more than Legacy %+v", elite, legacy)
+	}
+	if DefaultsForTier("Quantum") != DefaultsForTier(TierLegacy) {
+		t.Error("unknown tiers should get the most conservative defaults")
+	}
+}
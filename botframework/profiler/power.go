@@ -0,0 +1,280 @@
+package profiler
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// PowerSource is where a machine draws its power from
+type PowerSource string
+
+const (
+	PowerAC      PowerSource = "ac"
+	PowerBattery PowerSource = "battery"
+)
+
+// ThermalPressure is how hard the system is throttling to stay cool, in macOS's terms
+type ThermalPressure string
+
+const (
+	ThermalNominal  ThermalPressure = "nominal"
+	ThermalModerate ThermalPressure = "moderate"
+	ThermalHeavy    ThermalPressure = "heavy"
+	ThermalCritical ThermalPressure = "critical"
+)
+
+// ThermalPressures lists the pressure levels from coolest to hottest
+var ThermalPressures = []ThermalPressure{ThermalNominal, ThermalModerate, ThermalHeavy, ThermalCritical}
+
+func (t ThermalPressure) level() int {
+	for i, p := range ThermalPressures {
+		if p == t {
+			return i
+		}
+	}
+	return 0
+}
+
+// PowerInfo is the power and thermal state at detection. Empty fields are unknown, as on
+// desktops without a battery or hosts that expose no thermal sensors.
+type PowerInfo struct {
+	Source PowerSource `json:"source,omitempty"`
+	// BatteryPercent is the charge left, 0 when there is no battery
+	BatteryPercent int             `json:"battery_percent,omitempty"`
+	Thermal        ThermalPressure `json:"thermal,omitempty"`
+}
+
+// OnBattery reports whether the machine is running off its battery
+func (p PowerInfo) OnBattery() bool {
+	return p.Source == PowerBattery
+}
+
+// Constrained reports whether inference should be lighter than the hardware allows: on
+// battery, or while the system throttles heavily for heat
+func (p PowerInfo) Constrained() bool {
+	return p.OnBattery() || p.Thermal.level() >= ThermalHeavy.level()
+}
+
+// score down-ranks variants by size while power is constrained: heavier variants drain the
+// battery faster and heat a throttling machine further. The first 2GB are free so small
+// models are not penalised at all.
+func (p PowerInfo) score(variant Variant) (float64, string) {
+	perGB := 0.0
+	if p.OnBattery() {
+		perGB = 2.0
+		if p.BatteryPercent > 0 && p.BatteryPercent < 20 {
+			perGB = 4.0
+		}
+	}
+	switch p.Thermal {
+	case ThermalModerate:
+		perGB += 0.5
+	case ThermalHeavy:
+		perGB += 1.5
+	case ThermalCritical:
+		perGB += 3.0
+	}
+	heavyGB := variant.SizeGB - 2.0
+	if perGB == 0 || heavyGB <= 0 {
+		return 0, ""
+	}
+	penalty := perGB * heavyGB
+	return -penalty, fmt.Sprintf(", Power(%s): -%.1f", p, penalty)
+}
+
+func (p PowerInfo) String() string {
+	var parts []string
+	if p.Source != "" {
+		parts = append(parts, string(p.Source))
+	}
+	if p.OnBattery() && p.BatteryPercent > 0 {
+		parts = append(parts, strconv.Itoa(p.BatteryPercent)+"%")
+	}
+	if p.Thermal != "" && p.Thermal != ThermalNominal {
+		parts = append(parts, "thermal "+string(p.Thermal))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DetectPower reads the power source and thermal pressure: pmset (or powermetrics, which
+// needs root) on macOS, /sys/class/power_supply and /sys/class/thermal on Linux
+func DetectPower() PowerInfo {
+	switch runtime.GOOS {
+	case "darwin":
+		return detectDarwinPower()
+	case "linux":
+		return detectLinuxPower("/sys")
+	}
+	return PowerInfo{}
+}
+
+func detectDarwinPower() PowerInfo {
+	var info PowerInfo
+	if out, err := exec.Command("pmset", "-g", "batt").Output(); err == nil {
+		info.Source, info.BatteryPercent = parsePmsetBatt(string(out))
+	}
+	if os.Geteuid() == 0 {
+		out, err := exec.Command("powermetrics", "-n", "1", "-i", "100", "--samplers", "thermal").Output()
+		if err == nil {
+			info.Thermal = parsePowermetricsThermal(string(out))
+		}
+	}
+	if info.Thermal == "" {
+		if out, err := exec.Command("pmset", "-g", "therm").Output(); err == nil {
+			info.Thermal = parsePmsetTherm(string(out))
+		}
+	}
+	return info
+}
+
+var (
+	pmsetSource     = regexp.MustCompile(`drawing from '(AC|Battery) Power'`)
+	pmsetPercent    = regexp.MustCompile(`(\d+)%;`)
+	pmsetSpeedLimit = regexp.MustCompile(`CPU_Speed_Limit\s*=\s*(\d+)`)
+	pressureLevel   = regexp.MustCompile(`(?i)pressure level:\s*(\w+)`)
+)
+
+// parsePmsetBatt reads `pmset -g batt`:
+//
+//	Now drawing from 'Battery Power'
+//	 -InternalBattery-0 (id=4653155)	82%; discharging; 5:12 remaining present: true
+func parsePmsetBatt(out string) (PowerSource, int) {
+	var source PowerSource
+	if match := pmsetSource.FindStringSubmatch(out); match != nil {
+		source = PowerAC
+		if match[1] == "Battery" {
+			source = PowerBattery
+		}
+	}
+	percent := 0
+	if match := pmsetPercent.FindStringSubmatch(out); match != nil {
+		percent, _ = strconv.Atoi(match[1])
+	}
+	return source, percent
+}
+
+// parsePmsetTherm reads the CPU speed limit `pmset -g therm` reports once the system has
+// throttled; an unthrottled machine reports none and counts as nominal
+func parsePmsetTherm(out string) ThermalPressure {
+	match := pmsetSpeedLimit.FindStringSubmatch(out)
+	if match == nil {
+		return ThermalNominal
+	}
+	limit, _ := strconv.Atoi(match[1])
+	switch {
+	case limit >= 100:
+		return ThermalNominal
+	case limit >= 80:
+		return ThermalModerate
+	case limit >= 50:
+		return ThermalHeavy
+	}
+	return ThermalCritical
+}
+
+// parsePowermetricsThermal reads "Current pressure level: Heavy"; Trapping and Sleeping,
+// the levels past Heavy, count as critical
+func parsePowermetricsThermal(out string) ThermalPressure {
+	match := pressureLevel.FindStringSubmatch(out)
+	if match == nil {
+		return ""
+	}
+	switch level := ThermalPressure(strings.ToLower(match[1])); level {
+	case ThermalNominal, ThermalModerate, ThermalHeavy, ThermalCritical:
+		return level
+	case "trapping", "sleeping":
+		return ThermalCritical
+	}
+	return ""
+}
+
+// detectLinuxPower reads the power supplies and thermal zones under root (normally /sys)
+func detectLinuxPower(root string) PowerInfo {
+	var info PowerInfo
+	read := func(path ...string) string {
+		data, _ := os.ReadFile(filepath.Join(path...))
+		return string(bytes.TrimSpace(data))
+	}
+
+	supplies, _ := filepath.Glob(filepath.Join(root, "class", "power_supply", "*"))
+	mains, battery := false, false
+	for _, supply := range supplies {
+		switch read(supply, "type") {
+		case "Mains", "USB":
+			mains = mains || read(supply, "online") == "1"
+		case "Battery":
+			// peripherals such as wireless mice report their batteries too
+			if read(supply, "scope") == "Device" {
+				continue
+			}
+			battery = true
+			if read(supply, "status") == "Discharging" {
+				info.Source = PowerBattery
+			}
+			if percent, err := strconv.Atoi(read(supply, "capacity")); err == nil {
+				info.BatteryPercent = percent
+			}
+		}
+	}
+	if info.Source == "" && (mains || battery) {
+		info.Source = PowerAC
+	}
+
+	zones, _ := filepath.Glob(filepath.Join(root, "class", "thermal", "thermal_zone*"))
+	for _, zone := range zones {
+		if pressure := zonePressure(zone, read); pressure != "" && (info.Thermal == "" || pressure.level() > info.Thermal.level()) {
+			info.Thermal = pressure
+		}
+	}
+	if !info.OnBattery() {
+		info.BatteryPercent = 0
+	}
+	return info
+}
+
+// zonePressure compares a thermal zone's temperature with its trip points: at the passive
+// trip the kernel starts throttling (heavy), within 10°C of it the zone is warming up
+// (moderate). Zones without a passive trip are throttled 15°C below their critical one.
+func zonePressure(zone string, read func(...string) string) ThermalPressure {
+	temp, err := strconv.Atoi(read(zone, "temp"))
+	if err != nil {
+		return ""
+	}
+	passive, critical := 0, 0
+	trips, _ := filepath.Glob(filepath.Join(zone, "trip_point_*_type"))
+	for _, trip := range trips {
+		at, err := strconv.Atoi(read(strings.TrimSuffix(trip, "_type") + "_temp"))
+		if err != nil || at <= 0 {
+			continue
+		}
+		switch read(trip) {
+		case "passive", "hot":
+			if passive == 0 || at < passive {
+				passive = at
+			}
+		case "critical":
+			critical = at
+		}
+	}
+	if passive == 0 && critical > 0 {
+		passive = critical - 15000
+	}
+	switch {
+	case passive == 0:
+		return ""
+	case critical > 0 && temp >= critical:
+		return ThermalCritical
+	case temp >= passive:
+		return ThermalHeavy
+	case temp >= passive-10000:
+		return ThermalModerate
+	}
+	return ThermalNominal
+}
@@ -0,0 +1,112 @@
+package profiler
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePmset(t *testing.T) {
+	batt := "Now drawing from 'Battery Power'\n -InternalBattery-0 (id=4653155)\t82%; discharging; 5:12 remaining present: true\n"
+	if source, percent := parsePmsetBatt(batt); source != PowerBattery || percent != 82 {
+		t.Errorf("parsePmsetBatt(battery) = %s, %d", source, percent)
+	}
+	plugged := "Now drawing from 'AC Power'\n -InternalBattery-0 (id=4653155)\t100%; charged; 0:00 remaining present: true\n"
+	if source, _ := parsePmsetBatt(plugged); source != PowerAC {
+		t.Errorf("parsePmsetBatt(AC) = %s", source)
+	}
+
+	for out, want := range map[string]ThermalPressure{
+		"Note: No thermal warning level has been recorded\n":    ThermalNominal,
+		"CPU_Scheduler_Limit \t= 100\nCPU_Speed_Limit \t= 85\n": ThermalModerate,
+		"CPU_Speed_Limit \t= 60\n":                              ThermalHeavy,
+		"CPU_Speed_Limit \t= 35\n":                              ThermalCritical,
+	} {
+		if got := parsePmsetTherm(out); got != want {
+			t.Errorf("parsePmsetTherm(%q) = %s, want %s", out, got, want)
+		}
+	}
+	if got := parsePowermetricsThermal("**** Thermal pressure ****\n\nCurrent pressure level: Heavy\n"); got != ThermalHeavy {
+		t.Errorf("parsePowermetricsThermal = %s", got)
+	}
+	if got := parsePowermetricsThermal("Current pressure level: Trapping\n"); got != ThermalCritical {
+		t.Errorf("parsePowermetricsThermal(Trapping) = %s", got)
+	}
+}
+
+func TestDetectLinuxPower(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, value string) {
+		path = filepath.Join(root, "class", path)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, []byte(value+"\n"), 0o644)
+	}
+	if info := detectLinuxPower(root); info != (PowerInfo{}) {
+		t.Errorf("a host without supplies or sensors: %+v", info)
+	}
+
+	write("power_supply/AC/type", "Mains")
+	write("power_supply/AC/online", "0")
+	write("power_supply/BAT0/type", "Battery")
+	write("power_supply/BAT0/status", "Discharging")
+	write("power_supply/BAT0/capacity", "45")
+	// a wireless mouse running flat must not count as the laptop's battery
+	write("power_supply/hidpp_battery_0/type", "Battery")
+	write("power_supply/hidpp_battery_0/scope", "Device")
+	write("power_supply/hidpp_battery_0/status", "Discharging")
+	write("power_supply/hidpp_battery_0/capacity", "5")
+	write("thermal/thermal_zone0/temp", "52000")
+	write("thermal/thermal_zone0/trip_point_0_type", "passive")
+	write("thermal/thermal_zone0/trip_point_0_temp", "95000")
+	write("thermal/thermal_zone1/temp", "90000")
+	write("thermal/thermal_zone1/trip_point_0_type", "critical")
+	write("thermal/thermal_zone1/trip_point_0_temp", "100000")
+	info := detectLinuxPower(root)
+	if info != (PowerInfo{Source: PowerBattery, BatteryPercent: 45, Thermal: ThermalHeavy}) || !info.Constrained() {
+		t.Errorf("unplugged and throttling: %+v", info)
+	}
+
+	write("power_supply/AC/online", "1")
+	write("power_supply/BAT0/status", "Charging")
+	write("thermal/thermal_zone1/temp", "60000")
+	if info := detectLinuxPower(root); info != (PowerInfo{Source: PowerAC, Thermal: ThermalNominal}) || info.Constrained() {
+		t.Errorf("plugged in and cool: %+v", info)
+	}
+}
+
+func TestPowerDownRanksHeavyVariants(t *testing.T) {
+	registry := &ModelRegistry{Models: []Model{{ID: "llama-3-8b", ContextWindow: 8192, Benchmarks: Benchmarks{MMLU: 66},
+		Variants: []Variant{
+			{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98},
+			{Quant: "Q8_0", SizeGB: 8.5, AccuracyRetention: 1.0},
+		}}}}
+	best := func(profile *HardwareProfile) ScoredVariant {
+		return profile.Recommend(registry, RecommendationRequest{})[0]
+	}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024, Power: PowerInfo{Source: PowerAC}}
+	if got := best(profile); got.Variant.Quant != "Q8_0" || strings.Contains(got.Reason, "Power") {
+		t.Errorf("on AC: %s (%s), want Q8_0", got.Variant.Quant, got.Reason)
+	}
+	profile.Power = PowerInfo{Source: PowerBattery, BatteryPercent: 60}
+	if got := best(profile); got.Variant.Quant != "Q4_K_M" || !strings.Contains(got.Reason, "Power(battery, 60%)") {
+		t.Errorf("on battery: %s (%s), want Q4_K_M", got.Variant.Quant, got.Reason)
+	}
+	profile.Power = PowerInfo{Source: PowerAC, Thermal: ThermalCritical}
+	if got := best(profile); got.Variant.Quant != "Q4_K_M" {
+		t.Errorf("throttling: %s (%s), want Q4_K_M", got.Variant.Quant, got.Reason)
+	}
+}
+
+func TestSpecPower(t *testing.T) {
+	profile, err := FromSpec([]byte("ram_gb: 16\npower:\n  on_battery: true\n  battery_percent: 15\n  thermal: moderate\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Power != (PowerInfo{Source: PowerBattery, BatteryPercent: 15, Thermal: ThermalModerate}) {
+		t.Errorf("power = %+v", profile.Power)
+	}
+	if _, err := FromSpec([]byte(`{"ram_gb": 16, "power": {"thermal": "toasty"}}`)); err == nil || !strings.Contains(err.Error(), "thermal") {
+		t.Errorf("unknown thermal pressure: %v", err)
+	}
+}
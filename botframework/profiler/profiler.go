@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os/exec"
 	"runtime"
-	"strconv"
 	"strings"
 )
 
@@ -12,8 +11,8 @@ import (
 type Tier string
 
 const (
-	TierElite    Tier = "Elite"    // NVIDIA GPU > 24GB VRAM
-	TierHigh     Tier = "High"     // NVIDIA GPU 8-24GB VRAM
+	TierElite    Tier = "Elite"    // NVIDIA, AMD or Intel GPU > 24GB VRAM
+	TierHigh     Tier = "High"     // NVIDIA, AMD or Intel GPU 8-24GB VRAM
 	TierApple    Tier = "Apple"    // Apple Silicon
 	TierBalanced Tier = "Balanced" // High RAM, Limited/No GPU
 	TierLegacy   Tier = "Legacy"   // Low RAM, No GPU
@@ -27,16 +26,37 @@ const (
 	EngineExLlamaV2 Engine = "exllamav2"
 	EngineMLX       Engine = "mlx"
 	EngineLlamaCPP  Engine = "llama_cpp"
+	// Intel GPUs through oneAPI
+	EngineLlamaCPPSYCL Engine = "llama_cpp_sycl"
+	EngineIPEXLLM      Engine = "ipex_llm"
 )
 
+// Engines lists every backend the manager can run
+var Engines = []Engine{EngineVLLM, EngineExLlamaV2, EngineMLX, EngineLlamaCPP, EngineLlamaCPPSYCL, EngineIPEXLLM}
+
 type HardwareProfile struct {
 	VRAM_MB      int
 	SystemRAM_MB int
-	HasCuda      bool
-	HasMetal     bool
-	HasROCm      bool
-	ComputeCap   float64 // e.g. 8.6 for RTX 30-series
-	CpuAVX512    bool
+	// SystemRAMAvailable_MB is the memory free for new processes at detection; 0 when unknown
+	SystemRAMAvailable_MB int
+	HasCuda               bool
+	HasMetal              bool
+	HasROCm               bool
+	HasOneAPI             bool    // Intel GPU; VRAM_MB is only set for discrete Arc and Data Center cards
+	ComputeCap            float64 // e.g. 8.6 for RTX 30-series
+	GPUArch               string  // AMD LLVM target, e.g. "gfx90a" or "gfx1100"
+	CpuAVX512             bool    // same as CPU.AVX512F
+	CPU                   CPUInfo
+	MIGDevices            []MIGDevice // populated when a GPU is partitioned with MIG
+	GPUs                  []GPUInfo   // every NVIDIA or AMD device; VRAM_MB is the largest one's
+	Disk                  *DiskInfo   // the model cache volume, set by DetectDisk
+	Power                 PowerInfo   // AC or battery and thermal pressure, set from DetectPower
+	Apple                 *AppleChip  // the Apple Silicon chip, set on Macs
+	// ReservedMB is held by models running alongside the chat model, such as the embedding
+	// worker, and is not available to the chat model
+	ReservedMB int
+	// ModelPolicy rules gated or non-commercial models out of recommendations
+	ModelPolicy ModelPolicy
 }
 
 // DetectHardware scans the system to populate the HardwareProfile
@@ -48,9 +68,11 @@ func DetectHardware() *HardwareProfile {
 	}
 
 	// 1. Detect System RAM
-	profile.SystemRAM_MB = detectSystemRAM()
+	profile.SystemRAM_MB, profile.SystemRAMAvailable_MB = detectSystemRAM()
+	profile.CPU = detectCPU()
+	profile.CpuAVX512 = profile.CPU.AVX512F
 
-	// 2. Detect GPU (Metal vs CUDA)
+	// 2. Detect GPU (Metal vs CUDA vs ROCm vs oneAPI)
 	switch runtime.GOOS {
 	case "darwin":
 		// Check for Apple Silicon (Metal)
@@ -61,50 +83,44 @@ func DetectHardware() *HardwareProfile {
 			// On Unified Memory architecture, VRAM ~= System RAM (minus OS overhead)
 			// We'll conservatively estimate 70% of system RAM is available for GPU
 			profile.VRAM_MB = int(float64(profile.SystemRAM_MB) * 0.7)
+			profile.Apple = detectAppleChip()
 		}
 	case "linux", "windows":
 		// Check for NVIDIA
-		// nvidia-smi --query-gpu=memory.total,compute_cap --format=csv,noheader,nounits
-		out, err := exec.Command("nvidia-smi", "--query-gpu=memory.total,compute_cap", "--format=csv,noheader,nounits").Output()
-		if err == nil {
-			parts := strings.Split(strings.TrimSpace(string(out)), ",")
-			if len(parts) >= 2 {
-				profile.HasCuda = true
-				vram, _ := strconv.Atoi(strings.TrimSpace(parts[0]))
-				profile.VRAM_MB = vram
-				cap, _ := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
-				profile.ComputeCap = cap
+		if gpus, err := detectNvidiaGPUs(); err == nil && len(gpus) > 0 {
+			profile.HasCuda = true
+			profile.GPUs = gpus
+			// Single-device placements are sized against the largest card
+			largest := gpus[0]
+			for _, gpu := range gpus[1:] {
+				if gpu.VRAM_MB > largest.VRAM_MB {
+					largest = gpu
+				}
+			}
+			profile.VRAM_MB = largest.VRAM_MB
+			profile.ComputeCap = largest.ComputeCap
+			// With MIG enabled a worker only sees its own slice, so size models against the largest slice
+			if migDevices := detectMIG(); len(migDevices) > 0 {
+				profile.MIGDevices = migDevices
+				profile.VRAM_MB = largestMIGMemoryMB(migDevices)
 			}
-		} else {
-			// Check for AMD GPU (ROCm)
-			_, err := exec.Command("rocm-smi", "--showid").Output()
-			if err == nil {
-				profile.HasROCm = true
-				// For ROCm, we could parse VRAM, but for simplicity, assume based on system RAM
-				// In production, parse rocm-smi output for VRAM
-				profile.VRAM_MB = int(float64(profile.SystemRAM_MB) * 0.5) // Conservative estimate
+		} else if gpus := detectROCm(); len(gpus) > 0 {
+			largest := largestAMDGPU(gpus)
+			profile.HasROCm = true
+			profile.VRAM_MB = largest.VRAM_MB
+			profile.GPUArch = largest.Arch
+			for i, gpu := range gpus {
+				profile.GPUs = append(profile.GPUs, GPUInfo{Index: i, VRAM_MB: gpu.VRAM_MB})
 			}
+		} else if gpu, ok := detectOneAPI(); ok {
+			profile.HasOneAPI = true
+			profile.VRAM_MB = gpu.VRAM_MB
 		}
 	}
 
 	return profile
 }
 
-func detectSystemRAM() int {
-	// Simplified detection logic
-	// In production, use a library like 'github.com/jaypipes/ghw' or 'github.com/shirou/gopsutil'
-	// This is a placeholder implementation
-	if runtime.GOOS == "darwin" {
-		out, err := exec.Command("sysctl", "-n", "hw.memsize").Output()
-		if err == nil {
-			bytes, _ := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
-			return int(bytes / 1024 / 1024)
-		}
-	}
-	// Default fallback
-	return 8192 // 8GB
-}
-
 // ClassifyTier determines the hardware tier based on the profile
 func (p *HardwareProfile) ClassifyTier() Tier {
 	if p.HasMetal {
@@ -114,7 +130,7 @@ func (p *HardwareProfile) ClassifyTier() Tier {
 	vramGB := p.VRAM_MB / 1024
 	ramGB := p.SystemRAM_MB / 1024
 
-	if p.HasCuda || p.HasROCm {
+	if p.HasCuda || p.HasROCm || p.HasOneAPI {
 		if vramGB >= 24 {
 			return TierElite
 		}
@@ -123,7 +139,15 @@ func (p *HardwareProfile) ClassifyTier() Tier {
 		}
 	}
 
-	if ramGB >= 32 {
+	// CPU inference needs memory for the model and vector units to run it at a usable speed;
+	// a many-core AVX-512 or AMX server makes up for less memory. Profiles without CPU
+	// details are judged by memory alone.
+	cpu := p.CPU
+	known := cpu.LogicalCores > 0
+	if ramGB >= 32 && (!known || cpu.HasVectorUnit()) {
+		return TierBalanced
+	}
+	if ramGB >= 16 && (cpu.AVX512F || cpu.AMX) && cpu.PhysicalCores >= 8 {
 		return TierBalanced
 	}
 
@@ -141,24 +165,62 @@ func (p *HardwareProfile) GetRecommendedEngine(modelSizeGB float64) Engine {
 	if p.HasCuda || p.HasROCm {
 		vramGB := float64(p.VRAM_MB) / 1024.0
 		
-		// "Elite" Rule: If we have massive VRAM headroom (>20% more than model), use vLLM
-		if vramGB > (modelSizeGB * 1.2) {
+		// "Elite" Rule: If we have massive VRAM headroom (>20% more than model), use vLLM.
+		// Its ROCm build only supports some AMD architectures.
+		if vramGB > (modelSizeGB*1.2) && p.SupportsVLLM() {
 			return EngineVLLM
 		}
 		
-		// "High" Rule: If it fits tightly, ExLlamaV2 is often more memory efficient/fast for single user
-		if vramGB >= modelSizeGB {
+		// "High" Rule: If it fits tightly, ExLlamaV2 is often more memory efficient/fast for single user.
+		// On AMD, llama.cpp's HIP build is the better-supported choice.
+		if vramGB >= modelSizeGB && p.HasCuda {
 			return EngineExLlamaV2
 		}
+
+		// "Multi-GPU" Rule: too big for one card, but vLLM can shard it with tensor parallelism
+		if _, ok := p.PlanTensorParallel(modelSizeGB * 1.2); ok && p.SupportsVLLM() {
+			return EngineVLLM
+		}
+	}
+
+	// 3. Intel Arc: IPEX-LLM when there is headroom, otherwise llama.cpp's SYCL build, which
+	// can also offload part of a model that does not fit
+	if p.hasArc() {
+		if float64(p.VRAM_MB)/1024.0 > modelSizeGB*1.2 {
+			return EngineIPEXLLM
+		}
+		return EngineLlamaCPPSYCL
 	}
 
-	// 3. Fallback (Balanced/Legacy)
+	// 4. Fallback (Balanced/Legacy)
 	// If it doesn't fit in VRAM, or no GPU, we use llama.cpp for CPU offloading
 	return EngineLlamaCPP
 }
 
 // String returns a summary of the profile
 func (p *HardwareProfile) String() string {
-	return fmt.Sprintf("RAM: %dMB, VRAM: %dMB, CUDA: %v, ROCm: %v, Metal: %v, Compute: %.1f", 
-		p.SystemRAM_MB, p.VRAM_MB, p.HasCuda, p.HasROCm, p.HasMetal, p.ComputeCap)
+	summary := fmt.Sprintf("RAM: %dMB, VRAM: %dMB, CUDA: %v, ROCm: %v, oneAPI: %v, Metal: %v, Compute: %.1f",
+		p.SystemRAM_MB, p.VRAM_MB, p.HasCuda, p.HasROCm, p.HasOneAPI, p.HasMetal, p.ComputeCap)
+	if p.SystemRAMAvailable_MB > 0 {
+		summary += fmt.Sprintf(", RAM available: %dMB", p.SystemRAMAvailable_MB)
+	}
+	if len(p.GPUs) > 1 {
+		summary += fmt.Sprintf(", GPUs: %d (%dMB total)", len(p.GPUs), p.TotalVRAM_MB())
+	}
+	if p.Disk != nil {
+		summary += ", " + p.Disk.String()
+	}
+	if p.CPU.LogicalCores > 0 {
+		summary += ", CPU: " + p.CPU.String()
+	}
+	if p.Apple != nil {
+		summary += fmt.Sprintf(", Chip: %s (%.0fGB/s)", p.Apple.Name, p.Apple.BandwidthGBs)
+	}
+	if p.GPUArch != "" {
+		summary += fmt.Sprintf(", Arch: %s", p.GPUArch)
+	}
+	if len(p.MIGDevices) > 0 {
+		summary += fmt.Sprintf(", MIG slices: %d", len(p.MIGDevices))
+	}
+	return summary
 }
@@ -0,0 +1,54 @@
+package profiler
+
+import "fmt"
+
+// Preference is what recommendations favour when models trade answer quality for speed
+type Preference string
+
+const (
+	PreferLatency  Preference = "latency"
+	PreferBalanced Preference = "balanced"
+	PreferQuality  Preference = "quality"
+)
+
+// Preferences lists the preferences a RecommendationRequest accepts
+var Preferences = []Preference{PreferLatency, PreferBalanced, PreferQuality}
+
+// RecommendationRequest describes the workload models are recommended for
+type RecommendationRequest struct {
+	// ContextLength is the context every sequence needs, in tokens; models with a shorter
+	// window are left out. 0 scores the default context without ruling any model out.
+	ContextLength int `json:"context_length,omitempty"`
+	// Concurrency is how many sequences are served at once, each with its own KV cache;
+	// 0 counts as 1
+	Concurrency int `json:"concurrency,omitempty"`
+	// Preference weighs latency against quality; empty is balanced
+	Preference Preference `json:"preference,omitempty"`
+}
+
+func (r RecommendationRequest) sequences() int {
+	return max(r.Concurrency, 1)
+}
+
+// fitsWindow reports whether model's context window holds the requested context; models
+// without a known window are given the benefit of the doubt
+func (r RecommendationRequest) fitsWindow(model Model) bool {
+	return r.ContextLength <= 0 || model.ContextWindow <= 0 || r.ContextLength <= model.ContextWindow
+}
+
+// preferenceScore moves a variant's score toward the request's preference. Decoding reads
+// every weight once per token, so latency favours light variants by 2 points per GB; quality
+// counts what quantization loses twice, once in the base score and once here.
+func (r RecommendationRequest) preferenceScore(variant Variant) (float64, string) {
+	var score float64
+	switch r.Preference {
+	case PreferLatency:
+		score = -2 * variant.SizeGB
+	case PreferQuality:
+		score = -100 * (1 - variant.AccuracyRetention)
+	}
+	if score == 0 {
+		return 0, ""
+	}
+	return score, fmt.Sprintf(", Pref %s: %.1f", r.Preference, score)
+}
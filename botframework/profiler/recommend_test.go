@@ -0,0 +1,62 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRecommendLeavesOutModelsTheContextOverflows(t *testing.T) {
+	gqa := &Architecture{HiddenSize: 4096, Layers: 32, KVHeads: 8, HeadDim: 128}
+	variants := []Variant{{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98}}
+	registry := &ModelRegistry{Models: []Model{
+		{ID: "llama-3.1-8b", ContextWindow: 131072, Benchmarks: Benchmarks{MMLU: 66}, Architecture: gqa, Variants: variants},
+		// without grouped-query attention 128k tokens take 64GB of cache
+		{ID: "mha-7b", ContextWindow: 131072, Benchmarks: Benchmarks{MMLU: 70},
+			Architecture: &Architecture{HiddenSize: 4096, Layers: 32}, Variants: variants},
+		{ID: "llama-3-8b", ContextWindow: 8192, Benchmarks: Benchmarks{MMLU: 68}, Architecture: gqa, Variants: variants},
+	}}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024}
+
+	ids := func(req RecommendationRequest) []string {
+		var ids []string
+		for _, ranked := range profile.Recommend(registry, req) {
+			ids = append(ids, ranked.ModelID)
+		}
+		return ids
+	}
+	if got := ids(RecommendationRequest{}); len(got) != 3 {
+		t.Fatalf("the default context should rank every model, got %v", got)
+	}
+	if got := ids(RecommendationRequest{ContextLength: 131072}); len(got) != 1 || got[0] != "llama-3.1-8b" {
+		t.Errorf("at 128k only the GQA model's 16GB cache fits beside its weights, got %v", got)
+	}
+	// four 32k sequences need 16GB of cache, eight need 32GB
+	if got := ids(RecommendationRequest{ContextLength: 32768, Concurrency: 4}); len(got) != 1 {
+		t.Errorf("four 32k sequences: got %v", got)
+	}
+	if got := ids(RecommendationRequest{ContextLength: 32768, Concurrency: 8}); len(got) != 0 {
+		t.Errorf("eight 32k sequences should not fit 24GB, got %v", got)
+	}
+
+	score, reason := profile.CalculateScoreFor(registry.Models[1], variants[0], RecommendationRequest{ContextLength: 131072})
+	if score != 0 || !strings.Contains(reason, "KV cache") {
+		t.Errorf("score %.1f, reason %q", score, reason)
+	}
+}
+
+func TestRecommendPreference(t *testing.T) {
+	registry := &ModelRegistry{Models: []Model{{ID: "llama-3-8b", ContextWindow: 8192, Benchmarks: Benchmarks{MMLU: 66},
+		Variants: []Variant{
+			{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98},
+			{Quant: "Q8_0", SizeGB: 8.5, AccuracyRetention: 0.99},
+		}}}}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024}
+
+	cases := map[Preference]string{PreferBalanced: "Q8_0", PreferQuality: "Q8_0", PreferLatency: "Q4_K_M"}
+	for preference, want := range cases {
+		ranked := profile.Recommend(registry, RecommendationRequest{Preference: preference})
+		if len(ranked) != 2 || ranked[0].Variant.Quant != want {
+			t.Errorf("%s: want %s first, got %+v", preference, want, ranked)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package profiler
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// vllmROCmArchs are the AMD architectures vLLM's ROCm build supports: MI200 (gfx90a),
+// MI300 (gfx942), Radeon RX 7900 (gfx1100/gfx1101) and RX 9000 (gfx1200/gfx1201).
+// llama.cpp's HIP build runs on any GPU ROCm can drive.
+var vllmROCmArchs = []string{"gfx90a", "gfx942", "gfx1100", "gfx1101", "gfx1200", "gfx1201"}
+
+// amdGPU is one AMD GPU found by detectROCm
+type amdGPU struct {
+	VRAM_MB int
+	Arch    string // e.g. "gfx1100"; empty when the tool does not report it
+}
+
+// detectROCm finds AMD GPUs via amd-smi, then rocm-smi, then sysfs on Linux. The sysfs
+// fallback finds cards even when the ROCm tools are not installed.
+func detectROCm() []amdGPU {
+	if out, err := exec.Command("amd-smi", "static", "--asic", "--vram", "--json").Output(); err == nil {
+		if gpus := parseAMDSMI(out); len(gpus) > 0 {
+			return gpus
+		}
+	}
+	if out, err := exec.Command("rocm-smi", "--showmeminfo", "vram", "--showproductname", "--json").Output(); err == nil {
+		if gpus := parseROCmSMI(out); len(gpus) > 0 {
+			return gpus
+		}
+	}
+	return detectAMDSysfs("/sys")
+}
+
+// largestAMDGPU returns the card with the most VRAM, which bounds what one worker can load
+func largestAMDGPU(gpus []amdGPU) amdGPU {
+	largest := gpus[0]
+	for _, gpu := range gpus[1:] {
+		if gpu.VRAM_MB > largest.VRAM_MB {
+			largest = gpu
+		}
+	}
+	return largest
+}
+
+// parseAMDSMI reads `amd-smi static --asic --vram --json`. Older releases report the VRAM
+// size as a string ("65536 MB"), newer ones as {"value": 65536, "unit": "MB"}.
+func parseAMDSMI(out []byte) []amdGPU {
+	var entries []struct {
+		ASIC struct {
+			TargetGraphicsVersion string `json:"target_graphics_version"`
+		} `json:"asic"`
+		VRAM struct {
+			Size json.RawMessage `json:"size"`
+		} `json:"vram"`
+	}
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil
+	}
+	var gpus []amdGPU
+	for _, entry := range entries {
+		gpus = append(gpus, amdGPU{VRAM_MB: parseVRAMSize(entry.VRAM.Size), Arch: entry.ASIC.TargetGraphicsVersion})
+	}
+	return gpus
+}
+
+func parseVRAMSize(raw json.RawMessage) int {
+	var size struct {
+		Value float64 `json:"value"`
+		Unit  string  `json:"unit"`
+	}
+	if err := json.Unmarshal(raw, &size); err != nil {
+		var text string
+		if json.Unmarshal(raw, &text) != nil {
+			return 0
+		}
+		value, unit, _ := strings.Cut(strings.TrimSpace(text), " ")
+		size.Value, _ = strconv.ParseFloat(value, 64)
+		size.Unit = unit
+	}
+	switch strings.ToUpper(size.Unit) {
+	case "GB":
+		return int(size.Value * 1024)
+	case "B":
+		return int(size.Value / 1024 / 1024)
+	default:
+		return int(size.Value)
+	}
+}
+
+// parseROCmSMI reads `rocm-smi --showmeminfo vram --showproductname --json`, which reports
+// one object per card with string values
+func parseROCmSMI(out []byte) []amdGPU {
+	var cards map[string]map[string]string
+	if err := json.Unmarshal(out, &cards); err != nil {
+		return nil
+	}
+	var gpus []amdGPU
+	for _, name := range slices.Sorted(maps.Keys(cards)) {
+		if !strings.HasPrefix(name, "card") {
+			continue
+		}
+		card := cards[name]
+		total, _ := strconv.ParseInt(card["VRAM Total Memory (B)"], 10, 64)
+		gpus = append(gpus, amdGPU{VRAM_MB: int(total / 1024 / 1024), Arch: card["GFX Version"]})
+	}
+	return gpus
+}
+
+var gfxTargetPattern = regexp.MustCompile(`(?m)^gfx_target_version (\d+)$`)
+
+// detectAMDSysfs reads VRAM from the amdgpu DRM nodes and architectures from the KFD
+// topology, for hosts without the ROCm tools. Nodes without a GPU report target version 0.
+func detectAMDSysfs(root string) []amdGPU {
+	var gpus []amdGPU
+	cards, _ := filepath.Glob(filepath.Join(root, "class", "drm", "card*", "device", "vendor"))
+	for _, vendorFile := range cards {
+		vendor, err := os.ReadFile(vendorFile)
+		if err != nil || strings.TrimSpace(string(vendor)) != "0x1002" {
+			continue
+		}
+		total, err := os.ReadFile(filepath.Join(filepath.Dir(vendorFile), "mem_info_vram_total"))
+		if err != nil {
+			continue
+		}
+		bytes, _ := strconv.ParseInt(strings.TrimSpace(string(total)), 10, 64)
+		gpus = append(gpus, amdGPU{VRAM_MB: int(bytes / 1024 / 1024)})
+	}
+
+	var archs []string
+	nodes, _ := filepath.Glob(filepath.Join(root, "class", "kfd", "kfd", "topology", "nodes", "*", "properties"))
+	slices.Sort(nodes)
+	for _, node := range nodes {
+		properties, err := os.ReadFile(node)
+		if err != nil {
+			continue
+		}
+		if m := gfxTargetPattern.FindSubmatch(properties); m != nil {
+			version, _ := strconv.Atoi(string(m[1]))
+			if version > 0 {
+				archs = append(archs, gfxArch(version))
+			}
+		}
+	}
+	// KFD lists GPU nodes in the same order as the DRM cards
+	for i := range gpus {
+		if i < len(archs) {
+			gpus[i].Arch = archs[i]
+		}
+	}
+	return gpus
+}
+
+// gfxArch converts a KFD gfx_target_version (major*10000 + minor*100 + stepping) to the LLVM
+// target name, whose stepping digit is hexadecimal: 90010 is gfx90a, 110000 is gfx1100
+func gfxArch(version int) string {
+	return fmt.Sprintf("gfx%d%d%x", version/10000, version/100%100, version%100)
+}
+
+// vllmSupportsROCm reports whether vLLM's ROCm build runs on the profile's GPU
+func (p *HardwareProfile) vllmSupportsROCm() bool {
+	return slices.Contains(vllmROCmArchs, p.GPUArch)
+}
+
+// SupportsVLLM reports whether vLLM runs on the profile's GPUs: NVIDIA's, or AMD
+// architectures its ROCm build supports
+func (p *HardwareProfile) SupportsVLLM() bool {
+	return p.HasCuda || p.vllmSupportsROCm()
+}
@@ -0,0 +1,84 @@
+package profiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseAMDSMI(t *testing.T) {
+	out := `[{"gpu": 0, "asic": {"market_name": "AMD Instinct MI210", "target_graphics_version": "gfx90a"}, "vram": {"type": "HBM", "size": {"value": 65520, "unit": "MB"}}},
+	         {"gpu": 1, "asic": {"target_graphics_version": "gfx1100"}, "vram": {"size": "24560 MB"}}]`
+	gpus := parseAMDSMI([]byte(out))
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 GPUs, got %d", len(gpus))
+	}
+	if gpus[0].VRAM_MB != 65520 || gpus[0].Arch != "gfx90a" {
+		t.Fatalf("unexpected first GPU: %+v", gpus[0])
+	}
+	if gpus[1].VRAM_MB != 24560 || gpus[1].Arch != "gfx1100" {
+		t.Fatalf("unexpected second GPU: %+v", gpus[1])
+	}
+	if got := largestAMDGPU(gpus); got.Arch != "gfx90a" {
+		t.Fatalf("expected the MI210 to be largest, got %+v", got)
+	}
+}
+
+func TestParseROCmSMI(t *testing.T) {
+	out := `{"card0": {"VRAM Total Memory (B)": "25753026560", "VRAM Total Used Memory (B)": "28672000", "Card series": "Navi 31", "GFX Version": "gfx1100"}, "system": {"Driver version": "6.7.0"}}`
+	gpus := parseROCmSMI([]byte(out))
+	if len(gpus) != 1 || gpus[0].VRAM_MB != 24560 || gpus[0].Arch != "gfx1100" {
+		t.Fatalf("unexpected GPUs: %+v", gpus)
+	}
+}
+
+func TestDetectAMDSysfs(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("class/drm/card0/device/vendor", "0x8086\n")
+	write("class/drm/card1/device/vendor", "0x1002\n")
+	write("class/drm/card1/device/mem_info_vram_total", "17163091968\n")
+	write("class/kfd/kfd/topology/nodes/0/properties", "cpu_cores_count 16\ngfx_target_version 0\n")
+	write("class/kfd/kfd/topology/nodes/1/properties", "simd_count 120\ngfx_target_version 90010\n")
+
+	gpus := detectAMDSysfs(root)
+	if len(gpus) != 1 || gpus[0].VRAM_MB != 16368 || gpus[0].Arch != "gfx90a" {
+		t.Fatalf("unexpected GPUs: %+v", gpus)
+	}
+}
+
+func TestGfxArch(t *testing.T) {
+	cases := map[int]string{90010: "gfx90a", 90402: "gfx942", 110000: "gfx1100", 100300: "gfx1030"}
+	for version, want := range cases {
+		if got := gfxArch(version); got != want {
+			t.Errorf("%d: want %s, got %s", version, want, got)
+		}
+	}
+}
+
+func TestRecommendedEngineOnROCm(t *testing.T) {
+	mi210 := &HardwareProfile{HasROCm: true, VRAM_MB: 64 * 1024, GPUArch: "gfx90a"}
+	if got := mi210.GetRecommendedEngine(5.5); got != EngineVLLM {
+		t.Fatalf("expected vLLM on gfx90a, got %s", got)
+	}
+	if tier := mi210.ClassifyTier(); tier != TierElite {
+		t.Fatalf("expected Elite tier, got %s", tier)
+	}
+
+	// vLLM has no ROCm build for RDNA2, and ExLlamaV2 is CUDA-first
+	rx6800 := &HardwareProfile{HasROCm: true, VRAM_MB: 16 * 1024, GPUArch: "gfx1030"}
+	if got := rx6800.GetRecommendedEngine(5.5); got != EngineLlamaCPP {
+		t.Fatalf("expected llama.cpp on gfx1030, got %s", got)
+	}
+	if tier := rx6800.ClassifyTier(); tier != TierHigh {
+		t.Fatalf("expected High tier, got %s", tier)
+	}
+}
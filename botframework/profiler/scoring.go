@@ -7,13 +7,21 @@ import (
 	"math"
 	"os"
 	"sort"
+	"strings"
 )
 
 // ModelRegistry represents the JSON structure of available models
 type ModelRegistry struct {
-	Models []Model `json:"models"`
+	// SchemaVersion is the registry format; files without one predate versioning (version 1)
+	SchemaVersion int     `json:"schema_version,omitempty"`
+	Models        []Model `json:"models"`
+	// EmbeddingModels serve /v1/embeddings; they are ranked by RecommendEmbeddingModels
+	EmbeddingModels []Model `json:"embedding_models,omitempty"`
 }
 
+// RegistrySchemaVersion is the newest registry format this build understands
+const RegistrySchemaVersion = 1
+
 type Model struct {
 	ID            string    `json:"id"`
 	Name          string    `json:"name"`
@@ -22,17 +30,118 @@ type Model struct {
 	ContextWindow int       `json:"context_window"`
 	Benchmarks    Benchmarks `json:"benchmarks"`
 	Variants      []Variant `json:"variants"`
+	// HFRepo is the Hugging Face repository the variants are downloaded from
+	HFRepo string `json:"hf_repo,omitempty"`
+	// TokenizerRepo holds the tokenizer.json used to count tokens when HFRepo has none,
+	// as GGUF repositories rarely do; empty means HFRepo
+	TokenizerRepo string `json:"tokenizer_repo,omitempty"`
+	// Architecture sizes the KV cache; without it the cache is estimated from ParamsB
+	Architecture *Architecture `json:"architecture,omitempty"`
+	// Dimensions is the vector size of an embedding model; chat models leave it 0
+	Dimensions int `json:"dimensions,omitempty"`
+	// Tokenizer names the vocabulary; models sharing one can draft for each other
+	Tokenizer string `json:"tokenizer,omitempty"`
+	// License is the model's license identifier, e.g. "apache-2.0" or "llama3"
+	License string `json:"license,omitempty"`
+	// NonCommercial marks licenses that forbid commercial use; CC BY-NC licenses are
+	// recognised without it
+	NonCommercial bool `json:"non_commercial,omitempty"`
+	// Gated repositories need the license accepted on Hugging Face and HF_TOKEN to download
+	Gated bool `json:"gated,omitempty"`
+}
+
+// IsEmbedding reports whether m is an embedding model
+func (m Model) IsEmbedding() bool {
+	return m.Dimensions > 0
+}
+
+// Architecture holds the attention dimensions that decide the KV cache's size
+type Architecture struct {
+	HiddenSize int `json:"hidden_size"`
+	Layers     int `json:"layers"`
+	// KVHeads is below the attention head count for grouped-query attention
+	KVHeads int `json:"kv_heads"`
+	HeadDim int `json:"head_dim"`
+	// QuantizedKV is set when the engines can store the cache as q8_0
+	QuantizedKV bool `json:"quantized_kv"`
+}
+
+// Bytes per cached element: f16, and q8_0's 32 one-byte values plus a 2-byte scale per block
+const (
+	kvBytesF16 = 2.0
+	kvBytesQ8  = 34.0 / 32.0
+)
+
+// DefaultScoringContext is the context length scored when none is requested
+const DefaultScoringContext = 4096
+
+// KVCacheGB is the memory the KV cache of one sequence of contextTokens takes, storing
+// bytesPerElement per cached value. Models without architecture metadata are estimated
+// at 0.5GB (1GB above 10B parameters) per 4k tokens.
+func (m Model) KVCacheGB(contextTokens int, bytesPerElement float64) float64 {
+	arch := m.Architecture
+	if arch == nil || arch.Layers == 0 {
+		perWindow := 0.5
+		if m.ParamsB > 10 {
+			perWindow = 1.0
+		}
+		return perWindow * float64(contextTokens) / DefaultScoringContext * bytesPerElement / kvBytesF16
+	}
+	// each layer caches a key and a value vector per token; without grouped-query metadata
+	// those vectors are as wide as the hidden state
+	width := arch.KVHeads * arch.HeadDim
+	if width == 0 {
+		width = arch.HiddenSize
+	}
+	bytes := 2 * float64(arch.Layers) * float64(width) * float64(contextTokens) * bytesPerElement
+	return bytes / (1 << 30)
+}
+
+// kvCacheBeside is the KV cache of contextTokens engines allocate with freeGB left after the
+// weights: f16, or q8_0 when that leaves too little room and the model supports it. note
+// is " q8_0" for the quantized cache.
+func (m Model) kvCacheBeside(contextTokens int, freeGB float64) (gb float64, note string) {
+	gb = m.KVCacheGB(contextTokens, kvBytesF16)
+	if m.Architecture != nil && m.Architecture.QuantizedKV && freeGB-gb <= 0.5 {
+		return m.KVCacheGB(contextTokens, kvBytesQ8), " q8_0"
+	}
+	return gb, ""
+}
+
+// scoringContext clamps the requested context length to the model's window; 0 scores the
+// default, or the whole window when it is shorter
+func (m Model) scoringContext(requested int) int {
+	if requested <= 0 {
+		requested = DefaultScoringContext
+	}
+	if m.ContextWindow > 0 && requested > m.ContextWindow {
+		return m.ContextWindow
+	}
+	return requested
 }
 
 type Benchmarks struct {
 	MMLU  float64 `json:"mmlu"`
 	GSM8K float64 `json:"gsm8k"`
+	// MTEB is the average MTEB score of an embedding model
+	MTEB float64 `json:"mteb,omitempty"`
 }
 
 type Variant struct {
 	Quant             string  `json:"quant"`
 	SizeGB            float64 `json:"size_gb"`
 	AccuracyRetention float64 `json:"accuracy_retention"`
+	// File pins the repository file when its name does not contain the quant
+	File string `json:"file,omitempty"`
+	// Format is how the weights are stored; empty is GGUF
+	Format ModelFormat `json:"format,omitempty"`
+	SHA256 string      `json:"sha256,omitempty"` // overrides the checksum reported by the hub
+	// Sources are mirrors of the variant's file, tried in order before Hugging Face
+	Sources []Source `json:"sources,omitempty"`
+	// Measured is the throughput probed on this host, attached by Measurements.ApplySpeed
+	Measured *SpeedMeasurement `json:"-"`
+	// Failures are the loads of this variant that failed, attached by Measurements.ApplyFailures
+	Failures []LoadFailure `json:"-"`
 }
 
 // ScoredVariant wraps a variant with its calculated score
@@ -42,6 +151,11 @@ type ScoredVariant struct {
 	Variant   Variant
 	Score     float64
 	Reason    string
+	// RelativeEnergy is the estimated energy per 1k tokens relative to the
+	// model's largest variant (1.0 = same energy, 0.6 = 40% less)
+	RelativeEnergy float64
+	// Draft is the speculative-decoding pairing on High and Elite hosts, nil without one
+	Draft *DraftPlan
 }
 
 // LoadRegistry reads the model classification JSON
@@ -56,29 +170,89 @@ func LoadRegistry(path string) (*ModelRegistry, error) {
 	if err != nil {
 		return nil, err
 	}
+	return ParseRegistry(bytes)
+}
 
+// ParseRegistry decodes a registry, rejecting schema versions newer than this build, models
+// without an ID and mirrors without a checksum
+func ParseRegistry(data []byte) (*ModelRegistry, error) {
 	var registry ModelRegistry
-	if err := json.Unmarshal(bytes, &registry); err != nil {
+	if err := json.Unmarshal(data, &registry); err != nil {
 		return nil, err
 	}
-
+	if registry.SchemaVersion > RegistrySchemaVersion {
+		return nil, fmt.Errorf("registry schema version %d is newer than the supported %d; upgrade botframework",
+			registry.SchemaVersion, RegistrySchemaVersion)
+	}
+	for i, model := range registry.Models {
+		if model.ID == "" {
+			return nil, fmt.Errorf("registry model %d has no id", i)
+		}
+		if err := validateSources(model); err != nil {
+			return nil, err
+		}
+	}
+	for i, model := range registry.EmbeddingModels {
+		if model.ID == "" || model.Dimensions <= 0 {
+			return nil, fmt.Errorf("registry embedding model %d needs an id and dimensions", i)
+		}
+		if err := validateSources(model); err != nil {
+			return nil, err
+		}
+	}
 	return &registry, nil
 }
 
 // RecommendModels ranks models based on the hardware profile
 func (p *HardwareProfile) RecommendModels(registry *ModelRegistry) []ScoredVariant {
+	return p.RecommendModelsAt(registry, 0)
+}
+
+// RecommendModelsAt ranks models for workloads of contextTokens (0 for the default)
+func (p *HardwareProfile) RecommendModelsAt(registry *ModelRegistry, contextTokens int) []ScoredVariant {
+	return p.Recommend(registry, RecommendationRequest{ContextLength: contextTokens})
+}
+
+// Recommend ranks models for the workload req describes. Models whose context window is
+// shorter than the requested context, and variants whose KV cache for it would not fit
+// beside the weights, are left out. With the model cache's disk profiled, variants that are
+// not downloaded and would not fit on it are left out, and ones that load slowly from it
+// say so. Models the profile's ModelPolicy rules out are never recommended, and variants in
+// a format other than GGUF only where the engine recommended for them loads it.
+func (p *HardwareProfile) Recommend(registry *ModelRegistry, req RecommendationRequest) []ScoredVariant {
 	var recommendations []ScoredVariant
 
 	for _, model := range registry.Models {
+		if !p.ModelPolicy.Allows(model) || !req.fitsWindow(model) {
+			continue
+		}
+		largest := largestVariant(model)
 		for _, variant := range model.Variants {
-			score, reason := p.CalculateScore(model, variant)
+			if !p.Disk.fits(model.ID, variant) || !p.loads(variant) {
+				continue
+			}
+			score, reason := p.CalculateScoreFor(model, variant, req)
 			if score > 0 {
+				reason += p.Disk.loadNote(variant.SizeGB)
+				relativeEnergy := 1.0
+				if largest.SizeGB > 0 {
+					relativeEnergy = variant.SizeGB / largest.SizeGB
+				}
+				if relativeEnergy < 0.95 {
+					reason += fmt.Sprintf(", ~%.0f%% less energy per 1k tokens than %s", (1-relativeEnergy)*100, largest.Quant)
+				}
+				draft, ok := p.PlanDraft(registry, model, variant, req.ContextLength)
+				if ok {
+					reason += ", " + draft.String()
+				}
 				recommendations = append(recommendations, ScoredVariant{
-					ModelID:   model.ID,
-					ModelName: model.Name,
-					Variant:   variant,
-					Score:     score,
-					Reason:    reason,
+					ModelID:        model.ID,
+					ModelName:      model.Name,
+					Variant:        variant,
+					Score:          score,
+					Reason:         reason,
+					RelativeEnergy: relativeEnergy,
+					Draft:          draft,
 				})
 			}
 		}
@@ -92,28 +266,70 @@ func (p *HardwareProfile) RecommendModels(registry *ModelRegistry) []ScoredVaria
 	return recommendations
 }
 
+// largestVariant returns the heaviest variant of a model. Decoding is memory-bandwidth
+// bound, so energy per token scales roughly with the bytes of weights read per token.
+func largestVariant(model Model) Variant {
+	var largest Variant
+	for _, v := range model.Variants {
+		if v.SizeGB > largest.SizeGB {
+			largest = v
+		}
+	}
+	return largest
+}
+
 // CalculateScore implements the scoring logic defined in the spec
 func (p *HardwareProfile) CalculateScore(model Model, variant Variant) (float64, string) {
+	return p.CalculateScoreAt(model, variant, 0)
+}
+
+// CalculateScoreAt scores a variant serving contextTokens (0 for the default), leaving room
+// for that context's KV cache
+func (p *HardwareProfile) CalculateScoreAt(model Model, variant Variant, contextTokens int) (float64, string) {
+	return p.CalculateScoreFor(model, variant, RecommendationRequest{ContextLength: contextTokens})
+}
+
+// CalculateScoreFor scores a variant serving the workload req describes. Every concurrent
+// sequence needs its own KV cache; a variant that fits but not beside those caches scores 0.
+// Requests beyond the model's window are scored at the window.
+func (p *HardwareProfile) CalculateScoreFor(model Model, variant Variant, req RecommendationRequest) (float64, string) {
 	// 1. Size Score (Can we even load it?)
 	// Available memory for model (leaving buffer for OS)
-	// If Metal, we use VRAM (which is shared RAM). If CUDA, VRAM.
+	// If Metal, we use VRAM (which is shared RAM). If CUDA, ROCm or Arc, VRAM.
 	// If CPU only (Legacy), we use System RAM.
 	
-	availableMemGB := float64(p.VRAM_MB) / 1024.0
-	if !p.HasCuda && !p.HasMetal {
-		// Fallback to System RAM for CPU inference
-		availableMemGB = float64(p.SystemRAM_MB) / 1024.0
-	}
-
-	// Buffer: 2GB for OS/Display
-	safeMemGB := availableMemGB - 2.0
-	if safeMemGB < 0 {
-		safeMemGB = 0.5 // Minimal fallback
+	// Large models may still fit split across several GPUs, at a throughput cost for the
+	// cross-device all-reduces that is far smaller over NVLink than over PCIe. The budget
+	// leaves a 2GB buffer for the OS and display, and room for the KV cache of the
+	// requested context, quantized to q8_0 when f16 leaves too little and the model
+	// supports it.
+	budget := p.Budget(model, variant, req)
+	tpPenalty := 0.0
+	tpNote := ""
+	if plan := budget.TensorParallel; plan != nil {
+		tpPenalty = 15.0
+		if plan.NVLink {
+			tpPenalty = 5.0
+		}
+		tpNote = ", " + plan.String()
 	}
 
-	// Hard cutoff: If model is bigger than available memory, score 0
-	if variant.SizeGB > availableMemGB {
-		return 0, "Insufficient Memory"
+	// Hard cutoff: If model is bigger than available memory, score 0. On a discrete GPU
+	// llama.cpp can still run a GGUF that does not fit by leaving some layers on the CPU,
+	// scored by the decode rate the split is predicted to reach, with system RAM for headroom.
+	kvCacheGB, kvNote, contextTokens := budget.KVCacheGB, budget.kvNote(), budget.ContextTokens
+	remainingHeadroom := budget.HeadroomGB
+	offloadScore, offloadNote := 0.0, ""
+	switch budget.Limit {
+	case LimitWeights:
+		plan, headroomGB, ok := p.partialOffload(model, variant, contextTokens)
+		if !ok {
+			return 0, "Insufficient Memory"
+		}
+		remainingHeadroom = headroomGB
+		offloadScore, offloadNote = plan.score(variant.Measured)
+	case LimitKVCache:
+		return 0, fmt.Sprintf("Insufficient Memory for the KV cache (KV%s: %.1fGB at %dk)", kvNote, kvCacheGB, contextTokens/1024)
 	}
 
 	// 2. Efficiency Density Score
@@ -125,16 +341,6 @@ func (p *HardwareProfile) CalculateScore(model Model, variant Variant) (float64,
 	// 3. Memory Fit Bonus/Penalty
 	// If it fits comfortably (leaving room for KV cache), boost score.
 	// If it fits tightly, penalize.
-	
-	// KV Cache estimation (simplified from spec formula for 4k context)
-	// VRAM_KV approx 0.5GB for 7B model at 4k context (very rough estimate)
-	kvCacheEstGB := 0.5 
-	if model.ParamsB > 10 {
-		kvCacheEstGB = 1.0
-	}
-
-	remainingHeadroom := safeMemGB - variant.SizeGB - kvCacheEstGB
-	
 	memoryScore := 0.0
 	if remainingHeadroom > 2.0 {
 		// Lots of room, great for long context
@@ -158,13 +364,90 @@ func (p *HardwareProfile) CalculateScore(model Model, variant Variant) (float64,
 		hwBonus += 5.0
 	}
 
-	finalScore := baseScore + memoryScore + hwBonus
+	// 5. Empirical Speed
+	// Specs miss bottlenecks such as slow RAM, so a probed variant is judged by its measured
+	// decode rate against a comfortable reading speed, and by how long it takes to read
+	// the scored context. On Apple Silicon an unprobed variant's decode rate is estimated
+	// from the chip's memory bandwidth.
+	speedScore, speedNote := variant.Measured.score(contextTokens)
+	if variant.Measured == nil {
+		speedScore, speedNote = p.bandwidthScore(variant)
+	}
+
+	// 6. Workload Preference
+	prefScore, prefNote := req.preferenceScore(variant)
+
+	// 7. Power
+	// On battery or while throttling for heat, lighter variants are worth more than the
+	// accuracy the heavy ones add
+	powerScore, powerNote := p.Power.score(variant)
+
+	// 8. Observed Failures
+	// A variant that already ran out of memory here at this context will again; one that
+	// failed in other conditions is trusted less
+	failureScore, failureNote, excluded := variant.failureScore(p.Fingerprint(), contextTokens)
+	if excluded {
+		return 0, failureNote
+	}
+
+	finalScore := baseScore + memoryScore + hwBonus + speedScore + offloadScore + prefScore + powerScore + failureScore - tpPenalty
 
 	// Cap at 100, min 0
 	finalScore = math.Min(100, math.Max(0, finalScore))
 
-	reason := fmt.Sprintf("Base: %.1f, MemBonus: %.1f, HWBonus: %.1f (Headroom: %.1fGB)", 
-		baseScore, memoryScore, hwBonus, remainingHeadroom)
+	reason := fmt.Sprintf("Base: %.1f, MemBonus: %.1f, HWBonus: %.1f (Headroom: %.1fGB, KV%s: %.1fGB at %dk)%s%s%s%s%s%s",
+		baseScore, memoryScore, hwBonus, remainingHeadroom, kvNote, kvCacheGB, contextTokens/1024, tpNote, offloadNote, speedNote, prefNote, powerNote, failureNote)
 
 	return finalScore, reason
 }
+
+// loads reports whether the engine recommended for variant loads its weights. GGUF, the
+// registry's own format, is always offered.
+func (p *HardwareProfile) loads(variant Variant) bool {
+	if variant.Format == "" || variant.Format == FormatGGUF {
+		return true
+	}
+	return EngineCapabilities[p.GetRecommendedEngine(variant.SizeGB)].Supports(ModelFiles{Format: variant.Format})
+}
+
+// modelMemoryGB is the memory a model may use: VRAM on GPU hosts, system RAM for CPU
+// inference, less what models running alongside hold (ReservedMB)
+func (p *HardwareProfile) modelMemoryGB() float64 {
+	availableMemGB := float64(p.VRAM_MB) / 1024.0
+	if !p.HasCuda && !p.HasMetal && !p.HasROCm && !p.hasArc() {
+		// Fallback to System RAM for CPU inference. Memory other processes already hold is
+		// not available; the 2GB OS buffer below is added back since it is counted in there.
+		availableMemGB = float64(p.SystemRAM_MB) / 1024.0
+		if p.SystemRAMAvailable_MB > 0 {
+			availableMemGB = math.Min(availableMemGB, float64(p.SystemRAMAvailable_MB)/1024.0+2.0)
+		}
+	}
+	return availableMemGB - float64(p.ReservedMB)/1024.0
+}
+
+// Lookup returns the registry model that name refers to, matching served names such as
+// "llama-3-8b-instruct-q4_k_m" by their longest model ID prefix. Embedding models are
+// matched too. It returns nil for unknown models.
+func (r *ModelRegistry) Lookup(name string) *Model {
+	name = strings.ToLower(name)
+	var found *Model
+	best := 0
+	for _, models := range [][]Model{r.Models, r.EmbeddingModels} {
+		for i := range models {
+			id := strings.ToLower(models[i].ID)
+			if strings.HasPrefix(name, id) && len(id) > best {
+				best, found = len(id), &models[i]
+			}
+		}
+	}
+	return found
+}
+
+// ContextWindow returns the context length of the registry model that name refers to,
+// or 0 for unknown models
+func (r *ModelRegistry) ContextWindow(name string) int {
+	if model := r.Lookup(name); model != nil {
+		return model.ContextWindow
+	}
+	return 0
+}
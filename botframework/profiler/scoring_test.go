@@ -0,0 +1,86 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContextWindowMatchesLongestPrefix(t *testing.T) {
+	registry := &ModelRegistry{Models: []Model{
+		{ID: "llama-3-8b", ContextWindow: 8192},
+		{ID: "llama-3-8b-instruct-262k", ContextWindow: 262144},
+		{ID: "phi-3-mini-4k", ContextWindow: 4096},
+	}}
+
+	cases := map[string]int{
+		"llama-3-8b-instruct-q4_k_m":    8192,
+		"Llama-3-8B-Instruct-262k-Q8_0": 262144,
+		"phi-3-mini-4k":                 4096,
+		"mistral-7b":                    0,
+	}
+	for name, want := range cases {
+		if got := registry.ContextWindow(name); got != want {
+			t.Errorf("%s: want %d, got %d", name, want, got)
+		}
+	}
+}
+
+func TestKVCacheGrowsWithContext(t *testing.T) {
+	llama := Model{ParamsB: 8, Architecture: &Architecture{HiddenSize: 4096, Layers: 32, KVHeads: 8, HeadDim: 128}}
+	// 2 (K and V) * 32 layers * 1024 wide * 2 bytes = 128KiB per token
+	if got := llama.KVCacheGB(8192, kvBytesF16); got != 1.0 {
+		t.Errorf("llama 3 8B at 8k: %.3fGB, want 1GB", got)
+	}
+	if got := llama.KVCacheGB(32768, kvBytesF16); got != 4.0 {
+		t.Errorf("llama 3 8B at 32k: %.3fGB, want 4GB", got)
+	}
+
+	// without grouped-query metadata the cache is as wide as the hidden state
+	mha := Model{Architecture: &Architecture{HiddenSize: 4096, Layers: 32}}
+	if got := mha.KVCacheGB(8192, kvBytesF16); got != 4.0 {
+		t.Errorf("multi-head attention at 8k: %.3fGB, want 4GB", got)
+	}
+	if got := (Model{ParamsB: 13}).KVCacheGB(8192, kvBytesF16); got != 2.0 {
+		t.Errorf("unknown 13B architecture at 8k: %.3fGB, want 2GB", got)
+	}
+}
+
+func TestScoringLeavesRoomForRequestedContext(t *testing.T) {
+	model := Model{ParamsB: 7, ContextWindow: 65536, Benchmarks: Benchmarks{MMLU: 60},
+		Architecture: &Architecture{HiddenSize: 4096, Layers: 32, KVHeads: 8, HeadDim: 128}}
+	variant := Variant{Quant: "Q4_K_M", SizeGB: 4.3, AccuracyRetention: 0.97}
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 10240}
+
+	short, _ := profile.CalculateScoreAt(model, variant, 4096)
+	long, reason := profile.CalculateScoreAt(model, variant, 32768)
+	if long >= short {
+		t.Errorf("a 32k context (4GB of cache) should score below 4k: %.1f vs %.1f", long, short)
+	}
+	if !strings.Contains(reason, "at 32k") {
+		t.Errorf("reason = %q", reason)
+	}
+
+	// a q8_0 cache roughly halves the 32k cache, which then fits
+	model.Architecture.QuantizedKV = true
+	quantized, reason := profile.CalculateScoreAt(model, variant, 32768)
+	if quantized <= long || !strings.Contains(reason, "KV q8_0") {
+		t.Errorf("quantized KV: %.1f %q", quantized, reason)
+	}
+
+	// requests beyond the window are scored at the window
+	if _, reason := profile.CalculateScoreAt(model, variant, 1<<20); !strings.Contains(reason, "at 64k") {
+		t.Errorf("reason = %q", reason)
+	}
+}
+
+func TestParseRegistryChecksSchema(t *testing.T) {
+	if _, err := ParseRegistry([]byte(`{"models":[{"id":"phi-3-mini-4k"}]}`)); err != nil {
+		t.Errorf("a registry without schema_version should load: %v", err)
+	}
+	if _, err := ParseRegistry([]byte(`{"schema_version":2,"models":[]}`)); err == nil {
+		t.Error("expected a newer schema version to be rejected")
+	}
+	if _, err := ParseRegistry([]byte(`{"models":[{"name":"unnamed"}]}`)); err == nil {
+		t.Error("expected a model without an id to be rejected")
+	}
+}
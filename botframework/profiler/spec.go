@@ -0,0 +1,172 @@
+package profiler
+
+import (
+	"botframework/config/yaml"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Spec describes hardware the way a spec sheet does. FromSpec turns it into the profile
+// detection would produce on that machine, to ask what would be recommended there.
+type Spec struct {
+	// GPU is the vendor: nvidia, amd, intel or apple; empty for CPU-only machines
+	GPU string `json:"gpu,omitempty" yaml:"gpu"`
+	// GPUCount is the number of identical cards, 1 when unset
+	GPUCount int `json:"gpu_count,omitempty" yaml:"gpu_count"`
+	// VRAMGB is each card's memory; Apple GPUs share 70% of RAM unless it is set
+	VRAMGB     float64 `json:"vram_gb,omitempty" yaml:"vram_gb"`
+	ComputeCap float64 `json:"compute_cap,omitempty" yaml:"compute_cap"`
+	// Chip is the Apple Silicon chip, e.g. "M2 Ultra", whose bandwidth estimates decode speed
+	Chip string `json:"chip,omitempty" yaml:"chip"`
+	// GPUArch is the AMD LLVM target, e.g. "gfx1100"
+	GPUArch string  `json:"gpu_arch,omitempty" yaml:"gpu_arch"`
+	NVLink  bool    `json:"nvlink,omitempty" yaml:"nvlink"`
+	RAMGB   float64 `json:"ram_gb" yaml:"ram_gb"`
+	// CPUCores is the physical core count
+	CPUCores int `json:"cpu_cores,omitempty" yaml:"cpu_cores"`
+	// CPUFeatures are the vector extensions, from cpuFeatures; x86 machines default to AVX2
+	// and arm64 ones to NEON
+	CPUFeatures []string `json:"cpu_features,omitempty" yaml:"cpu_features"`
+	// Disk is the model cache's drive; without a class the disk is left unprofiled
+	Disk DiskSpec `json:"disk" yaml:"disk"`
+	// Power simulates a laptop unplugged or throttling; unset is a machine on AC
+	Power PowerSpec `json:"power" yaml:"power"`
+}
+
+type DiskSpec struct {
+	Class  DiskClass `json:"class,omitempty" yaml:"class"`
+	FreeGB float64   `json:"free_gb,omitempty" yaml:"free_gb"`
+}
+
+type PowerSpec struct {
+	OnBattery      bool `json:"on_battery,omitempty" yaml:"on_battery"`
+	BatteryPercent int  `json:"battery_percent,omitempty" yaml:"battery_percent"`
+	// Thermal is the pressure level: nominal, moderate, heavy or critical
+	Thermal ThermalPressure `json:"thermal,omitempty" yaml:"thermal"`
+}
+
+// SpecPresets are simulated profiles of common machines, by name
+var SpecPresets = map[string]Spec{
+	"rtx-4090": {GPU: "nvidia", VRAMGB: 24, ComputeCap: 8.9, RAMGB: 64, CPUCores: 16,
+		Disk: DiskSpec{Class: DiskNVMe, FreeGB: 500}},
+	"a100-80gb": {GPU: "nvidia", VRAMGB: 80, ComputeCap: 8.0, RAMGB: 256, CPUCores: 32,
+		CPUFeatures: []string{"avx2", "avx512f", "avx512bw", "avx512vnni"}, Disk: DiskSpec{Class: DiskNVMe, FreeGB: 1000}},
+	"m2-ultra":   {GPU: "apple", Chip: "M2 Ultra", RAMGB: 192, CPUCores: 24, Disk: DiskSpec{Class: DiskNVMe, FreeGB: 1000}},
+	"laptop-8gb": {RAMGB: 8, CPUCores: 4, Disk: DiskSpec{Class: DiskSSD, FreeGB: 100}},
+}
+
+// cpuFeatures sets the CPUInfo flag of each feature a spec may list
+var cpuFeatures = map[string]func(*CPUInfo){
+	"avx":        func(c *CPUInfo) { c.AVX = true },
+	"avx2":       func(c *CPUInfo) { c.AVX, c.AVX2 = true, true },
+	"avx512f":    func(c *CPUInfo) { c.AVX512F = true },
+	"avx512bw":   func(c *CPUInfo) { c.AVX512BW = true },
+	"avx512vnni": func(c *CPUInfo) { c.AVX512VNNI = true },
+	"avx512bf16": func(c *CPUInfo) { c.AVX512BF16 = true },
+	"amx":        func(c *CPUInfo) { c.AMX = true },
+	"neon":       func(c *CPUInfo) { c.NEON = true },
+	"sve":        func(c *CPUInfo) { c.SVE = true },
+}
+
+// FromSpec builds a simulated profile from a Spec written as JSON or YAML
+func FromSpec(data []byte) (*HardwareProfile, error) {
+	var spec Spec
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("hardware spec: %w", err)
+		}
+	} else if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("hardware spec: %w", err)
+	}
+	return spec.Profile()
+}
+
+// Profile returns the profile detection would produce on the machine s describes
+func (s Spec) Profile() (*HardwareProfile, error) {
+	if s.RAMGB <= 0 {
+		return nil, fmt.Errorf("hardware spec: ram_gb must be positive")
+	}
+	profile := &HardwareProfile{SystemRAM_MB: gbToMB(s.RAMGB)}
+
+	profile.CPU = CPUInfo{Arch: "amd64", PhysicalCores: s.CPUCores, LogicalCores: s.CPUCores}
+	if s.GPU == "apple" || slices.Contains(s.CPUFeatures, "neon") {
+		profile.CPU.Arch = "arm64"
+	}
+	features := s.CPUFeatures
+	if len(features) == 0 {
+		features = []string{"avx2"}
+		if profile.CPU.Arch == "arm64" {
+			features = []string{"neon"}
+		}
+	}
+	for _, feature := range features {
+		set, ok := cpuFeatures[strings.ToLower(feature)]
+		if !ok {
+			return nil, fmt.Errorf("hardware spec: unknown cpu feature %q", feature)
+		}
+		set(&profile.CPU)
+	}
+	profile.CpuAVX512 = profile.CPU.AVX512F
+
+	count := max(s.GPUCount, 1)
+	switch s.GPU {
+	case "":
+	case "apple":
+		profile.HasMetal = true
+		profile.VRAM_MB = int(float64(profile.SystemRAM_MB) * 0.7)
+		if s.VRAMGB > 0 {
+			profile.VRAM_MB = gbToMB(s.VRAMGB)
+		}
+		if s.Chip != "" {
+			chip, ok := ParseAppleChip(s.Chip)
+			if !ok {
+				return nil, fmt.Errorf("hardware spec: unknown apple chip %q", s.Chip)
+			}
+			profile.Apple = &chip
+		}
+	case "nvidia", "amd":
+		if s.VRAMGB <= 0 {
+			return nil, fmt.Errorf("hardware spec: %s GPUs need vram_gb", s.GPU)
+		}
+		profile.HasCuda, profile.HasROCm = s.GPU == "nvidia", s.GPU == "amd"
+		profile.VRAM_MB, profile.ComputeCap, profile.GPUArch = gbToMB(s.VRAMGB), s.ComputeCap, s.GPUArch
+		for i := range count {
+			profile.GPUs = append(profile.GPUs, GPUInfo{Index: i, VRAM_MB: profile.VRAM_MB, ComputeCap: s.ComputeCap, NVLink: s.NVLink && count > 1})
+		}
+	case "intel":
+		// integrated GPUs report no VRAM of their own
+		profile.HasOneAPI = true
+		profile.VRAM_MB = gbToMB(s.VRAMGB)
+	default:
+		return nil, fmt.Errorf("hardware spec: unknown gpu %q (want nvidia, amd, intel or apple)", s.GPU)
+	}
+
+	if s.Disk.Class != "" {
+		if _, ok := typicalReadMBps[s.Disk.Class]; !ok {
+			return nil, fmt.Errorf("hardware spec: unknown disk class %q (want nvme, ssd or hdd)", s.Disk.Class)
+		}
+		profile.Disk = &DiskInfo{Path: "simulated", FreeGB: s.Disk.FreeGB, Class: s.Disk.Class,
+			ReadMBps: typicalReadMBps[s.Disk.Class], cached: make(map[string]bool)}
+	}
+
+	if s.Power.Thermal != "" && !slices.Contains(ThermalPressures, s.Power.Thermal) {
+		return nil, fmt.Errorf("hardware spec: unknown thermal pressure %q (want nominal, moderate, heavy or critical)", s.Power.Thermal)
+	}
+	if s.Power.BatteryPercent < 0 || s.Power.BatteryPercent > 100 {
+		return nil, fmt.Errorf("hardware spec: battery_percent must be between 0 and 100")
+	}
+	profile.Power = PowerInfo{Source: PowerAC, Thermal: s.Power.Thermal}
+	if s.Power.OnBattery {
+		profile.Power.Source, profile.Power.BatteryPercent = PowerBattery, s.Power.BatteryPercent
+	}
+	return profile, nil
+}
+
+func gbToMB(gb float64) int {
+	return int(gb * 1024)
+}
@@ -0,0 +1,80 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromSpecReadsJSONAndYAML(t *testing.T) {
+	fromJSON, err := FromSpec([]byte(`{"gpu": "nvidia", "gpu_count": 2, "vram_gb": 24, "nvlink": true, "ram_gb": 128,
+		"cpu_cores": 16, "cpu_features": ["avx2", "avx512f"], "disk": {"class": "hdd", "free_gb": 50}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromYAML, err := FromSpec([]byte(`
+gpu: nvidia
+gpu_count: 2
+vram_gb: 24
+nvlink: true
+ram_gb: 128
+cpu_cores: 16
+cpu_features: [avx2, avx512f]
+disk:
+  class: hdd
+  free_gb: 50
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, profile := range []*HardwareProfile{fromJSON, fromYAML} {
+		if !profile.HasCuda || profile.VRAM_MB != 24576 || len(profile.GPUs) != 2 || !profile.GPUs[1].NVLink {
+			t.Errorf("GPUs not simulated: %+v", profile)
+		}
+		if !profile.CPU.AVX2 || !profile.CpuAVX512 || profile.CPU.PhysicalCores != 16 || profile.SystemRAM_MB != 131072 {
+			t.Errorf("CPU not simulated: %+v", profile.CPU)
+		}
+		if profile.Disk == nil || profile.Disk.ReadMBps != 120 || profile.Disk.FreeGB != 50 {
+			t.Errorf("disk not simulated: %+v", profile.Disk)
+		}
+		if tier := profile.ClassifyTier(); tier != TierElite {
+			t.Errorf("tier = %s", tier)
+		}
+	}
+}
+
+func TestSpecPresets(t *testing.T) {
+	want := map[string]Tier{"rtx-4090": TierElite, "a100-80gb": TierElite, "m2-ultra": TierApple, "laptop-8gb": TierLegacy}
+	for name, spec := range SpecPresets {
+		profile, err := spec.Profile()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if tier := profile.ClassifyTier(); tier != want[name] {
+			t.Errorf("%s: tier %s, want %s", name, tier, want[name])
+		}
+	}
+	apple, _ := SpecPresets["m2-ultra"].Profile()
+	if apple.CPU.Arch != "arm64" || !apple.CPU.NEON || apple.VRAM_MB != 137625 {
+		t.Errorf("Apple GPUs share 70%% of RAM: %+v", apple)
+	}
+	if apple.Apple == nil || apple.Apple.BandwidthGBs != 800 {
+		t.Errorf("m2-ultra chip: %+v, want the M2 Ultra's 800GB/s", apple.Apple)
+	}
+}
+
+func TestFromSpecRejectsBadSpecs(t *testing.T) {
+	for spec, want := range map[string]string{
+		`{"gpu": "nvidia", "ram_gb": 32}`:         "vram_gb",
+		`{"gpu": "voodoo", "ram_gb": 32}`:         "unknown gpu",
+		`{"ram_gb": 0}`:                           "ram_gb",
+		`{"ram_gb": 16, "cpu_features": ["mmx"]}`: "cpu feature",
+		`{"ram_gb": 16, "vram": 8}`:               "unknown field",
+		"ram_gb: 16\ndisk:\n  class: tape\n":      "disk class",
+		"ram_gb: 16\nvram: 8\n":                   "unknown key",
+		`{"gpu":"apple","chip":"M9","ram_gb":8}`:  "apple chip",
+	} {
+		if _, err := FromSpec([]byte(spec)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: error %v, want %q", spec, err, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package profiler
+
+import (
+	"fmt"
+	"math"
+)
+
+// Speculative decoding: a small draft model proposes a few tokens, which the target model
+// verifies in a single forward pass. Decoding is memory-bandwidth bound, so a pass costs
+// about the same whether it checks one token or several, and every accepted draft token
+// is one the target did not have to produce on its own.
+const (
+	// draftAcceptance is the chance the target accepts a drafted token, typical for an
+	// instruction-tuned draft of the same family on chat workloads
+	draftAcceptance = 0.7
+	// maxDraftTokens bounds the tokens drafted per verification step
+	maxDraftTokens = 8
+	// draftMaxParamsShare is the largest draft, as a share of the target's parameters,
+	// that still saves time
+	draftMaxParamsShare = 0.25
+	// minDraftSpeedup is the expected speedup below which a draft is not worth its memory
+	minDraftSpeedup = 1.1
+)
+
+// DraftPlan pairs a target model with a draft model for speculative decoding
+type DraftPlan struct {
+	ModelID string
+	Variant Variant
+	// Tokens is the number of tokens drafted per verification step
+	Tokens int
+	// Speedup is the expected decode speedup over the target alone
+	Speedup float64
+	// ExtraGB is the memory the draft's weights and KV cache add to the target's
+	ExtraGB float64
+}
+
+func (d *DraftPlan) String() string {
+	return fmt.Sprintf("speculative: %s %s draft (%d tokens), ~%.1fx decode for +%.1fGB",
+		d.ModelID, d.Variant.Quant, d.Tokens, d.Speedup, d.ExtraGB)
+}
+
+// PlanDraft picks the draft model speculative decoding pairs with variant of target on
+// High and Elite hosts: a registry model sharing the target's tokenizer and at most a
+// quarter of its size, whose weights and KV cache fit in the headroom the target leaves.
+// Of those, the one with the best expected speedup wins. ok is false when no draft helps.
+func (p *HardwareProfile) PlanDraft(registry *ModelRegistry, target Model, variant Variant, contextTokens int) (plan *DraftPlan, ok bool) {
+	if tier := p.ClassifyTier(); tier != TierHigh && tier != TierElite {
+		return nil, false
+	}
+	if target.Tokenizer == "" || target.ParamsB <= 0 || variant.SizeGB <= 0 {
+		return nil, false
+	}
+	// a target split across GPUs has no single device to hold the draft
+	availableMemGB := p.modelMemoryGB()
+	if variant.SizeGB > availableMemGB {
+		return nil, false
+	}
+	safeMemGB := availableMemGB - 2.0
+	contextTokens = target.scoringContext(contextTokens)
+	kvCacheGB, _ := target.kvCacheBeside(contextTokens, safeMemGB-variant.SizeGB)
+	headroomGB := safeMemGB - variant.SizeGB - kvCacheGB
+
+	for _, draft := range registry.Models {
+		if draft.ID == target.ID || draft.Tokenizer != target.Tokenizer || !p.ModelPolicy.Allows(draft) ||
+			draft.ParamsB <= 0 || draft.ParamsB > target.ParamsB*draftMaxParamsShare {
+			continue
+		}
+		draftKVGB := draft.KVCacheGB(draft.scoringContext(contextTokens), kvBytesF16)
+		for _, draftVariant := range draft.Variants {
+			extraGB := draftVariant.SizeGB + draftKVGB
+			if headroomGB-extraGB <= 0.5 {
+				continue
+			}
+			tokens, speedup := speculativeSpeedup(draftVariant.SizeGB / variant.SizeGB)
+			if speedup < minDraftSpeedup || (plan != nil && speedup <= plan.Speedup) {
+				continue
+			}
+			plan = &DraftPlan{ModelID: draft.ID, Variant: draftVariant, Tokens: tokens, Speedup: speedup, ExtraGB: extraGB}
+		}
+	}
+	return plan, plan != nil
+}
+
+// speculativeSpeedup returns the draft length with the best expected speedup for a draft
+// costing costRatio of a target pass, and that speedup. Drafting k tokens yields
+// (1-a^(k+1))/(1-a) tokens per step for k draft passes and one target pass.
+func speculativeSpeedup(costRatio float64) (tokens int, speedup float64) {
+	for k := 1; k <= maxDraftTokens; k++ {
+		accepted := (1 - math.Pow(draftAcceptance, float64(k+1))) / (1 - draftAcceptance)
+		if s := accepted / (float64(k)*costRatio + 1); s > speedup {
+			tokens, speedup = k, s
+		}
+	}
+	return tokens, speedup
+}
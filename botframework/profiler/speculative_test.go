@@ -0,0 +1,74 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func draftRegistry() *ModelRegistry {
+	return &ModelRegistry{Models: []Model{
+		{ID: "llama-8b", ParamsB: 8, ContextWindow: 8192, Tokenizer: "llama3", Benchmarks: Benchmarks{MMLU: 68},
+			Architecture: &Architecture{HiddenSize: 4096, Layers: 32, KVHeads: 8, HeadDim: 128},
+			Variants: []Variant{
+				{Quant: "Q4_K_M", SizeGB: 4.9, AccuracyRetention: 0.98},
+				{Quant: "Q8_0", SizeGB: 8.5, AccuracyRetention: 0.999},
+			}},
+		{ID: "llama-1b", ParamsB: 1.24, ContextWindow: 131072, Tokenizer: "llama3", Benchmarks: Benchmarks{MMLU: 49},
+			Architecture: &Architecture{HiddenSize: 2048, Layers: 16, KVHeads: 8, HeadDim: 64},
+			Variants: []Variant{
+				{Quant: "Q4_K_M", SizeGB: 0.81, AccuracyRetention: 0.97},
+				{Quant: "Q8_0", SizeGB: 1.32, AccuracyRetention: 0.995},
+			}},
+		{ID: "other-1b", ParamsB: 1, Tokenizer: "other", Benchmarks: Benchmarks{MMLU: 45},
+			Variants: []Variant{{Quant: "Q4_K_M", SizeGB: 0.7, AccuracyRetention: 0.97}}},
+	}}
+}
+
+func TestRecommendationsPairDraftOnHighTier(t *testing.T) {
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 12288}
+	byQuant := map[string]ScoredVariant{}
+	for _, ranked := range profile.RecommendModels(draftRegistry()) {
+		if ranked.ModelID == "llama-8b" {
+			byQuant[ranked.Variant.Quant] = ranked
+		}
+	}
+
+	q4 := byQuant["Q4_K_M"]
+	if q4.Draft == nil || q4.Draft.ModelID != "llama-1b" || q4.Draft.Variant.Quant != "Q4_K_M" {
+		t.Fatalf("Q4_K_M should draft with llama-1b Q4_K_M: %+v", q4.Draft)
+	}
+	if q4.Draft.Speedup < 1.5 || q4.Draft.Tokens < 2 || q4.Draft.ExtraGB < 0.81 {
+		t.Errorf("unexpected plan: %+v", q4.Draft)
+	}
+	if !strings.Contains(q4.Reason, "speculative: llama-1b Q4_K_M draft") {
+		t.Errorf("reason = %q", q4.Reason)
+	}
+	// the Q8_0 target leaves too little room for the draft
+	if q8 := byQuant["Q8_0"]; q8.Draft != nil || strings.Contains(q8.Reason, "speculative") {
+		t.Errorf("Q8_0 should not draft on 12GB: %+v", q8.Draft)
+	}
+}
+
+func TestPlanDraftSkipsLowerTiersAndOtherTokenizers(t *testing.T) {
+	registry := draftRegistry()
+	target := registry.Models[0]
+
+	cpu := &HardwareProfile{SystemRAM_MB: 65536}
+	if _, ok := cpu.PlanDraft(registry, target, target.Variants[0], 0); ok {
+		t.Error("Balanced hosts should not draft")
+	}
+
+	registry.Models[1].Tokenizer = "llama2"
+	gpu := &HardwareProfile{HasCuda: true, VRAM_MB: 24576}
+	if plan, ok := gpu.PlanDraft(registry, target, target.Variants[0], 0); ok {
+		t.Errorf("no draft shares the target's tokenizer, got %+v", plan)
+	}
+}
+
+func TestSpeculativeSpeedupShrinksWithDraftCost(t *testing.T) {
+	_, cheap := speculativeSpeedup(0.05)
+	_, costly := speculativeSpeedup(0.4)
+	if cheap <= costly || costly < 1 {
+		t.Errorf("speedup at 5%% cost %.2f, at 40%% cost %.2f", cheap, costly)
+	}
+}
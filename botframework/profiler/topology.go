@@ -0,0 +1,137 @@
+package profiler
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GPUInfo describes one GPU on a multi-GPU host
+type GPUInfo struct {
+	Index      int     `json:"index"`
+	Name       string  `json:"name,omitempty"`
+	VRAM_MB    int     `json:"vram_mb"`
+	ComputeCap float64 `json:"compute_cap,omitempty"`
+	PCIeGen    int     `json:"pcie_gen,omitempty"`   // current link generation
+	PCIeWidth  int     `json:"pcie_width,omitempty"` // current lanes
+	NVLink     bool    `json:"nvlink,omitempty"`     // has at least one active NVLink
+}
+
+// nvidiaQuery is the nvidia-smi query parsed by parseNvidiaGPUs
+const nvidiaQuery = "--query-gpu=index,name,memory.total,compute_cap,pcie.link.gen.current,pcie.link.width.current"
+
+// detectNvidiaGPUs lists every NVIDIA GPU with its link topology
+func detectNvidiaGPUs() ([]GPUInfo, error) {
+	out, err := exec.Command("nvidia-smi", nvidiaQuery, "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return nil, err
+	}
+	gpus := parseNvidiaGPUs(string(out))
+	if links, err := exec.Command("nvidia-smi", "nvlink", "--status").Output(); err == nil {
+		active := parseNVLinkStatus(string(links))
+		for i := range gpus {
+			gpus[i].NVLink = active[gpus[i].Index]
+		}
+	}
+	return gpus, nil
+}
+
+// parseNvidiaGPUs reads one CSV row per GPU; fields nvidia-smi cannot report are "[N/A]"
+func parseNvidiaGPUs(output string) []GPUInfo {
+	var gpus []GPUInfo
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) < 4 {
+			continue
+		}
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		gpu := GPUInfo{Name: fields[1]}
+		gpu.Index, _ = strconv.Atoi(fields[0])
+		gpu.VRAM_MB, _ = strconv.Atoi(fields[2])
+		gpu.ComputeCap, _ = strconv.ParseFloat(fields[3], 64)
+		if len(fields) >= 6 {
+			gpu.PCIeGen, _ = strconv.Atoi(fields[4])
+			gpu.PCIeWidth, _ = strconv.Atoi(fields[5])
+		}
+		gpus = append(gpus, gpu)
+	}
+	return gpus
+}
+
+var nvlinkActivePattern = regexp.MustCompile(`^\s+Link \d+: [\d.]+ GB/s`)
+
+// parseNVLinkStatus returns the GPUs with an active link in `nvidia-smi nvlink --status`
+func parseNVLinkStatus(output string) map[int]bool {
+	active := make(map[int]bool)
+	gpu := -1
+	for _, line := range strings.Split(output, "\n") {
+		if m := gpuLinePattern.FindStringSubmatch(line); m != nil {
+			gpu, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if gpu >= 0 && nvlinkActivePattern.MatchString(line) {
+			active[gpu] = true
+		}
+	}
+	return active
+}
+
+// TotalVRAM_MB is the memory of all GPUs together; VRAM_MB is what a single device offers
+func (p *HardwareProfile) TotalVRAM_MB() int {
+	if len(p.GPUs) == 0 {
+		return p.VRAM_MB
+	}
+	total := 0
+	for _, gpu := range p.GPUs {
+		total += gpu.VRAM_MB
+	}
+	return total
+}
+
+// TensorParallelPlan is a placement of one model split across several GPUs
+type TensorParallelPlan struct {
+	GPUs []int `json:"gpus"` // device indexes
+	// PerGPU_MB is the memory each device contributes, bounded by the smallest one
+	PerGPU_MB int  `json:"per_gpu_mb"`
+	NVLink    bool `json:"nvlink"` // every device is NVLink-connected
+}
+
+func (t TensorParallelPlan) String() string {
+	link := "PCIe"
+	if t.NVLink {
+		link = "NVLink"
+	}
+	return fmt.Sprintf("tensor-parallel across %d GPUs over %s", len(t.GPUs), link)
+}
+
+// PlanTensorParallel finds the smallest power-of-two group of GPUs (the sizes vLLM's tensor
+// parallelism accepts) whose memory holds sizeGB, preferring the largest devices. ok is false
+// when the model fits on one GPU, when there is only one, or when even all of them are too
+// small. MIG slices cannot be combined.
+func (p *HardwareProfile) PlanTensorParallel(sizeGB float64) (plan TensorParallelPlan, ok bool) {
+	if len(p.GPUs) < 2 || len(p.MIGDevices) > 0 || sizeGB <= float64(p.VRAM_MB)/1024.0 {
+		return TensorParallelPlan{}, false
+	}
+	gpus := append([]GPUInfo(nil), p.GPUs...)
+	sort.SliceStable(gpus, func(i, j int) bool { return gpus[i].VRAM_MB > gpus[j].VRAM_MB })
+
+	for n := 2; n <= len(gpus); n *= 2 {
+		group := gpus[:n]
+		perGPU := group[n-1].VRAM_MB
+		if float64(perGPU*n)/1024.0 < sizeGB {
+			continue
+		}
+		plan = TensorParallelPlan{PerGPU_MB: perGPU, NVLink: true}
+		for _, gpu := range group {
+			plan.GPUs = append(plan.GPUs, gpu.Index)
+			plan.NVLink = plan.NVLink && gpu.NVLink
+		}
+		return plan, true
+	}
+	return TensorParallelPlan{}, false
+}
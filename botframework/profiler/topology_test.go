@@ -0,0 +1,81 @@
+package profiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNvidiaGPUs(t *testing.T) {
+	out := `0, NVIDIA GeForce RTX 3090, 24576, 8.6, 4, 16
+1, NVIDIA GeForce RTX 3090, 24576, 8.6, 3, 8
+`
+	gpus := parseNvidiaGPUs(out)
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 GPUs, got %d", len(gpus))
+	}
+	if gpus[1].Index != 1 || gpus[1].VRAM_MB != 24576 || gpus[1].ComputeCap != 8.6 || gpus[1].PCIeGen != 3 || gpus[1].PCIeWidth != 8 {
+		t.Fatalf("unexpected second GPU: %+v", gpus[1])
+	}
+}
+
+func TestParseNVLinkStatus(t *testing.T) {
+	out := `GPU 0: NVIDIA A100-SXM4-80GB (UUID: GPU-1)
+	 Link 0: 25 GB/s
+	 Link 1: 25 GB/s
+GPU 1: NVIDIA A100-SXM4-80GB (UUID: GPU-2)
+	 Link 0: <inactive>
+`
+	active := parseNVLinkStatus(out)
+	if !active[0] || active[1] {
+		t.Fatalf("expected only GPU 0 linked, got %v", active)
+	}
+}
+
+func TestPlanTensorParallel(t *testing.T) {
+	profile := &HardwareProfile{HasCuda: true, VRAM_MB: 24576, GPUs: []GPUInfo{
+		{Index: 0, VRAM_MB: 24576, NVLink: true},
+		{Index: 1, VRAM_MB: 24576, NVLink: true},
+		{Index: 2, VRAM_MB: 12288},
+	}}
+	if got := profile.TotalVRAM_MB(); got != 61440 {
+		t.Fatalf("expected 60GB total, got %d", got)
+	}
+
+	if _, ok := profile.PlanTensorParallel(20); ok {
+		t.Fatal("a model that fits one GPU needs no plan")
+	}
+	plan, ok := profile.PlanTensorParallel(40)
+	if !ok || len(plan.GPUs) != 2 || plan.GPUs[0] != 0 || plan.GPUs[1] != 1 || !plan.NVLink {
+		t.Fatalf("expected the two linked 24GB cards, got %+v, %v", plan, ok)
+	}
+	// three cards are not a valid tensor-parallel size, so 55GB does not fit
+	if _, ok := profile.PlanTensorParallel(55); ok {
+		t.Fatal("expected no plan for 55GB")
+	}
+
+	profile.MIGDevices = []MIGDevice{{MemoryMB: 10240}}
+	if _, ok := profile.PlanTensorParallel(40); ok {
+		t.Fatal("MIG slices cannot be combined")
+	}
+}
+
+func TestScoringUsesTensorParallelPlacement(t *testing.T) {
+	model := Model{ID: "llama-3-70b", ParamsB: 70, Benchmarks: Benchmarks{MMLU: 80}}
+	variant := Variant{Quant: "Q4_K_M", SizeGB: 40, AccuracyRetention: 0.98}
+	single := &HardwareProfile{HasCuda: true, VRAM_MB: 24576, SystemRAM_MB: 65536}
+	if score, _ := single.CalculateScore(model, variant); score != 0 {
+		t.Fatalf("expected a 40GB model not to fit one 24GB card, got %.1f", score)
+	}
+
+	dual := &HardwareProfile{HasCuda: true, VRAM_MB: 24576, SystemRAM_MB: 65536, GPUs: []GPUInfo{
+		{Index: 0, VRAM_MB: 24576},
+		{Index: 1, VRAM_MB: 24576},
+	}}
+	score, reason := dual.CalculateScore(model, variant)
+	if score <= 0 || !strings.Contains(reason, "tensor-parallel across 2 GPUs over PCIe") {
+		t.Fatalf("expected a tensor-parallel placement, got %.1f %q", score, reason)
+	}
+	if got := dual.GetRecommendedEngine(40); got != EngineVLLM {
+		t.Fatalf("expected vLLM for a sharded model, got %s", got)
+	}
+}
@@ -0,0 +1,117 @@
+package profiler
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ResourceUsage is a live sample of memory and GPU utilisation
+type ResourceUsage struct {
+	RAMTotalMB     int     `json:"ram_total_mb"`
+	RAMUsedMB      int     `json:"ram_used_mb"`
+	VRAMTotalMB    int     `json:"vram_total_mb"`
+	VRAMUsedMB     int     `json:"vram_used_mb"`
+	GPUUtilPercent int     `json:"gpu_util_percent"`
+	PowerDrawW     float64 `json:"power_draw_w"`
+	// MemoryPressure is macOS's unified memory pressure: normal, warn or critical. Metal
+	// allocations start failing or swapping well before RAM is full.
+	MemoryPressure string `json:"memory_pressure,omitempty"`
+}
+
+// SampleUsage reads current RAM and GPU usage. Fields stay zero when the
+// platform tooling is unavailable.
+func SampleUsage() ResourceUsage {
+	var usage ResourceUsage
+
+	switch runtime.GOOS {
+	case "linux":
+		if total, available, ok := readMeminfo("/proc/meminfo"); ok {
+			usage.RAMTotalMB = total
+			usage.RAMUsedMB = total - available
+		}
+	case "darwin":
+		sampleAppleUsage(&usage)
+	}
+
+	out, err := exec.Command("nvidia-smi", "--query-gpu=memory.total,memory.used,utilization.gpu,power.draw", "--format=csv,noheader,nounits").Output()
+	if err == nil {
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			parts := strings.Split(line, ",")
+			if len(parts) < 3 {
+				continue
+			}
+			total, _ := strconv.Atoi(strings.TrimSpace(parts[0]))
+			used, _ := strconv.Atoi(strings.TrimSpace(parts[1]))
+			util, _ := strconv.Atoi(strings.TrimSpace(parts[2]))
+			usage.VRAMTotalMB += total
+			usage.VRAMUsedMB += used
+			if len(parts) >= 4 {
+				// power.draw reports "[N/A]" on GPUs without power telemetry
+				watts, _ := strconv.ParseFloat(strings.TrimSpace(parts[3]), 64)
+				usage.PowerDrawW += watts
+			}
+			if util > usage.GPUUtilPercent {
+				usage.GPUUtilPercent = util
+			}
+		}
+	}
+
+	return usage
+}
+
+// readMeminfo returns MemTotal and MemAvailable in MB
+func readMeminfo(path string) (int, int, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+
+	var totalKB, availableKB int
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		value, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			totalKB = value
+		case "MemAvailable:":
+			availableKB = value
+		}
+	}
+
+	if totalKB == 0 {
+		return 0, 0, false
+	}
+	return totalKB / 1024, availableKB / 1024, true
+}
+
+// SamplePowerDraw returns the combined GPU power draw in watts
+func SamplePowerDraw() (float64, bool) {
+	out, err := exec.Command("nvidia-smi", "--query-gpu=power.draw", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return 0, false
+	}
+
+	total := 0.0
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		watts, err := strconv.ParseFloat(strings.TrimSpace(line), 64)
+		if err != nil {
+			continue
+		}
+		total += watts
+		found = true
+	}
+	return total, found
+}
@@ -0,0 +1,138 @@
+package profiler
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// GPUMemory is a live VRAM reading for one device
+type GPUMemory struct {
+	Index   int `json:"index"`
+	TotalMB int `json:"total_mb"`
+	UsedMB  int `json:"used_mb"`
+}
+
+func (g GPUMemory) FreeMB() int {
+	return max(0, g.TotalMB-g.UsedMB)
+}
+
+// SampleVRAM reads the memory in use on every GPU: nvidia-smi on NVIDIA hosts, the amdgpu
+// sysfs counters on AMD, and on Apple Silicon the unified memory in use measured against
+// the 70% GPU share DetectHardware assumes. It returns nil when no GPU is found.
+func SampleVRAM() []GPUMemory {
+	switch runtime.GOOS {
+	case "darwin":
+		return sampleUnifiedMemory()
+	case "linux", "windows":
+		out, err := exec.Command("nvidia-smi", "--query-gpu=index,memory.total,memory.used", "--format=csv,noheader,nounits").Output()
+		if err == nil {
+			if gpus := parseNvidiaMemory(out); len(gpus) > 0 {
+				return gpus
+			}
+		}
+		return sampleAMDSysfs("/sys")
+	}
+	return nil
+}
+
+// parseNvidiaMemory reads `nvidia-smi --query-gpu=index,memory.total,memory.used` CSV output
+func parseNvidiaMemory(out []byte) []GPUMemory {
+	var gpus []GPUMemory
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.Split(line, ",")
+		if len(parts) < 3 {
+			continue
+		}
+		index, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+		total, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		used, err3 := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+		gpus = append(gpus, GPUMemory{Index: index, TotalMB: total, UsedMB: used})
+	}
+	return gpus
+}
+
+// sampleAMDSysfs reads mem_info_vram_total and mem_info_vram_used from the amdgpu DRM nodes
+func sampleAMDSysfs(root string) []GPUMemory {
+	var gpus []GPUMemory
+	cards, _ := filepath.Glob(filepath.Join(root, "class", "drm", "card*", "device", "vendor"))
+	for _, vendorFile := range cards {
+		vendor, err := os.ReadFile(vendorFile)
+		if err != nil || strings.TrimSpace(string(vendor)) != "0x1002" {
+			continue
+		}
+		device := filepath.Dir(vendorFile)
+		total, ok1 := readSysfsBytes(filepath.Join(device, "mem_info_vram_total"))
+		used, ok2 := readSysfsBytes(filepath.Join(device, "mem_info_vram_used"))
+		if !ok1 || !ok2 {
+			continue
+		}
+		gpus = append(gpus, GPUMemory{Index: len(gpus), TotalMB: int(total >> 20), UsedMB: int(used >> 20)})
+	}
+	return gpus
+}
+
+func readSysfsBytes(path string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	return value, err == nil
+}
+
+// sampleUnifiedMemory treats the GPU share of unified memory as VRAM. Memory the OS
+// cannot reclaim (everything but free, inactive and speculative pages) counts as used.
+func sampleUnifiedMemory() []GPUMemory {
+	out, err := exec.Command("uname", "-m").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "arm64" {
+		return nil
+	}
+	vmstat, err := exec.Command("vm_stat").Output()
+	if err != nil {
+		return nil
+	}
+	ramMB, _ := detectSystemRAM()
+	availableMB, ok := parseVMStat(vmstat)
+	if !ok {
+		return nil
+	}
+	total := int(float64(ramMB) * 0.7)
+	used := min(total, max(0, ramMB-availableMB))
+	return []GPUMemory{{Index: 0, TotalMB: total, UsedMB: used}}
+}
+
+var vmStatPageSize = regexp.MustCompile(`page size of (\d+) bytes`)
+
+// parseVMStat returns the reclaimable memory in MB reported by vm_stat
+func parseVMStat(out []byte) (int, bool) {
+	pageSize := int64(4096)
+	if m := vmStatPageSize.FindSubmatch(out); m != nil {
+		pageSize, _ = strconv.ParseInt(string(m[1]), 10, 64)
+	}
+	var pages int64
+	found := false
+	for _, line := range strings.Split(string(out), "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "Pages free", "Pages inactive", "Pages speculative":
+			n, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(value), "."), 10, 64)
+			if err != nil {
+				continue
+			}
+			pages += n
+			found = true
+		}
+	}
+	return int(pages * pageSize >> 20), found
+}
@@ -0,0 +1,45 @@
+package profiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseNvidiaMemory(t *testing.T) {
+	out := "0, 24576, 20480\n1, 24576, 512\nbad line\n"
+	gpus := parseNvidiaMemory([]byte(out))
+	if len(gpus) != 2 {
+		t.Fatalf("expected 2 GPUs, got %+v", gpus)
+	}
+	if gpus[0].FreeMB() != 4096 || gpus[1].Index != 1 || gpus[1].FreeMB() != 24064 {
+		t.Fatalf("unexpected GPUs: %+v", gpus)
+	}
+}
+
+func TestSampleAMDSysfs(t *testing.T) {
+	root := t.TempDir()
+	device := filepath.Join(root, "class/drm/card0/device")
+	os.MkdirAll(device, 0o755)
+	os.WriteFile(filepath.Join(device, "vendor"), []byte("0x1002\n"), 0o644)
+	os.WriteFile(filepath.Join(device, "mem_info_vram_total"), []byte("17179869184\n"), 0o644)
+	os.WriteFile(filepath.Join(device, "mem_info_vram_used"), []byte("4294967296\n"), 0o644)
+
+	gpus := sampleAMDSysfs(root)
+	if len(gpus) != 1 || gpus[0].TotalMB != 16384 || gpus[0].UsedMB != 4096 {
+		t.Fatalf("unexpected GPUs: %+v", gpus)
+	}
+}
+
+func TestParseVMStat(t *testing.T) {
+	out := `Mach Virtual Memory Statistics: (page size of 16384 bytes)
+Pages free:                               65536.
+Pages active:                            500000.
+Pages inactive:                           32768.
+Pages speculative:                          768.
+`
+	available, ok := parseVMStat([]byte(out))
+	if !ok || available != 1548 {
+		t.Fatalf("available = %d MB, %v", available, ok)
+	}
+}
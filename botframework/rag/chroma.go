@@ -0,0 +1,157 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// ChromaStore stores collections in a Chroma server over its v2 REST API
+type ChromaStore struct {
+	client   restClient
+	Tenant   string
+	Database string
+
+	mu  sync.Mutex
+	ids map[string]string // collection name -> Chroma collection id
+}
+
+func NewChromaStore(baseURL, tenant, database string) *ChromaStore {
+	if baseURL == "" {
+		baseURL = "http://localhost:8000"
+	}
+	if tenant == "" {
+		tenant = "default_tenant"
+	}
+	if database == "" {
+		database = "default_database"
+	}
+	return &ChromaStore{client: newRESTClient(baseURL, nil), Tenant: tenant, Database: database, ids: make(map[string]string)}
+}
+
+func (s *ChromaStore) collectionsPath() string {
+	return fmt.Sprintf("/api/v2/tenants/%s/databases/%s/collections", url.PathEscape(s.Tenant), url.PathEscape(s.Database))
+}
+
+// collectionPath resolves (creating if needed) the Chroma collection backing name
+func (s *ChromaStore) collectionPath(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.ids[name]; ok {
+		return s.collectionsPath() + "/" + id, nil
+	}
+
+	body := map[string]any{"name": name, "get_or_create": true, "metadata": map[string]string{"hnsw:space": "cosine"}}
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if _, err := s.client.do(ctx, http.MethodPost, s.collectionsPath(), body, &resp); err != nil {
+		return "", err
+	}
+	s.ids[name] = resp.ID
+	return s.collectionsPath() + "/" + resp.ID, nil
+}
+
+func (s *ChromaStore) Upsert(ctx context.Context, collection string, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	path, err := s.collectionPath(ctx, collection)
+	if err != nil {
+		return err
+	}
+
+	body := struct {
+		IDs        []string            `json:"ids"`
+		Embeddings [][]float32         `json:"embeddings"`
+		Documents  []string            `json:"documents"`
+		Metadatas  []map[string]string `json:"metadatas"`
+	}{}
+	for _, doc := range docs {
+		body.IDs = append(body.IDs, doc.ID)
+		body.Embeddings = append(body.Embeddings, doc.Vector)
+		body.Documents = append(body.Documents, doc.Text)
+		// Chroma rejects empty metadata objects; null is accepted
+		var metadata map[string]string
+		if len(doc.Metadata) > 0 {
+			metadata = doc.Metadata
+		}
+		body.Metadatas = append(body.Metadatas, metadata)
+	}
+	_, err = s.client.do(ctx, http.MethodPost, path+"/upsert", body, nil)
+	return err
+}
+
+func (s *ChromaStore) Query(ctx context.Context, collection string, vector []float32, topK int, filter map[string]string) ([]Match, error) {
+	path, err := s.collectionPath(ctx, collection)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]any{
+		"query_embeddings": [][]float32{vector},
+		"n_results":        topK,
+		"include":          []string{"documents", "metadatas", "distances"},
+	}
+	if where := chromaWhere(filter); where != nil {
+		body["where"] = where
+	}
+
+	var resp struct {
+		IDs       [][]string            `json:"ids"`
+		Documents [][]string            `json:"documents"`
+		Metadatas [][]map[string]string `json:"metadatas"`
+		Distances [][]float64           `json:"distances"`
+	}
+	if _, err := s.client.do(ctx, http.MethodPost, path+"/query", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.IDs) == 0 {
+		return nil, nil
+	}
+
+	matches := make([]Match, len(resp.IDs[0]))
+	for i, id := range resp.IDs[0] {
+		match := Match{Document: Document{ID: id}}
+		if len(resp.Documents) > 0 && i < len(resp.Documents[0]) {
+			match.Text = resp.Documents[0][i]
+		}
+		if len(resp.Metadatas) > 0 && i < len(resp.Metadatas[0]) {
+			match.Metadata = resp.Metadatas[0][i]
+		}
+		if len(resp.Distances) > 0 && i < len(resp.Distances[0]) {
+			// cosine distance -> similarity
+			match.Score = 1 - resp.Distances[0][i]
+		}
+		matches[i] = match
+	}
+	return matches, nil
+}
+
+func (s *ChromaStore) Delete(ctx context.Context, collection string, ids []string) error {
+	path, err := s.collectionPath(ctx, collection)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.do(ctx, http.MethodPost, path+"/delete", map[string]any{"ids": ids}, nil)
+	return err
+}
+
+// chromaWhere builds a where clause; Chroma requires $and for more than one condition
+func chromaWhere(filter map[string]string) map[string]any {
+	switch len(filter) {
+	case 0:
+		return nil
+	case 1:
+		for key, value := range filter {
+			return map[string]any{key: value}
+		}
+	}
+	var conditions []map[string]any
+	for key, value := range filter {
+		conditions = append(conditions, map[string]any{key: value})
+	}
+	return map[string]any{"$and": conditions}
+}
@@ -0,0 +1,52 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChromaStoreQueryConvertsDistances(t *testing.T) {
+	var where map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		base := "/api/v2/tenants/default_tenant/databases/default_database/collections"
+		switch {
+		case r.URL.Path == base:
+			json.NewEncoder(w).Encode(map[string]string{"id": "c1"})
+		case r.URL.Path == base+"/c1/query":
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			where, _ = body["where"].(map[string]any)
+			json.NewEncoder(w).Encode(map[string]any{
+				"ids":       [][]string{{"a"}},
+				"documents": [][]string{{"hello"}},
+				"metadatas": [][]map[string]string{{{"lang": "en"}}},
+				"distances": [][]float64{{0.25}},
+			})
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	store := NewChromaStore(server.URL, "", "")
+	matches, err := store.Query(context.Background(), "docs", []float32{1}, 1, map[string]string{"lang": "en", "kind": "faq"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Score != 0.75 || matches[0].Metadata["lang"] != "en" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+	if _, ok := where["$and"]; !ok {
+		t.Errorf("expected $and filter, got %v", where)
+	}
+}
+
+func TestChromaWhereSingleCondition(t *testing.T) {
+	where := chromaWhere(map[string]string{"lang": "en"})
+	if where["lang"] != "en" || where["$and"] != nil {
+		t.Errorf("unexpected where: %v", where)
+	}
+}
@@ -0,0 +1,161 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunking strategies
+const (
+	ChunkFixed    = "fixed"
+	ChunkSentence = "sentence"
+	ChunkSemantic = "semantic"
+)
+
+// ChunkOptions controls how extracted text is split before embedding
+type ChunkOptions struct {
+	Strategy string `json:"strategy"`
+	// Size is the target chunk length in characters
+	Size int `json:"size"`
+	// Overlap is the number of characters (fixed) or sentences (sentence) repeated between chunks
+	Overlap int `json:"overlap"`
+	// Threshold is the similarity below which semantic chunking starts a new chunk
+	Threshold float64 `json:"threshold"`
+}
+
+func DefaultChunkOptions() ChunkOptions {
+	return ChunkOptions{Strategy: ChunkSentence, Size: 1000, Overlap: 1, Threshold: 0.75}
+}
+
+func (o ChunkOptions) withDefaults() ChunkOptions {
+	def := DefaultChunkOptions()
+	if o.Strategy == "" {
+		o.Strategy = def.Strategy
+	}
+	if o.Size <= 0 {
+		o.Size = def.Size
+	}
+	if o.Threshold <= 0 {
+		o.Threshold = def.Threshold
+	}
+	return o
+}
+
+// Chunk splits text with the configured strategy; semantic chunking needs an embedder
+func Chunk(ctx context.Context, text string, opts ChunkOptions, embedder Embedder) ([]string, error) {
+	opts = opts.withDefaults()
+	switch opts.Strategy {
+	case ChunkFixed:
+		return chunkFixed(text, opts.Size, opts.Overlap), nil
+	case ChunkSentence:
+		return chunkSentences(splitSentences(text), opts.Size, opts.Overlap), nil
+	case ChunkSemantic:
+		if embedder == nil {
+			return nil, fmt.Errorf("semantic chunking requires an embedder")
+		}
+		return chunkSemantic(ctx, splitSentences(text), opts, embedder)
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy %q", opts.Strategy)
+	}
+}
+
+// chunkFixed cuts text into windows of size runes, preferring to break on whitespace
+func chunkFixed(text string, size, overlap int) []string {
+	if overlap >= size {
+		overlap = size / 4
+	}
+	runes := []rune(text)
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := min(start+size, len(runes))
+		if end < len(runes) {
+			if cut := lastSpace(runes[start:end]); cut > size/2 {
+				end = start + cut
+			}
+		}
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+		start = max(end-overlap, start+1)
+	}
+	return chunks
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == ' ' || runes[i] == '\n' {
+			return i
+		}
+	}
+	return -1
+}
+
+var sentenceEnd = regexp.MustCompile(`([.!?]["')\]]*)\s+|\n{2,}`)
+
+func splitSentences(text string) []string {
+	var sentences []string
+	last := 0
+	for _, loc := range sentenceEnd.FindAllStringSubmatchIndex(text, -1) {
+		end := loc[1]
+		if loc[2] >= 0 {
+			end = loc[3]
+		}
+		if s := strings.TrimSpace(text[last:end]); s != "" {
+			sentences = append(sentences, s)
+		}
+		last = loc[1]
+	}
+	if s := strings.TrimSpace(text[last:]); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// chunkSentences packs whole sentences up to size characters, repeating overlap sentences
+func chunkSentences(sentences []string, size, overlap int) []string {
+	var chunks []string
+	for start := 0; start < len(sentences); {
+		end, length := start+1, utf8.RuneCountInString(sentences[start])
+		for end < len(sentences) && length+1+utf8.RuneCountInString(sentences[end]) <= size {
+			length += 1 + utf8.RuneCountInString(sentences[end])
+			end++
+		}
+		chunks = append(chunks, strings.Join(sentences[start:end], " "))
+		if end == len(sentences) {
+			break
+		}
+		start = max(end-overlap, start+1)
+	}
+	return chunks
+}
+
+// chunkSemantic starts a new chunk where adjacent sentences stop being similar, or at size
+func chunkSemantic(ctx context.Context, sentences []string, opts ChunkOptions, embedder Embedder) ([]string, error) {
+	if len(sentences) == 0 {
+		return nil, nil
+	}
+	vectors, err := embedder.Embed(ctx, sentences)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []string
+	current := []string{sentences[0]}
+	length := utf8.RuneCountInString(sentences[0])
+	for i := 1; i < len(sentences); i++ {
+		n := utf8.RuneCountInString(sentences[i])
+		if cosine(vectors[i-1], vectors[i]) < opts.Threshold || length+n > opts.Size {
+			chunks = append(chunks, strings.Join(current, " "))
+			current, length = nil, 0
+		}
+		current = append(current, sentences[i])
+		length += n + 1
+	}
+	return append(chunks, strings.Join(current, " ")), nil
+}
@@ -0,0 +1,62 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestChunkSentencesRespectsSizeAndOverlap(t *testing.T) {
+	text := "One is first. Two is second! Three is third? Four is fourth."
+	chunks, err := Chunk(context.Background(), text, ChunkOptions{Strategy: ChunkSentence, Size: 31, Overlap: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"One is first. Two is second!", "Two is second! Three is third?", "Three is third? Four is fourth."}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q", chunks)
+	}
+}
+
+func TestChunkFixedBreaksOnWhitespace(t *testing.T) {
+	chunks := chunkFixed("alpha beta gamma delta epsilon", 12, 0)
+	for _, chunk := range chunks {
+		if len(chunk) > 12 || strings.HasPrefix(chunk, " ") {
+			t.Errorf("bad chunk %q", chunk)
+		}
+	}
+	if strings.Join(chunks, " ") != "alpha beta gamma delta epsilon" {
+		t.Errorf("chunks lost text: %q", chunks)
+	}
+}
+
+// topicEmbedder embeds sentences about cats and dogs on orthogonal axes
+type topicEmbedder struct{ calls int }
+
+func (e *topicEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if strings.Contains(text, "cat") {
+			vectors[i] = []float32{1, 0}
+		} else {
+			vectors[i] = []float32{0, 1}
+		}
+	}
+	return vectors, nil
+}
+
+func TestChunkSemanticSplitsOnTopicChange(t *testing.T) {
+	text := "A cat sleeps. The cat purrs. A dog barks. The dog runs."
+	chunks, err := Chunk(context.Background(), text, ChunkOptions{Strategy: ChunkSemantic, Size: 500}, &topicEmbedder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || chunks[0] != "A cat sleeps. The cat purrs." {
+		t.Errorf("got %q", chunks)
+	}
+
+	if _, err := Chunk(context.Background(), text, ChunkOptions{Strategy: ChunkSemantic}, nil); err == nil {
+		t.Error("expected semantic chunking without an embedder to fail")
+	}
+}
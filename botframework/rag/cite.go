@@ -0,0 +1,54 @@
+package rag
+
+import (
+	"botframework/chat"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var citationPattern = regexp.MustCompile(`\[(\d{1,3})\]`)
+
+// Citations returns a stream transform that appends the sources an answer cites. Models see
+// retrieved documents numbered [1], [2], ... (see AssembleContext); every number the answer
+// references is listed with its source once generation ends.
+func Citations(matches []Match) chat.TransformFactory {
+	sources := make([]string, len(matches))
+	for i, match := range matches {
+		sources[i] = sourceOf(match)
+	}
+	return func() chat.Transform { return &citer{sources: sources, cited: make(map[int]bool)} }
+}
+
+type citer struct {
+	sources []string
+	cited   map[int]bool
+	tail    string
+}
+
+func (c *citer) Push(delta string) string {
+	// keep a short tail so references split across chunks are still seen
+	text := c.tail + delta
+	for _, m := range citationPattern.FindAllStringSubmatch(text, -1) {
+		if n, _ := strconv.Atoi(m[1]); n >= 1 && n <= len(c.sources) {
+			c.cited[n] = true
+		}
+	}
+	c.tail = text[max(0, len(text)-4):]
+	return delta
+}
+
+func (c *citer) Flush() string {
+	if len(c.cited) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nSources:")
+	for n := 1; n <= len(c.sources); n++ {
+		if c.cited[n] {
+			fmt.Fprintf(&b, "\n[%d] %s", n, c.sources[n-1])
+		}
+	}
+	return b.String()
+}
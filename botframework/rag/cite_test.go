@@ -0,0 +1,25 @@
+package rag
+
+import "testing"
+
+func TestCitationsListCitedSources(t *testing.T) {
+	matches := []Match{
+		{Document: Document{ID: "a", Metadata: map[string]string{"source": "manual.pdf"}}},
+		{Document: Document{ID: "b"}},
+		{Document: Document{ID: "c"}},
+	}
+	c := Citations(matches)()
+
+	out := c.Push("Cats sleep a lot [")
+	out += c.Push("3] and purr [1][9].")
+	if out != "Cats sleep a lot [3] and purr [1][9]." {
+		t.Errorf("text should pass through unchanged, got %q", out)
+	}
+	if got := c.Flush(); got != "\n\nSources:\n[1] manual.pdf\n[3] c" {
+		t.Errorf("got %q", got)
+	}
+
+	if got := Citations(matches)().Flush(); got != "" {
+		t.Errorf("uncited answers get no sources, got %q", got)
+	}
+}
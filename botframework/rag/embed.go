@@ -0,0 +1,55 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// Embedder turns texts into vectors, one per input, in order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// HTTPEmbedder calls an OpenAI-compatible /v1/embeddings endpoint
+type HTTPEmbedder struct {
+	URL   string
+	Model string
+
+	client restClient
+}
+
+func NewHTTPEmbedder(url, model, apiKey string) *HTTPEmbedder {
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["Authorization"] = "Bearer " + apiKey
+	}
+	return &HTTPEmbedder{URL: url, Model: model, client: newRESTClient(url, headers)}
+}
+
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	body := map[string]any{"input": texts}
+	if e.Model != "" {
+		body["model"] = e.Model
+	}
+	if _, err := e.client.do(ctx, http.MethodPost, "", body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings: expected %d vectors, got %d", len(texts), len(resp.Data))
+	}
+
+	sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+	vectors := make([][]float32, len(resp.Data))
+	for i, item := range resp.Data {
+		vectors[i] = item.Embedding
+	}
+	return vectors, nil
+}
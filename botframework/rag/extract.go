@@ -0,0 +1,293 @@
+package rag
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Source is a raw document submitted for ingestion
+type Source struct {
+	Name        string            `json:"name"`
+	ContentType string            `json:"content_type,omitempty"`
+	Data        []byte            `json:"data,omitempty"`
+	Text        string            `json:"text,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+// Extracted is the plain text of a document plus metadata found in it
+type Extracted struct {
+	Text     string
+	Metadata map[string]string
+}
+
+// Format identifies how a source should be parsed
+func (s Source) Format() string {
+	ct := strings.ToLower(s.ContentType)
+	switch {
+	case strings.Contains(ct, "pdf"):
+		return "pdf"
+	case strings.Contains(ct, "html"):
+		return "html"
+	case strings.Contains(ct, "markdown"):
+		return "markdown"
+	}
+	switch strings.ToLower(filepath.Ext(s.Name)) {
+	case ".pdf":
+		return "pdf"
+	case ".html", ".htm":
+		return "html"
+	case ".md", ".markdown":
+		return "markdown"
+	}
+	if bytes.HasPrefix(s.Data, []byte("%PDF-")) {
+		return "pdf"
+	}
+	return "text"
+}
+
+// Extract converts a source into plain text and metadata
+func Extract(src Source) (Extracted, error) {
+	data := src.Data
+	if len(data) == 0 {
+		data = []byte(src.Text)
+	}
+
+	format := src.Format()
+	var out Extracted
+	var err error
+	switch format {
+	case "pdf":
+		out, err = extractPDF(data)
+	case "html":
+		out = extractHTML(string(data))
+	case "markdown":
+		out = extractMarkdown(string(data))
+	default:
+		out = Extracted{Text: string(data), Metadata: map[string]string{}}
+	}
+	if err != nil {
+		return Extracted{}, fmt.Errorf("%s: %w", src.Name, err)
+	}
+
+	out.Metadata["format"] = format
+	if src.Name != "" {
+		out.Metadata["source"] = src.Name
+	}
+	for key, value := range src.Metadata {
+		out.Metadata[key] = value
+	}
+	out.Text = normalizeWhitespace(out.Text)
+	return out, nil
+}
+
+var (
+	htmlTitle     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	htmlMeta      = regexp.MustCompile(`(?is)<meta\s+[^>]*name=["']([^"']+)["'][^>]*content=["']([^"']*)["']`)
+	htmlDropped   = regexp.MustCompile(`(?is)<(script|style|noscript|head)[^>]*>.*?</(script|style|noscript|head)>`)
+	htmlComment   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlBlockTag  = regexp.MustCompile(`(?i)</?(p|div|br|li|tr|h[1-6]|section|article|header|footer|blockquote|pre|table|ul|ol)\b[^>]*>`)
+	htmlTag       = regexp.MustCompile(`(?s)<[^>]+>`)
+	spaceRun      = regexp.MustCompile(`[ \t\f\v]+`)
+	blankLineRun  = regexp.MustCompile(`\n{3,}`)
+	mdFrontMatter = regexp.MustCompile(`(?s)\A---\n(.*?)\n---\n`)
+	mdHeading     = regexp.MustCompile(`(?m)^#{1,6}\s+(.*)$`)
+	mdImage       = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink        = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdEmphasis    = regexp.MustCompile("(\\*\\*|__|\\*|_|`)")
+	mdFence       = regexp.MustCompile("(?m)^```.*$")
+	mdListMarker  = regexp.MustCompile(`(?m)^\s*([-*+]|\d+\.)\s+`)
+	mdQuote       = regexp.MustCompile(`(?m)^>\s?`)
+)
+
+func extractHTML(doc string) Extracted {
+	meta := map[string]string{}
+	if m := htmlTitle.FindStringSubmatch(doc); m != nil {
+		meta["title"] = strings.TrimSpace(html.UnescapeString(m[1]))
+	}
+	for _, m := range htmlMeta.FindAllStringSubmatch(doc, -1) {
+		switch name := strings.ToLower(m[1]); name {
+		case "description", "author", "keywords":
+			meta[name] = html.UnescapeString(m[2])
+		}
+	}
+
+	text := htmlComment.ReplaceAllString(doc, "")
+	text = htmlDropped.ReplaceAllString(text, "")
+	text = htmlBlockTag.ReplaceAllString(text, "\n")
+	text = htmlTag.ReplaceAllString(text, " ")
+	return Extracted{Text: html.UnescapeString(text), Metadata: meta}
+}
+
+func extractMarkdown(doc string) Extracted {
+	meta := map[string]string{}
+	if m := mdFrontMatter.FindStringSubmatch(doc); m != nil {
+		for _, line := range strings.Split(m[1], "\n") {
+			if key, value, ok := strings.Cut(line, ":"); ok {
+				meta[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+		}
+		doc = doc[len(m[0]):]
+	}
+	if _, ok := meta["title"]; !ok {
+		if m := mdHeading.FindStringSubmatch(doc); m != nil {
+			meta["title"] = strings.TrimSpace(m[1])
+		}
+	}
+
+	text := mdFence.ReplaceAllString(doc, "")
+	text = mdHeading.ReplaceAllString(text, "$1")
+	text = mdImage.ReplaceAllString(text, "$1")
+	text = mdLink.ReplaceAllString(text, "$1")
+	text = mdListMarker.ReplaceAllString(text, "")
+	text = mdQuote.ReplaceAllString(text, "")
+	text = mdEmphasis.ReplaceAllString(text, "")
+	return Extracted{Text: text, Metadata: meta}
+}
+
+var (
+	pdfStream   = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfTitle    = regexp.MustCompile(`/Title\s*\(((?:\\.|[^\\)])*)\)`)
+	pdfAuthor   = regexp.MustCompile(`/Author\s*\(((?:\\.|[^\\)])*)\)`)
+	pdfTextOps  = regexp.MustCompile(`(?s)\[(.*?)\]\s*TJ|(\((?:\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>)\s*(Tj|'|")|(T\*|Td|TD|ET)`)
+	pdfStrToken = regexp.MustCompile(`\((?:\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>|-?\d+(\.\d+)?`)
+)
+
+// extractPDF pulls text from the content streams of a PDF. It handles uncompressed and
+// FlateDecode streams with simple (non-CID) fonts, which covers most generated documents;
+// scanned PDFs need OCR before ingestion.
+func extractPDF(data []byte) (Extracted, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return Extracted{}, fmt.Errorf("not a PDF file")
+	}
+
+	meta := map[string]string{}
+	if m := pdfTitle.FindSubmatch(data); m != nil {
+		meta["title"] = decodePDFString("(" + string(m[1]) + ")")
+	}
+	if m := pdfAuthor.FindSubmatch(data); m != nil {
+		meta["author"] = decodePDFString("(" + string(m[1]) + ")")
+	}
+
+	var text strings.Builder
+	for _, loc := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[loc[2]:loc[3]])
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		content := data[start : start+end]
+
+		if strings.Contains(dict, "/FlateDecode") {
+			reader, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			// truncated streams still yield their decoded prefix
+			content, _ = io.ReadAll(reader)
+		} else if strings.Contains(dict, "/Filter") || strings.Contains(dict, "/Subtype") {
+			// images, fonts and other encodings carry no extractable text
+			continue
+		}
+		appendPDFText(&text, content)
+	}
+
+	if strings.TrimSpace(text.String()) == "" {
+		return Extracted{}, fmt.Errorf("no extractable text (scanned or CID-encoded PDF?)")
+	}
+	return Extracted{Text: text.String(), Metadata: meta}, nil
+}
+
+func appendPDFText(out *strings.Builder, content []byte) {
+	for _, m := range pdfTextOps.FindAllSubmatch(content, -1) {
+		switch {
+		case m[1] != nil:
+			for _, token := range pdfStrToken.FindAll(m[1], -1) {
+				if token[0] == '(' || token[0] == '<' {
+					out.WriteString(decodePDFString(string(token)))
+				} else if kern, _ := strconv.ParseFloat(string(token), 64); kern < -200 {
+					// large negative kerning is how PDFs encode word spacing inside TJ arrays
+					out.WriteByte(' ')
+				}
+			}
+		case m[2] != nil:
+			if op := string(m[3]); op != "Tj" {
+				out.WriteByte('\n')
+			}
+			out.WriteString(decodePDFString(string(m[2])))
+		case m[4] != nil:
+			out.WriteByte('\n')
+		}
+	}
+}
+
+func decodePDFString(token string) string {
+	if strings.HasPrefix(token, "<") {
+		hex := strings.Join(strings.Fields(strings.Trim(token, "<>")), "")
+		if len(hex)%2 == 1 {
+			hex += "0"
+		}
+		var out []byte
+		for i := 0; i+1 < len(hex); i += 2 {
+			b, err := strconv.ParseUint(hex[i:i+2], 16, 8)
+			if err != nil {
+				return ""
+			}
+			out = append(out, byte(b))
+		}
+		return string(out)
+	}
+
+	body := token[1 : len(token)-1]
+	var out strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c != '\\' || i+1 >= len(body) {
+			out.WriteByte(c)
+			continue
+		}
+		i++
+		switch e := body[i]; e {
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 't':
+			out.WriteByte('\t')
+		case 'b', 'f':
+		case '\n':
+			// line continuation
+		default:
+			if e >= '0' && e <= '7' {
+				j := i
+				for j < len(body) && j < i+3 && body[j] >= '0' && body[j] <= '7' {
+					j++
+				}
+				v, _ := strconv.ParseUint(body[i:j], 8, 8)
+				out.WriteByte(byte(v))
+				i = j - 1
+			} else {
+				out.WriteByte(e)
+			}
+		}
+	}
+	return out.String()
+}
+
+func normalizeWhitespace(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = spaceRun.ReplaceAllString(text, " ")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	text = blankLineRun.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,68 @@
+package rag
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtractHTMLDropsMarkupAndKeepsTitle(t *testing.T) {
+	doc := `<html><head><title>Guide &amp; FAQ</title><meta name="author" content="Ops"><style>p{}</style></head>
+<body><script>alert(1)</script><h1>Install</h1><p>Run <b>make</b>.</p><!-- hidden --></body></html>`
+	out, err := Extract(Source{Name: "guide.html", Data: []byte(doc)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Metadata["title"] != "Guide & FAQ" || out.Metadata["author"] != "Ops" || out.Metadata["format"] != "html" {
+		t.Errorf("unexpected metadata: %v", out.Metadata)
+	}
+	if out.Text != "Install\n\nRun make ." {
+		t.Errorf("unexpected text: %q", out.Text)
+	}
+}
+
+func TestExtractMarkdownFrontMatter(t *testing.T) {
+	doc := "---\ntitle: \"Release Notes\"\nversion: 2\n---\n# Heading\n\nSee [the docs](http://x) and **bold** text.\n"
+	out, err := Extract(Source{Name: "notes.md", Text: doc, Metadata: map[string]string{"team": "infra"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Metadata["title"] != "Release Notes" || out.Metadata["version"] != "2" || out.Metadata["team"] != "infra" {
+		t.Errorf("unexpected metadata: %v", out.Metadata)
+	}
+	if out.Text != "Heading\n\nSee the docs and bold text." {
+		t.Errorf("unexpected text: %q", out.Text)
+	}
+}
+
+func TestExtractPDFFlateStream(t *testing.T) {
+	var content bytes.Buffer
+	zw := zlib.NewWriter(&content)
+	zw.Write([]byte("BT /F1 12 Tf (Hello) Tj [(Wor) -20 (ld)] TJ ET BT (Second \\(line\\)) Tj ET"))
+	zw.Close()
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj << /Title (Manual) >> endobj\n")
+	fmt.Fprintf(&pdf, "2 0 obj << /Length %d /Filter /FlateDecode >>\nstream\n", content.Len())
+	pdf.Write(content.Bytes())
+	pdf.WriteString("\nendstream endobj\n%%EOF")
+
+	out, err := Extract(Source{Name: "manual.pdf", Data: pdf.Bytes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Metadata["title"] != "Manual" {
+		t.Errorf("unexpected metadata: %v", out.Metadata)
+	}
+	if !strings.Contains(out.Text, "HelloWorld") || !strings.Contains(out.Text, "Second (line)") {
+		t.Errorf("unexpected text: %q", out.Text)
+	}
+}
+
+func TestExtractPDFWithoutTextFails(t *testing.T) {
+	if _, err := Extract(Source{Name: "scan.pdf", Data: []byte("%PDF-1.4\n%%EOF")}); err == nil {
+		t.Error("expected an error for a PDF without text")
+	}
+}
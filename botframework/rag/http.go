@@ -0,0 +1,65 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// restClient is shared by the HTTP-based vector database backends
+type restClient struct {
+	baseURL    string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+func newRESTClient(baseURL string, headers map[string]string) restClient {
+	return restClient{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		headers:    headers,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// do sends body as JSON and decodes the response into out when non-nil; it returns the status code
+func (c restClient) do(ctx context.Context, method, path string, body, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("decode %s response: %w", path, err)
+		}
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,308 @@
+package rag
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job states
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// JobStatus reports the progress of an ingestion job
+type JobStatus struct {
+	ID                 string `json:"id"`
+	Collection         string `json:"collection"`
+	Status             string `json:"status"`
+	Documents          int    `json:"documents"`
+	DocumentsProcessed int    `json:"documents_processed"`
+	Chunks             int    `json:"chunks"`
+	ChunksEmbedded     int    `json:"chunks_embedded"`
+	Duplicates         int    `json:"duplicates"`
+	// Progress is the fraction of chunks embedded so far
+	Progress   float64   `json:"progress"`
+	Errors     []string  `json:"errors,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+}
+
+type job struct {
+	mu      sync.Mutex
+	status  JobStatus
+	sources []Source
+	opts    ChunkOptions
+}
+
+func (j *job) update(fn func(*JobStatus)) {
+	j.mu.Lock()
+	fn(&j.status)
+	j.mu.Unlock()
+}
+
+func (j *job) snapshot() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.status
+	status.Errors = append([]string(nil), j.status.Errors...)
+	switch {
+	case status.Status == JobCompleted:
+		status.Progress = 1
+	case status.Chunks > 0:
+		status.Progress = float64(status.ChunksEmbedded) / float64(status.Chunks)
+	}
+	return status
+}
+
+// Ingester extracts, chunks, deduplicates and embeds documents in background jobs
+type Ingester struct {
+	Stores   *Stores
+	Embedder Embedder
+	// BatchSize is the number of chunks embedded per request
+	BatchSize int
+
+	mu    sync.Mutex
+	jobs  map[string]*job
+	order []string
+	queue chan *job
+	// seen holds content hashes already ingested per collection, so resubmitted documents are skipped
+	seen map[string]map[string]bool
+}
+
+// NewIngester starts workers goroutines that process jobs until ctx is cancelled
+func NewIngester(ctx context.Context, stores *Stores, embedder Embedder, workers int) *Ingester {
+	ing := &Ingester{
+		Stores:    stores,
+		Embedder:  embedder,
+		BatchSize: 32,
+		jobs:      make(map[string]*job),
+		queue:     make(chan *job, 64),
+		seen:      make(map[string]map[string]bool),
+	}
+	for i := 0; i < max(workers, 1); i++ {
+		go ing.work(ctx)
+	}
+	return ing
+}
+
+// Submit queues sources for ingestion into collection and returns the new job's status
+func (ing *Ingester) Submit(collection string, sources []Source, opts ChunkOptions) (JobStatus, error) {
+	if len(sources) == 0 {
+		return JobStatus{}, fmt.Errorf("no documents to ingest")
+	}
+	opts = opts.withDefaults()
+	if opts.Strategy != ChunkFixed && opts.Strategy != ChunkSentence && opts.Strategy != ChunkSemantic {
+		return JobStatus{}, fmt.Errorf("unknown chunking strategy %q", opts.Strategy)
+	}
+
+	j := &job{
+		status:  JobStatus{ID: newJobID(), Collection: collection, Status: JobQueued, Documents: len(sources), CreatedAt: time.Now()},
+		sources: sources,
+		opts:    opts,
+	}
+
+	ing.mu.Lock()
+	ing.jobs[j.status.ID] = j
+	ing.order = append(ing.order, j.status.ID)
+	ing.mu.Unlock()
+
+	select {
+	case ing.queue <- j:
+	default:
+		ing.mu.Lock()
+		delete(ing.jobs, j.status.ID)
+		ing.order = ing.order[:len(ing.order)-1]
+		ing.mu.Unlock()
+		return JobStatus{}, fmt.Errorf("ingestion queue is full")
+	}
+	return j.snapshot(), nil
+}
+
+// Job returns the status of one job
+func (ing *Ingester) Job(id string) (JobStatus, bool) {
+	ing.mu.Lock()
+	j, ok := ing.jobs[id]
+	ing.mu.Unlock()
+	if !ok {
+		return JobStatus{}, false
+	}
+	return j.snapshot(), true
+}
+
+// Jobs lists all jobs in submission order
+func (ing *Ingester) Jobs() []JobStatus {
+	ing.mu.Lock()
+	jobs := make([]*job, 0, len(ing.order))
+	for _, id := range ing.order {
+		jobs = append(jobs, ing.jobs[id])
+	}
+	ing.mu.Unlock()
+
+	statuses := make([]JobStatus, len(jobs))
+	for i, j := range jobs {
+		statuses[i] = j.snapshot()
+	}
+	return statuses
+}
+
+func (ing *Ingester) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-ing.queue:
+			ing.run(ctx, j)
+		}
+	}
+}
+
+type pendingChunk struct {
+	Document
+	hash string
+}
+
+func (ing *Ingester) run(ctx context.Context, j *job) {
+	j.update(func(s *JobStatus) { s.Status = JobRunning })
+	collection := j.status.Collection
+
+	var pending []pendingChunk
+	batchSeen := make(map[string]bool)
+	for _, src := range j.sources {
+		chunks, err := ing.prepare(ctx, src, j.opts, collection, batchSeen)
+		j.update(func(s *JobStatus) {
+			s.DocumentsProcessed++
+			if err != nil {
+				s.Errors = append(s.Errors, err.Error())
+				return
+			}
+			s.Chunks += len(chunks.unique)
+			s.Duplicates += chunks.duplicates
+		})
+		pending = append(pending, chunks.unique...)
+	}
+
+	store := ing.Stores.For(collection)
+	var failed error
+	for start := 0; start < len(pending) && failed == nil; start += ing.BatchSize {
+		batch := pending[start:min(start+ing.BatchSize, len(pending))]
+		texts := make([]string, len(batch))
+		for i, chunk := range batch {
+			texts[i] = chunk.Text
+		}
+
+		vectors, err := ing.Embedder.Embed(ctx, texts)
+		if err != nil {
+			failed = fmt.Errorf("embedding: %w", err)
+			break
+		}
+		docs := make([]Document, len(batch))
+		for i := range batch {
+			docs[i] = batch[i].Document
+			docs[i].Vector = vectors[i]
+		}
+		if err := store.Upsert(ctx, collection, docs); err != nil {
+			failed = fmt.Errorf("vector store: %w", err)
+			break
+		}
+
+		ing.markSeen(collection, batch)
+		j.update(func(s *JobStatus) { s.ChunksEmbedded += len(batch) })
+	}
+
+	j.update(func(s *JobStatus) {
+		s.FinishedAt = time.Now()
+		if failed != nil {
+			s.Status = JobFailed
+			s.Errors = append(s.Errors, failed.Error())
+			return
+		}
+		s.Status = JobCompleted
+	})
+	if failed != nil {
+		slog.Error("ingestion job failed", "job", j.status.ID, "err", failed)
+	} else {
+		slog.Info("ingested chunks", "job", j.status.ID, "chunks", len(pending), "collection", collection)
+	}
+}
+
+type preparedChunks struct {
+	unique     []pendingChunk
+	duplicates int
+}
+
+// prepare extracts and chunks one source, dropping chunks whose content was already ingested
+func (ing *Ingester) prepare(ctx context.Context, src Source, opts ChunkOptions, collection string, batchSeen map[string]bool) (preparedChunks, error) {
+	extracted, err := Extract(src)
+	if err != nil {
+		return preparedChunks{}, err
+	}
+	texts, err := Chunk(ctx, extracted.Text, opts, ing.Embedder)
+	if err != nil {
+		return preparedChunks{}, fmt.Errorf("%s: %w", src.Name, err)
+	}
+
+	docHash := contentHash(extracted.Text)
+	var out preparedChunks
+	for i, text := range texts {
+		hash := contentHash(text)
+		if batchSeen[hash] || ing.alreadySeen(collection, hash) {
+			out.duplicates++
+			continue
+		}
+		batchSeen[hash] = true
+
+		metadata := make(map[string]string, len(extracted.Metadata)+3)
+		for key, value := range extracted.Metadata {
+			metadata[key] = value
+		}
+		metadata["chunk_index"] = strconv.Itoa(i)
+		metadata["document_hash"] = docHash
+		out.unique = append(out.unique, pendingChunk{
+			Document: Document{ID: hash, Text: text, Metadata: metadata},
+			hash:     hash,
+		})
+	}
+	return out, nil
+}
+
+func (ing *Ingester) alreadySeen(collection, hash string) bool {
+	ing.mu.Lock()
+	defer ing.mu.Unlock()
+	return ing.seen[collection][hash]
+}
+
+func (ing *Ingester) markSeen(collection string, chunks []pendingChunk) {
+	ing.mu.Lock()
+	defer ing.mu.Unlock()
+	if ing.seen[collection] == nil {
+		ing.seen[collection] = make(map[string]bool)
+	}
+	for _, chunk := range chunks {
+		ing.seen[collection][chunk.hash] = true
+	}
+}
+
+// contentHash identifies text independent of case and whitespace, so near-identical
+// chunks collapse to one document ID
+func contentHash(text string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:16])
+}
+
+func newJobID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "ingest_" + hex.EncodeToString(b)
+}
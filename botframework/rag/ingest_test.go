@@ -0,0 +1,71 @@
+package rag
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func waitForJob(t *testing.T, ing *Ingester, id string) JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		status, _ := ing.Job(id)
+		if status.Status == JobCompleted || status.Status == JobFailed {
+			return status
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return JobStatus{}
+}
+
+func TestIngesterEmbedsAndDeduplicates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stores, _ := NewStores(Config{})
+	embedder := &topicEmbedder{}
+	ing := NewIngester(ctx, stores, embedder, 1)
+	ing.BatchSize = 2
+
+	sources := []Source{
+		{Name: "a.txt", Text: "A cat sleeps.\n\nA dog barks.\n\nThe cat purrs."},
+		{Name: "b.md", Text: "# Pets\n\nA  CAT sleeps."},
+	}
+	job, err := ing.Submit("pets", sources, ChunkOptions{Strategy: ChunkSentence, Size: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := waitForJob(t, ing, job.ID)
+	if status.Status != JobCompleted || status.Progress != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	// "A  CAT sleeps." normalises to the same content as "A cat sleeps."
+	if status.Chunks != 4 || status.Duplicates != 1 || status.ChunksEmbedded != 4 {
+		t.Errorf("unexpected counts: %+v", status)
+	}
+
+	matches, _ := stores.For("pets").Query(ctx, "pets", []float32{1, 0}, 10, map[string]string{"source": "a.txt"})
+	if len(matches) != 3 || matches[0].Metadata["chunk_index"] == "" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+
+	// resubmitting the same document is a no-op
+	again, _ := ing.Submit("pets", sources[:1], ChunkOptions{Strategy: ChunkSentence, Size: 10})
+	status = waitForJob(t, ing, again.ID)
+	if status.Chunks != 0 || status.Duplicates != 3 {
+		t.Errorf("expected all chunks to be duplicates: %+v", status)
+	}
+	if len(ing.Jobs()) != 2 {
+		t.Errorf("expected two jobs, got %d", len(ing.Jobs()))
+	}
+}
+
+func TestIngesterRejectsUnknownStrategy(t *testing.T) {
+	stores, _ := NewStores(Config{})
+	ing := NewIngester(context.Background(), stores, &topicEmbedder{}, 1)
+	if _, err := ing.Submit("c", []Source{{Name: "a", Text: "x"}}, ChunkOptions{Strategy: "paragraph"}); err == nil {
+		t.Error("expected unknown strategy to be rejected")
+	}
+}
@@ -0,0 +1,130 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// LocalStore is the built-in store: brute-force cosine search in memory, with each
+// collection persisted as a JSON file under Dir. An empty Dir keeps everything in memory.
+type LocalStore struct {
+	Dir string
+
+	mu          sync.Mutex
+	collections map[string]map[string]Document
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &LocalStore{Dir: dir, collections: make(map[string]map[string]Document)}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+func (s *LocalStore) path(collection string) string {
+	return filepath.Join(s.Dir, unsafeFileChars.ReplaceAllString(collection, "_")+".json")
+}
+
+// collection returns the named collection, loading it from disk on first use; callers hold s.mu
+func (s *LocalStore) collection(name string) (map[string]Document, error) {
+	if docs, ok := s.collections[name]; ok {
+		return docs, nil
+	}
+	docs := make(map[string]Document)
+	if s.Dir != "" {
+		data, err := os.ReadFile(s.path(name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err == nil {
+			var list []Document
+			if err := json.Unmarshal(data, &list); err != nil {
+				return nil, err
+			}
+			for _, doc := range list {
+				docs[doc.ID] = doc
+			}
+		}
+	}
+	s.collections[name] = docs
+	return docs, nil
+}
+
+func (s *LocalStore) save(name string, docs map[string]Document) error {
+	if s.Dir == "" {
+		return nil
+	}
+	list := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		list = append(list, doc)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	tmp := s.path(name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(name))
+}
+
+func (s *LocalStore) Upsert(ctx context.Context, collection string, docs []Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	for _, doc := range docs {
+		existing[doc.ID] = doc
+	}
+	return s.save(collection, existing)
+}
+
+func (s *LocalStore) Query(ctx context.Context, collection string, vector []float32, topK int, filter map[string]string) ([]Match, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs, err := s.collection(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, 0, len(docs))
+	for _, doc := range docs {
+		if !matchesFilter(doc.Metadata, filter) {
+			continue
+		}
+		matches = append(matches, Match{Document: doc, Score: cosine(vector, doc.Vector)})
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if topK > 0 && len(matches) > topK {
+		matches = matches[:topK]
+	}
+	return matches, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, collection string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	docs, err := s.collection(collection)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(docs, id)
+	}
+	return s.save(collection, docs)
+}
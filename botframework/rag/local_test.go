@@ -0,0 +1,61 @@
+package rag
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocalStoreQueryRanksAndFilters(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	docs := []Document{
+		{ID: "a", Text: "cats", Vector: []float32{1, 0}, Metadata: map[string]string{"lang": "en"}},
+		{ID: "b", Text: "dogs", Vector: []float32{0.7, 0.7}, Metadata: map[string]string{"lang": "en"}},
+		{ID: "c", Text: "chats", Vector: []float32{1, 0.1}, Metadata: map[string]string{"lang": "fr"}},
+	}
+	if err := store.Upsert(ctx, "pets", docs); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := store.Query(ctx, "pets", []float32{1, 0}, 2, map[string]string{"lang": "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 || matches[0].ID != "a" || matches[1].ID != "b" {
+		t.Fatalf("unexpected matches: %+v", matches)
+	}
+
+	// a fresh store over the same directory sees persisted documents
+	reopened, _ := NewLocalStore(dir)
+	if err := reopened.Delete(ctx, "pets", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ = reopened.Query(ctx, "pets", []float32{1, 0}, 1, nil)
+	if len(matches) != 1 || matches[0].ID != "c" {
+		t.Fatalf("unexpected matches after delete: %+v", matches)
+	}
+}
+
+func TestStoresRoutePerCollection(t *testing.T) {
+	stores, err := NewStores(Config{
+		Default:     StoreConfig{Type: "local"},
+		Collections: map[string]StoreConfig{"docs": {Type: "qdrant", URL: "http://qdrant:6333"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stores.For("docs").(*QdrantStore); !ok {
+		t.Error("expected docs to use qdrant")
+	}
+	if _, ok := stores.For("other").(*LocalStore); !ok {
+		t.Error("expected other collections to use the local store")
+	}
+
+	if _, err := NewStores(Config{Default: StoreConfig{Type: "pgvector"}}); err == nil {
+		t.Error("expected pgvector without dsn to fail")
+	}
+}
@@ -0,0 +1,65 @@
+package rag
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPGVectorStoreGeneratesEscapedSQL(t *testing.T) {
+	var scripts []string
+	store := NewPGVectorStore("postgres://db")
+	store.Run = func(ctx context.Context, dsn, script string) ([]byte, error) {
+		scripts = append(scripts, script)
+		if strings.HasPrefix(script, "SELECT") {
+			return []byte(`[{"id":"a","text":"it's","metadata":{"lang":"en"},"score":0.8}]` + "\n"), nil
+		}
+		return nil, nil
+	}
+
+	ctx := context.Background()
+	doc := Document{ID: "a", Text: "it's", Vector: []float32{0.5, 1}, Metadata: map[string]string{"lang": "en"}}
+	if err := store.Upsert(ctx, "My Docs", []Document{doc}); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := store.Query(ctx, "My Docs", []float32{0.5, 1}, 2, map[string]string{"lang": "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(scripts[0], "CREATE TABLE IF NOT EXISTS bf_my_docs") || !strings.Contains(scripts[0], "vector(2)") {
+		t.Errorf("unexpected schema script: %s", scripts[0])
+	}
+	if !strings.Contains(scripts[1], "'it''s'") || !strings.Contains(scripts[1], "'[0.5,1]'") {
+		t.Errorf("unexpected upsert script: %s", scripts[1])
+	}
+	if !strings.Contains(scripts[2], `metadata @> '{"lang":"en"}'::jsonb`) || !strings.Contains(scripts[2], "LIMIT 2") {
+		t.Errorf("unexpected query script: %s", scripts[2])
+	}
+	if len(matches) != 1 || matches[0].Text != "it's" || matches[0].Score != 0.8 {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestLibpqEnvKeepsPasswordOutOfArguments(t *testing.T) {
+	for dsn, want := range map[string]string{
+		"postgres://rag:s3cr%40t@db:5433/rag?sslmode=require": "PGDATABASE=rag PGHOST=db PGPASSWORD=s3cr@t PGPORT=5433 PGSSLMODE=require PGUSER=rag",
+		"postgresql://db/rag": "PGDATABASE=rag PGHOST=db",
+		`host=db dbname=rag user=rag password='it\'s secret'`: "PGDATABASE=rag PGHOST=db PGPASSWORD=it's secret PGUSER=rag",
+		"service=rag": "PGSERVICE=rag",
+	} {
+		env, err := libpqEnv(dsn)
+		if err != nil {
+			t.Errorf("%s: %v", dsn, err)
+			continue
+		}
+		if got := strings.Join(env, " "); got != want {
+			t.Errorf("%s: env = %s\nwant %s", dsn, got, want)
+		}
+	}
+	for _, dsn := range []string{"postgres://db/rag?bogus=1", "host=db password='open", "host"} {
+		if _, err := libpqEnv(dsn); err == nil {
+			t.Errorf("%s: want an error", dsn)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// QdrantStore stores collections in a Qdrant server over its REST API
+type QdrantStore struct {
+	client restClient
+
+	mu      sync.Mutex
+	created map[string]bool
+}
+
+func NewQdrantStore(baseURL, apiKey string) *QdrantStore {
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["api-key"] = apiKey
+	}
+	if baseURL == "" {
+		baseURL = "http://localhost:6333"
+	}
+	return &QdrantStore{client: newRESTClient(baseURL, headers), created: make(map[string]bool)}
+}
+
+type qdrantPoint struct {
+	ID      string         `json:"id"`
+	Vector  []float32      `json:"vector,omitempty"`
+	Payload map[string]any `json:"payload,omitempty"`
+	Score   float64        `json:"score,omitempty"`
+}
+
+// pointID maps an arbitrary document ID onto the UUIDs Qdrant requires
+func pointID(id string) string {
+	sum := sha1.Sum([]byte(id))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func (s *QdrantStore) ensureCollection(ctx context.Context, collection string, dims int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created[collection] {
+		return nil
+	}
+
+	path := "/collections/" + url.PathEscape(collection)
+	status, err := s.client.do(ctx, http.MethodGet, path, nil, nil)
+	if status == http.StatusNotFound {
+		body := map[string]any{"vectors": map[string]any{"size": dims, "distance": "Cosine"}}
+		_, err = s.client.do(ctx, http.MethodPut, path, body, nil)
+	}
+	if err != nil {
+		return err
+	}
+	s.created[collection] = true
+	return nil
+}
+
+func (s *QdrantStore) Upsert(ctx context.Context, collection string, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	if err := s.ensureCollection(ctx, collection, len(docs[0].Vector)); err != nil {
+		return err
+	}
+
+	points := make([]qdrantPoint, len(docs))
+	for i, doc := range docs {
+		payload := map[string]any{"_id": doc.ID, "text": doc.Text}
+		for key, value := range doc.Metadata {
+			payload[key] = value
+		}
+		points[i] = qdrantPoint{ID: pointID(doc.ID), Vector: doc.Vector, Payload: payload}
+	}
+	_, err := s.client.do(ctx, http.MethodPut, "/collections/"+url.PathEscape(collection)+"/points?wait=true", map[string]any{"points": points}, nil)
+	return err
+}
+
+func (s *QdrantStore) Query(ctx context.Context, collection string, vector []float32, topK int, filter map[string]string) ([]Match, error) {
+	body := map[string]any{"vector": vector, "limit": topK, "with_payload": true}
+	if len(filter) > 0 {
+		var must []map[string]any
+		for key, value := range filter {
+			must = append(must, map[string]any{"key": key, "match": map[string]any{"value": value}})
+		}
+		body["filter"] = map[string]any{"must": must}
+	}
+
+	var resp struct {
+		Result []qdrantPoint `json:"result"`
+	}
+	if _, err := s.client.do(ctx, http.MethodPost, "/collections/"+url.PathEscape(collection)+"/points/search", body, &resp); err != nil {
+		return nil, err
+	}
+
+	matches := make([]Match, 0, len(resp.Result))
+	for _, point := range resp.Result {
+		doc := Document{ID: point.ID, Metadata: map[string]string{}}
+		for key, value := range point.Payload {
+			text, _ := value.(string)
+			switch key {
+			case "_id":
+				doc.ID = text
+			case "text":
+				doc.Text = text
+			default:
+				doc.Metadata[key] = fmt.Sprint(value)
+			}
+		}
+		matches = append(matches, Match{Document: doc, Score: point.Score})
+	}
+	return matches, nil
+}
+
+func (s *QdrantStore) Delete(ctx context.Context, collection string, ids []string) error {
+	points := make([]string, len(ids))
+	for i, id := range ids {
+		points[i] = pointID(id)
+	}
+	_, err := s.client.do(ctx, http.MethodPost, "/collections/"+url.PathEscape(collection)+"/points/delete?wait=true", map[string]any{"points": points}, nil)
+	return err
+}
@@ -0,0 +1,61 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQdrantStoreCreatesCollectionAndMapsPayload(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Header.Get("api-key") != "k" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/collections/docs/points/search":
+			json.NewEncoder(w).Encode(map[string]any{"result": []map[string]any{
+				{"id": pointID("doc-1"), "score": 0.9, "payload": map[string]any{"_id": "doc-1", "text": "hello", "source": "a.md"}},
+			}})
+		default:
+			w.Write([]byte(`{"result":true}`))
+		}
+	}))
+	defer server.Close()
+
+	store := NewQdrantStore(server.URL, "k")
+	ctx := context.Background()
+	if err := store.Upsert(ctx, "docs", []Document{{ID: "doc-1", Text: "hello", Vector: []float32{1, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	matches, err := store.Query(ctx, "docs", []float32{1, 0}, 3, map[string]string{"source": "a.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"GET /collections/docs", "PUT /collections/docs", "PUT /collections/docs/points", "POST /collections/docs/points/search"}
+	if len(calls) != len(want) {
+		t.Fatalf("unexpected calls: %v", calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call %d: want %s, got %s", i, want[i], calls[i])
+		}
+	}
+	if len(matches) != 1 || matches[0].ID != "doc-1" || matches[0].Text != "hello" || matches[0].Metadata["source"] != "a.md" {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+}
+
+func TestPointIDIsStableUUID(t *testing.T) {
+	id := pointID("doc-1")
+	if id != pointID("doc-1") || len(id) != 36 || id[14] != '5' {
+		t.Errorf("unexpected point id %q", id)
+	}
+}
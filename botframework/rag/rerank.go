@@ -0,0 +1,55 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Reranker scores documents against a query; scores are returned in input order
+type Reranker interface {
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+}
+
+// HTTPReranker calls a /v1/rerank endpoint (the Jina/Cohere shape served by llama.cpp,
+// vLLM and TEI), normally routed by the manager to a registered reranker model
+type HTTPReranker struct {
+	URL   string
+	Model string
+
+	client restClient
+}
+
+func NewHTTPReranker(url, model string) *HTTPReranker {
+	return &HTTPReranker{URL: url, Model: model, client: newRESTClient(url, nil)}
+}
+
+func (r *HTTPReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	body := map[string]any{"query": query, "documents": documents, "top_n": len(documents)}
+	if r.Model != "" {
+		body["model"] = r.Model
+	}
+	var resp struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if _, err := r.client.do(ctx, http.MethodPost, "", body, &resp); err != nil {
+		return nil, err
+	}
+
+	scores := make([]float64, len(documents))
+	seen := 0
+	for _, result := range resp.Results {
+		if result.Index < 0 || result.Index >= len(documents) {
+			return nil, fmt.Errorf("rerank: result index %d out of range", result.Index)
+		}
+		scores[result.Index] = result.RelevanceScore
+		seen++
+	}
+	if seen != len(documents) {
+		return nil, fmt.Errorf("rerank: expected %d scores, got %d", len(documents), seen)
+	}
+	return scores, nil
+}
@@ -0,0 +1,43 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPRerankerMapsScoresToInputOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model     string   `json:"model"`
+			Documents []string `json:"documents"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Model != "bge-reranker" || len(body.Documents) != 2 {
+			t.Errorf("unexpected request: %+v", body)
+		}
+		w.Write([]byte(`{"results":[{"index":1,"relevance_score":0.9},{"index":0,"relevance_score":0.1}]}`))
+	}))
+	defer server.Close()
+
+	scores, err := NewHTTPReranker(server.URL, "bge-reranker").Rerank(context.Background(), "q", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores[0] != 0.1 || scores[1] != 0.9 {
+		t.Errorf("unexpected scores: %v", scores)
+	}
+}
+
+func TestHTTPRerankerRejectsMissingScores(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"results":[{"index":0,"relevance_score":0.5}]}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewHTTPReranker(server.URL, "").Rerank(context.Background(), "q", []string{"a", "b"}); err == nil {
+		t.Error("expected an error when the reranker skips documents")
+	}
+}
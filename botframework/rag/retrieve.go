@@ -0,0 +1,188 @@
+package rag
+
+import (
+	"botframework/chat"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RetrievalOptions tunes a retrieval: how many candidates the vector store returns,
+// how many survive reranking, and how long the reranker may take
+type RetrievalOptions struct {
+	TopKIn       int
+	TopKOut      int
+	RerankBudget time.Duration
+	Filter       map[string]string
+}
+
+func DefaultRetrievalOptions() RetrievalOptions {
+	return RetrievalOptions{TopKIn: 20, TopKOut: 4, RerankBudget: 300 * time.Millisecond}
+}
+
+// Retrieval is the result of one query, with timings for tuning top-k and the budget
+type Retrieval struct {
+	Matches  []Match `json:"matches"`
+	Reranked bool    `json:"reranked"`
+	SearchMs float64 `json:"search_ms"`
+	RerankMs float64 `json:"rerank_ms,omitempty"`
+}
+
+// Retriever runs vector search followed by an optional rerank stage
+type Retriever struct {
+	Stores   *Stores
+	Embedder Embedder
+	// Reranker is optional; without it vector order is kept
+	Reranker Reranker
+	Defaults RetrievalOptions
+}
+
+func (rt *Retriever) options(opts RetrievalOptions) RetrievalOptions {
+	if opts.TopKIn <= 0 {
+		opts.TopKIn = rt.Defaults.TopKIn
+	}
+	if opts.TopKOut <= 0 {
+		opts.TopKOut = rt.Defaults.TopKOut
+	}
+	if opts.RerankBudget <= 0 {
+		opts.RerankBudget = rt.Defaults.RerankBudget
+	}
+	if opts.TopKOut > opts.TopKIn {
+		opts.TopKIn = opts.TopKOut
+	}
+	return opts
+}
+
+// Retrieve finds the documents in collection most relevant to query. A reranker that errors
+// or exceeds the budget is skipped rather than failing the request.
+func (rt *Retriever) Retrieve(ctx context.Context, collection, query string, opts RetrievalOptions) (Retrieval, error) {
+	opts = rt.options(opts)
+
+	start := time.Now()
+	vectors, err := rt.Embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return Retrieval{}, fmt.Errorf("embed query: %w", err)
+	}
+	matches, err := rt.Stores.For(collection).Query(ctx, collection, vectors[0], opts.TopKIn, opts.Filter)
+	if err != nil {
+		return Retrieval{}, err
+	}
+	result := Retrieval{SearchMs: msSince(start)}
+
+	if rt.Reranker != nil && len(matches) > 1 {
+		rerankStart := time.Now()
+		rerankCtx, cancel := context.WithTimeout(ctx, opts.RerankBudget)
+		texts := make([]string, len(matches))
+		for i, match := range matches {
+			texts[i] = match.Text
+		}
+		scores, err := rt.Reranker.Rerank(rerankCtx, query, texts)
+		cancel()
+		result.RerankMs = msSince(rerankStart)
+
+		if err != nil {
+			slog.WarnContext(ctx, "rerank skipped", "rerank_ms", result.RerankMs, "err", err)
+		} else {
+			for i := range matches {
+				matches[i].Score = scores[i]
+			}
+			sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+			result.Reranked = true
+		}
+	}
+
+	if len(matches) > opts.TopKOut {
+		matches = matches[:opts.TopKOut]
+	}
+	result.Matches = matches
+	return result, nil
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t)) / float64(time.Millisecond)
+}
+
+// AssembleContext formats retrieved documents as a system message for the model
+func AssembleContext(matches []Match) string {
+	var b strings.Builder
+	b.WriteString("Use the following context to answer. If it does not contain the answer, say so.\n")
+	for i, match := range matches {
+		fmt.Fprintf(&b, "\n[%d] (%s)\n%s\n", i+1, sourceOf(match), match.Text)
+	}
+	return b.String()
+}
+
+func sourceOf(match Match) string {
+	if source := match.Metadata["source"]; source != "" {
+		return source
+	}
+	return match.ID
+}
+
+// chatRAG is the gateway-only "rag" extension on chat completion requests
+type chatRAG struct {
+	Collection   string            `json:"collection"`
+	TopKIn       int               `json:"top_k_in"`
+	TopKOut      int               `json:"top_k_out"`
+	RerankBudget int               `json:"rerank_budget_ms"`
+	Filter       map[string]string `json:"filter"`
+	// Cite appends the sources the answer references to the response
+	Cite bool `json:"cite"`
+}
+
+// RetrievedHeader reports how many documents were injected into a chat request
+const RetrievedHeader = "X-BotFramework-Retrieved"
+
+// Middleware augments chat completions that carry a "rag" field: it retrieves context for the
+// latest user message, inserts it as a system message and strips the extension before proxying
+func (rt *Retriever) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := chat.Read(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var ext chatRAG
+		if !req.Get("rag", &ext) || ext.Collection == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		req.Delete("rag")
+
+		query := req.LastUserText()
+		opts := RetrievalOptions{
+			TopKIn:       ext.TopKIn,
+			TopKOut:      ext.TopKOut,
+			RerankBudget: time.Duration(ext.RerankBudget) * time.Millisecond,
+			Filter:       ext.Filter,
+		}
+		retrieval, err := rt.Retrieve(r.Context(), ext.Collection, query, opts)
+		if err != nil {
+			http.Error(w, "retrieval failed: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if len(retrieval.Matches) > 0 {
+			system := chat.Message{Role: "system", Content: AssembleContext(retrieval.Matches)}
+			// place retrieved context after any existing system prompt
+			insert := 0
+			for insert < len(req.Messages) && req.Messages[insert].Role == "system" {
+				insert++
+			}
+			req.Messages = append(req.Messages[:insert], append([]chat.Message{system}, req.Messages[insert:]...)...)
+			if ext.Cite {
+				chat.AddTransform(r, Citations(retrieval.Matches))
+			}
+		}
+		if err := req.Write(r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(RetrievedHeader, fmt.Sprintf("%d", len(retrieval.Matches)))
+		next.ServeHTTP(w, r)
+	})
+}
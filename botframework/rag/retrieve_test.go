@@ -0,0 +1,105 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reverseReranker scores documents in reverse input order, optionally after a delay
+type reverseReranker struct{ delay time.Duration }
+
+func (r reverseReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	select {
+	case <-time.After(r.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	scores := make([]float64, len(documents))
+	for i := range documents {
+		scores[i] = float64(i)
+	}
+	return scores, nil
+}
+
+func newTestRetriever(t *testing.T) *Retriever {
+	t.Helper()
+	stores, _ := NewStores(Config{})
+	docs := []Document{
+		{ID: "a", Text: "cat one", Vector: []float32{1, 0}, Metadata: map[string]string{"source": "a.md"}},
+		{ID: "b", Text: "cat two", Vector: []float32{0.9, 0.1}},
+		{ID: "c", Text: "dog", Vector: []float32{0, 1}},
+	}
+	if err := stores.Default.Upsert(context.Background(), "pets", docs); err != nil {
+		t.Fatal(err)
+	}
+	return &Retriever{Stores: stores, Embedder: &topicEmbedder{}, Defaults: DefaultRetrievalOptions()}
+}
+
+func TestRetrieveReranksWithinBudget(t *testing.T) {
+	rt := newTestRetriever(t)
+	rt.Reranker = reverseReranker{}
+
+	result, err := rt.Retrieve(context.Background(), "pets", "a cat", RetrievalOptions{TopKIn: 3, TopKOut: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// vector order is a, b, c; the reranker reverses it
+	if !result.Reranked || len(result.Matches) != 2 || result.Matches[0].ID != "c" || result.Matches[1].ID != "b" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestRetrieveFallsBackWhenRerankerIsSlow(t *testing.T) {
+	rt := newTestRetriever(t)
+	rt.Reranker = reverseReranker{delay: time.Second}
+
+	result, err := rt.Retrieve(context.Background(), "pets", "a cat", RetrievalOptions{TopKOut: 1, RerankBudget: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reranked || result.Matches[0].ID != "a" {
+		t.Errorf("expected vector order after budget expiry: %+v", result)
+	}
+}
+
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, errors.New("no embedding model")
+}
+
+func TestMiddlewareInjectsContext(t *testing.T) {
+	rt := newTestRetriever(t)
+	var forwarded string
+	handler := rt.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		forwarded = string(body)
+	}))
+
+	body := `{"model":"llama","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"tell me about cats"}],"rag":{"collection":"pets","top_k_out":1}}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+
+	if rec.Header().Get(RetrievedHeader) != "1" {
+		t.Errorf("expected one retrieved document, got %q", rec.Header().Get(RetrievedHeader))
+	}
+	if strings.Contains(forwarded, `"rag"`) || !strings.Contains(forwarded, "[1] (a.md)") {
+		t.Errorf("unexpected forwarded body: %s", forwarded)
+	}
+	if strings.Index(forwarded, "be brief") > strings.Index(forwarded, "[1] (a.md)") {
+		t.Errorf("retrieved context should follow the original system prompt: %s", forwarded)
+	}
+
+	rt.Embedder = failingEmbedder{}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body)))
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 when retrieval fails, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Document is a chunk of text with its embedding, as stored in a collection
+type Document struct {
+	ID       string            `json:"id"`
+	Text     string            `json:"text"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Vector   []float32         `json:"vector,omitempty"`
+}
+
+// Match is a document returned by a similarity query; higher scores are closer
+type Match struct {
+	Document
+	Score float64 `json:"score"`
+}
+
+// VectorStore persists documents and answers nearest-neighbour queries per collection
+type VectorStore interface {
+	Upsert(ctx context.Context, collection string, docs []Document) error
+	// Query returns up to topK documents closest to vector whose metadata contains every filter pair
+	Query(ctx context.Context, collection string, vector []float32, topK int, filter map[string]string) ([]Match, error)
+	Delete(ctx context.Context, collection string, ids []string) error
+}
+
+// StoreConfig selects and configures a VectorStore backend
+type StoreConfig struct {
+	Type     string `json:"type"` // local | qdrant | chroma | pgvector
+	Path     string `json:"path,omitempty"`
+	URL      string `json:"url,omitempty"`
+	APIKey   string `json:"api_key,omitempty"`
+	DSN      string `json:"dsn,omitempty"`
+	Tenant   string `json:"tenant,omitempty"`
+	Database string `json:"database,omitempty"`
+}
+
+// Config maps collections to stores; collections without an entry use Default
+type Config struct {
+	Default     StoreConfig            `json:"default"`
+	Collections map[string]StoreConfig `json:"collections,omitempty"`
+}
+
+// NewStore builds the backend described by cfg
+func NewStore(cfg StoreConfig) (VectorStore, error) {
+	switch cfg.Type {
+	case "", "local":
+		return NewLocalStore(cfg.Path)
+	case "qdrant":
+		return NewQdrantStore(cfg.URL, cfg.APIKey), nil
+	case "chroma":
+		return NewChromaStore(cfg.URL, cfg.Tenant, cfg.Database), nil
+	case "pgvector":
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("pgvector store requires a dsn")
+		}
+		return NewPGVectorStore(cfg.DSN), nil
+	default:
+		return nil, fmt.Errorf("unknown vector store type %q", cfg.Type)
+	}
+}
+
+// Stores routes each collection to its configured VectorStore
+type Stores struct {
+	Default     VectorStore
+	collections map[string]VectorStore
+}
+
+func NewStores(cfg Config) (*Stores, error) {
+	def, err := NewStore(cfg.Default)
+	if err != nil {
+		return nil, fmt.Errorf("default store: %w", err)
+	}
+	stores := &Stores{Default: def, collections: make(map[string]VectorStore)}
+	for name, storeCfg := range cfg.Collections {
+		store, err := NewStore(storeCfg)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", name, err)
+		}
+		stores.collections[name] = store
+	}
+	return stores, nil
+}
+
+// LoadStores reads a JSON Config from path
+func LoadStores(path string) (*Stores, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return NewStores(cfg)
+}
+
+// For returns the store backing collection
+func (s *Stores) For(collection string) VectorStore {
+	if store, ok := s.collections[collection]; ok {
+		return store
+	}
+	return s.Default
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func matchesFilter(metadata, filter map[string]string) bool {
+	for key, value := range filter {
+		if metadata[key] != value {
+			return false
+		}
+	}
+	return true
+}
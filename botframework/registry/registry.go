@@ -0,0 +1,268 @@
+// Package registry keeps the model registry in sync with a published copy, so scoring uses
+// current benchmark and variant data. Fetches are conditional (ETag, If-Modified-Since),
+// the last good copy is cached on disk for offline starts, and a configured Ed25519 key
+// rejects registries that were not signed with it.
+package registry
+
+import (
+	"botframework/profiler"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRefresh is how often Run checks the remote registry
+const DefaultRefresh = 6 * time.Hour
+
+// maxSize bounds a downloaded registry
+const maxSize = 16 << 20
+
+var ErrBadSignature = errors.New("registry signature does not verify")
+
+// Source fetches the registry from URL. With PublicKey set, URL + ".sig" must hold the
+// base64 Ed25519 signature of the registry file.
+type Source struct {
+	URL       string
+	CachePath string // last verified copy; its ETag and signature are kept in CachePath + ".meta"
+	PublicKey ed25519.PublicKey
+	Client    *http.Client
+
+	mu   sync.RWMutex
+	data []byte // raw registry, verified and parsed once
+	meta cacheMeta
+}
+
+// cacheMeta is what a conditional request and a later verification of the cache need
+type cacheMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Signature    string `json:"signature,omitempty"`
+}
+
+func New(url, cachePath string) *Source {
+	return &Source{URL: url, CachePath: cachePath, Client: &http.Client{Timeout: time.Minute}}
+}
+
+// DefaultCachePath returns ~/.cache/botframework/registry.json
+func DefaultCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "registry.json"
+	}
+	return filepath.Join(dir, "botframework", "registry.json")
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("registry public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("registry public key is %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Current returns a fresh copy of the newest registry: the last one fetched, else the
+// cached one. It returns nil when neither is available.
+func (s *Source) Current() *profiler.ModelRegistry {
+	s.mu.Lock()
+	if s.data == nil {
+		s.loadCache()
+	}
+	data := s.data
+	s.mu.Unlock()
+	if data == nil {
+		return nil
+	}
+	registry, err := profiler.ParseRegistry(data)
+	if err != nil {
+		return nil
+	}
+	return registry
+}
+
+// loadCache reads the cached copy, dropping it when it belongs to another URL or does not
+// verify. s.mu must be held.
+func (s *Source) loadCache() {
+	if s.CachePath == "" {
+		return
+	}
+	data, err := os.ReadFile(s.CachePath)
+	if err != nil {
+		return
+	}
+	var meta cacheMeta
+	if raw, err := os.ReadFile(s.CachePath + ".meta"); err == nil {
+		_ = json.Unmarshal(raw, &meta)
+	}
+	if meta.URL != s.URL {
+		return
+	}
+	if err := s.check(data, meta.Signature); err != nil {
+		slog.Warn("ignoring cached registry", "path", s.CachePath, "err", err)
+		return
+	}
+	s.data, s.meta = data, meta
+}
+
+// check verifies the signature, when a key is configured, and the schema
+func (s *Source) check(data []byte, signature string) error {
+	if s.PublicKey != nil {
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+		if err != nil || !ed25519.Verify(s.PublicKey, data, sig) {
+			return ErrBadSignature
+		}
+	}
+	_, err := profiler.ParseRegistry(data)
+	return err
+}
+
+// Sync fetches the registry unless the copy held is still current, and reports whether it
+// changed. A registry that fails verification or validation is rejected and the held
+// copy kept.
+func (s *Source) Sync(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	if s.data == nil {
+		s.loadCache()
+	}
+	meta := s.meta
+	held := s.data != nil
+	s.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	if held {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fetch registry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && held {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("fetch registry: %s returned %s", s.URL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return false, fmt.Errorf("fetch registry: %w", err)
+	}
+	if len(data) > maxSize {
+		return false, fmt.Errorf("fetch registry: larger than %d MB", maxSize>>20)
+	}
+
+	next := cacheMeta{URL: s.URL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	if s.PublicKey != nil {
+		if next.Signature, err = s.fetchSignature(ctx); err != nil {
+			return false, err
+		}
+	}
+	if err := s.check(data, next.Signature); err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	changed := !bytes.Equal(s.data, data)
+	s.data, s.meta = data, next
+	s.mu.Unlock()
+	if err := s.writeCache(data, next); err != nil {
+		slog.Warn("registry cache not written", "path", s.CachePath, "err", err)
+	}
+	return changed, nil
+}
+
+func (s *Source) fetchSignature(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL+".sig", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch registry signature: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch registry signature: %s.sig returned %s", s.URL, resp.Status)
+	}
+	sig, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return strings.TrimSpace(string(sig)), err
+}
+
+// writeCache replaces the cached copy and its metadata
+func (s *Source) writeCache(data []byte, meta cacheMeta) error {
+	if s.CachePath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.CachePath), 0o755); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(s.CachePath, data); err != nil {
+		return err
+	}
+	return writeFile(s.CachePath+".meta", raw)
+}
+
+// writeFile writes through a temporary file so readers never see a partial copy
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Run syncs every interval (DefaultRefresh when 0) until ctx is done, calling onChange
+// with each new registry
+func (s *Source) Run(ctx context.Context, interval time.Duration, onChange func(*profiler.ModelRegistry)) {
+	if interval <= 0 {
+		interval = DefaultRefresh
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := s.Sync(ctx)
+		switch {
+		case err != nil:
+			slog.Warn("registry refresh failed, keeping the current copy", "url", s.URL, "err", err)
+		case changed:
+			registry := s.Current()
+			slog.Info("registry updated", "url", s.URL, "models", len(registry.Models))
+			if onChange != nil {
+				onChange(registry)
+			}
+		}
+	}
+}
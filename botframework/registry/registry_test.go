@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const published = `{"schema_version":1,"models":[{"id":"phi-3-mini-4k","params_b":3.8}]}`
+
+// publisher serves body under /registry.json with an ETag, and sig under .sig
+func publisher(t *testing.T, body *string, sig *string) (*httptest.Server, *atomic.Int32) {
+	var full atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/registry.json":
+			sum := sha256.Sum256([]byte(*body))
+			etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			full.Add(1)
+			w.Header().Set("ETag", etag)
+			w.Write([]byte(*body))
+		case "/registry.json.sig":
+			w.Write([]byte(*sig))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &full
+}
+
+func TestSyncUsesETagAndCache(t *testing.T) {
+	body, sig := published, ""
+	server, full := publisher(t, &body, &sig)
+	cache := filepath.Join(t.TempDir(), "registry.json")
+	source := New(server.URL+"/registry.json", cache)
+
+	if changed, err := source.Sync(context.Background()); err != nil || !changed {
+		t.Fatalf("first sync: changed %v, err %v", changed, err)
+	}
+	if changed, err := source.Sync(context.Background()); err != nil || changed || full.Load() != 1 {
+		t.Fatalf("second sync: changed %v, err %v, %d full fetches", changed, err, full.Load())
+	}
+
+	// a new process starts from the cache and revalidates it
+	restarted := New(server.URL+"/registry.json", cache)
+	if registry := restarted.Current(); registry == nil || registry.Models[0].ID != "phi-3-mini-4k" {
+		t.Fatalf("cached registry = %+v", registry)
+	}
+	if changed, err := restarted.Sync(context.Background()); err != nil || changed || full.Load() != 1 {
+		t.Errorf("revalidation: changed %v, err %v, %d full fetches", changed, err, full.Load())
+	}
+
+	body = strings.Replace(published, "3.8", "3.9", 1)
+	if changed, err := restarted.Sync(context.Background()); err != nil || !changed || restarted.Current().Models[0].ParamsB != 3.9 {
+		t.Errorf("update: changed %v, err %v", changed, err)
+	}
+}
+
+func TestSyncRejectsNewerSchema(t *testing.T) {
+	body, sig := `{"schema_version":99,"models":[]}`, ""
+	server, _ := publisher(t, &body, &sig)
+	source := New(server.URL+"/registry.json", "")
+	if _, err := source.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Errorf("err = %v", err)
+	}
+	if source.Current() != nil {
+		t.Error("a rejected registry should not be served")
+	}
+}
+
+func TestSyncVerifiesSignature(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	body := published
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte(body)))
+	server, _ := publisher(t, &body, &sig)
+	cache := filepath.Join(t.TempDir(), "registry.json")
+
+	source := New(server.URL+"/registry.json", cache)
+	source.PublicKey = public
+	if _, err := source.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	body = strings.Replace(published, "3.8", "70", 1) // tampered, old signature
+	if _, err := source.Sync(context.Background()); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("err = %v, want ErrBadSignature", err)
+	}
+	if source.Current().Models[0].ParamsB != 3.8 {
+		t.Error("the verified copy should be kept")
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	stranger := New(server.URL+"/registry.json", cache)
+	stranger.PublicKey = other
+	if stranger.Current() != nil {
+		t.Error("a cache signed with another key should be ignored")
+	}
+}
@@ -0,0 +1,280 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const maxTraceBody = 8 << 20
+
+// sensitiveHeaders are never written to a trace
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+	"X-Api-Key":           true,
+}
+
+// Trace is one recorded gateway request
+type Trace struct {
+	Time      time.Time         `json:"time"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      json.RawMessage   `json:"body,omitempty"`
+	Status    int               `json:"status"`
+	LatencyMs int64             `json:"latency_ms"`
+}
+
+// Recorder appends sanitized traces of every request to a JSONL writer
+type Recorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			// only the recorded copy is capped; the handler reads the whole body
+			body, _ = io.ReadAll(io.LimitReader(r.Body, maxTraceBody+1))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		trace := Trace{
+			Time:      start,
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Headers:   sanitizeHeaders(r.Header),
+			Status:    sw.status,
+			LatencyMs: time.Since(start).Milliseconds(),
+		}
+		if len(body) <= maxTraceBody {
+			trace.Body = sanitizeBody(body)
+		}
+		rec.write(trace)
+	})
+}
+
+func (rec *Recorder) write(trace Trace) {
+	line, err := json.Marshal(trace)
+	if err != nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	_, _ = rec.w.Write(append(line, '\n'))
+}
+
+func sanitizeHeaders(h http.Header) map[string]string {
+	out := make(map[string]string)
+	for name, values := range h {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] || len(values) == 0 {
+			continue
+		}
+		out[name] = values[0]
+	}
+	return out
+}
+
+// sanitizeBody drops end-user identifiers from JSON bodies; non-JSON bodies are not recorded
+func sanitizeBody(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil
+	}
+	delete(payload, "user")
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// Load reads traces from a JSONL file
+func Load(path string) ([]Trace, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var traces []Trace
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxTraceBody*2)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var trace Trace
+		if err := json.Unmarshal(scanner.Bytes(), &trace); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		traces = append(traces, trace)
+	}
+	return traces, scanner.Err()
+}
+
+// Options controls a replay run
+type Options struct {
+	BaseURL     string
+	Model       string // overrides the recorded model when set
+	Concurrency int
+	Client      *http.Client
+}
+
+// Report summarises a replay run
+type Report struct {
+	Requests     int         `json:"requests"`
+	Errors       int         `json:"errors"`
+	StatusCounts map[int]int `json:"status_counts"`
+	LatencyP50Ms int64       `json:"latency_p50_ms"`
+	LatencyP95Ms int64       `json:"latency_p95_ms"`
+	Mismatches   int         `json:"status_mismatches"`
+	Duration     string      `json:"duration"`
+}
+
+// Run replays traces against opts.BaseURL with opts.Concurrency workers
+func Run(ctx context.Context, traces []Trace, opts Options) Report {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Minute}
+	}
+
+	type result struct {
+		status   int
+		latency  time.Duration
+		recorded int
+	}
+	jobs := make(chan Trace)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for trace := range jobs {
+				start := time.Now()
+				status := send(ctx, opts, trace)
+				results <- result{status: status, latency: time.Since(start), recorded: trace.Status}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, trace := range traces {
+			select {
+			case jobs <- trace:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	started := time.Now()
+	report := Report{StatusCounts: make(map[int]int)}
+	var latencies []time.Duration
+	for res := range results {
+		report.Requests++
+		report.StatusCounts[res.status]++
+		if res.status == 0 || res.status >= http.StatusInternalServerError {
+			report.Errors++
+		}
+		if res.status != res.recorded {
+			report.Mismatches++
+		}
+		latencies = append(latencies, res.latency)
+	}
+	report.Duration = time.Since(started).Round(time.Millisecond).String()
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.LatencyP50Ms = latencies[(len(latencies)-1)/2].Milliseconds()
+		report.LatencyP95Ms = latencies[int(0.95*float64(len(latencies)-1))].Milliseconds()
+	}
+	return report
+}
+
+// send replays one trace and returns the status code, or 0 on transport errors
+func send(ctx context.Context, opts Options, trace Trace) int {
+	body := []byte(trace.Body)
+	if opts.Model != "" && len(body) > 0 {
+		var payload map[string]any
+		if err := json.Unmarshal(body, &payload); err == nil {
+			payload["model"] = opts.Model
+			body, _ = json.Marshal(payload)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, trace.Method, strings.TrimRight(opts.BaseURL, "/")+trace.Path, bytes.NewReader(body))
+	if err != nil {
+		return 0
+	}
+	for name, value := range trace.Headers {
+		req.Header.Set(name, value)
+	}
+	if opts.Model != "" {
+		req.Header.Set("X-Model", opts.Model)
+	}
+
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
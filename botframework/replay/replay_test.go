@@ -0,0 +1,98 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRecorderSanitizesTraces(t *testing.T) {
+	var out bytes.Buffer
+	h := NewRecorder(&out).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"user"`) {
+			t.Errorf("upstream must still receive the original body, got %s", body)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"qwen","user":"alice","messages":[]}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var trace Trace
+	if err := json.Unmarshal(out.Bytes(), &trace); err != nil {
+		t.Fatalf("decode trace: %v", err)
+	}
+	if _, ok := trace.Headers["Authorization"]; ok {
+		t.Fatal("authorization header must not be recorded")
+	}
+	if strings.Contains(string(trace.Body), "alice") {
+		t.Fatalf("user field must be stripped, got %s", trace.Body)
+	}
+	if trace.Status != http.StatusAccepted || trace.Path != "/v1/chat/completions" {
+		t.Fatalf("unexpected trace: %+v", trace)
+	}
+}
+
+func TestRecorderPassesLargeBodiesWhole(t *testing.T) {
+	var out bytes.Buffer
+	large := `{"model":"qwen","prompt":"` + strings.Repeat("a", maxTraceBody) + `"}`
+	var received int
+	h := NewRecorder(&out).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = len(body)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(large)))
+
+	if received != len(large) {
+		t.Fatalf("handler read %d bytes, want %d", received, len(large))
+	}
+	var trace Trace
+	if err := json.Unmarshal(out.Bytes(), &trace); err != nil {
+		t.Fatalf("decode trace: %v", err)
+	}
+	if trace.Body != nil {
+		t.Fatalf("a body over the cap must not be recorded, got %d bytes", len(trace.Body))
+	}
+}
+
+func TestRunReplaysWithModelOverride(t *testing.T) {
+	var seen atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if payload["model"] != "candidate" || r.Header.Get("X-Model") != "candidate" {
+			t.Errorf("expected model override, got body=%v header=%q", payload["model"], r.Header.Get("X-Model"))
+		}
+		seen.Add(1)
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	trace := `{"method":"POST","path":"/v1/chat/completions","body":{"model":"qwen"},"status":200}`
+	if err := os.WriteFile(path, []byte(trace+"\n"+trace+"\n\n"+trace+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	traces, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	report := Run(context.Background(), traces, Options{BaseURL: ts.URL, Model: "candidate", Concurrency: 2, Client: ts.Client()})
+	if report.Requests != 3 || seen.Load() != 3 {
+		t.Fatalf("expected 3 replayed requests, got report=%d server=%d", report.Requests, seen.Load())
+	}
+	if report.Errors != 0 || report.Mismatches != 0 || report.StatusCounts[http.StatusOK] != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
@@ -1,7 +1,7 @@
 """Pydantic schemas for REST API payloads."""
 # pylint: disable=too-few-public-methods,import-error
 import time
-from typing import Dict, List, Optional, TypedDict, Union
+from typing import Any, Dict, List, Optional, TypedDict, Union
 
 from pydantic import BaseModel, Field
 
@@ -32,6 +32,9 @@ class ChatCompletionRequest(BaseModel):
     # Additional parameters for llama.cpp
     top_k: Optional[int] = 40
     repeat_penalty: Optional[float] = 1.1
+    # {"type": "json_object", "schema": {...}} constrains the output with a grammar; the
+    # manager sets it to emulate tool calls
+    response_format: Optional[Dict[str, Any]] = None
 
 class ChatCompletionResponseChoice(BaseModel):
     """A single choice in a chat completion response."""
@@ -74,8 +77,39 @@ class ChatCompletionChunk(BaseModel):
     choices: List[ChatCompletionChunkChoice]
 
 
+class EmbeddingRequest(BaseModel):
+    """Request body for embeddings."""
+    model: Optional[str] = None
+    input: Union[str, List[str]]
+    encoding_format: Optional[str] = "float"
+    user: Optional[str] = None
+
+class EmbeddingData(BaseModel):
+    """One input's embedding vector."""
+    object: str = "embedding"
+    index: int
+    embedding: List[float]
+
+class EmbeddingUsage(BaseModel):
+    """Token usage for an embeddings request."""
+    prompt_tokens: int
+    total_tokens: int
+
+class EmbeddingResponse(BaseModel):
+    """Embeddings response."""
+    object: str = "list"
+    model: str
+    data: List[EmbeddingData]
+    usage: EmbeddingUsage
+
+
 class HealthResponse(BaseModel):
     """Health check response."""
     status: str
     model_loaded: bool
     model: str
+
+
+class AbortRequest(BaseModel):
+    """Request to stop generating for a request the manager abandoned."""
+    request_id: str
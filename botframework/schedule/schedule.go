@@ -0,0 +1,275 @@
+package schedule
+
+import (
+	"botframework/engine"
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy actions
+const (
+	ActionWarm   = "warm"   // keep the model loaded throughout the window
+	ActionUnload = "unload" // unload the model when the window opens
+)
+
+// Window is a daily time range on selected weekdays. End before Start wraps past midnight,
+// and such a window belongs to the day it starts on.
+type Window struct {
+	Days  [7]bool // indexed by time.Weekday
+	Start int     // minutes after midnight
+	End   int
+}
+
+// Contains reports whether t falls inside the window
+func (w Window) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start <= w.End {
+		return w.Days[t.Weekday()] && minute >= w.Start && minute < w.End
+	}
+	if minute >= w.Start {
+		return w.Days[t.Weekday()]
+	}
+	yesterday := (t.Weekday() + 6) % 7
+	return minute < w.End && w.Days[yesterday]
+}
+
+// Policy applies an action to a model during a window
+type Policy struct {
+	Model  string `json:"model"`
+	Action string `json:"action"`
+	Spec   string `json:"window"`
+	Window Window `json:"-"`
+}
+
+// ModelController loads and unloads models; *engine.ModelManager satisfies it
+type ModelController interface {
+	EnsureLoaded(model string) (engine.InferenceEngine, error)
+	Unload(model string) error
+	ListModels() []string
+}
+
+// PolicyStatus reports whether a policy is currently in effect
+type PolicyStatus struct {
+	Policy
+	Active    bool   `json:"active"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Scheduler keeps models warm or unloads them according to time-based policies. Warm
+// windows are enforced continuously, so a model that crashes or is unloaded inside one is
+// brought back; unload windows act once when they open, so on-demand loads still work
+// overnight.
+type Scheduler struct {
+	Policies   []Policy
+	Controller ModelController
+	Interval   time.Duration
+	Location   *time.Location
+
+	mu     sync.Mutex
+	active []bool
+	errors []string
+	now    func() time.Time
+}
+
+func New(controller ModelController, policies []Policy) *Scheduler {
+	return &Scheduler{
+		Policies:   policies,
+		Controller: controller,
+		Interval:   time.Minute,
+		Location:   time.Local,
+		active:     make([]bool, len(policies)),
+		errors:     make([]string, len(policies)),
+		now:        time.Now,
+	}
+}
+
+// Run evaluates the policies every Interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		s.Tick()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick applies the policies for the current time. A model inside both a warm and an unload
+// window stays loaded.
+func (s *Scheduler) Tick() {
+	now := s.now().In(s.Location)
+	warm := make(map[string]bool)
+	opened := make([]bool, len(s.Policies))
+
+	s.mu.Lock()
+	for i, policy := range s.Policies {
+		active := policy.Window.Contains(now)
+		if active && policy.Action == ActionWarm {
+			warm[policy.Model] = true
+		}
+		opened[i] = active && !s.active[i]
+		s.active[i] = active
+	}
+	s.mu.Unlock()
+
+	loaded := make(map[string]bool)
+	for _, model := range s.Controller.ListModels() {
+		loaded[model] = true
+	}
+	for i, policy := range s.Policies {
+		var err error
+		switch {
+		case policy.Action == ActionWarm && warm[policy.Model] && !loaded[policy.Model]:
+			slog.Info("schedule warming model", "model", policy.Model, "spec", policy.Spec)
+			_, err = s.Controller.EnsureLoaded(policy.Model)
+			loaded[policy.Model] = err == nil
+		case policy.Action == ActionUnload && opened[i] && !warm[policy.Model] && loaded[policy.Model]:
+			slog.Info("schedule unloading model", "model", policy.Model, "spec", policy.Spec)
+			err = s.Controller.Unload(policy.Model)
+			loaded[policy.Model] = err != nil
+		}
+		if err != nil {
+			slog.Warn("schedule action failed", "action", policy.Action, "model", policy.Model, "err", err)
+		}
+		s.mu.Lock()
+		if err != nil {
+			s.errors[i] = err.Error()
+		} else if s.active[i] {
+			s.errors[i] = ""
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Pinned reports whether a warm window currently holds model, so idle unloading can skip it
+func (s *Scheduler) Pinned(model string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, policy := range s.Policies {
+		if policy.Model == model && policy.Action == ActionWarm && s.active[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Status lists every policy with whether it is in effect
+func (s *Scheduler) Status() []PolicyStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]PolicyStatus, len(s.Policies))
+	for i, policy := range s.Policies {
+		out[i] = PolicyStatus{Policy: policy, Active: s.active[i], LastError: s.errors[i]}
+	}
+	return out
+}
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse reads semicolon-separated policies of the form "MODEL: ACTION DAYS HH:MM-HH:MM", e.g.
+//
+//	coder: warm weekdays 09:00-18:00; coder: unload daily 22:00-06:00; bge-m3: warm daily 01:30-04:00
+//
+// DAYS is daily, weekdays, weekends, a range like mon-fri or a list like mon,wed,fri.
+func Parse(spec string) ([]Policy, error) {
+	var policies []Policy
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, rest, ok := strings.Cut(entry, ":")
+		fields := strings.Fields(rest)
+		if !ok || strings.TrimSpace(model) == "" || len(fields) != 3 {
+			return nil, fmt.Errorf("policy %q: want \"MODEL: ACTION DAYS HH:MM-HH:MM\"", entry)
+		}
+		action := strings.ToLower(fields[0])
+		if action != ActionWarm && action != ActionUnload {
+			return nil, fmt.Errorf("policy %q: unknown action %q (want warm or unload)", entry, fields[0])
+		}
+		window, err := ParseWindow(fields[1], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("policy %q: %w", entry, err)
+		}
+		policies = append(policies, Policy{
+			Model:  strings.TrimSpace(model),
+			Action: action,
+			Spec:   fields[1] + " " + fields[2],
+			Window: window,
+		})
+	}
+	sort.SliceStable(policies, func(i, j int) bool { return policies[i].Model < policies[j].Model })
+	return policies, nil
+}
+
+// ParseWindow parses a day selection and an HH:MM-HH:MM time range
+func ParseWindow(days, hours string) (Window, error) {
+	var w Window
+	switch strings.ToLower(days) {
+	case "daily":
+		w.Days = [7]bool{true, true, true, true, true, true, true}
+	case "weekdays":
+		w.Days = [7]bool{false, true, true, true, true, true, false}
+	case "weekends":
+		w.Days = [7]bool{true, false, false, false, false, false, true}
+	default:
+		for _, part := range strings.Split(strings.ToLower(days), ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			start, ok := dayNames[from]
+			if !ok {
+				return w, fmt.Errorf("unknown day %q", from)
+			}
+			end := start
+			if isRange {
+				if end, ok = dayNames[to]; !ok {
+					return w, fmt.Errorf("unknown day %q", to)
+				}
+			}
+			for d := start; ; d = (d + 1) % 7 {
+				w.Days[d] = true
+				if d == end {
+					break
+				}
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(hours, "-")
+	if !ok {
+		return w, fmt.Errorf("time range %q: want HH:MM-HH:MM", hours)
+	}
+	var err error
+	if w.Start, err = parseClock(from); err != nil {
+		return w, err
+	}
+	if w.End, err = parseClock(to); err != nil {
+		return w, err
+	}
+	if w.Start == w.End {
+		return w, fmt.Errorf("time range %q is empty", hours)
+	}
+	return w, nil
+}
+
+func parseClock(s string) (int, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	h, errH := strconv.Atoi(hh)
+	m, errM := strconv.Atoi(mm)
+	// 24:00 is accepted as the end of the day
+	if !ok || errH != nil || errM != nil || h < 0 || m < 0 || m > 59 || h > 24 || h == 24 && m != 0 {
+		return 0, fmt.Errorf("invalid time %q (want HH:MM)", s)
+	}
+	return h*60 + m, nil
+}
@@ -0,0 +1,136 @@
+package schedule
+
+import (
+	"botframework/engine"
+	"sort"
+	"testing"
+	"time"
+)
+
+type fakeController struct {
+	loaded map[string]bool
+	loads  int
+}
+
+func (f *fakeController) EnsureLoaded(model string) (engine.InferenceEngine, error) {
+	f.loaded[model] = true
+	f.loads++
+	return nil, nil
+}
+
+func (f *fakeController) Unload(model string) error {
+	delete(f.loaded, model)
+	return nil
+}
+
+func (f *fakeController) ListModels() []string {
+	var names []string
+	for name := range f.loaded {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func at(day time.Weekday, clock string) time.Time {
+	// 2024-01-07 is a Sunday
+	t, _ := time.ParseInLocation("2006-01-02 15:04", "2024-01-07 "+clock, time.UTC)
+	return t.AddDate(0, 0, int(day))
+}
+
+func TestWindowContains(t *testing.T) {
+	office, err := ParseWindow("mon-fri", "09:00-18:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overnight, err := ParseWindow("fri,sat", "22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		window Window
+		t      time.Time
+		want   bool
+	}{
+		{office, at(time.Monday, "09:00"), true},
+		{office, at(time.Friday, "17:59"), true},
+		{office, at(time.Friday, "18:00"), false},
+		{office, at(time.Saturday, "12:00"), false},
+		{overnight, at(time.Friday, "23:00"), true},
+		{overnight, at(time.Saturday, "05:00"), true},  // Friday night's window
+		{overnight, at(time.Sunday, "05:00"), true},    // Saturday night's window
+		{overnight, at(time.Monday, "05:00"), false},   // no Sunday window
+		{overnight, at(time.Thursday, "23:00"), false}, // Thursday not selected
+	}
+	for i, c := range cases {
+		if got := c.window.Contains(c.t); got != c.want {
+			t.Errorf("case %d (%s): want %v, got %v", i, c.t.Format("Mon 15:04"), c.want, got)
+		}
+	}
+}
+
+func TestParseRejectsBadPolicies(t *testing.T) {
+	for _, spec := range []string{
+		"coder warm daily 09:00-18:00",
+		"coder: sleep daily 09:00-18:00",
+		"coder: warm someday 09:00-18:00",
+		"coder: warm daily 9-18",
+		"coder: warm daily 25:00-26:00",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("want error for %q", spec)
+		}
+	}
+}
+
+func TestSchedulerWarmsAndUnloads(t *testing.T) {
+	policies, err := Parse("coder: warm weekdays 09:00-18:00; coder: unload daily 22:00-06:00; bge-m3: warm daily 01:30-04:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := &fakeController{loaded: map[string]bool{}}
+	s := New(controller, policies)
+	s.Location = time.UTC
+
+	now := at(time.Monday, "08:00")
+	s.now = func() time.Time { return now }
+
+	s.Tick()
+	if len(controller.loaded) != 0 {
+		t.Fatalf("nothing should load before the windows: %v", controller.loaded)
+	}
+
+	now = at(time.Monday, "09:00")
+	s.Tick()
+	if !controller.loaded["coder"] || !s.Pinned("coder") {
+		t.Fatal("coder should be warm and pinned during office hours")
+	}
+
+	// A crash inside the warm window is repaired on the next tick
+	delete(controller.loaded, "coder")
+	s.Tick()
+	if !controller.loaded["coder"] {
+		t.Fatal("coder should be reloaded inside its warm window")
+	}
+
+	now = at(time.Monday, "22:00")
+	s.Tick()
+	if controller.loaded["coder"] || s.Pinned("coder") {
+		t.Fatal("coder should be unloaded overnight")
+	}
+
+	// An on-demand load during the unload window is left alone
+	controller.loaded["coder"] = true
+	now = at(time.Monday, "23:00")
+	s.Tick()
+	if !controller.loaded["coder"] {
+		t.Fatal("unload windows should only act when they open")
+	}
+
+	now = at(time.Tuesday, "01:30")
+	s.Tick()
+	if !controller.loaded["bge-m3"] {
+		t.Fatal("bge-m3 should be preloaded before the batch window")
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// launchdPlan installs a launchd agent, which runs in the user's session with access to the
+// GPU. It is restarted when it exits with an error, not after a clean stop, and its output
+// is appended to the log.
+func launchdPlan(cfg Config) Plan {
+	label := "com." + cfg.Name + ".manager"
+	plistPath := filepath.Join(cfg.Home, "Library/LaunchAgents", label+".plist")
+	logDir := cmp.Or(cfg.LogDir, filepath.Join(cfg.Home, "Library/Logs", cfg.Name))
+	logPath := filepath.Join(logDir, "manager.log")
+
+	var plist strings.Builder
+	plist.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	key := func(name, value string) {
+		fmt.Fprintf(&plist, "\t<key>%s</key>\n\t<string>%s</string>\n", name, escapeXML(value))
+	}
+	key("Label", label)
+	plist.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{cfg.Executable}, cfg.Args...) {
+		fmt.Fprintf(&plist, "\t\t<string>%s</string>\n", escapeXML(arg))
+	}
+	plist.WriteString("\t</array>\n")
+	key("WorkingDirectory", cfg.WorkDir)
+	plist.WriteString("\t<key>EnvironmentVariables</key>\n\t<dict>\n")
+	for _, name := range sortedEnv(cfg.Env) {
+		fmt.Fprintf(&plist, "\t\t<key>%s</key>\n\t\t<string>%s</string>\n", escapeXML(name), escapeXML(cfg.Env[name]))
+	}
+	plist.WriteString("\t</dict>\n")
+	plist.WriteString(`	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ThrottleInterval</key>
+	<integer>5</integer>
+	<key>ExitTimeOut</key>
+	<integer>30</integer>
+`)
+	key("StandardOutPath", logPath)
+	key("StandardErrorPath", logPath)
+	plist.WriteString("</dict>\n</plist>\n")
+
+	return Plan{
+		Dirs:      []string{logDir},
+		Files:     []File{{Path: plistPath, Content: []byte(plist.String()), Mode: 0o600}},
+		Install:   [][]string{{"launchctl", "load", "-w", plistPath}},
+		Start:     [][]string{{"launchctl", "start", label}},
+		Stop:      [][]string{{"launchctl", "stop", label}},
+		Uninstall: [][]string{{"launchctl", "unload", "-w", plistPath}},
+		LogPath:   logPath,
+	}
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,142 @@
+// Package service registers the manager with the host's init system, so it starts at boot,
+// restarts after a crash and logs to a file: a systemd unit on Linux, a launchd agent on
+// macOS and a scheduled task on Windows.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Config describes the manager as a service
+type Config struct {
+	// Name names the unit, agent or task; the label of the launchd agent is derived from it
+	Name string
+	// Executable is the absolute path of the manager binary, Args its arguments
+	Executable string
+	Args       []string
+	// WorkDir is where the manager runs, the directory botframework.yaml and relative
+	// paths are read from
+	WorkDir string
+	// Env is the manager's environment. It is kept in a file only the owner reads, since it
+	// may hold tokens.
+	Env map[string]string
+	// LogDir receives manager.log; empty picks the platform's log directory
+	LogDir string
+	// User installs a per-user service: a systemd user unit, or a task that starts at logon
+	// rather than boot. launchd agents always are.
+	User bool
+	// Home is the user's home directory, for per-user paths
+	Home string
+}
+
+// File is a file a plan writes
+type File struct {
+	Path    string
+	Content []byte
+	Mode    os.FileMode
+}
+
+// Plan is how one init system installs, starts, stops and removes the manager
+type Plan struct {
+	// Dirs are created before the files are written
+	Dirs  []string
+	Files []File
+	// The commands each action runs, in order: Install after the files are written,
+	// Uninstall before they are removed and Reload after
+	Install, Start, Stop, Uninstall, Reload [][]string
+	// LogPath is where the manager's output goes
+	LogPath string
+}
+
+// For plans the service on goos
+func For(goos string, cfg Config) (Plan, error) {
+	if cfg.Name == "" {
+		return Plan{}, errors.New("the service needs a name")
+	}
+	if !filepath.IsAbs(cfg.Executable) {
+		return Plan{}, fmt.Errorf("the manager binary %q is not an absolute path", cfg.Executable)
+	}
+	switch goos {
+	case "linux":
+		return systemdPlan(cfg), nil
+	case "darwin":
+		return launchdPlan(cfg), nil
+	case "windows":
+		return windowsPlan(cfg), nil
+	}
+	return Plan{}, fmt.Errorf("services are not supported on %s", goos)
+}
+
+// WriteFiles creates the plan's directories and files
+func (p Plan) WriteFiles() error {
+	for _, dir := range p.Dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	for _, file := range p.Files {
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file.Path, file.Content, file.Mode); err != nil {
+			return err
+		}
+		// WriteFile keeps the mode of a file that already exists
+		if err := os.Chmod(file.Path, file.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveFiles deletes the plan's files, leaving the logs
+func (p Plan) RemoveFiles() error {
+	var errs []error
+	for _, file := range p.Files {
+		if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run runs commands in order, echoing each to out, and stops at the first that fails
+func Run(ctx context.Context, out io.Writer, commands [][]string) error {
+	for _, command := range commands {
+		fmt.Fprintf(out, "$ %s\n", shellQuote(command))
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+		cmd.Stdout, cmd.Stderr = out, out
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", command[0], err)
+		}
+	}
+	return nil
+}
+
+// sortedEnv lists env by name, so rendered files are stable
+func sortedEnv(env map[string]string) []string {
+	return slices.Sorted(maps.Keys(env))
+}
+
+func shellQuote(args []string) string {
+	quoted := ""
+	for i, arg := range args {
+		if i > 0 {
+			quoted += " "
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t\"'$\\") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+		quoted += arg
+	}
+	return quoted
+}
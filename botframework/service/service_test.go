@@ -0,0 +1,174 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func testConfig() Config {
+	return Config{
+		Name:       "botframework",
+		Executable: "/opt/botframework/bin/manager",
+		Args:       []string{"--listen", ":8080", "--label", "100% $HOME"},
+		WorkDir:    "/opt/botframework",
+		Env:        map[string]string{"BOTFRAMEWORK_MODEL_PATH": "/models/phi 3.gguf", "HF_TOKEN": `hf_"secret"`},
+		Home:       "/home/ada",
+	}
+}
+
+func TestSystemdPlan(t *testing.T) {
+	plan, err := For("linux", testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Files) != 2 || plan.Files[0].Path != "/etc/systemd/system/botframework.service" || plan.Files[1].Mode != 0o600 {
+		t.Fatalf("files = %+v", plan.Files)
+	}
+	unit := string(plan.Files[0].Content)
+	for _, want := range []string{
+		`ExecStart="/opt/botframework/bin/manager" "--listen" ":8080" "--label" "100%% $$HOME"`,
+		"WorkingDirectory=/opt/botframework\n",
+		"EnvironmentFile=/etc/botframework/botframework.env\n",
+		"Restart=on-failure\n", "KillMode=mixed\n",
+		"StandardOutput=append:/var/log/botframework/manager.log\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit lacks %q:\n%s", want, unit)
+		}
+	}
+	if env := string(plan.Files[1].Content); env != "BOTFRAMEWORK_MODEL_PATH=\"/models/phi 3.gguf\"\nHF_TOKEN=\"hf_\\\"secret\\\"\"\n" {
+		t.Errorf("env file:\n%s", env)
+	}
+	if want := [][]string{{"systemctl", "daemon-reload"}, {"systemctl", "enable", "botframework.service"}}; !equalCommands(plan.Install, want) {
+		t.Errorf("install = %v", plan.Install)
+	}
+
+	cfg := testConfig()
+	cfg.User = true
+	plan, _ = For("linux", cfg)
+	if plan.Files[0].Path != "/home/ada/.config/systemd/user/botframework.service" || plan.LogPath != "/home/ada/.local/state/botframework/manager.log" {
+		t.Errorf("user unit at %s, logging to %s", plan.Files[0].Path, plan.LogPath)
+	}
+	if !strings.Contains(string(plan.Files[0].Content), "WantedBy=default.target") || plan.Start[0][1] != "--user" {
+		t.Errorf("user unit:\n%s\nstart = %v", plan.Files[0].Content, plan.Start)
+	}
+}
+
+func TestLaunchdPlan(t *testing.T) {
+	plan, err := For("darwin", testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Files[0].Path != "/home/ada/Library/LaunchAgents/com.botframework.manager.plist" || plan.Files[0].Mode != 0o600 {
+		t.Fatalf("files = %+v", plan.Files)
+	}
+	plist := string(plan.Files[0].Content)
+	if err := xml.Unmarshal(plan.Files[0].Content, new(struct{})); err != nil {
+		t.Fatalf("plist is not XML: %v\n%s", err, plist)
+	}
+	for _, want := range []string{"<string>100% $HOME</string>", "<string>hf_&#34;secret&#34;</string>",
+		"<key>SuccessfulExit</key>\n\t\t<false/>", "<string>/home/ada/Library/Logs/botframework/manager.log</string>"} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist lacks %q:\n%s", want, plist)
+		}
+	}
+	if plan.Install[0][0] != "launchctl" || plan.Start[0][2] != "com.botframework.manager" {
+		t.Errorf("install = %v, start = %v", plan.Install, plan.Start)
+	}
+}
+
+func TestWindowsPlan(t *testing.T) {
+	cfg := testConfig()
+	cfg.User = true
+	plan, err := For("windows", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := string(plan.Files[0].Content)
+	for _, want := range []string{`set "HF_TOKEN=hf_"secret""`, `"--label" "100%% $HOME" >> "`, "goto run"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script lacks %q:\n%s", want, script)
+		}
+	}
+
+	raw := plan.Files[1].Content
+	if !bytes.HasPrefix(raw, []byte{0xff, 0xfe}) {
+		t.Fatalf("task XML lacks a UTF-16LE byte order mark: % x", raw[:4])
+	}
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = uint16(raw[2*i]) | uint16(raw[2*i+1])<<8
+	}
+	task := string(utf16.Decode(units[1:]))
+	if !strings.Contains(task, "<LogonTrigger>") || !strings.Contains(task, "<ExecutionTimeLimit>PT0S</ExecutionTimeLimit>") {
+		t.Errorf("task:\n%s", task)
+	}
+	if !slices.Contains(plan.Install[0], "/XML") || plan.Uninstall[0][1] != "/Delete" {
+		t.Errorf("install = %v, uninstall = %v", plan.Install, plan.Uninstall)
+	}
+}
+
+func TestForRejectsBadConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.Executable = "manager"
+	if _, err := For("linux", cfg); err == nil {
+		t.Error("a relative binary path should be rejected")
+	}
+	if _, err := For("plan9", testConfig()); err == nil {
+		t.Error("plan9 has no supported init system")
+	}
+}
+
+func TestWriteAndRemoveFiles(t *testing.T) {
+	dir := t.TempDir()
+	plan := Plan{
+		Dirs:  []string{filepath.Join(dir, "logs")},
+		Files: []File{{Path: filepath.Join(dir, "etc", "unit"), Content: []byte("unit"), Mode: 0o600}},
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "etc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// an earlier install left the file readable by everyone
+	if err := os.WriteFile(plan.Files[0].Path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.WriteFiles(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(plan.Files[0].Path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("unit: %v %v, want mode 0600", info, err)
+	}
+	if _, err := os.Stat(plan.Dirs[0]); err != nil {
+		t.Errorf("log dir: %v", err)
+	}
+	if err := plan.RemoveFiles(); err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.RemoveFiles(); err != nil {
+		t.Errorf("removing files already gone: %v", err)
+	}
+}
+
+func TestRunStopsAtFirstFailure(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("needs /bin/sh")
+	}
+	var out bytes.Buffer
+	err := Run(context.Background(), &out, [][]string{{"/bin/sh", "-c", "echo one"}, {"/bin/sh", "-c", "exit 3"}, {"/bin/sh", "-c", "echo three"}})
+	if err == nil || !strings.Contains(out.String(), "one") || strings.Contains(out.String(), "three") {
+		t.Errorf("err = %v, output:\n%s", err, out.String())
+	}
+}
+
+func equalCommands(a, b [][]string) bool {
+	return slices.EqualFunc(a, b, func(x, y []string) bool { return slices.Equal(x, y) })
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// systemdPlan installs a unit that restarts the manager when it fails and appends its
+// output to the log. SIGTERM only reaches the manager, which drains requests and stops its
+// workers; whatever is left of the group is killed once TimeoutStopSec runs out. A user
+// unit starts at boot only once lingering is enabled for the user.
+func systemdPlan(cfg Config) Plan {
+	systemctl := []string{"systemctl"}
+	unitPath := filepath.Join("/etc/systemd/system", cfg.Name+".service")
+	envPath := filepath.Join("/etc", cfg.Name, cfg.Name+".env")
+	logDir, wantedBy := cmp.Or(cfg.LogDir, filepath.Join("/var/log", cfg.Name)), "multi-user.target"
+	if cfg.User {
+		systemctl = append(systemctl, "--user")
+		unitPath = filepath.Join(cfg.Home, ".config/systemd/user", cfg.Name+".service")
+		envPath = filepath.Join(cfg.Home, ".config", cfg.Name, cfg.Name+".env")
+		logDir, wantedBy = cmp.Or(cfg.LogDir, filepath.Join(cfg.Home, ".local/state", cfg.Name)), "default.target"
+	}
+	logPath := filepath.Join(logDir, "manager.log")
+
+	var unit strings.Builder
+	fmt.Fprintf(&unit, `[Unit]
+Description=BotFramework manager (%s)
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+WorkingDirectory=%s
+EnvironmentFile=%s
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+KillMode=mixed
+TimeoutStopSec=30
+StandardOutput=append:%s
+StandardError=append:%s
+
+[Install]
+WantedBy=%s
+`, cfg.Name, strings.ReplaceAll(cfg.WorkDir, "%", "%%"), envPath, systemdCommand(append([]string{cfg.Executable}, cfg.Args...)), logPath, logPath, wantedBy)
+
+	var env strings.Builder
+	for _, name := range sortedEnv(cfg.Env) {
+		fmt.Fprintf(&env, "%s=\"%s\"\n", name, strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(cfg.Env[name]))
+	}
+
+	unitName := cfg.Name + ".service"
+	command := func(args ...string) []string { return append(append([]string(nil), systemctl...), args...) }
+	return Plan{
+		Dirs: []string{logDir},
+		Files: []File{
+			{Path: unitPath, Content: []byte(unit.String()), Mode: 0o644},
+			{Path: envPath, Content: []byte(env.String()), Mode: 0o600},
+		},
+		Install:   [][]string{command("daemon-reload"), command("enable", unitName)},
+		Start:     [][]string{command("start", unitName)},
+		Stop:      [][]string{command("stop", unitName)},
+		Uninstall: [][]string{command("disable", "--now", unitName)},
+		Reload:    [][]string{command("daemon-reload")},
+		LogPath:   logPath,
+	}
+}
+
+// systemdCommand quotes a command line for ExecStart, escaping the specifiers and variable
+// references systemd would expand
+func systemdCommand(args []string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$")
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = `"` + escape.Replace(arg) + `"`
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// windowsPlan registers a scheduled task, which needs no service wrapper in the manager
+// binary: it starts at boot as SYSTEM, or at logon for a per-user install, and never times
+// out. The task runs a script that sets the environment, appends the output to the log and
+// starts the manager again five seconds after it exits with an error.
+func windowsPlan(cfg Config) Plan {
+	dir := filepath.Join(cmp.Or(os.Getenv("ProgramData"), `C:\ProgramData`), cfg.Name)
+	if cfg.User {
+		dir = filepath.Join(cfg.Home, "AppData", "Local", cfg.Name)
+	}
+	logDir := cmp.Or(cfg.LogDir, filepath.Join(dir, "logs"))
+	logPath := filepath.Join(logDir, "manager.log")
+	scriptPath := filepath.Join(dir, cfg.Name+"-service.cmd")
+	taskPath := filepath.Join(dir, cfg.Name+"-task.xml")
+
+	// cmd expands %VAR% even inside quotes
+	escape := strings.NewReplacer("%", "%%")
+	var script strings.Builder
+	script.WriteString("@echo off\r\n")
+	for _, name := range sortedEnv(cfg.Env) {
+		fmt.Fprintf(&script, "set \"%s=%s\"\r\n", name, escape.Replace(cfg.Env[name]))
+	}
+	fmt.Fprintf(&script, "cd /d \"%s\"\r\n", escape.Replace(cfg.WorkDir))
+	command := make([]string, 0, len(cfg.Args)+1)
+	for _, arg := range append([]string{cfg.Executable}, cfg.Args...) {
+		command = append(command, `"`+escape.Replace(arg)+`"`)
+	}
+	fmt.Fprintf(&script, ":run\r\n%s >> \"%s\" 2>&1\r\n", strings.Join(command, " "), escape.Replace(logPath))
+	script.WriteString("if errorlevel 1 (\r\n  timeout /t 5 /nobreak >nul\r\n  goto run\r\n)\r\n")
+
+	trigger, principal := "<BootTrigger><Enabled>true</Enabled></BootTrigger>",
+		"<Principal id=\"Author\"><UserId>S-1-5-18</UserId><RunLevel>HighestAvailable</RunLevel></Principal>"
+	if cfg.User {
+		trigger, principal = "<LogonTrigger><Enabled>true</Enabled></LogonTrigger>",
+			"<Principal id=\"Author\"><LogonType>InteractiveToken</LogonType><RunLevel>LeastPrivilege</RunLevel></Principal>"
+	}
+	task := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-16"?>
+<Task version="1.2" xmlns="http://schemas.microsoft.com/windows/2004/02/mit/task">
+  <RegistrationInfo><Description>BotFramework manager (%s)</Description></RegistrationInfo>
+  <Triggers>%s</Triggers>
+  <Principals>%s</Principals>
+  <Settings>
+    <MultipleInstancesPolicy>IgnoreNew</MultipleInstancesPolicy>
+    <DisallowStartIfOnBatteries>false</DisallowStartIfOnBatteries>
+    <StopIfGoingOnBatteries>false</StopIfGoingOnBatteries>
+    <ExecutionTimeLimit>PT0S</ExecutionTimeLimit>
+    <RestartOnFailure><Interval>PT1M</Interval><Count>999</Count></RestartOnFailure>
+    <Enabled>true</Enabled>
+  </Settings>
+  <Actions Context="Author">
+    <Exec>
+      <Command>cmd.exe</Command>
+      <Arguments>/c "%s"</Arguments>
+      <WorkingDirectory>%s</WorkingDirectory>
+    </Exec>
+  </Actions>
+</Task>
+`, escapeXML(cfg.Name), trigger, principal, escapeXML(scriptPath), escapeXML(cfg.WorkDir))
+
+	return Plan{
+		Dirs: []string{logDir},
+		Files: []File{
+			{Path: scriptPath, Content: []byte(script.String()), Mode: 0o600},
+			{Path: taskPath, Content: utf16LE(task), Mode: 0o600},
+		},
+		Install:   [][]string{{"schtasks", "/Create", "/TN", cfg.Name, "/XML", taskPath, "/F"}},
+		Start:     [][]string{{"schtasks", "/Run", "/TN", cfg.Name}},
+		Stop:      [][]string{{"schtasks", "/End", "/TN", cfg.Name}},
+		Uninstall: [][]string{{"schtasks", "/Delete", "/TN", cfg.Name, "/F"}},
+		LogPath:   logPath,
+	}
+}
+
+// utf16LE encodes s with a byte order mark, the encoding schtasks reads task XML in
+func utf16LE(s string) []byte {
+	units := utf16.Encode([]rune("\ufeff" + strings.ReplaceAll(s, "\n", "\r\n")))
+	b := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
@@ -0,0 +1,78 @@
+package sessions
+
+import (
+	"botframework/chat"
+	"context"
+	"log/slog"
+)
+
+// History strategies: how much of a session is prepended to a request
+const (
+	// StrategyWindow sends the last MaxMessages messages
+	StrategyWindow = "window"
+	// StrategyTokens sends the newest messages that fit in MaxTokens
+	StrategyTokens = "tokens"
+	// StrategySummarize sends what fits in MaxTokens after a summary of the rest
+	StrategySummarize = "summarize"
+)
+
+// History decides which part of a stored conversation is sent with a request
+type History struct {
+	Strategy    string
+	MaxMessages int
+	MaxTokens   int
+	Counter     chat.TokenCounter
+	// Summarizer condenses the messages StrategySummarize leaves out; without one they
+	// are dropped as with StrategyTokens
+	Summarizer chat.Summarizer
+}
+
+func NewHistory() History {
+	return History{Strategy: StrategyTokens, MaxMessages: 50, MaxTokens: 2048, Counter: chat.EstimateCounter{}}
+}
+
+// Prompt returns the messages of session to prepend to a request for model. A new summary
+// is written to session.Summary, to be stored with the session's next update.
+func (h History) Prompt(ctx context.Context, model string, session *Session) []chat.Message {
+	messages := session.Messages
+	start := 0
+	switch h.Strategy {
+	case StrategyWindow:
+		start = max(0, len(messages)-h.MaxMessages)
+	default:
+		// counted from the newest message back; each count includes the reply priming once
+		priming := h.Counter.CountTokens(model, nil)
+		tokens := priming
+		for i := len(messages) - 1; i >= 0; i-- {
+			tokens += h.Counter.CountTokens(model, messages[i:i+1]) - priming
+			if tokens > h.MaxTokens {
+				start = i + 1
+				break
+			}
+		}
+	}
+	// a tool result is only valid after the assistant message that called the tool
+	for start < len(messages) && messages[start].Role == "tool" {
+		start++
+	}
+	kept := messages[start:]
+	if h.Strategy != StrategySummarize || h.Summarizer == nil || start == 0 {
+		return kept
+	}
+
+	if session.Summarized != start {
+		// fold the newly dropped messages into the previous summary, as chat.Memory does
+		input := messages[:start]
+		if session.Summary != "" && session.Summarized < start {
+			input = append([]chat.Message{{Role: "system", Content: "Earlier summary: " + session.Summary}}, messages[session.Summarized:start]...)
+		}
+		summary, err := h.Summarizer.Summarize(ctx, model, input)
+		if err != nil {
+			slog.WarnContext(ctx, "session summary failed, dropping older messages", "session", session.ID, "err", err)
+			return kept
+		}
+		session.Summary, session.Summarized = summary, start
+	}
+	summary := chat.Message{Role: "system", Content: "Summary of the conversation so far: " + session.Summary}
+	return append([]chat.Message{summary}, kept...)
+}
@@ -0,0 +1,228 @@
+package sessions
+
+import (
+	"botframework/chat"
+	"botframework/files"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxReply bounds how much of a response is kept to read the assistant's reply from
+const maxReply = 4 << 20
+
+// Sessions prepends stored conversations to chat completions and records each exchange
+type Sessions struct {
+	Store   Store
+	History History
+}
+
+// Middleware serves chat completions naming a session in chat.SessionHeader or a
+// "session_id" body field. The session's history is prepended to the request's messages,
+// after its system prompt, and a successful reply is stored with the new messages. A
+// session is created by its first request. Sessions belong to the caller's API key.
+func (s *Sessions) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := chat.Read(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sessionID := r.Header.Get(chat.SessionHeader)
+		var bodySession string
+		if req.Get("session_id", &bodySession) {
+			req.Delete("session_id")
+			if sessionID == "" {
+				sessionID = bodySession
+			}
+		}
+		if sessionID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ValidID(sessionID) {
+			http.Error(w, ErrInvalidID.Error(), http.StatusBadRequest)
+			return
+		}
+
+		owner := files.Owner(r)
+		session, err := s.Store.Get(owner, sessionID)
+		if errors.Is(err, ErrNotFound) {
+			session, err = &Session{ID: sessionID}, nil
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		system, turns := splitSystem(req.Messages)
+		model := req.Model()
+		history := s.History.Prompt(r.Context(), model, session)
+		messages := append(append(append([]chat.Message{}, system...), history...), turns...)
+		req.Messages = messages
+		if err := req.Write(r); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// chat.Memory and other middleware key on the header
+		r.Header.Set(chat.SessionHeader, sessionID)
+		w.Header().Set(chat.SessionHeader, sessionID)
+
+		recorder := &replyRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		reply, ok := recorder.reply()
+		if !ok {
+			return
+		}
+		_, err = s.Store.Update(owner, sessionID, true, func(stored *Session) error {
+			stored.Messages = append(stored.Messages, turns...)
+			stored.Messages = append(stored.Messages, reply)
+			if model != "" {
+				stored.Model = model
+			}
+			if session.Summarized > stored.Summarized {
+				stored.Summary, stored.Summarized = session.Summary, session.Summarized
+			}
+			return nil
+		})
+		if err != nil {
+			slog.WarnContext(r.Context(), "session not saved", "session", sessionID, "err", err)
+		}
+	})
+}
+
+func splitSystem(messages []chat.Message) ([]chat.Message, []chat.Message) {
+	i := 0
+	for i < len(messages) && messages[i].Role == "system" {
+		i++
+	}
+	return messages[:i], messages[i:]
+}
+
+// replyRecorder passes a response through while keeping a copy to read the reply from
+type replyRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *replyRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *replyRecorder) Write(b []byte) (int, error) {
+	if r.body.Len()+len(b) <= maxReply {
+		r.body.Write(b)
+	} else {
+		r.overflow = true
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *replyRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *replyRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// toolCall is a tool call assembled from streamed fragments
+type toolCall struct {
+	Index    int    `json:"-"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// reply reads the assistant message of the first choice from a successful completion,
+// streamed or not
+func (r *replyRecorder) reply() (chat.Message, bool) {
+	if r.status != http.StatusOK || r.overflow {
+		return chat.Message{}, false
+	}
+	if !strings.HasPrefix(r.Header().Get("Content-Type"), "text/event-stream") {
+		var completion struct {
+			Choices []struct {
+				Message chat.Message `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(r.body.Bytes(), &completion); err != nil || len(completion.Choices) == 0 {
+			return chat.Message{}, false
+		}
+		message := completion.Choices[0].Message
+		message.Role = "assistant"
+		return message, true
+	}
+
+	var content strings.Builder
+	calls := map[int]*toolCall{}
+	seen := false
+	scanner := bufio.NewScanner(&r.body)
+	scanner.Buffer(make([]byte, 64<<10), maxReply)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok || strings.TrimSpace(data) == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Choices []struct {
+				Index int `json:"index"`
+				Delta struct {
+					Content   string     `json:"content"`
+					ToolCalls []toolCall `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if json.Unmarshal([]byte(data), &chunk) != nil {
+			continue
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Index != 0 {
+				continue
+			}
+			seen = true
+			content.WriteString(choice.Delta.Content)
+			for _, fragment := range choice.Delta.ToolCalls {
+				call, ok := calls[fragment.Index]
+				if !ok {
+					call = &toolCall{Index: fragment.Index}
+					calls[fragment.Index] = call
+				}
+				if fragment.ID != "" {
+					call.ID = fragment.ID
+				}
+				if fragment.Type != "" {
+					call.Type = fragment.Type
+				}
+				call.Function.Name += fragment.Function.Name
+				call.Function.Arguments += fragment.Function.Arguments
+			}
+		}
+	}
+	if !seen {
+		return chat.Message{}, false
+	}
+	message := chat.Message{Role: "assistant", Content: content.String()}
+	if len(calls) > 0 {
+		ordered := make([]*toolCall, 0, len(calls))
+		for _, call := range calls {
+			ordered = append(ordered, call)
+		}
+		sort.Slice(ordered, func(i, j int) bool { return ordered[i].Index < ordered[j].Index })
+		message.ToolCalls, _ = json.Marshal(ordered)
+	}
+	return message, true
+}
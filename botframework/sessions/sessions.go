@@ -0,0 +1,227 @@
+// Package sessions stores chat conversations on the server, so clients can send only their
+// newest message and have the conversation so far prepended to it.
+package sessions
+
+import (
+	"botframework/chat"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("session not found")
+	ErrInvalidID = errors.New("session ids are 1-128 letters, digits, '-' or '_'")
+)
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// ValidID reports whether id can name a session
+func ValidID(id string) bool {
+	return validID.MatchString(id)
+}
+
+// Session is a stored conversation. Messages hold the turns after any system prompt, which
+// clients send with each request.
+type Session struct {
+	ID        string            `json:"id"`
+	Object    string            `json:"object"`
+	Title     string            `json:"title,omitempty"`
+	Model     string            `json:"model,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt int64             `json:"created_at"`
+	UpdatedAt int64             `json:"updated_at"`
+	// MessageCount is reported by List, which leaves Messages out
+	MessageCount int            `json:"message_count"`
+	Messages     []chat.Message `json:"messages,omitempty"`
+	// Summary condenses the first Summarized messages once the summarize strategy has
+	// dropped them from prompts
+	Summary    string `json:"summary,omitempty"`
+	Summarized int    `json:"summarized,omitempty"`
+	Owner      string `json:"-"`
+}
+
+// Store keeps sessions. Each session belongs to the owner that created it and is invisible
+// to other owners, who may use the same ID for sessions of their own.
+type Store interface {
+	// Get returns one of owner's sessions with its messages
+	Get(owner, id string) (*Session, error)
+	// List returns owner's sessions without their messages, most recently updated first
+	List(owner string) ([]Session, error)
+	// Update applies fn to one of owner's sessions and stores the result; with create set,
+	// a missing session is created first. fn runs under the store's lock.
+	Update(owner, id string, create bool, fn func(*Session) error) (*Session, error)
+	Delete(owner, id string) error
+}
+
+// stored is the on-disk session, which keeps the owner hidden from API responses
+type stored struct {
+	Session
+	Owner string `json:"owner"`
+}
+
+// FileStore keeps each session in a JSON file of its own. The standard library has no
+// SQLite driver; another database plugs in through Store.
+type FileStore struct {
+	Dir string
+
+	mu       sync.Mutex
+	sessions map[string]Session // by storeKey, without messages
+	now      func() time.Time
+}
+
+// NewFileStore opens (or creates) a session store in dir, indexing the sessions in it
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	s := &FileStore{Dir: dir, sessions: make(map[string]Session), now: time.Now}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		session, err := s.read(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		s.sessions[storeKey(session.Owner, session.ID)] = header(session)
+	}
+	return s, nil
+}
+
+// storeKey names owner's session id, in the index and on disk
+func storeKey(owner, id string) string {
+	sum := sha256.Sum256([]byte(owner + "\x00" + id))
+	return hex.EncodeToString(sum[:12])
+}
+
+func (s *FileStore) path(key string) string { return filepath.Join(s.Dir, key+".json") }
+
+func (s *FileStore) read(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var record stored
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	record.Session.Owner = record.Owner
+	record.Session.MessageCount = len(record.Messages)
+	return &record.Session, nil
+}
+
+// header is session without its messages
+func header(session *Session) Session {
+	h := *session
+	h.Messages = nil
+	return h
+}
+
+func (s *FileStore) Get(owner, id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(owner, id)
+}
+
+func (s *FileStore) get(owner, id string) (*Session, error) {
+	key := storeKey(owner, id)
+	if _, ok := s.sessions[key]; !ok {
+		return nil, ErrNotFound
+	}
+	return s.read(s.path(key))
+}
+
+func (s *FileStore) List(owner string) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []Session{}
+	for _, session := range s.sessions {
+		if session.Owner == owner {
+			out = append(out, session)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].UpdatedAt != out[j].UpdatedAt {
+			return out[i].UpdatedAt > out[j].UpdatedAt
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out, nil
+}
+
+func (s *FileStore) Update(owner, id string, create bool, fn func(*Session) error) (*Session, error) {
+	if !ValidID(id) {
+		return nil, ErrInvalidID
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, err := s.get(owner, id)
+	if errors.Is(err, ErrNotFound) && create {
+		now := s.now().Unix()
+		session, err = &Session{ID: id, Object: "chat.session", CreatedAt: now, Owner: owner}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(session); err != nil {
+		return nil, err
+	}
+	session.UpdatedAt = s.now().Unix()
+	session.MessageCount = len(session.Messages)
+
+	data, err := json.Marshal(stored{Session: *session, Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+	// write a temporary file first so a crash never leaves a session half-written
+	key := storeKey(owner, id)
+	tmp := s.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, s.path(key)); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	s.sessions[key] = header(session)
+	return session, nil
+}
+
+func (s *FileStore) Delete(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := storeKey(owner, id)
+	if _, ok := s.sessions[key]; !ok {
+		return ErrNotFound
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	delete(s.sessions, key)
+	return nil
+}
+
+// NewID returns a random session id
+func NewID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "sess_" + hex.EncodeToString(b), nil
+}
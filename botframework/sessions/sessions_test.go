@@ -0,0 +1,187 @@
+package sessions
+
+import (
+	"botframework/chat"
+	"botframework/files"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFileStoreOwnershipAndPersistence(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Update("alice", "trip", true, func(s *Session) error {
+		s.Title = "Trip planning"
+		s.Messages = append(s.Messages, chat.Message{Role: "user", Content: "Where to?"})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("bob", "trip"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other owners must not see the session, got %v", err)
+	}
+	if err := store.Delete("bob", "trip"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("other owners must not delete the session, got %v", err)
+	}
+	if _, err := store.Update("alice", "missing", false, func(*Session) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update without create should not create, got %v", err)
+	}
+
+	reopened, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := reopened.Get("alice", "trip")
+	if err != nil || session.Title != "Trip planning" || len(session.Messages) != 1 || session.Owner != "alice" {
+		t.Fatalf("want persisted session, got %+v %v", session, err)
+	}
+	list, _ := reopened.List("alice")
+	if len(list) != 1 || list[0].Messages != nil || list[0].MessageCount != 1 {
+		t.Errorf("List should return headers with counts, got %+v", list)
+	}
+	if err := reopened.Delete("alice", "trip"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := reopened.List("alice"); len(list) != 0 {
+		t.Error("session still listed after delete")
+	}
+}
+
+func conversation(n int) *Session {
+	session := &Session{ID: "s"}
+	for i := range n {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		session.Messages = append(session.Messages, chat.Message{Role: role, Content: fmt.Sprintf("message %d %s", i, strings.Repeat("word ", 20))})
+	}
+	return session
+}
+
+type recordingSummarizer struct{ inputs [][]chat.Message }
+
+func (s *recordingSummarizer) Summarize(_ context.Context, _ string, messages []chat.Message) (string, error) {
+	s.inputs = append(s.inputs, messages)
+	return fmt.Sprintf("summary of %d messages", len(messages)), nil
+}
+
+func TestHistoryStrategies(t *testing.T) {
+	window := History{Strategy: StrategyWindow, MaxMessages: 4}
+	if got := window.Prompt(context.Background(), "m", conversation(10)); len(got) != 4 || got[0].Text() != conversation(10).Messages[6].Text() {
+		t.Errorf("window kept %d messages", len(got))
+	}
+
+	tokens := NewHistory()
+	tokens.MaxTokens = 100
+	got := tokens.Prompt(context.Background(), "m", conversation(10))
+	if len(got) == 0 || len(got) >= 10 {
+		t.Fatalf("token budget kept %d of 10 messages", len(got))
+	}
+	if count := tokens.Counter.CountTokens("m", got); count > tokens.MaxTokens {
+		t.Errorf("kept %d tokens, budget %d", count, tokens.MaxTokens)
+	}
+
+	summarizer := &recordingSummarizer{}
+	summarize := History{Strategy: StrategySummarize, MaxMessages: 50, MaxTokens: 100, Counter: chat.EstimateCounter{}, Summarizer: summarizer}
+	session := conversation(10)
+	got = summarize.Prompt(context.Background(), "m", session)
+	if got[0].Role != "system" || !strings.Contains(got[0].Text(), "summary of") || session.Summarized == 0 {
+		t.Fatalf("want a summary first, got %+v (summarized %d)", got[0], session.Summarized)
+	}
+	summarize.Prompt(context.Background(), "m", session)
+	if len(summarizer.inputs) != 1 {
+		t.Errorf("an unchanged boundary should reuse the summary, summarized %d times", len(summarizer.inputs))
+	}
+}
+
+func TestHistorySkipsOrphanedToolResults(t *testing.T) {
+	session := &Session{Messages: []chat.Message{
+		{Role: "user", Content: "weather?"},
+		{Role: "assistant", ToolCalls: json.RawMessage(`[{"id":"1"}]`)},
+		{Role: "tool", ToolCallID: "1", Content: "sunny"},
+		{Role: "assistant", Content: "It is sunny."},
+	}}
+	got := History{Strategy: StrategyWindow, MaxMessages: 2}.Prompt(context.Background(), "m", session)
+	if len(got) != 1 || got[0].Text() != "It is sunny." {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestMiddlewarePrependsHistoryAndStoresReplies(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seen []chat.Message
+	stream := false
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := chat.Read(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen = req.Messages
+		if req.Get("session_id", new(string)) {
+			t.Error("session_id should be removed from the body")
+		}
+		if stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Lis\"}}]}\n\n")
+			io.WriteString(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"bon\"}}]}\n\n")
+			io.WriteString(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"index":0,"message":{"role":"assistant","content":"Portugal?"}}]}`)
+	})
+	handler := (&Sessions{Store: store, History: NewHistory()}).Middleware(backend)
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, chat.CompletionsPath, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer key")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send(`{"model":"m","session_id":"trip","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Somewhere warm?"}]}`)
+	if rec.Code != http.StatusOK || rec.Header().Get(chat.SessionHeader) != "trip" {
+		t.Fatalf("status %d, session header %q", rec.Code, rec.Header().Get(chat.SessionHeader))
+	}
+	stream = true
+	send(`{"model":"m","session_id":"trip","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"Which city?"}]}`)
+	want := []string{"Be brief.", "Somewhere warm?", "Portugal?", "Which city?"}
+	if len(seen) != len(want) {
+		t.Fatalf("backend saw %d messages, want %d", len(seen), len(want))
+	}
+	for i, text := range want {
+		if seen[i].Text() != text {
+			t.Errorf("message %d = %q, want %q", i, seen[i].Text(), text)
+		}
+	}
+
+	owner := httptest.NewRequest(http.MethodGet, "/", nil)
+	owner.Header.Set("Authorization", "Bearer key")
+	session, err := store.Get(files.Owner(owner), "trip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Messages) != 4 || session.Messages[3].Text() != "Lisbon" || session.Model != "m" {
+		t.Errorf("stored session %+v", session)
+	}
+
+	if rec := send(`{"model":"m","session_id":"../etc","messages":[]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid session id: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,338 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CommandRunner executes a scheduler CLI command and returns its combined output
+type CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// Scheduler submits worker commands to a cluster and locates them once running
+type Scheduler interface {
+	Name() string
+	Submit(ctx context.Context, command []string) (jobID string, err error)
+	// Locate returns the host the job runs on, or "" while it is still pending
+	Locate(ctx context.Context, jobID string) (host string, err error)
+	Cancel(ctx context.Context, jobID string) error
+}
+
+// ClusterWorker runs the Python worker as a scheduler job instead of a local process
+type ClusterWorker struct {
+	ScriptPath string
+	Port       string
+	ModelPath  string
+	Python     string
+	Scheduler  Scheduler
+	HTTPClient *http.Client
+	// AllocationTimeout bounds how long the job may stay queued before Start fails
+	AllocationTimeout time.Duration
+	Readiness         ReadinessProbe
+
+	mu    sync.RWMutex
+	jobID string
+	host  string
+	proxy *httputil.ReverseProxy
+}
+
+func NewClusterWorker(scriptPath, port string, scheduler Scheduler) *ClusterWorker {
+	return &ClusterWorker{
+		ScriptPath:        scriptPath,
+		Port:              port,
+		Python:            "python3",
+		Scheduler:         scheduler,
+		HTTPClient:        &http.Client{Timeout: 2 * time.Second},
+		AllocationTimeout: 10 * time.Minute,
+		Readiness:         DefaultReadinessProbe(),
+	}
+}
+
+func (c *ClusterWorker) Start(ctx context.Context) error {
+	c.mu.Lock()
+	if c.jobID != "" {
+		c.mu.Unlock()
+		return errors.New("worker already started")
+	}
+	c.mu.Unlock()
+
+	command := []string{c.Python, c.ScriptPath, "--host", "0.0.0.0", "--port", c.Port}
+	if c.ModelPath != "" {
+		command = append(command, "--model-path", c.ModelPath)
+	}
+
+	slog.Info("submitting worker job", "scheduler", c.Scheduler.Name(), "port", c.Port)
+	jobID, err := c.Scheduler.Submit(ctx, command)
+	if err != nil {
+		return fmt.Errorf("submit %s job: %w", c.Scheduler.Name(), err)
+	}
+	c.mu.Lock()
+	c.jobID = jobID
+	c.mu.Unlock()
+	slog.Info("waiting for worker job allocation", "scheduler", c.Scheduler.Name(), "job", jobID)
+
+	host, err := c.waitForAllocation(ctx, jobID)
+	if err != nil {
+		_ = c.Stop()
+		return err
+	}
+
+	target, err := url.Parse(fmt.Sprintf("http://%s:%s", host, c.Port))
+	if err != nil {
+		_ = c.Stop()
+		return err
+	}
+	c.mu.Lock()
+	c.host = host
+	c.proxy = NewStreamingProxy(target)
+	c.mu.Unlock()
+
+	err = c.Readiness.Wait(ctx, func(context.Context) error {
+		_, err := c.Health()
+		return err
+	})
+	if err != nil {
+		_ = c.Stop()
+		return fmt.Errorf("%s job %s on %s: %w", c.Scheduler.Name(), jobID, host, err)
+	}
+	slog.Info("worker job ready", "job", jobID, "host", host)
+	return nil
+}
+
+func (c *ClusterWorker) waitForAllocation(ctx context.Context, jobID string) (string, error) {
+	deadline := time.Now().Add(c.AllocationTimeout)
+	for time.Now().Before(deadline) {
+		host, err := c.Scheduler.Locate(ctx, jobID)
+		if err != nil {
+			return "", err
+		}
+		if host != "" {
+			return host, nil
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	return "", fmt.Errorf("%s job %s was not allocated within %s", c.Scheduler.Name(), jobID, c.AllocationTimeout)
+}
+
+func (c *ClusterWorker) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	c.mu.RLock()
+	proxy := c.proxy
+	c.mu.RUnlock()
+	if proxy == nil {
+		http.Error(w, "worker job is not running", http.StatusServiceUnavailable)
+		return
+	}
+	ServeStreaming(proxy, w, r)
+}
+
+func (c *ClusterWorker) Health() (*WorkerHealth, error) {
+	c.mu.RLock()
+	host := c.host
+	c.mu.RUnlock()
+	if host == "" {
+		return nil, errors.New("worker job is not running")
+	}
+
+	resp, err := c.HTTPClient.Get(fmt.Sprintf("http://%s:%s/health", host, c.Port))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker health returned status %d", resp.StatusCode)
+	}
+
+	var health WorkerHealth
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Status reports whether the job is queued, serving, or not submitted
+func (c *ClusterWorker) Status() WorkerStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch {
+	case c.jobID == "":
+		return WorkerStatus{State: StateStopped}
+	case c.proxy == nil:
+		return WorkerStatus{State: StateStarting}
+	default:
+		return WorkerStatus{State: StateRunning}
+	}
+}
+
+// Stop cancels the scheduler job
+func (c *ClusterWorker) Stop() error {
+	c.mu.Lock()
+	jobID := c.jobID
+	c.jobID, c.host, c.proxy = "", "", nil
+	c.mu.Unlock()
+	if jobID == "" {
+		return nil
+	}
+
+	slog.Info("cancelling worker job", "scheduler", c.Scheduler.Name(), "job", jobID)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return c.Scheduler.Cancel(ctx, jobID)
+}
+
+// SlurmScheduler submits workers with sbatch and finds their node with squeue
+type SlurmScheduler struct {
+	Partition string
+	GPUs      int
+	ExtraArgs []string
+	Run       CommandRunner
+}
+
+func (s *SlurmScheduler) Name() string { return "slurm" }
+
+func (s *SlurmScheduler) run() CommandRunner {
+	if s.Run == nil {
+		return runCommand
+	}
+	return s.Run
+}
+
+func (s *SlurmScheduler) Submit(ctx context.Context, command []string) (string, error) {
+	args := []string{"--parsable", "--job-name=botframework-worker"}
+	if s.Partition != "" {
+		args = append(args, "--partition="+s.Partition)
+	}
+	if s.GPUs > 0 {
+		args = append(args, fmt.Sprintf("--gres=gpu:%d", s.GPUs))
+	}
+	args = append(args, s.ExtraArgs...)
+	args = append(args, "--wrap", shellJoin(command))
+
+	out, err := s.run()(ctx, "sbatch", args...)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	// --parsable prints "jobid" or "jobid;cluster"
+	jobID, _, _ := strings.Cut(strings.TrimSpace(string(out)), ";")
+	if jobID == "" {
+		return "", errors.New("sbatch returned no job id")
+	}
+	return jobID, nil
+}
+
+func (s *SlurmScheduler) Locate(ctx context.Context, jobID string) (string, error) {
+	out, err := s.run()(ctx, "squeue", "-h", "-j", jobID, "-o", "%T %N")
+	if err != nil {
+		return "", fmt.Errorf("squeue: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("slurm job %s is no longer queued", jobID)
+	}
+	switch fields[0] {
+	case "RUNNING":
+		if len(fields) < 2 {
+			return "", nil
+		}
+		return fields[1], nil
+	case "PENDING", "CONFIGURING":
+		return "", nil
+	default:
+		return "", fmt.Errorf("slurm job %s is %s", jobID, fields[0])
+	}
+}
+
+func (s *SlurmScheduler) Cancel(ctx context.Context, jobID string) error {
+	if out, err := s.run()(ctx, "scancel", jobID); err != nil {
+		return fmt.Errorf("scancel: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// workerAddressPattern matches the address the worker announces at startup
+var workerAddressPattern = regexp.MustCompile(`Worker starting on ([^\s:]+):\d+`)
+
+// RayScheduler submits workers as Ray jobs and finds their node from the job logs
+type RayScheduler struct {
+	Address string // Ray dashboard address, e.g. http://head:8265
+	GPUs    int
+	Run     CommandRunner
+}
+
+func (s *RayScheduler) Name() string { return "ray" }
+
+func (s *RayScheduler) run() CommandRunner {
+	if s.Run == nil {
+		return runCommand
+	}
+	return s.Run
+}
+
+func (s *RayScheduler) addressArgs() []string {
+	if s.Address == "" {
+		return nil
+	}
+	return []string{"--address", s.Address}
+}
+
+func (s *RayScheduler) Submit(ctx context.Context, command []string) (string, error) {
+	jobID := fmt.Sprintf("botframework-worker-%d", time.Now().UnixNano())
+	args := append([]string{"job", "submit", "--no-wait", "--submission-id", jobID}, s.addressArgs()...)
+	if s.GPUs > 0 {
+		args = append(args, fmt.Sprintf("--entrypoint-num-gpus=%d", s.GPUs))
+	}
+	args = append(args, "--")
+	args = append(args, command...)
+
+	if out, err := s.run()(ctx, "ray", args...); err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return jobID, nil
+}
+
+func (s *RayScheduler) Locate(ctx context.Context, jobID string) (string, error) {
+	args := append([]string{"job", "logs", jobID}, s.addressArgs()...)
+	out, err := s.run()(ctx, "ray", args...)
+	if err != nil {
+		// Logs are unavailable until the job is scheduled
+		return "", nil
+	}
+	if m := workerAddressPattern.FindSubmatch(out); m != nil {
+		return string(m[1]), nil
+	}
+	return "", nil
+}
+
+func (s *RayScheduler) Cancel(ctx context.Context, jobID string) error {
+	args := append([]string{"job", "stop", jobID}, s.addressArgs()...)
+	if out, err := s.run()(ctx, "ray", args...); err != nil {
+		return fmt.Errorf("ray job stop: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// shellJoin quotes arguments for sbatch --wrap
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,98 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeScheduler struct {
+	mu        sync.Mutex
+	calls     []string
+	responses map[string][]string
+}
+
+func (f *fakeScheduler) run(_ context.Context, name string, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, name+" "+strings.Join(args, " "))
+	queue := f.responses[name]
+	if len(queue) == 0 {
+		return nil, nil
+	}
+	out := queue[0]
+	if len(queue) > 1 {
+		f.responses[name] = queue[1:]
+	}
+	return []byte(out), nil
+}
+
+func TestSlurmWorkerLifecycle(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, `{"status":"ok","model_loaded":true,"model":"qwen.gguf"}`)
+	}))
+	defer ts.Close()
+
+	fake := &fakeScheduler{responses: map[string][]string{
+		"sbatch": {"4242;cluster\n"},
+		"squeue": {"PENDING (null)\n", "RUNNING 127.0.0.1\n"},
+	}}
+	scheduler := &SlurmScheduler{Partition: "gpu", GPUs: 1, Run: fake.run}
+	worker := NewClusterWorker("worker/main.py", extractPort(t, ts.URL), scheduler)
+	worker.ModelPath = "/models/qwen.gguf"
+	worker.HTTPClient = ts.Client()
+
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	health, err := worker.Health()
+	if err != nil || health.Model != "qwen.gguf" {
+		t.Fatalf("unexpected health %+v (%v)", health, err)
+	}
+	if err := worker.Stop(); err != nil {
+		t.Fatalf("stop: %v", err)
+	}
+
+	calls := strings.Join(fake.calls, "\n")
+	for _, want := range []string{"--partition=gpu", "--gres=gpu:1", "'--model-path' '/models/qwen.gguf'", "scancel 4242"} {
+		if !strings.Contains(calls, want) {
+			t.Fatalf("expected %q in scheduler calls:\n%s", want, calls)
+		}
+	}
+}
+
+func TestSlurmLocateFailedJob(t *testing.T) {
+	fake := &fakeScheduler{responses: map[string][]string{"squeue": {"FAILED node1\n"}}}
+	scheduler := &SlurmScheduler{Run: fake.run}
+	if _, err := scheduler.Locate(context.Background(), "1"); err == nil {
+		t.Fatal("expected error for failed job")
+	}
+}
+
+func TestRayLocateParsesWorkerAnnouncement(t *testing.T) {
+	fake := &fakeScheduler{responses: map[string][]string{"ray": {"loading...\nWorker starting on gpu-node-3:8082...\n"}}}
+	scheduler := &RayScheduler{Run: fake.run}
+
+	host, err := scheduler.Locate(context.Background(), "job")
+	if err != nil || host != "gpu-node-3" {
+		t.Fatalf("expected gpu-node-3, got %q (%v)", host, err)
+	}
+}
+
+func TestClusterWorkerAllocationTimeout(t *testing.T) {
+	fake := &fakeScheduler{responses: map[string][]string{"sbatch": {"7"}, "squeue": {"PENDING (null)"}}}
+	worker := NewClusterWorker("worker/main.py", "9", &SlurmScheduler{Run: fake.run})
+	worker.AllocationTimeout = 10 * time.Millisecond
+
+	if err := worker.Start(context.Background()); err == nil {
+		t.Fatal("expected allocation timeout")
+	}
+	if !strings.Contains(strings.Join(fake.calls, "\n"), "scancel 7") {
+		t.Fatalf("expected job to be cancelled, got %v", fake.calls)
+	}
+}
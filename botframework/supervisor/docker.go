@@ -0,0 +1,608 @@
+package supervisor
+
+import (
+	"botframework/logging"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dockerAPIVersion is the Engine API version requested; 1.41 (Docker 20.10) has the
+// device requests behind --gpus
+const dockerAPIVersion = "v1.41"
+
+// DefaultDockerImage is the engine image DockerWorker runs unless told otherwise
+const DefaultDockerImage = "vllm/vllm-openai:latest"
+
+// containerModelDir is where the model directory is mounted inside the container
+const containerModelDir = "/models"
+
+// errNoSuchImage is returned when creating a container from an image that is not pulled
+var errNoSuchImage = errors.New("no such image")
+
+// DockerClient talks to the Docker Engine API, over its Unix socket or TCP
+type DockerClient struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+// NewDockerClient connects to host as DOCKER_HOST spells it: unix:///var/run/docker.sock
+// (the default when host is empty) or tcp://host:2375
+func NewDockerClient(host string) (*DockerClient, error) {
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("docker host %q: %w", host, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		return &DockerClient{BaseURL: "http://docker", HTTP: &http.Client{Transport: transport}}, nil
+	case "tcp", "http":
+		return &DockerClient{BaseURL: "http://" + u.Host, HTTP: &http.Client{}}, nil
+	}
+	return nil, fmt.Errorf("docker host %q: unsupported scheme %q", host, u.Scheme)
+}
+
+// do sends a request to the Engine API and decodes its JSON answer into out, when not nil
+func (c *DockerClient) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := c.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// request sends a request to the Engine API, turning error statuses into errors
+func (c *DockerClient) request(ctx context.Context, method, path string, query url.Values, body any) (*http.Response, error) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(data)
+	}
+	target := c.BaseURL + "/" + dockerAPIVersion + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, payload)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker: %w", err)
+	}
+	if resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+	if resp.StatusCode == http.StatusNotFound && strings.Contains(strings.ToLower(apiErr.Message), "no such image") {
+		return nil, fmt.Errorf("docker %s %s: %w", method, path, errNoSuchImage)
+	}
+	return nil, fmt.Errorf("docker %s %s: status %d: %s", method, path, resp.StatusCode, apiErr.Message)
+}
+
+// pull fetches image, waiting for the pull to finish
+func (c *DockerClient) pull(ctx context.Context, image string) error {
+	resp, err := c.request(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": {image}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// progress messages stream until the pull is done; a failure arrives as one of them
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != "" {
+			return fmt.Errorf("pull %s: %s", image, message.Error)
+		}
+	}
+}
+
+// containerState is the part of a container inspection DockerWorker reads
+type containerState struct {
+	RestartCount int `json:"RestartCount"`
+	State        struct {
+		Status     string `json:"Status"` // created, running, restarting, exited, dead...
+		Running    bool   `json:"Running"`
+		Restarting bool   `json:"Restarting"`
+		ExitCode   int    `json:"ExitCode"`
+		Error      string `json:"Error"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+		Health     *struct {
+			Status string `json:"Status"` // starting, healthy, unhealthy
+		} `json:"Health"`
+	} `json:"State"`
+}
+
+// DockerWorker runs an inference engine as a container, such as vLLM's OpenAI server,
+// so hosts need Docker but no Python environment. The model's directory is mounted
+// read-only into the container, GPUs are passed through as --gpus does, and the engine's
+// port is published on the loopback interface. Docker restarts the container per the
+// restart policy; its output is streamed into the worker's logs.
+type DockerWorker struct {
+	// Name tags the worker's log lines and names the container; empty uses "docker:<port>"
+	Name  string
+	Image string
+	// Port is the host port the engine is published on
+	Port string
+	// ContainerPort is the port the engine listens on inside the container
+	ContainerPort string
+	// ModelPath is a model file or directory on this host, or a name the engine resolves
+	// itself such as a Hugging Face repository
+	ModelPath string
+	// ModelDir is mounted in place of the model's own directory, so a model cache can be
+	// shared by several containers
+	ModelDir string
+	// Args are passed to the engine after the host, port and model flags vLLM's server takes
+	Args []string
+	// GPUs passes GPUs through: "all", a count, or comma-separated device IDs; empty passes none
+	GPUs       string
+	Env        []string // extra KEY=VALUE entries for the container
+	Client     *DockerClient
+	HTTPClient *http.Client
+	Readiness  ReadinessProbe
+	Restart    RestartConfig
+	// StopGrace is how long Docker waits after SIGTERM before killing the engine
+	StopGrace time.Duration
+	// Warmup is sent once the engine is ready, before the worker is marked running;
+	// containers Docker restarts on its own are not warmed up again
+	Warmup Warmup
+
+	logs *logging.Tail
+	scan *LogScanner
+
+	mu       sync.RWMutex
+	parent   context.Context // what Start was called with, reused by Relaunch
+	id       string
+	proxy    *httputil.ReverseProxy
+	stopLogs context.CancelFunc
+	status   WorkerStatus
+}
+
+func NewDockerWorker(client *DockerClient, image, port, modelPath string) *DockerWorker {
+	if image == "" {
+		image = DefaultDockerImage
+	}
+	return &DockerWorker{
+		Image:         image,
+		Port:          port,
+		ContainerPort: "8000",
+		ModelPath:     modelPath,
+		Client:        client,
+		HTTPClient:    &http.Client{Timeout: 2 * time.Second},
+		Readiness:     DefaultReadinessProbe(),
+		Restart:       DefaultRestartConfig(),
+		StopGrace:     defaultStopGrace(),
+		Warmup:        DefaultWarmup(),
+		logs:          logging.NewTail(LogLines),
+		scan:          NewLogScanner(),
+		status:        WorkerStatus{State: StateStopped},
+	}
+}
+
+// name is the worker's log tag
+func (d *DockerWorker) name() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return "docker:" + d.Port
+}
+
+// containerName is the worker's name made safe for Docker
+func (d *DockerWorker) containerName() string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, d.name())
+	return "botframework-" + name
+}
+
+// mount returns the host directory mounted at containerModelDir and the model's path
+// inside the container; a model that is not on this host is passed through
+func (d *DockerWorker) mount() (hostDir, containerPath string) {
+	if d.ModelPath == "" {
+		return "", ""
+	}
+	if _, err := os.Stat(d.ModelPath); err != nil {
+		return "", d.ModelPath
+	}
+	hostDir = filepath.Dir(d.ModelPath)
+	if d.ModelDir != "" {
+		if rel, err := filepath.Rel(d.ModelDir, d.ModelPath); err == nil && !strings.HasPrefix(rel, "..") {
+			return d.ModelDir, containerModelDir + "/" + filepath.ToSlash(rel)
+		}
+	}
+	return hostDir, containerModelDir + "/" + filepath.Base(d.ModelPath)
+}
+
+// command is the engine's command line inside the container
+func (d *DockerWorker) command(modelPath string) []string {
+	args := []string{"--host", "0.0.0.0", "--port", d.ContainerPort}
+	if modelPath != "" {
+		args = append(args, "--model", modelPath, "--served-model-name", filepath.Base(d.ModelPath))
+	}
+	return append(args, d.Args...)
+}
+
+// deviceRequests renders GPUs as the Engine API's device requests
+func (d *DockerWorker) deviceRequests() []map[string]any {
+	if d.GPUs == "" {
+		return nil
+	}
+	request := map[string]any{"Capabilities": [][]string{{"gpu"}}}
+	if d.GPUs == "all" {
+		request["Count"] = -1
+	} else if count, err := strconv.Atoi(d.GPUs); err == nil {
+		request["Count"] = count
+	} else {
+		request["DeviceIDs"] = strings.Split(d.GPUs, ",")
+	}
+	return []map[string]any{request}
+}
+
+// restartPolicy maps the worker's restart policy onto Docker's
+func (d *DockerWorker) restartPolicy() map[string]any {
+	switch d.Restart.Policy {
+	case RestartNever:
+		return map[string]any{"Name": "no"}
+	case RestartAlways:
+		return map[string]any{"Name": "unless-stopped"}
+	}
+	return map[string]any{"Name": "on-failure", "MaximumRetryCount": d.Restart.MaxRestarts}
+}
+
+// containerConfig is the create request for the worker's container
+func (d *DockerWorker) containerConfig() map[string]any {
+	hostDir, modelPath := d.mount()
+	port := d.ContainerPort + "/tcp"
+	hostConfig := map[string]any{
+		"PortBindings":  map[string]any{port: []map[string]string{{"HostIp": "127.0.0.1", "HostPort": d.Port}}},
+		"RestartPolicy": d.restartPolicy(),
+		// vLLM shares tensors between its processes through /dev/shm
+		"IpcMode": "host",
+	}
+	if hostDir != "" {
+		hostConfig["Binds"] = []string{hostDir + ":" + containerModelDir + ":ro"}
+	}
+	if devices := d.deviceRequests(); devices != nil {
+		hostConfig["DeviceRequests"] = devices
+	}
+	return map[string]any{
+		"Image":        d.Image,
+		"Cmd":          d.command(modelPath),
+		"Env":          d.Env,
+		"ExposedPorts": map[string]any{port: struct{}{}},
+		"StopTimeout":  int(d.StopGrace.Seconds()),
+		"Labels":       map[string]string{"botframework.worker": d.name()},
+		"HostConfig":   hostConfig,
+	}
+}
+
+func (d *DockerWorker) Start(ctx context.Context) error {
+	d.mu.Lock()
+	if d.id != "" {
+		d.mu.Unlock()
+		return errors.New("worker already started")
+	}
+	d.parent = ctx
+	d.status = WorkerStatus{State: StateStarting, Restarts: d.status.Restarts}
+	d.mu.Unlock()
+
+	if err := d.startContainer(ctx); err != nil {
+		_ = d.Stop()
+		d.setState(StateFailed)
+		return err
+	}
+	return nil
+}
+
+func (d *DockerWorker) startContainer(ctx context.Context) error {
+	name := d.containerName()
+	// a container left behind by a manager that did not shut down cleanly holds the name
+	_ = d.Client.do(ctx, http.MethodDelete, "/containers/"+name, url.Values{"force": {"1"}}, nil, nil)
+
+	slog.Info("creating worker container", "worker", d.name(), "image", d.Image, "port", d.Port, "gpus", d.GPUs)
+	config := d.containerConfig()
+	var created struct {
+		ID string `json:"Id"`
+	}
+	query := url.Values{"name": {name}}
+	err := d.Client.do(ctx, http.MethodPost, "/containers/create", query, config, &created)
+	if errors.Is(err, errNoSuchImage) {
+		slog.Info("pulling worker image", "worker", d.name(), "image", d.Image)
+		if err := d.Client.pull(ctx, d.Image); err != nil {
+			return err
+		}
+		err = d.Client.do(ctx, http.MethodPost, "/containers/create", query, config, &created)
+	}
+	if err != nil {
+		return err
+	}
+
+	logCtx, stopLogs := context.WithCancel(context.WithoutCancel(ctx))
+	target, err := url.Parse("http://127.0.0.1:" + d.Port)
+	if err != nil {
+		stopLogs()
+		return err
+	}
+	d.mu.Lock()
+	d.id = created.ID
+	d.proxy = NewStreamingProxy(target)
+	d.stopLogs = stopLogs
+	d.mu.Unlock()
+
+	if err := d.Client.do(ctx, http.MethodPost, "/containers/"+created.ID+"/start", nil, nil, nil); err != nil {
+		return err
+	}
+	d.scan.Restart()
+	go d.streamLogs(logCtx, created.ID)
+
+	slog.Info("waiting for worker to initialize", "worker", d.name(), "container", shortID(created.ID))
+	if err := d.Readiness.WaitOrWake(ctx, scannedCheck(d.scan, func(context.Context) error {
+		_, err := d.Health()
+		return err
+	}), d.scan.Ready()); err != nil {
+		return err
+	}
+	slog.Info("worker ready", "worker", d.name(), "container", shortID(created.ID))
+	var warmup WarmupStatus
+	if len(d.Warmup.Prompts) > 0 {
+		warmup = warmUp(ctx, d.name(), d.Warmup, d.warmupRequest)
+	}
+	d.mu.Lock()
+	d.status.State = StateRunning
+	d.status.StartedAt = time.Now()
+	d.status.Warmup = warmup
+	d.mu.Unlock()
+	return nil
+}
+
+// warmupRequest sends one warmup prompt to the engine, under the model name it serves
+func (d *DockerWorker) warmupRequest(ctx context.Context, prompt string) error {
+	body := warmupChat(prompt, d.Warmup)
+	if d.ModelPath != "" {
+		body["model"] = filepath.Base(d.ModelPath)
+	}
+	return postWarmup(ctx, d.HTTPClient, d.Port, "/v1/chat/completions", body, d.Warmup)
+}
+
+// streamLogs follows the container's output into the log and Logs until ctx ends. Without
+// a TTY, Docker multiplexes stdout and stderr into frames behind an 8-byte header.
+func (d *DockerWorker) streamLogs(ctx context.Context, id string) {
+	query := url.Values{"follow": {"1"}, "stdout": {"1"}, "stderr": {"1"}}
+	resp, err := d.Client.request(ctx, http.MethodGet, "/containers/"+id+"/logs", query, nil)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Warn("worker logs unavailable", "worker", d.name(), "err", err)
+		}
+		return
+	}
+	defer resp.Body.Close()
+	stdout, stderr := d.output("stdout"), d.output("stderr")
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(resp.Body, header); err != nil {
+			return
+		}
+		out := stdout
+		if header[0] == 2 {
+			out = stderr
+		}
+		if _, err := io.CopyN(out, resp.Body, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return
+		}
+	}
+}
+
+// output sends a stream of the container's output to the log, to Logs and to the scanner
+func (d *DockerWorker) output(stream string) io.Writer {
+	writers := []io.Writer{logging.Writer(d.name(), stream), d.logs}
+	if d.scan != nil {
+		writers = append(writers, d.scan)
+	}
+	return io.MultiWriter(writers...)
+}
+
+// inspect reads the container's state from Docker
+func (d *DockerWorker) inspect(ctx context.Context) (*containerState, error) {
+	d.mu.RLock()
+	id := d.id
+	d.mu.RUnlock()
+	if id == "" {
+		return nil, errors.New("worker container is not running")
+	}
+	var state containerState
+	if err := d.Client.do(ctx, http.MethodGet, "/containers/"+id+"/json", nil, nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// Health checks the container with Docker, including the image's own health check when
+// it has one, then asks the engine. Engines such as vLLM answer /health with an empty
+// body once the model is loaded.
+func (d *DockerWorker) Health() (*WorkerHealth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.HTTPClient.Timeout)
+	defer cancel()
+	state, err := d.inspect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !state.State.Running || state.State.Restarting {
+		return nil, fmt.Errorf("worker container is %s", state.State.Status)
+	}
+	if health := state.State.Health; health != nil && health.Status == "unhealthy" {
+		return nil, errors.New("worker container is unhealthy")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:"+d.Port+"/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("worker health returned status %d", resp.StatusCode)
+	}
+	health := WorkerHealth{Status: "ok", ModelLoaded: true, Model: filepath.Base(d.ModelPath)}
+	_ = json.NewDecoder(resp.Body).Decode(&health)
+	return &health, nil
+}
+
+// Status reports the worker's lifecycle state; once running, Docker's view of the
+// container decides it, restarts included
+func (d *DockerWorker) Status() WorkerStatus {
+	d.mu.RLock()
+	status := d.status
+	d.mu.RUnlock()
+	status.Port = d.Port
+	status.LogStats = d.scan.Stats()
+	if status.State != StateRunning {
+		return status
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.HTTPClient.Timeout)
+	defer cancel()
+	state, err := d.inspect(ctx)
+	if err != nil {
+		return status
+	}
+	status.Restarts += state.RestartCount
+	switch {
+	case state.State.Restarting:
+		status.State = StateRestarting
+	case !state.State.Running:
+		status.State = StateFailed
+		status.LastExit = fmt.Sprintf("exit status %d", state.State.ExitCode)
+		if state.State.Error != "" {
+			status.LastExit = state.State.Error
+		}
+		status.LastExitAt, _ = time.Parse(time.RFC3339Nano, state.State.FinishedAt)
+	}
+	return status
+}
+
+func (d *DockerWorker) setState(state WorkerState) {
+	d.mu.Lock()
+	d.status.State = state
+	d.mu.Unlock()
+}
+
+func (d *DockerWorker) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	d.mu.RLock()
+	proxy := d.proxy
+	d.mu.RUnlock()
+	if proxy == nil {
+		http.Error(w, "worker container is not running", http.StatusServiceUnavailable)
+		return
+	}
+	ServeStreaming(proxy, w, r)
+}
+
+// Stop stops the container, giving the engine StopGrace to exit, and removes it
+func (d *DockerWorker) Stop() error {
+	d.mu.Lock()
+	id, stopLogs := d.id, d.stopLogs
+	d.id, d.proxy, d.stopLogs = "", nil, nil
+	d.status.State = StateStopped
+	d.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+
+	slog.Info("stopping worker", "worker", d.name(), "container", shortID(id))
+	ctx, cancel := context.WithTimeout(context.Background(), d.StopGrace+30*time.Second)
+	defer cancel()
+	query := url.Values{"t": {strconv.Itoa(int(d.StopGrace.Seconds()))}}
+	err := d.Client.do(ctx, http.MethodPost, "/containers/"+id+"/stop", query, nil, nil)
+	if stopLogs != nil {
+		stopLogs()
+	}
+	if removeErr := d.Client.do(ctx, http.MethodDelete, "/containers/"+id, url.Values{"force": {"1"}}, nil, nil); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// Relaunch replaces the container with a new one under the context the worker was first
+// started with
+func (d *DockerWorker) Relaunch() error {
+	d.mu.RLock()
+	parent := d.parent
+	restarts := d.status.Restarts
+	d.mu.RUnlock()
+	if parent == nil {
+		return errors.New("worker was never started")
+	}
+	if err := d.Stop(); err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.status.Restarts = restarts + 1
+	d.mu.Unlock()
+	return d.Start(parent)
+}
+
+// Logs returns up to the last n lines the container printed, oldest first (all kept when n <= 0)
+func (d *DockerWorker) Logs(n int) []string {
+	return d.logs.Lines(n)
+}
+
+// shortID abbreviates a container ID the way the docker CLI does
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,176 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDocker serves the parts of the Engine API DockerWorker uses
+type fakeDocker struct {
+	mu      sync.Mutex
+	calls   []string
+	create  map[string]any
+	pulled  bool
+	running bool
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/"+dockerAPIVersion)
+	f.calls = append(f.calls, r.Method+" "+path)
+	switch {
+	case path == "/containers/create":
+		if !f.pulled {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"No such image: vllm/vllm-openai:latest"}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&f.create)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"0123456789abcdef"}`))
+	case path == "/images/create":
+		f.pulled = true
+		w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"status":"Done"}` + "\n"))
+	case path == "/containers/0123456789abcdef/start":
+		f.running = true
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/containers/0123456789abcdef/json":
+		w.Write([]byte(`{"RestartCount":1,"State":{"Status":"running","Running":true,"Health":{"Status":"healthy"}}}`))
+	case path == "/containers/0123456789abcdef/logs":
+		for stream, line := range []string{"", "INFO loading model\n", "WARNING slow disk\n"} {
+			if line == "" {
+				continue
+			}
+			header := make([]byte, 8)
+			header[0] = byte(stream)
+			binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+			w.Write(append(header, line...))
+		}
+	case path == "/containers/0123456789abcdef/stop":
+		f.running = false
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"unexpected call"}`))
+	}
+}
+
+func TestDockerWorkerRunsContainer(t *testing.T) {
+	docker := &fakeDocker{}
+	api := httptest.NewServer(docker)
+	defer api.Close()
+	warmed := make(chan string, 1)
+	engine := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/chat/completions" {
+			var body struct {
+				Model string `json:"model"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			warmed <- body.Model
+		}
+	}))
+	defer engine.Close()
+
+	cache := t.TempDir()
+	model := filepath.Join(cache, "llama", "Q4_K_M", "llama.gguf")
+	if err := os.MkdirAll(filepath.Dir(model), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(model, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewDockerClient("tcp://" + strings.TrimPrefix(api.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker := NewDockerWorker(client, "", extractPort(t, engine.URL), model)
+	worker.ModelDir = cache
+	worker.GPUs = "all"
+	worker.Readiness = ReadinessProbe{InitialInterval: 10 * time.Millisecond, MaxInterval: 10 * time.Millisecond, Multiplier: 1, Deadline: time.Second}
+	worker.Warmup = Warmup{Prompts: []string{"Hello"}, MaxTokens: 1, Timeout: time.Second}
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	select {
+	case model := <-warmed:
+		if model != "llama.gguf" {
+			t.Errorf("warmup asked for model %q, want the served name llama.gguf", model)
+		}
+	default:
+		t.Error("the container was not warmed up before it was marked running")
+	}
+	if warmup := worker.Status().Warmup; warmup.Prompts != 1 || warmup.Error != "" {
+		t.Errorf("Status().Warmup = %+v", warmup)
+	}
+
+	docker.mu.Lock()
+	config := docker.create
+	pulled := docker.pulled
+	docker.mu.Unlock()
+	if !pulled {
+		t.Error("the missing image should have been pulled")
+	}
+	cmd := []string{}
+	for _, arg := range config["Cmd"].([]any) {
+		cmd = append(cmd, arg.(string))
+	}
+	if !slices.Contains(cmd, "/models/llama/Q4_K_M/llama.gguf") {
+		t.Errorf("Cmd = %v, want the model at its path in the mounted cache", cmd)
+	}
+	hostConfig := config["HostConfig"].(map[string]any)
+	if binds := hostConfig["Binds"].([]any); binds[0] != cache+":/models:ro" {
+		t.Errorf("Binds = %v", binds)
+	}
+	if devices := hostConfig["DeviceRequests"].([]any); devices[0].(map[string]any)["Count"] != float64(-1) {
+		t.Errorf("DeviceRequests = %v, want all GPUs", devices)
+	}
+
+	if status := worker.Status(); status.State != StateRunning || status.Restarts != 1 {
+		t.Errorf("Status() = %+v, want running with Docker's restart count", status)
+	}
+	health, err := worker.Health()
+	if err != nil || !health.ModelLoaded || health.Model != "llama.gguf" {
+		t.Errorf("Health() = %+v, %v", health, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(worker.Logs(0)) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if logs := worker.Logs(0); len(logs) != 2 || logs[1] != "WARNING slow disk" {
+		t.Errorf("Logs() = %q", logs)
+	}
+
+	if err := worker.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	docker.mu.Lock()
+	defer docker.mu.Unlock()
+	if docker.running || !slices.Contains(docker.calls, "DELETE /containers/0123456789abcdef") {
+		t.Errorf("container not stopped and removed: %v", docker.calls)
+	}
+}
+
+func TestNewDockerClientRejectsUnknownSchemes(t *testing.T) {
+	if _, err := NewDockerClient("ssh://host"); err == nil {
+		t.Error("ssh:// hosts are not supported")
+	}
+	client, err := NewDockerClient("")
+	if err != nil || client.BaseURL != "http://docker" {
+		t.Errorf("default client = %+v, %v", client, err)
+	}
+}
@@ -0,0 +1,426 @@
+package supervisor
+
+import (
+	"botframework/logging"
+	"botframework/tracing"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// grpcService is the full name of the service in proto/inference.proto
+const grpcService = "botframework.inference.v1.Inference"
+
+// maxGrpcMessage bounds a single response message (gRPC's default receive limit)
+const maxGrpcMessage = 4 << 20
+
+// GenerateMessage is one chat turn
+type GenerateMessage struct {
+	Role    string
+	Content string
+}
+
+// GenerateRequest mirrors inference.v1.GenerateRequest; nil pointers leave optional fields unset
+type GenerateRequest struct {
+	Model         string
+	Messages      []GenerateMessage
+	Prompt        string
+	Temperature   *float32
+	TopP          *float32
+	TopK          *int32
+	MaxTokens     *int32
+	Stop          []string
+	RepeatPenalty *float32
+	Seed          *int64
+}
+
+func (req *GenerateRequest) marshal() []byte {
+	var b []byte
+	b = appendString(b, 1, req.Model)
+	for _, m := range req.Messages {
+		var msg []byte
+		msg = appendString(msg, 1, m.Role)
+		msg = appendString(msg, 2, m.Content)
+		b = appendBytesField(b, 2, msg)
+	}
+	b = appendString(b, 3, req.Prompt)
+	if req.Temperature != nil {
+		b = appendFloat(b, 4, *req.Temperature)
+	}
+	if req.TopP != nil {
+		b = appendFloat(b, 5, *req.TopP)
+	}
+	if req.TopK != nil {
+		b = appendInt(b, 6, int64(*req.TopK), true)
+	}
+	if req.MaxTokens != nil {
+		b = appendInt(b, 7, int64(*req.MaxTokens), true)
+	}
+	for _, stop := range req.Stop {
+		b = appendBytesField(b, 8, []byte(stop))
+	}
+	if req.RepeatPenalty != nil {
+		b = appendFloat(b, 9, *req.RepeatPenalty)
+	}
+	if req.Seed != nil {
+		b = appendInt(b, 10, *req.Seed, true)
+	}
+	return b
+}
+
+// GenerateUsage counts the tokens of one call
+type GenerateUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+func parseUsage(data []byte) (GenerateUsage, error) {
+	var usage GenerateUsage
+	fields, err := parseProto(data)
+	for _, f := range fields {
+		switch f.field {
+		case 1:
+			usage.PromptTokens = int(int32(f.num))
+		case 2:
+			usage.CompletionTokens = int(int32(f.num))
+		}
+	}
+	return usage, err
+}
+
+// GenerateResponse is a complete generation
+type GenerateResponse struct {
+	ID           string
+	Model        string
+	Text         string
+	FinishReason string
+	Usage        GenerateUsage
+}
+
+func (resp *GenerateResponse) unmarshal(data []byte) error {
+	fields, err := parseProto(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.field {
+		case 1:
+			resp.ID = f.str()
+		case 2:
+			resp.Model = f.str()
+		case 3:
+			resp.Text = f.str()
+		case 4:
+			resp.FinishReason = f.str()
+		case 5:
+			if resp.Usage, err = parseUsage(f.data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GenerateChunk is one piece of a streamed generation; the last sets FinishReason and Usage
+type GenerateChunk struct {
+	Text         string
+	FinishReason string
+	Usage        *GenerateUsage
+}
+
+func (chunk *GenerateChunk) unmarshal(data []byte) error {
+	fields, err := parseProto(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.field {
+		case 1:
+			chunk.Text = f.str()
+		case 2:
+			chunk.FinishReason = f.str()
+		case 3:
+			usage, err := parseUsage(f.data)
+			if err != nil {
+				return err
+			}
+			chunk.Usage = &usage
+		}
+	}
+	return nil
+}
+
+// EmbedResponse holds one vector per input
+type EmbedResponse struct {
+	Model string
+	Data  [][]float32
+	Usage GenerateUsage
+}
+
+func (resp *EmbedResponse) unmarshal(data []byte) error {
+	fields, err := parseProto(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.field {
+		case 1:
+			resp.Model = f.str()
+		case 2:
+			inner, err := parseProto(f.data)
+			if err != nil {
+				return err
+			}
+			var vector []float32
+			for _, v := range inner {
+				if v.field != 1 {
+					continue
+				}
+				values, err := v.floats()
+				if err != nil {
+					return err
+				}
+				vector = append(vector, values...)
+			}
+			resp.Data = append(resp.Data, vector)
+		case 3:
+			if resp.Usage, err = parseUsage(f.data); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// GrpcError is a non-OK gRPC status returned by a worker
+type GrpcError struct {
+	Code    int
+	Message string
+}
+
+func (e *GrpcError) Error() string {
+	return fmt.Sprintf("worker returned gRPC status %d: %s", e.Code, e.Message)
+}
+
+// HTTPStatus maps the gRPC status code to the HTTP status reported to clients
+func (e *GrpcError) HTTPStatus() int {
+	switch e.Code {
+	case 3, 9, 11: // InvalidArgument, FailedPrecondition, OutOfRange
+		return http.StatusBadRequest
+	case 4: // DeadlineExceeded
+		return http.StatusGatewayTimeout
+	case 5: // NotFound
+		return http.StatusNotFound
+	case 7: // PermissionDenied
+		return http.StatusForbidden
+	case 8: // ResourceExhausted
+		return http.StatusTooManyRequests
+	case 12: // Unimplemented
+		return http.StatusNotImplemented
+	case 14: // Unavailable
+		return http.StatusServiceUnavailable
+	case 16: // Unauthenticated
+		return http.StatusUnauthorized
+	}
+	return http.StatusInternalServerError
+}
+
+// GrpcClient calls the inference service over cleartext HTTP/2, which is all gRPC needs
+// between the manager and a local worker
+type GrpcClient struct {
+	BaseURL string
+	HTTP    *http.Client
+}
+
+func NewGrpcClient(addr string) *GrpcClient {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	return &GrpcClient{
+		BaseURL: "http://" + addr,
+		HTTP:    &http.Client{Transport: &http.Transport{Protocols: &protocols}},
+	}
+}
+
+// grpcStream reads the length-prefixed messages of one call
+type grpcStream struct {
+	resp   *http.Response
+	header [5]byte
+}
+
+func (c *GrpcClient) call(ctx context.Context, method string, msg []byte) (*grpcStream, error) {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	frame = append(frame, msg...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+"/"+grpcService+"/"+method, bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set("Grpc-Timeout", strconv.FormatInt(max(1, time.Until(deadline).Milliseconds()), 10)+"m")
+	}
+	// gRPC metadata travels as HTTP/2 headers
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	if traceparent := tracing.Traceparent(ctx); traceparent != "" {
+		req.Header.Set(tracing.TraceparentHeader, traceparent)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("worker returned HTTP status %d", resp.StatusCode)
+	}
+	return &grpcStream{resp: resp}, nil
+}
+
+// Recv returns the next message, or io.EOF once the call ended with status OK
+func (s *grpcStream) Recv() ([]byte, error) {
+	if _, err := io.ReadFull(s.resp.Body, s.header[:]); err != nil {
+		if err == io.EOF {
+			return nil, s.status()
+		}
+		return nil, err
+	}
+	if s.header[0] != 0 {
+		return nil, errors.New("compressed gRPC messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(s.header[1:])
+	if size > maxGrpcMessage {
+		return nil, fmt.Errorf("gRPC message of %d bytes exceeds the %d byte limit", size, maxGrpcMessage)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(s.resp.Body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// status reads grpc-status from the trailers, or from the headers of a trailers-only response
+func (s *grpcStream) status() error {
+	code, message := s.resp.Trailer.Get("Grpc-Status"), s.resp.Trailer.Get("Grpc-Message")
+	if code == "" {
+		code, message = s.resp.Header.Get("Grpc-Status"), s.resp.Header.Get("Grpc-Message")
+	}
+	if code == "" {
+		return errors.New("worker response carries no gRPC status")
+	}
+	if code == "0" {
+		return io.EOF
+	}
+	n, _ := strconv.Atoi(code)
+	if unescaped, err := url.PathUnescape(message); err == nil {
+		message = unescaped
+	}
+	return &GrpcError{Code: n, Message: message}
+}
+
+func (s *grpcStream) Close() error {
+	return s.resp.Body.Close()
+}
+
+// unary makes a call that returns exactly one message
+func (c *GrpcClient) unary(ctx context.Context, method string, msg []byte) ([]byte, error) {
+	stream, err := c.call(ctx, method, msg)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	reply, err := stream.Recv()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%s returned no message", method)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		if err == nil {
+			err = fmt.Errorf("%s returned more than one message", method)
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *GrpcClient) Generate(ctx context.Context, req *GenerateRequest) (*GenerateResponse, error) {
+	reply, err := c.unary(ctx, "Generate", req.marshal())
+	if err != nil {
+		return nil, err
+	}
+	var resp GenerateResponse
+	return &resp, resp.unmarshal(reply)
+}
+
+// StreamGenerate calls fn with every chunk until the stream ends or fn returns an error
+func (c *GrpcClient) StreamGenerate(ctx context.Context, req *GenerateRequest, fn func(*GenerateChunk) error) error {
+	stream, err := c.call(ctx, "StreamGenerate", req.marshal())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var chunk GenerateChunk
+		if err := chunk.unmarshal(msg); err != nil {
+			return err
+		}
+		if err := fn(&chunk); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *GrpcClient) Embed(ctx context.Context, model string, input []string) (*EmbedResponse, error) {
+	var msg []byte
+	msg = appendString(msg, 1, model)
+	for _, text := range input {
+		msg = appendBytesField(msg, 2, []byte(text))
+	}
+	reply, err := c.unary(ctx, "Embed", msg)
+	if err != nil {
+		return nil, err
+	}
+	var resp EmbedResponse
+	return &resp, resp.unmarshal(reply)
+}
+
+func (c *GrpcClient) Health(ctx context.Context) (*WorkerHealth, error) {
+	reply, err := c.unary(ctx, "Health", nil)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseProto(reply)
+	if err != nil {
+		return nil, err
+	}
+	var health WorkerHealth
+	for _, f := range fields {
+		switch f.field {
+		case 1:
+			health.Status = f.str()
+		case 2:
+			health.ModelLoaded = f.num != 0
+		case 3:
+			health.Model = f.str()
+		}
+	}
+	return &health, nil
+}
@@ -0,0 +1,176 @@
+package supervisor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeGrpcWorker implements the inference service over h2c. Chat requests echo the last
+// message back; a model named "missing" fails with NOT_FOUND.
+func fakeGrpcWorker(t *testing.T) *GrpcWorker {
+	t.Helper()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" {
+			t.Errorf("unexpected request: %s %s", r.Proto, r.Header.Get("Content-Type"))
+		}
+		frame, _ := io.ReadAll(r.Body)
+		fields, err := parseProto(frame[5:])
+		if err != nil {
+			t.Fatalf("bad request message: %v", err)
+		}
+		var model, prompt string
+		var maxTokens int64 = -1
+		for _, f := range fields {
+			switch f.field {
+			case 1:
+				model = f.str()
+			case 2:
+				if r.URL.Path == "/"+grpcService+"/Embed" {
+					continue
+				}
+				message, _ := parseProto(f.data)
+				prompt = message[len(message)-1].str()
+			case 7:
+				maxTokens = int64(f.num)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		send := func(msg []byte) {
+			header := make([]byte, 5)
+			binary.BigEndian.PutUint32(header[1:], uint32(len(msg)))
+			w.Write(append(header, msg...))
+		}
+		if model == "missing" {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "model%20not%20loaded")
+			return
+		}
+		usage := appendInt(appendInt(nil, 1, 3, false), 2, 2, false)
+		switch strings.TrimPrefix(r.URL.Path, "/"+grpcService+"/") {
+		case "Generate":
+			var msg []byte
+			msg = appendString(msg, 2, model)
+			msg = appendString(msg, 3, "echo: "+prompt)
+			msg = appendString(msg, 4, "stop")
+			send(appendBytesField(msg, 5, usage))
+		case "StreamGenerate":
+			if maxTokens != 2 {
+				t.Errorf("max_tokens = %d, want 2", maxTokens)
+			}
+			send(appendString(nil, 1, "echo"))
+			send(appendBytesField(appendString(appendString(nil, 1, ": "+prompt), 2, "length"), 3, usage))
+		case "Embed":
+			vector := binary.LittleEndian.AppendUint32(nil, 0x3f800000)   // 1.0
+			vector = binary.LittleEndian.AppendUint32(vector, 0x40000000) // 2.0
+			send(appendBytesField(nil, 2, appendBytesField(nil, 1, vector)))
+		case "Health":
+			send(appendString(appendInt(appendString(nil, 1, "ok"), 2, 1, false), 3, "tiny.gguf"))
+		}
+		w.Header().Set("Grpc-Status", "0")
+	})
+	ts := httptest.NewUnstartedServer(handler)
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	worker := NewGrpcWorker("", "0")
+	worker.Client = NewGrpcClient(strings.TrimPrefix(ts.URL, "http://"))
+	return worker
+}
+
+func TestGrpcWorkerChatCompletion(t *testing.T) {
+	worker := fakeGrpcWorker(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"tiny","messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`))
+	worker.ProxyRequest(rec, req)
+
+	var resp struct {
+		Object  string
+		Choices []struct {
+			Message      struct{ Content string }
+			FinishReason string `json:"finish_reason"`
+		}
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		}
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if resp.Object != "chat.completion" || resp.Choices[0].Message.Content != "echo: hi" ||
+		resp.Choices[0].FinishReason != "stop" || resp.Usage.TotalTokens != 5 {
+		t.Errorf("unexpected response: %s", rec.Body)
+	}
+}
+
+func TestGrpcWorkerStreamsServerSentEvents(t *testing.T) {
+	worker := fakeGrpcWorker(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"tiny","stream":true,"max_tokens":2,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`))
+	worker.ProxyRequest(rec, req)
+
+	if !IsEventStream(rec.Header()) {
+		t.Fatalf("Content-Type = %s", rec.Header().Get("Content-Type"))
+	}
+	var text strings.Builder
+	var events []string
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		events = append(events, data)
+		var chunk struct {
+			Choices []struct{ Delta struct{ Content string } }
+			Usage   *struct{}
+		}
+		if json.Unmarshal([]byte(data), &chunk) == nil && len(chunk.Choices) > 0 {
+			text.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if text.String() != "echo: hi" || len(events) != 3 || events[2] != "[DONE]" {
+		t.Errorf("events = %v", events)
+	}
+	if !strings.Contains(events[1], `"usage"`) || !strings.Contains(events[1], `"finish_reason":"length"`) {
+		t.Errorf("last chunk = %s", events[1])
+	}
+}
+
+func TestGrpcWorkerMapsStatusCodes(t *testing.T) {
+	worker := fakeGrpcWorker(t)
+	rec := httptest.NewRecorder()
+	worker.ProxyRequest(rec, httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(`{"model":"missing","prompt":"hi"}`)))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "model not loaded") {
+		t.Errorf("status %d, body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestGrpcWorkerEmbeddingsAndHealth(t *testing.T) {
+	worker := fakeGrpcWorker(t)
+	rec := httptest.NewRecorder()
+	worker.ProxyRequest(rec, httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"tiny","input":"hi"}`)))
+	var resp struct {
+		Data []struct{ Embedding []float32 }
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if len(resp.Data) != 1 || len(resp.Data[0].Embedding) != 2 || resp.Data[0].Embedding[1] != 2 {
+		t.Errorf("status %d, body %s", rec.Code, rec.Body)
+	}
+
+	health, err := worker.Health()
+	if err != nil || health.Status != "ok" || !health.ModelLoaded || health.Model != "tiny.gguf" {
+		t.Errorf("health = %+v, %v", health, err)
+	}
+}
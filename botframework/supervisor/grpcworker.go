@@ -0,0 +1,352 @@
+package supervisor
+
+import (
+	"botframework/openai"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// GrpcWorker runs the Python worker with --protocol grpc. Clients still speak HTTP and SSE to
+// the manager; each request is translated into a call of the protocol in proto/inference.proto,
+// which avoids re-parsing JSON in the worker and reports failures as typed status codes.
+type GrpcWorker struct {
+	*PythonWorker
+	Client *GrpcClient
+}
+
+func NewGrpcWorker(scriptPath, port string) *GrpcWorker {
+	worker := &GrpcWorker{PythonWorker: NewPythonWorker(scriptPath, port), Client: NewGrpcClient("127.0.0.1:" + port)}
+	worker.Command = worker.command
+	worker.HealthCheck = func(ctx context.Context) error {
+		_, err := worker.Client.Health(ctx)
+		return err
+	}
+	worker.LivenessCheck = worker.generateOne
+	worker.WarmupRequest = worker.warmupRequest
+	return worker
+}
+
+// generateOne asks the worker for one token, or one embedding in embedding mode, over the
+// protocol
+func (g *GrpcWorker) generateOne(ctx context.Context) error {
+	if g.Mode == ModeEmbedding {
+		resp, err := g.Client.Embed(ctx, "", []string{"OK"})
+		if err == nil && (len(resp.Data) == 0 || len(resp.Data[0]) == 0) {
+			err = errors.New("liveness probe returned no embedding")
+		}
+		return err
+	}
+	maxTokens := int32(1)
+	_, err := g.Client.Generate(ctx, &GenerateRequest{Messages: []GenerateMessage{{Role: "user", Content: "Say OK."}}, MaxTokens: &maxTokens})
+	return err
+}
+
+// warmupRequest sends one warmup prompt over the protocol
+func (g *GrpcWorker) warmupRequest(ctx context.Context, prompt string) error {
+	if g.Mode == ModeEmbedding {
+		_, err := g.Client.Embed(ctx, "", []string{prompt})
+		return err
+	}
+	maxTokens := int32(max(g.Warmup.MaxTokens, 1))
+	_, err := g.Client.Generate(ctx, &GenerateRequest{Messages: []GenerateMessage{{Role: "user", Content: prompt}}, MaxTokens: &maxTokens})
+	return err
+}
+
+func (g *GrpcWorker) command(ctx context.Context) (*exec.Cmd, error) {
+	process, err := g.pythonCommand(ctx)
+	if err != nil {
+		return nil, err
+	}
+	process.Args = append(process.Args, "--protocol", "grpc")
+	return process, nil
+}
+
+func (g *GrpcWorker) Health() (*WorkerHealth, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return g.Client.Health(ctx)
+}
+
+// openAIRequest holds the fields of chat, completion and embedding requests that the
+// protocol carries
+type openAIRequest struct {
+	Model    string `json:"model"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+	Prompt              json.RawMessage `json:"prompt"`
+	Input               json.RawMessage `json:"input"`
+	Temperature         *float32        `json:"temperature"`
+	TopP                *float32        `json:"top_p"`
+	TopK                *int32          `json:"top_k"`
+	MaxTokens           *int32          `json:"max_tokens"`
+	MaxCompletionTokens *int32          `json:"max_completion_tokens"`
+	Stop                json.RawMessage `json:"stop"`
+	RepeatPenalty       *float32        `json:"repeat_penalty"`
+	Seed                *int64          `json:"seed"`
+	Stream              bool            `json:"stream"`
+	StreamOptions       struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+// ProxyRequest translates chat completions, completions and embeddings into gRPC calls
+func (g *GrpcWorker) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	defer g.track()()
+	if r.Method != http.MethodPost {
+		openai.WriteError(w, http.StatusMethodNotAllowed, "invalid_request_error", "", "method not allowed")
+		return
+	}
+	var body openAIRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "", "invalid JSON body: "+err.Error())
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/chat/completions", "/v1/completions":
+		chat := r.URL.Path == "/v1/chat/completions"
+		req, err := body.generateRequest(chat)
+		if err != nil {
+			openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+			return
+		}
+		if body.Stream {
+			g.stream(r.Context(), w, req, chat, body.StreamOptions.IncludeUsage)
+			return
+		}
+		g.generate(r.Context(), w, req, chat)
+	case "/v1/embeddings":
+		input, err := stringList(body.Input)
+		if err != nil || len(input) == 0 {
+			openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "", "input must be a string or a list of strings")
+			return
+		}
+		g.embed(r.Context(), w, body.Model, input)
+	default:
+		openai.WriteError(w, http.StatusNotFound, "invalid_request_error", "", r.URL.Path+" is not supported by gRPC workers")
+	}
+}
+
+func (body *openAIRequest) generateRequest(chat bool) (*GenerateRequest, error) {
+	req := &GenerateRequest{
+		Model:         body.Model,
+		Temperature:   body.Temperature,
+		TopP:          body.TopP,
+		TopK:          body.TopK,
+		MaxTokens:     body.MaxTokens,
+		RepeatPenalty: body.RepeatPenalty,
+		Seed:          body.Seed,
+	}
+	if body.MaxCompletionTokens != nil {
+		req.MaxTokens = body.MaxCompletionTokens
+	}
+	stop, err := stringList(body.Stop)
+	if err != nil {
+		return nil, errors.New("stop must be a string or a list of strings")
+	}
+	req.Stop = stop
+
+	if !chat {
+		prompts, err := stringList(body.Prompt)
+		if err != nil || len(prompts) != 1 {
+			return nil, errors.New("prompt must be a single string")
+		}
+		req.Prompt = prompts[0]
+		return req, nil
+	}
+	if len(body.Messages) == 0 {
+		return nil, errors.New("messages must not be empty")
+	}
+	for _, m := range body.Messages {
+		req.Messages = append(req.Messages, GenerateMessage{Role: m.Role, Content: contentText(m.Content)})
+	}
+	return req, nil
+}
+
+func (g *GrpcWorker) generate(ctx context.Context, w http.ResponseWriter, req *GenerateRequest, chat bool) {
+	resp, err := g.Client.Generate(ctx, req)
+	if err != nil {
+		writeGrpcError(w, err)
+		return
+	}
+	id, model := resp.ID, resp.Model
+	if id == "" {
+		id = completionID(chat)
+	}
+	if model == "" {
+		model = req.Model
+	}
+	choice := map[string]any{"index": 0, "finish_reason": resp.FinishReason}
+	object := "text_completion"
+	if chat {
+		object = "chat.completion"
+		choice["message"] = map[string]string{"role": "assistant", "content": resp.Text}
+	} else {
+		choice["text"] = resp.Text
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"id":      id,
+		"object":  object,
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []any{choice},
+		"usage":   usageJSON(resp.Usage),
+	})
+}
+
+// stream relays chunks as OpenAI server-sent events. Errors before the first chunk get a
+// proper status; later ones are sent as an error event, since the status is already out.
+func (g *GrpcWorker) stream(ctx context.Context, w http.ResponseWriter, req *GenerateRequest, chat, includeUsage bool) {
+	id, created := completionID(chat), time.Now().Unix()
+	object := "text_completion"
+	if chat {
+		object = "chat.completion.chunk"
+	}
+	started := false
+	send := func(payload any) {
+		data, _ := json.Marshal(payload)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	err := g.Client.StreamGenerate(ctx, req, func(chunk *GenerateChunk) error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("X-Accel-Buffering", "no")
+			w.WriteHeader(http.StatusOK)
+		}
+		choice := map[string]any{"index": 0, "finish_reason": nil}
+		if chunk.FinishReason != "" {
+			choice["finish_reason"] = chunk.FinishReason
+		}
+		if chat {
+			choice["delta"] = map[string]string{"content": chunk.Text}
+		} else {
+			choice["text"] = chunk.Text
+		}
+		event := map[string]any{"id": id, "object": object, "created": created, "model": req.Model, "choices": []any{choice}}
+		if chunk.Usage != nil && includeUsage {
+			event["usage"] = usageJSON(*chunk.Usage)
+		}
+		send(event)
+		return nil
+	})
+	if err != nil && !started {
+		writeGrpcError(w, err)
+		return
+	}
+	if err != nil {
+		send(map[string]any{"error": map[string]string{"message": err.Error(), "type": "server_error"}})
+		return
+	}
+	if !started {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func (g *GrpcWorker) embed(ctx context.Context, w http.ResponseWriter, model string, input []string) {
+	resp, err := g.Client.Embed(ctx, model, input)
+	if err != nil {
+		writeGrpcError(w, err)
+		return
+	}
+	if resp.Model == "" {
+		resp.Model = model
+	}
+	data := make([]any, len(resp.Data))
+	for i, vector := range resp.Data {
+		data[i] = map[string]any{"object": "embedding", "index": i, "embedding": vector}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"object": "list",
+		"model":  resp.Model,
+		"data":   data,
+		"usage":  map[string]int{"prompt_tokens": resp.Usage.PromptTokens, "total_tokens": resp.Usage.PromptTokens},
+	})
+}
+
+func usageJSON(usage GenerateUsage) map[string]int {
+	return map[string]int{
+		"prompt_tokens":     usage.PromptTokens,
+		"completion_tokens": usage.CompletionTokens,
+		"total_tokens":      usage.PromptTokens + usage.CompletionTokens,
+	}
+}
+
+func completionID(chat bool) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	if chat {
+		return "chatcmpl-" + hex.EncodeToString(b)
+	}
+	return "cmpl-" + hex.EncodeToString(b)
+}
+
+// stringList decodes a field that is a string or a list of strings; absent fields are empty
+func stringList(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}, nil
+	}
+	var list []string
+	err := json.Unmarshal(raw, &list)
+	return list, err
+}
+
+// contentText flattens message content, joining the text of multi-part content
+func contentText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	_ = json.Unmarshal(raw, &parts)
+	var texts []string
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// writeGrpcError reports a failed call with the HTTP status matching its gRPC status
+func writeGrpcError(w http.ResponseWriter, err error) {
+	var grpcErr *GrpcError
+	if errors.As(err, &grpcErr) {
+		errType := "server_error"
+		if status := grpcErr.HTTPStatus(); status < 500 {
+			errType = "invalid_request_error"
+		}
+		openai.WriteError(w, grpcErr.HTTPStatus(), errType, "", grpcErr.Message)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		openai.WriteError(w, http.StatusGatewayTimeout, "server_error", "", "request timed out")
+		return
+	}
+	openai.WriteError(w, http.StatusBadGateway, "server_error", "", err.Error())
+}
@@ -0,0 +1,209 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// HealthState is where a running worker stands with its liveness probe. A worker starts in
+// HealthStarting, is HealthHealthy once ready, becomes HealthDegraded when a probe is slow
+// or fails, and HealthFailed after LivenessProbe.Failures failures in a row, when it is
+// killed and restarted under its restart policy.
+type HealthState string
+
+const (
+	HealthStarting HealthState = "starting"
+	HealthHealthy  HealthState = "healthy"
+	HealthDegraded HealthState = "degraded"
+	HealthFailed   HealthState = "failed"
+)
+
+// LivenessProbe periodically sends a running worker a one-token generation, catching
+// workers that still answer /health but hang or return garbage
+type LivenessProbe struct {
+	// Interval is the time between probes; 0 disables them
+	Interval time.Duration
+	// Timeout is how long a probe may take before it counts as failed
+	Timeout time.Duration
+	// DegradedAfter is the latency above which a successful probe marks the worker degraded
+	DegradedAfter time.Duration
+	// Failures is how many failed probes in a row fail the worker
+	Failures int
+}
+
+// DefaultLivenessProbe reads the probe settings:
+//
+//	BOTFRAMEWORK_LIVENESS_INTERVAL        time between probes (default: off)
+//	BOTFRAMEWORK_LIVENESS_TIMEOUT         longest a probe may take (default: 30s)
+//	BOTFRAMEWORK_LIVENESS_DEGRADED_AFTER  latency that marks the worker degraded (default: 10s)
+//	BOTFRAMEWORK_LIVENESS_FAILURES        failed probes in a row that restart it (default: 3)
+func DefaultLivenessProbe() LivenessProbe {
+	probe := LivenessProbe{Timeout: 30 * time.Second, DegradedAfter: 10 * time.Second, Failures: 3}
+	if interval, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_LIVENESS_INTERVAL")); err == nil && interval > 0 {
+		probe.Interval = interval
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_LIVENESS_TIMEOUT")); err == nil && timeout > 0 {
+		probe.Timeout = timeout
+	}
+	if slow, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_LIVENESS_DEGRADED_AFTER")); err == nil && slow > 0 {
+		probe.DegradedAfter = slow
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_LIVENESS_FAILURES")); err == nil && n > 0 {
+		probe.Failures = n
+	}
+	return probe
+}
+
+// LivenessStatus is a worker's health state and its last probe
+type LivenessStatus struct {
+	State          HealthState `json:"state,omitempty"`
+	LatencySeconds float64     `json:"latency_seconds,omitempty"`
+	CheckedAt      time.Time   `json:"checked_at,omitzero"`
+	// Failures counts the failed probes since the last success
+	Failures int    `json:"failures,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// record moves the state machine on by one probe that took latency and failed with err
+func (s *LivenessStatus) record(probe LivenessProbe, latency time.Duration, err error) {
+	s.CheckedAt = time.Now()
+	s.LatencySeconds = latency.Seconds()
+	switch {
+	case err != nil:
+		s.Failures++
+		s.Error = err.Error()
+		s.State = HealthDegraded
+		if s.Failures >= probe.Failures {
+			s.State = HealthFailed
+		}
+	case probe.DegradedAfter > 0 && latency > probe.DegradedAfter:
+		s.Failures = 0
+		s.Error = fmt.Sprintf("probe took %s", latency.Round(time.Millisecond))
+		s.State = HealthDegraded
+	default:
+		s.Failures, s.Error = 0, ""
+		s.State = HealthHealthy
+	}
+}
+
+// watchLiveness probes the process that exit belongs to until it exits, killing it once it
+// fails the probe. A worker serving requests is not probed: engines like llama-cpp-python
+// generate one request at a time, so the probe would wait behind a long generation and
+// time out. Probes that requests arrived during are not counted either.
+func (p *PythonWorker) watchLiveness(ctx context.Context, exit *processExit) {
+	check := p.LivenessCheck
+	if check == nil {
+		check = p.generateOne
+	}
+	ticker := time.NewTicker(p.Liveness.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-exit.done:
+			return
+		case <-ticker.C:
+		}
+		if p.busy.Load() > 0 {
+			continue
+		}
+
+		served := p.served.Load()
+		probeCtx, cancel := context.WithTimeout(ctx, p.Liveness.Timeout)
+		start := time.Now()
+		err := check(probeCtx)
+		cancel()
+		latency := time.Since(start)
+		if p.served.Load() != served {
+			continue
+		}
+
+		p.mu.Lock()
+		if p.exit != exit || p.status.State != StateRunning {
+			p.mu.Unlock()
+			return
+		}
+		was := p.status.Liveness.State
+		p.status.Liveness.record(p.Liveness, latency, err)
+		liveness := p.status.Liveness
+		process := p.Process
+		p.mu.Unlock()
+
+		if liveness.State == was {
+			continue
+		}
+		switch liveness.State {
+		case HealthHealthy:
+			slog.Info("worker healthy again", "worker", p.name(), "latency", latency.Round(time.Millisecond))
+		case HealthDegraded:
+			slog.Warn("worker degraded", "worker", p.name(), "reason", liveness.Error)
+		case HealthFailed:
+			slog.Error("worker failed its liveness probe; killing it", "worker", p.name(), "failures", liveness.Failures, "err", liveness.Error)
+			_ = killGroup(process)
+			return
+		}
+	}
+}
+
+// generateOne asks the worker for one token over its OpenAI API, or one embedding in
+// embedding mode, and checks that the answer holds one
+func (p *PythonWorker) generateOne(ctx context.Context) error {
+	path, body := "/v1/chat/completions", `{"messages":[{"role":"user","content":"Say OK."}],"max_tokens":1,"temperature":0}`
+	if p.Mode == ModeEmbedding {
+		path, body = "/v1/embeddings", `{"input":"OK"}`
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://127.0.0.1:%s%s", p.Port, path), bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the probe's own timeout applies rather than the client's
+	client := *p.HTTPClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("liveness probe returned status %d", resp.StatusCode)
+	}
+
+	var answer struct {
+		Choices []json.RawMessage `json:"choices"`
+		Data    []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &answer); err != nil {
+		return fmt.Errorf("liveness probe returned garbage: %q", truncate(string(data), 80))
+	}
+	if p.Mode == ModeEmbedding {
+		if len(answer.Data) == 0 || len(answer.Data[0].Embedding) == 0 {
+			return fmt.Errorf("liveness probe returned no embedding: %q", truncate(string(data), 80))
+		}
+	} else if len(answer.Choices) == 0 {
+		return fmt.Errorf("liveness probe returned no choices: %q", truncate(string(data), 80))
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,129 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLivenessStateMachine(t *testing.T) {
+	probe := LivenessProbe{DegradedAfter: time.Second, Failures: 2}
+	status := LivenessStatus{State: HealthHealthy}
+	steps := []struct {
+		latency time.Duration
+		err     error
+		want    HealthState
+	}{
+		{10 * time.Millisecond, nil, HealthHealthy},
+		{2 * time.Second, nil, HealthDegraded},
+		{10 * time.Millisecond, nil, HealthHealthy},
+		{0, errors.New("hung"), HealthDegraded},
+		{0, errors.New("hung"), HealthFailed},
+	}
+	for i, step := range steps {
+		status.record(probe, step.latency, step.err)
+		if status.State != step.want {
+			t.Fatalf("step %d: state %s, want %s (%+v)", i, status.State, step.want, status)
+		}
+	}
+}
+
+func TestLivenessProbeRejectsGarbage(t *testing.T) {
+	for body, ok := range map[string]bool{
+		`{"choices":[{"message":{"content":"OK"}}]}`: true,
+		`{"choices":[]}`:    false,
+		`<html>oops</html>`: false,
+	} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(body))
+		}))
+		worker := NewPythonWorker("unused.py", extractPort(t, ts.URL))
+		if err := worker.generateOne(context.Background()); (err == nil) != ok {
+			t.Errorf("body %s: err = %v", body, err)
+		}
+		ts.Close()
+	}
+}
+
+func TestLivenessProbeRestartsHungWorker(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("needs /bin/sh")
+	}
+	// /health answers while generations hang
+	hang := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/") {
+			<-hang
+		}
+	}))
+	t.Cleanup(ts.Close)
+	t.Cleanup(func() { close(hang) })
+	script := filepath.Join(t.TempDir(), "worker.sh")
+	if err := os.WriteFile(script, []byte("sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BOTFRAMEWORK_PYTHON", "/bin/sh")
+
+	worker := NewPythonWorker(script, extractPort(t, ts.URL))
+	worker.Restart = RestartConfig{Policy: RestartOnFailure, MaxRestarts: 1, Backoff: time.Hour, MaxBackoff: time.Hour, StableAfter: time.Minute}
+	worker.Liveness = LivenessProbe{Interval: 10 * time.Millisecond, Timeout: 20 * time.Millisecond, Failures: 2}
+	// a warmup would hang like the probes
+	worker.Warmup = Warmup{}
+	t.Cleanup(func() { worker.Stop() })
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	status := waitForState(t, worker, StateRestarting)
+	if status.Liveness.State != HealthFailed || status.Liveness.Failures != 2 {
+		t.Errorf("liveness = %+v, want failed after two probes", status.Liveness)
+	}
+}
+
+func TestLivenessProbeSkipsBusyWorker(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("needs /bin/sh")
+	}
+	// like llama-cpp-python, the worker answers one generation at a time: probes wait
+	// behind the long generation in flight
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/") {
+			<-release
+		}
+	}))
+	t.Cleanup(ts.Close)
+	script := filepath.Join(t.TempDir(), "worker.sh")
+	if err := os.WriteFile(script, []byte("sleep 30\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BOTFRAMEWORK_PYTHON", "/bin/sh")
+
+	worker := NewPythonWorker(script, extractPort(t, ts.URL))
+	worker.Liveness = LivenessProbe{Interval: 10 * time.Millisecond, Timeout: 20 * time.Millisecond, Failures: 2}
+	worker.Warmup = Warmup{}
+	worker.StopGrace = 10 * time.Millisecond
+	t.Cleanup(func() { worker.Stop() })
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		worker.ProxyRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`)))
+	}()
+	time.Sleep(200 * time.Millisecond)
+	status := worker.Status()
+	close(release)
+	<-done
+	if status.State != StateRunning || status.Liveness.Failures != 0 {
+		t.Fatalf("status while serving = %+v, want running without failed probes", status)
+	}
+}
@@ -0,0 +1,152 @@
+package supervisor
+
+import (
+	"botframework/profiler"
+	"context"
+	"errors"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// offloadAllLayers asks llama-server to put every layer on the GPU
+const offloadAllLayers = 999
+
+// LlamaCppFlags are the llama-server options derived from the hardware profile
+type LlamaCppFlags struct {
+	GPULayers   int // -ngl
+	ContextSize int // -c
+	Threads     int // -t
+	// BatchSize and UBatchSize are set for CPU runs; 0 keeps llama-server's defaults
+	BatchSize  int // -b
+	UBatchSize int // -ub
+	// DraftModel turns on speculative decoding with a small model sharing the target's
+	// tokenizer; it is offloaded like the target. DraftMax is the tokens drafted per
+	// step, 0 keeping llama-server's default.
+	DraftModel string // -md
+	DraftMax   int    // --draft-max
+}
+
+// Args renders the flags as llama-server arguments
+func (f LlamaCppFlags) Args() []string {
+	args := []string{
+		"-ngl", strconv.Itoa(f.GPULayers),
+		"-c", strconv.Itoa(f.ContextSize),
+		"-t", strconv.Itoa(f.Threads),
+	}
+	if f.BatchSize > 0 {
+		args = append(args, "-b", strconv.Itoa(f.BatchSize), "-ub", strconv.Itoa(f.UBatchSize))
+	}
+	if f.DraftModel != "" {
+		args = append(args, "-md", f.DraftModel, "-ngld", strconv.Itoa(f.GPULayers))
+		if f.DraftMax > 0 {
+			args = append(args, "--draft-max", strconv.Itoa(f.DraftMax))
+		}
+	}
+	return args
+}
+
+// LlamaCppFlagsFor sizes llama-server for model, whose weights take modelSizeGB, on the
+// given hardware. The model is fully offloaded when it fits in VRAM with a gigabyte to
+// spare for the KV cache; otherwise as many layers as fit beside the KV cache go on the
+// GPU and the rest run on the CPU. The context grows with the memory left over. model may
+// be the zero Model, which estimates the layer count and the KV cache.
+func LlamaCppFlagsFor(profile *profiler.HardwareProfile, modelSizeGB float64, model profiler.Model) LlamaCppFlags {
+	// llama.cpp runs best with one thread per physical core; without a detected topology
+	// assume two threads per core
+	flags := LlamaCppFlags{Threads: max(1, runtime.NumCPU()/2)}
+	modelMB := int(modelSizeGB * 1024)
+
+	freeMB := 0
+	if profile != nil {
+		freeMB = profile.SystemRAM_MB - modelMB
+		gpu := profile.HasCuda || profile.HasMetal || profile.HasROCm || profile.HasOneAPI
+		if gpu && profile.VRAM_MB > modelMB+1024 {
+			flags.GPULayers = offloadAllLayers
+			freeMB = profile.VRAM_MB - modelMB
+		}
+		if profile.CPU.LogicalCores > 0 {
+			flags.Threads = profile.CPU.InferenceThreads()
+			if flags.GPULayers == 0 {
+				flags.UBatchSize = profile.CPU.InferenceBatch()
+				flags.BatchSize = 4 * flags.UBatchSize
+			}
+		}
+		// on battery or while throttling, half the cores still decode at most of the speed
+		// the throttled clocks allow and draw far less power
+		if profile.Power.Constrained() {
+			flags.Threads = max(1, flags.Threads/2)
+		}
+	}
+
+	switch {
+	case freeMB >= 8*1024:
+		flags.ContextSize = 8192
+	case freeMB >= 4*1024:
+		flags.ContextSize = 4096
+	default:
+		flags.ContextSize = 2048
+	}
+
+	// a model too large for VRAM still decodes faster with part of it on the GPU
+	if profile != nil && flags.GPULayers == 0 && (profile.HasCuda || profile.HasMetal || profile.HasROCm || profile.HasOneAPI) {
+		flags.GPULayers = profile.PlanOffload(model, modelSizeGB, flags.ContextSize).GPULayers
+	}
+	return flags
+}
+
+// LlamaCppWorker runs llama.cpp's llama-server binary directly, so GGUF models can be
+// served without a Python environment. Supervision, readiness and restarts are shared
+// with PythonWorker; requests are proxied to llama-server's OpenAI-compatible API.
+type LlamaCppWorker struct {
+	*PythonWorker
+	Binary string
+	Flags  LlamaCppFlags
+}
+
+func NewLlamaCppWorker(binary, port, modelPath string, flags LlamaCppFlags) *LlamaCppWorker {
+	worker := &LlamaCppWorker{PythonWorker: NewPythonWorker("", port), Binary: binary, Flags: flags}
+	worker.Name = "llama-server:" + port
+	worker.ModelPath = modelPath
+	worker.Command = worker.command
+	// llama-server stops generating once the client connection closes
+	worker.AbortPath = ""
+	return worker
+}
+
+// Args returns the llama-server command line, without the binary
+func (l *LlamaCppWorker) Args() []string {
+	args := []string{"-m", l.ModelPath, "--host", "127.0.0.1", "--port", l.Port}
+	if l.Mode == ModeEmbedding {
+		args = append(args, "--embeddings")
+	}
+	return append(args, l.Flags.Args()...)
+}
+
+func (l *LlamaCppWorker) command(ctx context.Context) (*exec.Cmd, error) {
+	if l.ModelPath == "" {
+		return nil, errors.New("llama-server needs a model file (set BOTFRAMEWORK_MODEL_PATH)")
+	}
+	slog.Info("starting llama-server", "worker", l.name(), "model", filepath.Base(l.ModelPath), "port", l.Port,
+		"gpu_layers", l.Flags.GPULayers, "context", l.Flags.ContextSize, "threads", l.Flags.Threads)
+	return exec.CommandContext(ctx, l.Binary, l.Args()...), nil
+}
+
+// Health reports llama-server's health. Its /health endpoint answers 503 until the model
+// has loaded, so a healthy server always has the model loaded.
+func (l *LlamaCppWorker) Health() (*WorkerHealth, error) {
+	health, err := l.PythonWorker.Health()
+	if err != nil {
+		return nil, err
+	}
+	health.ModelLoaded = true
+	health.Model = filepath.Base(l.ModelPath)
+	return health, nil
+}
+
+// Dialect names the gateway dialect for llama-server
+func (l *LlamaCppWorker) Dialect() string {
+	return "llama-server"
+}
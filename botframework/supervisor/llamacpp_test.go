@@ -0,0 +1,120 @@
+package supervisor
+
+import (
+	"botframework/profiler"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLlamaCppFlagsFor(t *testing.T) {
+	gpu := &profiler.HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024, SystemRAM_MB: 64 * 1024}
+	if flags := LlamaCppFlagsFor(gpu, 5, profiler.Model{}); flags.GPULayers != offloadAllLayers || flags.ContextSize != 8192 {
+		t.Errorf("24GB GPU, 5GB model: %+v, want full offload with 8192 context", flags)
+	}
+	// 80 layers of 0.5GB: 24GB less the 2.5GB KV cache of 8k tokens and 1.6GB of compute
+	// buffers holds 39 of them
+	model := profiler.Model{ParamsB: 70, Architecture: &profiler.Architecture{HiddenSize: 8192, Layers: 80, KVHeads: 8, HeadDim: 128}}
+	if flags := LlamaCppFlagsFor(gpu, 40, model); flags.GPULayers != 39 || flags.ContextSize != 8192 {
+		t.Errorf("24GB GPU, 40GB model: %+v, want 39 layers offloaded with 8192 context", flags)
+	}
+	small := &profiler.HardwareProfile{HasCuda: true, VRAM_MB: 2048, SystemRAM_MB: 64 * 1024}
+	if flags := LlamaCppFlagsFor(small, 40, model); flags.GPULayers != 0 {
+		t.Errorf("2GB GPU, 40GB model: %+v, want CPU, the KV cache leaving no room for layers", flags)
+	}
+
+	cpu := &profiler.HardwareProfile{SystemRAM_MB: 8 * 1024}
+	flags := LlamaCppFlagsFor(cpu, 5, profiler.Model{})
+	if flags.GPULayers != 0 || flags.ContextSize != 2048 || flags.Threads < 1 {
+		t.Errorf("8GB CPU host, 5GB model: %+v, want CPU with 2048 context", flags)
+	}
+
+	server := &profiler.HardwareProfile{SystemRAM_MB: 64 * 1024, CPU: profiler.CPUInfo{PhysicalCores: 16, LogicalCores: 32, AVX2: true, AVX512F: true}}
+	flags = LlamaCppFlagsFor(server, 5, profiler.Model{})
+	if flags.Threads != 16 || flags.UBatchSize != 512 || flags.BatchSize != 2048 {
+		t.Errorf("16-core AVX-512 host: %+v, want 16 threads and a 512 micro-batch", flags)
+	}
+	if args := flags.Args(); !slices.Contains(args, "-ub") {
+		t.Errorf("Args() = %v, want -b and -ub for a CPU run", args)
+	}
+
+	server.Power = profiler.PowerInfo{Source: profiler.PowerBattery}
+	if flags := LlamaCppFlagsFor(server, 5, profiler.Model{}); flags.Threads != 8 {
+		t.Errorf("16-core host on battery: %d threads, want 8", flags.Threads)
+	}
+}
+
+func TestLlamaCppWorkerArgs(t *testing.T) {
+	worker := NewLlamaCppWorker("llama-server", "9001", "/models/phi.gguf", LlamaCppFlags{GPULayers: 999, ContextSize: 4096, Threads: 8})
+	want := []string{"-m", "/models/phi.gguf", "--host", "127.0.0.1", "--port", "9001", "-ngl", "999", "-c", "4096", "-t", "8"}
+	if got := worker.Args(); !slices.Equal(got, want) {
+		t.Errorf("Args() = %v, want %v", got, want)
+	}
+
+	worker.Flags.DraftModel, worker.Flags.DraftMax = "/models/llama-1b.gguf", 3
+	if got := worker.Args(); !slices.Equal(got[len(want):], []string{"-md", "/models/llama-1b.gguf", "-ngld", "999", "--draft-max", "3"}) {
+		t.Errorf("Args() with a draft model = %v", got)
+	}
+
+	worker.Mode = ModeEmbedding
+	if got := worker.Args(); !slices.Contains(got, "--embeddings") {
+		t.Errorf("embedding worker Args() = %v, want --embeddings", got)
+	}
+}
+
+func TestLlamaCppWorkerNeedsModel(t *testing.T) {
+	worker := NewLlamaCppWorker("llama-server", "9001", "", LlamaCppFlags{})
+	if err := worker.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "model") {
+		t.Fatalf("Start without a model: %v", err)
+	}
+}
+
+func TestLlamaCppWorkerStart(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("needs /bin/sh")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	args := filepath.Join(dir, "args")
+	binary := filepath.Join(dir, "llama-server")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho \"$@\" > "+args+"\nexec sleep 10\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	worker := NewLlamaCppWorker(binary, extractPort(t, ts.URL), "/models/phi.gguf", LlamaCppFlags{ContextSize: 2048, Threads: 2})
+	worker.HTTPClient = ts.Client()
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer worker.Stop()
+
+	health, err := worker.Health()
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if !health.ModelLoaded || health.Model != "phi.gguf" {
+		t.Errorf("health = %+v, want phi.gguf loaded", health)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(args)
+		if strings.Contains(string(data), "-m /models/phi.gguf") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("llama-server was not started with the model, got args %q", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,219 @@
+package supervisor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogEventKind is what a well-known line of engine output reports
+type LogEventKind string
+
+const (
+	// EventWeights is llama.cpp placing a buffer of weights while it loads
+	EventWeights LogEventKind = "weights"
+	// EventModelLoaded is the model ready in memory
+	EventModelLoaded LogEventKind = "model_loaded"
+	// EventListening is the engine's server accepting requests
+	EventListening LogEventKind = "listening"
+	// EventThroughput is a generation rate the engine reports
+	EventThroughput LogEventKind = "throughput"
+	EventOOM        LogEventKind = "oom"
+	EventLoadFailed LogEventKind = "load_failed"
+)
+
+// LogEvent is a line of worker output the scanner recognised
+type LogEvent struct {
+	Kind LogEventKind
+	Line string
+	// Value is the number the line reports: megabytes for EventWeights and EventModelLoaded
+	// (0 when the engine does not say), tokens per second for EventThroughput
+	Value float64
+}
+
+// logPatterns recognise vLLM, llama.cpp (llama-server) and Python worker output, most
+// specific first. The first submatch that is set is the event's value.
+var logPatterns = []struct {
+	kind LogEventKind
+	re   *regexp.Regexp
+	// scale converts the value to the event's unit
+	scale float64
+}{
+	{EventOOM, regexp.MustCompile(`(?i)out of memory|OutOfMemoryError|cudaMalloc failed|hipMalloc failed|CUBLAS_STATUS_ALLOC_FAILED|ErrorOutOfDeviceMemory|failed to allocate \S+ buffer|unable to allocate backend buffer`), 1},
+	{EventLoadFailed, regexp.MustCompile(`(?i)error loading model|failed to load model|model path does not exist`), 1},
+	{EventWeights, regexp.MustCompile(`load_tensors: .*buffer size =\s*([\d.]+) MiB`), 1},
+	{EventModelLoaded, regexp.MustCompile(`Loading model weights took ([\d.]+) GB|Model loading took ([\d.]+) GiB|main: model loaded|Model loaded successfully`), 1024},
+	{EventThroughput, regexp.MustCompile(`Avg generation throughput: ([\d.]+) tokens/s|(?:^|[^t\s])\s*eval time = .*?([\d.]+) tokens per second`), 1},
+	{EventListening, regexp.MustCompile(`Uvicorn running on|Application startup complete|server is listening on`), 1},
+}
+
+// ParseLogLine returns the event a line of engine output reports, if it reports one
+func ParseLogLine(line string) (LogEvent, bool) {
+	for _, pattern := range logPatterns {
+		match := pattern.re.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		event := LogEvent{Kind: pattern.kind, Line: strings.TrimSpace(line)}
+		for _, group := range match[1:] {
+			if value, err := strconv.ParseFloat(group, 64); err == nil {
+				event.Value = value * pattern.scale
+				break
+			}
+		}
+		return event, true
+	}
+	return LogEvent{}, false
+}
+
+// ErrOutOfMemory and ErrModelLoadFailed match the startup errors of workers whose output
+// said they ran out of memory or could not load their model
+var (
+	ErrOutOfMemory     = errors.New("worker ran out of memory")
+	ErrModelLoadFailed = errors.New("worker failed to load its model")
+)
+
+// LogStats is what a worker's output said about it
+type LogStats struct {
+	// ModelLoadSeconds is how long the current process took to load its model
+	ModelLoadSeconds float64 `json:"model_load_seconds,omitempty"`
+	// WeightsMB is the memory the model's weights took, as the engine reported it
+	WeightsMB float64 `json:"weights_mb,omitempty"`
+	// TokensPerSecond is the last non-zero generation rate the engine reported
+	TokensPerSecond float64 `json:"tokens_per_second,omitempty"`
+	// OOMs counts the processes that ran out of memory
+	OOMs int `json:"ooms,omitempty"`
+	// Failure classifies what went wrong in the current or last process: oom or load_failed
+	Failure string `json:"failure,omitempty"`
+	// FailureLine is the output line Failure comes from
+	FailureLine string `json:"failure_line,omitempty"`
+}
+
+// LogScanner reads a worker's stdout and stderr for LogEvents. Each process's events
+// replace the last one's, but for OOMs, which add up across restarts.
+type LogScanner struct {
+	mu      sync.Mutex
+	partial []byte
+	started time.Time
+	stats   LogStats
+	// oom is set once the current process ran out of memory, so it counts once
+	oom   bool
+	ready chan struct{}
+}
+
+func NewLogScanner() *LogScanner {
+	return &LogScanner{started: time.Now(), ready: make(chan struct{}, 1)}
+}
+
+// Restart begins scanning a new process, started now
+func (s *LogScanner) Restart() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partial = s.partial[:0]
+	s.started = time.Now()
+	s.stats = LogStats{OOMs: s.stats.OOMs, TokensPerSecond: s.stats.TokensPerSecond}
+	s.oom = false
+	select {
+	case <-s.ready:
+	default:
+	}
+}
+
+// Ready receives when the worker logs that its model is loaded or its server is listening,
+// so readiness is probed then rather than at the next backoff
+func (s *LogScanner) Ready() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.ready
+}
+
+// Stats returns what the output said so far
+func (s *LogScanner) Stats() LogStats {
+	if s == nil {
+		return LogStats{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Failure returns ErrOutOfMemory or ErrModelLoadFailed, with the line that said so, once
+// the current process's output reports either
+func (s *LogScanner) Failure() error {
+	stats := s.Stats()
+	switch LogEventKind(stats.Failure) {
+	case EventOOM:
+		return fmt.Errorf("%w: %s", ErrOutOfMemory, stats.FailureLine)
+	case EventLoadFailed:
+		return fmt.Errorf("%w: %s", ErrModelLoadFailed, stats.FailureLine)
+	}
+	return nil
+}
+
+func (s *LogScanner) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partial = append(s.partial, p...)
+	for {
+		end := bytes.IndexByte(s.partial, '\n')
+		if end < 0 {
+			if len(s.partial) >= maxLine {
+				s.partial = s.partial[:0]
+			}
+			return len(p), nil
+		}
+		if event, ok := ParseLogLine(string(s.partial[:end])); ok {
+			s.record(event)
+		}
+		s.partial = s.partial[end+1:]
+	}
+}
+
+// maxLine bounds a buffered output line; longer lines are not scanned
+const maxLine = 64 << 10
+
+func (s *LogScanner) record(event LogEvent) {
+	switch event.Kind {
+	case EventWeights:
+		s.stats.WeightsMB += event.Value
+	case EventModelLoaded:
+		s.stats.ModelLoadSeconds = time.Since(s.started).Seconds()
+		if event.Value > 0 {
+			s.stats.WeightsMB = event.Value
+		}
+		s.wake()
+	case EventListening:
+		s.wake()
+	case EventThroughput:
+		if event.Value > 0 {
+			s.stats.TokensPerSecond = event.Value
+		}
+	case EventOOM:
+		if !s.oom {
+			s.oom = true
+			s.stats.OOMs++
+		}
+		s.stats.Failure, s.stats.FailureLine = string(event.Kind), event.Line
+	case EventLoadFailed:
+		// an OOM is the more precise cause when an engine reports both
+		if s.stats.Failure == "" {
+			s.stats.Failure, s.stats.FailureLine = string(event.Kind), event.Line
+		}
+	}
+}
+
+func (s *LogScanner) wake() {
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
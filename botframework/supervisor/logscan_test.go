@@ -0,0 +1,126 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestParseLogLine(t *testing.T) {
+	cases := []struct {
+		line  string
+		kind  LogEventKind
+		value float64
+	}{
+		{"INFO 05-01 12:00:03 model_runner.py:1072] Loading model weights took 14.9888 GB", EventModelLoaded, 14.9888 * 1024},
+		{"INFO 05-01 12:00:03 [gpu_model_runner.py:1595] Model loading took 4.00 GiB and 5.3 seconds", EventModelLoaded, 4096},
+		{"INFO 05-01 12:01:00 metrics.py:351] Avg prompt throughput: 120.5 tokens/s, Avg generation throughput: 35.2 tokens/s, Running: 1 reqs", EventThroughput, 35.2},
+		{"INFO:     Uvicorn running on http://0.0.0.0:8000 (Press CTRL+C to quit)", EventListening, 0},
+		{"llm_load_tensors:      CUDA0 buffer size =  4403.49 MiB", EventWeights, 4403.49},
+		{"load_tensors:   CPU_Mapped model buffer size =   281.81 MiB", EventWeights, 281.81},
+		{"main: model loaded", EventModelLoaded, 0},
+		{"main: server is listening on http://127.0.0.1:8080 - starting the main loop", EventListening, 0},
+		{"       eval time =    1234.56 ms /   100 tokens (   12.35 ms per token,    81.00 tokens per second)", EventThroughput, 81},
+		{"print_timings:        eval time =    1234.56 ms /   100 tokens (   12.35 ms per token,    81.00 tokens per second)", EventThroughput, 81},
+		{"ggml_backend_cuda_buffer_type_alloc_buffer: allocating 8192.00 MiB on device 0: cudaMalloc failed: out of memory", EventOOM, 0},
+		{"torch.OutOfMemoryError: CUDA out of memory. Tried to allocate 2.00 GiB", EventOOM, 0},
+		{"llama_load_model_from_file: failed to load model", EventLoadFailed, 0},
+		{"✅ Model loaded successfully!", EventModelLoaded, 0},
+		{"❌ Model path does not exist: /models/missing.gguf", EventLoadFailed, 0},
+	}
+	for _, c := range cases {
+		event, ok := ParseLogLine(c.line)
+		if !ok || event.Kind != c.kind || event.Value != c.value {
+			t.Errorf("%q: got %+v, %v; want %s %v", c.line, event, ok, c.kind, c.value)
+		}
+	}
+	for _, line := range []string{
+		"prompt eval time =     123.45 ms /    10 tokens (   12.35 ms per token,    81.00 tokens per second)",
+		"📥 Received request for model: phi-3",
+		"",
+	} {
+		if event, ok := ParseLogLine(line); ok {
+			t.Errorf("%q should not be an event, got %+v", line, event)
+		}
+	}
+}
+
+func TestLogScannerStats(t *testing.T) {
+	scan := NewLogScanner()
+	io.WriteString(scan, "llm_load_tensors:      CUDA0 buffer size =  4000.00 MiB\nllm_load_tensors:        CPU buffer size =   ")
+	io.WriteString(scan, "96.00 MiB\nmain: model loaded\n")
+	select {
+	case <-scan.Ready():
+	default:
+		t.Error("a loaded model should wake the readiness probe")
+	}
+	io.WriteString(scan, "       eval time =    1000.00 ms /   50 tokens (   20.00 ms per token,    50.00 tokens per second)\n")
+	io.WriteString(scan, "CUDA error: out of memory\nCUDA error: out of memory\n")
+
+	stats := scan.Stats()
+	if stats.WeightsMB != 4096 || stats.TokensPerSecond != 50 || stats.OOMs != 1 || stats.Failure != "oom" {
+		t.Errorf("stats = %+v", stats)
+	}
+	if err := scan.Failure(); !errors.Is(err, ErrOutOfMemory) {
+		t.Errorf("Failure() = %v", err)
+	}
+
+	scan.Restart()
+	if stats := scan.Stats(); stats.OOMs != 1 || stats.Failure != "" || stats.WeightsMB != 0 || scan.Failure() != nil {
+		t.Errorf("a new process keeps only the OOM count and throughput: %+v", stats)
+	}
+}
+
+func TestStartupStopsWhenWorkerRunsOutOfMemory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	worker := NewPythonWorker("unused.py", "0")
+	worker.Readiness.Deadline = time.Minute
+	worker.Restart.Policy = RestartNever
+	worker.Command = func(ctx context.Context) (*exec.Cmd, error) {
+		return exec.CommandContext(ctx, "sh", "-c", "echo 'cudaMalloc failed: out of memory' >&2; exec sleep 30"), nil
+	}
+	worker.HealthCheck = func(context.Context) error { return errors.New("still loading") }
+
+	start := time.Now()
+	err := worker.Start(context.Background())
+	defer worker.Stop()
+	if !errors.Is(err, ErrOutOfMemory) {
+		t.Fatalf("Start() = %v, want ErrOutOfMemory", err)
+	}
+	if waited := time.Since(start); waited > 10*time.Second {
+		t.Errorf("startup waited %s after the worker ran out of memory", waited)
+	}
+	if status := worker.Status(); status.Failure != "oom" || status.OOMs != 1 {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestStartupStopsWhenWorkerExits(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs sh")
+	}
+	worker := NewPythonWorker("unused.py", "0")
+	worker.Readiness.Deadline = time.Minute
+	worker.Restart.Policy = RestartNever
+	worker.Command = func(ctx context.Context) (*exec.Cmd, error) {
+		return exec.CommandContext(ctx, "sh", "-c", "echo 'ImportError: no module named llama_cpp' >&2; exit 1"), nil
+	}
+	worker.HealthCheck = func(context.Context) error { return errors.New("connection refused") }
+
+	start := time.Now()
+	err := worker.Start(context.Background())
+	defer worker.Stop()
+	var exitErr *exec.ExitError
+	if !errors.Is(err, ErrWorkerNeverReady) || !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("Start() = %v, want a never-ready error with the exit status", err)
+	}
+	if waited := time.Since(start); waited > 10*time.Second {
+		t.Errorf("startup waited %s after the worker exited", waited)
+	}
+}
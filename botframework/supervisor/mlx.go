@@ -0,0 +1,102 @@
+package supervisor
+
+import (
+	"botframework/profiler"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// mlxLauncher raises MLX's wired memory limit, so the weights stay resident in unified
+// memory instead of being paged out under pressure, then hands over to mlx_lm.server. It
+// takes the limit in MB followed by the server's arguments.
+const mlxLauncher = `import sys
+import mlx.core as mx
+limit = int(sys.argv[1]) << 20
+(getattr(mx, "set_wired_limit", None) or mx.metal.set_wired_limit)(limit)
+from mlx_lm.server import main
+sys.argv = ["mlx_lm.server"] + sys.argv[2:]
+main()
+`
+
+// MLXFlags are the mlx_lm.server settings derived from the hardware profile
+type MLXFlags struct {
+	// WiredLimitMB is the unified memory MLX keeps wired: the GPU's 70% share of RAM, less
+	// what models running alongside hold
+	WiredLimitMB int
+	// WeightsGB is the size of the weights the limit was sized for
+	WeightsGB float64
+}
+
+// MLXFlagsFor sizes mlx_lm.server for a model of modelSizeGB on Apple Silicon
+func MLXFlagsFor(profile *profiler.HardwareProfile, modelSizeGB float64) (MLXFlags, error) {
+	flags := MLXFlags{WeightsGB: modelSizeGB}
+	if profile == nil || !profile.HasMetal {
+		return flags, errors.New("MLX needs Apple Silicon")
+	}
+	flags.WiredLimitMB = profile.VRAM_MB - profile.ReservedMB
+	if float64(flags.WiredLimitMB)/1024.0 < modelSizeGB {
+		return flags, fmt.Errorf("the weights need %.1fGB, more than the %.1fGB of unified memory the GPU may wire", modelSizeGB, float64(flags.WiredLimitMB)/1024.0)
+	}
+	return flags, nil
+}
+
+// MLXWorker runs mlx_lm.server, MLX's OpenAI-compatible server, with its wired memory
+// limit raised to the GPU's share of unified memory. Supervision, readiness and restarts
+// are shared with PythonWorker; requests are proxied to the server.
+type MLXWorker struct {
+	*PythonWorker
+	// Python is the interpreter that has mlx_lm installed
+	Python string
+	Flags  MLXFlags
+}
+
+func NewMLXWorker(python, port, modelPath string, flags MLXFlags) *MLXWorker {
+	worker := &MLXWorker{PythonWorker: NewPythonWorker("", port), Python: python, Flags: flags}
+	worker.Name = "mlx:" + port
+	worker.ModelPath = modelPath
+	worker.Command = worker.command
+	// mlx_lm.server stops generating once the client connection closes
+	worker.AbortPath = ""
+	return worker
+}
+
+// Args returns the interpreter's command line: the launcher, the wired limit and the
+// mlx_lm.server arguments
+func (m *MLXWorker) Args() []string {
+	return []string{"-c", mlxLauncher, strconv.Itoa(m.Flags.WiredLimitMB),
+		"--model", m.ModelPath, "--host", "127.0.0.1", "--port", m.Port}
+}
+
+func (m *MLXWorker) command(ctx context.Context) (*exec.Cmd, error) {
+	if m.ModelPath == "" {
+		return nil, errors.New("mlx_lm.server needs a model (set BOTFRAMEWORK_MODEL_PATH)")
+	}
+	if m.Mode == ModeEmbedding {
+		return nil, errors.New("mlx_lm.server does not serve embeddings")
+	}
+	slog.Info("starting mlx_lm.server", "worker", m.name(), "model", filepath.Base(m.ModelPath), "port", m.Port,
+		"wired_limit_mb", m.Flags.WiredLimitMB)
+	return exec.CommandContext(ctx, m.Python, m.Args()...), nil
+}
+
+// Health reports mlx_lm.server's health. It loads the model given on its command line
+// before it listens, so a healthy server always has the model loaded.
+func (m *MLXWorker) Health() (*WorkerHealth, error) {
+	health, err := m.PythonWorker.Health()
+	if err != nil {
+		return nil, err
+	}
+	health.ModelLoaded = true
+	health.Model = filepath.Base(m.ModelPath)
+	return health, nil
+}
+
+// Dialect names the gateway dialect for mlx_lm.server
+func (m *MLXWorker) Dialect() string {
+	return "mlx"
+}
@@ -0,0 +1,38 @@
+package supervisor
+
+import (
+	"botframework/profiler"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMLXFlagsFor(t *testing.T) {
+	mac := &profiler.HardwareProfile{HasMetal: true, SystemRAM_MB: 32 * 1024, VRAM_MB: 22937, ReservedMB: 1024}
+	flags, err := MLXFlagsFor(mac, 8)
+	if err != nil || flags.WiredLimitMB != 21913 {
+		t.Errorf("32GB Mac: %+v, %v; want the 70%% share less the reserved memory wired", flags, err)
+	}
+	if _, err := MLXFlagsFor(mac, 24); err == nil {
+		t.Error("24GB of weights sized for a 32GB Mac")
+	}
+	if _, err := MLXFlagsFor(&profiler.HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024}, 8); err == nil {
+		t.Error("MLX sized for an NVIDIA host")
+	}
+}
+
+func TestMLXWorkerArgs(t *testing.T) {
+	worker := NewMLXWorker("python3", "9001", "/models/qwen-2.5-7b-4bit", MLXFlags{WiredLimitMB: 21913})
+	args := worker.Args()
+	if args[0] != "-c" || !strings.Contains(args[1], "set_wired_limit") {
+		t.Fatalf("Args() = %v, want the launcher script", args)
+	}
+	if want := []string{"21913", "--model", "/models/qwen-2.5-7b-4bit", "--host", "127.0.0.1", "--port", "9001"}; !slices.Equal(args[2:], want) {
+		t.Errorf("Args() = %v, want %v after the script", args[2:], want)
+	}
+
+	worker.Mode = ModeEmbedding
+	if _, err := worker.command(t.Context()); err == nil {
+		t.Error("mlx_lm.server started for embeddings")
+	}
+}
@@ -0,0 +1,327 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Worker is a single inference process the pool can balance across
+type Worker interface {
+	Start(ctx context.Context) error
+	ProxyRequest(w http.ResponseWriter, r *http.Request)
+	Health() (*WorkerHealth, error)
+	Status() WorkerStatus
+	Stop() error
+}
+
+// BalanceStrategy picks which pool member serves the next request
+type BalanceStrategy string
+
+const (
+	RoundRobin   BalanceStrategy = "round-robin"
+	LeastPending BalanceStrategy = "least-pending" // fewest in-flight requests
+)
+
+// ParseBalanceStrategy validates a strategy name
+func ParseBalanceStrategy(s string) (BalanceStrategy, error) {
+	switch strategy := BalanceStrategy(s); strategy {
+	case RoundRobin, LeastPending:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown balance strategy %q (want round-robin or least-pending)", s)
+}
+
+// ErrNoHealthyWorkers is reported when every pool member is out of rotation
+var ErrNoHealthyWorkers = errors.New("no healthy workers in pool")
+
+// WorkerPool serves one model from several workers, spreading requests across the members
+// that pass their health checks. It satisfies engine.InferenceEngine.
+type WorkerPool struct {
+	Strategy BalanceStrategy
+	// HealthInterval is how often members are probed; failing members leave the rotation
+	// until they pass again
+	HealthInterval time.Duration
+
+	members []*poolMember
+	next    atomic.Uint64
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+}
+
+type poolMember struct {
+	worker  Worker
+	pending atomic.Int64
+	healthy atomic.Bool
+}
+
+// PoolMemberStatus describes one pool member for admin views
+type PoolMemberStatus struct {
+	Index   int          `json:"index"`
+	Port    string       `json:"port,omitempty"`
+	Healthy bool         `json:"healthy"`
+	Pending int64        `json:"pending"`
+	Status  WorkerStatus `json:"status"`
+}
+
+func NewWorkerPool(strategy BalanceStrategy, workers ...Worker) *WorkerPool {
+	pool := &WorkerPool{Strategy: strategy, HealthInterval: 5 * time.Second}
+	for _, worker := range workers {
+		pool.members = append(pool.members, &poolMember{worker: worker})
+	}
+	return pool
+}
+
+// NewPythonWorkerPool creates one Python worker per port, all serving modelPath
+func NewPythonWorkerPool(scriptPath, modelPath string, ports []string, strategy BalanceStrategy) *WorkerPool {
+	workers := make([]Worker, len(ports))
+	for i, port := range ports {
+		worker := NewPythonWorker(scriptPath, port)
+		worker.ModelPath = modelPath
+		workers[i] = worker
+	}
+	return NewWorkerPool(strategy, workers...)
+}
+
+// Workers returns the pool's members, for callers that configure them before Start
+func (p *WorkerPool) Workers() []Worker {
+	workers := make([]Worker, len(p.members))
+	for i, m := range p.members {
+		workers[i] = m.worker
+	}
+	return workers
+}
+
+// Start starts every member concurrently. The pool starts if at least one member does;
+// members that fail stay out of rotation until a health check passes.
+func (p *WorkerPool) Start(ctx context.Context) error {
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.mu.Unlock()
+		return errors.New("pool already started")
+	}
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.mu.Unlock()
+
+	slog.Info("starting worker pool", "size", len(p.members), "strategy", p.Strategy)
+	errs := make([]error, len(p.members))
+	var wg sync.WaitGroup
+	for i, m := range p.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if errs[i] = m.worker.Start(ctx); errs[i] == nil {
+				m.healthy.Store(true)
+			} else {
+				slog.Warn("pool worker failed to start", "member", i, "err", errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p.healthyCount() == 0 {
+		return fmt.Errorf("%w: %w", ErrNoHealthyWorkers, errors.Join(errs...))
+	}
+	for i, m := range p.members {
+		if errs[i] != nil {
+			go p.retry(ctx, i, m, errs[i])
+		}
+	}
+	go p.monitor(ctx)
+	return nil
+}
+
+// restartable is a member that can be started again under its own restart policy
+type restartable interface {
+	Relaunch() error
+	restartConfig() RestartConfig
+}
+
+// retry starts a member that failed to start again, as its restart policy restarts a
+// worker that crashed: after a growing backoff, up to MaxRestarts times
+func (p *WorkerPool) retry(ctx context.Context, i int, m *poolMember, err error) {
+	worker, ok := m.worker.(restartable)
+	if !ok {
+		return
+	}
+	config := worker.restartConfig()
+	for attempt := 0; config.Policy.restarts(err) && attempt < config.MaxRestarts; attempt++ {
+		backoff := config.delay(attempt)
+		slog.Info("restarting pool worker", "member", i, "backoff", backoff, "attempt", attempt+1, "max_restarts", config.MaxRestarts)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if err = worker.Relaunch(); err == nil {
+			if ctx.Err() == nil {
+				m.healthy.Store(true)
+				slog.Info("pool worker back in rotation", "member", i)
+			}
+			return
+		}
+		slog.Warn("pool worker failed to start", "member", i, "err", err)
+	}
+	slog.Error("leaving pool worker out of rotation", "member", i, "policy", config.Policy, "err", err)
+}
+
+func (p *WorkerPool) monitor(ctx context.Context) {
+	ticker := time.NewTicker(p.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.CheckHealth()
+		}
+	}
+}
+
+// CheckHealth probes every member and updates the rotation
+func (p *WorkerPool) CheckHealth() {
+	for i, m := range p.members {
+		_, err := m.worker.Health()
+		status := m.worker.Status()
+		healthy := err == nil && status.State == StateRunning && status.Liveness.State != HealthFailed
+		if was := m.healthy.Swap(healthy); was != healthy {
+			if healthy {
+				slog.Info("pool worker back in rotation", "member", i)
+			} else {
+				slog.Warn("pool worker removed from rotation", "member", i, "err", err)
+			}
+		}
+	}
+}
+
+func (p *WorkerPool) healthyCount() int {
+	n := 0
+	for _, m := range p.members {
+		if m.healthy.Load() {
+			n++
+		}
+	}
+	return n
+}
+
+// pick selects a healthy member according to the strategy
+func (p *WorkerPool) pick() *poolMember {
+	n := uint64(len(p.members))
+	if n == 0 {
+		return nil
+	}
+	start := p.next.Add(1) - 1
+	var best *poolMember
+	for i := uint64(0); i < n; i++ {
+		m := p.members[(start+i)%n]
+		if !m.healthy.Load() {
+			continue
+		}
+		if p.Strategy != LeastPending {
+			return m
+		}
+		if best == nil || m.pending.Load() < best.pending.Load() {
+			best = m
+		}
+	}
+	return best
+}
+
+func (p *WorkerPool) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	m := p.pick()
+	if m == nil {
+		http.Error(w, ErrNoHealthyWorkers.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	m.pending.Add(1)
+	defer m.pending.Add(-1)
+	m.worker.ProxyRequest(w, r)
+}
+
+// Health reports the health of a member in rotation
+func (p *WorkerPool) Health() (*WorkerHealth, error) {
+	var lastErr error = ErrNoHealthyWorkers
+	for _, m := range p.members {
+		if !m.healthy.Load() {
+			continue
+		}
+		health, err := m.worker.Health()
+		if err == nil {
+			return health, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Status is running while any member is in rotation; restarts are summed across members
+func (p *WorkerPool) Status() WorkerStatus {
+	status := WorkerStatus{State: StateStopped}
+	var ports []string
+	for _, m := range p.members {
+		member := m.worker.Status()
+		status.Restarts += member.Restarts
+		if member.Port != "" {
+			ports = append(ports, member.Port)
+		}
+		switch {
+		case m.healthy.Load():
+			status.State = StateRunning
+		case status.State != StateRunning && member.State != StateStopped:
+			status.State = member.State
+		}
+	}
+	status.Port = strings.Join(ports, ",")
+	return status
+}
+
+// Available reports whether any member is in rotation
+func (p *WorkerPool) Available() bool {
+	return p.healthyCount() > 0
+}
+
+// Members describes every member, in or out of rotation
+func (p *WorkerPool) Members() []PoolMemberStatus {
+	out := make([]PoolMemberStatus, len(p.members))
+	for i, m := range p.members {
+		out[i] = PoolMemberStatus{Index: i, Healthy: m.healthy.Load(), Pending: m.pending.Load(), Status: m.worker.Status()}
+		switch worker := m.worker.(type) {
+		case *PythonWorker:
+			out[i].Port = worker.Port
+		case *LlamaCppWorker:
+			out[i].Port = worker.Port
+		case *GrpcWorker:
+			out[i].Port = worker.Port
+		}
+	}
+	return out
+}
+
+func (p *WorkerPool) Stop() error {
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.mu.Unlock()
+
+	// members stop concurrently so their grace periods overlap
+	errs := make([]error, len(p.members))
+	var wg sync.WaitGroup
+	for i, m := range p.members {
+		m.healthy.Store(false)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.worker.Stop()
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,181 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeWorker struct {
+	name     string
+	startErr error
+	down     atomic.Bool
+	served   atomic.Int32
+	release  chan struct{}
+}
+
+func (f *fakeWorker) Start(context.Context) error { return f.startErr }
+func (f *fakeWorker) ProxyRequest(w http.ResponseWriter, r *http.Request) {
+	f.served.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	w.Header().Set("X-Worker", f.name)
+}
+func (f *fakeWorker) Health() (*WorkerHealth, error) {
+	if f.down.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return &WorkerHealth{Status: "ok", Model: f.name}, nil
+}
+func (f *fakeWorker) Status() WorkerStatus {
+	if f.down.Load() || f.startErr != nil {
+		return WorkerStatus{State: StateRestarting, Restarts: 1}
+	}
+	return WorkerStatus{State: StateRunning}
+}
+func (f *fakeWorker) Stop() error { return nil }
+
+func servedBy(p *WorkerPool) string {
+	rec := httptest.NewRecorder()
+	p.ProxyRequest(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+	if rec.Code != http.StatusOK {
+		return rec.Result().Status
+	}
+	return rec.Header().Get("X-Worker")
+}
+
+func TestPoolRoundRobinSkipsUnhealthy(t *testing.T) {
+	a, b, c := &fakeWorker{name: "a"}, &fakeWorker{name: "b"}, &fakeWorker{name: "c"}
+	pool := NewWorkerPool(RoundRobin, a, b, c)
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Stop()
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		order = append(order, servedBy(pool))
+	}
+	if order[0] != "a" || order[1] != "b" || order[2] != "c" {
+		t.Errorf("unexpected rotation %v", order)
+	}
+
+	b.down.Store(true)
+	pool.CheckHealth()
+	for i := 0; i < 4; i++ {
+		if got := servedBy(pool); got == "b" {
+			t.Fatal("unhealthy worker should be out of rotation")
+		}
+	}
+	if status := pool.Status(); status.State != StateRunning || status.Restarts != 1 {
+		t.Errorf("unexpected pool status %+v", status)
+	}
+
+	b.down.Store(false)
+	pool.CheckHealth()
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		seen[servedBy(pool)] = true
+	}
+	if !seen["b"] {
+		t.Error("recovered worker should rejoin the rotation")
+	}
+}
+
+func TestPoolLeastPending(t *testing.T) {
+	busy := &fakeWorker{name: "busy", release: make(chan struct{})}
+	idle := &fakeWorker{name: "idle"}
+	pool := NewWorkerPool(LeastPending, busy, idle)
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Stop()
+
+	// with no requests in flight the tie goes to the first member, parking this request on busy
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		servedBy(pool)
+	}()
+	for busy.served.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		if got := servedBy(pool); got != "idle" {
+			t.Errorf("request %d went to %q", i, got)
+		}
+	}
+	close(busy.release)
+	wg.Wait()
+}
+
+func TestPoolStartNeedsOneWorker(t *testing.T) {
+	down := errors.New("no GPU")
+	partial := NewWorkerPool(RoundRobin, &fakeWorker{name: "a", startErr: down}, &fakeWorker{name: "b"})
+	if err := partial.Start(context.Background()); err != nil {
+		t.Fatalf("pool with one live worker should start: %v", err)
+	}
+	if got := servedBy(partial); got != "b" {
+		t.Errorf("got %q", got)
+	}
+	partial.Stop()
+
+	dead := NewWorkerPool(RoundRobin, &fakeWorker{name: "a", startErr: down})
+	if err := dead.Start(context.Background()); !errors.Is(err, ErrNoHealthyWorkers) {
+		t.Fatalf("expected ErrNoHealthyWorkers, got %v", err)
+	}
+	if got := servedBy(dead); got != "503 Service Unavailable" {
+		t.Errorf("got %q", got)
+	}
+}
+
+// flakyWorker fails to start until it has been relaunched fails times
+type flakyWorker struct {
+	fakeWorker
+	fails      int
+	relaunches atomic.Int32
+}
+
+func (f *flakyWorker) Relaunch() error {
+	if int(f.relaunches.Add(1)) < f.fails {
+		return errors.New("out of memory")
+	}
+	return nil
+}
+func (f *flakyWorker) restartConfig() RestartConfig {
+	return RestartConfig{Policy: RestartOnFailure, MaxRestarts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+}
+
+func TestPoolRetriesMembersThatFailToStart(t *testing.T) {
+	down := errors.New("out of memory")
+	flaky := &flakyWorker{fakeWorker: fakeWorker{name: "a", startErr: down}, fails: 2}
+	hopeless := &flakyWorker{fakeWorker: fakeWorker{name: "b", startErr: down}, fails: 10}
+	pool := NewWorkerPool(RoundRobin, flaky, hopeless, &fakeWorker{name: "c"})
+	pool.HealthInterval = time.Hour
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Stop()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !pool.members[0].healthy.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !pool.members[0].healthy.Load() || flaky.relaunches.Load() != 2 {
+		t.Fatalf("member a not back in rotation after %d relaunches", flaky.relaunches.Load())
+	}
+	for hopeless.relaunches.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := hopeless.relaunches.Load(); got != 3 || pool.members[1].healthy.Load() {
+		t.Errorf("member b relaunched %d times (healthy %v), want the policy's 3 and out of rotation", got, pool.members[1].healthy.Load())
+	}
+}
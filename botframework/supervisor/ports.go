@@ -0,0 +1,109 @@
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ErrPortInUse is returned when a configured port is held by another process or worker
+var ErrPortInUse = errors.New("port already in use")
+
+// PortAllocator hands out ports for workers and records who holds each one, so two
+// workers are never given the same port and a configured port that is taken is reported
+// before anything is started on it
+type PortAllocator struct {
+	mu     sync.Mutex
+	owners map[string]string // port -> owner
+}
+
+func NewPortAllocator() *PortAllocator {
+	return &PortAllocator{owners: make(map[string]string)}
+}
+
+// Allocate finds a free loopback port by binding 127.0.0.1:0 and records it for owner
+func (a *PortAllocator) Allocate(owner string) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// the kernel may offer a port given out before that its worker has not bound yet
+	for range 16 {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", fmt.Errorf("allocate port for %s: %w", owner, err)
+		}
+		port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+		ln.Close()
+		if _, taken := a.owners[port]; !taken {
+			a.owners[port] = owner
+			return port, nil
+		}
+	}
+	return "", fmt.Errorf("allocate port for %s: no free port found", owner)
+}
+
+// Reserve claims the port of addr ("host:port", ":port" or a bare port) for owner. It fails
+// with ErrPortInUse when another owner holds the port or it cannot be bound.
+func (a *PortAllocator) Reserve(addr, owner string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = "127.0.0.1", addr
+	}
+	if _, err := strconv.Atoi(port); err != nil {
+		return fmt.Errorf("%s: invalid port %q", owner, port)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if holder, taken := a.owners[port]; taken && holder != owner {
+		return fmt.Errorf("%s: port %s is assigned to %s: %w", owner, port, holder, ErrPortInUse)
+	}
+	if err := CheckPort(net.JoinHostPort(host, port)); err != nil {
+		return fmt.Errorf("%s: %w", owner, err)
+	}
+	a.owners[port] = owner
+	return nil
+}
+
+// Release forgets port so it can be handed out again
+func (a *PortAllocator) Release(port string) {
+	a.mu.Lock()
+	delete(a.owners, port)
+	a.mu.Unlock()
+}
+
+// PortAssignment is one recorded port and its holder
+type PortAssignment struct {
+	Port  string `json:"port"`
+	Owner string `json:"owner"`
+}
+
+// Assignments lists the recorded ports in numeric order
+func (a *PortAllocator) Assignments() []PortAssignment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]PortAssignment, 0, len(a.owners))
+	for port, owner := range a.owners {
+		out = append(out, PortAssignment{Port: port, Owner: owner})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		pi, _ := strconv.Atoi(out[i].Port)
+		pj, _ := strconv.Atoi(out[j].Port)
+		return pi < pj
+	})
+	return out
+}
+
+// CheckPort reports ErrPortInUse when addr cannot be bound because something listens on it
+func CheckPort(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		if errors.Is(err, syscall.EADDRINUSE) {
+			return fmt.Errorf("%s: %w", addr, ErrPortInUse)
+		}
+		return err
+	}
+	return ln.Close()
+}
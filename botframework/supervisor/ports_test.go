@@ -0,0 +1,39 @@
+package supervisor
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestPortAllocator(t *testing.T) {
+	ports := NewPortAllocator()
+	first, err := ports.Allocate("fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ports.Allocate("quality")
+	if err != nil || second == first {
+		t.Fatalf("second port %q (first %q), err %v", second, first, err)
+	}
+	if err := ports.Reserve(first, "other"); !errors.Is(err, ErrPortInUse) {
+		t.Errorf("reserving an assigned port: %v", err)
+	}
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	if err := ports.Reserve(busy.Addr().String(), "default worker"); !errors.Is(err, ErrPortInUse) {
+		t.Errorf("reserving a bound port: %v", err)
+	}
+
+	ports.Release(first)
+	if err := ports.Reserve(first, "other"); err != nil {
+		t.Errorf("a released port should be free again: %v", err)
+	}
+	if got := ports.Assignments(); len(got) != 2 {
+		t.Errorf("assignments = %+v", got)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package supervisor
+
+import "os/exec"
+
+func detach(cmd *exec.Cmd) {}
+
+func killGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package supervisor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the worker in its own process group, so a Ctrl-C in the manager's terminal
+// reaches only the manager, which drains requests before stopping its workers
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killGroup kills the worker and any processes it started, which share its process group,
+// so a hung child cannot keep holding the worker's port
+func killGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,101 @@
+package supervisor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProcessRSS returns the resident memory of pid and its descendants in bytes. Workers
+// launched through pipenv or a shell run the model in a child process, hence the tree.
+func ProcessRSS(pid int) (int64, error) {
+	if pid <= 0 {
+		return 0, fmt.Errorf("invalid pid %d", pid)
+	}
+	if _, err := os.Stat("/proc/self/status"); err == nil {
+		return procTreeRSS("/proc", pid)
+	}
+	// no procfs (macOS, BSD): the process alone
+	out, err := exec.Command("ps", "-o", "rss=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return 0, err
+	}
+	kb, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return kb * 1024, nil
+}
+
+func procTreeRSS(proc string, pid int) (int64, error) {
+	total, err := procRSS(proc, pid)
+	if err != nil {
+		return 0, err
+	}
+	children := procChildren(proc)
+	queue := children[pid]
+	for len(queue) > 0 {
+		child := queue[0]
+		queue = append(queue[1:], children[child]...)
+		if rss, err := procRSS(proc, child); err == nil {
+			total += rss
+		}
+	}
+	return total, nil
+}
+
+// procRSS reads VmRSS from /proc/<pid>/status
+func procRSS(proc string, pid int) (int64, error) {
+	file, err := os.Open(filepath.Join(proc, strconv.Itoa(pid), "status"))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "VmRSS:"); ok {
+			fields := strings.Fields(value)
+			if len(fields) == 0 {
+				break
+			}
+			kb, err := strconv.ParseInt(fields[0], 10, 64)
+			return kb * 1024, err
+		}
+	}
+	// kernel threads and zombies have no VmRSS
+	return 0, scanner.Err()
+}
+
+// procChildren maps each pid to its child pids using the ppid field of /proc/<pid>/stat
+func procChildren(proc string) map[int][]int {
+	children := make(map[int][]int)
+	entries, _ := os.ReadDir(proc)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join(proc, entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// the command name may contain spaces, so parse after its closing parenthesis
+		end := bytes.LastIndexByte(stat, ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		if ppid, err := strconv.Atoi(fields[1]); err == nil {
+			children[ppid] = append(children[ppid], pid)
+		}
+	}
+	return children
+}
@@ -0,0 +1,45 @@
+package supervisor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeProc(t *testing.T, proc, pid, ppid, rssKB string) {
+	t.Helper()
+	dir := filepath.Join(proc, pid)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	stat := pid + " (python3 -m worker) S " + ppid + " 1 1 0 -1\n"
+	status := "Name:\tpython3\nVmRSS:\t  " + rssKB + " kB\n"
+	if rssKB == "" {
+		status = "Name:\tkthread\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "status"), []byte(status), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcTreeRSS(t *testing.T) {
+	proc := t.TempDir()
+	writeProc(t, proc, "100", "1", "1000")   // pipenv
+	writeProc(t, proc, "101", "100", "5000") // python worker
+	writeProc(t, proc, "102", "101", "")     // exited helper without VmRSS
+	writeProc(t, proc, "200", "1", "9999")   // unrelated
+
+	rss, err := procTreeRSS(proc, 100)
+	if err != nil {
+		t.Fatalf("procTreeRSS: %v", err)
+	}
+	if want := int64(6000 * 1024); rss != want {
+		t.Errorf("rss = %d, want %d", rss, want)
+	}
+	if _, err := procTreeRSS(proc, 300); err == nil {
+		t.Error("expected an error for a missing pid")
+	}
+}
@@ -0,0 +1,118 @@
+package supervisor
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Protocol buffer wire types used by proto/inference.proto
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformedProto = errors.New("malformed protobuf message")
+
+func appendTag(b []byte, field, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+// appendString writes a string field, skipping the proto3 default
+func appendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendBytesField(b, field, []byte(s))
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendInt writes an int field; optional fields are written even when zero
+func appendInt(b []byte, field int, v int64, optional bool) []byte {
+	if v == 0 && !optional {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, uint64(v))
+}
+
+func appendFloat(b []byte, field int, v float32) []byte {
+	b = appendTag(b, field, wireFixed32)
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+}
+
+// protoField is one decoded field. Varint and fixed-width values are in num; length-delimited
+// values (strings, bytes, embedded messages, packed repeated fields) are in data.
+type protoField struct {
+	field int
+	wire  int
+	num   uint64
+	data  []byte
+}
+
+func (f protoField) str() string { return string(f.data) }
+
+func (f protoField) float() float32 { return math.Float32frombits(uint32(f.num)) }
+
+// parseProto splits a message into its fields, in wire order
+func parseProto(b []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errMalformedProto
+		}
+		b = b[n:]
+		f := protoField{field: int(tag >> 3), wire: int(tag & 7)}
+		switch f.wire {
+		case wireVarint:
+			f.num, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errMalformedProto
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errMalformedProto
+			}
+			f.num, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errMalformedProto
+			}
+			f.num, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return nil, errMalformedProto
+			}
+			f.data, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			return nil, errMalformedProto
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// floats decodes a repeated float field, packed (proto3's default) or not
+func (f protoField) floats() ([]float32, error) {
+	if f.wire == wireFixed32 {
+		return []float32{f.float()}, nil
+	}
+	if f.wire != wireBytes || len(f.data)%4 != 0 {
+		return nil, errMalformedProto
+	}
+	values := make([]float32, len(f.data)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(f.data[i*4:]))
+	}
+	return values, nil
+}
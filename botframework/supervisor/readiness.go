@@ -0,0 +1,131 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrWorkerNeverReady matches errors from workers that did not pass their readiness probe
+// before the startup deadline
+var ErrWorkerNeverReady = errors.New("worker never became ready")
+
+// NeverReadyError describes a failed startup; errors.Is(err, ErrWorkerNeverReady) holds for it
+type NeverReadyError struct {
+	Waited time.Duration
+	Probes int
+	Last   error // the last probe failure
+}
+
+func (e *NeverReadyError) Error() string {
+	return fmt.Sprintf("worker never became ready after %s (%d probes): %v", e.Waited.Round(time.Millisecond), e.Probes, e.Last)
+}
+
+func (e *NeverReadyError) Is(target error) bool { return target == ErrWorkerNeverReady }
+
+func (e *NeverReadyError) Unwrap() error { return e.Last }
+
+// ReadinessProbe polls a worker until its health check passes, backing off exponentially
+// between attempts so slow model loads are not hammered with requests
+type ReadinessProbe struct {
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	// Deadline bounds the whole startup, including model loading
+	Deadline time.Duration
+}
+
+// DefaultReadinessProbe waits up to BOTFRAMEWORK_WORKER_READY_TIMEOUT (default: 2m)
+func DefaultReadinessProbe() ReadinessProbe {
+	probe := ReadinessProbe{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     5 * time.Second,
+		Multiplier:      2,
+		Deadline:        2 * time.Minute,
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_WORKER_READY_TIMEOUT")); err == nil && timeout > 0 {
+		probe.Deadline = timeout
+	}
+	return probe
+}
+
+// Wait calls check until it succeeds. It returns a *NeverReadyError once the deadline passes,
+// or ctx's error if ctx is cancelled first.
+func (p ReadinessProbe) Wait(ctx context.Context, check func(context.Context) error) error {
+	return p.WaitOrWake(ctx, check, nil)
+}
+
+// WaitOrWake is Wait, probing again at once whenever wake receives rather than at the end of
+// the backoff. A check error made with abortProbe ends the wait with that error.
+func (p ReadinessProbe) WaitOrWake(ctx context.Context, check func(context.Context) error, wake <-chan struct{}) error {
+	return p.waitProcess(ctx, check, wake, nil)
+}
+
+// waitProcess is WaitOrWake for a worker process that gives up as soon as it exits, with a
+// *NeverReadyError carrying the exit error, rather than probing a dead worker until the
+// deadline. A nil exit waits out the deadline.
+func (p ReadinessProbe) waitProcess(ctx context.Context, check func(context.Context) error, wake <-chan struct{}, exit *processExit) error {
+	start := time.Now()
+	deadline, cancel := context.WithTimeout(ctx, p.Deadline)
+	defer cancel()
+
+	var exited <-chan struct{}
+	if exit != nil {
+		exited = exit.done
+	}
+	interval := p.InitialInterval
+	for probes := 1; ; probes++ {
+		err := check(deadline)
+		if err == nil {
+			return nil
+		}
+		if abort, ok := err.(abortError); ok {
+			return abort.error
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-wake:
+			timer.Stop()
+		case <-exited:
+			timer.Stop()
+			// the output is read to the end once the process is reaped, so a failure it
+			// reported is found by one more check
+			if err := check(deadline); err == nil {
+				return nil
+			} else if abort, ok := err.(abortError); ok {
+				return abort.error
+			}
+			return &NeverReadyError{Waited: time.Since(start), Probes: probes + 1, Last: exit.failure()}
+		case <-deadline.Done():
+			timer.Stop()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &NeverReadyError{Waited: time.Since(start), Probes: probes, Last: err}
+		}
+		interval = min(time.Duration(float64(interval)*p.Multiplier), p.MaxInterval)
+	}
+}
+
+// abortError is a check failure no amount of waiting fixes, such as a worker that ran out
+// of memory while loading
+type abortError struct{ error }
+
+func abortProbe(err error) error { return abortError{err} }
+
+// scannedCheck fails a readiness check for good once the worker's output reports a failure
+func scannedCheck(scan *LogScanner, check func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if err := scan.Failure(); err != nil {
+			return abortProbe(err)
+		}
+		return check(ctx)
+	}
+}
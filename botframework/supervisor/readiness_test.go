@@ -0,0 +1,56 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadinessProbeBacksOff(t *testing.T) {
+	probe := ReadinessProbe{InitialInterval: 10 * time.Millisecond, MaxInterval: 40 * time.Millisecond, Multiplier: 2, Deadline: time.Second}
+	var calls []time.Time
+	err := probe.Wait(context.Background(), func(context.Context) error {
+		calls = append(calls, time.Now())
+		if len(calls) < 5 {
+			return errors.New("loading")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// gaps should be roughly 10, 20, 40, 40ms
+	last := calls[4].Sub(calls[3])
+	first := calls[1].Sub(calls[0])
+	if last < 35*time.Millisecond || last > 200*time.Millisecond || first > last {
+		t.Errorf("unexpected probe spacing: first %s, last %s", first, last)
+	}
+}
+
+func TestReadinessProbeDeadline(t *testing.T) {
+	probe := ReadinessProbe{InitialInterval: 10 * time.Millisecond, MaxInterval: 10 * time.Millisecond, Multiplier: 2, Deadline: 50 * time.Millisecond}
+	err := probe.Wait(context.Background(), func(context.Context) error { return errors.New("connection refused") })
+
+	var neverReady *NeverReadyError
+	if !errors.Is(err, ErrWorkerNeverReady) || !errors.As(err, &neverReady) {
+		t.Fatalf("expected a NeverReadyError, got %v", err)
+	}
+	if neverReady.Probes < 2 || neverReady.Last.Error() != "connection refused" {
+		t.Errorf("unexpected error details: %+v", neverReady)
+	}
+}
+
+func TestReadinessProbeCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := DefaultReadinessProbe().Wait(ctx, func(context.Context) error { return errors.New("loading") })
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrWorkerNeverReady) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("cancel took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,252 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRemoteHealthInterval is how often a remote server's health is checked once it is up
+const DefaultRemoteHealthInterval = 15 * time.Second
+
+// RemoteOptions configure how RemoteEngine reaches its server
+type RemoteOptions struct {
+	// Header is set on every request, replacing what the client sent; for instance an
+	// Authorization header carrying the server's API key
+	Header http.Header
+	// TLS configures HTTPS connections: CAs, client certificates, verification
+	TLS *tls.Config
+	// HealthPath is checked for health; empty tries /health, then /v1/models for servers
+	// without it, such as Ollama
+	HealthPath     string
+	HealthInterval time.Duration
+}
+
+// RemoteEngine serves requests from an inference server it does not manage, such as vLLM,
+// Ollama or llama-server on another machine. It has no process to start or stop: Start
+// waits for the server to answer its health check, which is then repeated in the
+// background so fallbacks skip the server while it is unreachable.
+type RemoteEngine struct {
+	BaseURL *url.URL
+	RemoteOptions
+	HTTPClient *http.Client
+	Readiness  ReadinessProbe
+
+	proxy *httputil.ReverseProxy
+
+	mu      sync.RWMutex
+	cancel  context.CancelFunc
+	status  WorkerStatus
+	healthy bool
+}
+
+// NewRemoteEngine proxies to the server at baseURL; a path in it prefixes every request
+func NewRemoteEngine(baseURL string, opts RemoteOptions) (*RemoteEngine, error) {
+	target, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("remote url %q: %w", baseURL, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" || target.Host == "" {
+		return nil, fmt.Errorf("remote url %q: want http(s)://host[:port]", baseURL)
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = DefaultRemoteHealthInterval
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = opts.TLS
+
+	r := &RemoteEngine{
+		BaseURL:       target,
+		RemoteOptions: opts,
+		HTTPClient:    &http.Client{Timeout: 5 * time.Second, Transport: transport},
+		Readiness:     DefaultReadinessProbe(),
+		status:        WorkerStatus{State: StateStopped},
+	}
+	r.proxy = NewStreamingProxy(target)
+	r.proxy.Transport = transport
+	direct := r.proxy.Director
+	r.proxy.Director = func(req *http.Request) {
+		direct(req)
+		// virtual hosts and TLS certificates are for the server's name, not the manager's
+		req.Host = target.Host
+		r.setHeader(req)
+	}
+	return r, nil
+}
+
+// setHeader injects the configured headers into req
+func (r *RemoteEngine) setHeader(req *http.Request) {
+	for name, values := range r.Header {
+		req.Header[http.CanonicalHeaderKey(name)] = values
+	}
+}
+
+// name tags the engine's log lines
+func (r *RemoteEngine) name() string {
+	return "remote:" + r.BaseURL.Host
+}
+
+func (r *RemoteEngine) Start(ctx context.Context) error {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.mu.Unlock()
+		return errors.New("remote engine already started")
+	}
+	monitorCtx, cancel := context.WithCancel(ctx)
+	r.cancel = cancel
+	r.status = WorkerStatus{State: StateStarting}
+	r.mu.Unlock()
+
+	slog.Info("waiting for remote server", "worker", r.name(), "url", r.BaseURL.Redacted())
+	err := r.Readiness.Wait(ctx, func(context.Context) error {
+		_, err := r.Health()
+		return err
+	})
+	if err != nil {
+		cancel()
+		r.mu.Lock()
+		r.cancel = nil
+		r.status.State = StateFailed
+		r.mu.Unlock()
+		return fmt.Errorf("remote server %s: %w", r.BaseURL.Redacted(), err)
+	}
+	slog.Info("remote server ready", "worker", r.name())
+	r.mu.Lock()
+	r.status.State = StateRunning
+	r.status.StartedAt = time.Now()
+	r.healthy = true
+	r.mu.Unlock()
+
+	go r.monitor(monitorCtx)
+	return nil
+}
+
+// monitor re-checks the server's health every HealthInterval until ctx ends, logging
+// when it becomes unreachable and when it is back
+func (r *RemoteEngine) monitor(ctx context.Context) {
+	ticker := time.NewTicker(r.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		_, err := r.Health()
+		r.mu.Lock()
+		was := r.healthy
+		r.healthy = err == nil
+		if err != nil {
+			r.status.State = StateFailed
+			r.status.LastExit = "unreachable: " + err.Error()
+			r.status.LastExitAt = time.Now()
+		} else {
+			r.status.State = StateRunning
+		}
+		r.mu.Unlock()
+		switch {
+		case was && err != nil:
+			slog.Warn("remote server unreachable", "worker", r.name(), "err", err)
+		case !was && err == nil:
+			slog.Info("remote server reachable again", "worker", r.name())
+		}
+	}
+}
+
+// Health asks the server. Servers with a JSON /health, like the Python worker, report
+// themselves; otherwise an OK answer counts as healthy with the model loaded, and
+// /v1/models names the model.
+func (r *RemoteEngine) Health() (*WorkerHealth, error) {
+	paths := []string{r.HealthPath}
+	if r.HealthPath == "" {
+		paths = []string{"/health", "/v1/models"}
+	}
+	var err error
+	for _, path := range paths {
+		var health *WorkerHealth
+		var notFound bool
+		if health, notFound, err = r.check(path); !notFound {
+			return health, err
+		}
+	}
+	return nil, err
+}
+
+// check requests path from the server; notFound reports a 404, so another path may be tried
+func (r *RemoteEngine) check(path string) (health *WorkerHealth, notFound bool, err error) {
+	target := r.BaseURL.JoinPath(path)
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	r.setHeader(req)
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode == http.StatusNotFound, fmt.Errorf("remote %s returned status %d", path, resp.StatusCode)
+	}
+
+	var body struct {
+		WorkerHealth
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&body)
+	health = &body.WorkerHealth
+	if health.Status == "" {
+		health.Status, health.ModelLoaded = "ok", true
+	}
+	if health.Model == "" && len(body.Data) > 0 {
+		ids := make([]string, len(body.Data))
+		for i, model := range body.Data {
+			ids[i] = model.ID
+		}
+		health.Model = strings.Join(ids, ",")
+	}
+	return health, false, nil
+}
+
+// Available reports whether the server answered its last health check
+func (r *RemoteEngine) Available() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cancel != nil && r.healthy
+}
+
+// Status reports running while the server answers its health checks, and failed while it
+// does not
+func (r *RemoteEngine) Status() WorkerStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.status
+}
+
+func (r *RemoteEngine) ProxyRequest(w http.ResponseWriter, req *http.Request) {
+	ServeStreaming(r.proxy, w, req)
+}
+
+// Stop ends the health checks; the server itself keeps running
+func (r *RemoteEngine) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	r.healthy = false
+	r.status.State = StateStopped
+	return nil
+}
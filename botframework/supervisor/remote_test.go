@@ -0,0 +1,107 @@
+package supervisor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteEngineProxiesOverTLSWithInjectedHeader(t *testing.T) {
+	var seenAuth, seenPath atomic.Value
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer remote-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/ollama/v1/models":
+			w.Write([]byte(`{"data":[{"id":"llama3"}]}`))
+		case "/ollama/v1/chat/completions":
+			seenAuth.Store(r.Header.Get("Authorization"))
+			seenPath.Store(r.URL.Path)
+			w.Write([]byte(`{"choices":[]}`))
+		default:
+			// Ollama has no /health
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	remote, err := NewRemoteEngine(server.URL+"/ollama", RemoteOptions{
+		Header: http.Header{"Authorization": {"Bearer remote-key"}},
+		TLS:    &tls.Config{RootCAs: roots},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.Readiness = ReadinessProbe{InitialInterval: 10 * time.Millisecond, MaxInterval: 10 * time.Millisecond, Multiplier: 1, Deadline: time.Second}
+	if err := remote.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer remote.Stop()
+
+	health, err := remote.Health()
+	if err != nil || health.Model != "llama3" || !health.ModelLoaded {
+		t.Fatalf("Health() = %+v, %v; want the model from /v1/models", health, err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`))
+	req.Header.Set("Authorization", "Bearer manager-key")
+	rec := httptest.NewRecorder()
+	remote.ProxyRequest(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("proxied status %d", rec.Code)
+	}
+	if seenAuth.Load() != "Bearer remote-key" || seenPath.Load() != "/ollama/v1/chat/completions" {
+		t.Errorf("server saw %v at %v", seenAuth.Load(), seenPath.Load())
+	}
+}
+
+func TestRemoteEngineBecomesUnavailableWhenUnreachable(t *testing.T) {
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"ok","model_loaded":true,"model":"phi-3"}`))
+	}))
+	defer server.Close()
+
+	remote, err := NewRemoteEngine(server.URL, RemoteOptions{HealthInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer remote.Stop()
+	if !remote.Available() || remote.Status().State != StateRunning {
+		t.Fatalf("started remote should be available: %+v", remote.Status())
+	}
+
+	down.Store(true)
+	deadline := time.Now().Add(time.Second)
+	for remote.Available() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if status := remote.Status(); remote.Available() || status.State != StateFailed || !strings.Contains(status.LastExit, "503") {
+		t.Fatalf("unreachable remote: available %v, status %+v", remote.Available(), status)
+	}
+}
+
+func TestNewRemoteEngineRejectsBadURLs(t *testing.T) {
+	for _, u := range []string{"gpu-box:8000", "ftp://gpu-box", "http://"} {
+		if _, err := NewRemoteEngine(u, RemoteOptions{}); err == nil {
+			t.Errorf("%q should be rejected", u)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package supervisor
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// RestartPolicy decides whether a worker process that exits on its own is started again
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartOnFailure RestartPolicy = "on-failure" // restart after non-zero exits and crashes
+	RestartAlways    RestartPolicy = "always"
+)
+
+// ParseRestartPolicy validates a policy name
+func ParseRestartPolicy(s string) (RestartPolicy, error) {
+	switch policy := RestartPolicy(s); policy {
+	case RestartNever, RestartOnFailure, RestartAlways:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown restart policy %q (want never, on-failure or always)", s)
+}
+
+func (p RestartPolicy) restarts(exitErr error) bool {
+	switch p {
+	case RestartAlways:
+		return true
+	case RestartNever:
+		return false
+	default:
+		return exitErr != nil
+	}
+}
+
+// WorkerState is the lifecycle state of a supervised worker
+type WorkerState string
+
+const (
+	StateStarting   WorkerState = "starting"
+	StateRunning    WorkerState = "running"
+	StateRestarting WorkerState = "restarting"
+	StateStopped    WorkerState = "stopped"
+	// StateFailed means the worker could not start or the restart circuit breaker opened
+	StateFailed WorkerState = "failed"
+)
+
+// WorkerStatus is a snapshot of a worker's lifecycle
+type WorkerStatus struct {
+	State      WorkerState `json:"state"`
+	PID        int         `json:"pid,omitempty"`
+	Port       string      `json:"port,omitempty"` // comma-separated for pools
+	Restarts   int         `json:"restarts"`
+	StartedAt  time.Time   `json:"started_at,omitzero"`
+	LastExit   string      `json:"last_exit,omitempty"`
+	LastExitAt time.Time   `json:"last_exit_at,omitzero"`
+	// Liveness is the worker's health state, kept up by its liveness probe
+	Liveness LivenessStatus `json:"liveness,omitzero"`
+	// Warmup is how the warmup prompts sent to the current process once it was ready went
+	Warmup WarmupStatus `json:"warmup,omitzero"`
+	// LogStats is what the worker's output said: load time, throughput and failures
+	LogStats
+}
+
+// RestartConfig tunes how a worker is restarted after it exits
+type RestartConfig struct {
+	Policy RestartPolicy
+	// MaxRestarts is how many consecutive restarts are attempted before giving up
+	MaxRestarts int
+	// Backoff is the delay before the first restart, doubled for each consecutive one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// StableAfter is the uptime after which a worker's consecutive restart count resets
+	StableAfter time.Duration
+}
+
+// DefaultRestartConfig restarts failed workers up to 5 times in a row. BOTFRAMEWORK_RESTART_POLICY
+// and BOTFRAMEWORK_MAX_RESTARTS override the policy and the limit.
+func DefaultRestartConfig() RestartConfig {
+	config := RestartConfig{
+		Policy:      RestartOnFailure,
+		MaxRestarts: 5,
+		Backoff:     time.Second,
+		MaxBackoff:  30 * time.Second,
+		StableAfter: time.Minute,
+	}
+	if name := os.Getenv("BOTFRAMEWORK_RESTART_POLICY"); name != "" {
+		if policy, err := ParseRestartPolicy(name); err == nil {
+			config.Policy = policy
+		} else {
+			slog.Warn("invalid restart policy", "err", err)
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_MAX_RESTARTS")); err == nil && n >= 0 {
+		config.MaxRestarts = n
+	}
+	return config
+}
+
+// delay returns the backoff before consecutive restart number attempt (0-based)
+func (c RestartConfig) delay(attempt int) time.Duration {
+	d := c.Backoff
+	for i := 0; i < attempt && d < c.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, c.MaxBackoff)
+}
@@ -0,0 +1,184 @@
+package supervisor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRestartDelay(t *testing.T) {
+	config := RestartConfig{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for attempt, d := range want {
+		if got := config.delay(attempt); got != d {
+			t.Errorf("delay(%d) = %s, want %s", attempt, got, d)
+		}
+	}
+}
+
+// exitingWorker runs a shell "worker" that passes its health check and then exits with code
+func exitingWorker(t *testing.T, code string, policy RestartPolicy) *PythonWorker {
+	t.Helper()
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("needs /bin/sh")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	t.Cleanup(ts.Close)
+
+	script := filepath.Join(t.TempDir(), "worker.sh")
+	if err := os.WriteFile(script, []byte("sleep 0.05; exit "+code+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BOTFRAMEWORK_PYTHON", "/bin/sh")
+
+	worker := NewPythonWorker(script, extractPort(t, ts.URL))
+	worker.HTTPClient = ts.Client()
+	worker.Restart = RestartConfig{Policy: policy, MaxRestarts: 2, Backoff: 10 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, StableAfter: time.Minute}
+	t.Cleanup(func() { worker.Stop() })
+	return worker
+}
+
+func waitForState(t *testing.T, worker *PythonWorker, want WorkerState) WorkerStatus {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if status := worker.Status(); status.State == want {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("worker never reached %s: %+v", want, worker.Status())
+	return WorkerStatus{}
+}
+
+func TestRestartCircuitBreaker(t *testing.T) {
+	worker := exitingWorker(t, "1", RestartOnFailure)
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	status := waitForState(t, worker, StateFailed)
+	if status.Restarts != 2 || status.LastExit == "" {
+		t.Errorf("expected two restarts before giving up: %+v", status)
+	}
+	if worker.Available() {
+		t.Error("failed worker should not be available")
+	}
+}
+
+func TestRestartPolicies(t *testing.T) {
+	cases := []struct {
+		code     string
+		policy   RestartPolicy
+		restarts bool
+	}{
+		{"1", RestartNever, false},
+		{"0", RestartOnFailure, false},
+		{"0", RestartAlways, true},
+	}
+	for _, c := range cases {
+		worker := exitingWorker(t, c.code, c.policy)
+		if err := worker.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		want := StateStopped
+		if c.restarts {
+			want = StateFailed
+		}
+		if status := waitForState(t, worker, want); (status.Restarts > 0) != c.restarts {
+			t.Errorf("exit %s under %s: %+v", c.code, c.policy, status)
+		}
+	}
+}
+
+func TestParseRestartPolicy(t *testing.T) {
+	if policy, err := ParseRestartPolicy("always"); err != nil || policy != RestartAlways {
+		t.Errorf("got %q, %v", policy, err)
+	}
+	if _, err := ParseRestartPolicy("sometimes"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+// runningWorker starts a shell "worker" that runs script and stays up until signalled
+func runningWorker(t *testing.T, script string) *PythonWorker {
+	t.Helper()
+	worker := exitingWorker(t, "0", RestartNever)
+	if err := os.WriteFile(worker.ScriptPath, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	worker.StopGrace = 300 * time.Millisecond
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return worker
+}
+
+func TestStopSendsSIGTERM(t *testing.T) {
+	worker := runningWorker(t, "exec sleep 10\n")
+	start := time.Now()
+	if err := worker.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= worker.StopGrace {
+		t.Errorf("Stop took %s; the worker should have exited on SIGTERM", elapsed)
+	}
+}
+
+func TestStopKillsAfterGrace(t *testing.T) {
+	// the health check passes at once, so wait until the shell has installed its trap
+	trapped := filepath.Join(t.TempDir(), "trapped")
+	worker := runningWorker(t, "trap '' TERM\ntouch "+trapped+"\nexec sleep 10\n")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(trapped); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("worker script never ran")
+		}
+	}
+	start := time.Now()
+	if err := worker.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	elapsed := time.Since(start)
+	if elapsed < worker.StopGrace || elapsed > 5*time.Second {
+		t.Errorf("Stop took %s, want the %s grace period before SIGKILL", elapsed, worker.StopGrace)
+	}
+	// the monitor clears the PID just after it observes the exit
+	for deadline := time.Now().Add(time.Second); worker.Status().PID != 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status := worker.Status(); status.State != StateStopped || status.PID != 0 {
+		t.Errorf("status after Stop = %+v", status)
+	}
+}
+
+func TestRelaunchReplacesProcessAndKeepsLogs(t *testing.T) {
+	worker := runningWorker(t, "echo started\nexec sleep 10\n")
+	defer worker.Stop()
+	first := worker.Status().PID
+	// the health check passes at once, so wait until the first process has printed
+	for deadline := time.Now().Add(2 * time.Second); len(worker.Logs(0)) < 1 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := worker.Relaunch(); err != nil {
+		t.Fatalf("Relaunch: %v", err)
+	}
+	// give the old process's monitor time to observe its exit
+	time.Sleep(50 * time.Millisecond)
+	status := worker.Status()
+	if status.State != StateRunning || status.PID == 0 || status.PID == first || status.Restarts != 1 {
+		t.Errorf("status after Relaunch = %+v (first PID %d)", status, first)
+	}
+	for deadline := time.Now().Add(2 * time.Second); len(worker.Logs(0)) < 2 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if logs := worker.Logs(0); len(logs) != 2 || logs[1] != "started" {
+		t.Errorf("logs = %q", logs)
+	}
+}
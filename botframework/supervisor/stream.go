@@ -0,0 +1,94 @@
+package supervisor
+
+import (
+	"botframework/openai"
+	"context"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// StreamFlushInterval bounds how long output of responses that are not event streams, such
+// as chunked JSON lines, may sit in the proxy's buffer
+const StreamFlushInterval = 100 * time.Millisecond
+
+// NewStreamingProxy returns a reverse proxy to target that passes generated tokens on as soon
+// as the worker emits them. Event streams are flushed after every chunk (see ServeStreaming)
+// and marked so intermediaries such as nginx do not buffer or cache them. Requests whose
+// deadline passes before the worker answers get a 504; abandoned ones get nothing.
+func NewStreamingProxy(target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.FlushInterval = StreamFlushInterval
+	proxy.ModifyResponse = func(resp *http.Response) error {
+		if IsEventStream(resp.Header) {
+			resp.Header.Del("Content-Length")
+			resp.Header.Set("Cache-Control", "no-cache")
+			resp.Header.Set("X-Accel-Buffering", "no")
+		}
+		return nil
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		switch {
+		case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+			openai.WriteError(w, http.StatusGatewayTimeout, "server_error", "", "request timed out")
+		case r.Context().Err() != nil:
+			// the client disconnected; nobody is left to answer
+		default:
+			slog.Warn("worker request failed", "target", target.Host, "path", r.URL.Path, "err", err)
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}
+	return proxy
+}
+
+// IsEventStream reports whether the headers describe a Server-Sent Events response
+func IsEventStream(h http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// ServeStreaming proxies r, flushing every chunk of an event-stream response through the
+// middleware between the proxy and the client connection
+func ServeStreaming(proxy *httputil.ReverseProxy, w http.ResponseWriter, r *http.Request) {
+	proxy.ServeHTTP(&streamWriter{ResponseWriter: w}, r)
+}
+
+// streamWriter flushes after each write once the response turns out to be an event stream
+type streamWriter struct {
+	http.ResponseWriter
+	decided bool
+	stream  bool
+}
+
+func (s *streamWriter) WriteHeader(code int) {
+	if !s.decided {
+		s.decided = true
+		s.stream = IsEventStream(s.Header())
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *streamWriter) Write(b []byte) (int, error) {
+	if !s.decided {
+		s.WriteHeader(http.StatusOK)
+	}
+	n, err := s.ResponseWriter.Write(b)
+	if s.stream && err == nil {
+		s.Flush()
+	}
+	return n, err
+}
+
+func (s *streamWriter) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *streamWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
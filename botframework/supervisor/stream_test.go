@@ -0,0 +1,108 @@
+package supervisor
+
+import (
+	"botframework/logging"
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestStreamingProxyDeliversEventsAsGenerated(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		w.Write([]byte("data: {\"token\":\"Hel\"}\n\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	target, _ := url.Parse(backend.URL)
+	proxy := NewStreamingProxy(target)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeStreaming(proxy, w, r)
+	}))
+	defer front.Close()
+
+	resp, err := http.Get(front.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("X-Accel-Buffering") != "no" || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatalf("expected anti-buffering headers, got %v", resp.Header)
+	}
+
+	line := make(chan string, 1)
+	go func() {
+		text, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		line <- text
+	}()
+	select {
+	case got := <-line:
+		if got != "data: {\"token\":\"Hel\"}\n" {
+			t.Fatalf("unexpected first line %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("first event was buffered until the stream ended")
+	}
+}
+
+func TestProxyRequestAbortsTimedOutGeneration(t *testing.T) {
+	aborted := make(chan string, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/abort" {
+			var body struct {
+				RequestID string `json:"request_id"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			aborted <- body.RequestID
+			return
+		}
+		// a generation still prefilling: nothing is written until the client goes away
+		<-r.Context().Done()
+	}))
+	defer backend.Close()
+
+	target, _ := url.Parse(backend.URL)
+	worker := NewPythonWorker("", target.Port())
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(ctx)
+	req.Header.Set(logging.RequestIDHeader, "req-42")
+	rr := httptest.NewRecorder()
+	worker.ProxyRequest(rr, req)
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status %d, want 504", rr.Code)
+	}
+	select {
+	case id := <-aborted:
+		if id != "req-42" {
+			t.Fatalf("aborted %q, want req-42", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the worker was not told to abort")
+	}
+}
+
+func TestIsEventStream(t *testing.T) {
+	for contentType, want := range map[string]bool{
+		"text/event-stream":                true,
+		"text/event-stream; charset=utf-8": true,
+		"application/json":                 false,
+		"":                                 false,
+	} {
+		h := http.Header{"Content-Type": {contentType}}
+		if got := IsEventStream(h); got != want {
+			t.Errorf("%q: want %v, got %v", contentType, want, got)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package supervisor
 
 import (
+	"botframework/logging"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -13,7 +17,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
@@ -25,18 +33,91 @@ type WorkerHealth struct {
 }
 
 type PythonWorker struct {
+	// Name tags the worker's log lines; empty uses "worker:<port>"
+	Name       string
 	ScriptPath string
 	Port       string
+	ModelPath  string
+	// Mode is ModeEmbedding for a worker serving /v1/embeddings; empty serves chat
+	Mode       string
+	Env        []string // extra KEY=VALUE entries for the worker process
+	Args       []string // extra arguments for the worker script, e.g. --n-ctx 4096
 	Process    *exec.Cmd
 	Proxy      *httputil.ReverseProxy
 	HTTPClient *http.Client
+	Readiness  ReadinessProbe
+	Restart    RestartConfig
+	// StopGrace is how long Stop waits after SIGTERM before killing the worker
+	StopGrace time.Duration
+	// GPUs are the indexes of the only GPUs the worker sees, through CUDA_VISIBLE_DEVICES
+	// and HIP_VISIBLE_DEVICES; nil leaves every GPU visible
+	GPUs []int
+	// Command builds the worker process; nil runs ScriptPath with Python
+	Command func(ctx context.Context) (*exec.Cmd, error)
+	// HealthCheck is the readiness check; nil polls the worker's HTTP /health endpoint
+	HealthCheck func(ctx context.Context) error
+	// Liveness probes the worker with a generation once it is ready, and LivenessCheck is
+	// the probe; nil asks the OpenAI API for one token
+	Liveness      LivenessProbe
+	LivenessCheck func(ctx context.Context) error
+	// Warmup is sent once the worker is ready, before it is marked running, and
+	// WarmupRequest sends one of its prompts; nil uses the OpenAI API
+	Warmup        Warmup
+	WarmupRequest func(ctx context.Context, prompt string) error
+	// AbortPath is the worker endpoint told the X-Request-ID of a request abandoned
+	// mid-generation, so it stops generating; empty for workers that stop on disconnect
+	AbortPath string
+	// Acquire runs as Start launches the worker and Release once Stop or a failed Start
+	// has ended it, so a resource such as a MIG slice is held only while the worker is up.
+	// Acquire may change Env; restarts after a crash keep what it acquired.
+	Acquire func() error
+	Release func()
+
+	logs *logging.Tail // recent output, across restarts
+	scan *LogScanner   // events in the output: model loads, OOMs, throughput
+
+	// busy counts the requests being proxied and served every request ever proxied, so
+	// liveness probes leave a worker alone while it serves
+	busy   atomic.Int64
+	served atomic.Uint64
+
+	mu       sync.RWMutex
+	parent   context.Context // what Start was called with, reused by Relaunch
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping bool
+	status   WorkerStatus
+	exit     *processExit // the current process's exit
+}
+
+// processExit is closed once the process it belongs to has been waited for
+type processExit struct {
+	done chan struct{}
+	err  error
+}
 
-	mu          sync.RWMutex
-	ctx         context.Context
-	cancel      context.CancelFunc
-	stopping    bool
-	restarting  bool
-	maxRestarts int
+// failure describes why the process exited, once done is closed
+func (e *processExit) failure() error {
+	if e.err != nil {
+		return fmt.Errorf("worker exited: %w", e.err)
+	}
+	return errors.New("worker exited")
+}
+
+// ModeEmbedding loads the model for embeddings rather than text generation
+const ModeEmbedding = "embedding"
+
+// LogLines is how many lines of output each worker keeps for Logs
+const LogLines = 500
+
+// DefaultStopGrace is used unless BOTFRAMEWORK_WORKER_STOP_TIMEOUT is set
+const DefaultStopGrace = 10 * time.Second
+
+func defaultStopGrace() time.Duration {
+	if timeout, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_WORKER_STOP_TIMEOUT")); err == nil && timeout > 0 {
+		return timeout
+	}
+	return DefaultStopGrace
 }
 
 func NewPythonWorker(scriptPath, port string) *PythonWorker {
@@ -46,12 +127,42 @@ func NewPythonWorker(scriptPath, port string) *PythonWorker {
 	}
 
 	return &PythonWorker{
-		ScriptPath:  scriptPath,
-		Port:        port,
-		Proxy:       httputil.NewSingleHostReverseProxy(targetURL),
-		HTTPClient:  &http.Client{Timeout: 2 * time.Second},
-		maxRestarts: 3,
+		ScriptPath: scriptPath,
+		Port:       port,
+		Proxy:      NewStreamingProxy(targetURL),
+		HTTPClient: &http.Client{Timeout: 2 * time.Second},
+		Readiness:  DefaultReadinessProbe(),
+		Liveness:   DefaultLivenessProbe(),
+		Warmup:     DefaultWarmup(),
+		Restart:    DefaultRestartConfig(),
+		StopGrace:  defaultStopGrace(),
+		AbortPath:  "/abort",
+		logs:       logging.NewTail(LogLines),
+		scan:       NewLogScanner(),
+		status:     WorkerStatus{State: StateStopped},
+	}
+}
+
+// VisibleDevices limits a process to the given GPUs. Both variables are set, as only the
+// GPU runtime in use reads its own; nil devices set neither.
+func VisibleDevices(devices []int) []string {
+	if devices == nil {
+		return nil
+	}
+	list := make([]string, len(devices))
+	for i, device := range devices {
+		list[i] = strconv.Itoa(device)
+	}
+	joined := strings.Join(list, ",")
+	return []string{"CUDA_VISIBLE_DEVICES=" + joined, "HIP_VISIBLE_DEVICES=" + joined}
+}
+
+// name is the worker's log tag
+func (p *PythonWorker) name() string {
+	if p.Name != "" {
+		return p.Name
 	}
+	return "worker:" + p.Port
 }
 
 func resolveProjectRoot() string {
@@ -69,12 +180,21 @@ func (p *PythonWorker) Start(ctx context.Context) error {
 		p.mu.Unlock()
 		return errors.New("worker already started")
 	}
+	p.parent = ctx
 	p.ctx, p.cancel = context.WithCancel(ctx)
 	p.stopping = false
-	p.restarting = false
+	p.status = WorkerStatus{State: StateStarting}
 	p.mu.Unlock()
 
+	if p.Acquire != nil {
+		if err := p.Acquire(); err != nil {
+			p.setState(StateFailed)
+			return err
+		}
+	}
 	if err := p.startProcess(); err != nil {
+		p.setState(StateFailed)
+		p.release()
 		return err
 	}
 
@@ -83,57 +203,115 @@ func (p *PythonWorker) Start(ctx context.Context) error {
 }
 
 func (p *PythonWorker) startProcess() error {
-	fmt.Printf("🚀 Starting Python Engine: %s on port %s\n", p.ScriptPath, p.Port)
-
 	p.mu.RLock()
 	ctx := p.ctx
 	p.mu.RUnlock()
 
-	if configuredPython := os.Getenv("BOTFRAMEWORK_PYTHON"); configuredPython != "" {
-		fmt.Printf("🐍 Using BOTFRAMEWORK_PYTHON=%s\n", configuredPython)
-		p.Process = exec.CommandContext(ctx, configuredPython, p.ScriptPath, "--port", p.Port)
-	} else if _, err := exec.LookPath("pipenv"); err == nil {
-		fmt.Println("🐍 Using pipenv-managed Python environment")
-		p.Process = exec.CommandContext(ctx, "pipenv", "run", "python", p.ScriptPath, "--port", p.Port)
-	} else {
-		fmt.Println("🐍 Using system python3")
-		p.Process = exec.CommandContext(ctx, "python3", p.ScriptPath, "--port", p.Port)
+	command := p.Command
+	if command == nil {
+		command = p.pythonCommand
 	}
-	p.Process.Dir = resolveProjectRoot()
-	p.Process.Stdout = os.Stdout
-	p.Process.Stderr = os.Stderr
+	process, err := command(ctx)
+	if err != nil {
+		return err
+	}
+	if env := slices.Concat(p.Env, VisibleDevices(p.GPUs)); len(env) > 0 {
+		process.Env = append(os.Environ(), env...)
+	}
+	process.Stdout = p.output("stdout")
+	process.Stderr = p.output("stderr")
+	// Cancelling the worker's context stops it as gracefully as Stop does
+	process.Cancel = func() error { return process.Process.Signal(syscall.SIGTERM) }
+	process.WaitDelay = p.StopGrace
+	detach(process)
 
-	if err := p.Process.Start(); err != nil {
-		return fmt.Errorf("failed to start python process: %w", err)
+	p.mu.Lock()
+	p.Process = process
+	p.status.Liveness = LivenessStatus{State: HealthStarting}
+	p.mu.Unlock()
+	p.scan.Restart()
+	if err := process.Start(); err != nil {
+		return fmt.Errorf("failed to start worker process: %w", err)
 	}
+	exit := &processExit{done: make(chan struct{})}
+	go func() {
+		exit.err = process.Wait()
+		close(exit.done)
+	}()
+	p.mu.Lock()
+	p.status.PID = process.Process.Pid
+	p.exit = exit
+	p.mu.Unlock()
 
-	fmt.Println("⏳ Waiting for worker to initialize...")
-	if err := p.waitForHealthy(30 * time.Second); err != nil {
-		_ = p.Process.Process.Kill()
+	check := p.HealthCheck
+	if check == nil {
+		check = p.checkHealth
+	}
+	slog.Info("waiting for worker to initialize", "worker", p.name())
+	if err := p.Readiness.waitProcess(ctx, scannedCheck(p.scan, check), p.scan.Ready(), exit); err != nil {
+		_ = process.Process.Kill()
+		<-exit.done
 		return err
 	}
-	fmt.Println("✅ Worker is ready!")
+	slog.Info("worker ready", "worker", p.name(), "pid", process.Process.Pid)
+	var warmup WarmupStatus
+	if len(p.Warmup.Prompts) > 0 {
+		warmup = p.warmUp(ctx)
+	}
 	p.mu.Lock()
-	p.restarting = false
+	p.status.State = StateRunning
+	p.status.StartedAt = time.Now()
+	p.status.Liveness = LivenessStatus{State: HealthHealthy}
+	p.status.Warmup = warmup
 	p.mu.Unlock()
+	if p.Liveness.Interval > 0 {
+		go p.watchLiveness(ctx, exit)
+	}
 
 	return nil
 }
 
-func (p *PythonWorker) waitForHealthy(timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
-		if err := p.checkHealth(); err == nil {
-			return nil
-		}
-		time.Sleep(500 * time.Millisecond)
+// pythonCommand runs the FastAPI worker script from the project root
+func (p *PythonWorker) pythonCommand(ctx context.Context) (*exec.Cmd, error) {
+	var process *exec.Cmd
+	args := []string{p.ScriptPath, "--port", p.Port}
+	if p.ModelPath != "" {
+		args = append(args, "--model-path", p.ModelPath)
 	}
+	if p.Mode != "" {
+		args = append(args, "--mode", p.Mode)
+	}
+	args = append(args, p.Args...)
 
-	return fmt.Errorf("worker failed health check within %s", timeout)
+	if configuredPython := p.python(); configuredPython != "" {
+		process = exec.CommandContext(ctx, configuredPython, args...)
+	} else if _, err := exec.LookPath("pipenv"); err == nil {
+		process = exec.CommandContext(ctx, "pipenv", append([]string{"run", "python"}, args...)...)
+	} else {
+		process = exec.CommandContext(ctx, "python3", args...)
+	}
+	slog.Info("starting Python worker", "worker", p.name(), "script", p.ScriptPath, "port", p.Port, "python", process.Args[0])
+	process.Dir = resolveProjectRoot()
+	return process, nil
 }
 
-func (p *PythonWorker) checkHealth() error {
-	resp, err := p.HTTPClient.Get(fmt.Sprintf("http://127.0.0.1:%s/health", p.Port))
+// python is the interpreter BOTFRAMEWORK_PYTHON names in the worker's Env, else in the
+// manager's environment
+func (p *PythonWorker) python() string {
+	for _, entry := range slices.Backward(p.Env) {
+		if python, ok := strings.CutPrefix(entry, "BOTFRAMEWORK_PYTHON="); ok {
+			return python
+		}
+	}
+	return os.Getenv("BOTFRAMEWORK_PYTHON")
+}
+
+func (p *PythonWorker) checkHealth(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://127.0.0.1:%s/health", p.Port), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.HTTPClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -146,53 +324,147 @@ func (p *PythonWorker) checkHealth() error {
 	return nil
 }
 
+// monitorProcess waits on the worker process and restarts it according to the restart
+// policy. After Restart.MaxRestarts consecutive restarts the circuit breaker opens and the
+// worker stays failed; a worker that stays up for Restart.StableAfter resets the count.
 func (p *PythonWorker) monitorProcess() {
-	for attempt := 0; ; attempt++ {
+	consecutive := 0
+	for {
 		p.mu.RLock()
-		process := p.Process
-		stopping := p.stopping
+		exit := p.exit
+		ctx := p.ctx
 		p.mu.RUnlock()
-
-		if process == nil || stopping {
+		if exit == nil {
 			return
 		}
 
-		err := process.Wait()
-
-		p.mu.RLock()
-		stopping = p.stopping
-		ctxErr := p.ctx.Err()
-		p.mu.RUnlock()
+		<-exit.done
+		err := exit.err
 
-		if stopping || ctxErr != nil {
+		p.mu.Lock()
+		if p.exit != exit {
+			// Relaunch already replaced the process this monitor watched
+			p.mu.Unlock()
 			return
 		}
-
-		log.Printf("worker exited unexpectedly: %v", err)
-		if attempt >= p.maxRestarts {
-			log.Printf("worker restart limit reached (%d attempts)", p.maxRestarts)
+		stopping := p.stopping || ctx.Err() != nil
+		uptime := time.Duration(0)
+		if !p.status.StartedAt.IsZero() {
+			uptime = time.Since(p.status.StartedAt)
+		}
+		p.status.PID = 0
+		p.status.LastExitAt = time.Now()
+		p.status.LastExit = "exited cleanly"
+		if err != nil {
+			p.status.LastExit = err.Error()
+		}
+		if stopping {
+			p.status.State = StateStopped
+		}
+		p.mu.Unlock()
+		if stopping {
 			return
 		}
 
-		backoff := time.Duration(1<<attempt) * time.Second
-		log.Printf("restarting worker in %s (attempt %d/%d)", backoff, attempt+1, p.maxRestarts)
-		time.Sleep(backoff)
+		if failure := p.scan.Failure(); failure != nil {
+			slog.Warn("worker exited unexpectedly", "worker", p.name(), "err", err, "failure", failure)
+		} else {
+			slog.Warn("worker exited unexpectedly", "worker", p.name(), "err", err)
+		}
+		if !p.Restart.Policy.restarts(err) {
+			slog.Info("leaving worker stopped", "worker", p.name(), "policy", p.Restart.Policy)
+			p.setState(StateStopped)
+			return
+		}
+		if uptime >= p.Restart.StableAfter {
+			consecutive = 0
+		}
+		if consecutive >= p.Restart.MaxRestarts {
+			slog.Error("worker restart limit reached; giving up", "worker", p.name(), "restarts", p.Restart.MaxRestarts)
+			p.setState(StateFailed)
+			return
+		}
 
+		backoff := p.Restart.delay(consecutive)
+		consecutive++
 		p.mu.Lock()
-		p.restarting = true
+		p.status.State = StateRestarting
+		p.status.StartedAt = time.Time{}
+		p.status.Restarts++
 		p.mu.Unlock()
+		slog.Info("restarting worker", "worker", p.name(), "backoff", backoff, "attempt", consecutive, "max_restarts", p.Restart.MaxRestarts)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			p.setState(StateStopped)
+			return
+		}
 
 		if err := p.startProcess(); err != nil {
-			log.Printf("worker restart failed: %v", err)
-			continue
+			slog.Error("worker restart failed", "worker", p.name(), "err", err)
 		}
-
-		attempt = -1
 	}
 }
 
+func (p *PythonWorker) setState(state WorkerState) {
+	p.mu.Lock()
+	p.status.State = state
+	p.mu.Unlock()
+}
+
+// Status reports the worker's lifecycle state and restart history
+func (p *PythonWorker) Status() WorkerStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	status := p.status
+	status.Port = p.Port
+	status.LogStats = p.scan.Stats()
+	return status
+}
+
+// Available reports whether the worker is running and not in the middle of a restart
+func (p *PythonWorker) Available() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cancel != nil && !p.stopping && p.status.State == StateRunning
+}
+
+// ProxyRequest proxies r to the worker. When the client disconnects or the request's
+// deadline passes first, the worker is told to abort it: the closed connection alone does
+// not stop a generation that is not writing to it yet.
 func (p *PythonWorker) ProxyRequest(w http.ResponseWriter, r *http.Request) {
-	p.Proxy.ServeHTTP(w, r)
+	defer p.track()()
+	// deferred, as the proxy panics with http.ErrAbortHandler when the client goes away
+	// mid-stream
+	defer func() {
+		if err := r.Context().Err(); err != nil && p.AbortPath != "" {
+			go p.abort(r.Header.Get(logging.RequestIDHeader), err)
+		}
+	}()
+	ServeStreaming(p.Proxy, w, r)
+}
+
+// track counts a request as being served until the returned func is called
+func (p *PythonWorker) track() func() {
+	p.served.Add(1)
+	p.busy.Add(1)
+	return func() { p.busy.Add(-1) }
+}
+
+// abort asks the worker to stop generating for the request with id
+func (p *PythonWorker) abort(id string, reason error) {
+	if id == "" {
+		return
+	}
+	body, _ := json.Marshal(map[string]string{"request_id": id})
+	endpoint := fmt.Sprintf("http://127.0.0.1:%s%s", p.Port, p.AbortPath)
+	resp, err := p.HTTPClient.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		slog.Debug("worker abort failed", "worker", p.name(), "request_id", id, "err", err)
+		return
+	}
+	resp.Body.Close()
+	slog.Info("aborted abandoned request", "worker", p.name(), "request_id", id, "reason", reason)
 }
 
 func (p *PythonWorker) Health() (*WorkerHealth, error) {
@@ -214,26 +486,112 @@ func (p *PythonWorker) Health() (*WorkerHealth, error) {
 	return &health, nil
 }
 
+// Stop sends the worker SIGTERM and kills it if it is still running after StopGrace
 func (p *PythonWorker) Stop() error {
 	p.mu.Lock()
 	p.stopping = true
+	p.status.State = StateStopped
 	process := p.Process
+	exit := p.exit
 	cancel := p.cancel
 	p.mu.Unlock()
 
+	var err error
+	if process != nil && process.Process != nil && exit != nil {
+		slog.Info("stopping worker", "worker", p.name())
+		err = p.terminate(process, exit)
+	}
+	// cancel only once the process is gone, so the context does not cut the grace period short
 	if cancel != nil {
 		cancel()
 	}
+	p.release()
+	return err
+}
+
+// release gives back what Acquire took, if anything
+func (p *PythonWorker) release() {
+	if p.Release != nil {
+		p.Release()
+	}
+}
+
+// Relaunch stops the worker, if it is running, and starts it again under the context it was
+// first started with; a stopped worker is started again
+func (p *PythonWorker) Relaunch() error {
+	p.mu.RLock()
+	parent := p.parent
+	restarts := p.status.Restarts
+	p.mu.RUnlock()
+	if parent == nil {
+		return errors.New("worker was never started")
+	}
+	if parent.Err() != nil {
+		return parent.Err()
+	}
+	if err := p.Stop(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.cancel = nil
+	p.mu.Unlock()
+	err := p.Start(parent)
+	p.mu.Lock()
+	p.status.Restarts = restarts + 1
+	p.mu.Unlock()
+	return err
+}
 
-	if process != nil && process.Process != nil {
-		fmt.Println("🛑 Stopping Python Engine...")
-		if err := process.Process.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
-			return process.Process.Kill()
+// restartConfig is how the worker is restarted, for a pool retrying a failed start
+func (p *PythonWorker) restartConfig() RestartConfig {
+	return p.Restart
+}
+
+// Logs returns up to the last n lines the worker printed, oldest first (all kept when n <= 0)
+func (p *PythonWorker) Logs(n int) []string {
+	return p.logs.Lines(n)
+}
+
+// output sends a stream of the worker's output to the log, to Logs and to the scanner
+func (p *PythonWorker) output(stream string) io.Writer {
+	writers := []io.Writer{logging.Writer(p.name(), stream)}
+	if p.logs != nil {
+		writers = append(writers, p.logs)
+	}
+	if p.scan != nil {
+		writers = append(writers, p.scan)
+	}
+	return io.MultiWriter(writers...)
+}
+
+func (p *PythonWorker) terminate(process *exec.Cmd, exit *processExit) error {
+	select {
+	case <-exit.done:
+		return nil
+	default:
+	}
+	if err := process.Process.Signal(syscall.SIGTERM); err != nil {
+		if errors.Is(err, os.ErrProcessDone) {
+			<-exit.done
+			return nil
 		}
-		_, err := process.Process.Wait()
-		if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		// no SIGTERM on this platform
+		if err := process.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+		<-exit.done
+		return nil
+	}
+
+	select {
+	case <-exit.done:
+	case <-time.After(p.StopGrace):
+		slog.Warn("worker still running after SIGTERM; killing it", "worker", p.name(), "grace", p.StopGrace)
+		if err := process.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
 			return err
 		}
+		<-exit.done
 	}
 	return nil
 }
@@ -1,6 +1,8 @@
 package supervisor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -30,7 +32,7 @@ func TestCheckHealthOK(t *testing.T) {
 	worker := NewPythonWorker("unused.py", extractPort(t, ts.URL))
 	worker.HTTPClient = ts.Client()
 
-	if err := worker.checkHealth(); err != nil {
+	if err := worker.checkHealth(context.Background()); err != nil {
 		t.Fatalf("expected healthy, got error: %v", err)
 	}
 }
@@ -44,7 +46,7 @@ func TestCheckHealthNon200(t *testing.T) {
 	worker := NewPythonWorker("unused.py", extractPort(t, ts.URL))
 	worker.HTTPClient = ts.Client()
 
-	if err := worker.checkHealth(); err == nil {
+	if err := worker.checkHealth(context.Background()); err == nil {
 		t.Fatal("expected error for non-200 status")
 	}
 }
@@ -63,7 +65,8 @@ func TestWaitForHealthyEventually(t *testing.T) {
 	worker := NewPythonWorker("unused.py", extractPort(t, ts.URL))
 	worker.HTTPClient = ts.Client()
 
-	if err := worker.waitForHealthy(2 * time.Second); err != nil {
+	worker.Readiness.Deadline = 2 * time.Second
+	if err := worker.Readiness.Wait(context.Background(), worker.checkHealth); err != nil {
 		t.Fatalf("expected eventual health success, got: %v", err)
 	}
 }
@@ -77,8 +80,10 @@ func TestWaitForHealthyTimeout(t *testing.T) {
 	worker := NewPythonWorker("unused.py", extractPort(t, ts.URL))
 	worker.HTTPClient = ts.Client()
 
-	if err := worker.waitForHealthy(300 * time.Millisecond); err == nil {
-		t.Fatal("expected timeout error")
+	worker.Readiness.Deadline = 300 * time.Millisecond
+	err := worker.Readiness.Wait(context.Background(), worker.checkHealth)
+	if !errors.Is(err, ErrWorkerNeverReady) {
+		t.Fatalf("expected ErrWorkerNeverReady, got: %v", err)
 	}
 }
 
@@ -100,3 +105,25 @@ func TestHealthDecode(t *testing.T) {
 		t.Fatalf("unexpected health payload: %+v", health)
 	}
 }
+
+func TestVisibleDevices(t *testing.T) {
+	if env := VisibleDevices(nil); env != nil {
+		t.Fatalf("no assignment should leave the environment alone, got %v", env)
+	}
+	env := VisibleDevices([]int{1, 3})
+	if len(env) != 2 || env[0] != "CUDA_VISIBLE_DEVICES=1,3" || env[1] != "HIP_VISIBLE_DEVICES=1,3" {
+		t.Fatalf("unexpected environment %v", env)
+	}
+}
+
+func TestPythonCommandPrefersWorkerEnvInterpreter(t *testing.T) {
+	t.Setenv("BOTFRAMEWORK_PYTHON", "/usr/bin/python3")
+	p := NewPythonWorker("worker/main.py", "8081")
+	if cmd, _ := p.pythonCommand(context.Background()); cmd.Args[0] != "/usr/bin/python3" {
+		t.Errorf("interpreter = %s, want the manager's BOTFRAMEWORK_PYTHON", cmd.Args[0])
+	}
+	p.Env = []string{"BOTFRAMEWORK_PYTHON=/venvs/llama_cpp/bin/python"}
+	if cmd, _ := p.pythonCommand(context.Background()); cmd.Args[0] != "/venvs/llama_cpp/bin/python" {
+		t.Errorf("interpreter = %s, want the worker's venv", cmd.Args[0])
+	}
+}
@@ -0,0 +1,216 @@
+package supervisor
+
+import (
+	"botframework/profiler"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+)
+
+// vLLM sizing: the memory each GPU keeps outside vLLM's allocation for the CUDA context and
+// graphs, the compute buffers taken from the allocation, the highest share of a GPU vLLM
+// is given and the step max-model-len is rounded down to
+const (
+	vllmReserveMB       = 1024
+	vllmActivationsGB   = 0.5
+	vllmMaxUtilization  = 0.95
+	vllmContextStep     = 256
+	vllmMinModelLen     = 2048
+	vllmWeightsOverhead = 1.2
+)
+
+// VLLMFlags are the vllm serve options derived from the hardware profile
+type VLLMFlags struct {
+	TensorParallel   int // --tensor-parallel-size
+	PipelineParallel int // --pipeline-parallel-size
+	// GPUMemoryUtilization is the share of each GPU's memory vLLM allocates for the weights,
+	// activations and KV cache
+	GPUMemoryUtilization float64 // --gpu-memory-utilization
+	MaxModelLen          int     // --max-model-len
+	// Devices are the GPUs the parallel plan spans; nil uses the first ones visible
+	Devices []int
+	// WeightsGB and MaxKVTokens are what the flags were sized for: the weights, and the
+	// tokens the KV cache left by the utilization holds. Validate checks overrides against them.
+	WeightsGB   float64
+	MaxKVTokens int
+}
+
+// Args renders the flags as vllm serve arguments
+func (f VLLMFlags) Args() []string {
+	args := []string{
+		"--tensor-parallel-size", strconv.Itoa(f.TensorParallel),
+		"--gpu-memory-utilization", strconv.FormatFloat(f.GPUMemoryUtilization, 'f', 2, 64),
+	}
+	if f.PipelineParallel > 1 {
+		args = append(args, "--pipeline-parallel-size", strconv.Itoa(f.PipelineParallel))
+	}
+	if f.MaxModelLen > 0 {
+		args = append(args, "--max-model-len", strconv.Itoa(f.MaxModelLen))
+	}
+	return args
+}
+
+// VLLMFlagsFor sizes vllm serve for model, whose weights take modelSizeGB, on the given
+// hardware. A model that fits one GPU with 20% to spare runs on it; a larger one is split
+// with tensor parallelism across a power-of-two group of GPUs, or with pipeline parallelism
+// across all of them when no such group holds it. Each GPU keeps a gigabyte outside vLLM's
+// share, and max-model-len is what the KV cache left beside the weights holds, up to the
+// model's window. model may be the zero Model, which estimates the KV cache.
+func VLLMFlagsFor(profile *profiler.HardwareProfile, modelSizeGB float64, model profiler.Model) (VLLMFlags, error) {
+	flags := VLLMFlags{TensorParallel: 1, PipelineParallel: 1, WeightsGB: modelSizeGB}
+	if profile == nil || !profile.SupportsVLLM() {
+		return flags, errors.New("vLLM needs an NVIDIA GPU or an AMD GPU its ROCm build supports")
+	}
+
+	perGPU_MB := profile.VRAM_MB
+	needGB := modelSizeGB * vllmWeightsOverhead
+	if needGB > float64(perGPU_MB)/1024.0 {
+		if plan, ok := profile.PlanTensorParallel(needGB); ok {
+			flags.TensorParallel, flags.Devices, perGPU_MB = len(plan.GPUs), plan.GPUs, plan.PerGPU_MB
+		} else if smallest := smallestGPU(profile.GPUs); len(profile.GPUs) > 1 && float64(smallest*len(profile.GPUs))/1024.0 >= needGB {
+			flags.PipelineParallel, perGPU_MB = len(profile.GPUs), smallest
+			for _, gpu := range profile.GPUs {
+				flags.Devices = append(flags.Devices, gpu.Index)
+			}
+		} else {
+			return flags, fmt.Errorf("the weights need %.1fGB, more than the GPUs hold", needGB)
+		}
+	}
+
+	reserveMB := vllmReserveMB + profile.ReservedMB
+	flags.GPUMemoryUtilization = math.Min(vllmMaxUtilization, math.Floor(float64(perGPU_MB-reserveMB)/float64(perGPU_MB)*100)/100)
+	if flags.GPUMemoryUtilization <= 0 {
+		return flags, fmt.Errorf("%dMB per GPU leaves no memory for vLLM", perGPU_MB)
+	}
+
+	gpus := flags.TensorParallel * flags.PipelineParallel
+	kvGB := float64(perGPU_MB*gpus)/1024.0*flags.GPUMemoryUtilization - modelSizeGB - vllmActivationsGB*float64(gpus)
+	if perToken := model.KVCacheGB(1, 2); kvGB > 0 && perToken > 0 {
+		flags.MaxKVTokens = int(kvGB / perToken)
+	}
+	flags.MaxModelLen = flags.MaxKVTokens / vllmContextStep * vllmContextStep
+	if model.ContextWindow > 0 {
+		flags.MaxModelLen = min(flags.MaxModelLen, model.ContextWindow)
+	}
+	if flags.MaxModelLen < vllmMinModelLen {
+		return flags, fmt.Errorf("the KV cache left beside %.1fGB of weights holds %d tokens, under %d", modelSizeGB, flags.MaxKVTokens, vllmMinModelLen)
+	}
+	return flags, nil
+}
+
+// smallestGPU is the memory of the smallest of gpus
+func smallestGPU(gpus []profiler.GPUInfo) int {
+	smallest := 0
+	for i, gpu := range gpus {
+		if i == 0 || gpu.VRAM_MB < smallest {
+			smallest = gpu.VRAM_MB
+		}
+	}
+	return smallest
+}
+
+// Validate checks the flags against the hardware: the parallel plan fits the GPUs visible
+// (devices when set, else all of them), and the weights and context fit what the
+// utilization gives vLLM
+func (f VLLMFlags) Validate(profile *profiler.HardwareProfile, devices []int) error {
+	if profile == nil || !profile.SupportsVLLM() {
+		return errors.New("vLLM needs an NVIDIA GPU or an AMD GPU its ROCm build supports")
+	}
+	if f.TensorParallel < 1 || f.TensorParallel&(f.TensorParallel-1) != 0 {
+		return fmt.Errorf("tensor parallel size %d is not a power of two", f.TensorParallel)
+	}
+	if f.PipelineParallel < 1 {
+		return fmt.Errorf("pipeline parallel size %d is below 1", f.PipelineParallel)
+	}
+	visible := max(len(profile.GPUs), 1)
+	if devices != nil {
+		visible = len(devices)
+	}
+	gpus := f.TensorParallel * f.PipelineParallel
+	if gpus > visible {
+		return fmt.Errorf("tensor parallel size %d x pipeline parallel size %d needs %d GPUs, %d visible", f.TensorParallel, f.PipelineParallel, gpus, visible)
+	}
+	if f.GPUMemoryUtilization <= 0 || f.GPUMemoryUtilization > 1 {
+		return fmt.Errorf("GPU memory utilization %.2f is not between 0 and 1", f.GPUMemoryUtilization)
+	}
+	if allocatedGB := float64(profile.VRAM_MB*gpus) / 1024.0 * f.GPUMemoryUtilization; f.WeightsGB > allocatedGB {
+		return fmt.Errorf("the weights need %.1fGB, more than the %.1fGB vLLM is given across %d GPUs", f.WeightsGB, allocatedGB, gpus)
+	}
+	if f.MaxKVTokens > 0 && f.MaxModelLen > f.MaxKVTokens {
+		return fmt.Errorf("max model length %d exceeds the %d tokens the KV cache holds", f.MaxModelLen, f.MaxKVTokens)
+	}
+	return nil
+}
+
+// VLLMWorker runs vLLM's OpenAI-compatible server with `vllm serve`, sized by VLLMFlags
+// rather than the Python worker's defaults. Supervision, readiness and restarts are shared
+// with PythonWorker; requests are proxied to vLLM's API.
+type VLLMWorker struct {
+	*PythonWorker
+	Binary string
+	Flags  VLLMFlags
+	// Profile is the hardware the flags are validated against before launching
+	Profile *profiler.HardwareProfile
+	// ExtraArgs are passed to vllm serve after the flags, e.g. a tool call parser
+	ExtraArgs []string
+}
+
+func NewVLLMWorker(binary, port, modelPath string, flags VLLMFlags, profile *profiler.HardwareProfile) *VLLMWorker {
+	worker := &VLLMWorker{PythonWorker: NewPythonWorker("", port), Binary: binary, Flags: flags, Profile: profile}
+	worker.Name = "vllm:" + port
+	worker.ModelPath = modelPath
+	worker.GPUs = flags.Devices
+	worker.Command = worker.command
+	// vLLM aborts a request once the client connection closes
+	worker.AbortPath = ""
+	return worker
+}
+
+// Args returns the vllm command line, without the binary
+func (v *VLLMWorker) Args() []string {
+	args := []string{"serve", v.ModelPath, "--host", "127.0.0.1", "--port", v.Port,
+		"--served-model-name", filepath.Base(v.ModelPath)}
+	if v.Mode == ModeEmbedding {
+		args = append(args, "--task", "embed")
+	}
+	args = append(args, v.Flags.Args()...)
+	return append(args, v.ExtraArgs...)
+}
+
+func (v *VLLMWorker) command(ctx context.Context) (*exec.Cmd, error) {
+	if v.ModelPath == "" {
+		return nil, errors.New("vllm needs a model (set BOTFRAMEWORK_MODEL_PATH)")
+	}
+	if err := v.Flags.Validate(v.Profile, v.GPUs); err != nil {
+		return nil, fmt.Errorf("vllm flags: %w", err)
+	}
+	slog.Info("starting vllm", "worker", v.name(), "model", filepath.Base(v.ModelPath), "port", v.Port,
+		"tensor_parallel", v.Flags.TensorParallel, "pipeline_parallel", v.Flags.PipelineParallel,
+		"gpu_memory_utilization", v.Flags.GPUMemoryUtilization, "max_model_len", v.Flags.MaxModelLen)
+	return exec.CommandContext(ctx, v.Binary, v.Args()...), nil
+}
+
+// Health reports vLLM's health. Its /health endpoint answers 200 with an empty body once
+// the engine has loaded the model, so a healthy server always has the model loaded.
+func (v *VLLMWorker) Health() (*WorkerHealth, error) {
+	resp, err := v.HTTPClient.Get(fmt.Sprintf("http://127.0.0.1:%s/health", v.Port))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vllm health returned status %d", resp.StatusCode)
+	}
+	return &WorkerHealth{Status: "ok", ModelLoaded: true, Model: filepath.Base(v.ModelPath)}, nil
+}
+
+// Dialect names the gateway dialect for vLLM
+func (v *VLLMWorker) Dialect() string {
+	return "vllm"
+}
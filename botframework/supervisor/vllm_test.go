@@ -0,0 +1,123 @@
+package supervisor
+
+import (
+	"botframework/profiler"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVLLMFlagsFor(t *testing.T) {
+	llama8b := profiler.Model{ContextWindow: 8192, Architecture: &profiler.Architecture{Layers: 32, KVHeads: 8, HeadDim: 128}}
+
+	single := &profiler.HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024}
+	flags, err := VLLMFlagsFor(single, 16, llama8b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flags.TensorParallel != 1 || flags.PipelineParallel != 1 || flags.GPUMemoryUtilization != 0.95 || flags.MaxModelLen != 8192 {
+		t.Errorf("24GB GPU, 16GB model: %+v, want one GPU at 0.95 with the full 8192 window", flags)
+	}
+	if flags.MaxKVTokens < 8192 || flags.Devices != nil {
+		t.Errorf("24GB GPU, 16GB model: %+v, want room for the window on the first GPU", flags)
+	}
+
+	long := llama8b
+	long.ContextWindow = 131072
+	if flags, _ := VLLMFlagsFor(single, 16, long); flags.MaxModelLen >= 131072 || flags.MaxModelLen%vllmContextStep != 0 || flags.MaxModelLen > flags.MaxKVTokens {
+		t.Errorf("128k window: max model len %d, want what %d KV tokens hold in steps of %d", flags.MaxModelLen, flags.MaxKVTokens, vllmContextStep)
+	}
+
+	gpus := func(n, mb int) []profiler.GPUInfo {
+		list := make([]profiler.GPUInfo, n)
+		for i := range list {
+			list[i] = profiler.GPUInfo{Index: i, VRAM_MB: mb}
+		}
+		return list
+	}
+	quad := &profiler.HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024, GPUs: gpus(4, 24*1024)}
+	flags, err = VLLMFlagsFor(quad, 50, llama8b)
+	if err != nil || flags.TensorParallel != 4 || flags.PipelineParallel != 1 || !slices.Equal(flags.Devices, []int{0, 1, 2, 3}) {
+		t.Errorf("4x24GB, 50GB model: %+v, %v, want tensor parallel across all four", flags, err)
+	}
+	if args := strings.Join(flags.Args(), " "); !strings.Contains(args, "--tensor-parallel-size 4") || strings.Contains(args, "--pipeline-parallel-size") {
+		t.Errorf("Args() = %s", args)
+	}
+
+	triple := &profiler.HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024, GPUs: gpus(3, 24*1024)}
+	flags, err = VLLMFlagsFor(triple, 50, llama8b)
+	if err != nil || flags.TensorParallel != 1 || flags.PipelineParallel != 3 {
+		t.Errorf("3x24GB, 50GB model: %+v, %v, want pipeline parallel across three", flags, err)
+	}
+
+	if _, err := VLLMFlagsFor(single, 30, llama8b); err == nil {
+		t.Error("30GB model on one 24GB GPU sized without an error")
+	}
+	if _, err := VLLMFlagsFor(&profiler.HardwareProfile{HasMetal: true, VRAM_MB: 64 * 1024}, 5, llama8b); err == nil {
+		t.Error("vLLM sized for a Metal host")
+	}
+	if _, err := VLLMFlagsFor(&profiler.HardwareProfile{HasCuda: true, VRAM_MB: 8 * 1024}, 6.5, llama8b); err == nil {
+		t.Error("6.5GB model on an 8GB GPU sized with no room for a context")
+	}
+}
+
+func TestVLLMFlagsValidate(t *testing.T) {
+	dual := &profiler.HardwareProfile{HasCuda: true, VRAM_MB: 24 * 1024, GPUs: []profiler.GPUInfo{{Index: 0, VRAM_MB: 24 * 1024}, {Index: 1, VRAM_MB: 24 * 1024}}}
+	valid := VLLMFlags{TensorParallel: 2, PipelineParallel: 1, GPUMemoryUtilization: 0.9, MaxModelLen: 8192, WeightsGB: 30, MaxKVTokens: 65536}
+	if err := valid.Validate(dual, nil); err != nil {
+		t.Fatalf("valid flags: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		modify  func(*VLLMFlags)
+		devices []int
+		want    string
+	}{
+		{"odd tensor parallel", func(f *VLLMFlags) { f.TensorParallel = 3 }, nil, "power of two"},
+		{"too many GPUs", func(f *VLLMFlags) { f.TensorParallel = 4 }, nil, "needs 4 GPUs, 2 visible"},
+		{"pinned to one GPU", func(*VLLMFlags) {}, []int{1}, "needs 2 GPUs, 1 visible"},
+		{"utilization", func(f *VLLMFlags) { f.GPUMemoryUtilization = 1.5 }, nil, "between 0 and 1"},
+		{"weights", func(f *VLLMFlags) { f.TensorParallel, f.WeightsGB = 1, 30 }, nil, "weights need"},
+		{"context", func(f *VLLMFlags) { f.MaxModelLen = 131072 }, nil, "KV cache holds"},
+	} {
+		flags := valid
+		tc.modify(&flags)
+		if err := flags.Validate(dual, tc.devices); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Validate() = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestVLLMWorkerArgs(t *testing.T) {
+	flags := VLLMFlags{TensorParallel: 2, PipelineParallel: 1, GPUMemoryUtilization: 0.9, MaxModelLen: 8192, Devices: []int{2, 3}}
+	worker := NewVLLMWorker("vllm", "9001", "/models/llama-3-70b-awq", flags, nil)
+	want := []string{"serve", "/models/llama-3-70b-awq", "--host", "127.0.0.1", "--port", "9001", "--served-model-name", "llama-3-70b-awq",
+		"--tensor-parallel-size", "2", "--gpu-memory-utilization", "0.90", "--max-model-len", "8192"}
+	if got := worker.Args(); !slices.Equal(got, want) {
+		t.Errorf("Args() = %v, want %v", got, want)
+	}
+	if !slices.Equal(worker.GPUs, []int{2, 3}) {
+		t.Errorf("GPUs = %v, want the plan's devices", worker.GPUs)
+	}
+	if _, err := worker.command(t.Context()); err == nil || !strings.Contains(err.Error(), "vLLM needs") {
+		t.Errorf("command() without a GPU profile: %v", err)
+	}
+
+	worker.Mode = ModeEmbedding
+	if got := worker.Args(); !slices.Contains(got, "embed") {
+		t.Errorf("embedding worker Args() = %v, want --task embed", got)
+	}
+}
+
+func TestVLLMWorkerHealth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer ts.Close()
+	worker := NewVLLMWorker("vllm", ts.URL[strings.LastIndex(ts.URL, ":")+1:], "/models/qwen-2.5-7b", VLLMFlags{}, nil)
+	health, err := worker.Health()
+	if err != nil || !health.ModelLoaded || health.Model != "qwen-2.5-7b" {
+		t.Errorf("Health() = %+v, %v; want the model loaded", health, err)
+	}
+}
@@ -0,0 +1,159 @@
+package supervisor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Warmup is sent to a worker once it is ready and before it is marked running, so the
+// first user request does not pay for the engine's one-off work: CUDA graph capture,
+// Metal shader compilation, allocator and prefix cache fills
+type Warmup struct {
+	// Prompts are sent in order, each as a chat completion (an embedding in embedding
+	// mode); none disables warmup
+	Prompts []string
+	// MaxTokens is how many tokens each prompt generates
+	MaxTokens int
+	// Timeout bounds the whole warmup; a worker still warming up when it passes is put to
+	// work anyway
+	Timeout time.Duration
+}
+
+// DefaultWarmupPrompt warms up workers unless BOTFRAMEWORK_WARMUP says otherwise
+const DefaultWarmupPrompt = "Write one sentence about the weather."
+
+// DefaultWarmup reads the warmup settings:
+//
+//	BOTFRAMEWORK_WARMUP             off, or prompts separated by "|" (default: one short prompt)
+//	BOTFRAMEWORK_WARMUP_MAX_TOKENS  tokens each prompt generates (default: 16)
+//	BOTFRAMEWORK_WARMUP_TIMEOUT     longest the warmup may take (default: 2m)
+func DefaultWarmup() Warmup {
+	warmup := Warmup{Prompts: []string{DefaultWarmupPrompt}, MaxTokens: 16, Timeout: 2 * time.Minute}
+	switch spec := os.Getenv("BOTFRAMEWORK_WARMUP"); spec {
+	case "":
+	case "off":
+		warmup.Prompts = nil
+	default:
+		warmup.Prompts = ParseWarmupPrompts(spec)
+	}
+	if n, err := strconv.Atoi(os.Getenv("BOTFRAMEWORK_WARMUP_MAX_TOKENS")); err == nil && n > 0 {
+		warmup.MaxTokens = n
+	}
+	if timeout, err := time.ParseDuration(os.Getenv("BOTFRAMEWORK_WARMUP_TIMEOUT")); err == nil && timeout > 0 {
+		warmup.Timeout = timeout
+	}
+	return warmup
+}
+
+// ParseWarmupPrompts splits prompts separated by "|", dropping empty ones
+func ParseWarmupPrompts(spec string) []string {
+	var prompts []string
+	for _, prompt := range strings.Split(spec, "|") {
+		if prompt = strings.TrimSpace(prompt); prompt != "" {
+			prompts = append(prompts, prompt)
+		}
+	}
+	return prompts
+}
+
+// WarmupStatus is how the last process's warmup went
+type WarmupStatus struct {
+	// Seconds is how long the warmup took, every prompt included
+	Seconds float64 `json:"seconds"`
+	// Prompts counts the prompts the worker answered
+	Prompts int    `json:"prompts"`
+	Error   string `json:"error,omitempty"`
+}
+
+// warmUp sends the warmup prompts, stopping at the first failure. A failed warmup is
+// reported but does not fail the worker; the request that follows finds out whether it
+// can serve.
+func (p *PythonWorker) warmUp(ctx context.Context) WarmupStatus {
+	send := p.WarmupRequest
+	if send == nil {
+		send = p.warmupRequest
+	}
+	return warmUp(ctx, p.name(), p.Warmup, send)
+}
+
+// warmUp sends warmup's prompts to worker with send, stopping at the first failure
+func warmUp(ctx context.Context, worker string, warmup Warmup, send func(ctx context.Context, prompt string) error) WarmupStatus {
+	if warmup.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, warmup.Timeout)
+		defer cancel()
+	}
+	var status WarmupStatus
+	start := time.Now()
+	for _, prompt := range warmup.Prompts {
+		if err := send(ctx, prompt); err != nil {
+			status.Error = err.Error()
+			break
+		}
+		status.Prompts++
+	}
+	status.Seconds = time.Since(start).Seconds()
+	if status.Error != "" {
+		slog.Warn("worker warmup failed", "worker", worker, "prompts", status.Prompts, "err", status.Error)
+	} else {
+		slog.Info("worker warmed up", "worker", worker, "prompts", status.Prompts, "duration", time.Since(start).Round(time.Millisecond))
+	}
+	return status
+}
+
+// warmupRequest sends one warmup prompt over the worker's OpenAI API
+func (p *PythonWorker) warmupRequest(ctx context.Context, prompt string) error {
+	path, body := "/v1/chat/completions", warmupChat(prompt, p.Warmup)
+	if p.Mode == ModeEmbedding {
+		path, body = "/v1/embeddings", map[string]any{"input": prompt}
+	}
+	return postWarmup(ctx, p.HTTPClient, p.Port, path, body, p.Warmup)
+}
+
+// warmupChat is the chat completion a warmup prompt is sent as
+func warmupChat(prompt string, warmup Warmup) map[string]any {
+	return map[string]any{
+		"messages":    []map[string]string{{"role": "user", "content": prompt}},
+		"max_tokens":  max(warmup.MaxTokens, 1),
+		"temperature": 0,
+	}
+}
+
+// postWarmup sends a warmup request to the engine on port
+func postWarmup(ctx context.Context, httpClient *http.Client, port, path string, body map[string]any, warmup Warmup) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://127.0.0.1:%s%s", port, path), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the warmup's own timeout applies rather than the client's
+	client := *httpClient
+	client.Timeout = 0
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("warmup timed out after %s", warmup.Timeout)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("warmup returned status %d: %s", resp.StatusCode, truncate(strings.TrimSpace(string(answer)), 80))
+	}
+	return nil
+}
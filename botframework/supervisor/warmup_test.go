@@ -0,0 +1,66 @@
+package supervisor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDefaultWarmup(t *testing.T) {
+	if warmup := DefaultWarmup(); !slices.Equal(warmup.Prompts, []string{DefaultWarmupPrompt}) || warmup.MaxTokens != 16 {
+		t.Errorf("default warmup = %+v", warmup)
+	}
+	t.Setenv("BOTFRAMEWORK_WARMUP", "Hello | Summarise this: ok |")
+	t.Setenv("BOTFRAMEWORK_WARMUP_MAX_TOKENS", "64")
+	t.Setenv("BOTFRAMEWORK_WARMUP_TIMEOUT", "30s")
+	if warmup := DefaultWarmup(); !slices.Equal(warmup.Prompts, []string{"Hello", "Summarise this: ok"}) || warmup.MaxTokens != 64 || warmup.Timeout != 30*time.Second {
+		t.Errorf("configured warmup = %+v", warmup)
+	}
+	t.Setenv("BOTFRAMEWORK_WARMUP", "off")
+	if warmup := DefaultWarmup(); warmup.Prompts != nil {
+		t.Errorf("warmup off = %+v", warmup)
+	}
+}
+
+func TestWarmupSendsPrompts(t *testing.T) {
+	var prompts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+			MaxTokens int `json:"max_tokens"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path != "/v1/chat/completions" || len(body.Messages) != 1 || body.MaxTokens != 8 {
+			t.Errorf("warmup request %s %+v", r.URL.Path, body)
+		}
+		prompts = append(prompts, body.Messages[0].Content)
+		if body.Messages[0].Content == "fail" {
+			http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	worker := NewPythonWorker("unused.py", extractPort(t, ts.URL))
+	worker.Warmup = Warmup{Prompts: []string{"Hello", "Tell me a story"}, MaxTokens: 8, Timeout: time.Minute}
+
+	status := worker.warmUp(context.Background())
+	if status.Prompts != 2 || status.Error != "" || status.Seconds <= 0 {
+		t.Errorf("status = %+v", status)
+	}
+	if !slices.Equal(prompts, worker.Warmup.Prompts) {
+		t.Errorf("prompts sent = %v", prompts)
+	}
+
+	// a failed prompt ends the warmup
+	prompts = nil
+	worker.Warmup.Prompts = []string{"Hello", "fail", "never sent"}
+	status = worker.warmUp(context.Background())
+	if status.Prompts != 1 || status.Error == "" || len(prompts) != 2 {
+		t.Errorf("status = %+v after %v", status, prompts)
+	}
+}
@@ -0,0 +1,260 @@
+package telemetry
+
+import (
+	"botframework/engine"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaVersion identifies the report layout for the receiving endpoint
+const SchemaVersion = 1
+
+// minSampleTokens skips tiny completions whose throughput is mostly request overhead
+const minSampleTokens = 16
+
+// Sample is one anonymized (hardware tier, engine, model family, quantization) throughput figure.
+// Nothing identifying the host, its users, prompts or exact model names is included.
+type Sample struct {
+	HardwareTier    string  `json:"hardware_tier"`
+	Engine          string  `json:"engine"`
+	ModelFamily     string  `json:"model_family"`
+	Quant           string  `json:"quant,omitempty"`
+	TokensPerSecond float64 `json:"tokens_per_second"`
+	Requests        int     `json:"requests"`
+}
+
+// Report is the exact payload posted to the telemetry endpoint
+type Report struct {
+	Schema  int      `json:"schema"`
+	Samples []Sample `json:"samples"`
+}
+
+type key struct {
+	family, quant string
+}
+
+type throughput struct {
+	tokens   int
+	seconds  float64
+	requests int
+}
+
+// Collector aggregates generation throughput per model family and periodically reports it.
+// With an empty Endpoint it only collects, so operators can inspect Preview before opting in.
+type Collector struct {
+	Tier     string
+	Engine   string
+	Endpoint string
+	Interval time.Duration
+	// Family maps a served model name to its public family (e.g. "llama"); unknown models are
+	// reported as "other" so private fine-tune names never leave the host
+	Family func(model string) string
+	Client *http.Client
+
+	mu    sync.Mutex
+	stats map[key]*throughput
+}
+
+func NewCollector(tier, engineName, endpoint string, family func(string) string) *Collector {
+	return &Collector{
+		Tier:     tier,
+		Engine:   engineName,
+		Endpoint: endpoint,
+		Interval: 24 * time.Hour,
+		Family:   family,
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		stats:    make(map[key]*throughput),
+	}
+}
+
+// Observe records that model generated tokens in elapsed
+func (c *Collector) Observe(model string, tokens int, elapsed time.Duration) {
+	if tokens < minSampleTokens || elapsed <= 0 {
+		return
+	}
+	family := ""
+	if c.Family != nil {
+		family = c.Family(model)
+	}
+	if family == "" {
+		family = "other"
+	}
+	k := key{family: strings.ToLower(family), quant: quantOf(model)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats, ok := c.stats[k]
+	if !ok {
+		stats = &throughput{}
+		c.stats[k] = stats
+	}
+	stats.tokens += tokens
+	stats.seconds += elapsed.Seconds()
+	stats.requests++
+}
+
+// Preview returns the report that would be sent next
+func (c *Collector) Preview() Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := Report{Schema: SchemaVersion, Samples: []Sample{}}
+	for k, stats := range c.stats {
+		report.Samples = append(report.Samples, Sample{
+			HardwareTier:    c.Tier,
+			Engine:          c.Engine,
+			ModelFamily:     k.family,
+			Quant:           k.quant,
+			TokensPerSecond: math.Round(float64(stats.tokens)/stats.seconds*10) / 10,
+			Requests:        stats.requests,
+		})
+	}
+	sort.Slice(report.Samples, func(i, j int) bool {
+		a, b := report.Samples[i], report.Samples[j]
+		if a.ModelFamily != b.ModelFamily {
+			return a.ModelFamily < b.ModelFamily
+		}
+		return a.Quant < b.Quant
+	})
+	return report
+}
+
+// Run sends the aggregated report every Interval until ctx is cancelled. Statistics are
+// reset only after a successful send, so an unreachable endpoint loses nothing.
+func (c *Collector) Run(ctx context.Context) {
+	if c.Endpoint == "" {
+		return
+	}
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				slog.Warn("telemetry report not sent", "err", err)
+			}
+		}
+	}
+}
+
+// Flush posts the current report and clears the statistics it covered
+func (c *Collector) Flush(ctx context.Context) error {
+	report := c.Preview()
+	if len(report.Samples) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+
+	c.mu.Lock()
+	c.stats = make(map[key]*throughput)
+	c.mu.Unlock()
+	return nil
+}
+
+// Middleware measures generation throughput of inference responses. Streams are timed from
+// the first chunk so prompt processing does not count against decode speed.
+func (c *Collector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		model, _ := engine.RequestedModel(r)
+		tw := &throughputWriter{ResponseWriter: w, start: time.Now()}
+		next.ServeHTTP(tw, r)
+
+		if tw.status >= 300 {
+			return
+		}
+		if model == "" {
+			model = w.Header().Get(engine.ServedByHeader)
+		}
+		tokens, elapsed := tw.measure()
+		c.Observe(model, tokens, elapsed)
+	})
+}
+
+var quantPattern = regexp.MustCompile(`(?i)(?:^|[-_.])((?:iq|q)\d(?:_[a-z0-9]+)*|f16|fp16|bf16|fp8|int8|int4|awq|gptq|exl2)$`)
+
+// quantOf extracts a well-known quantization suffix from a served model name
+func quantOf(model string) string {
+	if m := quantPattern.FindStringSubmatch(model); m != nil {
+		return strings.ToLower(m[1])
+	}
+	return ""
+}
+
+const maxCapture = 1 << 20
+
+// throughputWriter counts generated tokens and times generation
+type throughputWriter struct {
+	http.ResponseWriter
+	status     int
+	start      time.Time
+	firstChunk time.Time
+	chunks     int
+	body       bytes.Buffer
+}
+
+func (t *throughputWriter) WriteHeader(code int) {
+	t.status = code
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *throughputWriter) Write(b []byte) (int, error) {
+	if strings.HasPrefix(t.Header().Get("Content-Type"), "text/event-stream") {
+		if t.chunks == 0 {
+			t.firstChunk = time.Now()
+		}
+		t.chunks += bytes.Count(b, []byte("data: "))
+	} else if t.body.Len() < maxCapture {
+		t.body.Write(b[:min(len(b), maxCapture-t.body.Len())])
+	}
+	return t.ResponseWriter.Write(b)
+}
+
+func (t *throughputWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// measure returns completion tokens and the time spent generating them. Each SSE chunk
+// carries roughly one token, and the final "data: [DONE]" sentinel carries none.
+func (t *throughputWriter) measure() (int, time.Duration) {
+	if t.chunks > 0 {
+		return t.chunks - 1, time.Since(t.firstChunk)
+	}
+	var payload struct {
+		Usage struct {
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.Unmarshal(t.body.Bytes(), &payload); err != nil {
+		return 0, 0
+	}
+	return payload.Usage.CompletionTokens, time.Since(t.start)
+}
@@ -0,0 +1,96 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func family(model string) string {
+	if strings.HasPrefix(model, "llama-3") {
+		return "Llama"
+	}
+	return ""
+}
+
+func TestPreviewAnonymizesModels(t *testing.T) {
+	c := NewCollector("High", "llama_cpp", "", family)
+	c.Observe("llama-3-8b-instruct-Q4_K_M", 100, 2*time.Second)
+	c.Observe("llama-3-8b-instruct-q4_k_m", 300, 2*time.Second)
+	c.Observe("acme-internal-support-bot", 50, time.Second)
+	c.Observe("llama-3-8b", 3, time.Second) // too short to measure
+
+	report := c.Preview()
+	if len(report.Samples) != 2 {
+		t.Fatalf("want 2 samples, got %+v", report.Samples)
+	}
+	llama := report.Samples[0]
+	if llama.ModelFamily != "llama" || llama.Quant != "q4_k_m" || llama.TokensPerSecond != 100 || llama.Requests != 2 {
+		t.Errorf("unexpected llama sample %+v", llama)
+	}
+	if other := report.Samples[1]; other.ModelFamily != "other" || other.HardwareTier != "High" || other.Engine != "llama_cpp" {
+		t.Errorf("unexpected sample for unknown model %+v", other)
+	}
+
+	data, _ := json.Marshal(report)
+	if strings.Contains(string(data), "acme") || strings.Contains(string(data), "instruct") {
+		t.Errorf("report leaks model names: %s", data)
+	}
+}
+
+func TestFlushSendsAndResets(t *testing.T) {
+	var received Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	c := NewCollector("Apple", "mlx", server.URL, family)
+	c.Observe("llama-3-8b-4bit", 40, time.Second)
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if received.Schema != SchemaVersion || len(received.Samples) != 1 {
+		t.Fatalf("unexpected report %+v", received)
+	}
+	if len(c.Preview().Samples) != 0 {
+		t.Error("want statistics reset after a successful send")
+	}
+}
+
+func TestFlushKeepsStatsOnFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewCollector("High", "vllm", server.URL, family)
+	c.Observe("llama-3-8b", 40, time.Second)
+	if err := c.Flush(context.Background()); err == nil {
+		t.Fatal("want error for failed send")
+	}
+	if len(c.Preview().Samples) != 1 {
+		t.Error("want statistics kept after a failed send")
+	}
+}
+
+func TestMiddlewareMeasuresCompletions(t *testing.T) {
+	c := NewCollector("High", "vllm", "", family)
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"usage":{"prompt_tokens":500,"completion_tokens":32}}`))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"llama-3-8b-q8_0"}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	samples := c.Preview().Samples
+	if len(samples) != 1 || samples[0].Quant != "q8_0" || samples[0].TokensPerSecond <= 0 {
+		t.Fatalf("unexpected samples %+v", samples)
+	}
+}
@@ -0,0 +1,166 @@
+package tokenizer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// gpt2Pattern is the split regex byte-level pre-tokenizers use when tokenizer.json names none
+const gpt2Pattern = `'s|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+`
+
+// splitter pre-tokenizes text the way the split regexes of byte-level tokenizers do. Go's
+// regexp has no lookahead, so the two families in use are matched by hand: GPT-2's, and
+// the one Llama 3 and Qwen share, which groups digits and lets any one symbol lead a word.
+type splitter struct {
+	// symbolPrefix lets a character other than a letter, digit or line break lead a word
+	// (Llama 3); otherwise only a space does (GPT-2)
+	symbolPrefix bool
+	// digits caps the length of a digit run, 0 for no limit
+	digits int
+	// foldCase matches contractions such as 'S and 'LL
+	foldCase bool
+}
+
+// splitterFor recognises the family of a split regex
+func splitterFor(pattern string) splitter {
+	if !strings.Contains(pattern, `[^\r\n\p{L}\p{N}]?\p{L}`) {
+		return splitter{}
+	}
+	s := splitter{symbolPrefix: true, foldCase: strings.Contains(pattern, "(?i:"), digits: 1}
+	if strings.Contains(pattern, `\p{N}{1,3}`) {
+		s.digits = 3
+	}
+	return s
+}
+
+var contractions = []string{"s", "t", "re", "ve", "m", "ll", "d"}
+
+// split cuts text into the words BPE merges within
+func (s splitter) split(text string) []string {
+	var words []string
+	for text != "" {
+		n := s.next(text)
+		words = append(words, text[:n])
+		text = text[n:]
+	}
+	return words
+}
+
+// next returns the byte length of the word text starts with
+func (s splitter) next(text string) int {
+	r0, n0 := utf8.DecodeRuneInString(text)
+	r1, n1 := utf8.DecodeRuneInString(text[n0:])
+	if n0 == len(text) {
+		r1 = -1
+	}
+
+	if r0 == '\'' {
+		for _, c := range contractions {
+			if len(text) > n0 && hasPrefix(text[n0:], c, s.foldCase) {
+				return n0 + len(c)
+			}
+		}
+	}
+	if unicode.IsLetter(r0) {
+		return n0 + span(text[n0:], unicode.IsLetter)
+	}
+	if unicode.IsLetter(r1) && (r0 == ' ' || s.symbolPrefix && !unicode.IsNumber(r0) && r0 != '\r' && r0 != '\n') {
+		return n0 + n1 + span(text[n0+n1:], unicode.IsLetter)
+	}
+	if unicode.IsNumber(r0) {
+		if s.digits > 0 {
+			return spanN(text, unicode.IsNumber, s.digits)
+		}
+		return span(text, unicode.IsNumber)
+	}
+	if r0 == ' ' && unicode.IsNumber(r1) && !s.symbolPrefix {
+		return n0 + span(text[n0:], unicode.IsNumber)
+	}
+	if start := 0; isSymbol(r0) || r0 == ' ' && isSymbol(r1) {
+		if !isSymbol(r0) {
+			start = n0
+		}
+		end := start + span(text[start:], isSymbol)
+		if s.symbolPrefix {
+			end += span(text[end:], func(r rune) bool { return r == '\r' || r == '\n' })
+		}
+		return end
+	}
+
+	// whitespace: Llama 3 ends a run at its last line break, both end it before the space
+	// that leads the next word
+	n := span(text, unicode.IsSpace)
+	if n == 0 {
+		return n0
+	}
+	if s.symbolPrefix {
+		if i := strings.LastIndexAny(text[:n], "\r\n"); i >= 0 {
+			return i + 1
+		}
+	}
+	if n < len(text) {
+		if _, last := utf8.DecodeLastRuneInString(text[:n]); n > last {
+			return n - last
+		}
+	}
+	return n
+}
+
+func isSymbol(r rune) bool {
+	return r >= 0 && !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// span returns the byte length of the run of runes at the start of text that match
+func span(text string, match func(rune) bool) int {
+	return spanN(text, match, -1)
+}
+
+// spanN is span for at most limit runes, any number when limit is negative
+func spanN(text string, match func(rune) bool, limit int) int {
+	n := 0
+	for n < len(text) && limit != 0 {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		if !match(r) {
+			break
+		}
+		n += size
+		limit--
+	}
+	return n
+}
+
+func hasPrefix(text, prefix string, foldCase bool) bool {
+	if len(text) < len(prefix) {
+		return false
+	}
+	if foldCase {
+		return strings.EqualFold(text[:len(prefix)], prefix)
+	}
+	return text[:len(prefix)] == prefix
+}
+
+// byteRunes is GPT-2's reversible map from bytes to printable runes: printable Latin-1
+// bytes stand for themselves and the rest are shifted past 255
+var byteRunes = func() [256]rune {
+	var runes [256]rune
+	shifted := rune(256)
+	for b := range 256 {
+		if b >= '!' && b <= '~' || b >= 0xa1 && b <= 0xac || b >= 0xae {
+			runes[b] = rune(b)
+		} else {
+			runes[b] = shifted
+			shifted++
+		}
+	}
+	return runes
+}()
+
+// toByteLevel spells word's bytes with byteRunes
+func toByteLevel(word string) string {
+	var b strings.Builder
+	for i := 0; i < len(word); i++ {
+		b.WriteRune(byteRunes[word[i]])
+	}
+	return b.String()
+}
@@ -0,0 +1,381 @@
+// Package tokenizer counts tokens with a model's own vocabulary, read from the Hugging Face
+// tokenizer.json shipped with the model. It implements BPE with the byte-level (GPT-2,
+// Llama 3, Qwen) and metaspace (Llama 2, Mistral) pre-tokenizers; other tokenizer models
+// are reported as unsupported so callers fall back to estimates.
+package tokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrUnsupported is returned for tokenizer models other than BPE, such as Unigram or WordPiece
+var ErrUnsupported = errors.New("unsupported tokenizer")
+
+// metaspace stands for spaces in sentencepiece vocabularies
+const metaspace = "▁"
+
+// maxCachedWords bounds the per-tokenizer cache of encoded words
+const maxCachedWords = 1 << 16
+
+// Tokenizer encodes text into token ids
+type Tokenizer struct {
+	vocab map[string]int
+	ranks map[[2]string]int
+	// added are tokens matched verbatim before pre-tokenization, such as chat markers
+	added []addedToken
+	// byteLevel maps bytes to printable runes before BPE (GPT-2 style); otherwise spaces
+	// become metaspace and unknown characters fall back to <0xNN> byte tokens
+	byteLevel    bool
+	prefixSpace  bool
+	splitter     splitter
+	normalizers  []normalizer
+	byteFallback bool
+	ignoreMerges bool
+	unk          int
+
+	mu    sync.Mutex
+	cache map[string][]int
+}
+
+type addedToken struct {
+	ID      int    `json:"id"`
+	Content string `json:"content"`
+}
+
+// file is the part of tokenizer.json the encoder reads
+type file struct {
+	AddedTokens  []addedToken `json:"added_tokens"`
+	Normalizer   *component   `json:"normalizer"`
+	PreTokenizer *component   `json:"pre_tokenizer"`
+	Model        struct {
+		Type         string            `json:"type"`
+		Vocab        json.RawMessage   `json:"vocab"`
+		Merges       []json.RawMessage `json:"merges"`
+		ByteFallback bool              `json:"byte_fallback"`
+		IgnoreMerges bool              `json:"ignore_merges"`
+		UnkToken     *string           `json:"unk_token"`
+	} `json:"model"`
+}
+
+// component is a normalizer or pre-tokenizer, possibly a sequence of them
+type component struct {
+	Type           string      `json:"type"`
+	Normalizers    []component `json:"normalizers"`
+	PreTokenizers  []component `json:"pretokenizers"`
+	AddPrefixSpace *bool       `json:"add_prefix_space"`
+	PrependScheme  string      `json:"prepend_scheme"`
+	Prepend        string      `json:"prepend"`
+	Content        string      `json:"content"`
+	Pattern        struct {
+		String string `json:"String"`
+		Regex  string `json:"Regex"`
+	} `json:"pattern"`
+}
+
+// each visits c and the components of its sequences, in order
+func (c *component) each(fn func(c *component)) {
+	if c == nil {
+		return
+	}
+	fn(c)
+	for i := range c.Normalizers {
+		c.Normalizers[i].each(fn)
+	}
+	for i := range c.PreTokenizers {
+		c.PreTokenizers[i].each(fn)
+	}
+}
+
+// normalizer is a Prepend, Replace or Lowercase normalizer; others leave text unchanged
+type normalizer struct {
+	kind, pattern, content string
+}
+
+func (n normalizer) apply(text string) string {
+	switch n.kind {
+	case "Prepend":
+		return n.content + text
+	case "Replace":
+		return strings.ReplaceAll(text, n.pattern, n.content)
+	case "Lowercase":
+		return strings.ToLower(text)
+	}
+	return text
+}
+
+// Load reads a tokenizer.json file
+func Load(path string) (*Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Parse reads the contents of a tokenizer.json file
+func Parse(data []byte) (*Tokenizer, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Model.Type != "BPE" && !(f.Model.Type == "" && len(f.Model.Merges) > 0) {
+		return nil, fmt.Errorf("%w: %s model", ErrUnsupported, f.Model.Type)
+	}
+
+	t := &Tokenizer{
+		ranks:        make(map[[2]string]int, len(f.Model.Merges)),
+		added:        f.AddedTokens,
+		byteFallback: f.Model.ByteFallback,
+		ignoreMerges: f.Model.IgnoreMerges,
+		unk:          -1,
+		cache:        make(map[string][]int),
+	}
+	if err := json.Unmarshal(f.Model.Vocab, &t.vocab); err != nil {
+		return nil, fmt.Errorf("vocab: %w", err)
+	}
+	for rank, raw := range f.Model.Merges {
+		var pair [2]string
+		var merge string
+		if json.Unmarshal(raw, &merge) == nil {
+			var ok bool
+			if pair[0], pair[1], ok = strings.Cut(merge, " "); !ok {
+				return nil, fmt.Errorf("merge %d: %q is not a pair", rank, merge)
+			}
+		} else if err := json.Unmarshal(raw, &pair); err != nil {
+			return nil, fmt.Errorf("merge %d: %w", rank, err)
+		}
+		if _, seen := t.ranks[pair]; !seen {
+			t.ranks[pair] = rank
+		}
+	}
+	if f.Model.UnkToken != nil {
+		if id, ok := t.vocab[*f.Model.UnkToken]; ok {
+			t.unk = id
+		}
+	}
+
+	f.Normalizer.each(func(c *component) {
+		switch c.Type {
+		case "Prepend":
+			t.normalizers = append(t.normalizers, normalizer{kind: c.Type, content: c.Prepend})
+		case "Replace":
+			if c.Pattern.String != "" {
+				t.normalizers = append(t.normalizers, normalizer{kind: c.Type, pattern: c.Pattern.String, content: c.Content})
+			}
+		case "Lowercase":
+			t.normalizers = append(t.normalizers, normalizer{kind: c.Type})
+		}
+	})
+	split := ""
+	f.PreTokenizer.each(func(c *component) {
+		switch c.Type {
+		case "ByteLevel":
+			t.byteLevel = true
+			if c.AddPrefixSpace != nil && *c.AddPrefixSpace {
+				t.prefixSpace = true
+			}
+			if split == "" {
+				split = gpt2Pattern
+			}
+		case "Split":
+			split = c.Pattern.Regex
+		case "Metaspace":
+			// the Llama 2 era tokenizers that prepend through a normalizer have no
+			// pre-tokenizer; later ones do it here
+			if c.PrependScheme != "never" && (c.AddPrefixSpace == nil || *c.AddPrefixSpace) {
+				t.normalizers = append(t.normalizers, normalizer{kind: "Prepend", content: metaspace})
+			}
+			t.normalizers = append(t.normalizers, normalizer{kind: "Replace", pattern: " ", content: metaspace})
+		}
+	})
+	if t.byteLevel {
+		t.splitter = splitterFor(split)
+	}
+	return t, nil
+}
+
+// Count returns the number of tokens text encodes to
+func (t *Tokenizer) Count(text string) int {
+	return len(t.Encode(text))
+}
+
+// Encode returns the token ids of text. Added tokens such as <|im_start|> are matched
+// verbatim; characters the vocabulary lacks become byte tokens or the unknown token.
+func (t *Tokenizer) Encode(text string) []int {
+	var ids []int
+	for text != "" {
+		at, token := t.nextAdded(text)
+		ids = t.encodeSegment(ids, text[:at])
+		if token == nil {
+			break
+		}
+		ids = append(ids, token.ID)
+		text = text[at+len(token.Content):]
+	}
+	return ids
+}
+
+// nextAdded finds the earliest added token in text, the longest when several start there
+func (t *Tokenizer) nextAdded(text string) (int, *addedToken) {
+	at, found := len(text), (*addedToken)(nil)
+	for i := range t.added {
+		token := &t.added[i]
+		if token.Content == "" {
+			continue
+		}
+		if j := strings.Index(text[:min(len(text), at+len(token.Content))], token.Content); j >= 0 &&
+			(j < at || (j == at && len(token.Content) > len(found.Content))) {
+			at, found = j, token
+		}
+	}
+	return at, found
+}
+
+func (t *Tokenizer) encodeSegment(ids []int, text string) []int {
+	if text == "" {
+		return ids
+	}
+	for _, n := range t.normalizers {
+		text = n.apply(text)
+	}
+	if t.byteLevel {
+		if t.prefixSpace && !strings.HasPrefix(text, " ") {
+			text = " " + text
+		}
+		for _, word := range t.splitter.split(text) {
+			ids = t.encodeWord(ids, toByteLevel(word))
+		}
+		return ids
+	}
+	// sentencepiece pieces start at a metaspace, so words are split before each one
+	for text != "" {
+		end := strings.Index(text[1:], metaspace) + 1
+		if end == 0 {
+			end = len(text)
+		}
+		ids = t.encodeWord(ids, text[:end])
+		text = text[end:]
+	}
+	return ids
+}
+
+// encodeWord appends the ids of one pre-tokenized word, merging its characters by rank
+func (t *Tokenizer) encodeWord(ids []int, word string) []int {
+	t.mu.Lock()
+	cached, ok := t.cache[word]
+	t.mu.Unlock()
+	if ok {
+		return append(ids, cached...)
+	}
+
+	var encoded []int
+	if id, ok := t.vocab[word]; ok && (t.ignoreMerges || utf8.RuneCountInString(word) == 1) {
+		encoded = []int{id}
+	} else {
+		for _, symbol := range t.merge(word) {
+			encoded = t.lookup(encoded, symbol)
+		}
+	}
+
+	t.mu.Lock()
+	if len(t.cache) >= maxCachedWords {
+		clear(t.cache)
+	}
+	t.cache[word] = encoded
+	t.mu.Unlock()
+	return append(ids, encoded...)
+}
+
+// merge applies the lowest ranked merge until none applies
+func (t *Tokenizer) merge(word string) []string {
+	symbols := make([]string, 0, len(word))
+	for _, r := range word {
+		symbols = append(symbols, string(r))
+	}
+	for len(symbols) > 1 {
+		best, at := -1, -1
+		for i := 0; i+1 < len(symbols); i++ {
+			if rank, ok := t.ranks[[2]string{symbols[i], symbols[i+1]}]; ok && (best < 0 || rank < best) {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		pair := [2]string{symbols[at], symbols[at+1]}
+		merged := symbols[:0]
+		for i := 0; i < len(symbols); i++ {
+			if i+1 < len(symbols) && symbols[i] == pair[0] && symbols[i+1] == pair[1] {
+				merged = append(merged, pair[0]+pair[1])
+				i++
+				continue
+			}
+			merged = append(merged, symbols[i])
+		}
+		symbols = merged
+	}
+	return symbols
+}
+
+// lookup appends the id of symbol, or of its bytes when the vocabulary lacks it
+func (t *Tokenizer) lookup(ids []int, symbol string) []int {
+	if id, ok := t.vocab[symbol]; ok {
+		return append(ids, id)
+	}
+	if t.byteFallback {
+		for i := 0; i < len(symbol); i++ {
+			if id, ok := t.vocab[fmt.Sprintf("<0x%02X>", symbol[i])]; ok {
+				ids = append(ids, id)
+			} else {
+				ids = append(ids, t.unk)
+			}
+		}
+		return ids
+	}
+	return append(ids, t.unk)
+}
+
+// Cache loads each model's tokenizer on first use and keeps it. Files that fail to load
+// are logged once and not retried.
+type Cache struct {
+	// Path returns the tokenizer.json of a model, "" when it has none
+	Path func(model string) string
+
+	mu     sync.Mutex
+	loaded map[string]*Tokenizer
+}
+
+func NewCache(path func(model string) string) *Cache {
+	return &Cache{Path: path, loaded: make(map[string]*Tokenizer)}
+}
+
+// Get returns the tokenizer of model, nil when it has none
+func (c *Cache) Get(model string) *Tokenizer {
+	path := c.Path(model)
+	if path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t, ok := c.loaded[path]; ok {
+		return t
+	}
+	t, err := Load(path)
+	if err != nil {
+		slog.Warn("tokenizer unavailable, estimating token counts", "model", model, "err", err)
+		t = nil
+	}
+	c.loaded[path] = t
+	return t
+}
@@ -0,0 +1,103 @@
+package tokenizer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// byteLevelJSON is a GPT-2 style vocabulary: Ġ spells a space
+const byteLevelJSON = `{
+	"added_tokens": [{"id": 100, "content": "<|im_start|>"}, {"id": 101, "content": "<|im_end|>"}],
+	"pre_tokenizer": {"type": "ByteLevel", "add_prefix_space": false, "use_regex": true},
+	"model": {
+		"type": "BPE",
+		"vocab": {"h": 0, "e": 1, "l": 2, "o": 3, "Ġ": 4, "w": 5, "r": 6, "d": 7, "!": 8,
+			"he": 9, "ll": 10, "hell": 11, "hello": 12, "Ġw": 13, "or": 14, "Ġwor": 15, "Ġworld": 16, "Ċ": 17},
+		"merges": ["h e", "l l", "he ll", "hell o", "Ġ w", ["o", "r"], "Ġw or", "Ġwor l", "Ġworl d"]
+	}
+}`
+
+func TestByteLevelEncode(t *testing.T) {
+	tok, err := Parse([]byte(byteLevelJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// merges pass through Ġworl although it is no token
+	if got, want := tok.Encode("hello world!"), []int{12, 16, 8}; !slices.Equal(got, want) {
+		t.Errorf("Encode = %v, want %v", got, want)
+	}
+	if got, want := tok.Encode("<|im_start|>hello<|im_end|>\n"), []int{100, 12, 101, 17}; !slices.Equal(got, want) {
+		t.Errorf("Encode with added tokens = %v, want %v", got, want)
+	}
+	if n := tok.Count("hello hello"); n != 3 {
+		t.Errorf("Count = %d, want 3 (hello Ġ hello: no merge joins Ġ and h)", n)
+	}
+}
+
+func TestMetaspaceByteFallback(t *testing.T) {
+	tok, err := Parse([]byte(`{
+		"normalizer": {"type": "Sequence", "normalizers": [
+			{"type": "Prepend", "prepend": "▁"},
+			{"type": "Replace", "pattern": {"String": " "}, "content": "▁"}]},
+		"pre_tokenizer": null,
+		"model": {"type": "BPE", "byte_fallback": true, "unk_token": "<unk>",
+			"vocab": {"<unk>": 0, "<0xE2>": 1, "<0x98>": 2, "<0x83>": 3, "▁": 4, "h": 5, "i": 6, "▁h": 7, "▁hi": 8},
+			"merges": ["▁ h", "▁h i"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tok.Encode("hi ☃ hi"), []int{8, 4, 1, 2, 3, 8}; !slices.Equal(got, want) {
+		t.Errorf("Encode = %v, want %v", got, want)
+	}
+}
+
+func TestSplitters(t *testing.T) {
+	for _, tc := range []struct {
+		splitter splitter
+		text     string
+		want     []string
+	}{
+		{splitter{}, "Hello world's  123 !!", []string{"Hello", " world", "'s", " ", " 123", " !!"}},
+		{splitter{}, "a\n\nb ", []string{"a", "\n", "\n", "b", " "}},
+		{splitterFor(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`),
+			"I'LL  pay $12345.\n\n  ok", []string{"I", "'LL", " ", " pay", " $", "123", "45", ".\n\n", " ", " ok"}},
+	} {
+		if got := tc.splitter.split(tc.text); !slices.Equal(got, tc.want) {
+			t.Errorf("split(%q) = %q, want %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestParseRejectsUnigram(t *testing.T) {
+	if _, err := Parse([]byte(`{"model": {"type": "Unigram", "vocab": [["a", -1.0]]}}`)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tokenizer.json")
+	if err := os.WriteFile(path, []byte(byteLevelJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache := NewCache(func(model string) string {
+		switch model {
+		case "qwen", "qwen-alias":
+			return path
+		case "broken":
+			return filepath.Join(dir, "missing.json")
+		}
+		return ""
+	})
+	tok := cache.Get("qwen")
+	if tok == nil || cache.Get("qwen-alias") != tok {
+		t.Fatalf("models sharing a file should share its tokenizer")
+	}
+	if cache.Get("broken") != nil || cache.Get("unknown") != nil {
+		t.Error("models without a loadable tokenizer should have none")
+	}
+}
@@ -0,0 +1,374 @@
+package tools
+
+import (
+	"botframework/chat"
+	"botframework/openai"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Emulator gives backends that do not parse tool calls themselves the OpenAI tools API. The
+// declared tools are described in a system message and the output is constrained through
+// response_format to a JSON schema, which llama-server and llama-cpp-python compile into a
+// grammar: {"tool_calls": [{"name": ..., "arguments": {...}}]} or {"content": "..."}. The
+// JSON the model writes is turned back into tool_calls. Earlier calls and tool results in
+// the conversation become plain messages, which every chat template accepts.
+type Emulator struct {
+	// Native reports whether the backend serving model handles tools itself; its requests
+	// pass through untouched. Nil emulates tools for every model.
+	Native func(model string) bool
+}
+
+func NewEmulator(native func(model string) bool) *Emulator {
+	return &Emulator{Native: native}
+}
+
+// Tool choices, besides a named function
+const (
+	ChoiceAuto     = "auto"
+	ChoiceNone     = "none"
+	ChoiceRequired = "required"
+)
+
+// toolChoice reads tool_choice: a mode, or "required" with the function the request names
+func toolChoice(req *chat.Request) (string, string) {
+	var raw json.RawMessage
+	if !req.Get("tool_choice", &raw) {
+		return ChoiceAuto, ""
+	}
+	var mode string
+	if json.Unmarshal(raw, &mode) == nil {
+		if mode == ChoiceNone || mode == ChoiceRequired {
+			return mode, ""
+		}
+		return ChoiceAuto, ""
+	}
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if json.Unmarshal(raw, &named) == nil && named.Function.Name != "" {
+		return ChoiceRequired, named.Function.Name
+	}
+	return ChoiceAuto, ""
+}
+
+// Middleware rewrites chat completions that declare tools for a backend without tool
+// support and turns its answers into tool calls. Streamed requests are generated whole and
+// replayed as events, as a call is only known once its JSON is complete.
+func (e *Emulator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := chat.Read(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var declared []tool
+		if !req.Get("tools", &declared) || len(declared) == 0 || (e.Native != nil && e.Native(req.Model())) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		mode, name := toolChoice(req)
+		functions := make(map[string]Function, len(declared))
+		for _, t := range declared {
+			functions[t.Function.Name] = t.Function
+		}
+		if _, ok := functions[name]; name != "" && !ok {
+			openai.WriteError(w, http.StatusBadRequest, "invalid_request_error", "invalid_tool_choice", fmt.Sprintf("tool_choice names %q, which is not among the tools", name))
+			return
+		}
+
+		req.Messages = plainHistory(req.Messages)
+		for _, field := range []string{"tools", "tool_choice", "parallel_tool_calls"} {
+			req.Delete(field)
+		}
+		if mode != ChoiceNone {
+			req.Messages = withSystemPrompt(req.Messages, toolPrompt(declared, mode, name))
+			if err := req.Set("response_format", map[string]any{
+				"type": "json_object", "schema": outputSchema(declared, mode, name),
+			}); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		var stream bool
+		req.Get("stream", &stream)
+		var streamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		}
+		req.Get("stream_options", &streamOptions)
+		if stream {
+			req.Delete("stream")
+			req.Delete("stream_options")
+		}
+		if err := req.Write(r); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		resp := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		next.ServeHTTP(resp, r)
+		var completion map[string]any
+		if resp.status == http.StatusOK && json.Unmarshal(resp.body.Bytes(), &completion) == nil {
+			if mode != ChoiceNone {
+				toolResponse(completion, functions)
+				resp.setBody(completion)
+			}
+			if stream {
+				replayStream(w, resp.header, completion, streamOptions.IncludeUsage)
+				return
+			}
+		}
+		for key, values := range resp.header {
+			w.Header()[key] = values
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(resp.status)
+		_, _ = w.Write(resp.body.Bytes())
+	})
+}
+
+// plainHistory rewrites earlier tool calls as the JSON the model is asked to write and tool
+// results as user messages, so templates without tool roles keep them
+func plainHistory(messages []chat.Message) []chat.Message {
+	names := make(map[string]string) // call ID -> function
+	out := make([]chat.Message, 0, len(messages))
+	for _, m := range messages {
+		switch {
+		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			var calls []toolCall
+			if json.Unmarshal(m.ToolCalls, &calls) != nil || len(calls) == 0 {
+				break
+			}
+			written := make([]map[string]any, len(calls))
+			for i, call := range calls {
+				names[call.ID] = call.Function.Name
+				var args any = map[string]any{}
+				if repaired, ok := RepairJSON(call.Function.Arguments); ok {
+					_ = json.Unmarshal([]byte(repaired), &args)
+				}
+				written[i] = map[string]any{"name": call.Function.Name, "arguments": args}
+			}
+			data, _ := json.Marshal(map[string]any{"tool_calls": written})
+			m = chat.Message{Role: "assistant", Content: string(data)}
+		case m.Role == "tool" || m.Role == "function":
+			name := m.Name
+			if name == "" {
+				name = names[m.ToolCallID]
+			}
+			m = chat.Message{Role: "user", Content: fmt.Sprintf("Result of the %s tool call:\n%s", name, m.Text())}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// withSystemPrompt appends prompt to the leading system message, adding one if needed
+func withSystemPrompt(messages []chat.Message, prompt string) []chat.Message {
+	if len(messages) > 0 && messages[0].Role == "system" {
+		if text, ok := messages[0].Content.(string); ok {
+			messages[0].Content = text + "\n\n" + prompt
+			return messages
+		}
+	}
+	return append([]chat.Message{{Role: "system", Content: prompt}}, messages...)
+}
+
+// toolPrompt describes the tools and the JSON answers the model may give
+func toolPrompt(declared []tool, mode, name string) string {
+	var b strings.Builder
+	b.WriteString("You can call these tools:\n")
+	for _, t := range declared {
+		if name != "" && t.Function.Name != name {
+			continue
+		}
+		params, _ := json.Marshal(parameters(t.Function))
+		fmt.Fprintf(&b, "- %s", t.Function.Name)
+		if t.Function.Description != "" {
+			fmt.Fprintf(&b, ": %s", t.Function.Description)
+		}
+		fmt.Fprintf(&b, "\n  arguments schema: %s\n", params)
+	}
+	b.WriteString(`Answer with a single JSON object. To call tools, write {"tool_calls": [{"name": "<tool>", "arguments": {...}}]}`)
+	if mode == ChoiceRequired {
+		b.WriteString("; you must call a tool.")
+	} else {
+		b.WriteString(`; to reply without a tool, write {"content": "<your reply>"}.`)
+	}
+	return b.String()
+}
+
+func parameters(fn Function) map[string]any {
+	if fn.Parameters == nil {
+		return map[string]any{"type": "object"}
+	}
+	return fn.Parameters
+}
+
+// outputSchema is the JSON schema of the answers toolPrompt allows
+func outputSchema(declared []tool, mode, name string) map[string]any {
+	var calls []any
+	for _, t := range declared {
+		if name != "" && t.Function.Name != name {
+			continue
+		}
+		calls = append(calls, map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name":      map[string]any{"const": t.Function.Name},
+				"arguments": parameters(t.Function),
+			},
+			"required":             []string{"name", "arguments"},
+			"additionalProperties": false,
+		})
+	}
+	item := calls[0]
+	if len(calls) > 1 {
+		item = map[string]any{"anyOf": calls}
+	}
+	toolCalls := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"tool_calls": map[string]any{"type": "array", "items": item, "minItems": 1},
+		},
+		"required":             []string{"tool_calls"},
+		"additionalProperties": false,
+	}
+	if mode == ChoiceRequired {
+		return toolCalls
+	}
+	return map[string]any{"anyOf": []any{toolCalls, map[string]any{
+		"type":                 "object",
+		"properties":           map[string]any{"content": map[string]any{"type": "string"}},
+		"required":             []string{"content"},
+		"additionalProperties": false,
+	}}}
+}
+
+// toolResponse turns the JSON each choice's message holds into tool calls or a reply.
+// Messages that are not such JSON, from backends that ignored the schema, are left alone
+// unless they hold a recognisable call.
+func toolResponse(completion map[string]any, functions map[string]Function) {
+	choices, _ := completion["choices"].([]any)
+	for _, c := range choices {
+		choice, _ := c.(map[string]any)
+		message, _ := choice["message"].(map[string]any)
+		content, _ := message["content"].(string)
+		if message == nil || content == "" {
+			continue
+		}
+		var answer struct {
+			ToolCalls []struct {
+				Name      string          `json:"name"`
+				Arguments json.RawMessage `json:"arguments"`
+			} `json:"tool_calls"`
+			Content *string `json:"content"`
+		}
+		var calls []any
+		repaired, valid := RepairJSON(content)
+		switch {
+		case valid && json.Unmarshal([]byte(repaired), &answer) == nil && len(answer.ToolCalls) > 0:
+			for _, written := range answer.ToolCalls {
+				var call toolCall
+				call.ID = newCallID()
+				call.Type = "function"
+				call.Function.Name = written.Name
+				call.Function.Arguments = "{}"
+				var compact bytes.Buffer
+				var encoded string
+				if json.Unmarshal(written.Arguments, &encoded) == nil {
+					call.Function.Arguments = encoded
+				} else if json.Compact(&compact, written.Arguments) == nil {
+					call.Function.Arguments = compact.String()
+				}
+				calls = append(calls, call)
+			}
+		case valid && answer.Content != nil:
+			message["content"] = *answer.Content
+			continue
+		default:
+			call, ok := callFromContent(content, functions)
+			if !ok {
+				continue
+			}
+			calls = []any{call}
+		}
+		message["tool_calls"] = calls
+		message["content"] = nil
+		choice["finish_reason"] = "tool_calls"
+	}
+}
+
+// replayStream sends a complete chat completion as the event stream a streaming backend
+// would have sent: the message in one chunk, then its finish reason, then usage if asked
+func replayStream(w http.ResponseWriter, header http.Header, completion map[string]any, includeUsage bool) {
+	for key, values := range header {
+		w.Header()[key] = values
+	}
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	base := map[string]any{
+		"id": completion["id"], "object": "chat.completion.chunk", "model": completion["model"],
+		"created": completion["created"],
+	}
+	if base["created"] == nil {
+		base["created"] = time.Now().Unix()
+	}
+	send := func(choices []any, usage any) {
+		chunk := make(map[string]any, len(base)+2)
+		for key, value := range base {
+			chunk[key] = value
+		}
+		chunk["choices"] = choices
+		if usage != nil {
+			chunk["usage"] = usage
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+
+	choices, _ := completion["choices"].([]any)
+	deltas := make([]any, 0, len(choices))
+	finishes := make([]any, 0, len(choices))
+	for i, c := range choices {
+		choice, _ := c.(map[string]any)
+		message, _ := choice["message"].(map[string]any)
+		delta := map[string]any{"role": "assistant"}
+		if calls, _ := message["tool_calls"].([]any); len(calls) > 0 {
+			indexed := make([]any, len(calls))
+			for j, call := range calls {
+				data, _ := json.Marshal(call)
+				var fields map[string]any
+				_ = json.Unmarshal(data, &fields)
+				fields["index"] = j
+				indexed[j] = fields
+			}
+			delta["tool_calls"] = indexed
+		} else {
+			delta["content"] = message["content"]
+		}
+		index := choice["index"]
+		if index == nil {
+			index = i
+		}
+		deltas = append(deltas, map[string]any{"index": index, "delta": delta, "finish_reason": nil})
+		finishes = append(finishes, map[string]any{"index": index, "delta": map[string]any{}, "finish_reason": choice["finish_reason"]})
+	}
+	send(deltas, nil)
+	send(finishes, nil)
+	if usage, ok := completion["usage"]; ok && includeUsage {
+		send([]any{}, usage)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveEmulator(t *testing.T, native bool, request, content string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var sent map[string]any
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &sent); err != nil {
+			t.Fatalf("backend got %s: %v", body, err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "x", "model": "m", "usage": map[string]int{"total_tokens": 9},
+			"choices": []any{map[string]any{"index": 0, "finish_reason": "stop",
+				"message": map[string]any{"role": "assistant", "content": content}}},
+		})
+	})
+	emulator := NewEmulator(func(string) bool { return native })
+	rec := httptest.NewRecorder()
+	emulator.Middleware(backend).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(request)))
+	return rec, sent
+}
+
+func TestEmulatorTurnsJSONIntoToolCalls(t *testing.T) {
+	rec, sent := serveEmulator(t, false, toolRequest, `{"tool_calls": [{"name": "get_weather", "arguments": {"city": "Paris"}}]}`)
+	if _, ok := sent["tools"]; ok {
+		t.Error("tools were passed to a backend without tool support")
+	}
+	format, _ := sent["response_format"].(map[string]any)
+	if format["type"] != "json_object" || format["schema"] == nil {
+		t.Errorf("response_format = %v, want a JSON schema", sent["response_format"])
+	}
+	messages, _ := sent["messages"].([]any)
+	system, _ := messages[0].(map[string]any)
+	if system["role"] != "system" || !strings.Contains(system["content"].(string), "get_weather") {
+		t.Errorf("first message = %v, want the tools described", system)
+	}
+
+	call, finish := firstCall(t, rec.Body.String())
+	if finish != "tool_calls" || call.Function.Name != "get_weather" || call.Function.Arguments != `{"city":"Paris"}` || call.ID == "" {
+		t.Errorf("call = %+v, finish_reason %q", call, finish)
+	}
+}
+
+func TestEmulatorPassesNativeBackendsThrough(t *testing.T) {
+	_, sent := serveEmulator(t, true, toolRequest, "hi")
+	if _, ok := sent["tools"]; !ok || sent["response_format"] != nil {
+		t.Errorf("a native backend got %v", sent)
+	}
+}
+
+func TestEmulatorRequiredChoiceSchema(t *testing.T) {
+	declared := []tool{{Type: "function", Function: Function{Name: "a"}}, {Type: "function", Function: Function{Name: "b"}}}
+	schema := outputSchema(declared, ChoiceRequired, "b")
+	data, _ := json.Marshal(schema)
+	if strings.Contains(string(data), `"content"`) || strings.Contains(string(data), `"const":"a"`) || !strings.Contains(string(data), `"const":"b"`) {
+		t.Errorf("schema for a required call of b = %s", data)
+	}
+	if data, _ := json.Marshal(outputSchema(declared, ChoiceAuto, "")); !strings.Contains(string(data), `"content"`) {
+		t.Errorf("auto should allow a plain reply: %s", data)
+	}
+}
+
+func TestEmulatorReplaysStreams(t *testing.T) {
+	request := strings.Replace(toolRequest, `"model":"m",`, `"model":"m","stream":true,"stream_options":{"include_usage":true},`, 1)
+	rec, sent := serveEmulator(t, false, request, `{"content": "It is sunny."}`)
+	if sent["stream"] != nil {
+		t.Error("the backend should generate the whole answer")
+	}
+	if rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("content type %q", rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"content":"It is sunny."`) || !strings.Contains(body, `"total_tokens":9`) ||
+		!strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("stream = %s", body)
+	}
+}
+
+func TestEmulatorRewritesToolHistory(t *testing.T) {
+	request := `{"model":"m","tool_choice":"none","messages":[{"role":"user","content":"weather?"},
+{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+{"role":"tool","tool_call_id":"call_1","content":"18C"}],
+"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}]}`
+	rec, sent := serveEmulator(t, false, request, "It is 18C.")
+	if sent["response_format"] != nil {
+		t.Error("tool_choice none should not constrain the output")
+	}
+	messages, _ := json.Marshal(sent["messages"])
+	if !strings.Contains(string(messages), `{"content":"{\"tool_calls\":[{\"arguments\":{\"city\":\"Paris\"},\"name\":\"get_weather\"}]}","role":"assistant"}`) ||
+		!strings.Contains(string(messages), `Result of the get_weather tool call:\n18C`) || strings.Contains(string(messages), `"tool"`) {
+		t.Errorf("messages = %s", messages)
+	}
+	if !strings.Contains(rec.Body.String(), `"content":"It is 18C."`) {
+		t.Errorf("response = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RepairJSON fixes the mistakes small models make most often when writing JSON: code fences,
+// surrounding prose, single-quoted strings, unquoted keys, Python literals, trailing commas
+// and unclosed strings or brackets. It reports whether the result is valid JSON.
+func RepairJSON(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	if json.Valid([]byte(s)) {
+		return s, true
+	}
+
+	s = stripFences(s)
+	if start := strings.IndexAny(s, "{["); start > 0 {
+		s = s[start:]
+	}
+	if json.Valid([]byte(s)) {
+		return s, true
+	}
+
+	repaired := repairTokens(s)
+	return repaired, json.Valid([]byte(repaired))
+}
+
+func stripFences(s string) string {
+	if i := strings.Index(s, "```"); i >= 0 {
+		rest := s[i+3:]
+		if nl := strings.IndexByte(rest, '\n'); nl >= 0 && !strings.ContainsAny(rest[:nl], "{[") {
+			rest = rest[nl+1:]
+		}
+		if end := strings.Index(rest, "```"); end >= 0 {
+			rest = rest[:end]
+		}
+		return strings.TrimSpace(rest)
+	}
+	return s
+}
+
+// repairTokens rewrites s in a single pass, tracking string and bracket state
+func repairTokens(s string) string {
+	var out strings.Builder
+	var stack []byte
+	var quote byte // active string delimiter, 0 outside strings
+
+	// expectKey is true right after '{' or ',' inside an object
+	expectKey := false
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if quote != 0 {
+			switch {
+			case c == '\\' && i+1 < len(s):
+				out.WriteByte(c)
+				i++
+				out.WriteByte(s[i])
+			case c == quote:
+				out.WriteByte('"')
+				quote = 0
+			case c == '"':
+				// a double quote inside a single-quoted string
+				out.WriteString(`\"`)
+			case c == '\n':
+				out.WriteString(`\n`)
+			default:
+				out.WriteByte(c)
+			}
+			continue
+		}
+
+		switch {
+		case c == '"' || c == '\'':
+			quote = c
+			out.WriteByte('"')
+			expectKey = false
+		case c == '{' || c == '[':
+			stack = append(stack, c)
+			out.WriteByte(c)
+			expectKey = c == '{'
+		case c == '}' || c == ']':
+			trimTrailingComma(&out)
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			out.WriteByte(c)
+			expectKey = false
+			if len(stack) == 0 {
+				// ignore anything after the top-level value
+				return out.String()
+			}
+		case c == ',':
+			out.WriteByte(c)
+			expectKey = len(stack) > 0 && stack[len(stack)-1] == '{'
+		case isIdentStart(c):
+			j := i
+			for j < len(s) && isIdentChar(s[j]) {
+				j++
+			}
+			word := s[i:j]
+			if expectKey {
+				out.WriteString(`"` + word + `"`)
+				expectKey = false
+			} else {
+				switch word {
+				case "True":
+					word = "true"
+				case "False":
+					word = "false"
+				case "None", "undefined":
+					word = "null"
+				}
+				out.WriteString(word)
+			}
+			i = j - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	if quote != 0 {
+		out.WriteByte('"')
+	}
+	trimTrailingComma(&out)
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			out.WriteByte('}')
+		} else {
+			out.WriteByte(']')
+		}
+	}
+	return out.String()
+}
+
+func trimTrailingComma(out *strings.Builder) {
+	trimmed := strings.TrimRight(out.String(), " \t\r\n")
+	if strings.HasSuffix(trimmed, ",") {
+		trimmed = trimmed[:len(trimmed)-1]
+		out.Reset()
+		out.WriteString(trimmed)
+	}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '-'
+}
@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"city": "Paris"}`:                                         `{"city":"Paris"}`,
+		"```json\n{\"city\": \"Paris\"}\n```":                       `{"city":"Paris"}`,
+		`Sure! Here is the call: {"city": "Paris"} Hope that helps`: `{"city":"Paris"}`,
+		`{'city': 'Paris', 'note': 'say "hi"'}`:                     `{"city":"Paris","note":"say \"hi\""}`,
+		`{city: "Paris", days: 3,}`:                                 `{"city":"Paris","days":3}`,
+		`{"metric": True, "extra": None}`:                           `{"metric":true,"extra":null}`,
+		`{"city": "Paris", "tags": ["a", "b"`:                       `{"city":"Paris","tags":["a","b"]}`,
+		`{"city": "Par`:                                             `{"city":"Par"}`,
+	}
+	for input, want := range cases {
+		got, ok := RepairJSON(input)
+		if !ok {
+			t.Errorf("%q: not repaired (got %q)", input, got)
+			continue
+		}
+		var gotValue, wantValue any
+		json.Unmarshal([]byte(got), &gotValue)
+		json.Unmarshal([]byte(want), &wantValue)
+		if !reflect.DeepEqual(gotValue, wantValue) {
+			t.Errorf("%q: got %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestRepairJSONGivesUpOnProse(t *testing.T) {
+	if _, ok := RepairJSON("I cannot help with that."); ok {
+		t.Error("prose should not be reported as valid JSON")
+	}
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coerce checks value against a JSON schema, converting values that are recoverably wrong —
+// numbers or booleans sent as strings, a scalar where an array is expected, enum values in
+// the wrong case — and dropping properties a closed object does not allow. It returns the
+// coerced value and any violations it could not fix. Supported keywords: type, properties,
+// required, additionalProperties, items and enum.
+func Coerce(value any, schema map[string]any) (any, []string) {
+	return coerce(value, schema, "$")
+}
+
+func coerce(value any, schema map[string]any, path string) (any, []string) {
+	if schema == nil {
+		return value, nil
+	}
+
+	types := schemaTypes(schema)
+	if len(types) > 0 && !typeMatches(value, types) {
+		converted, ok := convert(value, types)
+		if !ok {
+			return value, []string{fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonType(value))}
+		}
+		value = converted
+	}
+
+	var errs []string
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		for _, name := range requiredNames(schema) {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %q", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			propSchema, known := properties[key].(map[string]any)
+			if !known {
+				if closed, ok := schema["additionalProperties"].(bool); ok && !closed {
+					delete(v, key)
+				}
+				continue
+			}
+			var propErrs []string
+			v[key], propErrs = coerce(v[key], propSchema, path+"."+key)
+			errs = append(errs, propErrs...)
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i := range v {
+				var itemErrs []string
+				v[i], itemErrs = coerce(v[i], items, fmt.Sprintf("%s[%d]", path, i))
+				errs = append(errs, itemErrs...)
+			}
+		}
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		matched, ok := matchEnum(value, enum)
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, enum))
+		} else {
+			value = matched
+		}
+	}
+	return value, errs
+}
+
+func schemaTypes(schema map[string]any) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var types []string
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func requiredNames(schema map[string]any) []string {
+	list, _ := schema["required"].([]any)
+	var names []string
+	for _, item := range list {
+		if name, ok := item.(string); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func jsonType(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func typeMatches(value any, types []string) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func convert(value any, types []string) (any, bool) {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if s, ok := value.(string); ok {
+				if n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+					return float64(n), true
+				}
+			}
+		case "number":
+			if s, ok := value.(string); ok {
+				if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+					return n, true
+				}
+			}
+		case "boolean":
+			if s, ok := value.(string); ok {
+				if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+					return b, true
+				}
+			}
+		case "string":
+			switch v := value.(type) {
+			case float64:
+				return strconv.FormatFloat(v, 'f', -1, 64), true
+			case bool:
+				return strconv.FormatBool(v), true
+			}
+		case "array":
+			if value != nil {
+				return []any{value}, true
+			}
+		case "null":
+			if s, ok := value.(string); ok && (s == "" || s == "null") {
+				return nil, true
+			}
+		}
+	}
+	return value, false
+}
+
+func matchEnum(value any, enum []any) (any, bool) {
+	for _, option := range enum {
+		if option == value {
+			return option, true
+		}
+	}
+	if s, ok := value.(string); ok {
+		for _, option := range enum {
+			if o, ok := option.(string); ok && strings.EqualFold(o, strings.TrimSpace(s)) {
+				return o, true
+			}
+		}
+	}
+	return value, false
+}
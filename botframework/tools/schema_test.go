@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const weatherSchema = `{
+	"type": "object",
+	"properties": {
+		"city": {"type": "string"},
+		"days": {"type": "integer"},
+		"metric": {"type": "boolean"},
+		"unit": {"type": "string", "enum": ["celsius", "fahrenheit"]},
+		"tags": {"type": "array", "items": {"type": "string"}}
+	},
+	"required": ["city"],
+	"additionalProperties": false
+}`
+
+func decode(t *testing.T, s string) map[string]any {
+	t.Helper()
+	var v map[string]any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestCoerceFixesRecoverableValues(t *testing.T) {
+	schema := decode(t, weatherSchema)
+	value := decode(t, `{"city": "Paris", "days": "3", "metric": "true", "unit": "Celsius", "tags": "sunny", "mood": "happy"}`)
+
+	coerced, errs := Coerce(value, schema)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	want := decode(t, `{"city": "Paris", "days": 3, "metric": true, "unit": "celsius", "tags": ["sunny"]}`)
+	if !reflect.DeepEqual(coerced, want) {
+		t.Errorf("got %v, want %v", coerced, want)
+	}
+}
+
+func TestCoerceReportsUnfixableViolations(t *testing.T) {
+	schema := decode(t, weatherSchema)
+	_, errs := Coerce(decode(t, `{"days": "three", "unit": "kelvin"}`), schema)
+
+	joined := strings.Join(errs, "\n")
+	for _, want := range []string{`missing required property "city"`, "$.days: expected integer", "$.unit: kelvin is not one of"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing %q in %v", want, errs)
+		}
+	}
+}
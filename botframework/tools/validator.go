@@ -0,0 +1,279 @@
+package tools
+
+import (
+	"botframework/chat"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// RepairHeader reports what the validator did to a tool-calling response:
+// "repaired", "reasked=N" or "invalid"
+const RepairHeader = "X-BotFramework-Tool-Repair"
+
+// Function is a tool declared on a chat completion request
+type Function struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type tool struct {
+	Type     string   `json:"type"`
+	Function Function `json:"function"`
+}
+
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// Validator checks tool calls in non-streaming chat completions against the request's tool
+// schemas, repairing them where possible and re-asking the model up to MaxReasks times
+type Validator struct {
+	MaxReasks int
+}
+
+func NewValidator() *Validator {
+	return &Validator{MaxReasks: 1}
+}
+
+// Check repairs the tool calls in message (a chat completion choice message) in place.
+// It reports whether anything was changed and lists problems that remain.
+func (v *Validator) Check(message map[string]any, functions map[string]Function) (bool, []string) {
+	changed := false
+	rawCalls, _ := message["tool_calls"].([]any)
+
+	if len(rawCalls) == 0 {
+		// small models often write the call as JSON text instead of a tool_calls entry
+		content, _ := message["content"].(string)
+		call, ok := callFromContent(content, functions)
+		if !ok {
+			return false, nil
+		}
+		rawCalls = []any{call}
+		message["tool_calls"] = rawCalls
+		message["content"] = nil
+		changed = true
+	}
+
+	var problems []string
+	for i, raw := range rawCalls {
+		data, _ := json.Marshal(raw)
+		var call toolCall
+		if err := json.Unmarshal(data, &call); err != nil {
+			problems = append(problems, fmt.Sprintf("tool call %d is malformed", i))
+			continue
+		}
+
+		fn, ok := functions[call.Function.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown function %q", call.Function.Name))
+			continue
+		}
+
+		args := strings.TrimSpace(call.Function.Arguments)
+		if args == "" {
+			args = "{}"
+		}
+		repaired, valid := RepairJSON(args)
+		if !valid {
+			problems = append(problems, fmt.Sprintf("%s: arguments are not valid JSON", fn.Name))
+			continue
+		}
+		var value any
+		_ = json.Unmarshal([]byte(repaired), &value)
+		coerced, errs := Coerce(value, fn.Parameters)
+		for _, err := range errs {
+			problems = append(problems, fn.Name+": "+err)
+		}
+
+		normalized, _ := json.Marshal(coerced)
+		if string(normalized) != call.Function.Arguments {
+			changed = true
+		}
+		call.Function.Arguments = string(normalized)
+		if call.Type == "" {
+			call.Type = "function"
+		}
+		rawCalls[i] = call
+	}
+	return changed, problems
+}
+
+// callFromContent recognises {"name": ..., "arguments": {...}} written as message text
+func callFromContent(content string, functions map[string]Function) (toolCall, bool) {
+	if !strings.ContainsAny(content, "{") {
+		return toolCall{}, false
+	}
+	repaired, ok := RepairJSON(content)
+	if !ok {
+		return toolCall{}, false
+	}
+	var payload struct {
+		Name       string          `json:"name"`
+		Arguments  json.RawMessage `json:"arguments"`
+		Parameters json.RawMessage `json:"parameters"`
+	}
+	if json.Unmarshal([]byte(repaired), &payload) != nil {
+		return toolCall{}, false
+	}
+	if _, known := functions[payload.Name]; !known {
+		return toolCall{}, false
+	}
+
+	args := payload.Arguments
+	if len(args) == 0 {
+		args = payload.Parameters
+	}
+	var call toolCall
+	call.ID = newCallID()
+	call.Type = "function"
+	call.Function.Name = payload.Name
+	call.Function.Arguments = string(args)
+	// arguments may arrive as a JSON-encoded string
+	var encoded string
+	if json.Unmarshal(args, &encoded) == nil {
+		call.Function.Arguments = encoded
+	}
+	return call, true
+}
+
+func newCallID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "call_" + hex.EncodeToString(b)
+}
+
+// Middleware validates tool calls on chat completions that declare tools and are not streamed
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := chat.Read(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var declared []tool
+		var stream bool
+		req.Get("stream", &stream)
+		if stream || !req.Get("tools", &declared) || len(declared) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		functions := make(map[string]Function, len(declared))
+		for _, t := range declared {
+			functions[t.Function.Name] = t.Function
+		}
+
+		original := append([]chat.Message(nil), req.Messages...)
+		outcome := ""
+		var resp *bufferedResponse
+		for attempt := 0; ; attempt++ {
+			resp = &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+			next.ServeHTTP(resp, r.Clone(r.Context()))
+			if resp.status != http.StatusOK {
+				break
+			}
+
+			var completion map[string]any
+			if json.Unmarshal(resp.body.Bytes(), &completion) != nil {
+				break
+			}
+			choices, _ := completion["choices"].([]any)
+			var problems []string
+			var badMessage map[string]any
+			changed := false
+			for _, c := range choices {
+				choice, _ := c.(map[string]any)
+				message, _ := choice["message"].(map[string]any)
+				if message == nil {
+					continue
+				}
+				fixed, errs := v.Check(message, functions)
+				if fixed {
+					changed = true
+					if calls, _ := message["tool_calls"].([]any); len(calls) > 0 {
+						choice["finish_reason"] = "tool_calls"
+					}
+				}
+				if len(errs) > 0 && badMessage == nil {
+					badMessage = message
+				}
+				problems = append(problems, errs...)
+			}
+
+			if changed {
+				resp.setBody(completion)
+			}
+			if len(problems) == 0 {
+				if changed && outcome == "" {
+					outcome = "repaired"
+				}
+				break
+			}
+			if attempt >= v.MaxReasks {
+				outcome = "invalid"
+				break
+			}
+
+			outcome = "reasked=" + strconv.Itoa(attempt+1)
+			req.Messages = append(append([]chat.Message(nil), original...), reaskMessages(badMessage, problems)...)
+			if err := req.Write(r); err != nil {
+				break
+			}
+		}
+
+		for key, values := range resp.header {
+			w.Header()[key] = values
+		}
+		if outcome != "" {
+			w.Header().Set(RepairHeader, outcome)
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(resp.status)
+		_, _ = w.Write(resp.body.Bytes())
+	})
+}
+
+// reaskMessages shows the model its invalid call and what was wrong with it
+func reaskMessages(message map[string]any, problems []string) []chat.Message {
+	previous, _ := json.Marshal(message["tool_calls"])
+	if calls, _ := message["tool_calls"].([]any); len(calls) == 0 {
+		content, _ := message["content"].(string)
+		previous = []byte(content)
+	}
+	return []chat.Message{
+		{Role: "assistant", Content: string(previous)},
+		{Role: "user", Content: "That tool call was invalid:\n- " + strings.Join(problems, "\n- ") +
+			"\nCall the tool again with arguments that match its JSON schema exactly."},
+	}
+}
+
+// bufferedResponse holds a backend response so it can be inspected before reaching the client
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header         { return b.header }
+func (b *bufferedResponse) WriteHeader(status int)      { b.status = status }
+func (b *bufferedResponse) Write(p []byte) (int, error) { return b.body.Write(p) }
+
+func (b *bufferedResponse) setBody(v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	b.body.Reset()
+	b.body.Write(data)
+}
@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const toolRequest = `{"model":"m","messages":[{"role":"user","content":"weather in Paris?"}],
+"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"},"days":{"type":"integer"}},"required":["city"]}}}]}`
+
+func completion(message string) string {
+	return `{"id":"x","choices":[{"index":0,"finish_reason":"stop","message":` + message + `}]}`
+}
+
+func serveValidator(t *testing.T, responses ...string) (*httptest.ResponseRecorder, []string) {
+	t.Helper()
+	var bodies []string
+	backend := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(responses[min(len(bodies), len(responses))-1]))
+	})
+	rec := httptest.NewRecorder()
+	NewValidator().Middleware(backend).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(toolRequest)))
+	return rec, bodies
+}
+
+func firstCall(t *testing.T, body string) (toolCall, string) {
+	t.Helper()
+	var resp struct {
+		Choices []struct {
+			FinishReason string `json:"finish_reason"`
+			Message      struct {
+				ToolCalls []toolCall `json:"tool_calls"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil || len(resp.Choices[0].Message.ToolCalls) == 0 {
+		t.Fatalf("no tool call in %s", body)
+	}
+	return resp.Choices[0].Message.ToolCalls[0], resp.Choices[0].FinishReason
+}
+
+func TestValidatorRepairsArguments(t *testing.T) {
+	rec, bodies := serveValidator(t, completion(`{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"get_weather","arguments":"{city: 'Paris', days: '2',}"}}]}`))
+
+	call, _ := firstCall(t, rec.Body.String())
+	if call.Function.Arguments != `{"city":"Paris","days":2}` || len(bodies) != 1 {
+		t.Errorf("unexpected arguments %s after %d calls", call.Function.Arguments, len(bodies))
+	}
+	if rec.Header().Get(RepairHeader) != "repaired" {
+		t.Errorf("expected repaired header, got %q", rec.Header().Get(RepairHeader))
+	}
+}
+
+func TestValidatorConvertsContentToToolCall(t *testing.T) {
+	rec, _ := serveValidator(t, completion(`{"role":"assistant","content":"`+"```json\\n{\\\"name\\\": \\\"get_weather\\\", \\\"arguments\\\": {\\\"city\\\": \\\"Paris\\\"}}\\n```"+`"}`))
+
+	call, finish := firstCall(t, rec.Body.String())
+	if call.Function.Name != "get_weather" || call.Function.Arguments != `{"city":"Paris"}` || finish != "tool_calls" || call.ID == "" {
+		t.Errorf("unexpected call %+v finish=%s", call, finish)
+	}
+}
+
+func TestValidatorReasksOnce(t *testing.T) {
+	bad := completion(`{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"get_weather","arguments":"{\"days\": 2}"}}]}`)
+	good := completion(`{"role":"assistant","tool_calls":[{"id":"c2","type":"function","function":{"name":"get_weather","arguments":"{\"city\": \"Paris\"}"}}]}`)
+	rec, bodies := serveValidator(t, bad, good)
+
+	if len(bodies) != 2 || !strings.Contains(bodies[1], `missing required property \"city\"`) {
+		t.Fatalf("expected a re-ask describing the problem, got %v", bodies)
+	}
+	if call, _ := firstCall(t, rec.Body.String()); call.ID != "c2" {
+		t.Errorf("expected the re-asked call, got %+v", call)
+	}
+	if rec.Header().Get(RepairHeader) != "reasked=1" {
+		t.Errorf("unexpected header %q", rec.Header().Get(RepairHeader))
+	}
+
+	rec, bodies = serveValidator(t, bad, bad, bad)
+	if len(bodies) != 2 || rec.Header().Get(RepairHeader) != "invalid" {
+		t.Errorf("expected one bounded re-ask then invalid, got %d calls header %q", len(bodies), rec.Header().Get(RepairHeader))
+	}
+}
+
+func TestValidatorPassesThroughPlainAnswers(t *testing.T) {
+	rec, _ := serveValidator(t, completion(`{"role":"assistant","content":"It is sunny."}`))
+	if rec.Header().Get(RepairHeader) != "" || !strings.Contains(rec.Body.String(), "It is sunny.") {
+		t.Errorf("plain answer was modified: %s", rec.Body.String())
+	}
+}
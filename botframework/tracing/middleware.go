@@ -0,0 +1,197 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxTail is how much of the end of a response is kept, at least, to read its usage and
+// timings, which llama-server and the Python worker send in the last chunk of a stream
+const maxTail = 64 << 10
+
+// Middleware records a server span for every request, continuing the client's trace, and
+// forwards the span's context in the traceparent header so workers join the trace. When the
+// response reports the worker's timings, prefill and decode become child spans. Requests
+// whose client chose not to sample the trace pass through untouched.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote, ok := ParseTraceparent(r.Header.Get(TraceparentHeader))
+		if ok && !remote.Sampled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, span := t.Start(r.Context(), r.Method+" "+r.URL.Path, SpanKindServer, remote, time.Now())
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("url.path", r.URL.Path)
+		r.Header.Set(TraceparentHeader, span.Context.Traceparent())
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		end := time.Now()
+
+		span.SetAttribute("http.response.status_code", rec.status)
+		if rec.status >= 500 {
+			span.SetError(http.StatusText(rec.status))
+		}
+		if !rec.firstWrite.IsZero() {
+			span.AddEvent("first_byte", rec.firstWrite, nil)
+		}
+		t.annotate(span, rec, end)
+		span.Finish(end)
+	})
+}
+
+// workerReport is what a response says about the generation behind it
+type workerReport struct {
+	Model string `json:"model"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Timings *Timings `json:"timings"`
+}
+
+// Timings is the generation timing a worker reports in a response's "timings" field, in
+// llama-server's format
+type Timings struct {
+	PromptN     int     `json:"prompt_n"`
+	PromptMs    float64 `json:"prompt_ms"`
+	PredictedN  int     `json:"predicted_n"`
+	PredictedMs float64 `json:"predicted_ms"`
+}
+
+// annotate adds the model, token counts and timings the response reported. Prefill and
+// decode are laid out back to back ending when the last byte was written, since the worker
+// reports their durations but not when they started.
+func (t *Tracer) annotate(span *Span, rec *responseRecorder, end time.Time) {
+	report := parseReport(rec.tail.Bytes(), rec.Header().Get("Content-Type"))
+	if report.Model != "" {
+		span.SetAttribute("gen_ai.response.model", report.Model)
+	}
+	if report.Usage != nil {
+		span.SetAttribute("gen_ai.usage.input_tokens", report.Usage.PromptTokens)
+		span.SetAttribute("gen_ai.usage.output_tokens", report.Usage.CompletionTokens)
+	}
+	timings := report.Timings
+	if timings == nil || timings.PromptMs+timings.PredictedMs <= 0 {
+		return
+	}
+	if !rec.lastWrite.IsZero() {
+		end = rec.lastWrite
+	}
+	decodeStart := end.Add(-milliseconds(timings.PredictedMs))
+	prefillStart := decodeStart.Add(-milliseconds(timings.PromptMs))
+	if prefillStart.Before(span.Start) {
+		// the reported timings overlap the proxy's own; keep the children inside the request
+		prefillStart = span.Start
+	}
+	span.SetAttribute("llm.prefill_ms", timings.PromptMs)
+	span.SetAttribute("llm.decode_ms", timings.PredictedMs)
+
+	ctx := contextWithSpan(span)
+	if timings.PromptMs > 0 {
+		_, prefill := t.Start(ctx, "prefill", SpanKindInternal, SpanContext{}, prefillStart)
+		if timings.PromptN > 0 {
+			prefill.SetAttribute("llm.tokens", timings.PromptN)
+		}
+		prefill.Finish(later(decodeStart, prefillStart))
+	}
+	if timings.PredictedMs > 0 {
+		_, decode := t.Start(ctx, "decode", SpanKindInternal, SpanContext{}, later(decodeStart, prefillStart))
+		if timings.PredictedN > 0 {
+			decode.SetAttribute("llm.tokens", timings.PredictedN)
+		}
+		decode.Finish(end)
+	}
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// parseReport reads a JSON response, or the last reports in a stream of server-sent events
+func parseReport(tail []byte, contentType string) workerReport {
+	var report workerReport
+	if !strings.HasPrefix(contentType, "text/event-stream") {
+		_ = json.Unmarshal(tail, &report)
+		return report
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(tail))
+	scanner.Buffer(make([]byte, 0, 4096), 2*maxTail)
+	for scanner.Scan() {
+		data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+		if !ok {
+			continue
+		}
+		var chunk workerReport
+		if json.Unmarshal(bytes.TrimSpace(data), &chunk) != nil {
+			continue
+		}
+		if chunk.Model != "" {
+			report.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			report.Usage = chunk.Usage
+		}
+		if chunk.Timings != nil {
+			report.Timings = chunk.Timings
+		}
+	}
+	return report
+}
+
+// responseRecorder notes the status, when the first and last bytes were written and the
+// tail of the body
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	firstWrite  time.Time
+	lastWrite   time.Time
+	tail        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = code, true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	now := time.Now()
+	if r.firstWrite.IsZero() {
+		r.firstWrite = now
+	}
+	r.lastWrite = now
+	r.tail.Write(p)
+	// keep between maxTail and twice that, compacting rarely so long streams copy little
+	if r.tail.Len() > 2*maxTail {
+		kept := append([]byte(nil), r.tail.Bytes()[r.tail.Len()-maxTail:]...)
+		r.tail.Reset()
+		r.tail.Write(kept)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
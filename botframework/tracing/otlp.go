@@ -0,0 +1,241 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPending bounds the spans held for export; more are dropped while the collector is down
+const maxPending = 4096
+
+// exportBatch is the most spans sent in one request
+const exportBatch = 512
+
+// Exporter sends finished spans to an OTLP/HTTP collector as JSON, in batches
+type Exporter struct {
+	// Endpoint is the collector's traces URL, e.g. http://localhost:4318/v1/traces
+	Endpoint string
+	// Service is reported as the service.name resource attribute
+	Service string
+	// Header is set on every export request, e.g. for an API key
+	Header http.Header
+	Client *http.Client
+
+	mu      sync.Mutex
+	pending []*Span
+	dropped int
+}
+
+// NewExporter exports to endpoint, a collector's base URL ("http://localhost:4318") or its
+// full traces URL
+func NewExporter(endpoint, service string) *Exporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+	return &Exporter{
+		Endpoint: endpoint,
+		Service:  service,
+		Header:   make(http.Header),
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export queues span for the next flush
+func (e *Exporter) Export(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) >= maxPending {
+		e.dropped++
+		return
+	}
+	e.pending = append(e.pending, span)
+}
+
+// Run flushes queued spans every interval, and once more when ctx is done
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := e.Flush(flushCtx); err != nil {
+				slog.Warn("exporting traces failed", "err", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := e.Flush(ctx); err != nil {
+				slog.Warn("exporting traces failed", "err", err)
+			}
+		}
+	}
+}
+
+// Flush sends every queued span. Spans of a failed batch are dropped rather than retried,
+// so a missing collector cannot grow the queue.
+func (e *Exporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	spans, dropped := e.pending, e.dropped
+	e.pending, e.dropped = nil, 0
+	e.mu.Unlock()
+	if dropped > 0 {
+		slog.Warn("trace export queue full, spans dropped", "spans", dropped)
+	}
+
+	for len(spans) > 0 {
+		batch := spans[:min(exportBatch, len(spans))]
+		spans = spans[len(batch):]
+		if err := e.send(ctx, batch); err != nil {
+			return fmt.Errorf("%w (%d spans dropped)", err, len(batch)+len(spans))
+		}
+	}
+	return nil
+}
+
+func (e *Exporter) send(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range e.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned HTTP status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP JSON encoding, see opentelemetry-proto's trace/v1 ExportTraceServiceRequest. IDs are
+// hex strings and 64-bit integers are decimal strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              SpanKind        `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Events            []otlpEvent     `json:"events,omitempty"`
+		Status            otlpStatus      `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string          `json:"timeUnixNano"`
+		Name         string          `json:"name"`
+		Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"` // 1 ok, 2 error
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+func (e *Exporter) request(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mu.Lock()
+		s := otlpSpan{
+			TraceID:           span.Context.TraceID.String(),
+			SpanID:            span.Context.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: unixNano(span.Start),
+			EndTimeUnixNano:   unixNano(span.End),
+			Attributes:        attributes(span.Attributes),
+		}
+		if span.Parent != (SpanID{}) {
+			s.ParentSpanID = span.Parent.String()
+		}
+		for _, event := range span.Events {
+			s.Events = append(s.Events, otlpEvent{TimeUnixNano: unixNano(event.Time), Name: event.Name, Attributes: attributes(event.Attributes)})
+		}
+		if span.Error != "" {
+			s.Status = otlpStatus{Code: 2, Message: span.Error}
+		}
+		span.mu.Unlock()
+		encoded = append(encoded, s)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: attributes(map[string]any{"service.name": e.Service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "botframework"}, Spans: encoded}},
+	}}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// attributes encodes values as OTLP AnyValues, sorted by key
+func attributes(values map[string]any) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(values))
+	for key, value := range values {
+		var v map[string]any
+		switch value := value.(type) {
+		case string:
+			v = map[string]any{"stringValue": value}
+		case bool:
+			v = map[string]any{"boolValue": value}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(value)}
+		case int64:
+			v = map[string]any{"intValue": strconv.FormatInt(value, 10)}
+		case float64:
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			v = map[string]any{"doubleValue": value}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(value)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: key, Value: v})
+	}
+	sort.Slice(encoded, func(i, j int) bool { return encoded[i].Key < encoded[j].Key })
+	return encoded
+}
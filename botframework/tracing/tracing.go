@@ -0,0 +1,185 @@
+// Package tracing records OpenTelemetry spans for inference requests, carries W3C trace
+// context to workers and exports the spans over OTLP/HTTP, so a trace viewer such as Jaeger
+// shows a request's latency end to end.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader carries W3C trace context from clients, to workers and back
+const TraceparentHeader = "traceparent"
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// SpanContext identifies a span within its trace
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// Valid reports whether both IDs are set; W3C forbids all-zero IDs
+func (c SpanContext) Valid() bool {
+	return c.TraceID != TraceID{} && c.SpanID != SpanID{}
+}
+
+// Traceparent formats c as a traceparent header value
+func (c SpanContext) Traceparent() string {
+	flags := 0
+	if c.Sampled {
+		flags = 1
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", c.TraceID, c.SpanID, flags)
+}
+
+// ParseTraceparent reads a traceparent header value, "00-<trace id>-<span id>-<flags>"
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var c SpanContext
+	if len(value) < 55 || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return c, false
+	}
+	// version ff is invalid; later versions may append fields after the flags
+	if value[:2] == "ff" || (value[:2] == "00" && len(value) != 55) || (len(value) > 55 && value[55] != '-') {
+		return c, false
+	}
+	var version, flags [1]byte
+	if _, err := hex.Decode(version[:], []byte(value[:2])); err != nil {
+		return c, false
+	}
+	if _, err := hex.Decode(c.TraceID[:], []byte(value[3:35])); err != nil {
+		return c, false
+	}
+	if _, err := hex.Decode(c.SpanID[:], []byte(value[36:52])); err != nil {
+		return c, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(value[53:55])); err != nil {
+		return c, false
+	}
+	c.Sampled = flags[0]&1 == 1
+	return c, c.Valid()
+}
+
+// SpanKind is the OTLP span kind
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Event is a point in time within a span
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]any
+}
+
+// Span is one timed operation. Its fields are safe to read once Finish has been called.
+type Span struct {
+	Name       string
+	Kind       SpanKind
+	Context    SpanContext
+	Parent     SpanID // zero for a trace's root span
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]any
+	Events     []Event
+	// Error is the status message of a failed span
+	Error string
+
+	mu     sync.Mutex
+	tracer *Tracer
+}
+
+// SetAttribute records key on the span; values are strings, bools, ints or float64s
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// AddEvent records a named point in time
+func (s *Span) AddEvent(name string, at time.Time, attributes map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Events = append(s.Events, Event{Name: name, Time: at, Attributes: attributes})
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Error = message
+}
+
+// Finish ends the span at end and hands it to the exporter
+func (s *Span) Finish(end time.Time) {
+	s.mu.Lock()
+	s.End = end
+	s.mu.Unlock()
+	if s.tracer != nil && s.Context.Sampled {
+		s.tracer.exporter.Export(s)
+	}
+}
+
+// Tracer starts spans and sends the finished ones to its exporter
+type Tracer struct {
+	exporter *Exporter
+}
+
+func NewTracer(exporter *Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Start begins a span at start. It continues the trace of the span in ctx, or else of
+// remote, the context a client sent; with neither it begins a new, sampled trace.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind, remote SpanContext, start time.Time) (context.Context, *Span) {
+	span := &Span{Name: name, Kind: kind, Start: start, Attributes: make(map[string]any), tracer: t}
+	parent := remote
+	if current := SpanFromContext(ctx); current != nil {
+		parent = current.Context
+	}
+	if parent.Valid() {
+		span.Context.TraceID, span.Context.Sampled = parent.TraceID, parent.Sampled
+		span.Parent = parent.SpanID
+	} else {
+		rand.Read(span.Context.TraceID[:])
+		span.Context.Sampled = true
+	}
+	rand.Read(span.Context.SpanID[:])
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type spanKey struct{}
+
+// contextWithSpan returns a context carrying only span, to start its children from
+func contextWithSpan(span *Span) context.Context {
+	return context.WithValue(context.Background(), spanKey{}, span)
+}
+
+// SpanFromContext returns the span ctx carries, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// Traceparent returns the traceparent header value for the span ctx carries, or ""
+func Traceparent(ctx context.Context) string {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.Context.Traceparent()
+	}
+	return ""
+}
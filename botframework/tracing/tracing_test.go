@@ -0,0 +1,144 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var timeZero = time.Unix(0, 0)
+
+func TestParseTraceparent(t *testing.T) {
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	c, ok := ParseTraceparent(header)
+	if !ok || !c.Sampled || c.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || c.SpanID.String() != "00f067aa0ba902b7" {
+		t.Fatalf("ParseTraceparent(%q) = %+v, %v", header, c, ok)
+	}
+	if c.Traceparent() != header {
+		t.Errorf("Traceparent() = %q, want %q", c.Traceparent(), header)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, ok := ParseTraceparent(bad); ok {
+			t.Errorf("%q should be rejected", bad)
+		}
+	}
+	if _, ok := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); !ok {
+		t.Error("later versions may carry extra fields")
+	}
+}
+
+func TestMiddlewareContinuesTraceAndRecordsTimings(t *testing.T) {
+	exporter := NewExporter("http://collector", "botframework")
+	tracer := NewTracer(exporter)
+	var forwarded string
+	handler := tracer.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(TraceparentHeader)
+		time.Sleep(80 * time.Millisecond) // longer than the reported timings
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"model\":\"phi-3\",\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n")
+		io.WriteString(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3},\"timings\":{\"prompt_n\":12,\"prompt_ms\":40,\"predicted_n\":3,\"predicted_ms\":25}}\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`))
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	parent, ok := ParseTraceparent(forwarded)
+	if !ok || parent.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || parent.SpanID.String() == "00f067aa0ba902b7" {
+		t.Fatalf("worker got traceparent %q, want the request span in the client's trace", forwarded)
+	}
+	spans := exporter.pending
+	if len(spans) != 3 {
+		t.Fatalf("exported %d spans, want prefill, decode and the request", len(spans))
+	}
+	byName := make(map[string]*Span)
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	server := byName["POST /v1/chat/completions"]
+	if server == nil || server.Context.SpanID != parent.SpanID || server.Parent.String() != "00f067aa0ba902b7" {
+		t.Fatalf("request span %+v does not continue the client's trace", server)
+	}
+	if server.Attributes["gen_ai.response.model"] != "phi-3" || server.Attributes["gen_ai.usage.output_tokens"] != 3 {
+		t.Errorf("request span attributes %v", server.Attributes)
+	}
+	prefill, decode := byName["prefill"], byName["decode"]
+	if prefill == nil || decode == nil || prefill.Parent != server.Context.SpanID || decode.Parent != server.Context.SpanID {
+		t.Fatal("prefill and decode should be children of the request span")
+	}
+	if prefill.End.After(decode.Start) || decode.End.Sub(decode.Start).Milliseconds() != 25 {
+		t.Errorf("prefill %v-%v and decode %v-%v are not laid out back to back", prefill.Start, prefill.End, decode.Start, decode.End)
+	}
+}
+
+func TestMiddlewareSkipsUnsampledTraces(t *testing.T) {
+	exporter := NewExporter("http://collector", "botframework")
+	handler := NewTracer(exporter).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if len(exporter.pending) != 0 {
+		t.Errorf("an unsampled trace exported %d spans", len(exporter.pending))
+	}
+}
+
+func TestExporterSendsOTLPJSON(t *testing.T) {
+	var got otlpRequest
+	var path, auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer collector.Close()
+
+	exporter := NewExporter(collector.URL, "botframework")
+	exporter.Header.Set("Authorization", "Bearer secret")
+	tracer := NewTracer(exporter)
+	_, span := tracer.Start(context.Background(), "GET /v1/models", SpanKindServer, SpanContext{}, timeZero)
+	span.SetAttribute("http.response.status_code", 502)
+	span.SetError("Bad Gateway")
+	span.Finish(timeZero.Add(1500))
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/v1/traces" || auth != "Bearer secret" {
+		t.Errorf("export went to %q with Authorization %q", path, auth)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request %+v", got)
+	}
+	if attrs := got.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || attrs[0].Value["stringValue"] != "botframework" {
+		t.Errorf("resource attributes %+v", attrs)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	s := spans[0]
+	if len(s.TraceID) != 32 || len(s.SpanID) != 16 || s.ParentSpanID != "" || s.Kind != SpanKindServer || s.Status.Code != 2 {
+		t.Errorf("unexpected span %+v", s)
+	}
+	if s.EndTimeUnixNano != "1500" || s.Attributes[0].Value["intValue"] != "502" {
+		t.Errorf("span times or attributes not encoded as OTLP expects: %+v", s)
+	}
+	if len(exporter.pending) != 0 {
+		t.Error("flushed spans are still queued")
+	}
+}
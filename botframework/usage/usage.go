@@ -0,0 +1,144 @@
+// Package usage reads the token usage an inference response reports, for the middleware
+// that bills, limits or records requests by the tokens they used.
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// maxCapture bounds the response body buffered to read its token usage
+const maxCapture = 1 << 20
+
+// Writer captures the response status and enough of the body to read its token usage,
+// from OpenAI and Anthropic responses and event streams or Ollama responses and NDJSON
+// streams
+type Writer struct {
+	http.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	pending []byte
+	usage   event // of the last event that reported any
+	chunks  int
+}
+
+// NewWriter wraps w to read the usage of the response written to it
+func NewWriter(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w}
+}
+
+// event is the token usage a response or stream event may report
+type event struct {
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+		// the Anthropic Messages API reports these instead
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (e event) tokens() (int, int, bool) {
+	switch {
+	case e.Usage != nil && e.Usage.InputTokens+e.Usage.OutputTokens > 0:
+		return e.Usage.InputTokens, e.Usage.OutputTokens, true
+	case e.Usage != nil && e.Usage.PromptTokens+e.Usage.CompletionTokens == 0:
+		// embeddings report only prompt and total tokens
+		return e.Usage.TotalTokens, 0, e.Usage.TotalTokens > 0
+	case e.Usage != nil:
+		return e.Usage.PromptTokens, e.Usage.CompletionTokens, true
+	}
+	return e.PromptEvalCount, e.EvalCount, e.PromptEvalCount+e.EvalCount > 0
+}
+
+// stream reports how lines of the response carry events: "data:" lines, every line, or
+// not at all when the response is a single document
+func (u *Writer) stream() (prefix string, ok bool) {
+	switch contentType := u.Header().Get("Content-Type"); {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		return "data:", true
+	case strings.HasPrefix(contentType, "application/x-ndjson"):
+		return "", true
+	}
+	return "", false
+}
+
+func (u *Writer) WriteHeader(code int) {
+	if u.status == 0 {
+		u.status = code
+	}
+	u.ResponseWriter.WriteHeader(code)
+}
+
+func (u *Writer) Write(p []byte) (int, error) {
+	if u.status == 0 {
+		u.status = http.StatusOK
+	}
+	prefix, streaming := u.stream()
+	if !streaming {
+		if u.body.Len() < maxCapture {
+			u.body.Write(p[:min(len(p), maxCapture-u.body.Len())])
+		}
+		return u.ResponseWriter.Write(p)
+	}
+	u.pending = append(u.pending, p...)
+	for {
+		end := bytes.IndexByte(u.pending, '\n')
+		if end < 0 {
+			break
+		}
+		line := bytes.TrimSpace(u.pending[:end])
+		u.pending = u.pending[end+1:]
+		data, ok := bytes.CutPrefix(line, []byte(prefix))
+		if !ok || len(data) == 0 || string(bytes.TrimSpace(data)) == "[DONE]" {
+			continue
+		}
+		u.chunks++
+		var e event
+		if json.Unmarshal(data, &e) == nil {
+			if _, _, reported := e.tokens(); reported {
+				u.usage = e
+			}
+		}
+	}
+	return u.ResponseWriter.Write(p)
+}
+
+func (u *Writer) Flush() {
+	if f, ok := u.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (u *Writer) Unwrap() http.ResponseWriter {
+	return u.ResponseWriter
+}
+
+// Status returns the status code the response was written with, 0 before it was written
+func (u *Writer) Status() int {
+	return u.status
+}
+
+// Tokens returns the prompt and completion tokens the response reported, and whether it
+// reported any: a document's usage, or that of the last stream event carrying some
+func (u *Writer) Tokens() (prompt, completion int, reported bool) {
+	if _, streaming := u.stream(); streaming {
+		return u.usage.tokens()
+	}
+	var payload event
+	if json.Unmarshal(u.body.Bytes(), &payload) != nil {
+		return 0, 0, false
+	}
+	return payload.tokens()
+}
+
+// Chunks returns the number of events a stream carried, 0 for a single document. Streams
+// that never report usage are counted at one completion token per event.
+func (u *Writer) Chunks() int {
+	return u.chunks
+}
@@ -0,0 +1,37 @@
+package usage
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriterReadsOllamaStreams(t *testing.T) {
+	u := NewWriter(httptest.NewRecorder())
+	u.Header().Set("Content-Type", "application/x-ndjson")
+	_, _ = u.Write([]byte(`{"response":"Hi","done":false}` + "\n"))
+	_, _ = u.Write([]byte(`{"response":"","done":true,"prompt_eval_count":9,"eval_count":3}` + "\n"))
+	if prompt, completion, reported := u.Tokens(); prompt != 9 || completion != 3 || !reported {
+		t.Fatalf("tokens = %d, %d, %v; want 9, 3, true", prompt, completion, reported)
+	}
+}
+
+func TestWriterCountsChunksOfStreamsWithoutUsage(t *testing.T) {
+	u := NewWriter(httptest.NewRecorder())
+	u.Header().Set("Content-Type", "text/event-stream")
+	_, _ = u.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"b\"}}]}\n\ndata: [DONE]\n\n"))
+	if _, _, reported := u.Tokens(); reported {
+		t.Fatal("a stream without usage reported some")
+	}
+	if u.Chunks() != 2 || u.Status() != 200 {
+		t.Fatalf("chunks = %d, status = %d; want 2, 200", u.Chunks(), u.Status())
+	}
+}
+
+func TestWriterReadsEmbeddingUsage(t *testing.T) {
+	u := NewWriter(httptest.NewRecorder())
+	u.Header().Set("Content-Type", "application/json")
+	_, _ = u.Write([]byte(`{"data":[],"usage":{"prompt_tokens":0,"total_tokens":7}}`))
+	if prompt, completion, reported := u.Tokens(); prompt != 7 || completion != 0 || !reported {
+		t.Fatalf("tokens = %d, %d, %v; want 7, 0, true", prompt, completion, reported)
+	}
+}
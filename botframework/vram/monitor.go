@@ -0,0 +1,328 @@
+// Package vram watches GPU memory while the manager runs and applies backpressure when free
+// VRAM runs low, before a worker hits an out-of-memory error.
+package vram
+
+import (
+	"botframework/chat"
+	"botframework/openai"
+	"botframework/profiler"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Action is what the monitor does while free VRAM is below the threshold
+type Action string
+
+const (
+	// ActionPause rejects new inference requests with 503 until memory frees up
+	ActionPause Action = "pause"
+	// ActionShrink shrinks chat context windows and caps max_tokens, so the KV cache grows less
+	ActionShrink Action = "shrink"
+	// ActionAlert logs the transition and posts it to AlertURL
+	ActionAlert Action = "alert"
+)
+
+// ShrunkHeader is set on responses whose request was shrunk to ease memory pressure
+const ShrunkHeader = "X-BotFramework-VRAM-Shrunk"
+
+// ParseActions reads a comma-separated action list, e.g. "pause,alert"
+func ParseActions(list string) ([]Action, error) {
+	var actions []Action
+	for _, name := range strings.Split(list, ",") {
+		switch action := Action(strings.TrimSpace(name)); action {
+		case "":
+		case ActionPause, ActionShrink, ActionAlert:
+			actions = append(actions, action)
+		default:
+			return nil, fmt.Errorf("unknown VRAM action %q", name)
+		}
+	}
+	return actions, nil
+}
+
+// Event is posted to AlertURL when memory pressure starts or ends
+type Event struct {
+	Pressure bool                 `json:"pressure"`
+	GPUs     []profiler.GPUMemory `json:"gpus"`
+	Time     time.Time            `json:"time"`
+}
+
+// Status is the monitor's latest reading
+type Status struct {
+	GPUs           []profiler.GPUMemory `json:"gpus"`
+	SampledAt      time.Time            `json:"sampled_at"`
+	MinFreeMB      int                  `json:"min_free_mb"`
+	MinFreePercent float64              `json:"min_free_percent"`
+	Actions        []Action             `json:"actions"`
+	Pressure       bool                 `json:"pressure"`
+	PressureSince  *time.Time           `json:"pressure_since,omitempty"`
+	Episodes       int                  `json:"episodes"`
+	Paused         uint64               `json:"paused_requests"`
+	Shrunk         uint64               `json:"shrunk_requests"`
+}
+
+// Monitor polls GPU memory. A GPU is under pressure when its free memory drops below
+// MinFreeMB or MinFreePercent of its total; pressure ends once every GPU has 10% more
+// headroom than the threshold, so the actions do not flap around it.
+type Monitor struct {
+	MinFreeMB      int
+	MinFreePercent float64
+	Actions        []Action
+	// ShrinkFactor scales context windows while shrinking
+	ShrinkFactor float64
+	// ShrinkMaxTokens caps max_tokens while shrinking
+	ShrinkMaxTokens int
+	AlertURL        string
+	Client          *http.Client
+
+	sample func() []profiler.GPUMemory
+
+	mu        sync.Mutex
+	gpus      []profiler.GPUMemory
+	sampledAt time.Time
+	pressure  bool
+	since     time.Time
+	episodes  int
+	paused    uint64
+	shrunk    uint64
+	interval  time.Duration
+}
+
+func NewMonitor(sample func() []profiler.GPUMemory) *Monitor {
+	return &Monitor{
+		MinFreePercent:  5,
+		Actions:         []Action{ActionAlert},
+		ShrinkFactor:    0.5,
+		ShrinkMaxTokens: 256,
+		Client:          &http.Client{Timeout: 10 * time.Second},
+		sample:          sample,
+	}
+}
+
+// Run samples every interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	m.mu.Lock()
+	m.interval = interval
+	m.mu.Unlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Sample(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sample takes one reading and applies the actions when the pressure state changes
+func (m *Monitor) Sample(ctx context.Context) {
+	gpus := m.sample()
+	if len(gpus) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	was := m.pressure
+	m.gpus, m.sampledAt = gpus, time.Now()
+	if was {
+		m.pressure = !m.recovered(gpus)
+	} else {
+		m.pressure = m.exhausted(gpus)
+	}
+	changed := m.pressure != was
+	if changed && m.pressure {
+		m.since = m.sampledAt
+		m.episodes++
+	}
+	m.mu.Unlock()
+
+	if changed {
+		m.notify(ctx, Event{Pressure: !was, GPUs: gpus, Time: time.Now()})
+	}
+}
+
+// exhausted reports whether any GPU is below the threshold; callers hold m.mu
+func (m *Monitor) exhausted(gpus []profiler.GPUMemory) bool {
+	for _, gpu := range gpus {
+		if gpu.FreeMB() < m.thresholdMB(gpu, 1) {
+			return true
+		}
+	}
+	return false
+}
+
+// recovered reports whether every GPU is clear of the threshold plus a 10% margin
+func (m *Monitor) recovered(gpus []profiler.GPUMemory) bool {
+	for _, gpu := range gpus {
+		if gpu.FreeMB() < m.thresholdMB(gpu, 1.1) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Monitor) thresholdMB(gpu profiler.GPUMemory, scale float64) int {
+	threshold := max(float64(m.MinFreeMB), float64(gpu.TotalMB)*m.MinFreePercent/100)
+	return int(threshold * scale)
+}
+
+func (m *Monitor) has(action Action) bool {
+	for _, a := range m.Actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Monitor) notify(ctx context.Context, event Event) {
+	if event.Pressure {
+		slog.Warn("free VRAM below threshold", "gpus", describe(event.GPUs), "actions", m.Actions)
+	} else {
+		slog.Info("free VRAM recovered", "gpus", describe(event.GPUs))
+	}
+	if !m.has(ActionAlert) || m.AlertURL == "" {
+		return
+	}
+	if err := m.post(ctx, event); err != nil {
+		slog.WarnContext(ctx, "VRAM alert not sent", "err", err)
+	}
+}
+
+func (m *Monitor) post(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.AlertURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func describe(gpus []profiler.GPUMemory) string {
+	parts := make([]string, len(gpus))
+	for i, gpu := range gpus {
+		parts[i] = fmt.Sprintf("GPU %d: %d/%d MB free", gpu.Index, gpu.FreeMB(), gpu.TotalMB)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Pressure reports whether free VRAM is currently below the threshold
+func (m *Monitor) Pressure() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pressure
+}
+
+// Status returns the latest reading
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := Status{
+		GPUs:           append([]profiler.GPUMemory(nil), m.gpus...),
+		SampledAt:      m.sampledAt,
+		MinFreeMB:      m.MinFreeMB,
+		MinFreePercent: m.MinFreePercent,
+		Actions:        m.Actions,
+		Pressure:       m.pressure,
+		Episodes:       m.episodes,
+		Paused:         m.paused,
+		Shrunk:         m.shrunk,
+	}
+	if m.pressure {
+		since := m.since
+		status.PressureSince = &since
+	}
+	return status
+}
+
+// Window wraps a context window lookup so windows shrink by ShrinkFactor under pressure.
+// fallback is used for models the lookup does not know.
+func (m *Monitor) Window(window func(model string) int, fallback int) func(model string) int {
+	return func(model string) int {
+		size := 0
+		if window != nil {
+			size = window(model)
+		}
+		if size <= 0 {
+			size = fallback
+		}
+		if size > 0 && m.has(ActionShrink) && m.Pressure() {
+			return int(float64(size) * m.ShrinkFactor)
+		}
+		return size
+	}
+}
+
+// Middleware applies the pause and shrink actions to inference requests under pressure
+func (m *Monitor) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !m.Pressure() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if m.has(ActionPause) {
+			m.mu.Lock()
+			m.paused++
+			retry := max(1, int(m.interval.Seconds()))
+			m.mu.Unlock()
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			openai.WriteError(w, http.StatusServiceUnavailable, "server_error", "vram_exhausted",
+				"GPU memory is nearly exhausted; retry later")
+			return
+		}
+		if m.has(ActionShrink) {
+			m.shrink(w, r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// shrink caps max_tokens on chat completions
+func (m *Monitor) shrink(w http.ResponseWriter, r *http.Request) {
+	req, err := chat.Read(r)
+	if err != nil || m.ShrinkMaxTokens <= 0 {
+		return
+	}
+	var maxTokens int
+	field := "max_tokens"
+	if req.Get("max_completion_tokens", &maxTokens) {
+		field = "max_completion_tokens"
+	} else {
+		req.Get("max_tokens", &maxTokens)
+	}
+	if maxTokens > 0 && maxTokens <= m.ShrinkMaxTokens {
+		return
+	}
+	if err := req.Set(field, m.ShrinkMaxTokens); err != nil {
+		return
+	}
+	if err := req.Write(r); err != nil {
+		return
+	}
+	m.mu.Lock()
+	m.shrunk++
+	m.mu.Unlock()
+	w.Header().Set(ShrunkHeader, strconv.Itoa(m.ShrinkMaxTokens))
+}
@@ -0,0 +1,156 @@
+package vram
+
+import (
+	"botframework/profiler"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeGPU reports a single 10 GB GPU with the given free memory
+type fakeGPU struct{ freeMB int }
+
+func (g *fakeGPU) sample() []profiler.GPUMemory {
+	return []profiler.GPUMemory{{Index: 0, TotalMB: 10000, UsedMB: 10000 - g.freeMB}}
+}
+
+func TestPressureHysteresis(t *testing.T) {
+	gpu := &fakeGPU{freeMB: 5000}
+	m := NewMonitor(gpu.sample)
+	m.MinFreeMB = 1000
+	ctx := context.Background()
+
+	steps := []struct {
+		freeMB int
+		want   bool
+	}{
+		{5000, false},
+		{900, true},  // below the threshold
+		{1050, true}, // above it, but inside the 10% margin
+		{1200, false},
+		{999, true},
+	}
+	for _, step := range steps {
+		gpu.freeMB = step.freeMB
+		m.Sample(ctx)
+		if got := m.Pressure(); got != step.want {
+			t.Fatalf("free %d MB: pressure = %v, want %v", step.freeMB, got, step.want)
+		}
+	}
+	if status := m.Status(); status.Episodes != 2 || status.PressureSince == nil {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestPercentThreshold(t *testing.T) {
+	gpu := &fakeGPU{freeMB: 400}
+	m := NewMonitor(gpu.sample)
+	m.Sample(context.Background())
+	if !m.Pressure() {
+		t.Error("4% free should be below the default 5% threshold")
+	}
+}
+
+func TestPauseRejectsRequests(t *testing.T) {
+	gpu := &fakeGPU{freeMB: 100}
+	m := NewMonitor(gpu.sample)
+	m.Actions = []Action{ActionPause}
+	m.Sample(context.Background())
+
+	served := false
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = true }))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`)))
+	if served || rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("served = %v, status = %d, headers = %v", served, rec.Code, rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if !served {
+		t.Error("reads should pass while paused")
+	}
+
+	gpu.freeMB = 9000
+	m.Sample(context.Background())
+	served = false
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`)))
+	if !served {
+		t.Error("requests should resume once memory recovers")
+	}
+}
+
+func TestShrinkCapsMaxTokensAndWindow(t *testing.T) {
+	gpu := &fakeGPU{freeMB: 100}
+	m := NewMonitor(gpu.sample)
+	m.Actions = []Action{ActionShrink}
+	m.ShrinkMaxTokens = 128
+
+	window := m.Window(func(model string) int {
+		if model == "known" {
+			return 8192
+		}
+		return 0
+	}, 4096)
+	if window("known") != 8192 {
+		t.Fatal("windows should be untouched without pressure")
+	}
+	m.Sample(context.Background())
+	if window("known") != 4096 || window("other") != 2048 {
+		t.Errorf("shrunk windows = %d, %d", window("known"), window("other"))
+	}
+
+	var body map[string]any
+	handler := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions",
+		strings.NewReader(`{"model":"known","max_tokens":2000,"messages":[{"role":"user","content":"hi"}]}`)))
+	if body["max_tokens"] != float64(128) || rec.Header().Get(ShrunkHeader) != "128" {
+		t.Errorf("body = %v, headers = %v", body, rec.Header())
+	}
+	if m.Status().Shrunk != 1 {
+		t.Errorf("shrunk = %d", m.Status().Shrunk)
+	}
+}
+
+func TestAlertPostsTransitions(t *testing.T) {
+	var events []Event
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		events = append(events, event)
+	}))
+	defer ts.Close()
+
+	gpu := &fakeGPU{freeMB: 100}
+	m := NewMonitor(gpu.sample)
+	m.AlertURL = ts.URL
+	m.Sample(context.Background())
+	m.Sample(context.Background())
+	gpu.freeMB = 9000
+	m.Sample(context.Background())
+
+	if len(events) != 2 || !events[0].Pressure || events[1].Pressure {
+		t.Fatalf("events = %+v", events)
+	}
+	if events[0].GPUs[0].FreeMB() != 100 {
+		t.Errorf("alert GPUs = %+v", events[0].GPUs)
+	}
+}
+
+func TestParseActions(t *testing.T) {
+	actions, err := ParseActions("pause, shrink,alert")
+	if err != nil || len(actions) != 3 {
+		t.Fatalf("actions = %v, err = %v", actions, err)
+	}
+	if _, err := ParseActions("pause,reboot"); err == nil {
+		t.Error("unknown actions should be rejected")
+	}
+}
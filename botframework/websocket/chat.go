@@ -0,0 +1,376 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChatPath is the route ChatHandler is served on
+const ChatPath = "/ws/chat"
+
+// Defaults for ChatHandler
+const (
+	DefaultPingInterval  = 30 * time.Second
+	DefaultMaxConcurrent = 4
+)
+
+// ChatHandler streams chat completions over WebSocket. Each text message from the client is
+// an OpenAI chat completion request, optionally carrying an "id" that is echoed on every
+// frame of its reply (one is assigned otherwise); {"type": "cancel", "id": ...} aborts a
+// reply. The handler answers with frames of three types:
+//
+//	{"type": "delta", "id": "c1", "content": "Hel"}           (with "index" for n > 1)
+//	{"type": "done", "id": "c1", "finish_reason": "stop", "usage": {...}}
+//	{"type": "error", "id": "c1", "status": 429, "message": "..."}
+//
+// Requests run through Backend, the OpenAI-compatible inference handler, so they get the
+// same defaults, retrieval and transforms as /v1/chat/completions.
+type ChatHandler struct {
+	Backend http.Handler
+	// Origins lists the browser origins allowed to connect; "*" allows any. Without it only
+	// same-host pages and clients sending no Origin may connect.
+	Origins []string
+	// PingInterval is how often the server pings; a client silent for two intervals is dropped
+	PingInterval time.Duration
+	// MaxConcurrent bounds the replies streaming at once on one connection
+	MaxConcurrent int
+
+	ctx context.Context // closes every connection with "going away" when done
+}
+
+func NewChatHandler(ctx context.Context, backend http.Handler) *ChatHandler {
+	return &ChatHandler{Backend: backend, PingInterval: DefaultPingInterval, MaxConcurrent: DefaultMaxConcurrent, ctx: ctx}
+}
+
+// Frame is a message the server sends
+type Frame struct {
+	Type         string          `json:"type"`
+	ID           string          `json:"id"`
+	Index        int             `json:"index,omitempty"`
+	Content      string          `json:"content,omitempty"`
+	ToolCalls    json.RawMessage `json:"tool_calls,omitempty"`
+	FinishReason string          `json:"finish_reason,omitempty"`
+	Usage        json.RawMessage `json:"usage,omitempty"`
+	Status       int             `json:"status,omitempty"`
+	Message      string          `json:"message,omitempty"`
+}
+
+// allowOrigin reports whether the page at origin may open a socket to host
+func (h *ChatHandler) allowOrigin(origin, host string) bool {
+	if origin == "" || slices.Contains(h.Origins, "*") || slices.Contains(h.Origins, origin) {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(parsed.Host, host)
+}
+
+func (h *ChatHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.allowOrigin(r.Header.Get("Origin"), r.Host) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	conn, err := Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	interval := h.PingInterval
+	if interval <= 0 {
+		interval = DefaultPingInterval
+	}
+	session := &chatSession{
+		handler: h,
+		conn:    conn,
+		header:  r.Header,
+		remote:  r.RemoteAddr,
+		running: make(map[string]context.CancelFunc),
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if h.ctx != nil {
+		stop := context.AfterFunc(h.ctx, func() { conn.Close(CloseGoingAway, "server shutting down") })
+		defer stop()
+	}
+
+	extend := func() { _ = conn.SetReadDeadline(time.Now().Add(2 * interval)) }
+	conn.OnPong = extend
+	extend()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if conn.WriteMessage(OpPing, nil) != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		opcode, message, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		extend()
+		if opcode != OpText {
+			conn.Close(CloseUnsupported, "send JSON text messages")
+			break
+		}
+		session.handle(ctx, message)
+	}
+	cancel()
+	session.wait.Wait()
+	conn.Close(CloseNormal, "")
+}
+
+// chatSession is one client's connection and the replies streaming on it
+type chatSession struct {
+	handler *ChatHandler
+	conn    *Conn
+	header  http.Header
+	remote  string
+
+	mu      sync.Mutex
+	running map[string]context.CancelFunc
+	next    int
+	wait    sync.WaitGroup
+}
+
+func (s *chatSession) send(frame Frame) {
+	data, err := json.Marshal(frame)
+	if err == nil {
+		err = s.conn.WriteMessage(OpText, data)
+	}
+	if err != nil {
+		slog.Debug("websocket frame not sent", "id", frame.ID, "err", err)
+	}
+}
+
+// handle starts the reply to a request, or cancels one
+func (s *chatSession) handle(ctx context.Context, message []byte) {
+	var body map[string]any
+	if err := json.Unmarshal(message, &body); err != nil {
+		s.send(Frame{Type: "error", Status: http.StatusBadRequest, Message: "invalid JSON: " + err.Error()})
+		return
+	}
+	id, _ := body["id"].(string)
+	kind, _ := body["type"].(string)
+	delete(body, "id")
+	delete(body, "type")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if kind == "cancel" {
+		if cancel, ok := s.running[id]; ok {
+			cancel()
+		}
+		return
+	}
+	if kind != "" && kind != "chat" {
+		s.send(Frame{Type: "error", ID: id, Status: http.StatusBadRequest, Message: "unknown message type " + strconv.Quote(kind)})
+		return
+	}
+	if id == "" {
+		s.next++
+		id = "req-" + strconv.Itoa(s.next)
+	}
+	if _, busy := s.running[id]; busy {
+		s.send(Frame{Type: "error", ID: id, Status: http.StatusConflict, Message: "a reply with this id is still streaming"})
+		return
+	}
+	limit := s.handler.MaxConcurrent
+	if limit <= 0 {
+		limit = DefaultMaxConcurrent
+	}
+	if len(s.running) >= limit {
+		s.send(Frame{Type: "error", ID: id, Status: http.StatusTooManyRequests, Message: fmt.Sprintf("at most %d replies may stream at once", limit)})
+		return
+	}
+
+	body["stream"] = true
+	if _, set := body["stream_options"]; !set {
+		body["stream_options"] = map[string]any{"include_usage": true}
+	}
+	requestCtx, cancel := context.WithCancel(ctx)
+	s.running[id] = cancel
+	s.wait.Add(1)
+	go func() {
+		defer s.wait.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, id)
+			s.mu.Unlock()
+			cancel()
+		}()
+		s.complete(requestCtx, id, body)
+	}()
+}
+
+// complete runs one request through the backend and relays its reply
+func (s *chatSession) complete(ctx context.Context, id string, body map[string]any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		s.send(Frame{Type: "error", ID: id, Status: http.StatusBadRequest, Message: err.Error()})
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat/completions", bytes.NewReader(data))
+	if err != nil {
+		s.send(Frame{Type: "error", ID: id, Status: http.StatusInternalServerError, Message: err.Error()})
+		return
+	}
+	for _, name := range []string{"Authorization", "X-Api-Key", "X-Request-Id"} {
+		if value := s.header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = s.remote
+
+	relay := &relayWriter{header: http.Header{}, session: s, id: id}
+	s.handler.Backend.ServeHTTP(relay, req)
+	relay.finish(ctx)
+}
+
+// chunk is the part of a streamed or complete chat completion the relay forwards
+type chunk struct {
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content   string          `json:"content"`
+			ToolCalls json.RawMessage `json:"tool_calls"`
+		} `json:"delta"`
+		Message struct {
+			Content   string          `json:"content"`
+			ToolCalls json.RawMessage `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage json.RawMessage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// relayWriter turns the backend's event stream into frames as it arrives. Errors and
+// non-streamed replies are buffered and sent whole by finish.
+type relayWriter struct {
+	header       http.Header
+	status       int
+	streamed     bool
+	pending      []byte
+	body         bytes.Buffer
+	session      *chatSession
+	id           string
+	finishReason string
+	usage        json.RawMessage
+	failed       string
+}
+
+func (w *relayWriter) Header() http.Header { return w.header }
+
+func (w *relayWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	w.streamed = code < 300 && strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream")
+}
+
+func (w *relayWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if !w.streamed {
+		return w.body.Write(p)
+	}
+	w.pending = append(w.pending, p...)
+	for {
+		end := bytes.IndexByte(w.pending, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		line := bytes.TrimSpace(w.pending[:end])
+		w.pending = w.pending[end+1:]
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = bytes.TrimSpace(data)
+			if string(data) != "[DONE]" {
+				w.relay(data, false)
+			}
+		}
+	}
+}
+
+// Flush is a no-op: each delta is sent as its event arrives
+func (w *relayWriter) Flush() {}
+
+// relay sends the deltas of one chunk, or of a whole reply when complete is set
+func (w *relayWriter) relay(data []byte, complete bool) {
+	var c chunk
+	if err := json.Unmarshal(data, &c); err != nil {
+		return
+	}
+	if c.Error != nil {
+		w.failed = c.Error.Message
+		return
+	}
+	for _, choice := range c.Choices {
+		content, toolCalls := choice.Delta.Content, choice.Delta.ToolCalls
+		if complete {
+			content, toolCalls = choice.Message.Content, choice.Message.ToolCalls
+		}
+		if isNull(toolCalls) {
+			toolCalls = nil
+		}
+		if content != "" || toolCalls != nil {
+			w.session.send(Frame{Type: "delta", ID: w.id, Index: choice.Index, Content: content, ToolCalls: toolCalls})
+		}
+		if choice.FinishReason != nil && choice.Index == 0 {
+			w.finishReason = *choice.FinishReason
+		}
+	}
+	if !isNull(c.Usage) {
+		w.usage = c.Usage
+	}
+}
+
+func isNull(raw json.RawMessage) bool {
+	return len(raw) == 0 || string(raw) == "null"
+}
+
+// finish sends the closing frame of the reply: done, or error with the backend's message
+func (w *relayWriter) finish(ctx context.Context) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	switch {
+	case w.status >= 300:
+		message := strings.TrimSpace(w.body.String())
+		var c chunk
+		if json.Unmarshal(w.body.Bytes(), &c) == nil && c.Error != nil && c.Error.Message != "" {
+			message = c.Error.Message
+		}
+		w.session.send(Frame{Type: "error", ID: w.id, Status: w.status, Message: message})
+		return
+	case !w.streamed:
+		w.relay(w.body.Bytes(), true)
+	}
+	switch {
+	case w.failed != "":
+		w.session.send(Frame{Type: "error", ID: w.id, Status: http.StatusBadGateway, Message: w.failed})
+	case ctx.Err() != nil:
+		w.session.send(Frame{Type: "done", ID: w.id, FinishReason: "cancelled"})
+	default:
+		w.session.send(Frame{Type: "done", ID: w.id, FinishReason: w.finishReason, Usage: w.usage})
+	}
+}
@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// streamingBackend answers like the inference gateway: an event stream for a known model,
+// an OpenAI error otherwise
+func streamingBackend(seen *map[string]any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, seen)
+		if !strings.Contains(string(body), `"phi-3"`) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"model not found","code":"model_not_found"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}`,
+			`{"choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":3,"completion_tokens":2}}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+}
+
+func (c *client) frame() Frame {
+	c.t.Helper()
+	op, payload := c.read()
+	if op != OpText {
+		c.t.Fatalf("frame opcode %d: %q", op, payload)
+	}
+	var f Frame
+	if err := json.Unmarshal([]byte(payload), &f); err != nil {
+		c.t.Fatal(err)
+	}
+	return f
+}
+
+func TestChatRelaysDeltasAndErrors(t *testing.T) {
+	var seen map[string]any
+	server := httptest.NewServer(NewChatHandler(context.Background(), streamingBackend(&seen)))
+	defer server.Close()
+	c := dial(t, server, nil)
+
+	c.write(OpText, true, `{"id":"c1","model":"phi-3","messages":[{"role":"user","content":"hi"}]}`)
+	var text string
+	for _, want := range []string{"delta", "delta", "done"} {
+		f := c.frame()
+		if f.Type != want || f.ID != "c1" {
+			t.Fatalf("frame %+v, want %s for c1", f, want)
+		}
+		text += f.Content
+		if f.Type == "done" && (f.FinishReason != "stop" || !strings.Contains(string(f.Usage), `"completion_tokens":2`)) {
+			t.Errorf("done = %+v", f)
+		}
+	}
+	if text != "Hello" {
+		t.Errorf("text = %q", text)
+	}
+	if seen["stream"] != true || seen["id"] != nil {
+		t.Errorf("forwarded body = %v", seen)
+	}
+
+	c.write(OpText, true, `{"model":"llama","messages":[]}`)
+	if f := c.frame(); f.Type != "error" || f.ID != "req-1" || f.Status != http.StatusNotFound || f.Message != "model not found" {
+		t.Errorf("error frame = %+v", f)
+	}
+}
+
+func TestChatRejectsForeignOrigins(t *testing.T) {
+	server := httptest.NewServer(NewChatHandler(context.Background(), http.NotFoundHandler()))
+	defer server.Close()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+ChatPath, nil)
+	req.Header.Set("Origin", "https://evil.example")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+	dial(t, server, http.Header{"Origin": {server.URL}})
+}
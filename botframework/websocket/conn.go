@@ -0,0 +1,298 @@
+// Package websocket is a server-side WebSocket (RFC 6455) implementation and a chat endpoint
+// that streams completions over it, for clients that prefer sockets to server-sent events.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Opcodes of the frames a message or control signal is sent in
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// Close status codes
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	CloseUnsupported   = 1003
+	CloseInvalidData   = 1007
+	ClosePolicy        = 1008
+	CloseTooBig        = 1009
+	CloseInternalError = 1011
+)
+
+// DefaultMaxMessage bounds a message a client may send
+const DefaultMaxMessage = 1 << 20
+
+// acceptGUID is appended to the client's key to prove the server speaks WebSocket
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// CloseError is returned by ReadMessage once the peer closed the connection
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed: %d %s", e.Code, e.Reason)
+}
+
+var errUpgrade = errors.New("not a websocket handshake")
+
+// Conn is a server side WebSocket connection. One goroutine may read while others write.
+type Conn struct {
+	// MaxMessage bounds a message assembled by ReadMessage (DefaultMaxMessage when 0)
+	MaxMessage int
+	// OnPong is called for each pong received, for keepalive bookkeeping
+	OnPong func()
+
+	conn net.Conn
+	r    *bufio.Reader
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// Upgrade completes the opening handshake of r and takes over its connection. On failure
+// the client has been answered with an HTTP error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !headerHas(r.Header, "Connection", "upgrade") || !headerHas(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errUpgrade
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errUpgrade
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errUpgrade
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket not supported on this connection", http.StatusInternalServerError)
+		return nil, err
+	}
+	// the server's read and write deadlines were meant for the HTTP request
+	_ = netConn.SetDeadline(time.Time{})
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return &Conn{conn: netConn, r: rw.Reader}, nil
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerHas reports whether a comma-separated header lists token, ignoring case
+func headerHas(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReadDeadline fails a pending or later ReadMessage once t passes
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// ReadMessage returns the next text or binary message, answering pings and passing pongs
+// to OnPong along the way. Protocol violations close the connection with the matching
+// status; a close from the peer is acknowledged and returned as a *CloseError.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	limit := c.MaxMessage
+	if limit <= 0 {
+		limit = DefaultMaxMessage
+	}
+	var opcode int
+	var message []byte
+	for {
+		fin, op, payload, err := c.readFrame(limit - len(message))
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case OpPing:
+			if err := c.WriteMessage(OpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			if c.OnPong != nil {
+				c.OnPong()
+			}
+			continue
+		case OpClose:
+			closeErr := &CloseError{Code: 1005}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			_ = c.Close(CloseNormal, "")
+			return 0, nil, closeErr
+		case OpText, OpBinary:
+			if opcode != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "new message inside a fragmented one")
+			}
+			opcode = op
+		case OpContinuation:
+			if opcode == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation without a message")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", op))
+		}
+		message = append(message, payload...)
+		if fin {
+			if opcode == OpText && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidData, "text message is not UTF-8")
+			}
+			return opcode, message, nil
+		}
+	}
+}
+
+// readFrame reads one frame, unmasking its payload. Data frames larger than room are
+// refused.
+func (c *Conn) readFrame(room int) (bool, int, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := head[0]&0x80 != 0
+	op := int(head[0] & 0x0F)
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	control := op >= OpClose
+	if control && (!fin || length > 125) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if !control && length > uint64(max(room, 0)) {
+		return false, 0, nil, c.fail(CloseTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// fail closes the connection with code and returns the matching error
+func (c *Conn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// WriteMessage sends payload as a single unmasked frame
+func (c *Conn) WriteMessage(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	return c.writeFrame(opcode, payload)
+}
+
+// writeFrame sends one frame; c.writeMu must be held
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|byte(opcode))
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	frame = append(frame, payload...)
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame with code and reason and closes the connection. Later writes
+// fail; closing twice is harmless.
+func (c *Conn) Close(code int, reason string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload = append(payload, reason...)
+	_ = c.writeFrame(OpClose, payload)
+	return c.conn.Close()
+}
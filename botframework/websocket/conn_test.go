@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client is the test side of a connection: it masks what it sends, as browsers do
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dial(t *testing.T, server *httptest.Server, header http.Header) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest(http.MethodGet, server.URL+ChatPath, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake: %s", resp.Status)
+	}
+	// the RFC 6455 example key and its accept value
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{t: t, conn: conn, r: r}
+}
+
+func (c *client) write(opcode int, fin bool, payload string) {
+	head := byte(opcode)
+	if fin {
+		head |= 0x80
+	}
+	frame := []byte{head}
+	if n := len(payload); n <= 125 {
+		frame = append(frame, 0x80|byte(n))
+	} else {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i := range len(payload) {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) read() (int, string) {
+	c.t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		io.ReadFull(c.r, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return int(head[0] & 0x0F), string(payload)
+}
+
+// echo serves a connection that sends every message back
+func echo(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		conn.MaxMessage = 64
+		for {
+			opcode, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(opcode, message)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFramesPingsAndFragments(t *testing.T) {
+	c := dial(t, echo(t), nil)
+
+	c.write(OpPing, true, "are you there")
+	if op, payload := c.read(); op != OpPong || payload != "are you there" {
+		t.Fatalf("ping answered with %d %q", op, payload)
+	}
+	c.write(OpText, false, "hel")
+	c.write(OpPing, true, "")
+	c.write(OpContinuation, true, "lo")
+	if op, _ := c.read(); op != OpPong {
+		t.Fatalf("ping between fragments answered with %d", op)
+	}
+	if op, payload := c.read(); op != OpText || payload != "hello" {
+		t.Fatalf("echo = %d %q", op, payload)
+	}
+
+	c.write(OpText, true, strings.Repeat("x", 65))
+	op, payload := c.read()
+	if op != OpClose || binary.BigEndian.Uint16([]byte(payload)) != CloseTooBig {
+		t.Errorf("oversized message: frame %d %q", op, payload)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	resp, err := http.Get(echo(t).URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,153 @@
+"""gRPC transport for the worker (``--protocol grpc``), serving proto/inference.proto."""
+from __future__ import annotations
+
+# pylint: disable=import-error,no-member
+import os
+import sys
+import tempfile
+import threading
+import time
+import uuid
+from concurrent import futures
+from typing import Any, Callable, Iterator, Optional
+
+import grpc
+from grpc_tools import protoc
+
+PROTO_DIR = os.path.join(os.path.dirname(os.path.dirname(os.path.abspath(__file__))), "proto")
+
+
+def load_protocol():
+    """Compile inference.proto into a temporary package and import its modules."""
+    out_dir = tempfile.mkdtemp(prefix="botframework-proto-")
+    status = protoc.main([
+        "grpc_tools.protoc",
+        f"-I{PROTO_DIR}",
+        f"--python_out={out_dir}",
+        f"--grpc_python_out={out_dir}",
+        os.path.join(PROTO_DIR, "inference.proto"),
+    ])
+    if status != 0:
+        raise RuntimeError("failed to compile inference.proto")
+    sys.path.insert(0, out_dir)
+    import inference_pb2  # type: ignore  # pylint: disable=import-outside-toplevel
+    import inference_pb2_grpc  # type: ignore  # pylint: disable=import-outside-toplevel
+    return inference_pb2, inference_pb2_grpc
+
+
+def sampling_args(request: Any) -> dict[str, Any]:
+    """Translate the optional sampling fields into llama-cpp keyword arguments."""
+    return {
+        "temperature": request.temperature if request.HasField("temperature") else 0.7,
+        "top_p": request.top_p if request.HasField("top_p") else 0.95,
+        "top_k": request.top_k if request.HasField("top_k") else 40,
+        "max_tokens": request.max_tokens if request.HasField("max_tokens") else None,
+        "repeat_penalty": request.repeat_penalty if request.HasField("repeat_penalty") else 1.1,
+        "seed": request.seed if request.HasField("seed") else None,
+        "stop": list(request.stop) or None,
+    }
+
+
+def serve(
+    get_llm: Callable[[], Optional[Any]],
+    llm_lock: threading.Lock,
+    model_name: str,
+    host: str,
+    port: int,
+) -> None:
+    """Serve the inference service until the process is terminated. Calls run on a thread
+    pool, so each holds llm_lock while it uses the model."""
+    pb, pb_grpc = load_protocol()
+
+    def usage_of(result: dict[str, Any]):
+        usage = result.get("usage") or {}
+        return pb.Usage(
+            prompt_tokens=usage.get("prompt_tokens", 0),
+            completion_tokens=usage.get("completion_tokens", 0),
+        )
+
+    class Inference(pb_grpc.InferenceServicer):
+        """Runs generations on the worker's llama-cpp model."""
+
+        def _require_llm(self, context: grpc.ServicerContext):
+            llm = get_llm()
+            if llm is None:
+                context.abort(grpc.StatusCode.UNAVAILABLE, "no model loaded")
+            return llm
+
+        def _run(self, request: Any, llm: Any, stream: bool, context: grpc.ServicerContext):
+            # a call cancelled by the manager (client gone, deadline passed) stops generating
+            from llama_cpp import StoppingCriteriaList  # pylint: disable=import-outside-toplevel
+
+            cancelled = StoppingCriteriaList([lambda _tokens, _logits: not context.is_active()])
+            if request.messages:
+                messages = [{"role": m.role, "content": m.content} for m in request.messages]
+                return llm.create_chat_completion(
+                    messages=messages, stream=stream, stopping_criteria=cancelled,
+                    **sampling_args(request)
+                ), True
+            return llm.create_completion(
+                prompt=request.prompt, stream=stream, stopping_criteria=cancelled,
+                **sampling_args(request)
+            ), False
+
+        def Generate(self, request, context):  # noqa: N802
+            llm = self._require_llm(context)
+            with llm_lock:
+                result, chat = self._run(request, llm, stream=False, context=context)
+            choice = result["choices"][0]
+            text = choice["message"]["content"] if chat else choice["text"]
+            return pb.GenerateResponse(
+                id=result.get("id", f"chatcmpl-{uuid.uuid4().hex}"),
+                model=model_name,
+                text=text or "",
+                finish_reason=choice.get("finish_reason") or "stop",
+                usage=usage_of(result),
+            )
+
+        def StreamGenerate(self, request, context) -> Iterator[Any]:  # noqa: N802
+            llm = self._require_llm(context)
+            with llm_lock:
+                stream, chat = self._run(request, llm, stream=True, context=context)
+                completion_tokens = 0
+                for chunk in stream:
+                    if not context.is_active():
+                        return
+                    choice = chunk["choices"][0]
+                    delta = choice.get("delta") or {}
+                    text = delta.get("content") if chat else choice.get("text")
+                    finish = choice.get("finish_reason") or ""
+                    if text:
+                        completion_tokens += 1
+                    reply = pb.GenerateChunk(text=text or "", finish_reason=finish)
+                    if finish:
+                        reply.usage.CopyFrom(pb.Usage(completion_tokens=completion_tokens))
+                    yield reply
+
+        def Embed(self, request, context):  # noqa: N802
+            llm = self._require_llm(context)
+            try:
+                with llm_lock:
+                    result = llm.create_embedding(list(request.input))
+            except Exception as exc:  # pylint: disable=broad-exception-caught
+                context.abort(grpc.StatusCode.FAILED_PRECONDITION, str(exc))
+            data = [pb.Embedding(values=item["embedding"]) for item in result["data"]]
+            return pb.EmbedResponse(model=model_name, data=data, usage=usage_of(result))
+
+        def Health(self, request, context):  # noqa: N802
+            llm = get_llm()
+            return pb.HealthResponse(
+                status="ok" if llm else "mock_mode",
+                model_loaded=llm is not None,
+                model=model_name,
+            )
+
+    server = grpc.server(futures.ThreadPoolExecutor(max_workers=8))
+    pb_grpc.add_InferenceServicer_to_server(Inference(), server)
+    server.add_insecure_port(f"{host}:{port}")
+    server.start()
+    try:
+        while True:
+            time.sleep(3600)
+    except KeyboardInterrupt:
+        server.stop(grace=5)
@@ -1,28 +1,37 @@
-"""Worker service entrypoint for chat completions."""
+"""Worker service entrypoint for chat completions and embeddings."""
 from __future__ import annotations
 
 # pylint: disable=import-error,wrong-import-position
 import argparse
+import hashlib
 import json
 import os
+import socket
 import sys
+import threading
 import time
 from contextlib import asynccontextmanager
 from typing import Optional, Sequence, TYPE_CHECKING
 
 import uvicorn
-from fastapi import FastAPI
-from fastapi.responses import StreamingResponse
+from fastapi import FastAPI, Header
+from fastapi.responses import JSONResponse, StreamingResponse
+from starlette.concurrency import run_in_threadpool
 
 # Add the parent directory to sys.path to allow imports from botframework
 sys.path.append(os.path.dirname(os.path.dirname(os.path.abspath(__file__))))
 
 from rest.schemas import (
+    AbortRequest,
     ChatCompletionRequest,
     ChatCompletionResponse,
     ChatCompletionResponseChoice,
     ChatCompletionUsage,
     ChatMessage,
+    EmbeddingData,
+    EmbeddingRequest,
+    EmbeddingResponse,
+    EmbeddingUsage,
     HealthResponse,
     LlamaMessage,
 )
@@ -35,13 +44,28 @@ if TYPE_CHECKING:
 
 try:
     from llama_cpp import Llama as _LlamaRuntime
+    from llama_cpp import StoppingCriteriaList
 except ImportError:
     _LlamaRuntime = None
+    StoppingCriteriaList = None
 
 
 # Global LLM instance (typed strictly as Llama)
 llm: Optional["Llama"] = None
 loaded_model_name = "mock"
+# Llama is not thread-safe: generations run on worker threads (the manager may send several
+# at once, and warmup and liveness probes bypass its queue), so every llm call holds this
+llm_lock = threading.Lock()
+# "chat" or "embedding"; an embedding worker loads its model for embeddings only
+worker_mode = "chat"
+
+# Vector size of the embeddings served in mock mode
+MOCK_EMBEDDING_DIMENSIONS = 8
+
+# X-Request-IDs of the requests generating, and of those among them the manager abandoned
+# because the client disconnected or the request timed out; these stop at the next token
+running_requests: set[str] = set()
+aborted_requests: set[str] = set()
 
 
 @asynccontextmanager
@@ -56,9 +80,13 @@ async def lifespan(_app: FastAPI):
 app = FastAPI(title="BotFramework Worker", lifespan=lifespan)
 
 @app.post("/v1/chat/completions")
-async def chat_completions(request: ChatCompletionRequest):
+async def chat_completions(
+    request: ChatCompletionRequest,
+    traceparent: Optional[str] = Header(default=None),
+    x_request_id: Optional[str] = Header(default=None),
+):
     """Handle chat completion requests."""
-    print(f"📥 Received request for model: {request.model}")
+    print(f"📥 Received request for model: {request.model}{trace_suffix(traceparent)}")
 
     if llm is None:
         # Fallback for mock mode if model failed to load or lib missing
@@ -71,58 +99,126 @@ async def chat_completions(request: ChatCompletionRequest):
 
     if request.stream:
         return StreamingResponse(
-            stream_chat_response(messages, request),
+            stream_chat_response(messages, request, x_request_id),
             media_type="text/event-stream"
         )
-    return create_chat_response(messages, request)
+    # generation runs off the event loop so /abort can be served meanwhile
+    return await run_in_threadpool(create_chat_response, messages, request, x_request_id)
+
+@app.post("/abort")
+async def abort(request: AbortRequest):
+    """Stop generating for a request the manager abandoned."""
+    if request.request_id not in running_requests:
+        return {"aborted": None}
+    print(f"⏹️  Aborting request {request.request_id}")
+    aborted_requests.add(request.request_id)
+    return {"aborted": request.request_id}
+
+def abort_criteria(request_id: Optional[str]):
+    """Track request_id as running and return stopping criteria that end its generation
+    once it is aborted."""
+    if not request_id or StoppingCriteriaList is None:
+        return None
+    running_requests.add(request_id)
+    return StoppingCriteriaList([lambda _tokens, _logits: request_id in aborted_requests])
 
 def create_chat_response(
     messages: Sequence["ChatCompletionRequestMessage"],
     request: ChatCompletionRequest,
+    request_id: Optional[str] = None,
 ):
     """Create a non-streaming chat completion response."""
     assert llm is not None  # For type checker
     temperature = 0.7 if request.temperature is None else request.temperature
     top_k = 40 if request.top_k is None else request.top_k
     repeat_penalty = 1.1 if request.repeat_penalty is None else request.repeat_penalty
-    response = llm.create_chat_completion(
-        messages=messages,
-        temperature=temperature,
-        top_p=request.top_p,
-        top_k=top_k,
-        max_tokens=request.max_tokens,
-        stop=request.stop,
-        repeat_penalty=repeat_penalty,
-        stream=False
-    )
-    return response
+    try:
+        with llm_lock:
+            return llm.create_chat_completion(
+                messages=messages,
+                temperature=temperature,
+                top_p=request.top_p,
+                top_k=top_k,
+                max_tokens=request.max_tokens,
+                stop=request.stop,
+                repeat_penalty=repeat_penalty,
+                response_format=request.response_format,
+                stopping_criteria=abort_criteria(request_id),
+                stream=False
+            )
+    finally:
+        finish_request(request_id)
+
+def finish_request(request_id: Optional[str]):
+    """Forget a request once its generation has ended."""
+    running_requests.discard(request_id or "")
+    aborted_requests.discard(request_id or "")
 
 def stream_chat_response(
     messages: Sequence["ChatCompletionRequestMessage"],
     request: ChatCompletionRequest,
+    request_id: Optional[str] = None,
 ):
     """Stream chat completion chunks as server-sent events."""
     assert llm is not None  # For type checker
     temperature = 0.7 if request.temperature is None else request.temperature
     top_k = 40 if request.top_k is None else request.top_k
     repeat_penalty = 1.1 if request.repeat_penalty is None else request.repeat_penalty
-    stream = llm.create_chat_completion(
-        messages=messages,
-        temperature=temperature,
-        top_p=request.top_p,
-        top_k=top_k,
-        max_tokens=request.max_tokens,
-        stop=request.stop,
-        repeat_penalty=repeat_penalty,
-        stream=True
-    )
+    # the lock is held until the stream ends or the client goes away and the generator closes
+    with llm_lock:
+        stream = llm.create_chat_completion(
+            messages=messages,
+            temperature=temperature,
+            top_p=request.top_p,
+            top_k=top_k,
+            max_tokens=request.max_tokens,
+            stop=request.stop,
+            repeat_penalty=repeat_penalty,
+            response_format=request.response_format,
+            stopping_criteria=abort_criteria(request_id),
+            stream=True
+        )
+
+        # llama-cpp-python returns dicts that match OpenAI format. Each chunk is held back
+        # until the next arrives, so the last one can carry the timings in llama-server's
+        # format: the wait for the first token is prefill, the rest is decode.
+        started = time.perf_counter()
+        first_token = None
+        previous = None
+        tokens = 0
+        try:
+            for chunk in stream:
+                if request_id in aborted_requests:
+                    return
+                if first_token is None:
+                    first_token = time.perf_counter()
+                choices = chunk.get("choices") or [{}]
+                if choices[0].get("delta", {}).get("content"):
+                    tokens += 1
+                if previous is not None:
+                    yield f"data: {json.dumps(previous)}\n\n"
+                previous = chunk
+        finally:
+            finish_request(request_id)
 
-    for chunk in stream:
-        # llama-cpp-python returns dicts that match OpenAI format
-        yield f"data: {json.dumps(chunk)}\n\n"
+    if previous is not None:
+        finished = time.perf_counter()
+        previous["timings"] = {
+            "prompt_ms": (first_token - started) * 1000,
+            "predicted_n": tokens,
+            "predicted_ms": (finished - first_token) * 1000,
+        }
+        yield f"data: {json.dumps(previous)}\n\n"
 
     yield "data: [DONE]\n\n"
 
+def trace_suffix(traceparent: Optional[str]) -> str:
+    """Return the trace ID from a W3C traceparent header for log lines, or nothing."""
+    parts = (traceparent or "").split("-")
+    if len(parts) >= 4 and len(parts[1]) == 32:
+        return f" (trace {parts[1]})"
+    return ""
+
 def mock_response(request: ChatCompletionRequest) -> ChatCompletionResponse:
     """Return a mock response when the model is unavailable."""
     return ChatCompletionResponse(
@@ -151,6 +247,54 @@ def mock_response(request: ChatCompletionRequest) -> ChatCompletionResponse:
     )
 
 
+@app.post("/v1/embeddings")
+async def embeddings(request: EmbeddingRequest):
+    """Handle embedding requests."""
+    inputs = [request.input] if isinstance(request.input, str) else request.input
+    if llm is None:
+        return mock_embeddings(inputs)
+    if worker_mode != "embedding":
+        return JSONResponse(
+            status_code=400,
+            content={"error": {
+                "message": "this worker serves chat; start an embedding worker with --mode embedding",
+                "type": "invalid_request_error",
+                "code": "embeddings_unsupported",
+            }},
+        )
+    result = await run_in_threadpool(create_embedding, inputs)
+    return EmbeddingResponse(
+        model=loaded_model_name,
+        data=[
+            EmbeddingData(index=i, embedding=item["embedding"])
+            for i, item in enumerate(result["data"])
+        ],
+        usage=EmbeddingUsage(
+            prompt_tokens=result["usage"]["prompt_tokens"],
+            total_tokens=result["usage"]["total_tokens"],
+        ),
+    )
+
+def create_embedding(inputs: Sequence[str]):
+    """Embed inputs on the loaded model, one call at a time."""
+    assert llm is not None  # For type checker
+    with llm_lock:
+        return llm.create_embedding(list(inputs))
+
+def mock_embeddings(inputs: Sequence[str]) -> EmbeddingResponse:
+    """Return stable pseudo-embeddings derived from each input's hash."""
+    data = []
+    for i, text in enumerate(inputs):
+        digest = hashlib.sha256(text.encode("utf-8")).digest()
+        vector = [b / 127.5 - 1 for b in digest[:MOCK_EMBEDDING_DIMENSIONS]]
+        data.append(EmbeddingData(index=i, embedding=vector))
+    return EmbeddingResponse(
+        model=loaded_model_name,
+        data=data,
+        usage=EmbeddingUsage(prompt_tokens=0, total_tokens=0),
+    )
+
+
 @app.get("/health", response_model=HealthResponse)
 async def health() -> HealthResponse:
     """Simple health check endpoint."""
@@ -163,6 +307,12 @@ async def health() -> HealthResponse:
 
 if __name__ == "__main__":
     parser = argparse.ArgumentParser()
+    parser.add_argument(
+        "--host",
+        type=str,
+        default="127.0.0.1",
+        help="Interface to bind (use 0.0.0.0 when scheduled on a cluster node)",
+    )
     parser.add_argument(
         "--port",
         type=int,
@@ -181,14 +331,33 @@ if __name__ == "__main__":
         default=-1,
         help="Number of layers to offload to GPU (-1 for all)",
     )
+    parser.add_argument(
+        "--protocol",
+        choices=("http", "grpc"),
+        default="http",
+        help="Serve the OpenAI-compatible HTTP API or the gRPC protocol in proto/inference.proto",
+    )
+    parser.add_argument(
+        "--mode",
+        choices=("chat", "embedding"),
+        default="chat",
+        help="Load the model for chat completions or for /v1/embeddings",
+    )
     parser.add_argument(
         "--n-ctx",
         type=int,
         default=2048,
         help="Context window size",
     )
+    parser.add_argument(
+        "--n-batch",
+        type=int,
+        default=512,
+        help="Prompt tokens processed per step",
+    )
 
     args = parser.parse_args()
+    worker_mode = args.mode
 
     if args.model_path and _LlamaRuntime:
         if os.path.exists(args.model_path):
@@ -198,6 +367,8 @@ if __name__ == "__main__":
                     model_path=args.model_path,
                     n_gpu_layers=args.n_gpu_layers,
                     n_ctx=args.n_ctx,
+                    n_batch=args.n_batch,
+                    embedding=worker_mode == "embedding",
                     verbose=True
                 )
                 loaded_model_name = os.path.basename(args.model_path)
@@ -212,5 +383,12 @@ if __name__ == "__main__":
             "Starting in Mock Mode."
         )
 
-    print(f"Worker starting on port {args.port}...")
-    uvicorn.run(app, host="127.0.0.1", port=args.port)
+    # The manager discovers cluster-scheduled workers from this line
+    announce_host = socket.gethostname() if args.host == "0.0.0.0" else args.host
+    print(f"Worker starting on {announce_host}:{args.port}...", flush=True)
+    if args.protocol == "grpc":
+        from grpc_server import serve  # pylint: disable=import-outside-toplevel
+
+        serve(lambda: llm, llm_lock, loaded_model_name, args.host, args.port)
+    else:
+        uvicorn.run(app, host=args.host, port=args.port)